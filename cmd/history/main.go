@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/output"
+	"github.com/alysoliman1/money-pies/internal/pkg/store"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+func main() {
+	storePath := flag.String("store", "", "path to the store database")
+	pieKey := flag.String("pie", "", "only list rebalances of the pie with this ID or name")
+	format := output.FlagWithCSV(flag.CommandLine)
+	flag.Parse()
+	msgs := format.Messages()
+
+	if *storePath == "" {
+		fmt.Fprintln(msgs, "Store not specified, pass --store")
+		os.Exit(2)
+	}
+
+	db, err := store.Open(*storePath)
+	if err != nil {
+		fmt.Fprintln(msgs, err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	executions, err := db.Executions(*pieKey)
+	if err != nil {
+		fmt.Fprintln(msgs, "failed to read history:", err)
+		os.Exit(1)
+	}
+	if format.IsCSV() {
+		reports := make([]*pies.ExecutionReport, len(executions))
+		for i, execution := range executions {
+			reports[i] = execution.Report
+		}
+		if err := pies.WriteExecutionReportCSV(os.Stdout, reports...); err != nil {
+			fmt.Fprintln(msgs, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if format.IsJSON() {
+		if executions == nil {
+			executions = []store.ExecutionRecord{}
+		}
+		if err := output.WriteJSON(os.Stdout, executions); err != nil {
+			fmt.Fprintln(msgs, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(executions) == 0 {
+		fmt.Println("No rebalances recorded")
+		return
+	}
+
+	printHistory(os.Stdout, executions)
+}
+
+// printHistory writes one line per executed rebalance followed by the totals
+func printHistory(w io.Writer, executions []store.ExecutionRecord) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Time\tPie\tAccount\tOrders\tFailed\tBought\tSold\t")
+
+	var totalOrders, totalFailed int
+	var totalBought, totalSold decimal.Decimal
+	for _, execution := range executions {
+		report := execution.Report
+		var failed int
+		var bought, sold decimal.Decimal
+		for _, order := range report.Orders {
+			if order.Error != "" {
+				failed++
+			}
+			if order.Request.Action == pies.OrderActionSell {
+				sold = sold.Add(order.FilledValue())
+			} else {
+				bought = bought.Add(order.FilledValue())
+			}
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\t%s\t\n",
+			execution.Time.Local().Format(time.DateTime), report.Pie, report.AccountID,
+			len(report.Orders), failed, bought.StringFixed(2), sold.StringFixed(2))
+
+		totalOrders += len(report.Orders)
+		totalFailed += failed
+		totalBought = totalBought.Add(bought)
+		totalSold = totalSold.Add(sold)
+	}
+
+	fmt.Fprintf(tw, "Total\t\t\t%d\t%d\t%s\t%s\t\n", totalOrders, totalFailed, totalBought.StringFixed(2), totalSold.StringFixed(2))
+	tw.Flush()
+}
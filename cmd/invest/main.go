@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages"
+	"github.com/alysoliman1/money-pies/internal/pkg/cli"
+	"github.com/alysoliman1/money-pies/internal/pkg/config"
+	"github.com/alysoliman1/money-pies/internal/pkg/logging"
+	"github.com/alysoliman1/money-pies/internal/pkg/output"
+	"github.com/alysoliman1/money-pies/internal/pkg/store"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+func main() {
+	piePath := flag.String("pie", "", "path to the pie definition file")
+	amountFlag := flag.Float64("amount", 0, "dollars of the account's cash to invest")
+	useAvailable := flag.Bool("use-available", false, "invest the account's available cash when it is less than --amount, or all of it without --amount")
+	fractional := flag.Bool("fractional", false, "place the buys as dollar amounts, for brokerages that trade fractional shares; fund buys always are")
+	accountName := flag.String("account", "", "number or nickname of the account to invest in, needed when the brokerage has several")
+	dryRun := flag.Bool("dry-run", false, "simulate the orders at the current quotes instead of placing them")
+	storePath := flag.String("store", "", "path to a store database to record the execution in")
+	safety := cli.SafetyFlags(flag.CommandLine)
+	overrides := config.Flags(flag.CommandLine)
+	logOptions := logging.Flags(flag.CommandLine, slog.LevelWarn)
+	format := output.Flag(flag.CommandLine)
+	flag.Parse()
+	logger := logOptions.Setup(os.Stderr)
+	msgs := format.Messages()
+
+	if *piePath == "" {
+		fmt.Fprintln(msgs, "Pie file not specified, pass --pie")
+		os.Exit(2)
+	}
+	if *amountFlag <= 0 && !*useAvailable {
+		fmt.Fprintln(msgs, "Amount not specified, pass --amount or --use-available")
+		os.Exit(2)
+	}
+	pie, err := pies.LoadPie(*piePath)
+	if err != nil {
+		fmt.Fprintln(msgs, err)
+		os.Exit(1)
+	}
+	risk, err := safety.RiskChecker(pie)
+	if err != nil {
+		fmt.Fprintln(msgs, err)
+		os.Exit(1)
+	}
+
+	client, brokerageName, err := brokerages.Open(*overrides)
+	if err != nil {
+		cli.PrintError(msgs, brokerageName, err)
+		os.Exit(1)
+	}
+	if *fractional {
+		if trader, ok := client.(pies.FractionalTrader); !ok || !trader.SupportsFractionalShares() {
+			fmt.Fprintf(msgs, "%s does not trade fractional shares, invest without --fractional\n", brokerageName)
+			os.Exit(2)
+		}
+	}
+	if *dryRun {
+		client = pies.NewDryRunClient(client, nil)
+	}
+	investor := &pies.Investor{BrokerageClient: client, Logger: logger, Risk: risk}
+	if !*dryRun {
+		investor.Notifier, err = brokerages.OpenNotifier(*overrides)
+		if err != nil {
+			fmt.Fprintln(msgs, err)
+			os.Exit(1)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var account pies.Account
+	if *accountName != "" {
+		account, err = investor.SelectAccountByName(ctx, *accountName)
+	} else {
+		account, err = investor.SelectAccount(ctx, "", "")
+	}
+	if err != nil {
+		cli.PrintError(msgs, brokerageName, err)
+		os.Exit(1)
+	}
+
+	available := account.CashBalance
+	amount := pies.NewDecimal(*amountFlag)
+	switch {
+	case !available.IsPositive():
+		fmt.Fprintf(msgs, "Account %s has no cash to invest\n", account.ID())
+		os.Exit(1)
+	case *useAvailable && (amount.IsZero() || amount.GreaterThan(available)):
+		amount = available
+	case amount.GreaterThan(available):
+		fmt.Fprintf(msgs, "Account %s has %s available, less than %s; pass --use-available to invest what is there\n",
+			account.ID(), available.StringFixed(2), amount.StringFixed(2))
+		os.Exit(1)
+	}
+
+	plan, err := investor.AllocateCash(ctx, pie, amount)
+	if err != nil {
+		cli.PrintError(msgs, brokerageName, fmt.Errorf("failed to allocate %s: %w", amount.StringFixed(2), err))
+		os.Exit(1)
+	}
+	plan = plan.Notional(*fractional)
+	printPlan(msgs, plan, amount)
+
+	if len(plan.Orders) == 0 {
+		cli.WriteResult(*format, result{Plan: plan})
+		return
+	}
+
+	if err := safety.CheckTotal(plan); err != nil {
+		fmt.Fprintln(msgs, err)
+		os.Exit(1)
+	}
+	if err := cli.CheckRisk(ctx, msgs, investor, plan); err != nil {
+		cli.PrintError(msgs, brokerageName, err)
+		os.Exit(1)
+	}
+	if err := confirm(msgs, *dryRun, safety, account, plan); err != nil {
+		if errors.Is(err, cli.ErrDeclined) {
+			fmt.Fprintln(msgs, "Nothing was bought")
+		} else {
+			fmt.Fprintln(msgs, err)
+		}
+		os.Exit(1)
+	}
+
+	if *storePath != "" {
+		db, err := store.Open(*storePath)
+		if err != nil {
+			fmt.Fprintln(msgs, err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		investor.History = db
+	}
+
+	fmt.Fprintln(msgs)
+	report, err := investor.ExecutePlan(ctx, plan, pies.ExecuteOptions{
+		CashAccount: account.Kind == pies.AccountKindCash,
+		OnUpdate:    output.Progress(msgs),
+	})
+	if report != nil {
+		fmt.Fprintln(msgs)
+		output.PrintReport(msgs, report)
+		cli.WriteResult(*format, result{Plan: plan, Report: report})
+	}
+	if err != nil {
+		cli.PrintError(msgs, brokerageName, err)
+		os.Exit(1)
+	}
+}
+
+// result is what invest writes with --output json
+type result struct {
+	Plan   *pies.RebalancePlan   `json:"plan"`
+	Report *pies.ExecutionReport `json:"report,omitempty"` // Only once the buys are placed
+}
+
+// confirm has the plan's buys confirmed, the live ones through the shared
+// safety prompt and simulated ones with a plain yes or no
+func confirm(w io.Writer, dryRun bool, safety *cli.Safety, account pies.Account, plan *pies.RebalancePlan) error {
+	if !dryRun {
+		return safety.Confirm(w, os.Stdin, account, plan)
+	}
+	if safety.Yes {
+		return nil
+	}
+	confirmed, err := output.Confirm(w, os.Stdin, fmt.Sprintf("\nSimulate these %d buys?", len(plan.Orders)))
+	if err == nil && !confirmed {
+		err = cli.ErrDeclined
+	}
+	return err
+}
+
+// printPlan writes the planned buys and what is left of amount once they
+// fill
+func printPlan(w io.Writer, plan *pies.RebalancePlan, amount decimal.Decimal) {
+	if len(plan.Orders) == 0 {
+		fmt.Fprintln(w, "Nothing to buy")
+	} else {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "Symbol\tBuy\tPrice\tValue\t")
+		for _, planned := range plan.Orders {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n",
+				planned.Order.Symbol, output.OrderSize(planned.Order), planned.Price.StringFixed(2), planned.Value.StringFixed(2))
+		}
+		tw.Flush()
+	}
+	for _, skipped := range plan.Skipped {
+		fmt.Fprintf(w, "Skipped %s %s %s: %s\n", skipped.Action, skipped.Quantity, skipped.Symbol, skipped.Reason)
+	}
+
+	fmt.Fprintf(w, "\nInvesting: %s\n", amount.StringFixed(2))
+	fmt.Fprintf(w, "Leftover: %s\n", plan.Leftover.StringFixed(2))
+	fmt.Fprintf(w, "Cash after: %s\n", plan.CashAfter.StringFixed(2))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages"
+	"github.com/alysoliman1/money-pies/internal/pkg/cli"
+	"github.com/alysoliman1/money-pies/internal/pkg/config"
+	"github.com/alysoliman1/money-pies/internal/pkg/output"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// runAccounts runs money-pies accounts with args and returns the exit code
+func runAccounts(args []string) int {
+	flags := flag.NewFlagSet("accounts", flag.ExitOnError)
+	overrides := config.Flags(flags)
+	sortBy := flags.String("sort", "", "sort by value, largest first, or name; defaults to the brokerage's order")
+	format := output.Flag(flags)
+	flags.Parse(args)
+	msgs := format.Messages()
+
+	if err := sortAccounts(nil, *sortBy); err != nil {
+		fmt.Fprintln(msgs, err)
+		return 2
+	}
+
+	investor, brokerageName, err := openInvestor(*overrides)
+	if err != nil {
+		fmt.Fprintln(msgs, err)
+		return 1
+	}
+	accounts, err := investor.GetAccounts(context.Background())
+	if err != nil {
+		cli.PrintError(msgs, brokerageName, err)
+		return 1
+	}
+
+	sortAccounts(accounts, *sortBy)
+
+	if format.IsJSON() {
+		if accounts == nil {
+			accounts = []pies.Account{}
+		}
+		if err := output.WriteJSON(os.Stdout, accounts); err != nil {
+			fmt.Fprintln(msgs, err)
+			return 1
+		}
+		return 0
+	}
+	printAccounts(os.Stdout, accounts)
+	return 0
+}
+
+// openInvestor returns an investor for the brokerage configured with
+// overrides and its name
+func openInvestor(overrides config.Overrides) (*pies.Investor, string, error) {
+	client, brokerageName, err := brokerages.Open(overrides)
+	if err != nil {
+		if errors.Is(err, pies.ErrNotAuthenticated) {
+			return nil, brokerageName, errors.New(brokerages.NotAuthenticatedMessage(brokerageName))
+		}
+		return nil, brokerageName, err
+	}
+	return &pies.Investor{BrokerageClient: client}, brokerageName, nil
+}
+
+// selectAccount returns the account numbered or named name, or the
+// brokerage's only account when name is empty
+func selectAccount(ctx context.Context, investor *pies.Investor, name string) (pies.Account, error) {
+	if name != "" {
+		return investor.SelectAccountByName(ctx, name)
+	}
+	return investor.SelectAccount(ctx, "", "")
+}
+
+// printAccounts writes one line per account
+func printAccounts(w io.Writer, accounts []pies.Account) {
+	if len(accounts) == 0 {
+		fmt.Fprintln(w, "No accounts")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Account\tNickname\tType\tCash\tBuying power\tMarket value\tTotal\t")
+	for _, account := range accounts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			accountNumber(account), account.Nickname, account.Kind, account.CashBalance.StringFixed(2),
+			account.BuyingPower.StringFixed(2), account.MarketValue.StringFixed(2), account.TotalValue.StringFixed(2))
+	}
+	tw.Flush()
+}
+
+// accountNumber returns the number the account is shown by
+func accountNumber(account pies.Account) string {
+	if account.AccountNumber != "" {
+		return account.AccountNumber
+	}
+	return account.AccountID
+}
+
+// sortAccounts sorts accounts by the --sort key, failing for unknown keys
+func sortAccounts(accounts []pies.Account, by string) error {
+	switch by {
+	case "":
+	case "value":
+		slices.SortStableFunc(accounts, func(a, b pies.Account) int {
+			return b.TotalValue.Cmp(a.TotalValue)
+		})
+	case "name":
+		slices.SortStableFunc(accounts, func(a, b pies.Account) int {
+			return cmp.Or(strings.Compare(strings.ToLower(a.Nickname), strings.ToLower(b.Nickname)),
+				strings.Compare(accountNumber(a), accountNumber(b)))
+		})
+	default:
+		return fmt.Errorf("unknown sort %q, want value or name", by)
+	}
+	return nil
+}
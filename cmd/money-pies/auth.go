@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+	"github.com/alysoliman1/money-pies/internal/pkg/config"
+	"github.com/alysoliman1/money-pies/internal/pkg/oauth"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// authCommand is a subcommand of money-pies auth. It is given the client
+// and the error, if any, of loading its saved token.
+type authCommand struct {
+	summary string
+	run     func(ctx context.Context, client *schwab.Client, loadErr error) error
+}
+
+var authCommands = map[string]authCommand{
+	"login":          {"sign in through the browser and save the token", login},
+	"serve-callback": {"print the sign-in URL and wait for its redirect, for machines without a browser", login},
+	"status":         {"report when the saved token expires, exiting 1 when it needs a refresh and 2 a new login", authStatus},
+	"refresh":        {"exchange the saved refresh token for a new token now", refresh},
+}
+
+// authUsage lists the auth subcommands in the order they are usually run
+var authUsage = []string{"login", "serve-callback", "status", "refresh"}
+
+// runAuth runs money-pies auth with args and returns the exit code
+func runAuth(args []string) int {
+	if len(args) == 0 {
+		printAuthUsage()
+		return 2
+	}
+	name := args[0]
+	command, ok := authCommands[name]
+	if !ok {
+		fmt.Printf("unknown auth command %q\n", name)
+		printAuthUsage()
+		return 2
+	}
+
+	flags := flag.NewFlagSet("auth "+name, flag.ExitOnError)
+	overrides := config.Flags(flags)
+	port := flags.Int("port", 0, "port to listen on for the OAuth redirect, defaults to the port of the config's redirect_uri")
+	listen := flags.String("listen", "", "host:port to listen on for the OAuth redirect, for a proxy forwarding the redirect_uri to another address")
+	certDir := flags.String("cert-dir", "", "directory holding the cert.pem and key.pem served to the OAuth redirect")
+	timeout := flags.Duration("timeout", 5*time.Minute, "how long to wait for the sign-in to complete in the browser")
+	manual := flags.Bool("manual", false, "with login, paste the URL the browser was redirected to instead of listening for it, for machines that cannot receive the redirect")
+	flags.Parse(args[1:])
+
+	run := command.run
+	if *manual {
+		if name != "login" {
+			fmt.Printf("auth %s: --manual only applies to login\n", name)
+			return 2
+		}
+		run = loginManual
+	}
+
+	opts := []schwab.Option{schwab.WithAuthTimeout(*timeout)}
+	if name == "serve-callback" {
+		opts = append(opts, schwab.WithURLOpener(func(url string) error {
+			fmt.Println("Visit the following URL to authorize the application:")
+			fmt.Println(url)
+			return nil
+		}))
+	}
+
+	client, err := openSchwab(*overrides, *listen, *port, *certDir, opts...)
+	if err != nil {
+		fmt.Printf("auth %s: %v\n", name, err)
+		return 1
+	}
+	loadErr := client.LoadToken()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, client, loadErr); err != nil {
+		fmt.Printf("auth %s: %v\n", name, err)
+		var exit *exitError
+		if errors.As(err, &exit) {
+			return exit.code
+		}
+		return 1
+	}
+	return 0
+}
+
+func printAuthUsage() {
+	fmt.Println("Usage: money-pies auth <command> [--config file] [--client-id id] [--token-file file] [--listen addr | --port port] [--cert-dir dir] [--timeout duration] [--manual]")
+	fmt.Println("\nCommands:")
+	for _, name := range authUsage {
+		fmt.Printf("  %-15s %s\n", name, authCommands[name].summary)
+	}
+}
+
+// openSchwab creates a client from the brokerage config loaded with
+// overrides, with the callback listener moved to listen or to port, and the
+// certificate in certDir, when they are set
+func openSchwab(overrides config.Overrides, listen string, port int, certDir string, opts ...schwab.Option) (*schwab.Client, error) {
+	brokerageConfig, err := config.Load(overrides)
+	if err != nil {
+		return nil, err
+	}
+	if brokerageConfig.Brokerage != "schwab" {
+		return nil, fmt.Errorf("%s needs no sign-in, its credentials are in its config", brokerageConfig.Brokerage)
+	}
+
+	schwabConfig, err := schwab.ParseConfig(brokerageConfig.Config)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case listen != "":
+		if _, _, err := net.SplitHostPort(listen); err != nil {
+			return nil, fmt.Errorf("invalid --listen %q: %w", listen, err)
+		}
+		schwabConfig.CallbackAddr = listen
+	case port != 0:
+		addr := schwabConfig.CallbackAddr
+		if addr == "" {
+			redirect, err := oauth.ParseRedirectURI(schwabConfig.RedirectURI)
+			if err != nil {
+				return nil, err
+			}
+			addr = redirect.Addr
+		}
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid callback_addr %q: %w", addr, err)
+		}
+		schwabConfig.CallbackAddr = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	if certDir != "" {
+		schwabConfig.CallbackCertFile = filepath.Join(certDir, "cert.pem")
+		schwabConfig.CallbackKeyFile = filepath.Join(certDir, "key.pem")
+	}
+
+	return schwab.NewClient(schwabConfig, append([]schwab.Option{schwab.WithLogger(slog.Default())}, opts...)...)
+}
+
+// login runs the OAuth flow unless the saved token is still usable
+func login(ctx context.Context, client *schwab.Client, loadErr error) error {
+	if loadErr == nil && client.IsAuthenticated() {
+		fmt.Println("Already logged in, run money-pies auth status for details")
+		return nil
+	}
+	if loadErr != nil && !errors.Is(loadErr, pies.ErrNotAuthenticated) {
+		// Signing in again replaces the unusable token
+		fmt.Println(loadErr)
+	}
+
+	if err := client.Authenticate(ctx); err != nil {
+		return err
+	}
+	fmt.Println("Logged in")
+	return nil
+}
+
+// loginManual runs the OAuth flow with the redirect pasted on stdin, unless
+// the saved token is still usable
+func loginManual(ctx context.Context, client *schwab.Client, loadErr error) error {
+	if loadErr == nil && client.IsAuthenticated() {
+		fmt.Println("Already logged in, run money-pies auth status for details")
+		return nil
+	}
+
+	stdin := bufio.NewReader(os.Stdin)
+	prompt := func(authURL string, previous error) (string, error) {
+		if previous == nil {
+			fmt.Println("Visit the following URL to authorize the application:")
+			fmt.Println(authURL)
+			fmt.Println("\nOnce authorized, the browser is sent to the redirect URL, which may fail to load.")
+		} else {
+			fmt.Printf("%v, try again\n", previous)
+		}
+		fmt.Print("Paste the URL from the address bar, or just its code: ")
+		line, err := stdin.ReadString('\n')
+		if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+			return "", fmt.Errorf("failed to read the pasted url: %w", err)
+		}
+		return line, nil
+	}
+
+	if err := client.AuthenticateManual(ctx, prompt); err != nil {
+		return err
+	}
+	fmt.Println("Logged in")
+	return nil
+}
+
+// Exit codes of money-pies auth status, for scheduled jobs to alert on
+const (
+	statusValid        = 0
+	statusNeedsRefresh = 1
+	statusNeedsReauth  = 2
+)
+
+// exitError is an auth command failure exiting with a specific code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// authStatus prints the expiry and scopes of the saved token and where it is
+// stored, never the token itself. It fails with statusNeedsRefresh when only
+// the access token has expired and statusNeedsReauth when the OAuth flow has
+// to be run again.
+func authStatus(ctx context.Context, client *schwab.Client, loadErr error) error {
+	fmt.Printf("Token store: %s\n", describeTokenStore(client))
+
+	token, ok := client.CurrentToken()
+	if !ok {
+		return &exitError{statusNeedsReauth, fmt.Errorf("not logged in, run money-pies auth login: %w", loadErr)}
+	}
+
+	now := time.Now()
+	if now.Before(token.ExpiresAt) {
+		fmt.Printf("Access token valid until %s\n", token.ExpiresAt.Local().Format(time.DateTime))
+	} else {
+		fmt.Printf("Access token expired at %s\n", token.ExpiresAt.Local().Format(time.DateTime))
+	}
+	switch {
+	case token.RefreshTokenExpiresAt.IsZero():
+		fmt.Println("Refresh token expiry unknown")
+	case now.Before(token.RefreshTokenExpiresAt):
+		fmt.Printf("Refresh token valid until %s, %s from now\n",
+			token.RefreshTokenExpiresAt.Local().Format(time.DateTime), token.RefreshTokenExpiresAt.Sub(now).Round(time.Minute))
+	default:
+		fmt.Printf("Refresh token expired at %s\n", token.RefreshTokenExpiresAt.Local().Format(time.DateTime))
+	}
+	scopes := token.Scope
+	if scopes == "" {
+		scopes = "unknown"
+	}
+	fmt.Printf("Scopes: %s\n", scopes)
+
+	switch {
+	case loadErr != nil || !client.RefreshTokenValid() && !client.IsAuthenticated():
+		if loadErr == nil {
+			loadErr = schwab.ErrRefreshTokenExpired
+		}
+		return &exitError{statusNeedsReauth, fmt.Errorf("%w, run money-pies auth login", loadErr)}
+	case !client.IsAuthenticated():
+		return &exitError{statusNeedsRefresh, errors.New("access token expired, run money-pies auth refresh")}
+	}
+	return nil
+}
+
+// describeTokenStore says where client keeps its token
+func describeTokenStore(client *schwab.Client) string {
+	if stringer, ok := client.TokenStore().(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprintf("%T", client.TokenStore())
+}
+
+// refresh replaces the saved token with a freshly refreshed one
+func refresh(ctx context.Context, client *schwab.Client, loadErr error) error {
+	if loadErr != nil {
+		return fmt.Errorf("%w, run money-pies auth login", loadErr)
+	}
+	if err := client.Refresh(ctx); err != nil {
+		return err
+	}
+
+	token, _ := client.CurrentToken()
+	fmt.Printf("Token refreshed, access token valid until %s\n", token.ExpiresAt.Local().Format(time.DateTime))
+	return nil
+}
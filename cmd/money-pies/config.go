@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+	"github.com/alysoliman1/money-pies/internal/pkg/config"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// runConfig runs money-pies config with args and returns the exit code
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Println("Usage: money-pies config check [--config file] [--client-id id] [--token-file file]")
+		return 2
+	}
+
+	flags := flag.NewFlagSet("config check", flag.ExitOnError)
+	overrides := config.Flags(flags)
+	flags.Parse(args[1:])
+
+	brokerageConfig, err := config.Load(*overrides)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Printf("Brokerage: %s\n", brokerageConfig.Brokerage)
+
+	problems := checkConfig(brokerageConfig)
+	if len(problems) > 0 {
+		fmt.Println("Problems:")
+		for _, problem := range problems {
+			fmt.Printf("  %v\n", problem)
+		}
+		return 1
+	}
+	fmt.Println("Config OK")
+	return 0
+}
+
+// checkConfig returns every problem found with the brokerage's config and
+// its saved credentials
+func checkConfig(brokerageConfig pies.BrokerageConfig) []error {
+	if brokerageConfig.Brokerage != "schwab" {
+		// Other brokerages report their first problem when creating the client
+		_, err := brokerageConfig.NewClient()
+		if err != nil {
+			return []error{err}
+		}
+		return nil
+	}
+
+	schwabConfig, err := schwab.ParseConfig(brokerageConfig.Config)
+	if err != nil {
+		return []error{err}
+	}
+	if err := schwabConfig.Validate(); err != nil {
+		return unjoin(err)
+	}
+
+	client, err := schwab.NewClient(schwabConfig)
+	if err != nil {
+		return []error{err}
+	}
+	fmt.Printf("Token store: %s\n", describeTokenStore(client))
+	if err := client.LoadToken(); err != nil {
+		return []error{fmt.Errorf("%w, run money-pies auth login", err)}
+	}
+	return nil
+}
+
+// unjoin returns the errors joined into err, or err alone
+func unjoin(err error) []error {
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
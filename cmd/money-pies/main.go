@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/logging"
+)
+
+func main() {
+	logOptions := logging.Flags(flag.CommandLine, slog.LevelWarn)
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: money-pies [--log-level level] [--log-format text|json] <command> [arguments]")
+		fmt.Fprintln(flag.CommandLine.Output(), "\nCommands:")
+		fmt.Fprintln(flag.CommandLine.Output(), "  auth      sign in to the brokerage and manage its token")
+		fmt.Fprintln(flag.CommandLine.Output(), "  config    check the brokerage config for problems")
+		fmt.Fprintln(flag.CommandLine.Output(), "  accounts  list the brokerage's accounts")
+		fmt.Fprintln(flag.CommandLine.Output(), "  positions list an account's holdings")
+		fmt.Fprintln(flag.CommandLine.Output(), "  orders    list, show and cancel an account's orders")
+	}
+	flag.Parse()
+	logOptions.Setup(os.Stderr)
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	switch flag.Arg(0) {
+	case "auth":
+		os.Exit(runAuth(flag.Args()[1:]))
+	case "config":
+		os.Exit(runConfig(flag.Args()[1:]))
+	case "accounts":
+		os.Exit(runAccounts(flag.Args()[1:]))
+	case "positions":
+		os.Exit(runPositions(flag.Args()[1:]))
+	case "orders":
+		os.Exit(runOrders(flag.Args()[1:]))
+	default:
+		fmt.Printf("unknown command %q\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/cli"
+	"github.com/alysoliman1/money-pies/internal/pkg/config"
+	"github.com/alysoliman1/money-pies/internal/pkg/output"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// ordersCommand is a subcommand of money-pies orders. It is given the
+// investor, the selected account and the arguments left after the flags.
+type ordersCommand struct {
+	summary string
+	usage   string
+	run     func(ctx context.Context, investor *pies.Investor, account pies.Account, args []string) error
+	flags   func(flags *flag.FlagSet) // Registers the flags of the subcommand, if any
+}
+
+// ordersFlags holds the flags of the orders subcommands
+var ordersFlags struct {
+	status     string
+	since      string
+	allWorking bool
+	yes        bool
+	format     *output.Format
+}
+
+var ordersCommands = map[string]ordersCommand{
+	"list": {
+		summary: "list the account's recent orders",
+		run:     listOrders,
+		flags: func(flags *flag.FlagSet) {
+			flags.StringVar(&ordersFlags.status, "status", "", "only list orders with this status, e.g. working or filled, or open for every order that may still fill")
+			flags.StringVar(&ordersFlags.since, "since", "7d", "list orders entered since this long ago, e.g. 12h or 30d, or since a date like 2006-01-02")
+		},
+	},
+	"show": {
+		summary: "print an order's full status and fills",
+		usage:   "<order-id>",
+		run:     showOrder,
+	},
+	"cancel": {
+		summary: "cancel an open order, or every one with --all-working",
+		usage:   "<order-id> | --all-working",
+		run:     cancelOrders,
+		flags: func(flags *flag.FlagSet) {
+			flags.BoolVar(&ordersFlags.allWorking, "all-working", false, "cancel every order that may still fill")
+			flags.BoolVar(&ordersFlags.yes, "yes", false, "cancel without asking first")
+		},
+	},
+}
+
+// ordersUsage lists the orders subcommands in the order they are shown
+var ordersUsage = []string{"list", "show", "cancel"}
+
+// errOrdersFailed is returned by orders commands that have reported their
+// own failures, for which there is nothing more to print
+var errOrdersFailed = errors.New("orders failed")
+
+// runOrders runs money-pies orders with args and returns the exit code.
+// Without a subcommand it lists the orders.
+func runOrders(args []string) int {
+	name := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	command, ok := ordersCommands[name]
+	if !ok {
+		fmt.Printf("unknown orders command %q\n", name)
+		printOrdersUsage()
+		return 2
+	}
+
+	flags := flag.NewFlagSet("orders "+name, flag.ExitOnError)
+	overrides := config.Flags(flags)
+	accountName := flags.String("account", "", "number or nickname of the account, needed when the brokerage has several")
+	ordersFlags.format = output.FlagWithCSV(flags)
+	if command.flags != nil {
+		command.flags(flags)
+	}
+	// Flags may come before or after the order ID
+	flags.Parse(args)
+	var positional []string
+	for flags.NArg() > 0 {
+		positional = append(positional, flags.Arg(0))
+		flags.Parse(flags.Args()[1:])
+	}
+	msgs := ordersFlags.format.Messages()
+
+	investor, brokerageName, err := openInvestor(*overrides)
+	if err != nil {
+		fmt.Fprintln(msgs, err)
+		return 1
+	}
+
+	ctx := context.Background()
+	account, err := selectAccount(ctx, investor, *accountName)
+	if err != nil {
+		cli.PrintError(msgs, brokerageName, err)
+		return 1
+	}
+
+	if err := command.run(ctx, investor, account, positional); err != nil {
+		var usage usageError
+		switch {
+		case errors.As(err, &usage):
+			fmt.Fprintf(msgs, "orders %s: %v\n", name, err)
+			return 2
+		case !errors.Is(err, errOrdersFailed):
+			cli.PrintError(msgs, brokerageName, err)
+		}
+		return 1
+	}
+	return 0
+}
+
+func printOrdersUsage() {
+	fmt.Println("Usage: money-pies orders <command> [--account account] [--output json|csv] [arguments]")
+	fmt.Println("\nCommands:")
+	for _, name := range ordersUsage {
+		command := ordersCommands[name]
+		fmt.Printf("  %-7s %-28s %s\n", name, command.usage, command.summary)
+	}
+}
+
+// usageError reports arguments an orders command cannot run with
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// listOrders writes the orders matching --status entered since --since
+func listOrders(ctx context.Context, investor *pies.Investor, account pies.Account, args []string) error {
+	if len(args) > 0 {
+		return usageError("list takes no arguments")
+	}
+	from, err := parseSince(ordersFlags.since, time.Now())
+	if err != nil {
+		return usageError(err.Error())
+	}
+
+	status := pies.OrderStatus(strings.ToUpper(ordersFlags.status))
+	query := pies.OrdersQuery{From: from, Status: status}
+	if status == "OPEN" {
+		query.Status = ""
+	}
+	orders, err := investor.BrokerageClient.GetRecentOrders(ctx, account.ID(), query)
+	if err != nil {
+		return fmt.Errorf("failed to get orders: %w", err)
+	}
+	if status == "OPEN" {
+		orders = openOrders(orders)
+	}
+
+	switch {
+	case ordersFlags.format.IsJSON():
+		return output.WriteJSON(os.Stdout, orders)
+	case ordersFlags.format.IsCSV():
+		return pies.WriteOrdersCSV(os.Stdout, orders)
+	}
+	printOrders(os.Stdout, orders)
+	return nil
+}
+
+// showOrder writes everything known about one order
+func showOrder(ctx context.Context, investor *pies.Investor, account pies.Account, args []string) error {
+	if len(args) != 1 {
+		return usageError("show takes one order ID")
+	}
+	order, err := investor.BrokerageClient.GetOrderStatus(ctx, account.ID(), args[0])
+	if err != nil {
+		return fmt.Errorf("failed to get order %s: %w", args[0], err)
+	}
+
+	switch {
+	case ordersFlags.format.IsJSON():
+		return output.WriteJSON(os.Stdout, order)
+	case ordersFlags.format.IsCSV():
+		return pies.WriteOrdersCSV(os.Stdout, []pies.Order{*order})
+	}
+	printOrder(os.Stdout, order, "")
+	return nil
+}
+
+// cancelResult is what money-pies orders cancel writes with --output json
+type cancelResult struct {
+	Cancelled []string `json:"cancelled"`          // IDs of the orders cancelled
+	NotOpen   []string `json:"not_open,omitempty"` // IDs of the orders that had already closed
+}
+
+// cancelOrders cancels the order given, or every open order with
+// --all-working. Orders that have already closed are reported and fail the
+// command rather than counting as cancelled.
+func cancelOrders(ctx context.Context, investor *pies.Investor, account pies.Account, args []string) error {
+	if ordersFlags.format.IsCSV() {
+		return usageError("cancel writes no csv, use --output text or json")
+	}
+	msgs := ordersFlags.format.Messages()
+
+	var orderIDs []string
+	switch {
+	case ordersFlags.allWorking && len(args) > 0:
+		return usageError("cancel takes either an order ID or --all-working")
+	case ordersFlags.allWorking:
+		orders, err := investor.BrokerageClient.GetRecentOrders(ctx, account.ID(), pies.OrdersQuery{})
+		if err != nil {
+			return fmt.Errorf("failed to get orders: %w", err)
+		}
+		orders = openOrders(orders)
+		if len(orders) == 0 {
+			fmt.Fprintln(msgs, "No open orders")
+			return writeCancelResult(cancelResult{})
+		}
+
+		printOrders(msgs, orders)
+		if !ordersFlags.yes {
+			confirmed, err := output.Confirm(msgs, os.Stdin, fmt.Sprintf("\nCancel these %d orders in account %s?", len(orders), account.ID()))
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				fmt.Fprintln(msgs, "Nothing was cancelled")
+				return errOrdersFailed
+			}
+		}
+		for _, order := range orders {
+			orderIDs = append(orderIDs, order.ID)
+		}
+	case len(args) == 1:
+		orderIDs = args
+	default:
+		return usageError("cancel takes one order ID, or --all-working")
+	}
+
+	result := cancelResult{Cancelled: []string{}}
+	failed := false
+	for _, orderID := range orderIDs {
+		err := investor.BrokerageClient.CancelPendingOrder(ctx, account.ID(), orderID)
+		switch {
+		case err == nil:
+			fmt.Fprintf(msgs, "Cancelled order %s\n", orderID)
+			result.Cancelled = append(result.Cancelled, orderID)
+		case errors.Is(err, pies.ErrOrderNotOpen):
+			fmt.Fprintf(msgs, "Order %s was not cancelled: %v\n", orderID, err)
+			result.NotOpen = append(result.NotOpen, orderID)
+			failed = true
+		case errors.Is(err, pies.ErrNotAuthenticated):
+			return err
+		default:
+			fmt.Fprintf(msgs, "Failed to cancel order %s: %v\n", orderID, err)
+			failed = true
+		}
+	}
+
+	if err := writeCancelResult(result); err != nil {
+		return err
+	}
+	if failed {
+		return errOrdersFailed
+	}
+	return nil
+}
+
+// writeCancelResult writes result to stdout when the output format is JSON
+func writeCancelResult(result cancelResult) error {
+	if !ordersFlags.format.IsJSON() {
+		return nil
+	}
+	if result.Cancelled == nil {
+		result.Cancelled = []string{}
+	}
+	return output.WriteJSON(os.Stdout, result)
+}
+
+// openOrders returns the orders that may still fill
+func openOrders(orders []pies.Order) []pies.Order {
+	open := []pies.Order{}
+	for _, order := range orders {
+		if order.Status.IsOpen() {
+			open = append(open, order)
+		}
+	}
+	return open
+}
+
+// parseSince returns the time s refers to: a number of days such as 7d, a
+// duration such as 12h, or a date
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if duration, err := time.ParseDuration(s); err == nil && duration >= 0 {
+		return now.Add(-duration), nil
+	}
+	if date, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return date, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q, want a number of days like 7d, a duration like 12h or a date like 2006-01-02", s)
+}
+
+// printOrders writes one line per order, newest first
+func printOrders(w io.Writer, orders []pies.Order) {
+	if len(orders) == 0 {
+		fmt.Fprintln(w, "No orders")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Submitted\tOrder\tAction\tSymbol\tQuantity\tType\tStatus\tRaw status\tFilled\tPrice\t")
+	for _, order := range orders {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			order.SubmittedAt.Local().Format(time.DateTime), order.ID, order.Action, order.Symbol, order.Quantity,
+			order.Type, order.Status, order.RawStatus, order.FilledQty, order.FilledPrice.StringFixed(2))
+	}
+	tw.Flush()
+}
+
+// printOrder writes one field of order per line, followed by the orders of
+// its strategy indented below it
+func printOrder(w io.Writer, order *pies.Order, indent string) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	field := func(name, value string) {
+		fmt.Fprintf(tw, "%s%s:\t%s\n", indent, name, value)
+	}
+	field("Order", order.ID)
+	if order.Strategy != "" {
+		field("Strategy", string(order.Strategy))
+	}
+	field("Symbol", order.Symbol)
+	field("Action", string(order.Action))
+	field("Type", string(order.Type))
+	field("Quantity", order.Quantity.String())
+	if order.LimitPrice != nil {
+		field("Limit price", order.LimitPrice.StringFixed(2))
+	}
+	if order.StopPrice != nil {
+		field("Stop price", order.StopPrice.StringFixed(2))
+	}
+	field("Status", string(order.Status))
+	field("Raw status", order.RawStatus)
+	field("Submitted", order.SubmittedAt.Local().Format(time.DateTime))
+	field("Filled", fmt.Sprintf("%s of %s", order.FilledQty, order.Quantity))
+	if order.FilledQty.IsPositive() {
+		field("Average fill price", order.FilledPrice.StringFixed(2))
+		field("Filled value", order.FilledQty.Mul(order.FilledPrice).StringFixed(2))
+	}
+	if order.FilledAt != nil {
+		field("Filled at", order.FilledAt.Local().Format(time.DateTime))
+	}
+	tw.Flush()
+
+	for i := range order.Children {
+		fmt.Fprintln(w)
+		printOrder(w, &order.Children[i], indent+"  ")
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/cli"
+	"github.com/alysoliman1/money-pies/internal/pkg/config"
+	"github.com/alysoliman1/money-pies/internal/pkg/output"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// positionRow is a position as money-pies positions writes it with
+// --output json
+type positionRow struct {
+	pies.Position
+	InPie *bool `json:"in_pie,omitempty"` // Only with --pie
+}
+
+// runPositions runs money-pies positions with args and returns the exit code
+func runPositions(args []string) int {
+	flags := flag.NewFlagSet("positions", flag.ExitOnError)
+	overrides := config.Flags(flags)
+	accountName := flags.String("account", "", "number or nickname of the account, needed when the brokerage has several")
+	sortBy := flags.String("sort", "", "sort by value or pl, largest first, or symbol; defaults to the brokerage's order")
+	piePath := flags.String("pie", "", "path to a pie definition file, to mark the holdings it has no slice for")
+	format := output.FlagWithCSV(flags)
+	flags.Parse(args)
+	msgs := format.Messages()
+
+	if err := sortPositions(nil, *sortBy); err != nil {
+		fmt.Fprintln(msgs, err)
+		return 2
+	}
+
+	var pieSymbols map[string]bool
+	if *piePath != "" {
+		pie, err := pies.LoadPie(*piePath)
+		if err == nil {
+			pie, err = pie.Flatten()
+		}
+		if err != nil {
+			fmt.Fprintln(msgs, err)
+			return 1
+		}
+		pieSymbols = make(map[string]bool)
+		for _, slice := range pie.Slices {
+			if !slice.IsCash() {
+				pieSymbols[strings.ToUpper(slice.Asset.Symbol)] = true
+			}
+		}
+	}
+
+	investor, brokerageName, err := openInvestor(*overrides)
+	if err != nil {
+		fmt.Fprintln(msgs, err)
+		return 1
+	}
+
+	ctx := context.Background()
+	account, err := selectAccount(ctx, investor, *accountName)
+	if err != nil {
+		cli.PrintError(msgs, brokerageName, err)
+		return 1
+	}
+
+	positions, err := investor.BrokerageClient.GetPositions(ctx, account.ID())
+	if err != nil {
+		cli.PrintError(msgs, brokerageName, fmt.Errorf("failed to get positions: %w", err))
+		return 1
+	}
+	sortPositions(positions, *sortBy)
+
+	rows := make([]positionRow, len(positions))
+	for i, position := range positions {
+		rows[i].Position = position
+		if pieSymbols != nil {
+			inPie := pieSymbols[strings.ToUpper(position.Symbol)]
+			rows[i].InPie = &inPie
+		}
+	}
+
+	switch {
+	case format.IsJSON():
+		if err := output.WriteJSON(os.Stdout, rows); err != nil {
+			fmt.Fprintln(msgs, err)
+			return 1
+		}
+		return 0
+	case format.IsCSV():
+		if err := pies.WritePositionsCSV(os.Stdout, positions); err != nil {
+			fmt.Fprintln(msgs, err)
+			return 1
+		}
+		return 0
+	}
+	printPositions(os.Stdout, rows, pieSymbols != nil)
+	return 0
+}
+
+// sortPositions sorts positions by the --sort key, failing for unknown keys
+func sortPositions(positions []pies.Position, by string) error {
+	switch by {
+	case "":
+	case "value":
+		slices.SortStableFunc(positions, func(a, b pies.Position) int {
+			return b.MarketValue.Cmp(a.MarketValue)
+		})
+	case "pl":
+		slices.SortStableFunc(positions, func(a, b pies.Position) int {
+			return b.UnrealizedPL.Cmp(a.UnrealizedPL)
+		})
+	case "symbol":
+		slices.SortStableFunc(positions, func(a, b pies.Position) int {
+			return cmp.Compare(a.Symbol, b.Symbol)
+		})
+	default:
+		return fmt.Errorf("unknown sort %q, want value, symbol or pl", by)
+	}
+	return nil
+}
+
+// printPositions writes one line per position, with a column saying whether
+// the pie holds it when showInPie is set
+func printPositions(w io.Writer, rows []positionRow, showInPie bool) {
+	if len(rows) == 0 {
+		fmt.Fprintln(w, "No positions")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	header := "Symbol\tDescription\tQuantity\tAvg price\tPrice\tMarket value\tDay P/L\tUnrealized P/L\tP/L %\t"
+	if showInPie {
+		header += "In pie\t"
+	}
+	fmt.Fprintln(tw, header)
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%.2f%%\t",
+			row.Symbol, shortDescription(row.Description), row.Quantity, row.AveragePrice.StringFixed(2), row.CurrentPrice.StringFixed(2),
+			row.MarketValue.StringFixed(2), row.DayPL.StringFixed(2), row.UnrealizedPL.StringFixed(2), row.UnrealizedPLPct)
+		if row.InPie != nil {
+			inPie := "no"
+			if *row.InPie {
+				inPie = "yes"
+			}
+			fmt.Fprintf(tw, "%s\t", inPie)
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+// maxDescriptionWidth is how many characters of a description the positions
+// table shows
+const maxDescriptionWidth = 30
+
+// shortDescription cuts description down to maxDescriptionWidth characters
+func shortDescription(description string) string {
+	runes := []rune(description)
+	if len(runes) <= maxDescriptionWidth {
+		return description
+	}
+	return string(runes[:maxDescriptionWidth-3]) + "..."
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages"
+	"github.com/alysoliman1/money-pies/internal/pkg/config"
+	"github.com/alysoliman1/money-pies/internal/pkg/logging"
+	"github.com/alysoliman1/money-pies/internal/pkg/output"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+func main() {
+	plan := flag.Bool("plan", false, "plan the trades moving the brokerage account from the old pie to the new one")
+	allowSells := flag.Bool("allow-sells", true, "allow the plan to sell overweight slices")
+	overrides := config.Flags(flag.CommandLine)
+	logOptions := logging.Flags(flag.CommandLine, slog.LevelWarn)
+	format := output.Flag(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: pie-diff [--plan] [--output json] old-pie new-pie")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	logger := logOptions.Setup(os.Stderr)
+	msgs := format.Messages()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	oldPie, err := pies.LoadPie(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(msgs, err)
+		os.Exit(1)
+	}
+	newPie, err := pies.LoadPie(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(msgs, err)
+		os.Exit(1)
+	}
+
+	result := diffResult{Diff: pies.Diff(oldPie, newPie)}
+	if !format.IsJSON() {
+		printDiff(os.Stdout, result.Diff)
+	}
+	if !*plan {
+		writeJSON(*format, result)
+		return
+	}
+
+	client, brokerageName, err := brokerages.Open(*overrides)
+	if err != nil {
+		if errors.Is(err, pies.ErrNotAuthenticated) {
+			fmt.Fprintln(msgs, brokerages.NotAuthenticatedMessage(brokerageName))
+		} else {
+			fmt.Fprintln(msgs, err)
+		}
+		os.Exit(1)
+	}
+
+	investor := pies.Investor{
+		BrokerageClient: client,
+		Logger:          logger,
+	}
+
+	rebalancePlan, err := investor.PlanForPieChange(context.Background(), oldPie, newPie, pies.RebalanceOptions{AllowSells: *allowSells})
+	if err != nil {
+		if errors.Is(err, pies.ErrNotAuthenticated) {
+			fmt.Fprintln(msgs, brokerages.NotAuthenticatedMessage(brokerageName))
+		} else {
+			fmt.Fprintln(msgs, "failed to plan the pie change:", err)
+		}
+		os.Exit(1)
+	}
+
+	result.Plan = rebalancePlan
+	if !format.IsJSON() {
+		fmt.Println()
+		printPlan(os.Stdout, rebalancePlan)
+	}
+	writeJSON(*format, result)
+}
+
+// diffResult is what pie-diff writes with --output json
+type diffResult struct {
+	Diff pies.PieDiff        `json:"diff"`
+	Plan *pies.RebalancePlan `json:"plan,omitempty"` // Only with --plan
+}
+
+// writeJSON writes result to stdout when the output format is JSON
+func writeJSON(format output.Format, result diffResult) {
+	if !format.IsJSON() {
+		return
+	}
+	if err := output.WriteJSON(os.Stdout, result); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// printDiff writes one line per added, removed or changed slice
+func printDiff(w io.Writer, diff pies.PieDiff) {
+	if diff.IsEmpty() {
+		fmt.Fprintln(w, "No changes")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Change\tSymbol\tOld %\tNew %\tChange\tName")
+	for _, change := range diff.Added {
+		fmt.Fprintf(tw, "added\t%s\t\t%.2f\t%+.2f\t%s\n",
+			change.Symbol, change.NewWeight, change.WeightChange(), change.NewName)
+	}
+	for _, change := range diff.Removed {
+		fmt.Fprintf(tw, "removed\t%s\t%.2f\t\t%+.2f\t%s\n",
+			change.Symbol, change.OldWeight, change.WeightChange(), change.OldName)
+	}
+	for _, change := range diff.Changed {
+		name := change.NewName
+		if change.Renamed() {
+			name = fmt.Sprintf("%s -> %s", change.OldName, change.NewName)
+		}
+		fmt.Fprintf(tw, "changed\t%s\t%.2f\t%.2f\t%+.2f\t%s\n",
+			change.Symbol, change.OldWeight, change.NewWeight, change.WeightChange(), name)
+	}
+	tw.Flush()
+}
+
+// printPlan writes the planned orders, sells first, and the trades skipped
+func printPlan(w io.Writer, plan *pies.RebalancePlan) {
+	if len(plan.Orders) == 0 {
+		fmt.Fprintln(w, "Nothing to trade")
+	} else {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "Action\tSymbol\tQuantity\tPrice\tValue\t")
+		for _, planned := range plan.Orders {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n",
+				planned.Order.Action, planned.Order.Symbol, planned.Order.Quantity, planned.Price.StringFixed(2), planned.Value.StringFixed(2))
+		}
+		tw.Flush()
+	}
+
+	fmt.Fprintf(w, "\nCash: %s\n", plan.Cash.StringFixed(2))
+	fmt.Fprintf(w, "Cash after: %s\n", plan.CashAfter.StringFixed(2))
+	for _, skipped := range plan.Skipped {
+		fmt.Fprintf(w, "Skipped %s %s %s: %s\n", skipped.Action, skipped.Quantity, skipped.Symbol, skipped.Reason)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+func main() {
+	fromCSV := flag.String("from-csv", "", "path to a CSV export of the pie, e.g. from M1 Finance")
+	out := flag.String("out", "", "path to write the pie file to; .yaml or .yml writes YAML, anything else JSON")
+	name := flag.String("name", "", "name of the pie, defaults to the CSV file name")
+	flag.Parse()
+
+	if *fromCSV == "" || *out == "" {
+		fmt.Println("usage: pie-import --from-csv export.csv --out pie.json [--name NAME]")
+		os.Exit(2)
+	}
+
+	file, err := os.Open(*fromCSV)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	pie, err := pies.ImportCSV(file)
+	if err != nil {
+		fmt.Printf("failed to import %s: %v\n", *fromCSV, err)
+		os.Exit(1)
+	}
+
+	pie.Name = *name
+	if pie.Name == "" {
+		pie.Name = strings.TrimSuffix(filepath.Base(*fromCSV), filepath.Ext(*fromCSV))
+	}
+
+	switch strings.ToLower(filepath.Ext(*out)) {
+	case ".yaml", ".yml":
+		err = pie.SaveYAML(*out)
+	default:
+		err = pie.Save(*out)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("wrote %s with %d slices\n", *out, len(pie.Slices))
+}
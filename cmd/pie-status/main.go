@@ -2,41 +2,272 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
-	"github.com/asoliman1/money-pies/internal/pkg/brokerages/schwab"
-	"github.com/asoliman1/money-pies/internal/pkg/pies"
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages"
+	"github.com/alysoliman1/money-pies/internal/pkg/cli"
+	"github.com/alysoliman1/money-pies/internal/pkg/config"
+	"github.com/alysoliman1/money-pies/internal/pkg/logging"
+	"github.com/alysoliman1/money-pies/internal/pkg/output"
+	"github.com/alysoliman1/money-pies/internal/pkg/store"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
 )
 
 func main() {
-	clientConfigFile := os.Getenv("SCHWAB_CLIENT_CONFIG")
-	if clientConfigFile == "" {
-		fmt.Println("Schwab Client Config not specified")
-		return
+	piePath := flag.String("pie", "", "path to the pie definition file")
+	storePath := flag.String("store", "", "path to a store database to save a status snapshot to")
+	since := flag.String("since", "", "report returns since this date (YYYY-MM-DD)")
+	benchmarks := flag.String("benchmark", "", "comma-separated symbols to compare returns with, in addition to the pie's")
+	accountName := flag.String("account", "", "number or nickname of the account to report on, needed when the brokerage has several")
+	threshold := flag.Float64("threshold", 0, "allowed drift in percentage points, overriding the pie's drift bands")
+	watchInterval := flag.Duration("watch", 0, "keep checking the pie at this interval during market hours, e.g. 15m")
+	holidays := flag.String("holidays", "", "comma-separated 2006-01-02 dates the market is closed, for --watch")
+	allHours := flag.Bool("all-hours", false, "with --watch, keep checking outside market hours")
+	quoteTTL := flag.Duration("quote-ttl", time.Minute, "with --watch, how long quotes are reused before they are fetched again")
+	notifyCmd := flag.String("notify-cmd", "", "with --watch, shell command run with the status JSON on stdin when a slice leaves its drift band")
+	overrides := config.Flags(flag.CommandLine)
+	logOptions := logging.Flags(flag.CommandLine, slog.LevelWarn)
+	format := output.Flag(flag.CommandLine)
+	flag.Parse()
+	logger := logOptions.Setup(os.Stderr)
+	msgs := format.Messages()
+
+	if *piePath == "" {
+		fmt.Fprintln(msgs, "Pie file not specified, pass --pie")
+		os.Exit(2)
+	}
+	pie, err := pies.LoadPie(*piePath)
+	if err != nil {
+		fmt.Fprintln(msgs, err)
+		os.Exit(1)
 	}
 
-	rawClientConfig, err := os.ReadFile(clientConfigFile)
+	client, brokerageName, err := brokerages.Open(*overrides)
 	if err != nil {
-		log.Fatalf("failed to read config file: %v", err)
+		if errors.Is(err, pies.ErrNotAuthenticated) {
+			fmt.Fprintln(msgs, brokerages.NotAuthenticatedMessage(brokerageName))
+		} else {
+			fmt.Fprintln(msgs, err)
+		}
+		os.Exit(1)
+	}
+
+	if *watchInterval > 0 {
+		client = pies.NewQuoteCacheClient(client, *quoteTTL)
+	}
+	investor := pies.Investor{
+		BrokerageClient: client,
+		Logger:          logger,
+	}
+	if *accountName != "" {
+		if _, err := investor.SelectAccountByName(context.Background(), *accountName); err != nil {
+			if errors.Is(err, pies.ErrNotAuthenticated) {
+				fmt.Fprintln(msgs, brokerages.NotAuthenticatedMessage(brokerageName))
+			} else {
+				fmt.Fprintln(msgs, err)
+			}
+			os.Exit(1)
+		}
+	}
+
+	var returns *pies.ReturnOptions
+	if *since != "" {
+		start, err := time.ParseInLocation(time.DateOnly, *since, time.Local)
+		if err != nil {
+			fmt.Fprintln(msgs, "invalid --since date, want YYYY-MM-DD")
+			os.Exit(2)
+		}
+		returns = &pies.ReturnOptions{
+			Since:      start,
+			Benchmarks: strings.Split(*benchmarks, ","),
+		}
+	}
+	check := func(ctx context.Context) (*pies.PieStatus, error) {
+		return getStatus(ctx, &investor, pie, returns, *threshold)
 	}
 
-	var clientConfig schwab.Config
-	if err := json.Unmarshal(rawClientConfig, &clientConfig); err != nil {
-		fmt.Printf("failed to unmarshal config: %v", err)
+	if *watchInterval > 0 {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		w := watcher{
+			check:     check,
+			interval:  *watchInterval,
+			notifyCmd: *notifyCmd,
+			storePath: *storePath,
+			format:    *format,
+			msgs:      msgs,
+		}
+		if !*allHours {
+			w.market = pies.USMarketHours{Holidays: cli.SplitList(*holidays)}
+		}
+		if err := w.run(ctx); err != nil {
+			if errors.Is(err, pies.ErrNotAuthenticated) {
+				fmt.Fprintln(msgs, brokerages.NotAuthenticatedMessage(brokerageName))
+			} else {
+				fmt.Fprintln(msgs, err)
+			}
+			os.Exit(1)
+		}
 		return
 	}
 
-	timeoutInSeconds := 30
-	schwabClient := schwab.
-		NewClient(clientConfig, timeoutInSeconds).
-		GetAccessTokenFromFile()
+	status, err := check(context.Background())
+	if err != nil {
+		if errors.Is(err, pies.ErrNotAuthenticated) {
+			fmt.Fprintln(msgs, brokerages.NotAuthenticatedMessage(brokerageName))
+		} else {
+			fmt.Fprintln(msgs, err)
+		}
+		os.Exit(1)
+	}
+
+	if format.IsJSON() {
+		if err := output.WriteJSON(os.Stdout, status); err != nil {
+			fmt.Fprintln(msgs, err)
+			os.Exit(1)
+		}
+	} else {
+		printStatus(os.Stdout, status)
+	}
 
-	investor := pies.Investor{
-		BrokerageClient: schwabClient,
+	if *storePath != "" {
+		if err := saveSnapshot(*storePath, status); err != nil {
+			fmt.Fprintln(msgs, err)
+			os.Exit(1)
+		}
 	}
 
-	investor.GetPieStatus(context.Background(), pies.Pie{})
+	// A distinct exit code lets cron jobs tell drift apart from failures
+	if breaching := status.NeedsRebalance(); len(breaching) > 0 {
+		symbols := make([]string, 0, len(breaching))
+		for _, slice := range breaching {
+			symbols = append(symbols, fmt.Sprintf("%s (%+.2f)", slice.Symbol, slice.Drift))
+		}
+		fmt.Fprintf(msgs, "\nOutside drift band: %s\n", strings.Join(symbols, ", "))
+		os.Exit(3)
+	}
+}
+
+// getStatus compares the investor's account with pie, with the returns
+// since returns.Since when it is set. A positive threshold replaces the
+// drift bands of the pie's slices.
+func getStatus(ctx context.Context, investor *pies.Investor, pie pies.Pie, returns *pies.ReturnOptions, threshold float64) (*pies.PieStatus, error) {
+	var status *pies.PieStatus
+	var err error
+	if returns == nil {
+		status, err = investor.GetPieStatus(ctx, pie)
+	} else {
+		status, err = investor.GetPieStatusWithReturns(ctx, pie, *returns)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pie status: %w", err)
+	}
+	if threshold > 0 {
+		for i := range status.Slices {
+			status.Slices[i].Band = pies.DriftBand{Absolute: threshold}
+		}
+	}
+	return status, nil
+}
+
+// saveSnapshot records the status in the store at path
+func saveSnapshot(path string, status *pies.PieStatus) error {
+	db, err := store.Open(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.SaveSnapshot(status); err != nil {
+		return fmt.Errorf("failed to save status snapshot: %w", err)
+	}
+	return nil
+}
+
+// printStatus writes one line per slice followed by the account totals
+func printStatus(w io.Writer, status *pies.PieStatus) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Symbol\tTarget %\tActual %\tDrift\tDrift $\tValue\t")
+	var targetWeight, currentWeight float64
+	hasCashSlice := false
+	for _, slice := range status.Slices {
+		fmt.Fprintf(tw, "%s\t%.2f\t%.2f\t%+.2f\t%s\t%s\t\n",
+			slice.Symbol, slice.TargetWeight, slice.CurrentWeight, slice.Drift, signed(slice.DriftValue), slice.Value.StringFixed(2))
+		targetWeight += slice.TargetWeight
+		currentWeight += slice.CurrentWeight
+		hasCashSlice = hasCashSlice || slice.IsCash
+	}
+	// Without a cash slice the cash sits outside the pie's weights, but it
+	// is still part of what the pie could be invested in
+	total := status.PieValue
+	if !hasCashSlice {
+		fmt.Fprintf(tw, "Cash\t\t\t\t\t%s\t\n", status.Cash.StringFixed(2))
+		total = total.Add(status.Cash)
+	}
+	fmt.Fprintf(tw, "Total\t%.2f\t%.2f\t\t\t%s\t\n", targetWeight, currentWeight, total.StringFixed(2))
+	tw.Flush()
+
+	if len(status.Groups) > 0 {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "Slice\tTarget %\tActual %\tDrift\tDrift $\tValue\t")
+		for _, group := range status.Groups {
+			fmt.Fprintf(tw, "%s\t%.2f\t%.2f\t%+.2f\t%s\t%s\t\n",
+				group.Name, group.TargetWeight, group.CurrentWeight, group.Drift, signed(group.DriftValue), group.Value.StringFixed(2))
+		}
+		tw.Flush()
+	}
+
+	fmt.Fprintf(w, "\nPie value: %s\n", status.PieValue.StringFixed(2))
+	if len(status.Other) > 0 {
+		symbols := make([]string, 0, len(status.Other))
+		for _, position := range status.Other {
+			symbols = append(symbols, position.Symbol)
+		}
+		fmt.Fprintf(w, "Outside the pie: %s (%s)\n", status.OtherValue.StringFixed(2), strings.Join(symbols, ", "))
+	}
+	if len(status.CashEquivalents) > 0 {
+		symbols := make([]string, 0, len(status.CashEquivalents))
+		for _, position := range status.CashEquivalents {
+			symbols = append(symbols, position.Symbol)
+		}
+		fmt.Fprintf(w, "Cash equivalents: %s (%s)\n", status.CashEquivalentValue.StringFixed(2), strings.Join(symbols, ", "))
+	}
+	if len(status.Unpriced) > 0 {
+		fmt.Fprintf(w, "No quote for: %s\n", strings.Join(status.Unpriced, ", "))
+	}
+	if len(status.Missing) > 0 {
+		fmt.Fprintf(w, "Not held: %s\n", strings.Join(status.Missing, ", "))
+	}
+
+	if len(status.Returns) > 0 {
+		fmt.Fprintf(w, "\nReturns since %s\n", status.Returns[0].Start.Format(time.DateOnly))
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "\tStart value\tContributions\tEnd value\tReturn %\tAnnual %\t")
+		for _, r := range status.Returns {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%+.2f\t%+.2f\t\n",
+				r.Symbol, r.StartValue.StringFixed(2), signed(r.Contributions), r.EndValue.StringFixed(2), 100*r.Cumulative, 100*r.Annualized)
+		}
+		tw.Flush()
+	}
+}
+
+// signed formats a dollar amount to cents with its sign, like %+.2f
+func signed(amount decimal.Decimal) string {
+	if amount.IsNegative() {
+		return amount.StringFixed(2)
+	}
+	return "+" + amount.StringFixed(2)
 }
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/output"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// notifyTimeout is how long --notify-cmd may run before it is killed
+const notifyTimeout = time.Minute
+
+// watcher checks a pie at an interval until its context is cancelled
+type watcher struct {
+	check     func(ctx context.Context) (*pies.PieStatus, error)
+	interval  time.Duration
+	market    pies.MarketCalendar // Nil checks at every tick
+	notifyCmd string              // Run when a slice leaves its band, empty for none
+	storePath string              // Store each status is saved to, empty for none
+	format    output.Format
+	msgs      io.Writer
+
+	breaching map[string]bool // The slices outside their band at the last check
+}
+
+// run checks the pie now and at every tick, writing a summary line per
+// check. Failures the next check may not have, such as a dropped
+// connection, are reported and watching goes on; only being signed out
+// ends it early. It returns nil once ctx is cancelled.
+func (w *watcher) run(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	wasClosed := false
+	for {
+		open := true
+		if w.market != nil {
+			var err error
+			open, err = w.market.IsOpen(ctx, time.Now())
+			if err != nil {
+				return err
+			}
+		}
+		switch {
+		case !open && !wasClosed:
+			fmt.Fprintf(w.msgs, "%s market closed, waiting for it to open\n", time.Now().Format(time.TimeOnly))
+		case open:
+			if err := w.tick(ctx); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				if errors.Is(err, pies.ErrNotAuthenticated) {
+					return err
+				}
+				fmt.Fprintf(w.msgs, "%s %v\n", time.Now().Format(time.TimeOnly), err)
+			}
+		}
+		wasClosed = !open
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick checks the pie once, reporting the slices that crossed their band
+// since the last check
+func (w *watcher) tick(ctx context.Context) error {
+	status, err := w.check(ctx)
+	if err != nil {
+		return err
+	}
+
+	breaching := make(map[string]bool)
+	var entered []pies.SliceStatus
+	for _, slice := range status.NeedsRebalance() {
+		breaching[slice.Symbol] = true
+		if !w.breaching[slice.Symbol] {
+			entered = append(entered, slice)
+		}
+	}
+	var left []string
+	for _, slice := range status.Slices {
+		if w.breaching[slice.Symbol] && !breaching[slice.Symbol] {
+			left = append(left, slice.Symbol)
+		}
+	}
+	w.breaching = breaching
+
+	if w.format.IsJSON() {
+		if err := output.WriteJSON(os.Stdout, status); err != nil {
+			return err
+		}
+	} else {
+		fmt.Println(summarize(time.Now(), status))
+	}
+	for _, slice := range entered {
+		fmt.Fprintf(w.msgs, "  ** %s left its drift band: %+.2f\n", slice.Symbol, slice.Drift)
+	}
+	for _, symbol := range left {
+		fmt.Fprintf(w.msgs, "  %s is back within its drift band\n", symbol)
+	}
+
+	if w.storePath != "" {
+		if err := saveSnapshot(w.storePath, status); err != nil {
+			return err
+		}
+	}
+	if w.notifyCmd != "" && len(entered) > 0 {
+		if err := notify(ctx, w.notifyCmd, status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// summarize describes status in one line: the pie's value, its most
+// drifted slice and the slices outside their band
+func summarize(at time.Time, status *pies.PieStatus) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s value %s cash %s", at.Format(time.TimeOnly), status.PieValue.StringFixed(2), status.Cash.StringFixed(2))
+
+	var largest *pies.SliceStatus
+	for i, slice := range status.Slices {
+		if largest == nil || math.Abs(slice.Drift) > math.Abs(largest.Drift) {
+			largest = &status.Slices[i]
+		}
+	}
+	if largest != nil {
+		fmt.Fprintf(&b, ", largest drift %s %+.2f", largest.Symbol, largest.Drift)
+	}
+
+	breaching := status.NeedsRebalance()
+	if len(breaching) == 0 {
+		b.WriteString(", all within band")
+		return b.String()
+	}
+	symbols := make([]string, 0, len(breaching))
+	for _, slice := range breaching {
+		symbols = append(symbols, fmt.Sprintf("%s %+.2f", slice.Symbol, slice.Drift))
+	}
+	fmt.Fprintf(&b, ", OUTSIDE BAND: %s", strings.Join(symbols, ", "))
+	return b.String()
+}
+
+// notify runs command through the shell with status as JSON on its stdin
+func notify(ctx context.Context, command string, status *pies.PieStatus) error {
+	encoded, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("failed to encode status: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(encoded)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run notify command: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages"
+	"github.com/alysoliman1/money-pies/internal/pkg/cli"
+	"github.com/alysoliman1/money-pies/internal/pkg/config"
+	"github.com/alysoliman1/money-pies/internal/pkg/logging"
+	"github.com/alysoliman1/money-pies/internal/pkg/output"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+func main() {
+	watch := flag.Duration("watch", 0, "fetch the quotes again at this interval, e.g. 5s, until interrupted")
+	overrides := config.Flags(flag.CommandLine)
+	logOptions := logging.Flags(flag.CommandLine, slog.LevelWarn)
+	format := output.Flag(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: quote [--watch interval] [--output json] <symbol>...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	logOptions.Setup(os.Stderr)
+	msgs := format.Messages()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	symbols := make([]string, flag.NArg())
+	for i, symbol := range flag.Args() {
+		symbols[i] = strings.ToUpper(symbol)
+	}
+
+	client, brokerageName, err := brokerages.Open(*overrides)
+	if err != nil {
+		cli.PrintError(msgs, brokerageName, err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *watch <= 0 {
+		r, err := fetch(ctx, client, symbols)
+		if err != nil {
+			cli.PrintError(msgs, brokerageName, err)
+			os.Exit(1)
+		}
+		if err := writeResult(*format, r, false); err != nil {
+			fmt.Fprintln(msgs, err)
+			os.Exit(1)
+		}
+		if len(r.Missing) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	ticker := time.NewTicker(*watch)
+	defer ticker.Stop()
+	for {
+		r, err := fetch(ctx, client, symbols)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case errors.Is(err, pies.ErrNotAuthenticated):
+			cli.PrintError(msgs, brokerageName, err)
+			os.Exit(1)
+		case err != nil:
+			// Keep watching through failures the next fetch may not have
+			fmt.Fprintln(msgs, err)
+		default:
+			if err := writeResult(*format, r, true); err != nil {
+				fmt.Fprintln(msgs, err)
+				os.Exit(1)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// result is what quote writes with --output json
+type result struct {
+	Quotes  []pies.Quote      `json:"quotes"`            // In the order the symbols were given
+	Missing map[string]string `json:"missing,omitempty"` // Why each symbol without a quote has none
+}
+
+// fetch gets the quotes of symbols in one batch. Symbols the brokerage could
+// not price are reported in the result's Missing rather than failing it.
+func fetch(ctx context.Context, client pies.BrokerageClient, symbols []string) (result, error) {
+	quotes, err := client.GetQuotes(ctx, symbols)
+	var missing *pies.MissingQuotesError
+	if err != nil && !errors.As(err, &missing) {
+		return result{}, fmt.Errorf("failed to get quotes: %w", err)
+	}
+
+	r := result{Quotes: []pies.Quote{}}
+	for _, symbol := range symbols {
+		if quote, ok := quotes[symbol]; ok {
+			r.Quotes = append(r.Quotes, quote)
+			continue
+		}
+		if r.Missing == nil {
+			r.Missing = make(map[string]string)
+		}
+		r.Missing[symbol] = "no quote returned"
+		if missing != nil && missing.Errors[symbol] != nil {
+			r.Missing[symbol] = missing.Errors[symbol].Error()
+		}
+	}
+	return r, nil
+}
+
+// writeResult writes r to stdout, as JSON or as a table that replaces the
+// previous one on a terminal when refresh is set
+func writeResult(format output.Format, r result, refresh bool) error {
+	if format.IsJSON() {
+		return output.WriteJSON(os.Stdout, r)
+	}
+	if refresh {
+		// Move to the top left and clear the screen
+		fmt.Print("\033[H\033[2J")
+		fmt.Printf("Quotes at %s\n\n", time.Now().Format(time.TimeOnly))
+	}
+	printQuotes(os.Stdout, r)
+	return nil
+}
+
+// printQuotes writes one line per quote followed by the symbols without one
+func printQuotes(w io.Writer, r result) {
+	if len(r.Quotes) > 0 {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "Symbol\tBid\tAsk\tLast\tChange\tChange %\tVolume\t")
+		for _, quote := range r.Quotes {
+			change, changePct := "", ""
+			if quote.Close.IsPositive() {
+				diff := quote.Last.Sub(quote.Close)
+				change = diff.StringFixed(2)
+				changePct = fmt.Sprintf("%.2f%%", diff.Div(quote.Close).InexactFloat64()*100)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t\n",
+				quote.Symbol, quote.Bid.StringFixed(2), quote.Ask.StringFixed(2), quote.Last.StringFixed(2), change, changePct, quote.Volume)
+		}
+		tw.Flush()
+	}
+	for _, symbol := range slices.Sorted(maps.Keys(r.Missing)) {
+		fmt.Fprintf(w, "%s: %s\n", symbol, r.Missing[symbol])
+	}
+}
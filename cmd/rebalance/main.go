@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages"
+	"github.com/alysoliman1/money-pies/internal/pkg/cli"
+	"github.com/alysoliman1/money-pies/internal/pkg/config"
+	"github.com/alysoliman1/money-pies/internal/pkg/logging"
+	"github.com/alysoliman1/money-pies/internal/pkg/output"
+	"github.com/alysoliman1/money-pies/internal/pkg/store"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+func main() {
+	piePath := flag.String("pie", "", "path to the pie definition file")
+	accountName := flag.String("account", "", "number or nickname of the account to rebalance, needed when the brokerage has several")
+	allowSells := flag.Bool("allow-sells", false, "sell overweight slices to fund the buys")
+	minTrade := flag.Float64("min-trade", 0, "skip trades worth less than this many dollars")
+	limitOffset := flag.Float64("limit-offset-bps", 0, "place limit orders this many basis points past the quote; 0 places market orders")
+	dryRun := flag.Bool("dry-run", false, "simulate the orders at the current quotes instead of placing them")
+	planOnly := flag.String("plan-only", "", "write the plan as JSON to this file and stop without trading")
+	storePath := flag.String("store", "", "path to a store database to record the execution in")
+	safety := cli.SafetyFlags(flag.CommandLine)
+	overrides := config.Flags(flag.CommandLine)
+	logOptions := logging.Flags(flag.CommandLine, slog.LevelWarn)
+	format := output.Flag(flag.CommandLine)
+	flag.Parse()
+	logger := logOptions.Setup(os.Stderr)
+	msgs := format.Messages()
+
+	if *piePath == "" {
+		fmt.Fprintln(msgs, "Pie file not specified, pass --pie")
+		os.Exit(2)
+	}
+	pie, err := pies.LoadPie(*piePath)
+	if err != nil {
+		fmt.Fprintln(msgs, err)
+		os.Exit(1)
+	}
+	risk, err := safety.RiskChecker(pie)
+	if err != nil {
+		fmt.Fprintln(msgs, err)
+		os.Exit(1)
+	}
+
+	client, brokerageName, err := brokerages.Open(*overrides)
+	if err != nil {
+		cli.PrintError(msgs, brokerageName, err)
+		os.Exit(1)
+	}
+	fractional := false
+	if trader, ok := client.(pies.FractionalTrader); ok {
+		fractional = trader.SupportsFractionalShares()
+	}
+	if *dryRun {
+		client = pies.NewDryRunClient(client, nil)
+	}
+	investor := &pies.Investor{BrokerageClient: client, Logger: logger, Risk: risk}
+	if !*dryRun {
+		investor.Notifier, err = brokerages.OpenNotifier(*overrides)
+		if err != nil {
+			fmt.Fprintln(msgs, err)
+			os.Exit(1)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var account pies.Account
+	if *accountName != "" {
+		account, err = investor.SelectAccountByName(ctx, *accountName)
+	} else {
+		account, err = investor.SelectAccount(ctx, "", "")
+	}
+	if err != nil {
+		cli.PrintError(msgs, brokerageName, err)
+		os.Exit(1)
+	}
+
+	opts := pies.RebalanceOptions{
+		AllowSells:    *allowSells,
+		MinTradeValue: pies.NewDecimal(*minTrade),
+		Fractional:    fractional,
+	}
+	if *limitOffset > 0 {
+		opts.OrderType = pies.OrderTypeLimit
+		opts.LimitOffsetBps = *limitOffset
+	}
+	plan, err := investor.ComputeRebalancePlan(ctx, pie, opts)
+	if err != nil {
+		cli.PrintError(msgs, brokerageName, fmt.Errorf("failed to plan the rebalance: %w", err))
+		os.Exit(1)
+	}
+	printPlan(msgs, plan)
+
+	if *planOnly != "" {
+		if err := savePlan(*planOnly, plan); err != nil {
+			fmt.Fprintln(msgs, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(msgs, "\nWrote the plan to %s\n", *planOnly)
+		cli.WriteResult(*format, result{Plan: plan})
+		return
+	}
+	if len(plan.Orders) == 0 {
+		cli.WriteResult(*format, result{Plan: plan})
+		return
+	}
+
+	if err := safety.CheckTotal(plan); err != nil {
+		fmt.Fprintln(msgs, err)
+		os.Exit(1)
+	}
+	if err := cli.CheckRisk(ctx, msgs, investor, plan); err != nil {
+		cli.PrintError(msgs, brokerageName, err)
+		os.Exit(1)
+	}
+	if err := confirm(msgs, *dryRun, safety, account, plan); err != nil {
+		if errors.Is(err, cli.ErrDeclined) {
+			fmt.Fprintln(msgs, "Nothing was traded")
+		} else {
+			fmt.Fprintln(msgs, err)
+		}
+		os.Exit(1)
+	}
+
+	if *storePath != "" {
+		db, err := store.Open(*storePath)
+		if err != nil {
+			fmt.Fprintln(msgs, err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		investor.History = db
+	}
+
+	fmt.Fprintln(msgs)
+	report, err := investor.ExecutePlan(ctx, plan, pies.ExecuteOptions{
+		CashAccount: account.Kind == pies.AccountKindCash,
+		OnUpdate:    output.Progress(msgs),
+	})
+	if report != nil {
+		fmt.Fprintln(msgs)
+		output.PrintReport(msgs, report)
+		cli.WriteResult(*format, result{Plan: plan, Report: report})
+	}
+	if err != nil {
+		cli.PrintError(msgs, brokerageName, err)
+		os.Exit(1)
+	}
+}
+
+// result is what rebalance writes with --output json
+type result struct {
+	Plan   *pies.RebalancePlan   `json:"plan"`
+	Report *pies.ExecutionReport `json:"report,omitempty"` // Only once the plan is executed
+}
+
+// confirm has the plan's orders confirmed, the live ones through the shared
+// safety prompt and simulated ones with a plain yes or no
+func confirm(w io.Writer, dryRun bool, safety *cli.Safety, account pies.Account, plan *pies.RebalancePlan) error {
+	if !dryRun {
+		return safety.Confirm(w, os.Stdin, account, plan)
+	}
+	if safety.Yes {
+		return nil
+	}
+	confirmed, err := output.Confirm(w, os.Stdin, fmt.Sprintf("\nSimulate these %d orders?", len(plan.Orders)))
+	if err == nil && !confirmed {
+		err = cli.ErrDeclined
+	}
+	return err
+}
+
+// savePlan writes the plan as JSON to path
+func savePlan(path string, plan *pies.RebalancePlan) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create plan file: %w", err)
+	}
+	if err := output.WriteJSON(file, plan); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write plan file: %w", err)
+	}
+	return nil
+}
+
+// printPlan writes the planned orders, sells first, followed by the trades
+// skipped
+func printPlan(w io.Writer, plan *pies.RebalancePlan) {
+	if len(plan.Orders) == 0 && len(plan.Skipped) == 0 {
+		fmt.Fprintln(w, "Nothing to trade")
+	} else {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "Symbol\tAction\tQuantity\tValue\tReason")
+		for _, planned := range plan.Orders {
+			reason := "below target"
+			if planned.Order.Action == pies.OrderActionSell {
+				reason = "above target"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+				planned.Order.Symbol, planned.Order.Action, planned.Order.Quantity, planned.Value.StringFixed(2), reason)
+		}
+		for _, skipped := range plan.Skipped {
+			fmt.Fprintf(tw, "%s\tskip %s\t%s\t%s\t%s\n",
+				skipped.Symbol, skipped.Action, skipped.Quantity, skipped.Value.StringFixed(2), skipped.Reason)
+		}
+		tw.Flush()
+	}
+
+	fmt.Fprintf(w, "\nCash: %s\n", plan.Cash.StringFixed(2))
+	fmt.Fprintf(w, "Cash after: %s\n", plan.CashAfter.StringFixed(2))
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages"
+	"github.com/alysoliman1/money-pies/internal/pkg/cli"
+	"github.com/alysoliman1/money-pies/internal/pkg/config"
+	"github.com/alysoliman1/money-pies/internal/pkg/logging"
+	"github.com/alysoliman1/money-pies/internal/pkg/notify"
+	"github.com/alysoliman1/money-pies/internal/pkg/store"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+func main() {
+	piePath := flag.String("pie", "", "path to the pie definition file")
+	schedule := flag.String("schedule", "0 10 * * 1-5", "cron expression of when to check the pie")
+	timezone := flag.String("timezone", "America/New_York", "time zone the schedule is evaluated in")
+	threshold := flag.Float64("threshold", 5, "drift band in percentage points for pies without one")
+	holidays := flag.String("holidays", "", "comma-separated 2006-01-02 dates the market is closed")
+	allowSells := flag.Bool("allow-sells", false, "sell overweight slices to fund the buys")
+	taxFreeSells := flag.Bool("tax-free-sells", false, "sell overweight slices to fund the buys in IRAs, where selling is not taxed")
+	live := flag.Bool("live", false, "place orders; without it runs only report what they would trade")
+	lockPath := flag.String("lock", "", "lock file preventing two daemons from rebalancing at once")
+	storePath := flag.String("store", "", "path to a store database to record executions in")
+	webhook := flag.String("webhook", "", "URL notifications are posted to as JSON {\"text\": ..., \"event\": {...}}, in addition to those in the config file")
+	screenList := flag.String("screen-list", "", "allow/deny list file symbols must pass before they are traded")
+	riskConfig := flag.String("risk-config", "", "file of pre-trade risk limits orders are checked against; the pie's own risk limits take precedence")
+	overrides := config.Flags(flag.CommandLine)
+	logOptions := logging.Flags(flag.CommandLine, slog.LevelInfo)
+	flag.Parse()
+	logger := logOptions.Setup(os.Stderr)
+
+	if *piePath == "" {
+		fmt.Println("Pie file not specified, pass --pie")
+		os.Exit(2)
+	}
+	pie, err := pies.LoadPie(*piePath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	location, err := time.LoadLocation(*timezone)
+	if err != nil {
+		fmt.Println("invalid time zone:", err)
+		os.Exit(2)
+	}
+	cron, err := pies.ParseSchedule(*schedule, location)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	// An expired session is reported by every run instead of stopping the
+	// daemon, so signing in again is enough to resume
+	client, _, err := brokerages.Open(*overrides)
+	if err != nil && (client == nil || !errors.Is(err, pies.ErrNotAuthenticated)) {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var riskLimits pies.RiskLimits
+	if *riskConfig != "" {
+		riskLimits, err = pies.LoadRiskLimits(*riskConfig)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	investor := &pies.Investor{BrokerageClient: client, Logger: logger, Risk: pies.NewRiskChecker(riskLimits, pie)}
+	if *screenList != "" {
+		screener, err := pies.LoadScreenList(*screenList)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := pies.ScreenPie(context.Background(), screener, pie); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		investor.Screener = screener
+	}
+	if *storePath != "" {
+		db, err := store.Open(*storePath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		investor.History = db
+	}
+
+	scheduler := &pies.Scheduler{
+		Investor:  investor,
+		Pie:       pie,
+		Schedule:  cron,
+		Market:    pies.USMarketHours{Holidays: cli.SplitList(*holidays)},
+		Threshold: pies.DriftBand{Absolute: *threshold},
+		Rebalance: pies.RebalanceOptions{Mode: pies.RebalanceBreaching, AllowSells: *allowSells, AllowTaxFreeSells: *taxFreeSells},
+		DryRun:    !*live,
+		LockPath:  *lockPath,
+	}
+	scheduler.Notifier, err = openNotifier(*overrides, *webhook)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Keep the token fresh between runs so a run never starts by refreshing
+	if refresher, ok := client.(pies.AutoRefresher); ok {
+		refresher.SetNotifier(scheduler.Notifier)
+		refreshing := refresher.StartAutoRefresh(ctx)
+		defer func() {
+			stop()
+			<-refreshing
+		}()
+	}
+
+	slog.Info("rebalance daemon started", slog.String("pie", pie.Name), slog.String("schedule", *schedule), slog.Bool("live", *live))
+	if err := scheduler.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// openNotifier creates the notifiers configured in the config file, adding
+// a webhook posting to webhookURL when it is set
+func openNotifier(overrides config.Overrides, webhookURL string) (pies.Notifier, error) {
+	notifier, err := brokerages.OpenNotifier(overrides)
+	if err != nil || webhookURL == "" {
+		return notifier, err
+	}
+
+	webhook, err := notify.NewWebhook(notify.WebhookConfig{URL: webhookURL})
+	if err != nil {
+		return nil, fmt.Errorf("invalid --webhook: %w", err)
+	}
+	if notifier == nil {
+		return webhook, nil
+	}
+	return notify.Multi{notifier, webhook}, nil
+}
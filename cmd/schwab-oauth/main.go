@@ -2,91 +2,55 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"log"
-	"net/http"
+	"log/slog"
 	"os"
 
-	"github.com/asoliman1/money-pies/internal/pkg/brokerages/schwab"
-	"github.com/pkg/browser"
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+	"github.com/alysoliman1/money-pies/internal/pkg/config"
+	"github.com/alysoliman1/money-pies/internal/pkg/logging"
 )
 
+// schwab-oauth is kept for scripts that still run it and will be removed in
+// the next release
 func main() {
-	clientConfigFile := os.Getenv("SCHWAB_CLIENT_CONFIG")
-	if clientConfigFile == "" {
-		fmt.Println("Schwab Client Config not specified")
-		return
-	}
+	fmt.Println("schwab-oauth is deprecated, use money-pies auth login")
 
-	rawClientConfig, err := os.ReadFile(clientConfigFile)
+	brokerageConfig, err := config.Load(config.Overrides{})
 	if err != nil {
-		log.Fatalf("failed to read config file: %v", err)
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if brokerageConfig.Brokerage != "schwab" {
+		fmt.Printf("schwab-oauth signs in to schwab, but the brokerage config is for %s\n", brokerageConfig.Brokerage)
+		os.Exit(1)
 	}
 
-	var clientConfig schwab.Config
-	if err := json.Unmarshal(rawClientConfig, &clientConfig); err != nil {
-		fmt.Printf("failed to unmarshal config: %v", err)
-		return
+	clientConfig, err := schwab.ParseConfig(brokerageConfig.Config)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
 
-	timeoutInSeconds := 30
-	schwabClient := schwab.
-		NewClient(clientConfig, timeoutInSeconds).
-		GetAccessTokenFromFile()
+	logger := logging.Options{Level: slog.LevelWarn}.New(os.Stderr)
+	schwabClient, err := schwab.NewClient(clientConfig, schwab.WithLogger(logger))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := schwabClient.LoadToken(); err != nil && !schwab.IsAuthError(err) {
+		// Authenticating again replaces the unusable token
+		fmt.Println(err)
+	}
 	if schwabClient.IsAuthenticated() {
 		fmt.Println("already authenticated")
 		return
 	}
 
-	ctx := context.Background()
-
-	port := "8080"
-	addr := fmt.Sprintf("127.0.0.1:%s", port)
-	server := &http.Server{
-		Addr: addr,
+	if err := schwabClient.Authenticate(context.Background()); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
 
-	authCodeChan := make(chan string)
-
-	go func() {
-		authURL := schwabClient.GetAuthURL()
-		if err := browser.OpenURL(authURL); err != nil {
-			fmt.Println("Please visit the following URL to authorize the application:")
-			fmt.Println(authURL)
-		}
-
-		authCode := <-authCodeChan
-		fmt.Println("Received authorization code", authCode)
-
-		if err := schwabClient.ExchangeAuthCodeForAccessToken(ctx, authCode); err != nil {
-			fmt.Println("failed to get access token", err)
-			server.Shutdown(ctx)
-			return
-		}
-
-		if !schwabClient.IsAuthenticated() {
-			fmt.Println("failed to authenticate")
-			server.Shutdown(ctx)
-			return
-		}
-
-		fmt.Println("OAuth2.0 flow complete")
-		server.Shutdown(ctx)
-	}()
-
-	// Register the handler for all paths
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if authCode := r.URL.Query().Get("code"); authCode != "" {
-			authCodeChan <- authCode
-		}
-	})
-
-	// Start the HTTPS server with self-signed certificate
-	if err := server.ListenAndServeTLS(
-		"local-cert/cert.pem",
-		"local-cert/key.pem",
-	); err != nil && err != http.ErrServerClosed {
-		fmt.Println("server error", err)
-	}
+	fmt.Println("OAuth2.0 flow complete")
 }
@@ -0,0 +1,491 @@
+// Package alpacatest provides an in-memory mock of the Alpaca trading and
+// market data APIs for exercising the alpaca client without keys or network
+// access.
+package alpacatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/alpaca"
+)
+
+const (
+	// AccountID and AccountNumber identify the mock's single account
+	AccountID     = "904837e3-3b76-47ec-b432-046db621571b"
+	AccountNumber = "PA1234567890"
+
+	// KeyID and SecretKey are the only API key the mock accepts
+	KeyID     = "test-key-id"
+	SecretKey = "test-secret-key"
+)
+
+// Request is a request received by the mock
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   []byte
+}
+
+// Position is a holding of the mock account
+type Position struct {
+	Symbol       string
+	Quantity     float64
+	AveragePrice float64
+}
+
+// Server is a mock Alpaca API serving both the trading and market data
+// endpoints. Orders placed through it are kept in memory and can be read
+// back, replaced and cancelled; their status can be changed with
+// SetOrderStatus to simulate fills.
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	requests    []Request
+	overrides   map[string]cannedResponse
+	cash        float64
+	multiplier  string
+	positions   []Position
+	quotes      map[string]float64
+	orders      map[string]map[string]any
+	orderIDs    []string // Order IDs, oldest first
+	nextOrderID int
+}
+
+type cannedResponse struct {
+	status int
+	body   string
+}
+
+// NewServer starts a mock cash account with $10,000 in cash, no positions
+// and quotes for VTI and VXUS. Call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		overrides:   make(map[string]cannedResponse),
+		cash:        10_000,
+		multiplier:  "1",
+		quotes:      map[string]float64{"VTI": 250, "VXUS": 60},
+		orders:      make(map[string]map[string]any),
+		nextOrderID: 1000,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v2/account", s.authorized(s.handleAccount))
+	mux.HandleFunc("GET /v2/positions", s.authorized(s.handlePositions))
+	mux.HandleFunc("POST /v2/orders", s.authorized(s.handlePlaceOrder))
+	mux.HandleFunc("GET /v2/orders", s.authorized(s.handleListOrders))
+	mux.HandleFunc("GET /v2/orders/{id}", s.authorized(s.handleGetOrder))
+	mux.HandleFunc("PATCH /v2/orders/{id}", s.authorized(s.handleReplaceOrder))
+	mux.HandleFunc("DELETE /v2/orders/{id}", s.authorized(s.handleCancelOrder))
+	mux.HandleFunc("GET /v2/stocks/snapshots", s.authorized(s.handleSnapshots))
+	mux.HandleFunc("GET /v2/stocks/{symbol}/snapshot", s.authorized(s.handleSnapshot))
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(strings.NewReader(string(body)))
+
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.RawQuery,
+			Header: r.Header.Clone(),
+			Body:   body,
+		})
+		override, ok := s.overrides[r.Method+" "+r.URL.Path]
+		s.mu.Unlock()
+
+		if ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(override.status)
+			io.WriteString(w, override.body)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+
+	return s
+}
+
+// Config returns a client configuration pointing both endpoints at the mock
+func (s *Server) Config() alpaca.Config {
+	return alpaca.Config{
+		KeyID:         KeyID,
+		SecretKey:     SecretKey,
+		Paper:         true,
+		BaseURL:       s.URL,
+		DataURL:       s.URL,
+		AllowInsecure: true,
+	}
+}
+
+// NewClient returns a client for the mock
+func (s *Server) NewClient(opts ...alpaca.Option) *alpaca.Client {
+	return alpaca.NewClient(s.Config(), opts...)
+}
+
+// Requests returns every request received so far, oldest first
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// LastRequest returns the most recent request to method and path, or false
+func (s *Server) LastRequest(method, path string) (Request, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(s.requests) - 1; i >= 0; i-- {
+		if s.requests[i].Method == method && s.requests[i].Path == path {
+			return s.requests[i], true
+		}
+	}
+	return Request{}, false
+}
+
+// SetResponse makes every request to method and path return status and body
+// instead of the mock's own response, e.g. to simulate errors
+func (s *Server) SetResponse(method, path string, status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.overrides[method+" "+path] = cannedResponse{status: status, body: body}
+}
+
+// ClearResponse removes a response set with SetResponse
+func (s *Server) ClearResponse(method, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.overrides, method+" "+path)
+}
+
+// SetCash sets the account's cash balance
+func (s *Server) SetCash(cash float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cash = cash
+}
+
+// SetMargin makes the account a 2x margin account, or a cash account when
+// margin is false
+func (s *Server) SetMargin(margin bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.multiplier = "1"
+	if margin {
+		s.multiplier = "2"
+	}
+}
+
+// SetPositions replaces the account's holdings
+func (s *Server) SetPositions(positions ...Position) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.positions = append([]Position(nil), positions...)
+}
+
+// SetQuote sets the last price quoted for symbol
+func (s *Server) SetQuote(symbol string, price float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.quotes[symbol] = price
+}
+
+// SetOrderStatus changes the Alpaca status of a placed order. Setting it to
+// filled fills the whole order at price.
+func (s *Server) SetOrderStatus(orderID string, status string, price float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderID]
+	if !ok {
+		return fmt.Errorf("no order %s", orderID)
+	}
+
+	order["status"] = status
+	if status == "filled" {
+		quantity, _ := strconv.ParseFloat(stringValue(order["qty"]), 64)
+		if notional, err := strconv.ParseFloat(stringValue(order["notional"]), 64); err == nil && price > 0 {
+			quantity = notional / price
+		}
+		order["filled_qty"] = formatFloat(quantity)
+		order["filled_avg_price"] = formatFloat(price)
+		order["filled_at"] = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	return nil
+}
+
+// authorized rejects requests without the mock's API key
+func (s *Server) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("APCA-API-KEY-ID") != KeyID || r.Header.Get("APCA-API-SECRET-KEY") != SecretKey {
+			writeError(w, http.StatusUnauthorized, 40110000, "request is not authorized")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (s *Server) handleAccount(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var marketValue float64
+	for _, p := range s.positions {
+		marketValue += p.Quantity * s.quotes[p.Symbol]
+	}
+	buyingPower := s.cash
+	if s.multiplier != "1" {
+		buyingPower *= 2
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":                          AccountID,
+		"account_number":              AccountNumber,
+		"status":                      "ACTIVE",
+		"currency":                    "USD",
+		"cash":                        formatFloat(s.cash),
+		"buying_power":                formatFloat(buyingPower),
+		"non_marginable_buying_power": formatFloat(s.cash),
+		"long_market_value":           formatFloat(marketValue),
+		"equity":                      formatFloat(s.cash + marketValue),
+		"multiplier":                  s.multiplier,
+	})
+}
+
+func (s *Server) handlePositions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	positions := make([]map[string]any, 0, len(s.positions))
+	for _, p := range s.positions {
+		price := s.quotes[p.Symbol]
+		cost := p.Quantity * p.AveragePrice
+		value := p.Quantity * price
+		var plpc float64
+		if cost != 0 {
+			plpc = (value - cost) / cost
+		}
+		positions = append(positions, map[string]any{
+			"asset_id":        "asset-" + p.Symbol,
+			"symbol":          p.Symbol,
+			"asset_class":     "us_equity",
+			"qty":             formatFloat(p.Quantity),
+			"side":            "long",
+			"avg_entry_price": formatFloat(p.AveragePrice),
+			"current_price":   formatFloat(price),
+			"market_value":    formatFloat(value),
+			"cost_basis":      formatFloat(cost),
+			"unrealized_pl":   formatFloat(value - cost),
+			"unrealized_plpc": formatFloat(plpc),
+		})
+	}
+	writeJSON(w, http.StatusOK, positions)
+}
+
+func (s *Server) handlePlaceOrder(w http.ResponseWriter, r *http.Request) {
+	var order map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, 40010000, "invalid order: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, s.addOrderLocked(order))
+}
+
+func (s *Server) handleReplaceOrder(w http.ResponseWriter, r *http.Request) {
+	var changes map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, 40010000, "invalid order: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.orders[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, 40410000, "order not found")
+		return
+	}
+	if existing["status"] != "new" && existing["status"] != "accepted" {
+		writeError(w, http.StatusUnprocessableEntity, 42210000, "order is not replaceable")
+		return
+	}
+	existing["status"] = "replaced"
+
+	order := map[string]any{
+		"symbol":         existing["symbol"],
+		"side":           existing["side"],
+		"type":           existing["type"],
+		"time_in_force":  existing["time_in_force"],
+		"limit_price":    existing["limit_price"],
+		"extended_hours": existing["extended_hours"],
+		"qty":            existing["qty"],
+	}
+	for key, value := range changes {
+		order[key] = value
+	}
+	replacement := s.addOrderLocked(order)
+	existing["replaced_by"] = replacement["id"]
+	replacement["replaces"] = existing["id"]
+
+	writeJSON(w, http.StatusOK, replacement)
+}
+
+// addOrderLocked stores a submitted order as accepted. The caller must hold
+// mu.
+func (s *Server) addOrderLocked(order map[string]any) map[string]any {
+	s.nextOrderID++
+	id := fmt.Sprintf("order-%d", s.nextOrderID)
+
+	order["id"] = id
+	order["client_order_id"] = "client-" + id
+	order["order_class"] = ""
+	order["status"] = "accepted"
+	order["filled_qty"] = "0"
+	order["filled_avg_price"] = nil
+	order["submitted_at"] = time.Now().UTC().Format(time.RFC3339Nano)
+	order["filled_at"] = nil
+	if _, ok := order["qty"]; !ok {
+		order["qty"] = nil
+	}
+	s.orders[id] = order
+	s.orderIDs = append(s.orderIDs, id)
+	return order
+}
+
+func (s *Server) handleGetOrder(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, 40410000, "order not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, order)
+}
+
+func (s *Server) handleListOrders(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := r.URL.Query()
+	status := query.Get("status")
+	after, _ := time.Parse(time.RFC3339, query.Get("after"))
+	until, _ := time.Parse(time.RFC3339, query.Get("until"))
+
+	orders := make([]map[string]any, 0, len(s.orders))
+	for i := len(s.orderIDs) - 1; i >= 0; i-- {
+		order := s.orders[s.orderIDs[i]]
+		open := order["status"] == "new" || order["status"] == "accepted" || order["status"] == "partially_filled"
+		submitted, _ := time.Parse(time.RFC3339Nano, order["submitted_at"].(string))
+		switch {
+		case (status == "" || status == "open") && !open || status == "closed" && open:
+			continue
+		case !after.IsZero() && !submitted.After(after):
+			continue
+		case !until.IsZero() && submitted.After(until):
+			continue
+		}
+		orders = append(orders, order)
+	}
+	writeJSON(w, http.StatusOK, orders)
+}
+
+func (s *Server) handleCancelOrder(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, 40410000, "order not found")
+		return
+	}
+	if order["status"] != "new" && order["status"] != "accepted" {
+		writeError(w, http.StatusUnprocessableEntity, 42210000, "order is not cancelable")
+		return
+	}
+	order["status"] = "canceled"
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleSnapshots(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshots := make(map[string]any)
+	for _, symbol := range strings.Split(r.URL.Query().Get("symbols"), ",") {
+		if price, ok := s.quotes[symbol]; ok {
+			snapshots[symbol] = snapshot(price)
+		}
+	}
+	writeJSON(w, http.StatusOK, snapshots)
+}
+
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	price, ok := s.quotes[r.PathValue("symbol")]
+	if !ok {
+		writeError(w, http.StatusNotFound, 40410000, "symbol not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, snapshot(price))
+}
+
+// snapshot renders a market data snapshot around price. Market data prices
+// are numbers, unlike the trading API's strings.
+func snapshot(price float64) map[string]any {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	return map[string]any{
+		"latestTrade":  map[string]any{"t": now, "p": price, "s": 100},
+		"latestQuote":  map[string]any{"t": now, "bp": price - 0.01, "ap": price + 0.01, "bs": 1, "as": 1},
+		"dailyBar":     map[string]any{"t": now, "o": price, "h": price, "l": price, "c": price, "v": 1_000_000},
+		"prevDailyBar": map[string]any{"t": now, "o": price, "h": price, "l": price, "c": price, "v": 1_000_000},
+	}
+}
+
+// stringValue returns v when it is a string, and "" otherwise
+func stringValue(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+// formatFloat renders an amount the way the trading API does, as a string
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes Alpaca's error payload
+func writeError(w http.ResponseWriter, status int, code int, message string) {
+	writeJSON(w, status, map[string]any{"code": code, "message": message})
+}
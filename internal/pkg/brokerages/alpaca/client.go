@@ -0,0 +1,361 @@
+// Package alpaca implements the brokerage.BrokerageClient interface for
+// Alpaca's trading and market data APIs.
+package alpaca
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+// Alpaca API Documentation Links:
+// Trading API: https://docs.alpaca.markets/reference/getaccount-1
+// Market Data API: https://docs.alpaca.markets/reference/stocksnapshots-1
+
+const (
+	// Alpaca endpoints, used when Config leaves them empty
+	liveBaseURL    = "https://api.alpaca.markets"
+	paperBaseURL   = "https://paper-api.alpaca.markets"
+	defaultDataURL = "https://data.alpaca.markets"
+
+	// defaultFeed is the market data feed free accounts can use
+	defaultFeed = "iex"
+
+	// Alpaca API paths
+	accountPath   = "/v2/account"
+	positionsPath = "/v2/positions"
+	ordersPath    = "/v2/orders"
+	snapshotsPath = "/v2/stocks/snapshots"
+
+	// Headers carrying the API key
+	keyIDHeader     = "APCA-API-KEY-ID"
+	secretKeyHeader = "APCA-API-SECRET-KEY"
+
+	// maxSymbolsPerSnapshotRequest keeps batched snapshot URLs short
+	maxSymbolsPerSnapshotRequest = 100
+)
+
+// Version is reported in the default User-Agent. Release builds set it with
+// -ldflags "-X github.com/alysoliman1/money-pies/internal/pkg/brokerages/alpaca.Version=v1.2.3".
+var Version = "dev"
+
+// Config holds Alpaca API configuration
+type Config struct {
+	KeyID     string `json:"key_id"`
+	SecretKey string `json:"secret_key"`
+
+	// Paper trades in Alpaca's paper environment. Paper accounts have their
+	// own keys.
+	Paper bool `json:"paper"`
+
+	// BaseURL and DataURL override the trading and market data endpoints,
+	// e.g. to point the client at a local mock server. An empty BaseURL
+	// uses the live or paper endpoint according to Paper.
+	BaseURL string `json:"base_url"`
+	DataURL string `json:"data_url"`
+	// AllowInsecure permits plain http endpoint URLs for local testing
+	AllowInsecure bool `json:"allow_insecure"`
+
+	// Feed is the market data feed quotes come from, "iex" or "sip". Empty
+	// uses iex, the feed available without a data subscription.
+	Feed string `json:"feed"`
+
+	// UserAgent identifies the process in API requests. Empty uses
+	// "money-pies/<Version>".
+	UserAgent string `json:"user_agent"`
+
+	// DryRun logs orders to the WithLogger logger instead of submitting them.
+	// PlaceOrder, ReplaceOrder and CancelPendingOrder never reach Alpaca;
+	// their simulated orders fill at the current quote when GetOrderStatus
+	// is called. Reads are unaffected.
+	DryRun bool `json:"dry_run"`
+}
+
+// Client implements the brokerage.BrokerageClient interface for Alpaca. An
+// Alpaca key belongs to a single account, so the account IDs passed to its
+// methods are not sent to Alpaca.
+type Client struct {
+	config     Config
+	httpClient *http.Client
+	timeout    time.Duration
+	logger     *slog.Logger
+
+	// dryRun simulates order submission when Config.DryRun is set
+	dryRun *brokerage.DryRunOrders
+
+	// endpointErr records an invalid endpoint configuration, reported by
+	// every call that would use it
+	endpointErr error
+}
+
+// NewClient creates a new Alpaca client. Unless WithHTTPClient is given, the
+// underlying HTTP client uses defaultTimeout or the WithTimeout value.
+func NewClient(config Config, opts ...Option) *Client {
+	if config.BaseURL == "" {
+		config.BaseURL = liveBaseURL
+		if config.Paper {
+			config.BaseURL = paperBaseURL
+		}
+	}
+	if config.DataURL == "" {
+		config.DataURL = defaultDataURL
+	}
+	if config.Feed == "" {
+		config.Feed = defaultFeed
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	config.DataURL = strings.TrimSuffix(config.DataURL, "/")
+	if config.UserAgent == "" {
+		config.UserAgent = "money-pies/" + Version
+	}
+
+	c := &Client{
+		config:      config,
+		timeout:     defaultTimeout,
+		logger:      slog.New(slog.DiscardHandler),
+		endpointErr: validateEndpoints(config),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if config.DryRun {
+		c.dryRun = brokerage.NewDryRunOrders(c, c.logger)
+	}
+
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{
+			Timeout: c.timeout,
+		}
+	}
+
+	return c
+}
+
+// validateEndpoints checks that the configured endpoint URLs are absolute and
+// use https, unless AllowInsecure permits plain http
+func validateEndpoints(config Config) error {
+	endpoints := []struct {
+		name  string
+		value string
+	}{
+		{"base_url", config.BaseURL},
+		{"data_url", config.DataURL},
+	}
+
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint.value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %w", endpoint.name, endpoint.value, err)
+		}
+		if u.Host == "" {
+			return fmt.Errorf("invalid %s %q: must be an absolute URL", endpoint.name, endpoint.value)
+		}
+
+		switch {
+		case u.Scheme == "https":
+		case u.Scheme == "http" && config.AllowInsecure:
+		default:
+			return fmt.Errorf("invalid %s %q: scheme must be https unless allow_insecure is set", endpoint.name, endpoint.value)
+		}
+	}
+
+	return nil
+}
+
+// IsAuthenticated reports whether the client has an API key. Alpaca keys do
+// not expire, so a configured key is only found to be wrong by a request.
+func (c *Client) IsAuthenticated() bool {
+	return c.config.KeyID != "" && c.config.SecretKey != ""
+}
+
+// SupportsFractionalShares reports that Alpaca trades fractional shares
+func (c *Client) SupportsFractionalShares() bool {
+	return true
+}
+
+// request sends an API request to baseURL+path with payload, if any, as its
+// JSON body and returns the response body. Responses other than 2xx fail
+// with an *APIError, which also matches ErrNotAuthenticated for a rejected
+// key.
+func (c *Client) request(ctx context.Context, op, method, baseURL, path string, payload any) ([]byte, error) {
+	if c.endpointErr != nil {
+		return nil, c.endpointErr
+	}
+	if !c.IsAuthenticated() {
+		return nil, ErrNotAuthenticated
+	}
+
+	var body io.Reader
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s request: %w", op, err)
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set(keyIDHeader, c.config.KeyID)
+	req.Header.Set(secretKeyHeader, c.config.SecretKey)
+	req.Header.Set("User-Agent", c.config.UserAgent)
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		c.logger.WarnContext(ctx, "alpaca request failed",
+			slog.String("method", method),
+			slog.String("path", path),
+			slog.Duration("latency", latency),
+			slog.String("error", err.Error()))
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	level := slog.LevelInfo
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		level = slog.LevelWarn
+	}
+	c.logger.LogAttrs(ctx, level, "alpaca request",
+		slog.String("method", method),
+		slog.String("path", path),
+		slog.Int("status", resp.StatusCode),
+		slog.Duration("latency", latency))
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response: %w", op, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(op, resp, respBody)
+	}
+
+	return respBody, nil
+}
+
+// alpacaAccount mirrors the account object. Alpaca sends amounts as strings,
+// which decimal.Decimal decodes exactly.
+type alpacaAccount struct {
+	ID              string          `json:"id"`
+	AccountNumber   string          `json:"account_number"`
+	Status          string          `json:"status"`
+	Cash            decimal.Decimal `json:"cash"`
+	BuyingPower     decimal.Decimal `json:"buying_power"`
+	SettledCash     decimal.Decimal `json:"non_marginable_buying_power"`
+	LongMarketValue decimal.Decimal `json:"long_market_value"`
+	Equity          decimal.Decimal `json:"equity"`
+	Multiplier      string          `json:"multiplier"`
+}
+
+// GetAccounts retrieves the key's account. A multiplier of 1 makes it a cash
+// account, anything more a margin account.
+// Documentation: https://docs.alpaca.markets/reference/getaccount-1
+// Endpoint: GET /v2/account
+func (c *Client) GetAccounts(ctx context.Context) ([]brokerage.Account, error) {
+	body, err := c.request(ctx, "get account", "GET", c.config.BaseURL, accountPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var account alpacaAccount
+	if err := json.Unmarshal(body, &account); err != nil {
+		return nil, fmt.Errorf("failed to parse account response: %w", err)
+	}
+
+	accountType, kind := "MARGIN", brokerage.AccountKindMargin
+	if account.Multiplier == "1" {
+		accountType, kind = "CASH", brokerage.AccountKindCash
+	}
+	nickname := "Alpaca"
+	if c.config.Paper {
+		nickname = "Alpaca Paper"
+	}
+
+	return []brokerage.Account{{
+		AccountID:     account.ID,
+		AccountNumber: account.AccountNumber,
+		Nickname:      nickname,
+		Type:          accountType,
+		Kind:          kind,
+		CashBalance:   account.Cash,
+		SettledCash:   account.SettledCash,
+		BuyingPower:   account.BuyingPower,
+		MarketValue:   account.LongMarketValue,
+		TotalValue:    account.Equity,
+	}}, nil
+}
+
+// GetPositions retrieves the account's open positions. Short positions have
+// a negative quantity.
+// Documentation: https://docs.alpaca.markets/reference/getallopenpositions
+// Endpoint: GET /v2/positions
+func (c *Client) GetPositions(ctx context.Context, accountID string) ([]brokerage.Position, error) {
+	body, err := c.request(ctx, "get positions", "GET", c.config.BaseURL, positionsPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var alpacaPositions []struct {
+		Symbol         string          `json:"symbol"`
+		Quantity       decimal.Decimal `json:"qty"`
+		Side           string          `json:"side"`
+		AverageEntry   decimal.Decimal `json:"avg_entry_price"`
+		CurrentPrice   decimal.Decimal `json:"current_price"`
+		MarketValue    decimal.Decimal `json:"market_value"`
+		UnrealizedPL   decimal.Decimal `json:"unrealized_pl"`
+		UnrealizedPLPC decimal.Decimal `json:"unrealized_plpc"`
+		IntradayPL     decimal.Decimal `json:"unrealized_intraday_pl"`
+		AssetClass     string          `json:"asset_class"`
+	}
+	if err := json.Unmarshal(body, &alpacaPositions); err != nil {
+		return nil, fmt.Errorf("failed to parse positions response: %w", err)
+	}
+
+	positions := make([]brokerage.Position, 0, len(alpacaPositions))
+	for _, p := range alpacaPositions {
+		quantity := p.Quantity
+		if p.Side == "short" && quantity.IsPositive() {
+			quantity = quantity.Neg()
+		}
+		long, short := brokerage.LongShort(quantity)
+		assetType := p.AssetClass
+		if assetType == "us_equity" {
+			// Alpaca does not tell ETFs apart from stocks
+			assetType = brokerage.AssetTypeEquity
+		}
+		positions = append(positions, brokerage.Position{
+			Symbol:          p.Symbol,
+			AssetType:       assetType,
+			Quantity:        quantity,
+			LongQuantity:    long,
+			ShortQuantity:   short,
+			AveragePrice:    p.AverageEntry,
+			CurrentPrice:    p.CurrentPrice,
+			MarketValue:     p.MarketValue,
+			UnrealizedPL:    p.UnrealizedPL,
+			UnrealizedPLPct: p.UnrealizedPLPC.InexactFloat64() * 100,
+			DayPL:           p.IntradayPL,
+		})
+	}
+
+	return positions, nil
+}
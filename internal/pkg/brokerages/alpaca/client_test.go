@@ -0,0 +1,616 @@
+package alpaca_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/alpaca"
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/alpaca/alpacatest"
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+// requireJSONEqual fails the test unless got and want hold the same JSON
+func requireJSONEqual(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var gotValue, wantValue any
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("body %s is not JSON: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("want %s is not JSON: %v", want, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+}
+
+// placeLimitOrder places a day limit buy of quantity VTI shares and returns
+// its ID
+func placeLimitOrder(t *testing.T, client *alpaca.Client, quantity int64, limit string) string {
+	t.Helper()
+
+	order, err := client.PlaceOrder(t.Context(), alpacatest.AccountID, brokerage.OrderRequest{
+		Symbol:     "VTI",
+		Action:     brokerage.OrderActionBuy,
+		Type:       brokerage.OrderTypeLimit,
+		Quantity:   decimal.NewFromInt(quantity),
+		LimitPrice: brokerage.DecimalPtr(decimal.RequireFromString(limit)),
+	})
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	return order.ID
+}
+
+func TestGetAccounts(t *testing.T) {
+	tests := []struct {
+		name     string
+		margin   bool
+		wantType string
+		wantKind brokerage.AccountKind
+		wantBP   int64
+	}{
+		{name: "cash", wantType: "CASH", wantKind: brokerage.AccountKindCash, wantBP: 5000},
+		{name: "margin", margin: true, wantType: "MARGIN", wantKind: brokerage.AccountKindMargin, wantBP: 10000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := alpacatest.NewServer()
+			defer mock.Close()
+			mock.SetCash(5000)
+			mock.SetMargin(tt.margin)
+			mock.SetPositions(alpacatest.Position{Symbol: "VTI", Quantity: 10, AveragePrice: 200})
+			client := mock.NewClient()
+
+			accounts, err := client.GetAccounts(t.Context())
+			if err != nil {
+				t.Fatalf("GetAccounts() error = %v", err)
+			}
+			if len(accounts) != 1 {
+				t.Fatalf("GetAccounts() = %+v, want the key's single account", accounts)
+			}
+
+			got := accounts[0]
+			if got.AccountID != alpacatest.AccountID || got.AccountNumber != alpacatest.AccountNumber {
+				t.Errorf("ID and number = %q, %q, want %q, %q", got.AccountID, got.AccountNumber, alpacatest.AccountID, alpacatest.AccountNumber)
+			}
+			if got.Nickname != "Alpaca Paper" || got.Type != tt.wantType || got.Kind != tt.wantKind {
+				t.Errorf("nickname, type and kind = %q, %q, %q, want Alpaca Paper, %s, %s", got.Nickname, got.Type, got.Kind, tt.wantType, tt.wantKind)
+			}
+			for name, value := range map[string]struct{ got, want decimal.Decimal }{
+				"CashBalance": {got.CashBalance, decimal.NewFromInt(5000)},
+				"SettledCash": {got.SettledCash, decimal.NewFromInt(5000)},
+				"BuyingPower": {got.BuyingPower, decimal.NewFromInt(tt.wantBP)},
+				"MarketValue": {got.MarketValue, decimal.NewFromInt(2500)},
+				"TotalValue":  {got.TotalValue, decimal.NewFromInt(7500)},
+			} {
+				if !value.got.Equal(value.want) {
+					t.Errorf("%s = %s, want %s", name, value.got, value.want)
+				}
+			}
+
+			req, _ := mock.LastRequest("GET", "/v2/account")
+			if got := req.Header.Get("User-Agent"); got != "money-pies/"+alpaca.Version {
+				t.Errorf("User-Agent = %q, want money-pies/%s", got, alpaca.Version)
+			}
+		})
+	}
+}
+
+func TestGetPositions(t *testing.T) {
+	mock := alpacatest.NewServer()
+	defer mock.Close()
+	mock.SetPositions(
+		alpacatest.Position{Symbol: "VTI", Quantity: 10, AveragePrice: 200},
+		alpacatest.Position{Symbol: "VXUS", Quantity: 2.5, AveragePrice: 64},
+	)
+	client := mock.NewClient()
+
+	positions, err := client.GetPositions(t.Context(), alpacatest.AccountID)
+	if err != nil {
+		t.Fatalf("GetPositions() error = %v", err)
+	}
+	if len(positions) != 2 {
+		t.Fatalf("GetPositions() = %+v, want two positions", positions)
+	}
+
+	vti := positions[0]
+	if vti.Symbol != "VTI" || vti.AssetType != brokerage.AssetTypeEquity ||
+		!vti.Quantity.Equal(decimal.NewFromInt(10)) || !vti.LongQuantity.Equal(decimal.NewFromInt(10)) || !vti.ShortQuantity.IsZero() {
+		t.Errorf("VTI = %+v, want 10 long equity shares", vti)
+	}
+	if !vti.CurrentPrice.Equal(decimal.NewFromInt(250)) || !vti.MarketValue.Equal(decimal.NewFromInt(2500)) ||
+		!vti.UnrealizedPL.Equal(decimal.NewFromInt(500)) || vti.UnrealizedPLPct != 25 {
+		t.Errorf("VTI = %+v, want $2,500 at $250 with a $500 (25%%) gain", vti)
+	}
+
+	vxus := positions[1]
+	if !vxus.Quantity.Equal(decimal.RequireFromString("2.5")) || !vxus.UnrealizedPL.Equal(decimal.NewFromInt(-10)) {
+		t.Errorf("VXUS = %+v, want 2.5 fractional shares with a $10 loss", vxus)
+	}
+}
+
+func TestGetPositionsEmpty(t *testing.T) {
+	mock := alpacatest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+
+	positions, err := client.GetPositions(t.Context(), alpacatest.AccountID)
+	if err != nil {
+		t.Fatalf("GetPositions() error = %v", err)
+	}
+	if positions == nil || len(positions) != 0 {
+		t.Errorf("GetPositions() = %#v, want an empty, non-nil slice", positions)
+	}
+}
+
+func TestPlaceOrderSendsAlpacaOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		order brokerage.OrderRequest
+		want  string
+	}{
+		{
+			name: "market",
+			order: brokerage.OrderRequest{
+				Symbol:   "VTI",
+				Action:   brokerage.OrderActionBuy,
+				Type:     brokerage.OrderTypeMarket,
+				Quantity: decimal.RequireFromString("1.5"),
+			},
+			want: `{"symbol": "VTI", "qty": "1.5", "side": "buy", "type": "market", "time_in_force": "day"}`,
+		},
+		{
+			name: "notional market",
+			order: brokerage.OrderRequest{
+				Symbol: "VTI",
+				Action: brokerage.OrderActionBuy,
+				Type:   brokerage.OrderTypeMarket,
+				Amount: brokerage.DecimalPtr(decimal.RequireFromString("100.004")),
+			},
+			want: `{"symbol": "VTI", "notional": "100", "side": "buy", "type": "market", "time_in_force": "day"}`,
+		},
+		{
+			name: "good till cancel limit",
+			order: brokerage.OrderRequest{
+				Symbol:     "VXUS",
+				Action:     brokerage.OrderActionSell,
+				Type:       brokerage.OrderTypeLimit,
+				Quantity:   decimal.NewFromInt(12),
+				LimitPrice: brokerage.DecimalPtr(decimal.RequireFromString("61.25")),
+				Duration:   brokerage.OrderDurationGTC,
+			},
+			want: `{"symbol": "VXUS", "qty": "12", "side": "sell", "type": "limit", "time_in_force": "gtc", "limit_price": "61.25"}`,
+		},
+		{
+			name: "amount of a limit order in shares",
+			order: brokerage.OrderRequest{
+				Symbol:     "VXUS",
+				Action:     brokerage.OrderActionBuy,
+				Type:       brokerage.OrderTypeLimit,
+				Amount:     brokerage.DecimalPtr(decimal.NewFromInt(90)),
+				LimitPrice: brokerage.DecimalPtr(decimal.NewFromInt(60)),
+			},
+			want: `{"symbol": "VXUS", "qty": "1.5", "side": "buy", "type": "limit", "time_in_force": "day", "limit_price": "60"}`,
+		},
+		{
+			name: "extended hours limit",
+			order: brokerage.OrderRequest{
+				Symbol:     "VTI",
+				Action:     brokerage.OrderActionBuy,
+				Type:       brokerage.OrderTypeLimit,
+				Quantity:   decimal.NewFromInt(2),
+				LimitPrice: brokerage.DecimalPtr(decimal.NewFromInt(249)),
+				Session:    brokerage.OrderSessionPM,
+			},
+			want: `{"symbol": "VTI", "qty": "2", "side": "buy", "type": "limit", "time_in_force": "day", "limit_price": "249", "extended_hours": true}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := alpacatest.NewServer()
+			defer mock.Close()
+			client := mock.NewClient()
+
+			order, err := client.PlaceOrder(t.Context(), alpacatest.AccountID, tt.order)
+			if err != nil {
+				t.Fatalf("PlaceOrder() error = %v", err)
+			}
+			if order.ID == "" || order.Status != brokerage.OrderStatusPending || order.RawStatus != "accepted" {
+				t.Errorf("order ID and status = %q, %s (%s), want an ID, PENDING (accepted)", order.ID, order.Status, order.RawStatus)
+			}
+
+			req, ok := mock.LastRequest("POST", "/v2/orders")
+			if !ok {
+				t.Fatal("no order reached the mock")
+			}
+			if got := req.Header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			requireJSONEqual(t, req.Body, tt.want)
+		})
+	}
+}
+
+func TestPlaceOrderRejectsUnsupportedOrders(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   brokerage.OrderRequest
+		wantErr string
+	}{
+		{
+			name: "extended hours market",
+			order: brokerage.OrderRequest{
+				Symbol:   "VTI",
+				Action:   brokerage.OrderActionBuy,
+				Type:     brokerage.OrderTypeMarket,
+				Quantity: decimal.NewFromInt(1),
+				Session:  brokerage.OrderSessionAM,
+			},
+			wantErr: "orders in the AM session must be DAY limit orders",
+		},
+		{
+			name: "good till cancel extended hours limit",
+			order: brokerage.OrderRequest{
+				Symbol:     "VTI",
+				Action:     brokerage.OrderActionBuy,
+				Type:       brokerage.OrderTypeLimit,
+				Quantity:   decimal.NewFromInt(1),
+				LimitPrice: brokerage.DecimalPtr(decimal.NewFromInt(249)),
+				Duration:   brokerage.OrderDurationGTC,
+				Session:    brokerage.OrderSessionSeamless,
+			},
+			wantErr: "orders in the SEAMLESS session must be DAY limit orders",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := alpacatest.NewServer()
+			defer mock.Close()
+			client := mock.NewClient()
+
+			_, err := client.PlaceOrder(t.Context(), alpacatest.AccountID, tt.order)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("PlaceOrder() error = %v, want %q", err, tt.wantErr)
+			}
+			if _, ok := mock.LastRequest("POST", "/v2/orders"); ok {
+				t.Error("an unsupported order reached the mock")
+			}
+		})
+	}
+}
+
+func TestGetOrderStatus(t *testing.T) {
+	mock := alpacatest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+	id := placeLimitOrder(t, client, 4, "249.50")
+
+	order, err := client.GetOrderStatus(t.Context(), alpacatest.AccountID, id)
+	if err != nil {
+		t.Fatalf("GetOrderStatus() error = %v", err)
+	}
+	if order.Status != brokerage.OrderStatusPending || order.Symbol != "VTI" || order.Action != brokerage.OrderActionBuy ||
+		order.Type != brokerage.OrderTypeLimit || !order.Quantity.Equal(decimal.NewFromInt(4)) ||
+		order.LimitPrice == nil || !order.LimitPrice.Equal(decimal.RequireFromString("249.5")) {
+		t.Errorf("open order = %+v", order)
+	}
+
+	if err := mock.SetOrderStatus(id, "filled", 249.25); err != nil {
+		t.Fatal(err)
+	}
+	order, err = client.GetOrderStatus(t.Context(), alpacatest.AccountID, id)
+	if err != nil {
+		t.Fatalf("GetOrderStatus() error = %v", err)
+	}
+	if order.Status != brokerage.OrderStatusFilled || !order.FilledQty.Equal(decimal.NewFromInt(4)) ||
+		!order.FilledPrice.Equal(decimal.RequireFromString("249.25")) || order.FilledAt == nil {
+		t.Errorf("filled order = %+v, want 4 filled at 249.25", order)
+	}
+
+	_, err = client.GetOrderStatus(t.Context(), alpacatest.AccountID, "order-999")
+	var apiErr *alpaca.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Code != 40410000 || !alpaca.IsNotFound(err) {
+		t.Errorf("GetOrderStatus() of an unknown order error = %v, want a 404 APIError", err)
+	}
+}
+
+func TestFilledNotionalOrderCarriesItsQuantity(t *testing.T) {
+	mock := alpacatest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+
+	placed, err := client.PlaceOrder(t.Context(), alpacatest.AccountID, brokerage.OrderRequest{
+		Symbol: "VXUS",
+		Action: brokerage.OrderActionBuy,
+		Type:   brokerage.OrderTypeMarket,
+		Amount: brokerage.DecimalPtr(decimal.NewFromInt(150)),
+	})
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	if !placed.Quantity.IsZero() {
+		t.Errorf("unfilled notional order quantity = %s, want 0", placed.Quantity)
+	}
+
+	if err := mock.SetOrderStatus(placed.ID, "filled", 60); err != nil {
+		t.Fatal(err)
+	}
+	order, err := client.GetOrderStatus(t.Context(), alpacatest.AccountID, placed.ID)
+	if err != nil {
+		t.Fatalf("GetOrderStatus() error = %v", err)
+	}
+	if !order.Quantity.Equal(decimal.RequireFromString("2.5")) || !order.FilledQty.Equal(decimal.RequireFromString("2.5")) {
+		t.Errorf("filled notional order quantity = %s, filled %s, want 2.5", order.Quantity, order.FilledQty)
+	}
+}
+
+func TestOrderStatuses(t *testing.T) {
+	tests := []struct {
+		status string
+		want   brokerage.OrderStatus
+	}{
+		{"new", brokerage.OrderStatusPending},
+		{"accepted", brokerage.OrderStatusPending},
+		{"partially_filled", brokerage.OrderStatusPartiallyFilled},
+		{"pending_cancel", brokerage.OrderStatusWorking},
+		{"done_for_day", brokerage.OrderStatusWorking},
+		{"filled", brokerage.OrderStatusFilled},
+		{"canceled", brokerage.OrderStatusCancelled},
+		{"rejected", brokerage.OrderStatusRejected},
+		{"expired", brokerage.OrderStatusExpired},
+		{"replaced", brokerage.OrderStatusReplaced},
+		{"suspended", brokerage.OrderStatusUnknown},
+	}
+
+	mock := alpacatest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+	id := placeLimitOrder(t, client, 1, "200")
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			if err := mock.SetOrderStatus(id, tt.status, 0); err != nil {
+				t.Fatal(err)
+			}
+			order, err := client.GetOrderStatus(t.Context(), alpacatest.AccountID, id)
+			if err != nil {
+				t.Fatalf("GetOrderStatus() error = %v", err)
+			}
+			if order.Status != tt.want || order.RawStatus != tt.status {
+				t.Errorf("status = %s (%s), want %s", order.Status, order.RawStatus, tt.want)
+			}
+		})
+	}
+}
+
+func TestCancelPendingOrder(t *testing.T) {
+	mock := alpacatest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+	id := placeLimitOrder(t, client, 4, "200")
+
+	if err := client.CancelPendingOrder(t.Context(), alpacatest.AccountID, id); err != nil {
+		t.Fatalf("CancelPendingOrder() error = %v", err)
+	}
+	order, err := client.GetOrderStatus(t.Context(), alpacatest.AccountID, id)
+	if err != nil {
+		t.Fatalf("GetOrderStatus() error = %v", err)
+	}
+	if order.Status != brokerage.OrderStatusCancelled {
+		t.Errorf("status after cancelling = %s, want CANCELLED", order.Status)
+	}
+
+	if err := client.CancelPendingOrder(t.Context(), alpacatest.AccountID, id); !errors.Is(err, brokerage.ErrOrderNotOpen) {
+		t.Errorf("second CancelPendingOrder() error = %v, want ErrOrderNotOpen", err)
+	}
+}
+
+func TestReplaceOrder(t *testing.T) {
+	mock := alpacatest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+	id := placeLimitOrder(t, client, 4, "200")
+
+	replacement := brokerage.OrderRequest{
+		Symbol:     "VTI",
+		Action:     brokerage.OrderActionBuy,
+		Type:       brokerage.OrderTypeLimit,
+		Quantity:   decimal.NewFromInt(5),
+		LimitPrice: brokerage.DecimalPtr(decimal.RequireFromString("201.5")),
+	}
+	order, err := client.ReplaceOrder(t.Context(), alpacatest.AccountID, id, replacement)
+	if err != nil {
+		t.Fatalf("ReplaceOrder() error = %v", err)
+	}
+	if order.ID == id || !order.Quantity.Equal(decimal.NewFromInt(5)) || !order.LimitPrice.Equal(decimal.RequireFromString("201.5")) {
+		t.Errorf("replacement = %+v, want a new order for 5 at 201.5", order)
+	}
+
+	req, _ := mock.LastRequest("PATCH", "/v2/orders/"+id)
+	requireJSONEqual(t, req.Body, `{"qty": "5", "time_in_force": "day", "limit_price": "201.5"}`)
+
+	original, err := client.GetOrderStatus(t.Context(), alpacatest.AccountID, id)
+	if err != nil {
+		t.Fatalf("GetOrderStatus() error = %v", err)
+	}
+	if original.Status != brokerage.OrderStatusReplaced {
+		t.Errorf("original status = %s, want REPLACED", original.Status)
+	}
+
+	// Alpaca cannot change what is traded
+	replacement.Action = brokerage.OrderActionSell
+	if _, err := client.ReplaceOrder(t.Context(), alpacatest.AccountID, order.ID, replacement); err == nil ||
+		!strings.Contains(err.Error(), "only changes the quantity") {
+		t.Errorf("ReplaceOrder() changing the action error = %v", err)
+	}
+}
+
+func TestGetRecentOrders(t *testing.T) {
+	mock := alpacatest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+	first := placeLimitOrder(t, client, 1, "200")
+	second := placeLimitOrder(t, client, 2, "200")
+	third := placeLimitOrder(t, client, 3, "200")
+	if err := mock.SetOrderStatus(second, "filled", 200); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(orders []brokerage.Order) []string {
+		var ids []string
+		for _, order := range orders {
+			ids = append(ids, order.ID)
+		}
+		return ids
+	}
+
+	orders, err := client.GetRecentOrders(t.Context(), alpacatest.AccountID, brokerage.OrdersQuery{})
+	if err != nil {
+		t.Fatalf("GetRecentOrders() error = %v", err)
+	}
+	if got, want := ids(orders), []string{third, second, first}; !slices.Equal(got, want) {
+		t.Errorf("GetRecentOrders() = %v, want %v, newest first", got, want)
+	}
+	req, _ := mock.LastRequest("GET", "/v2/orders")
+	if !strings.Contains(req.Query, "status=all") || !strings.Contains(req.Query, "direction=desc") {
+		t.Errorf("query = %q, want every order newest first", req.Query)
+	}
+
+	orders, err = client.GetRecentOrders(t.Context(), alpacatest.AccountID, brokerage.OrdersQuery{Status: brokerage.OrderStatusFilled})
+	if err != nil {
+		t.Fatalf("GetRecentOrders() error = %v", err)
+	}
+	if got, want := ids(orders), []string{second}; !slices.Equal(got, want) {
+		t.Errorf("GetRecentOrders(FILLED) = %v, want %v", got, want)
+	}
+	req, _ = mock.LastRequest("GET", "/v2/orders")
+	if !strings.Contains(req.Query, "status=closed") {
+		t.Errorf("query = %q, want closed orders", req.Query)
+	}
+
+	orders, err = client.GetRecentOrders(t.Context(), alpacatest.AccountID, brokerage.OrdersQuery{MaxResults: 1})
+	if err != nil {
+		t.Fatalf("GetRecentOrders() error = %v", err)
+	}
+	if got, want := ids(orders), []string{third}; !slices.Equal(got, want) {
+		t.Errorf("GetRecentOrders(MaxResults 1) = %v, want %v", got, want)
+	}
+
+	from := time.Date(2024, 3, 1, 9, 30, 0, 0, time.FixedZone("EST", -5*60*60))
+	if _, err := client.GetRecentOrders(t.Context(), alpacatest.AccountID, brokerage.OrdersQuery{From: from}); err != nil {
+		t.Fatalf("GetRecentOrders() error = %v", err)
+	}
+	req, _ = mock.LastRequest("GET", "/v2/orders")
+	if !strings.Contains(req.Query, "after=2024-03-01T14%3A30%3A00Z") {
+		t.Errorf("query = %q, want after in UTC", req.Query)
+	}
+}
+
+func TestGetQuote(t *testing.T) {
+	mock := alpacatest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+
+	quote, err := client.GetQuote(t.Context(), "VTI")
+	if err != nil {
+		t.Fatalf("GetQuote() error = %v", err)
+	}
+	if quote.Symbol != "VTI" || !quote.Last.Equal(decimal.NewFromInt(250)) || !quote.Bid.Equal(decimal.RequireFromString("249.99")) ||
+		!quote.Ask.Equal(decimal.RequireFromString("250.01")) || !quote.Close.Equal(decimal.NewFromInt(250)) || quote.Volume != 1_000_000 {
+		t.Errorf("GetQuote() = %+v, want VTI at 250", quote)
+	}
+
+	req, _ := mock.LastRequest("GET", "/v2/stocks/VTI/snapshot")
+	if req.Query != "feed=iex" {
+		t.Errorf("query = %q, want the iex feed", req.Query)
+	}
+
+	var apiErr *alpaca.APIError
+	if _, err := client.GetQuote(t.Context(), "NOPE"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("GetQuote() of an unknown symbol error = %v, want a 404 APIError", err)
+	}
+}
+
+func TestGetQuotesReportsMissingSymbols(t *testing.T) {
+	mock := alpacatest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+
+	quotes, err := client.GetQuotes(t.Context(), []string{"VTI", "NOPE", "VXUS"})
+	var missing *brokerage.MissingQuotesError
+	if !errors.As(err, &missing) || !slices.Equal(missing.Symbols, []string{"NOPE"}) {
+		t.Fatalf("GetQuotes() error = %v, want NOPE missing", err)
+	}
+	if len(quotes) != 2 || !quotes["VTI"].Last.Equal(decimal.NewFromInt(250)) || !quotes["VXUS"].Last.Equal(decimal.NewFromInt(60)) {
+		t.Errorf("GetQuotes() = %+v, want VTI and VXUS", quotes)
+	}
+	if got := len(mock.Requests()); got != 1 {
+		t.Errorf("GetQuotes() sent %d requests, want one batch", got)
+	}
+}
+
+func TestUnauthenticated(t *testing.T) {
+	mock := alpacatest.NewServer()
+	defer mock.Close()
+
+	config := mock.Config()
+	config.SecretKey = ""
+	client := alpaca.NewClient(config)
+	if client.IsAuthenticated() {
+		t.Error("IsAuthenticated() = true without a secret key")
+	}
+	if _, err := client.GetAccounts(t.Context()); !errors.Is(err, brokerage.ErrNotAuthenticated) {
+		t.Errorf("GetAccounts() without a secret key error = %v, want ErrNotAuthenticated", err)
+	}
+	if got := len(mock.Requests()); got != 0 {
+		t.Errorf("sent %d requests without a key, want none", got)
+	}
+
+	config.SecretKey = "wrong"
+	client = alpaca.NewClient(config)
+	_, err := client.GetAccounts(t.Context())
+	var apiErr *alpaca.APIError
+	if !errors.Is(err, brokerage.ErrNotAuthenticated) || !errors.As(err, &apiErr) || apiErr.Message != "request is not authorized" {
+		t.Errorf("GetAccounts() with a rejected key error = %v, want ErrNotAuthenticated carrying Alpaca's message", err)
+	}
+}
+
+func TestEndpointValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  alpaca.Config
+		wantErr string
+	}{
+		{
+			name:    "plain http",
+			config:  alpaca.Config{KeyID: "k", SecretKey: "s", BaseURL: "http://localhost:1234"},
+			wantErr: `invalid base_url "http://localhost:1234": scheme must be https unless allow_insecure is set`,
+		},
+		{
+			name:    "relative data URL",
+			config:  alpaca.Config{KeyID: "k", SecretKey: "s", DataURL: "data.example.com"},
+			wantErr: `invalid data_url "data.example.com": must be an absolute URL`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := alpaca.NewClient(tt.config)
+			if _, err := client.GetAccounts(t.Context()); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("GetAccounts() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package alpaca
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// ErrNotAuthenticated is returned when the client has no API key or Alpaca
+// rejects it. It is brokerage.ErrNotAuthenticated.
+var ErrNotAuthenticated = brokerage.ErrNotAuthenticated
+
+// APIError is returned when Alpaca answers a request with an unexpected
+// status code. Alpaca's {"code","message"} error payload is parsed into
+// Code and Message.
+type APIError struct {
+	Op         string // The operation that failed, e.g. "get account"
+	StatusCode int
+	Code       int
+	Message    string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	detail := e.Message
+	if detail == "" {
+		detail = e.Body
+	}
+	return fmt.Sprintf("%s failed with status %d: %s", e.Op, e.StatusCode, detail)
+}
+
+// Is makes an APIError for a rejected key match ErrNotAuthenticated
+func (e *APIError) Is(target error) bool {
+	return target == ErrNotAuthenticated && e.StatusCode == http.StatusUnauthorized
+}
+
+// newAPIError builds an APIError from a failed response and its body
+func newAPIError(op string, resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		Op:         op,
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+	}
+
+	var payload struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &payload) == nil {
+		apiErr.Code = payload.Code
+		apiErr.Message = payload.Message
+	}
+	return apiErr
+}
+
+// IsNotFound reports whether err is an APIError for a 404 response
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,40 @@
+package alpaca
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/logging"
+)
+
+// defaultTimeout is the HTTP timeout used when WithTimeout is not given
+const defaultTimeout = 30 * time.Second
+
+// Option configures optional behaviour of a Client
+type Option func(*Client)
+
+// WithTimeout sets the timeout applied to every HTTP request made by the
+// client. A timeout of 0 disables the timeout entirely. It has no effect when
+// combined with WithHTTPClient.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithHTTPClient makes the client send all requests through httpClient. Use
+// it to supply a proxy, custom TLS settings or an instrumented Transport.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithLogger logs every API request (method, path, status and latency) to
+// logger. API keys are never logged.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logging.RedactLogger(logger)
+	}
+}
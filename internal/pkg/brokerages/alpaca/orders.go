@@ -0,0 +1,362 @@
+package alpaca
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+// maxOrdersPerRequest is the most orders Alpaca lists in one response
+const maxOrdersPerRequest = 500
+
+// alpacaOrderRequest is the body of an order submission. Quantities and
+// prices are sent as strings, as Alpaca documents them.
+type alpacaOrderRequest struct {
+	Symbol        string           `json:"symbol"`
+	Quantity      *decimal.Decimal `json:"qty,omitempty"`
+	Notional      *decimal.Decimal `json:"notional,omitempty"`
+	Side          string           `json:"side"`
+	Type          string           `json:"type"`
+	TimeInForce   string           `json:"time_in_force"`
+	LimitPrice    *decimal.Decimal `json:"limit_price,omitempty"`
+	StopPrice     *decimal.Decimal `json:"stop_price,omitempty"`
+	ExtendedHours bool             `json:"extended_hours,omitempty"`
+}
+
+// alpacaOrder mirrors the order object returned by the orders endpoints
+type alpacaOrder struct {
+	ID             string           `json:"id"`
+	Symbol         string           `json:"symbol"`
+	Side           string           `json:"side"`
+	Type           string           `json:"type"`
+	OrderClass     string           `json:"order_class"`
+	Status         string           `json:"status"`
+	Quantity       decimal.Decimal  `json:"qty"`
+	Notional       decimal.Decimal  `json:"notional"`
+	FilledQuantity decimal.Decimal  `json:"filled_qty"`
+	FilledAvgPrice decimal.Decimal  `json:"filled_avg_price"`
+	LimitPrice     *decimal.Decimal `json:"limit_price"`
+	StopPrice      *decimal.Decimal `json:"stop_price"`
+	SubmittedAt    time.Time        `json:"submitted_at"`
+	FilledAt       *time.Time       `json:"filled_at"`
+	Legs           []alpacaOrder    `json:"legs"`
+}
+
+// PlaceOrder submits a new order. Orders sized by Amount are sent as
+// notional orders when they are DAY market orders in the normal session,
+// which is all Alpaca accepts notional orders as, and are otherwise
+// converted to fractional shares at the current quote.
+// Documentation: https://docs.alpaca.markets/reference/postorder
+// Endpoint: POST /v2/orders
+func (c *Client) PlaceOrder(ctx context.Context, accountID string, order brokerage.OrderRequest) (*brokerage.Order, error) {
+	if err := order.Validate(); err != nil {
+		return nil, err
+	}
+	if c.dryRun != nil {
+		return c.dryRun.Place(ctx, accountID, order)
+	}
+	if !acceptsNotional(order) {
+		var err error
+		if order, err = brokerage.ResolveAmount(ctx, c, order, true); err != nil {
+			return nil, err
+		}
+	}
+
+	request, err := buildOrderRequest(order)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.request(ctx, "place order", "POST", c.config.BaseURL, ordersPath, request)
+	if err != nil {
+		return nil, err
+	}
+	return parseOrder("place order", body)
+}
+
+// acceptsNotional reports whether Alpaca takes order, sized by Amount, as a
+// notional order
+func acceptsNotional(order brokerage.OrderRequest) bool {
+	return order.Amount != nil &&
+		order.Type == brokerage.OrderTypeMarket &&
+		(order.Duration == "" || order.Duration == brokerage.OrderDurationDay) &&
+		(order.Session == "" || order.Session == brokerage.OrderSessionNormal)
+}
+
+// buildOrderRequest validates an order request and converts it to an Alpaca
+// order. Duration and session default to DAY and NORMAL; any other session
+// is sent as an extended hours order, which Alpaca only takes as a DAY limit
+// order.
+func buildOrderRequest(order brokerage.OrderRequest) (alpacaOrderRequest, error) {
+	if err := order.Validate(); err != nil {
+		return alpacaOrderRequest{}, err
+	}
+
+	request := alpacaOrderRequest{
+		Symbol: order.Symbol,
+		Side:   strings.ToLower(string(order.Action)),
+		Type:   strings.ToLower(string(order.Type)),
+	}
+	if order.Amount != nil {
+		request.Notional = brokerage.DecimalPtr(brokerage.RoundCents(*order.Amount))
+	} else {
+		request.Quantity = &order.Quantity
+	}
+
+	switch order.Action {
+	case brokerage.OrderActionBuy, brokerage.OrderActionSell:
+	default:
+		return alpacaOrderRequest{}, fmt.Errorf("unsupported order action %q", order.Action)
+	}
+
+	switch order.Type {
+	case brokerage.OrderTypeMarket:
+	case brokerage.OrderTypeLimit:
+		if order.LimitPrice == nil || !order.LimitPrice.IsPositive() {
+			return alpacaOrderRequest{}, errors.New("limit orders require a positive limit price")
+		}
+		request.LimitPrice = order.LimitPrice
+	case brokerage.OrderTypeStop:
+		if order.StopPrice == nil || !order.StopPrice.IsPositive() {
+			return alpacaOrderRequest{}, errors.New("stop orders require a positive stop price")
+		}
+		request.StopPrice = order.StopPrice
+	default:
+		return alpacaOrderRequest{}, fmt.Errorf("unsupported order type %q", order.Type)
+	}
+
+	switch order.Duration {
+	case "", brokerage.OrderDurationDay:
+		request.TimeInForce = "day"
+	case brokerage.OrderDurationGTC:
+		request.TimeInForce = "gtc"
+	default:
+		return alpacaOrderRequest{}, fmt.Errorf("unsupported order duration %q", order.Duration)
+	}
+
+	switch order.Session {
+	case "", brokerage.OrderSessionNormal:
+	case brokerage.OrderSessionAM, brokerage.OrderSessionPM, brokerage.OrderSessionSeamless:
+		if order.Type != brokerage.OrderTypeLimit || request.TimeInForce != "day" {
+			return alpacaOrderRequest{}, fmt.Errorf("orders in the %s session must be DAY limit orders", order.Session)
+		}
+		request.ExtendedHours = true
+	default:
+		return alpacaOrderRequest{}, fmt.Errorf("unsupported order session %q", order.Session)
+	}
+
+	return request, nil
+}
+
+// ReplaceOrder replaces the quantity, limit or stop price and duration of a
+// working order, which is all Alpaca lets a replacement change. Orders sized by
+// Amount are converted to fractional shares at the current quote.
+// Documentation: https://docs.alpaca.markets/reference/patchorderbyorderid-1
+// Endpoint: PATCH /v2/orders/{order_id}
+func (c *Client) ReplaceOrder(ctx context.Context, accountID string, orderID string, newOrder brokerage.OrderRequest) (*brokerage.Order, error) {
+	if c.dryRun != nil {
+		return c.dryRun.Replace(ctx, accountID, orderID, newOrder)
+	}
+
+	newOrder, err := brokerage.ResolveAmount(ctx, c, newOrder, true)
+	if err != nil {
+		return nil, err
+	}
+	request, err := buildOrderRequest(newOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := c.GetOrderStatus(ctx, accountID, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check order %s before replacing: %w", orderID, err)
+	}
+	if !existing.Status.IsOpen() {
+		return nil, fmt.Errorf("cannot replace order %s with status %s", orderID, existing.Status)
+	}
+	if !strings.EqualFold(existing.Symbol, newOrder.Symbol) || existing.Action != newOrder.Action || existing.Type != newOrder.Type {
+		return nil, fmt.Errorf("cannot replace order %s: alpaca only changes the quantity, prices and duration of an order", orderID)
+	}
+
+	replacement := struct {
+		Quantity    *decimal.Decimal `json:"qty"`
+		TimeInForce string           `json:"time_in_force"`
+		LimitPrice  *decimal.Decimal `json:"limit_price,omitempty"`
+		StopPrice   *decimal.Decimal `json:"stop_price,omitempty"`
+	}{request.Quantity, request.TimeInForce, request.LimitPrice, request.StopPrice}
+
+	body, err := c.request(ctx, "replace order", "PATCH", c.config.BaseURL, ordersPath+"/"+url.PathEscape(orderID), replacement)
+	if err != nil {
+		return nil, err
+	}
+	return parseOrder("replace order", body)
+}
+
+// GetOrderStatus retrieves an order by its ID
+// Documentation: https://docs.alpaca.markets/reference/getorderbyorderid
+// Endpoint: GET /v2/orders/{order_id}
+func (c *Client) GetOrderStatus(ctx context.Context, accountID string, orderID string) (*brokerage.Order, error) {
+	if c.dryRun != nil {
+		if order, ok, err := c.dryRun.Status(ctx, orderID); ok {
+			return order, err
+		}
+	}
+
+	body, err := c.request(ctx, "get order", "GET", c.config.BaseURL, ordersPath+"/"+url.PathEscape(orderID), nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseOrder("get order", body)
+}
+
+// CancelPendingOrder cancels an open order
+// Documentation: https://docs.alpaca.markets/reference/deleteorderbyorderid
+// Endpoint: DELETE /v2/orders/{order_id}
+func (c *Client) CancelPendingOrder(ctx context.Context, accountID string, orderID string) error {
+	if c.dryRun != nil {
+		return c.dryRun.Cancel(ctx, accountID, orderID)
+	}
+
+	_, err := c.request(ctx, "cancel order", "DELETE", c.config.BaseURL, ordersPath+"/"+url.PathEscape(orderID), nil)
+	if err != nil {
+		return brokerage.CheckOrderClosed(ctx, c, accountID, orderID, err)
+	}
+	return nil
+}
+
+// GetRecentOrders retrieves the account's orders matching query, newest
+// first. Alpaca only filters by open or closed, so other statuses are
+// filtered after fetching up to 500 orders.
+// Documentation: https://docs.alpaca.markets/reference/getallorders
+// Endpoint: GET /v2/orders
+func (c *Client) GetRecentOrders(ctx context.Context, accountID string, query brokerage.OrdersQuery) ([]brokerage.Order, error) {
+	params := url.Values{}
+	params.Set("direction", "desc")
+	params.Set("nested", "true")
+	params.Set("limit", strconv.Itoa(maxOrdersPerRequest))
+	switch {
+	case query.Status == "":
+		params.Set("status", "all")
+	case query.Status.IsOpen():
+		params.Set("status", "open")
+	default:
+		params.Set("status", "closed")
+	}
+	if !query.From.IsZero() {
+		params.Set("after", query.From.UTC().Format(time.RFC3339))
+	}
+	if !query.To.IsZero() {
+		params.Set("until", query.To.UTC().Format(time.RFC3339))
+	}
+
+	body, err := c.request(ctx, "get orders", "GET", c.config.BaseURL, ordersPath+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var alpacaOrders []alpacaOrder
+	if err := json.Unmarshal(body, &alpacaOrders); err != nil {
+		return nil, fmt.Errorf("failed to parse orders response: %w", err)
+	}
+
+	orders := make([]brokerage.Order, 0, len(alpacaOrders))
+	for _, ao := range alpacaOrders {
+		order := convertOrder(ao)
+		if query.Status != "" && order.Status != query.Status {
+			continue
+		}
+
+		orders = append(orders, order)
+		if query.MaxResults > 0 && len(orders) == query.MaxResults {
+			break
+		}
+	}
+
+	return orders, nil
+}
+
+// parseOrder decodes a single order response
+func parseOrder(op string, body []byte) (*brokerage.Order, error) {
+	var ao alpacaOrder
+	if err := json.Unmarshal(body, &ao); err != nil {
+		return nil, fmt.Errorf("failed to parse %s response: %w", op, err)
+	}
+
+	order := convertOrder(ao)
+	order.RawResponse = string(body)
+	return &order, nil
+}
+
+// convertOrder converts an Alpaca order to our standard order. A notional
+// order has no quantity until it fills, and then carries its filled
+// quantity. The legs of bracket, OCO and OTO orders are converted into
+// Children.
+func convertOrder(ao alpacaOrder) brokerage.Order {
+	order := brokerage.Order{
+		ID:          ao.ID,
+		Symbol:      ao.Symbol,
+		Action:      brokerage.OrderAction(strings.ToUpper(ao.Side)),
+		Type:        brokerage.OrderType(strings.ToUpper(ao.Type)),
+		Quantity:    ao.Quantity,
+		LimitPrice:  ao.LimitPrice,
+		StopPrice:   ao.StopPrice,
+		Status:      convertOrderStatus(ao.Status, ao.FilledQuantity),
+		RawStatus:   ao.Status,
+		FilledQty:   ao.FilledQuantity,
+		FilledPrice: ao.FilledAvgPrice,
+		SubmittedAt: ao.SubmittedAt,
+		FilledAt:    ao.FilledAt,
+	}
+	if order.Quantity.IsZero() && order.Status == brokerage.OrderStatusFilled {
+		order.Quantity = order.FilledQty
+	}
+
+	switch ao.OrderClass {
+	case "oco":
+		order.Strategy = brokerage.OrderStrategyOCO
+	case "oto", "bracket":
+		order.Strategy = brokerage.OrderStrategyTrigger
+	}
+	for _, leg := range ao.Legs {
+		order.Children = append(order.Children, convertOrder(leg))
+	}
+
+	return order
+}
+
+// convertOrderStatus converts an Alpaca order status to our standard status.
+// Orders waiting on a cancel or replace, or done for the day, can still
+// fill, so they count as working.
+func convertOrderStatus(status string, filledQty decimal.Decimal) brokerage.OrderStatus {
+	switch status {
+	case "new", "accepted", "pending_new", "accepted_for_bidding", "held":
+		return brokerage.OrderStatusPending
+	case "partially_filled":
+		return brokerage.OrderStatusPartiallyFilled
+	case "pending_cancel", "pending_replace", "done_for_day":
+		if filledQty.IsPositive() {
+			return brokerage.OrderStatusPartiallyFilled
+		}
+		return brokerage.OrderStatusWorking
+	case "filled":
+		return brokerage.OrderStatusFilled
+	case "canceled":
+		return brokerage.OrderStatusCancelled
+	case "rejected":
+		return brokerage.OrderStatusRejected
+	case "expired":
+		return brokerage.OrderStatusExpired
+	case "replaced":
+		return brokerage.OrderStatusReplaced
+	default:
+		return brokerage.OrderStatusUnknown
+	}
+}
@@ -0,0 +1,143 @@
+package alpaca
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+// alpacaSnapshot mirrors a market data snapshot: the latest trade and quote
+// and the current and previous daily bars
+type alpacaSnapshot struct {
+	LatestTrade *struct {
+		Timestamp time.Time       `json:"t"`
+		Price     decimal.Decimal `json:"p"`
+	} `json:"latestTrade"`
+	LatestQuote *struct {
+		Timestamp time.Time       `json:"t"`
+		Bid       decimal.Decimal `json:"bp"`
+		Ask       decimal.Decimal `json:"ap"`
+	} `json:"latestQuote"`
+	DailyBar     *alpacaBar `json:"dailyBar"`
+	PrevDailyBar *alpacaBar `json:"prevDailyBar"`
+}
+
+// alpacaBar is the part of a price bar a quote uses
+type alpacaBar struct {
+	Close  decimal.Decimal `json:"c"`
+	Volume int64           `json:"v"`
+}
+
+// GetQuote retrieves a quote for a symbol from its market data snapshot
+// Documentation: https://docs.alpaca.markets/reference/stocksnapshotsingle
+// Endpoint: GET /v2/stocks/{symbol}/snapshot
+func (c *Client) GetQuote(ctx context.Context, symbol string) (*brokerage.Quote, error) {
+	params := url.Values{}
+	params.Set("feed", c.config.Feed)
+	path := fmt.Sprintf("/v2/stocks/%s/snapshot?%s", url.PathEscape(symbol), params.Encode())
+
+	body, err := c.request(ctx, "get quote", "GET", c.config.DataURL, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot alpacaSnapshot
+	if err := json.Unmarshal(body, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse quote response: %w", err)
+	}
+	if snapshot.LatestTrade == nil && snapshot.LatestQuote == nil && snapshot.DailyBar == nil {
+		return nil, fmt.Errorf("no quote returned for symbol %s", symbol)
+	}
+
+	quote := convertSnapshot(symbol, snapshot)
+	return &quote, nil
+}
+
+// BatchesQuotes reports that GetQuotes fetches many symbols per request,
+// implementing brokerage.QuoteBatcher
+func (c *Client) BatchesQuotes() bool {
+	return true
+}
+
+// GetQuotes retrieves quotes for multiple symbols, batching them into
+// snapshot requests of up to 100 symbols. Symbols missing from the response
+// are reported through a *brokerage.MissingQuotesError together with the
+// quotes that were found.
+// Documentation: https://docs.alpaca.markets/reference/stocksnapshots-1
+// Endpoint: GET /v2/stocks/snapshots
+func (c *Client) GetQuotes(ctx context.Context, symbols []string) (map[string]brokerage.Quote, error) {
+	quotes := make(map[string]brokerage.Quote, len(symbols))
+	var missing []string
+
+	for start := 0; start < len(symbols); start += maxSymbolsPerSnapshotRequest {
+		end := min(start+maxSymbolsPerSnapshotRequest, len(symbols))
+		batch := symbols[start:end]
+
+		params := url.Values{}
+		params.Set("symbols", strings.Join(batch, ","))
+		params.Set("feed", c.config.Feed)
+
+		body, err := c.request(ctx, "get quotes", "GET", c.config.DataURL, snapshotsPath+"?"+params.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var snapshots map[string]*alpacaSnapshot
+		if err := json.Unmarshal(body, &snapshots); err != nil {
+			return nil, fmt.Errorf("failed to parse quotes response: %w", err)
+		}
+
+		for _, symbol := range batch {
+			snapshot, ok := snapshots[symbol]
+			if !ok {
+				snapshot, ok = snapshots[strings.ToUpper(symbol)]
+			}
+			if !ok || snapshot == nil {
+				missing = append(missing, symbol)
+				continue
+			}
+			quotes[symbol] = convertSnapshot(symbol, *snapshot)
+		}
+	}
+
+	if len(missing) > 0 {
+		return quotes, &brokerage.MissingQuotesError{Symbols: missing}
+	}
+
+	return quotes, nil
+}
+
+// convertSnapshot converts a snapshot to a quote. Last is the latest trade,
+// or today's close when there has been none, and Close is the previous
+// day's close.
+func convertSnapshot(symbol string, snapshot alpacaSnapshot) brokerage.Quote {
+	quote := brokerage.Quote{
+		Symbol: symbol,
+		Raw:    snapshot,
+	}
+	if snapshot.LatestQuote != nil {
+		quote.Bid = snapshot.LatestQuote.Bid
+		quote.Ask = snapshot.LatestQuote.Ask
+		quote.Timestamp = snapshot.LatestQuote.Timestamp
+	}
+	if snapshot.LatestTrade != nil {
+		quote.Last = snapshot.LatestTrade.Price
+		quote.Timestamp = snapshot.LatestTrade.Timestamp
+	}
+	if snapshot.DailyBar != nil {
+		if quote.Last.IsZero() {
+			quote.Last = snapshot.DailyBar.Close
+		}
+		quote.Volume = snapshot.DailyBar.Volume
+	}
+	if snapshot.PrevDailyBar != nil {
+		quote.Close = snapshot.PrevDailyBar.Close
+	}
+	return quote
+}
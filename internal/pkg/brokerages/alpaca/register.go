@@ -0,0 +1,32 @@
+package alpaca
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+func init() {
+	brokerage.RegisterBrokerage("alpaca", newBrokerage)
+}
+
+// newBrokerage creates a client from a Config in JSON, logging to
+// slog.Default, and fails if it has no API key or an invalid endpoint
+func newBrokerage(raw json.RawMessage) (brokerage.BrokerageClient, error) {
+	var config Config
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse alpaca config: %w", err)
+	}
+
+	client := NewClient(config, WithLogger(slog.Default()))
+	if !client.IsAuthenticated() {
+		return nil, errors.New("alpaca config needs a key_id and secret_key")
+	}
+	if client.endpointErr != nil {
+		return nil, client.endpointErr
+	}
+	return client, nil
+}
@@ -0,0 +1,56 @@
+// Package brokerages links every brokerage package into a binary, each
+// registering itself with pies.RegisterBrokerage, and opens the brokerage
+// configured for the commands.
+package brokerages
+
+import (
+	"fmt"
+
+	_ "github.com/alysoliman1/money-pies/internal/pkg/brokerages/alpaca"
+	_ "github.com/alysoliman1/money-pies/internal/pkg/brokerages/paper"
+	_ "github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+	_ "github.com/alysoliman1/money-pies/internal/pkg/brokerages/tradier"
+	"github.com/alysoliman1/money-pies/internal/pkg/config"
+	"github.com/alysoliman1/money-pies/internal/pkg/notify"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// Open creates a client for the brokerage configured by config.Load with
+// overrides and returns it with the brokerage's name. As with
+// pies.NewBrokerage, a client that needs a new sign-in is returned together
+// with an error matching pies.ErrNotAuthenticated.
+func Open(overrides config.Overrides) (pies.BrokerageClient, string, error) {
+	brokerageConfig, err := config.Load(overrides)
+	if err != nil {
+		return nil, "", err
+	}
+
+	client, err := brokerageConfig.NewClient()
+	return client, brokerageConfig.Brokerage, err
+}
+
+// OpenNotifier creates the notifier configured in the notifications section
+// of the config loaded by config.Load with overrides, nil when there is none
+func OpenNotifier(overrides config.Overrides) (pies.Notifier, error) {
+	brokerageConfig, err := config.Load(overrides)
+	if err != nil {
+		return nil, err
+	}
+	return notify.New(brokerageConfig.Notifications)
+}
+
+// signInHints tell the user how to sign in to brokerages whose credentials
+// can expire
+var signInHints = map[string]string{
+	"schwab": "run money-pies auth login first",
+}
+
+// NotAuthenticatedMessage tells the user that the brokerage registered as
+// name needs them to sign in again, and how
+func NotAuthenticatedMessage(name string) string {
+	hint, ok := signInHints[name]
+	if !ok {
+		hint = "check the credentials in its config"
+	}
+	return fmt.Sprintf("Not logged in to %s or the session has expired, %s", name, hint)
+}
@@ -0,0 +1,610 @@
+package paper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// defaultAccountID names the single paper account when Config leaves it empty
+	defaultAccountID = "paper"
+
+	// defaultStartingCash funds a new paper account when Config leaves it zero
+	defaultStartingCash = 100_000
+)
+
+var (
+	// ErrInsufficientCash is returned when a buy costs more than the cash
+	// not already committed to other open buys
+	ErrInsufficientCash = errors.New("insufficient cash")
+
+	// ErrInsufficientShares is returned when a sell exceeds the shares held
+	// and not already committed to other open sells
+	ErrInsufficientShares = errors.New("insufficient shares")
+
+	// ErrOrderNotFound is returned for order IDs the account never issued
+	ErrOrderNotFound = errors.New("order not found")
+)
+
+// Config holds paper account configuration
+type Config struct {
+	// AccountID identifies the account. Empty uses defaultAccountID.
+	AccountID string `json:"account_id"`
+
+	// StartingCash funds the account the first time it is created. Zero
+	// uses defaultStartingCash.
+	StartingCash decimal.Decimal `json:"starting_cash"`
+
+	// Kind is the kind of account simulated, e.g. an IRA to try
+	// RebalanceOptions.AllowTaxFreeSells. Empty simulates a cash account.
+	Kind brokerage.AccountKind `json:"kind"`
+
+	// StateFile persists cash, positions and orders between runs. Empty
+	// keeps the account in memory only.
+	StateFile string `json:"state_file"`
+
+	// MaxFillQuantity caps the shares filled each time an order is matched
+	// against a quote, so large orders fill in several partial executions.
+	// Zero fills orders completely.
+	MaxFillQuantity decimal.Decimal `json:"max_fill_quantity"`
+}
+
+// Client implements the brokerage.BrokerageClient interface against a
+// simulated in-memory account. Market orders fill at the quote source's
+// prices, limit orders fill once the quote crosses their limit, and open
+// orders are matched again whenever the account is read.
+type Client struct {
+	config Config
+	quotes brokerage.QuoteSource
+
+	mu    sync.Mutex
+	state state
+	now   func() time.Time
+}
+
+// NewClient creates a paper account pricing orders with quotes. When
+// Config.StateFile exists the account is restored from it, otherwise a new
+// account is funded with Config.StartingCash.
+func NewClient(config Config, quotes brokerage.QuoteSource) (*Client, error) {
+	if config.AccountID == "" {
+		config.AccountID = defaultAccountID
+	}
+	if config.Kind == "" {
+		config.Kind = brokerage.AccountKindCash
+	}
+	if config.StartingCash.IsZero() {
+		config.StartingCash = decimal.NewFromInt(defaultStartingCash)
+	}
+
+	c := &Client{
+		config: config,
+		quotes: quotes,
+		state:  newState(config.StartingCash),
+		now:    time.Now,
+	}
+
+	if config.StateFile != "" {
+		loaded, err := loadState(config.StateFile)
+		if err != nil {
+			return nil, err
+		}
+		if loaded != nil {
+			c.state = *loaded
+		}
+	}
+
+	return c, nil
+}
+
+// IsAuthenticated always reports true, a paper account needs no credentials
+func (c *Client) IsAuthenticated() bool {
+	return true
+}
+
+// GetAccounts returns the single paper account
+func (c *Client) GetAccounts(ctx context.Context) ([]brokerage.Account, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.matchOrders(ctx); err != nil {
+		return nil, err
+	}
+
+	positions, err := c.positions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var marketValue decimal.Decimal
+	for _, position := range positions {
+		marketValue = marketValue.Add(position.MarketValue)
+	}
+
+	return []brokerage.Account{
+		{
+			AccountID:     c.config.AccountID,
+			AccountNumber: c.config.AccountID,
+			Nickname:      "Paper",
+			Type:          "PAPER",
+			Kind:          c.config.Kind,
+			CashBalance:   c.state.Cash,
+			SettledCash:   c.state.Cash, // Paper trades settle immediately
+			BuyingPower:   c.state.Cash.Sub(c.reservedCash("")),
+			MarketValue:   marketValue,
+			TotalValue:    c.state.Cash.Add(marketValue),
+		},
+	}, nil
+}
+
+// GetPositions returns the account's holdings valued at current quotes
+func (c *Client) GetPositions(ctx context.Context, accountID string) ([]brokerage.Position, error) {
+	if err := c.checkAccount(accountID); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.matchOrders(ctx); err != nil {
+		return nil, err
+	}
+
+	return c.positions(ctx)
+}
+
+// SupportsFractionalShares reports that paper accounts trade fractional shares
+func (c *Client) SupportsFractionalShares() bool {
+	return true
+}
+
+// PlaceOrder accepts an order and immediately tries to fill it. Orders sized
+// by Amount are converted to a fractional quantity at the current quote.
+// Orders the account cannot cover are recorded as rejected and returned
+// along with an error wrapping ErrInsufficientCash or ErrInsufficientShares.
+func (c *Client) PlaceOrder(ctx context.Context, accountID string, order brokerage.OrderRequest) (*brokerage.Order, error) {
+	if err := c.checkAccount(accountID); err != nil {
+		return nil, err
+	}
+	order, err := brokerage.ResolveAmount(ctx, c.quotes, order, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateOrder(order); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	placed, err := c.placeOrder(ctx, order)
+	if saveErr := c.save(); saveErr != nil {
+		return nil, saveErr
+	}
+	if placed == nil {
+		return nil, err
+	}
+
+	result := *placed
+	return &result, err
+}
+
+// ReplaceOrder cancels a working order and places newOrder in its place
+func (c *Client) ReplaceOrder(ctx context.Context, accountID string, orderID string, newOrder brokerage.OrderRequest) (*brokerage.Order, error) {
+	if err := c.checkAccount(accountID); err != nil {
+		return nil, err
+	}
+	newOrder, err := brokerage.ResolveAmount(ctx, c.quotes, newOrder, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateOrder(newOrder); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.closeOrder(orderID, brokerage.OrderStatusReplaced); err != nil {
+		return nil, err
+	}
+
+	placed, err := c.placeOrder(ctx, newOrder)
+	if saveErr := c.save(); saveErr != nil {
+		return nil, saveErr
+	}
+	if placed == nil {
+		return nil, err
+	}
+
+	result := *placed
+	return &result, err
+}
+
+// GetOrderStatus matches open orders against current quotes and returns the
+// order orderID
+func (c *Client) GetOrderStatus(ctx context.Context, accountID string, orderID string) (*brokerage.Order, error) {
+	if err := c.checkAccount(accountID); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.matchOrders(ctx); err != nil {
+		return nil, err
+	}
+
+	order := c.findOrder(orderID)
+	if order == nil {
+		return nil, fmt.Errorf("%w: %s", ErrOrderNotFound, orderID)
+	}
+
+	result := *order
+	return &result, nil
+}
+
+// CancelPendingOrder cancels the unfilled remainder of a working order
+func (c *Client) CancelPendingOrder(ctx context.Context, accountID string, orderID string) error {
+	if err := c.checkAccount(accountID); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.closeOrder(orderID, brokerage.OrderStatusCancelled); err != nil {
+		return err
+	}
+
+	return c.save()
+}
+
+// GetRecentOrders returns the account's orders matching query, newest first
+func (c *Client) GetRecentOrders(ctx context.Context, accountID string, query brokerage.OrdersQuery) ([]brokerage.Order, error) {
+	if err := c.checkAccount(accountID); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.matchOrders(ctx); err != nil {
+		return nil, err
+	}
+
+	orders := []brokerage.Order{}
+	for _, order := range slices.Backward(c.state.Orders) {
+		if !query.From.IsZero() && order.SubmittedAt.Before(query.From) {
+			continue
+		}
+		if !query.To.IsZero() && order.SubmittedAt.After(query.To) {
+			continue
+		}
+		if query.Status != "" && order.Status != query.Status {
+			continue
+		}
+
+		orders = append(orders, *order)
+		if query.MaxResults > 0 && len(orders) == query.MaxResults {
+			break
+		}
+	}
+
+	return orders, nil
+}
+
+// GetQuote returns the quote source's quote for symbol
+func (c *Client) GetQuote(ctx context.Context, symbol string) (*brokerage.Quote, error) {
+	return c.quotes.GetQuote(ctx, symbol)
+}
+
+// GetQuotes returns quotes for symbols, reporting the symbols the quote
+// source could not price in a *brokerage.MissingQuotesError
+func (c *Client) GetQuotes(ctx context.Context, symbols []string) (map[string]brokerage.Quote, error) {
+	quotes := make(map[string]brokerage.Quote, len(symbols))
+	var missing []string
+	for _, symbol := range symbols {
+		quote, err := c.quotes.GetQuote(ctx, symbol)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			missing = append(missing, symbol)
+			continue
+		}
+		quotes[symbol] = *quote
+	}
+
+	if len(missing) > 0 {
+		return quotes, &brokerage.MissingQuotesError{Symbols: missing}
+	}
+
+	return quotes, nil
+}
+
+// checkAccount rejects account IDs other than the paper account's
+func (c *Client) checkAccount(accountID string) error {
+	if accountID != c.config.AccountID {
+		return fmt.Errorf("unknown paper account %q", accountID)
+	}
+	return nil
+}
+
+// validateOrder rejects the order types the simulation cannot fill. The
+// rest of the order has been checked by ResolveAmount.
+func validateOrder(order brokerage.OrderRequest) error {
+	switch order.Type {
+	case brokerage.OrderTypeMarket, brokerage.OrderTypeLimit:
+		return nil
+	default:
+		return fmt.Errorf("unsupported order type %q", order.Type)
+	}
+}
+
+// placeOrder records order and tries to fill it. The caller must hold mu.
+func (c *Client) placeOrder(ctx context.Context, request brokerage.OrderRequest) (*brokerage.Order, error) {
+	quote, err := c.quotes.GetQuote(ctx, request.Symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quote for %s: %w", request.Symbol, err)
+	}
+
+	c.state.NextOrderID++
+	order := &brokerage.Order{
+		ID:          fmt.Sprintf("%d", c.state.NextOrderID),
+		Symbol:      request.Symbol,
+		Action:      request.Action,
+		Type:        request.Type,
+		Quantity:    request.Quantity,
+		LimitPrice:  request.LimitPrice,
+		Status:      brokerage.OrderStatusWorking,
+		SubmittedAt: c.now(),
+	}
+
+	if rejection := c.checkFunds(order, quote); rejection != nil {
+		order.Status = brokerage.OrderStatusRejected
+		c.state.Orders = append(c.state.Orders, order)
+		return order, rejection
+	}
+
+	c.state.Orders = append(c.state.Orders, order)
+	c.fill(order, quote)
+	return order, nil
+}
+
+// checkFunds reports whether the account can cover order, counting what
+// other open orders have already committed. The caller must hold mu.
+func (c *Client) checkFunds(order *brokerage.Order, quote *brokerage.Quote) error {
+	switch order.Action {
+	case brokerage.OrderActionBuy:
+		price := buyPrice(quote)
+		if order.LimitPrice != nil {
+			price = *order.LimitPrice
+		}
+		cost := order.Quantity.Mul(price)
+		available := c.state.Cash.Sub(c.reservedCash(order.ID))
+		if cost.GreaterThan(available) {
+			return fmt.Errorf("%w: order for %s costs %s, %s available", ErrInsufficientCash, order.Symbol, cost.StringFixed(2), available.StringFixed(2))
+		}
+
+	case brokerage.OrderActionSell:
+		held := c.state.Positions[order.Symbol].Quantity
+		available := held.Sub(c.reservedShares(order.Symbol, order.ID))
+		if order.Quantity.GreaterThan(available) {
+			return fmt.Errorf("%w: order sells %v %s, %v available", ErrInsufficientShares, order.Quantity, order.Symbol, available)
+		}
+	}
+
+	return nil
+}
+
+// reservedCash is the cost of the unfilled part of every open buy other than
+// excludeID. The caller must hold mu.
+func (c *Client) reservedCash(excludeID string) decimal.Decimal {
+	var reserved decimal.Decimal
+	for _, order := range c.state.Orders {
+		if order.ID == excludeID || !order.Status.IsOpen() || order.Action != brokerage.OrderActionBuy {
+			continue
+		}
+		if order.LimitPrice != nil {
+			reserved = reserved.Add(order.Quantity.Sub(order.FilledQty).Mul(*order.LimitPrice))
+		}
+	}
+	return reserved
+}
+
+// reservedShares is the unfilled quantity of every open sell of symbol other
+// than excludeID. The caller must hold mu.
+func (c *Client) reservedShares(symbol string, excludeID string) decimal.Decimal {
+	var reserved decimal.Decimal
+	for _, order := range c.state.Orders {
+		if order.ID == excludeID || order.Symbol != symbol || !order.Status.IsOpen() || order.Action != brokerage.OrderActionSell {
+			continue
+		}
+		reserved = reserved.Add(order.Quantity.Sub(order.FilledQty))
+	}
+	return reserved
+}
+
+// matchOrders tries to fill every open order at current quotes. The caller
+// must hold mu.
+func (c *Client) matchOrders(ctx context.Context) error {
+	changed := false
+	for _, order := range c.state.Orders {
+		if !order.Status.IsOpen() {
+			continue
+		}
+
+		quote, err := c.quotes.GetQuote(ctx, order.Symbol)
+		if err != nil {
+			return fmt.Errorf("failed to get quote for %s: %w", order.Symbol, err)
+		}
+		if c.fill(order, quote) {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	return c.save()
+}
+
+// fill executes as much of order as the quote, MaxFillQuantity and the
+// account's cash allow, reporting whether anything was filled. The caller
+// must hold mu.
+func (c *Client) fill(order *brokerage.Order, quote *brokerage.Quote) bool {
+	var price decimal.Decimal
+	switch order.Action {
+	case brokerage.OrderActionBuy:
+		price = buyPrice(quote)
+		if order.LimitPrice != nil && price.GreaterThan(*order.LimitPrice) {
+			return false
+		}
+	case brokerage.OrderActionSell:
+		price = sellPrice(quote)
+		if order.LimitPrice != nil && price.LessThan(*order.LimitPrice) {
+			return false
+		}
+	}
+	if !price.IsPositive() {
+		return false
+	}
+
+	quantity := order.Quantity.Sub(order.FilledQty)
+	if c.config.MaxFillQuantity.IsPositive() {
+		quantity = decimal.Min(quantity, c.config.MaxFillQuantity)
+	}
+
+	position := c.state.Positions[order.Symbol]
+	switch order.Action {
+	case brokerage.OrderActionBuy:
+		// A market buy is only checked against the quote it was placed at,
+		// so a rising price can leave too little cash for all of it
+		quantity = decimal.Min(quantity, brokerage.RoundShares(c.state.Cash.Div(price), true))
+		if !quantity.IsPositive() {
+			return false
+		}
+		c.state.Cash = c.state.Cash.Sub(quantity.Mul(price))
+		position.CostBasis = position.CostBasis.Add(quantity.Mul(price))
+		position.Quantity = position.Quantity.Add(quantity)
+
+	case brokerage.OrderActionSell:
+		quantity = decimal.Min(quantity, position.Quantity)
+		if !quantity.IsPositive() {
+			return false
+		}
+		c.state.Cash = c.state.Cash.Add(quantity.Mul(price))
+		position.CostBasis = position.CostBasis.Sub(quantity.Mul(position.averagePrice()))
+		position.Quantity = position.Quantity.Sub(quantity)
+	}
+
+	if position.Quantity.IsPositive() {
+		c.state.Positions[order.Symbol] = position
+	} else {
+		delete(c.state.Positions, order.Symbol)
+	}
+
+	// FilledPrice is the average over all of the order's executions
+	filledValue := order.FilledQty.Mul(order.FilledPrice).Add(quantity.Mul(price))
+	order.FilledQty = order.FilledQty.Add(quantity)
+	order.FilledPrice = filledValue.Div(order.FilledQty)
+
+	now := c.now()
+	order.FilledAt = &now
+	if order.FilledQty.GreaterThanOrEqual(order.Quantity) {
+		order.Status = brokerage.OrderStatusFilled
+	} else {
+		order.Status = brokerage.OrderStatusPartiallyFilled
+	}
+
+	return true
+}
+
+// closeOrder moves the open order orderID to status, cancelling its unfilled
+// remainder. The caller must hold mu.
+func (c *Client) closeOrder(orderID string, status brokerage.OrderStatus) error {
+	order := c.findOrder(orderID)
+	if order == nil {
+		return fmt.Errorf("%w: %s", ErrOrderNotFound, orderID)
+	}
+	if !order.Status.IsOpen() {
+		return fmt.Errorf("order %s is already %s: %w", orderID, order.Status, brokerage.ErrOrderNotOpen)
+	}
+
+	order.Status = status
+	return nil
+}
+
+// findOrder returns the order orderID, or nil. The caller must hold mu.
+func (c *Client) findOrder(orderID string) *brokerage.Order {
+	for _, order := range c.state.Orders {
+		if order.ID == orderID {
+			return order
+		}
+	}
+	return nil
+}
+
+// positions values the account's holdings at current quotes. The caller must
+// hold mu.
+func (c *Client) positions(ctx context.Context) ([]brokerage.Position, error) {
+	positions := make([]brokerage.Position, 0, len(c.state.Positions))
+	for _, symbol := range slices.Sorted(maps.Keys(c.state.Positions)) {
+		held := c.state.Positions[symbol]
+		quote, err := c.quotes.GetQuote(ctx, symbol)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get quote for %s: %w", symbol, err)
+		}
+
+		position := brokerage.Position{
+			Symbol:       symbol,
+			Description:  quote.Description,
+			AssetType:    brokerage.AssetTypeEquity,
+			Quantity:     held.Quantity,
+			LongQuantity: held.Quantity,
+			AveragePrice: held.averagePrice(),
+			CurrentPrice: quote.Last,
+			MarketValue:  held.Quantity.Mul(quote.Last),
+			DayPL:        quote.DayChange(held.Quantity),
+		}
+		position.UnrealizedPL = position.MarketValue.Sub(held.CostBasis)
+		if !held.CostBasis.IsZero() {
+			position.UnrealizedPLPct = position.UnrealizedPL.Div(held.CostBasis).InexactFloat64() * 100
+		}
+		positions = append(positions, position)
+	}
+
+	return positions, nil
+}
+
+// save persists the account if Config.StateFile is set. The caller must hold mu.
+func (c *Client) save() error {
+	if c.config.StateFile == "" {
+		return nil
+	}
+	return saveState(c.config.StateFile, &c.state)
+}
+
+// buyPrice is the price a buy executes at: the ask, falling back to the last trade
+func buyPrice(quote *brokerage.Quote) decimal.Decimal {
+	if quote.Ask.IsPositive() {
+		return quote.Ask
+	}
+	return quote.Last
+}
+
+// sellPrice is the price a sell executes at: the bid, falling back to the last trade
+func sellPrice(quote *brokerage.Quote) decimal.Decimal {
+	if quote.Bid.IsPositive() {
+		return quote.Bid
+	}
+	return quote.Last
+}
@@ -0,0 +1,218 @@
+package paper_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/paper"
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/alysoliman1/money-pies/pkg/pies/piestest"
+	"github.com/shopspring/decimal"
+)
+
+// newClient returns a paper account holding cash, with VTI quoted at $100.
+// The quotes are returned to move prices.
+func newClient(t *testing.T, config paper.Config) (*paper.Client, *paper.FixedQuotes) {
+	t.Helper()
+
+	quotes := paper.NewFixedQuotes(map[string]decimal.Decimal{"VTI": decimal.NewFromInt(100)})
+	client, err := paper.NewClient(config, quotes)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return client, quotes
+}
+
+// order returns a day order for quantity shares of VTI, limited at limit
+// when it is not empty
+func order(action brokerage.OrderAction, quantity int64, limit string) brokerage.OrderRequest {
+	request := brokerage.OrderRequest{
+		Symbol:   "VTI",
+		Action:   action,
+		Type:     brokerage.OrderTypeMarket,
+		Quantity: decimal.NewFromInt(quantity),
+		Duration: brokerage.OrderDurationDay,
+	}
+	if limit != "" {
+		request.Type = brokerage.OrderTypeLimit
+		request.LimitPrice = brokerage.DecimalPtr(decimal.RequireFromString(limit))
+	}
+	return request
+}
+
+// cash returns the account's cash balance
+func cash(t *testing.T, client *paper.Client) decimal.Decimal {
+	t.Helper()
+
+	accounts, err := client.GetAccounts(t.Context())
+	if err != nil {
+		t.Fatalf("GetAccounts() error = %v", err)
+	}
+	return accounts[0].CashBalance
+}
+
+func TestPartialFills(t *testing.T) {
+	client, quotes := newClient(t, paper.Config{
+		StartingCash:    decimal.NewFromInt(10_000),
+		MaxFillQuantity: decimal.NewFromInt(4),
+	})
+
+	placed, err := client.PlaceOrder(t.Context(), "paper", order(brokerage.OrderActionBuy, 10, ""))
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	if placed.Status != brokerage.OrderStatusPartiallyFilled || !placed.FilledQty.Equal(decimal.NewFromInt(4)) {
+		t.Fatalf("placed order = %s with %s filled, want PARTIALLY_FILLED with 4", placed.Status, placed.FilledQty)
+	}
+
+	// The rest fills at a higher price over the next two matches
+	quotes.SetPrice("VTI", decimal.NewFromInt(110))
+	status, err := client.GetOrderStatus(t.Context(), "paper", placed.ID)
+	if err != nil {
+		t.Fatalf("GetOrderStatus() error = %v", err)
+	}
+	if status.Status != brokerage.OrderStatusPartiallyFilled || !status.FilledQty.Equal(decimal.NewFromInt(8)) {
+		t.Errorf("after one match = %s with %s filled, want PARTIALLY_FILLED with 8", status.Status, status.FilledQty)
+	}
+	status, err = client.GetOrderStatus(t.Context(), "paper", placed.ID)
+	if err != nil {
+		t.Fatalf("GetOrderStatus() error = %v", err)
+	}
+	if status.Status != brokerage.OrderStatusFilled || !status.FilledQty.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("after two matches = %s with %s filled, want FILLED with 10", status.Status, status.FilledQty)
+	}
+
+	// 4 at $100 and 6 at $110
+	if want := decimal.NewFromInt(106); !status.FilledPrice.Equal(want) {
+		t.Errorf("FilledPrice = %s, want the average %s", status.FilledPrice, want)
+	}
+	if got, want := cash(t, client), decimal.NewFromInt(10_000-1060); !got.Equal(want) {
+		t.Errorf("cash = %s, want %s", got, want)
+	}
+}
+
+func TestInsufficientCash(t *testing.T) {
+	client, _ := newClient(t, paper.Config{StartingCash: decimal.NewFromInt(1000)})
+
+	placed, err := client.PlaceOrder(t.Context(), "paper", order(brokerage.OrderActionBuy, 11, ""))
+	if !errors.Is(err, paper.ErrInsufficientCash) {
+		t.Fatalf("PlaceOrder() error = %v, want ErrInsufficientCash", err)
+	}
+	if placed == nil || placed.Status != brokerage.OrderStatusRejected {
+		t.Fatalf("placed order = %+v, want it recorded as rejected", placed)
+	}
+	if got := cash(t, client); !got.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("cash = %s after a rejected buy, want 1000", got)
+	}
+
+	// Exactly the cash available is affordable
+	if _, err := client.PlaceOrder(t.Context(), "paper", order(brokerage.OrderActionBuy, 10, "")); err != nil {
+		t.Errorf("PlaceOrder() for all the cash error = %v", err)
+	}
+}
+
+func TestOpenLimitBuysReserveCash(t *testing.T) {
+	client, _ := newClient(t, paper.Config{StartingCash: decimal.NewFromInt(1000)})
+
+	// Below the market, so it stays working and holds $900 back
+	working, err := client.PlaceOrder(t.Context(), "paper", order(brokerage.OrderActionBuy, 10, "90"))
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	if working.Status != brokerage.OrderStatusWorking {
+		t.Fatalf("limit order status = %s, want WORKING", working.Status)
+	}
+
+	if _, err := client.PlaceOrder(t.Context(), "paper", order(brokerage.OrderActionBuy, 2, "")); !errors.Is(err, paper.ErrInsufficientCash) {
+		t.Errorf("PlaceOrder() beyond the unreserved cash error = %v, want ErrInsufficientCash", err)
+	}
+	if _, err := client.PlaceOrder(t.Context(), "paper", order(brokerage.OrderActionBuy, 1, "")); err != nil {
+		t.Errorf("PlaceOrder() within the unreserved cash error = %v", err)
+	}
+}
+
+func TestInsufficientShares(t *testing.T) {
+	client, _ := newClient(t, paper.Config{StartingCash: decimal.NewFromInt(1000)})
+	if _, err := client.PlaceOrder(t.Context(), "paper", order(brokerage.OrderActionBuy, 5, "")); err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+
+	// An open sell commits shares, so a second sell only gets the rest
+	if _, err := client.PlaceOrder(t.Context(), "paper", order(brokerage.OrderActionSell, 3, "200")); err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	if _, err := client.PlaceOrder(t.Context(), "paper", order(brokerage.OrderActionSell, 3, "")); !errors.Is(err, paper.ErrInsufficientShares) {
+		t.Errorf("PlaceOrder() beyond the uncommitted shares error = %v, want ErrInsufficientShares", err)
+	}
+	if _, err := client.PlaceOrder(t.Context(), "paper", order(brokerage.OrderActionSell, 2, "")); err != nil {
+		t.Errorf("PlaceOrder() within the uncommitted shares error = %v", err)
+	}
+}
+
+func TestCancelPendingOrder(t *testing.T) {
+	client, _ := newClient(t, paper.Config{
+		StartingCash:    decimal.NewFromInt(10_000),
+		MaxFillQuantity: decimal.NewFromInt(4),
+	})
+
+	placed, err := client.PlaceOrder(t.Context(), "paper", order(brokerage.OrderActionBuy, 10, ""))
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	if err := client.CancelPendingOrder(t.Context(), "paper", placed.ID); err != nil {
+		t.Fatalf("CancelPendingOrder() error = %v", err)
+	}
+
+	// The filled part stays filled and the remainder never fills
+	status, err := client.GetOrderStatus(t.Context(), "paper", placed.ID)
+	if err != nil {
+		t.Fatalf("GetOrderStatus() error = %v", err)
+	}
+	if status.Status != brokerage.OrderStatusCancelled || !status.FilledQty.Equal(decimal.NewFromInt(4)) {
+		t.Errorf("cancelled order = %s with %s filled, want CANCELLED with 4", status.Status, status.FilledQty)
+	}
+	if got := cash(t, client); !got.Equal(decimal.NewFromInt(9600)) {
+		t.Errorf("cash = %s, want 9600", got)
+	}
+
+	if err := client.CancelPendingOrder(t.Context(), "paper", placed.ID); !errors.Is(err, brokerage.ErrOrderNotOpen) {
+		t.Errorf("second CancelPendingOrder() error = %v, want ErrOrderNotOpen", err)
+	}
+	if err := client.CancelPendingOrder(t.Context(), "paper", "missing"); !errors.Is(err, paper.ErrOrderNotFound) {
+		t.Errorf("CancelPendingOrder() of an unknown order error = %v, want ErrOrderNotFound", err)
+	}
+}
+
+func TestStateFilePersistsAccount(t *testing.T) {
+	config := paper.Config{
+		StartingCash: decimal.NewFromInt(1000),
+		StateFile:    filepath.Join(t.TempDir(), "paper.json"),
+	}
+	client, _ := newClient(t, config)
+	if _, err := client.PlaceOrder(t.Context(), "paper", order(brokerage.OrderActionBuy, 3, "")); err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+
+	// StartingCash only funds a new account
+	config.StartingCash = decimal.NewFromInt(5000)
+	reopened, _ := newClient(t, config)
+
+	if got := cash(t, reopened); !got.Equal(decimal.NewFromInt(700)) {
+		t.Errorf("cash after reopening = %s, want 700", got)
+	}
+	positions, err := reopened.GetPositions(t.Context(), "paper")
+	if err != nil {
+		t.Fatalf("GetPositions() error = %v", err)
+	}
+	if len(positions) != 1 || positions[0].Symbol != "VTI" || !positions[0].Quantity.Equal(decimal.NewFromInt(3)) {
+		t.Errorf("positions after reopening = %+v, want 3 VTI", positions)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	piestest.RunBrokerageConformanceTests(t, func() brokerage.BrokerageClient {
+		client, _ := newClient(t, paper.Config{})
+		return client
+	})
+}
@@ -0,0 +1,54 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+// FixedQuotes is a brokerage.QuoteSource serving prices set by hand, for
+// demos and for driving fills deterministically
+type FixedQuotes struct {
+	mu     sync.Mutex
+	prices map[string]decimal.Decimal
+}
+
+// NewFixedQuotes creates a quote source serving prices, keyed by symbol
+func NewFixedQuotes(prices map[string]decimal.Decimal) *FixedQuotes {
+	q := &FixedQuotes{prices: make(map[string]decimal.Decimal, len(prices))}
+	for symbol, price := range prices {
+		q.prices[symbol] = price
+	}
+	return q
+}
+
+// SetPrice changes the price quoted for symbol
+func (q *FixedQuotes) SetPrice(symbol string, price decimal.Decimal) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.prices[symbol] = price
+}
+
+func (q *FixedQuotes) GetQuote(ctx context.Context, symbol string) (*brokerage.Quote, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	price, ok := q.prices[symbol]
+	if !ok {
+		return nil, fmt.Errorf("no price set for %s", symbol)
+	}
+
+	return &brokerage.Quote{
+		Symbol:    symbol,
+		Bid:       price,
+		Ask:       price,
+		Last:      price,
+		Close:     price,
+		Timestamp: time.Now(),
+	}, nil
+}
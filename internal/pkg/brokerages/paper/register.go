@@ -0,0 +1,59 @@
+package paper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+func init() {
+	brokerage.RegisterBrokerage("paper", newBrokerage)
+}
+
+// registryConfig is the configuration of a paper account created through
+// the brokerage registry: a Config and where its quotes come from, either
+// fixed prices or another registered brokerage
+type registryConfig struct {
+	Config
+
+	// Prices are fixed quotes keyed by symbol
+	Prices map[string]decimal.Decimal `json:"prices"`
+
+	// Quotes prices orders with another brokerage's quotes, e.g.
+	// {"brokerage": "schwab", "config": {...}}. Used when Prices is empty.
+	Quotes *brokerage.BrokerageConfig `json:"quotes"`
+}
+
+// newBrokerage creates a paper account from a registryConfig in JSON. When
+// the quoting brokerage needs a new sign-in, the account is returned
+// together with its error.
+func newBrokerage(raw json.RawMessage) (brokerage.BrokerageClient, error) {
+	var config registryConfig
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse paper config: %w", err)
+	}
+
+	var quotes brokerage.QuoteSource
+	var quotesErr error
+	switch {
+	case len(config.Prices) > 0:
+		quotes = NewFixedQuotes(config.Prices)
+	case config.Quotes != nil:
+		quoteClient, err := config.Quotes.NewClient()
+		if quoteClient == nil {
+			return nil, fmt.Errorf("failed to create paper quote source: %w", err)
+		}
+		quotes, quotesErr = quoteClient, err
+	default:
+		return nil, errors.New("paper config needs prices or a quotes brokerage")
+	}
+
+	client, err := NewClient(config.Config, quotes)
+	if err != nil {
+		return nil, err
+	}
+	return client, quotesErr
+}
@@ -0,0 +1,138 @@
+package paper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+// state is everything persisted about a paper account
+type state struct {
+	Cash        decimal.Decimal    `json:"cash"`
+	Positions   map[string]holding `json:"positions"`
+	Orders      []*brokerage.Order `json:"orders"`
+	NextOrderID int                `json:"next_order_id"`
+}
+
+// holding is the quantity held of a symbol and what was paid for it
+type holding struct {
+	Quantity  decimal.Decimal `json:"quantity"`
+	CostBasis decimal.Decimal `json:"cost_basis"`
+}
+
+// averagePrice is the average price paid per share held
+func (h holding) averagePrice() decimal.Decimal {
+	if h.Quantity.IsZero() {
+		return decimal.Zero
+	}
+	return h.CostBasis.Div(h.Quantity)
+}
+
+// newState returns a fresh account funded with cash
+func newState(cash decimal.Decimal) state {
+	return state{
+		Cash:      cash,
+		Positions: make(map[string]holding),
+	}
+}
+
+// loadState reads the account saved at path, returning nil if there is none
+func loadState(path string) (*state, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read paper account state: %w", err)
+	}
+
+	var loaded state
+	if err := json.Unmarshal(raw, &loaded); err != nil {
+		return nil, fmt.Errorf("failed to parse paper account state %s: %w", path, err)
+	}
+	if err := loadLegacyOrders(raw, &loaded); err != nil {
+		return nil, fmt.Errorf("failed to parse paper account state %s: %w", path, err)
+	}
+	if loaded.Positions == nil {
+		loaded.Positions = make(map[string]holding)
+	}
+
+	return &loaded, nil
+}
+
+// legacyOrderKeys maps the keys orders were saved under before Order had
+// JSON tags to the current ones. Single-word keys still match as JSON keys
+// are matched ignoring case.
+var legacyOrderKeys = map[string]string{
+	"LimitPrice":  "limit_price",
+	"StopPrice":   "stop_price",
+	"RawStatus":   "raw_status",
+	"FilledQty":   "filled_qty",
+	"FilledPrice": "filled_price",
+	"SubmittedAt": "submitted_at",
+	"FilledAt":    "filled_at",
+}
+
+// loadLegacyOrders decodes the orders of raw again when they were saved under
+// the legacy keys. The state is saved in the current format by the next
+// order.
+func loadLegacyOrders(raw []byte, loaded *state) error {
+	var saved struct {
+		Orders []map[string]json.RawMessage `json:"orders"`
+	}
+	if err := json.Unmarshal(raw, &saved); err != nil {
+		return err
+	}
+
+	for i, order := range saved.Orders {
+		if _, legacy := order["SubmittedAt"]; !legacy {
+			continue
+		}
+		for old, current := range legacyOrderKeys {
+			if value, ok := order[old]; ok {
+				delete(order, old)
+				order[current] = value
+			}
+		}
+		rawOrder, err := json.Marshal(order)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(rawOrder, loaded.Orders[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveState atomically replaces the account saved at path
+func saveState(path string, s *state) error {
+	raw, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal paper account state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to save paper account state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save paper account state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save paper account state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save paper account state: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package schwab
+
+import (
+	"strings"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// accountKinds maps the account types Schwab reports onto
+// brokerage.AccountKind. The accounts endpoint only says CASH or MARGIN,
+// IRAs included; the type in the account's user preferences names the
+// registration, so IRA types found there take precedence.
+var accountKinds = map[string]brokerage.AccountKind{
+	"CASH":            brokerage.AccountKindCash,
+	"MARGIN":          brokerage.AccountKindMargin,
+	"BROKERAGE":       brokerage.AccountKindTaxable,
+	"INDIVIDUAL":      brokerage.AccountKindTaxable,
+	"JOINT":           brokerage.AccountKindTaxable,
+	"IRA":             brokerage.AccountKindTraditionalIRA,
+	"TRADITIONAL_IRA": brokerage.AccountKindTraditionalIRA,
+	"ROLLOVER_IRA":    brokerage.AccountKindTraditionalIRA,
+	"SEP_IRA":         brokerage.AccountKindTraditionalIRA,
+	"SIMPLE_IRA":      brokerage.AccountKindTraditionalIRA,
+	"ROTH_IRA":        brokerage.AccountKindRothIRA,
+	"ROTH":            brokerage.AccountKindRothIRA,
+}
+
+// accountKind classifies an account from its type in the accounts response
+// and the type in its user preferences, which may be empty
+func accountKind(accountType, preferenceType string) brokerage.AccountKind {
+	preferenceKind := lookupAccountKind(preferenceType)
+	if preferenceKind.TaxAdvantaged() {
+		return preferenceKind
+	}
+	if kind := lookupAccountKind(accountType); kind != brokerage.AccountKindUnknown {
+		return kind
+	}
+	return preferenceKind
+}
+
+// lookupAccountKind maps a single Schwab account type, tolerating case and
+// spaces or dashes in place of underscores
+func lookupAccountKind(schwabType string) brokerage.AccountKind {
+	normalized := strings.ToUpper(strings.TrimSpace(schwabType))
+	normalized = strings.NewReplacer(" ", "_", "-", "_").Replace(normalized)
+	if kind, ok := accountKinds[normalized]; ok {
+		return kind
+	}
+	return brokerage.AccountKindUnknown
+}
@@ -0,0 +1,77 @@
+package schwab_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab/schwabtest"
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+func TestAccountKinds(t *testing.T) {
+	tests := []struct {
+		accountType    string
+		preferenceType string
+		want           brokerage.AccountKind
+	}{
+		{accountType: "CASH", want: brokerage.AccountKindCash},
+		{accountType: "MARGIN", want: brokerage.AccountKindMargin},
+		{accountType: "margin", want: brokerage.AccountKindMargin},
+		{accountType: "CASH", preferenceType: "BROKERAGE", want: brokerage.AccountKindCash},
+		{accountType: "MARGIN", preferenceType: "JOINT", want: brokerage.AccountKindMargin},
+		{accountType: "CASH", preferenceType: "IRA", want: brokerage.AccountKindTraditionalIRA},
+		{accountType: "CASH", preferenceType: "ROLLOVER_IRA", want: brokerage.AccountKindTraditionalIRA},
+		{accountType: "CASH", preferenceType: "Rollover IRA", want: brokerage.AccountKindTraditionalIRA},
+		{accountType: "CASH", preferenceType: "sep-ira", want: brokerage.AccountKindTraditionalIRA},
+		{accountType: "CASH", preferenceType: "ROTH_IRA", want: brokerage.AccountKindRothIRA},
+		{accountType: "MARGIN", preferenceType: "ROTH", want: brokerage.AccountKindRothIRA},
+		{accountType: "", preferenceType: "INDIVIDUAL", want: brokerage.AccountKindTaxable},
+		{accountType: "FUTURES", preferenceType: "BROKERAGE", want: brokerage.AccountKindTaxable},
+		{accountType: "FUTURES", want: brokerage.AccountKindUnknown},
+		{want: brokerage.AccountKindUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%q with preference %q", tt.accountType, tt.preferenceType), func(t *testing.T) {
+			mock := schwabtest.NewServer()
+			defer mock.Close()
+			mock.SetResponse(http.MethodGet, "/trader/v1/accounts", http.StatusOK, fmt.Sprintf(
+				`[{"securitiesAccount": {"type": %q, "accountNumber": %q, "currentBalances": {}}}]`,
+				tt.accountType, schwabtest.AccountNumber))
+			mock.SetResponse(http.MethodGet, "/trader/v1/userPreference", http.StatusOK, fmt.Sprintf(
+				`{"accounts": [{"accountNumber": %q, "type": %q}]}`,
+				schwabtest.AccountNumber, tt.preferenceType))
+			client := mock.NewClient()
+
+			accounts, err := client.GetAccounts(t.Context())
+			if err != nil {
+				t.Fatalf("GetAccounts() error = %v", err)
+			}
+			if len(accounts) != 1 {
+				t.Fatalf("GetAccounts() = %+v, want one account", accounts)
+			}
+			if got := accounts[0].Kind; got != tt.want {
+				t.Errorf("Kind = %q, want %q", got, tt.want)
+			}
+			if got := accounts[0].Type; got != tt.accountType {
+				t.Errorf("Type = %q, want the raw %q", got, tt.accountType)
+			}
+		})
+	}
+}
+
+func TestAccountKindWithoutPreferences(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	mock.SetResponse(http.MethodGet, "/trader/v1/userPreference", http.StatusInternalServerError, `{"message": "unavailable"}`)
+	client := mock.NewClient()
+
+	accounts, err := client.GetAccounts(t.Context())
+	if err != nil {
+		t.Fatalf("GetAccounts() error = %v", err)
+	}
+	if len(accounts) != 1 || accounts[0].Kind != brokerage.AccountKindMargin {
+		t.Errorf("GetAccounts() = %+v, want one margin account", accounts)
+	}
+}
@@ -0,0 +1,100 @@
+package schwab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/oauth"
+)
+
+const (
+	// Defaults for the local OAuth redirect listener used by Authenticate
+	defaultCallbackCertFile = "local-cert/cert.pem"
+	defaultCallbackKeyFile  = "local-cert/key.pem"
+
+	// defaultAuthTimeout is how long Authenticate waits for the redirect
+	defaultAuthTimeout = 5 * time.Minute
+)
+
+// Authenticate runs the complete OAuth authorization code flow: it starts an
+// HTTPS listener for the redirect on the host, port and path of
+// Config.RedirectURI, or on Config.CallbackAddr when set, sends the user to
+// the authorization URL, waits for the callback, exchanges the code for a
+// token and saves it to the token store. The listener is shut down before
+// Authenticate returns.
+func (c *Client) Authenticate(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.authTimeout)
+	defer cancel()
+
+	redirect, err := oauth.ParseRedirectURI(c.config.RedirectURI)
+	if err != nil {
+		return err
+	}
+	for _, warning := range redirect.Warnings() {
+		c.logger.WarnContext(ctx, warning, slog.String("redirect_uri", c.config.RedirectURI))
+	}
+	addr := redirect.Addr
+	if c.config.CallbackAddr != "" {
+		addr = c.config.CallbackAddr
+	}
+
+	authURL, state := c.GetAuthURLWithState()
+	listener, err := oauth.Listen(addr, redirect.Path, c.config.CallbackCertFile, c.config.CallbackKeyFile, state)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	if err := c.openURL(authURL); err != nil {
+		fmt.Println("Please visit the following URL to authorize the application:")
+		fmt.Println(authURL)
+	}
+
+	code, err := listener.Wait(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := c.ExchangeAuthCodeForAccessToken(ctx, code); err != nil {
+		return fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	return nil
+}
+
+// AuthenticateManual runs the OAuth authorization code flow without a local
+// listener, for machines that cannot receive the redirect. prompt is given
+// the authorization URL and returns what the user pasted after visiting it:
+// the URL the browser was redirected to or the bare code. A paste holding no
+// code calls prompt again with the reason, which is nil on the first call.
+// The code is then exchanged for a token saved to the token store.
+func (c *Client) AuthenticateManual(ctx context.Context, prompt func(authURL string, previous error) (string, error)) error {
+	authURL, state := c.GetAuthURLWithState()
+
+	var previous error
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("authorization cancelled: %w", err)
+		}
+		pasted, err := prompt(authURL, previous)
+		if err != nil {
+			return err
+		}
+		code, err := oauth.ParsePastedCode(pasted, state)
+		if errors.Is(err, oauth.ErrMalformedPaste) {
+			previous = err
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := c.ExchangeAuthCodeForAccessToken(ctx, code); err != nil {
+			return fmt.Errorf("failed to get access token: %w", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,206 @@
+package schwab_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab/schwabtest"
+)
+
+// callbackCert writes a self-signed certificate for 127.0.0.1 to dir and
+// returns the certificate and key files
+func callbackCert(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// freeAddr returns a loopback address with a port nothing is listening on
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// callbackClient trusts any certificate, like a browser told to accept the
+// listener's self-signed one
+var callbackClient = &http.Client{
+	Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	Timeout:   5 * time.Second,
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name string
+		// redirect returns the query the browser is redirected with, given
+		// the state of the authorization URL
+		redirect func(state string) url.Values
+		wantErr  string // Empty when authentication succeeds
+	}{
+		{
+			name:     "authorized",
+			redirect: func(state string) url.Values { return url.Values{"code": {schwabtest.AuthCode}, "state": {state}} },
+		},
+		{
+			name:     "denied",
+			redirect: func(state string) url.Values { return url.Values{"error": {"access_denied"}, "state": {state}} },
+			wantErr:  "authorization denied: access_denied",
+		},
+		{
+			name:     "state mismatch",
+			redirect: func(string) url.Values { return url.Values{"code": {schwabtest.AuthCode}, "state": {"forged"}} },
+			wantErr:  "authorization callback state mismatch, refusing to exchange code",
+		},
+		{
+			name:     "unknown code",
+			redirect: func(state string) url.Values { return url.Values{"code": {"stale-code"}, "state": {state}} },
+			wantErr:  "failed to get access token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := schwabtest.NewServer()
+			defer mock.Close()
+
+			addr := freeAddr(t)
+			config := mock.Config()
+			config.RedirectURI = "https://" + addr + "/callback"
+			config.CallbackCertFile, config.CallbackKeyFile = callbackCert(t, t.TempDir())
+
+			// Follow the authorization URL straight to the redirect, as the
+			// browser would once the user signs in
+			redirected := make(chan error, 1)
+			open := func(authURL string) error {
+				parsed, err := url.Parse(authURL)
+				if err != nil {
+					return err
+				}
+				callbackURL := config.RedirectURI + "?" + tt.redirect(parsed.Query().Get("state")).Encode()
+				go func() {
+					resp, err := callbackClient.Get(callbackURL)
+					if err == nil {
+						io.Copy(io.Discard, resp.Body)
+						resp.Body.Close()
+					}
+					redirected <- err
+				}()
+				return nil
+			}
+
+			store := &schwab.MemoryTokenStore{}
+			client, err := schwab.NewClient(config, schwab.WithTokenStore(store), schwab.WithURLOpener(open),
+				schwab.WithAuthTimeout(5*time.Second))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = client.Authenticate(t.Context())
+			if err := <-redirected; err != nil {
+				t.Fatalf("redirect to the callback listener failed: %v", err)
+			}
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Authenticate() error = %v, want %q", err, tt.wantErr)
+				}
+				if _, loadErr := store.Load(); !errors.Is(loadErr, schwab.ErrNoToken) {
+					t.Errorf("token store Load() error = %v, want nothing saved", loadErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Authenticate() error = %v", err)
+				}
+				token, err := store.Load()
+				if err != nil || token.AccessToken != schwabtest.AccessToken || token.RefreshToken != schwabtest.RefreshToken {
+					t.Errorf("saved token = %+v, %v, want the mock's token", token, err)
+				}
+				form, _ := tokenRequestForm(t, mock)
+				if form.Get("grant_type") != "authorization_code" || form.Get("code") != schwabtest.AuthCode ||
+					form.Get("redirect_uri") != config.RedirectURI {
+					t.Errorf("token request form = %v, want the code exchanged for the redirect URI", form)
+				}
+			}
+
+			// The listener is gone once Authenticate returns
+			if conn, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+				conn.Close()
+				t.Errorf("callback listener on %s is still accepting connections", addr)
+			}
+		})
+	}
+}
+
+func TestAuthenticateTimesOut(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+
+	config := mock.Config()
+	config.RedirectURI = "https://" + freeAddr(t) + "/callback"
+	config.CallbackCertFile, config.CallbackKeyFile = callbackCert(t, t.TempDir())
+	client, err := schwab.NewClient(config, schwab.WithTokenStore(&schwab.MemoryTokenStore{}),
+		schwab.WithURLOpener(func(string) error { return nil }), schwab.WithAuthTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Authenticate(t.Context())
+	if want := "authorization timed out waiting for the oauth callback"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Authenticate() error = %v, want %q", err, want)
+	}
+	if n := countRequests(mock, "POST", "/v1/oauth/token"); n != 0 {
+		t.Errorf("token requests = %d, want none without a callback", n)
+	}
+}
@@ -0,0 +1,184 @@
+package schwab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+const (
+	// autoRefreshJitter spreads background refreshes over this long before
+	// tokenRefreshMargin, so processes sharing a token store don't refresh
+	// in lockstep
+	autoRefreshJitter = 2 * time.Minute
+
+	// autoRefreshIdle is how often the background refresh looks again when
+	// the client has no token it can refresh
+	autoRefreshIdle = time.Minute
+
+	// autoRefreshRetryBase is the backoff after the first failed background
+	// refresh, doubling on every subsequent one up to autoRefreshRetryMax
+	autoRefreshRetryBase = 10 * time.Second
+	autoRefreshRetryMax  = 5 * time.Minute
+
+	// autoRefreshFailuresBeforeNotify is how many background refreshes in a
+	// row fail before the notifier is told
+	autoRefreshFailuresBeforeNotify = 3
+
+	// notifyTimeout bounds how long delivering a notification may take
+	notifyTimeout = 30 * time.Second
+)
+
+// SetNotifier makes the client tell notifier, with a token_expiring event,
+// when StartAutoRefresh cannot keep the token fresh
+func (c *Client) SetNotifier(notifier brokerage.Notifier) {
+	c.tokenCallbacksMu.Lock()
+	defer c.tokenCallbacksMu.Unlock()
+
+	c.notifier = notifier
+}
+
+// StartAutoRefresh refreshes the access token in the background a few
+// minutes before it expires, so requests made after a quiet spell don't pay
+// for the refresh or fail on it mid-rebalance. Refreshed tokens are saved to
+// the token store and passed to the OnTokenRefreshed callbacks, as they are
+// when requests refresh them.
+//
+// Failed refreshes are retried with a growing backoff. After
+// autoRefreshFailuresBeforeNotify of them in a row, or as soon as the
+// refresh token has expired, the SetNotifier notifier is told once, until a
+// refresh succeeds again. The returned channel is closed once the refreshing
+// has stopped after ctx is cancelled.
+func (c *Client) StartAutoRefresh(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.autoRefresh(ctx)
+	}()
+	return done
+}
+
+// autoRefresh is the loop run by StartAutoRefresh
+func (c *Client) autoRefresh(ctx context.Context) {
+	failures := 0
+	notified := false
+	for {
+		wait, accessToken := c.nextAutoRefresh()
+		if failures > 0 {
+			wait = autoRefreshBackoff(failures)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.after(wait):
+		}
+
+		err := c.autoRefreshToken(ctx, accessToken)
+		switch {
+		case err == nil:
+			failures, notified = 0, false
+		case ctx.Err() != nil:
+			return
+		case errors.Is(err, ErrRefreshTokenExpired):
+			// Retrying can't help, only signing in again does
+			failures = 0
+			if !notified {
+				c.notifyTokenExpiring(ctx, "The Schwab refresh token has expired, sign in again to keep trading")
+				notified = true
+			}
+		default:
+			failures++
+			if failures >= autoRefreshFailuresBeforeNotify && !notified {
+				c.notifyTokenExpiring(ctx, fmt.Sprintf("Refreshing the Schwab access token has failed %d times in a row, the sign-in may need renewing: %v",
+					failures, err))
+				notified = true
+			}
+		}
+	}
+}
+
+// nextAutoRefresh returns how long to wait before refreshing the current
+// token, and the access token it is due for. It waits autoRefreshIdle when
+// the client has no token it can refresh.
+func (c *Client) nextAutoRefresh() (time.Duration, string) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.token == nil {
+		return autoRefreshIdle, ""
+	}
+	if !c.refreshTokenValidLocked() {
+		return autoRefreshIdle, c.token.AccessToken
+	}
+
+	refreshAt := c.token.ExpiresAt.Add(-tokenRefreshMargin - rand.N(autoRefreshJitter))
+	return max(refreshAt.Sub(c.clock.Now()), 0), c.token.AccessToken
+}
+
+// autoRefreshToken refreshes the token if it still holds accessToken. A
+// token replaced since, by a request or a new sign-in, is left alone.
+func (c *Client) autoRefreshToken(ctx context.Context, accessToken string) error {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.token == nil || c.token.AccessToken != accessToken {
+		return nil
+	}
+	if !c.refreshTokenValidLocked() {
+		return ErrRefreshTokenExpired
+	}
+	return c.refreshToken(ctx)
+}
+
+// autoRefreshBackoff returns the wait before retrying after failures
+// background refreshes in a row, with up to half of it replaced by jitter
+func autoRefreshBackoff(failures int) time.Duration {
+	delay := autoRefreshRetryMax
+	if shift := failures - 1; shift < 16 {
+		delay = min(autoRefreshRetryBase<<shift, autoRefreshRetryMax)
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// notifyTokenExpiring logs message and sends it to the SetNotifier notifier
+// as a token_expiring event
+func (c *Client) notifyTokenExpiring(ctx context.Context, message string) {
+	c.logger.WarnContext(ctx, message)
+
+	c.tokenCallbacksMu.Lock()
+	notifier := c.notifier
+	c.tokenCallbacksMu.Unlock()
+	if notifier == nil {
+		return
+	}
+
+	event := brokerage.Event{
+		Kind:    brokerage.EventTokenExpiring,
+		Time:    c.clock.Now(),
+		Message: message,
+	}
+	if expiresAt := c.RefreshTokenExpiresAt(); !expiresAt.IsZero() {
+		event.ExpiresAt = &expiresAt
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notifyTimeout)
+	defer cancel()
+	if err := notifier.Notify(ctx, event); err != nil {
+		c.logger.ErrorContext(ctx, "failed to send notification",
+			slog.String("kind", string(event.Kind)), slog.String("error", err.Error()))
+	}
+}
+
+// after returns a channel receiving the time once d has passed on the
+// client's clock, or on the system clock when it is not a TimerClock
+func (c *Client) after(d time.Duration) <-chan time.Time {
+	if clock, ok := c.clock.(TimerClock); ok {
+		return clock.After(d)
+	}
+	return time.After(d)
+}
@@ -0,0 +1,231 @@
+package schwab_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab/schwabtest"
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/alysoliman1/money-pies/pkg/pies/piestest"
+)
+
+var clockStart = time.Date(2025, time.March, 3, 14, 0, 0, 0, time.UTC)
+
+// autoRefreshClient returns a client for mock on clock holding token, and
+// starts its background refresh. The refresh is stopped, and waited for,
+// when the test ends.
+func autoRefreshClient(t *testing.T, mock *schwabtest.Server, clock *schwabtest.Clock, token schwab.Token) (*schwab.Client, *piestest.Notifier) {
+	t.Helper()
+
+	client := mock.NewClient(schwab.WithClock(clock))
+	if err := client.SetAccessToken(token); err != nil {
+		t.Fatal(err)
+	}
+	notifier := &piestest.Notifier{}
+	client.SetNotifier(notifier)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := client.StartAutoRefresh(ctx)
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return client, notifier
+}
+
+// freshToken returns a token issued at clockStart
+func freshToken(refreshToken string) schwab.Token {
+	return schwab.Token{
+		AccessToken:           "first-access-token",
+		RefreshToken:          refreshToken,
+		TokenType:             "Bearer",
+		ExpiresIn:             1800,
+		ExpiresAt:             clockStart.Add(30 * time.Minute),
+		RefreshTokenExpiresAt: clockStart.Add(7 * 24 * time.Hour),
+	}
+}
+
+// eventually fails the test unless cond becomes true within a second
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// advance waits for the background refresh to wait on clock, then moves
+// clock forward by d
+func advance(t *testing.T, clock *schwabtest.Clock, d time.Duration) {
+	t.Helper()
+
+	eventually(t, "the refresh to wait", func() bool { return clock.Waiters() > 0 })
+	clock.Advance(d)
+}
+
+// refreshes counts the refresh requests the mock received
+func refreshes(mock *schwabtest.Server) int {
+	return countRequests(mock, "POST", "/v1/oauth/token")
+}
+
+func TestAutoRefreshBeforeExpiry(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	clock := schwabtest.NewClock(clockStart)
+	store := &schwab.MemoryTokenStore{}
+
+	client := mock.NewClient(schwab.WithClock(clock), schwab.WithTokenStore(store))
+	if err := client.SetAccessToken(freshToken(schwabtest.RefreshToken)); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var refreshed []schwab.Token
+	client.OnTokenRefreshed(func(token schwab.Token) {
+		mu.Lock()
+		defer mu.Unlock()
+		refreshed = append(refreshed, token)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := client.StartAutoRefresh(ctx)
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// The refresh is due 5 minutes before expiry, less up to 2 minutes of
+	// jitter, so nothing happens in the first 23 minutes
+	advance(t, clock, 23*time.Minute-time.Second)
+	time.Sleep(10 * time.Millisecond)
+	if n := refreshes(mock); n != 0 {
+		t.Fatalf("refreshed %d times 23 minutes into a 30 minute token, want 0", n)
+	}
+
+	clock.Advance(2*time.Minute + time.Second)
+	eventually(t, "the refresh", func() bool { return refreshes(mock) == 1 })
+	eventually(t, "the refresh callback", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(refreshed) == 1
+	})
+
+	saved, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if saved.AccessToken != schwabtest.AccessToken {
+		t.Errorf("saved access token = %q, want the refreshed %q", saved.AccessToken, schwabtest.AccessToken)
+	}
+	if want := clock.Now().Add(30 * time.Minute); !saved.ExpiresAt.Equal(want) {
+		t.Errorf("saved ExpiresAt = %s, want %s", saved.ExpiresAt, want)
+	}
+	if !client.IsAuthenticated() {
+		t.Error("IsAuthenticated() = false after the refresh, want true")
+	}
+
+	// The refreshed token is refreshed again before it expires
+	advance(t, clock, 25*time.Minute)
+	eventually(t, "the second refresh", func() bool { return refreshes(mock) == 2 })
+}
+
+func TestAutoRefreshStopsWhenCancelled(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	clock := schwabtest.NewClock(clockStart)
+
+	client := mock.NewClient(schwab.WithClock(clock))
+	if err := client.SetAccessToken(freshToken(schwabtest.RefreshToken)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := client.StartAutoRefresh(ctx)
+	eventually(t, "the refresh to wait", func() bool { return clock.Waiters() > 0 })
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("StartAutoRefresh() did not stop after its context was cancelled")
+	}
+
+	clock.Advance(time.Hour)
+	time.Sleep(10 * time.Millisecond)
+	if n := refreshes(mock); n != 0 {
+		t.Errorf("refreshed %d times after stopping, want 0", n)
+	}
+}
+
+func TestAutoRefreshNotifiesAfterRepeatedFailures(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	mock.SetResponse("POST", "/v1/oauth/token", http.StatusBadRequest,
+		`{"error": "invalid_request", "error_description": "try again later"}`)
+	clock := schwabtest.NewClock(clockStart)
+	_, notifier := autoRefreshClient(t, mock, clock, freshToken(schwabtest.RefreshToken))
+
+	advance(t, clock, 25*time.Minute)
+	eventually(t, "the first refresh", func() bool { return refreshes(mock) == 1 })
+
+	// Retries back off for at least 5 and 10 seconds, up to 10 and 20
+	advance(t, clock, 4*time.Second)
+	time.Sleep(10 * time.Millisecond)
+	if n := refreshes(mock); n != 1 {
+		t.Fatalf("retried after 4 seconds, %d refreshes, want 1", n)
+	}
+	clock.Advance(6 * time.Second)
+	eventually(t, "the first retry", func() bool { return refreshes(mock) == 2 })
+	if kinds := notifier.Kinds(); len(kinds) != 0 {
+		t.Fatalf("notified %v after 2 failures, want nothing", kinds)
+	}
+
+	advance(t, clock, 20*time.Second)
+	eventually(t, "the notification", func() bool { return len(notifier.Events()) == 1 })
+	event := notifier.Events()[0]
+	if event.Kind != brokerage.EventTokenExpiring || event.ExpiresAt == nil {
+		t.Errorf("event = %+v, want a token_expiring event with the refresh token's expiry", event)
+	}
+
+	// Further failures are not notified again
+	for range 3 {
+		advance(t, clock, 5*time.Minute)
+	}
+	eventually(t, "more retries", func() bool { return refreshes(mock) == 6 })
+	if n := len(notifier.Events()); n != 1 {
+		t.Errorf("notified %d times, want once until a refresh succeeds", n)
+	}
+}
+
+func TestAutoRefreshNotifiesExpiredRefreshToken(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	clock := schwabtest.NewClock(clockStart)
+	token := freshToken(schwabtest.RefreshToken)
+	token.RefreshTokenExpiresAt = clockStart.Add(-time.Minute)
+	_, notifier := autoRefreshClient(t, mock, clock, token)
+
+	advance(t, clock, time.Minute)
+	eventually(t, "the notification", func() bool { return len(notifier.Events()) == 1 })
+	if kind := notifier.Events()[0].Kind; kind != brokerage.EventTokenExpiring {
+		t.Errorf("event kind = %s, want %s", kind, brokerage.EventTokenExpiring)
+	}
+
+	for range 3 {
+		advance(t, clock, time.Minute)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n := len(notifier.Events()); n != 1 {
+		t.Errorf("notified %d times, want once", n)
+	}
+	if n := refreshes(mock); n != 0 {
+		t.Errorf("sent %d refresh requests with an expired refresh token, want 0", n)
+	}
+}
@@ -1,18 +1,26 @@
 package schwab
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"maps"
 	"net/http"
 	"net/url"
-	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	brokerage "github.com/asoliman1/money-pies/internal/pkg/pies"
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/pkg/browser"
+	"github.com/shopspring/decimal"
 )
 
 // Schwab API Documentation Links:
@@ -22,14 +30,31 @@ import (
 // Account & Trading Endpoints: https://api.schwabapi.com/trader/v1/docs/
 
 const (
-	// Schwab API endpoints
-	baseURL             = "https://api.schwabapi.com"
-	authURL             = "https://api.schwabapi.com/v1/oauth/authorize"
-	tokenURL            = "https://api.schwabapi.com/v1/oauth/token"
+	// Schwab production endpoints, used when Config leaves them empty
+	defaultBaseURL  = "https://api.schwabapi.com"
+	defaultAuthURL  = "https://api.schwabapi.com/v1/oauth/authorize"
+	defaultTokenURL = "https://api.schwabapi.com/v1/oauth/token"
+
+	// Schwab API paths
 	accountsPath        = "/trader/v1/accounts"
 	accountsNumbersPath = "/trader/v1/accounts/accountNumbers"
 	ordersPath          = "/trader/v1/accounts/%s/orders"
 	quotesPath          = "/marketdata/v1/quotes"
+
+	// defaultOrdersLookback is how far back GetRecentOrders looks when the
+	// query has no start time
+	defaultOrdersLookback = 60 * 24 * time.Hour
+
+	// tokenRefreshMargin is how long before expiry an access token is
+	// refreshed, so requests never go out with a token about to lapse
+	tokenRefreshMargin = 5 * time.Minute
+
+	// refreshTokenLifetime is how long Schwab honors a refresh token after the
+	// authorization code exchange that issued it
+	refreshTokenLifetime = 7 * 24 * time.Hour
+
+	// maxSymbolsPerQuoteRequest keeps batched quote URLs under the API's length limit
+	maxSymbolsPerQuoteRequest = 100
 )
 
 // Config holds Schwab API configuration
@@ -38,6 +63,65 @@ type Config struct {
 	ClientSecret string `json:"client_secret"`
 	RedirectURI  string `json:"redirect_uri"`
 	TokenFile    string `json:"token_file"`
+
+	// BaseURL, AuthURL and TokenURL override the production endpoints, e.g.
+	// to point the client at a sandbox or a local mock server
+	BaseURL  string `json:"base_url"`
+	AuthURL  string `json:"auth_url"`
+	TokenURL string `json:"token_url"`
+	// AllowInsecure permits plain http endpoint URLs for local testing
+	AllowInsecure bool `json:"allow_insecure"`
+
+	// CallbackAddr is the address Authenticate listens on for the OAuth
+	// redirect, and CallbackCertFile/CallbackKeyFile the TLS certificate it
+	// serves. An empty CallbackAddr listens on the host and port of
+	// RedirectURI, and empty certificate files use the local-cert ones.
+	CallbackAddr     string `json:"callback_addr"`
+	CallbackCertFile string `json:"callback_cert_file"`
+	CallbackKeyFile  string `json:"callback_key_file"`
+
+	// MaxRetryAttempts is the total number of attempts made for a request
+	// that fails transiently. Zero uses defaultMaxRetryAttempts and 1
+	// disables retries.
+	MaxRetryAttempts int `json:"max_retry_attempts"`
+	// RetryBaseDelayMillis is the backoff before the first retry, doubling
+	// on every subsequent one. Zero uses defaultRetryBaseDelay.
+	RetryBaseDelayMillis int `json:"retry_base_delay_ms"`
+	// RetryOrders opts order submission (POST) into retries. It is off by
+	// default because a retried submission can place the same trade twice.
+	RetryOrders bool `json:"retry_orders"`
+	// MaxRateLimitRetries is how many times a request answered with 429 is
+	// resent after waiting for its Retry-After. Zero uses
+	// defaultMaxRateLimitRetries and a negative value disables these retries.
+	MaxRateLimitRetries int `json:"max_rate_limit_retries"`
+
+	// RequestsPerSecond caps the rate of API requests made by a client.
+	// Zero uses defaultRequestsPerSecond and a negative value disables the
+	// limit.
+	RequestsPerSecond float64 `json:"requests_per_second"`
+
+	// UserAgent identifies the process in API requests. Empty uses
+	// "money-pies/<Version>".
+	UserAgent string `json:"user_agent"`
+
+	// DryRun logs orders to the WithLogger logger instead of submitting them.
+	// PlaceOrder, ReplaceOrder and CancelPendingOrder never reach Schwab;
+	// their simulated orders fill at the current quote when GetOrderStatus
+	// is called. Reads are unaffected.
+	DryRun bool `json:"dry_run"`
+
+	// DuplicateGuard makes PlaceOrder refuse, with ErrDuplicateOrder, an
+	// order identical to one placed in the last DuplicateWindowSeconds, so a
+	// retry bug or a second run cannot trade twice. Orders that set
+	// AllowDuplicate are let through. Zero DuplicateWindowSeconds uses
+	// defaultDuplicateWindow. The placed orders are remembered in memory,
+	// and in DuplicateGuardFile as well when it is set, which lets processes
+	// sharing the file see each other's orders. They take turns through a
+	// lock file beside it, DuplicateGuardFile with ".lock" appended. Dry
+	// runs are not guarded.
+	DuplicateGuard         bool   `json:"duplicate_guard"`
+	DuplicateWindowSeconds int    `json:"duplicate_window_seconds"`
+	DuplicateGuardFile     string `json:"duplicate_guard_file"`
 }
 
 // Token represents OAuth tokens
@@ -48,57 +132,249 @@ type Token struct {
 	TokenType    string    `json:"token_type"`
 	Scope        string    `json:"scope"`
 	ExpiresAt    time.Time `json:"expires_at"`
+
+	// RefreshTokenExpiresAt is zero for token files written before it was
+	// tracked, in which case the refresh token is assumed to still be valid
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at,omitempty"`
 }
 
 // Client implements the brokerage.BrokerageClient interface for Schwab
 type Client struct {
-	config     Config
-	httpClient *http.Client
-	token      *Token
+	config      Config
+	httpClient  *http.Client
+	timeout     time.Duration
+	rateLimiter *rateLimiter
+	logger      *slog.Logger
+	metrics     Metrics
+	clock       Clock
+	tokenStore  TokenStore
+
+	// do sends requests over httpClient, via the recorder if there is one.
+	// middleware wraps the sending of API requests, and send is do wrapped
+	// in it.
+	recorder   *recorder
+	do         RoundTripperFunc
+	middleware []Middleware
+	send       RoundTripperFunc
+
+	// dryRun simulates order submission when Config.DryRun is set
+	dryRun *brokerage.DryRunOrders
+
+	// duplicates refuses repeated orders when Config.DuplicateGuard is set
+	duplicates *duplicateGuard
+
+	// accountHashes caches plain account number -> hash value
+	accountHashesMu sync.Mutex
+	accountHashes   map[string]string
+
+	// tokenMu guards token and codeVerifier, and serializes refreshes so
+	// concurrent requests never spend the same refresh token twice
+	tokenMu sync.Mutex
+	token   *Token
+
+	// generateCodeVerifier produces the PKCE code verifier for each
+	// authorization attempt; codeVerifier holds the one last issued
+	generateCodeVerifier func() string
+	codeVerifier         string
+
+	// authTimeout bounds how long Authenticate waits for the OAuth redirect
+	// and openURL shows the authorization URL to the user
+	authTimeout time.Duration
+	openURL     func(string) error
+
+	// tokenCallbacks are run whenever a new token is issued, and notifier
+	// is told when StartAutoRefresh cannot refresh the token
+	tokenCallbacksMu sync.Mutex
+	tokenCallbacks   []func(Token)
+	notifier         brokerage.Notifier
 }
 
-// NewClient creates a new Schwab client
+// NewClient creates a new Schwab client, failing when config does not pass
+// Validate or leaves the client nowhere to keep its token. Unless
+// WithHTTPClient is given, the underlying HTTP client uses defaultTimeout or
+// the WithTimeout value.
 // Documentation: https://developer.schwab.com/products/trader-api--individual/details/documentation/Retail%20Trader%20API%20Production
-func NewClient(config Config, timeoutInSeconds int) *Client {
-	return &Client{
-		config: config,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+func NewClient(config Config, opts ...Option) (*Client, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid schwab config: %w", err)
+	}
+
+	if config.BaseURL == "" {
+		config.BaseURL = defaultBaseURL
+	}
+	if config.AuthURL == "" {
+		config.AuthURL = defaultAuthURL
+	}
+	if config.TokenURL == "" {
+		config.TokenURL = defaultTokenURL
+	}
+	if config.CallbackCertFile == "" {
+		config.CallbackCertFile = defaultCallbackCertFile
+	}
+	if config.CallbackKeyFile == "" {
+		config.CallbackKeyFile = defaultCallbackKeyFile
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.UserAgent == "" {
+		config.UserAgent = "money-pies/" + Version
+	}
+
+	c := &Client{
+		config:               config,
+		timeout:              defaultTimeout,
+		authTimeout:          defaultAuthTimeout,
+		openURL:              browser.OpenURL,
+		logger:               slog.New(slog.DiscardHandler),
+		metrics:              noopMetrics{},
+		clock:                realClock{},
+		rateLimiter:          newRateLimiter(config.RequestsPerSecond),
+		generateCodeVerifier: newCodeVerifier,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if config.DryRun {
+		c.dryRun = brokerage.NewDryRunOrders(c, c.logger)
+	}
+	c.duplicates = newDuplicateGuard(config, c.clock)
+
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{
+			Timeout: c.timeout,
+		}
+	}
+	c.do = c.httpClient.Do
+	if c.recorder != nil {
+		c.recorder.logger = c.logger
+		c.do = c.recorder.wrap(c.do)
+		if c.recorder.mode == ReplayMode {
+			store := &MemoryTokenStore{}
+			store.Save(placeholderToken())
+			c.tokenStore = store
+		}
+	}
+	c.send = chain(c.do, c.middleware)
+
+	if c.tokenStore == nil {
+		if config.TokenFile == "" {
+			return nil, errors.New("invalid schwab config: token_file is required")
+		}
+		c.tokenStore = &FileTokenStore{Path: config.TokenFile}
+	}
+
+	return c, nil
+}
+
+// validateEndpoints checks that the configured endpoint URLs are absolute and
+// use https, unless AllowInsecure permits plain http
+func validateEndpoints(config Config) []error {
+	endpoints := []struct {
+		name  string
+		value string
+	}{
+		{"base_url", config.BaseURL},
+		{"auth_url", config.AuthURL},
+		{"token_url", config.TokenURL},
+	}
+
+	var errs []error
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint.value)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("invalid %s %q: %w", endpoint.name, endpoint.value, err))
+		case u.Host == "":
+			errs = append(errs, fmt.Errorf("invalid %s %q: must be an absolute URL", endpoint.name, endpoint.value))
+		case u.Scheme == "https":
+		case u.Scheme == "http" && config.AllowInsecure:
+		default:
+			errs = append(errs, fmt.Errorf("invalid %s %q: scheme must be https unless allow_insecure is set", endpoint.name, endpoint.value))
+		}
 	}
+	return errs
 }
 
+// GetAuthURL builds the URL the user visits to authorize the application.
+// Callers that handle the redirect themselves should prefer
+// GetAuthURLWithState so the callback can be verified.
 func (c *Client) GetAuthURL() string {
-	return fmt.Sprintf("%s?client_id=%s&redirect_uri=%s&response_type=code",
-		authURL,
+	authURL, _ := c.GetAuthURLWithState()
+	return authURL
+}
+
+// GetAuthURLWithState builds the authorization URL along with the random
+// state value embedded in it. The redirect back to RedirectURI must carry the
+// same state, otherwise the callback did not originate from this request.
+// Each call starts a new PKCE exchange: a fresh code verifier is stored on the
+// client and its S256 challenge is included in the URL.
+func (c *Client) GetAuthURLWithState() (string, string) {
+	verifier := c.generateCodeVerifier()
+	c.tokenMu.Lock()
+	c.codeVerifier = verifier
+	c.tokenMu.Unlock()
+	state := newState()
+
+	authURL := fmt.Sprintf("%s?client_id=%s&redirect_uri=%s&response_type=code&code_challenge=%s&code_challenge_method=S256&state=%s",
+		c.config.AuthURL,
 		url.QueryEscape(c.config.ClientID),
 		url.QueryEscape(c.config.RedirectURI),
+		url.QueryEscape(codeChallenge(verifier)),
+		url.QueryEscape(state),
 	)
+
+	return authURL, state
+}
+
+// SetAccessToken stores the token on the client and persists it to the
+// token store
+func (c *Client) SetAccessToken(token Token) error {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	return c.setAccessTokenLocked(token)
 }
 
-func (c *Client) SetAccessToken(token Token) *Client {
+// setAccessTokenLocked is SetAccessToken for callers already holding tokenMu
+func (c *Client) setAccessTokenLocked(token Token) error {
 	c.token = &token
-	rawToken, err := json.Marshal(token)
-	if err != nil {
-		return c
+	if err := c.tokenStore.Save(&token); err != nil {
+		return fmt.Errorf("failed to save token: %w", err)
 	}
-	os.WriteFile(c.config.TokenFile, rawToken, 0644)
-	return c
+
+	return nil
 }
 
-func (c *Client) GetAccessTokenFromFile() *Client {
-	rawToken, err := os.ReadFile(c.config.TokenFile)
+// LoadToken loads a previously saved token from the token store. The
+// error wraps ErrNoToken when nothing has been saved yet and
+// ErrRefreshTokenExpired when the saved token can no longer be refreshed; a
+// token that merely needs refreshing loads without error.
+func (c *Client) LoadToken() error {
+	token, err := c.tokenStore.Load()
 	if err != nil {
-		return c
+		return fmt.Errorf("failed to load token: %w", err)
 	}
 
-	var token Token
-	if err := json.Unmarshal(rawToken, &token); err != nil {
-		fmt.Printf("failed to unmarshal token")
-		return c
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	c.token = token
+	if !c.isAuthenticatedLocked() && !c.refreshTokenValidLocked() {
+		return ErrRefreshTokenExpired
 	}
 
-	c.token = &token
+	return nil
+}
+
+// GetAccessTokenFromFile loads a previously saved token, logging any error
+// as a warning.
+//
+// Deprecated: use LoadToken, which reports why the token could not be loaded.
+func (c *Client) GetAccessTokenFromFile() *Client {
+	if err := c.LoadToken(); err != nil && !errors.Is(err, ErrNoToken) {
+		c.logger.Warn("failed to load token", slog.String("error", err.Error()))
+	}
 	return c
 }
 
@@ -108,18 +384,19 @@ func (c *Client) ExchangeAuthCodeForAccessToken(ctx context.Context, code string
 	data.Set("grant_type", "authorization_code")
 	data.Set("code", code)
 	data.Set("redirect_uri", c.config.RedirectURI)
+	c.tokenMu.Lock()
+	verifier := c.codeVerifier
+	c.tokenMu.Unlock()
+	if verifier != "" {
+		data.Set("code_verifier", verifier)
+	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(data.Encode()))
+	req, err := c.newTokenRequest(ctx, data)
 	if err != nil {
 		return fmt.Errorf("failed to create token request: %w", err)
 	}
 
-	credentials := fmt.Sprintf("%s:%s", c.config.ClientID, c.config.ClientSecret)
-	encodedCredentials := base64.StdEncoding.EncodeToString([]byte(credentials))
-	req.Header.Set("Authorization", fmt.Sprintf("Basic %s", encodedCredentials))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to exchange code for token: %w", err)
 	}
@@ -131,7 +408,7 @@ func (c *Client) ExchangeAuthCodeForAccessToken(ctx context.Context, code string
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("token request failed with status %d: %s", resp.StatusCode, string(body))
+		return newAPIError("token request", resp, body)
 	}
 
 	var token Token
@@ -139,33 +416,93 @@ func (c *Client) ExchangeAuthCodeForAccessToken(ctx context.Context, code string
 		return fmt.Errorf("failed to parse token response: %w", err)
 	}
 
-	token.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
-	c.SetAccessToken(token)
+	now := c.clock.Now()
+	token.ExpiresAt = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+	token.RefreshTokenExpiresAt = now.Add(refreshTokenLifetime)
+
+	c.tokenMu.Lock()
+	if token.RefreshToken == "" && c.token != nil {
+		// Without a new refresh token the one held, and its expiry, stay
+		// usable
+		token.RefreshToken = c.token.RefreshToken
+		token.RefreshTokenExpiresAt = c.token.RefreshTokenExpiresAt
+	}
+	err = c.setAccessTokenLocked(token)
+	c.tokenMu.Unlock()
+	if err != nil {
+		return err
+	}
 
+	c.notifyTokenRefreshed(ctx, token)
 	return nil
 }
 
-// RefreshToken refreshes the access token using the refresh token
+// newTokenRequest builds a request posting data to the token endpoint.
+// Confidential clients authenticate with Basic auth; public clients, which
+// have no secret, identify themselves with client_id in the body instead and
+// rely on PKCE.
+func (c *Client) newTokenRequest(ctx context.Context, data url.Values) (*http.Request, error) {
+	if c.config.ClientSecret == "" {
+		data.Set("client_id", c.config.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.config.TokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	if c.config.ClientSecret != "" {
+		credentials := fmt.Sprintf("%s:%s", c.config.ClientID, c.config.ClientSecret)
+		encodedCredentials := base64.StdEncoding.EncodeToString([]byte(credentials))
+		req.Header.Set("Authorization", fmt.Sprintf("Basic %s", encodedCredentials))
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", c.config.UserAgent)
+	return req, nil
+}
+
+// refreshToken refreshes the access token using the refresh token. The caller
+// must hold tokenMu.
 // Documentation: https://developer.schwab.com/products/trader-api--individual/details/documentation/Retail%20Trader%20API%20Production
 func (c *Client) refreshToken(ctx context.Context) error {
+	var refreshToken string
+	if c.token != nil {
+		refreshToken = c.token.RefreshToken
+	}
+
+	err := c.requestRefreshedToken(ctx)
+	c.metrics.TokenRefreshed(err == nil)
+	if err != nil {
+		c.logger.WarnContext(ctx, "schwab token refresh failed",
+			slog.String("error", redact(err.Error(), refreshToken)))
+		return err
+	}
+
+	c.logger.InfoContext(ctx, "schwab token refreshed",
+		slog.Time("expires_at", c.token.ExpiresAt))
+	c.notifyTokenRefreshed(ctx, *c.token)
+	return nil
+}
+
+// requestRefreshedToken performs the refresh token exchange for refreshToken
+func (c *Client) requestRefreshedToken(ctx context.Context) error {
 	if c.token == nil || c.token.RefreshToken == "" {
 		return fmt.Errorf("no refresh token available")
 	}
+	if !c.refreshTokenValidLocked() {
+		return ErrRefreshTokenExpired
+	}
 
 	data := url.Values{}
 	data.Set("grant_type", "refresh_token")
 	data.Set("refresh_token", c.token.RefreshToken)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(data.Encode()))
+	req, err := c.newTokenRequest(ctx, data)
 	if err != nil {
 		return fmt.Errorf("failed to create refresh token request: %w", err)
 	}
 
-	encodedCredentials := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", c.config.ClientID, c.config.ClientSecret)))
-	req.Header.Set("Authorization", fmt.Sprintf("Basic %s", encodedCredentials))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to refresh token: %w", err)
 	}
@@ -177,7 +514,7 @@ func (c *Client) refreshToken(ctx context.Context) error {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("refresh token request failed with status %d: %s", resp.StatusCode, string(body))
+		return newAPIError("refresh token request", resp, body)
 	}
 
 	var token Token
@@ -185,38 +522,271 @@ func (c *Client) refreshToken(ctx context.Context) error {
 		return fmt.Errorf("failed to parse refresh token response: %w", err)
 	}
 
-	token.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
-	c.SetAccessToken(token)
-
-	return nil
+	// Refreshing does not extend the refresh token's lifetime
+	token.ExpiresAt = c.clock.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	token.RefreshTokenExpiresAt = c.token.RefreshTokenExpiresAt
+	if token.RefreshToken == "" {
+		// Schwab sometimes leaves the refresh token out of the response,
+		// which does not revoke the one that was just used
+		token.RefreshToken = c.token.RefreshToken
+	}
+	return c.setAccessTokenLocked(token)
 }
 
 // IsAuthenticated checks if the client has a valid access token
 func (c *Client) IsAuthenticated() bool {
-	return c.token != nil && time.Now().Before(c.token.ExpiresAt)
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	return c.isAuthenticatedLocked()
 }
 
-// makeRequest is a helper function to make authenticated API requests
-func (c *Client) makeRequest(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
-	// Check if token needs refresh
-	if c.token != nil && time.Now().Add(5*time.Minute).After(c.token.ExpiresAt) {
+// isAuthenticatedLocked is IsAuthenticated for callers already holding tokenMu
+func (c *Client) isAuthenticatedLocked() bool {
+	return c.token != nil && c.clock.Now().Before(c.token.ExpiresAt)
+}
+
+// RefreshTokenValid reports whether the client holds a refresh token that has
+// not yet expired. Once it returns false the OAuth flow has to be run again.
+func (c *Client) RefreshTokenValid() bool {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	return c.refreshTokenValidLocked()
+}
+
+// RefreshTokenExpiresAt returns when the client's refresh token expires, zero
+// when it has none or the expiry is not known
+func (c *Client) RefreshTokenExpiresAt() time.Time {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.token == nil {
+		return time.Time{}
+	}
+	return c.token.RefreshTokenExpiresAt
+}
+
+// Refresh exchanges the refresh token for a new token now, whether or not
+// the access token is close to expiring, and saves it to the token store
+func (c *Client) Refresh(ctx context.Context) error {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	return c.refreshToken(ctx)
+}
+
+// CurrentToken returns a copy of the client's token, or false when it has
+// none
+func (c *Client) CurrentToken() (Token, bool) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.token == nil {
+		return Token{}, false
+	}
+	return *c.token, true
+}
+
+// TokenStore returns where the client loads and saves its token
+func (c *Client) TokenStore() TokenStore {
+	return c.tokenStore
+}
+
+// refreshTokenValidLocked is RefreshTokenValid for callers already holding tokenMu
+func (c *Client) refreshTokenValidLocked() bool {
+	if c.token == nil || c.token.RefreshToken == "" {
+		return false
+	}
+	return c.token.RefreshTokenExpiresAt.IsZero() || c.clock.Now().Before(c.token.RefreshTokenExpiresAt)
+}
+
+// OnTokenRefreshed registers fn to be called with a copy of every new token,
+// both after the authorization code exchange and after each refresh. Schwab
+// may issue a new refresh token on refresh or leave it unchanged, so this is
+// the place to push it to an external secrets manager.
+//
+// Callbacks run on their own goroutine so a slow one never holds up
+// requests; a panicking callback is recovered and logged.
+func (c *Client) OnTokenRefreshed(fn func(Token)) {
+	c.tokenCallbacksMu.Lock()
+	defer c.tokenCallbacksMu.Unlock()
+
+	c.tokenCallbacks = append(c.tokenCallbacks, fn)
+}
+
+// notifyTokenRefreshed runs the OnTokenRefreshed callbacks for token
+func (c *Client) notifyTokenRefreshed(ctx context.Context, token Token) {
+	c.tokenCallbacksMu.Lock()
+	callbacks := slices.Clone(c.tokenCallbacks)
+	c.tokenCallbacksMu.Unlock()
+
+	for _, fn := range callbacks {
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					c.logger.ErrorContext(ctx, "schwab token refresh callback panicked",
+						slog.Any("panic", r))
+				}
+			}()
+			fn(token)
+		}()
+	}
+}
+
+// validAccessToken returns an access token that is safe to use, refreshing it
+// first when it is close to expiry. Concurrent callers wait for a single
+// in-flight refresh and then all use its result.
+func (c *Client) validAccessToken(ctx context.Context) (string, error) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.token != nil && c.needsRefreshLocked() {
+		if !c.refreshTokenValidLocked() {
+			// Keep using the access token for whatever life it has left
+			if c.isAuthenticatedLocked() {
+				return c.token.AccessToken, nil
+			}
+			return "", ErrRefreshTokenExpired
+		}
 		if err := c.refreshToken(ctx); err != nil {
-			return nil, fmt.Errorf("failed to refresh token: %w", err)
+			// A failed refresh is only fatal once the current token is unusable
+			if c.isAuthenticatedLocked() {
+				return c.token.AccessToken, nil
+			}
+			return "", fmt.Errorf("%w: failed to refresh token: %w", ErrTokenExpired, err)
+		}
+	}
+
+	if !c.isAuthenticatedLocked() {
+		return "", ErrNotAuthenticated
+	}
+
+	return c.token.AccessToken, nil
+}
+
+// needsRefreshLocked reports whether the access token is within
+// tokenRefreshMargin of expiring. The caller must hold tokenMu.
+func (c *Client) needsRefreshLocked() bool {
+	return !c.clock.Now().Before(c.token.ExpiresAt.Add(-tokenRefreshMargin))
+}
+
+// forceRefresh refreshes the token after the server rejected staleToken,
+// unless another request has already replaced it in the meantime
+func (c *Client) forceRefresh(ctx context.Context, staleToken string) error {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.token != nil && c.token.AccessToken != staleToken {
+		return nil
+	}
+	return c.refreshToken(ctx)
+}
+
+// makeRequest is a helper function to make authenticated API requests.
+// Transient failures are retried with exponential backoff; see shouldRetry.
+func (c *Client) makeRequest(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	// Buffer the body so it can be resent on retries
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+
+	maxAttempts := c.maxAttempts(method)
+	reauthenticated := false
+	rateLimitRetries := 0
+	for attempt := 1; ; {
+		accessToken, err := c.validAccessToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.rateLimiter.Wait(ctx); err != nil {
+			return nil, err
 		}
+
+		resp, err := c.sendRequest(ctx, method, path, payload, accessToken)
+
+		// Schwab sometimes revokes access tokens before they expire. Refresh
+		// and resend once; a second 401 means the session is really gone.
+		if err == nil && resp.StatusCode == http.StatusUnauthorized {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			if reauthenticated {
+				return nil, ErrNotAuthenticated
+			}
+			if err := c.forceRefresh(ctx, accessToken); err != nil {
+				return nil, fmt.Errorf("%w: failed to refresh token after 401: %w", ErrNotAuthenticated, err)
+			}
+			reauthenticated = true
+			continue
+		}
+
+		// A 429 means the request was not processed, so even order
+		// submissions can safely wait out the Retry-After and resend
+		if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+			c.metrics.RateLimited(endpointLabel(path))
+		}
+		if err == nil && resp.StatusCode == http.StatusTooManyRequests && rateLimitRetries < c.maxRateLimitRetries() {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			rateLimitRetries++
+			delay, ok := retryAfter(resp, c.clock.Now())
+			if !ok {
+				delay = c.retryDelay(rateLimitRetries)
+			}
+			if err := sleepContext(ctx, delay); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if attempt >= maxAttempts || !shouldRetry(ctx, resp, err) {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if err := sleepContext(ctx, c.retryDelay(attempt)); err != nil {
+			return nil, err
+		}
+		attempt++
 	}
+}
 
-	if !c.IsAuthenticated() {
-		return nil, fmt.Errorf("not authenticated")
+// sendRequest performs a single attempt of an authenticated API request
+func (c *Client) sendRequest(ctx context.Context, method, path string, payload []byte, accessToken string) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, baseURL+path, body)
+	req, err := http.NewRequestWithContext(ctx, method, c.config.BaseURL+path, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.token.AccessToken)
+	correlationID := requestCorrelationID(ctx)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("User-Agent", c.config.UserAgent)
+	req.Header.Set(correlationIDHeader, correlationID)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
-	resp, err := c.httpClient.Do(req)
+	start := time.Now()
+	resp, err := c.send(req)
+	latency := time.Since(start)
+	c.logResponse(ctx, method, path, correlationID, resp, err, latency, accessToken)
+	c.metrics.RequestCompleted(endpointLabel(path), statusClass(resp, err), latency)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -240,7 +810,7 @@ func (c *Client) GetAccounts(ctx context.Context) ([]brokerage.Account, error) {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("get accounts failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError("get accounts", resp, body)
 	}
 
 	var schwabAccounts []struct {
@@ -249,9 +819,10 @@ func (c *Client) GetAccounts(ctx context.Context) ([]brokerage.Account, error) {
 			Type            string `json:"type"`
 			AccountID       string `json:"accountId"`
 			CurrentBalances struct {
-				CashBalance float64 `json:"cashBalance"`
-				BuyingPower float64 `json:"buyingPower"`
-				MarketValue float64 `json:"longMarketValue"`
+				CashBalance             json.Number `json:"cashBalance"`
+				CashAvailableForTrading json.Number `json:"cashAvailableForTrading"`
+				BuyingPower             json.Number `json:"buyingPower"`
+				MarketValue             json.Number `json:"longMarketValue"`
 			} `json:"currentBalances"`
 		} `json:"securitiesAccount"`
 	}
@@ -260,28 +831,121 @@ func (c *Client) GetAccounts(ctx context.Context) ([]brokerage.Account, error) {
 		return nil, fmt.Errorf("failed to parse accounts response: %w", err)
 	}
 
+	accountHashes, err := c.GetAccountNumbers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Preferences only add nicknames and IRA registrations, so accounts are
+	// still returned without them
+	nicknames := make(map[string]string)
+	preferenceTypes := make(map[string]string)
+	if preferences, err := c.GetUserPreferences(ctx); err != nil {
+		c.logger.WarnContext(ctx, "failed to load account nicknames", slog.String("error", err.Error()))
+	} else {
+		for _, ap := range preferences.Accounts {
+			nicknames[ap.AccountNumber] = ap.NickName
+			preferenceTypes[ap.AccountNumber] = ap.Type
+		}
+	}
+
 	accounts := make([]brokerage.Account, 0, len(schwabAccounts))
 	for _, sa := range schwabAccounts {
 		acc := sa.SecuritiesAccount
+		cash := decimalFrom(acc.CurrentBalances.CashBalance)
+		marketValue := decimalFrom(acc.CurrentBalances.MarketValue)
 		accounts = append(accounts, brokerage.Account{
 			AccountID:     acc.AccountID,
 			AccountNumber: acc.AccountNumber,
+			AccountHash:   accountHashes[acc.AccountNumber],
+			Nickname:      nicknames[acc.AccountNumber],
 			Type:          acc.Type,
-			CashBalance:   acc.CurrentBalances.CashBalance,
-			BuyingPower:   acc.CurrentBalances.BuyingPower,
-			MarketValue:   acc.CurrentBalances.MarketValue,
-			TotalValue:    acc.CurrentBalances.CashBalance + acc.CurrentBalances.MarketValue,
+			Kind:          accountKind(acc.Type, preferenceTypes[acc.AccountNumber]),
+			CashBalance:   cash,
+			SettledCash:   decimalFrom(acc.CurrentBalances.CashAvailableForTrading),
+			BuyingPower:   decimalFrom(acc.CurrentBalances.BuyingPower),
+			MarketValue:   marketValue,
+			TotalValue:    cash.Add(marketValue),
 		})
 	}
 
 	return accounts, nil
 }
 
+// GetAccountNumbers retrieves the mapping from plain account numbers to the
+// encrypted hash values Schwab expects in account-specific paths. The mapping
+// is cached on the client for resolveAccountID.
+// Documentation: https://developer.schwab.com/products/trader-api--individual/details/specifications/Retail%20Trader%20API%20Production
+// Endpoint: GET /trader/v1/accounts/accountNumbers
+func (c *Client) GetAccountNumbers(ctx context.Context) (map[string]string, error) {
+	resp, err := c.makeRequest(ctx, "GET", accountsNumbersPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read account numbers response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("get account numbers", resp, body)
+	}
+
+	var schwabAccountNumbers []struct {
+		AccountNumber string `json:"accountNumber"`
+		HashValue     string `json:"hashValue"`
+	}
+
+	if err := json.Unmarshal(body, &schwabAccountNumbers); err != nil {
+		return nil, fmt.Errorf("failed to parse account numbers response: %w", err)
+	}
+
+	accountHashes := make(map[string]string, len(schwabAccountNumbers))
+	for _, an := range schwabAccountNumbers {
+		accountHashes[an.AccountNumber] = an.HashValue
+	}
+
+	c.accountHashesMu.Lock()
+	c.accountHashes = accountHashes
+	c.accountHashesMu.Unlock()
+
+	return maps.Clone(accountHashes), nil
+}
+
+// resolveAccountID translates a plain account number into its hash value.
+// Identifiers that aren't known account numbers are assumed to already be
+// hashes and are returned unchanged.
+func (c *Client) resolveAccountID(ctx context.Context, accountID string) (string, error) {
+	c.accountHashesMu.Lock()
+	accountHashes := c.accountHashes
+	c.accountHashesMu.Unlock()
+
+	if accountHashes == nil {
+		var err error
+		if accountHashes, err = c.GetAccountNumbers(ctx); err != nil {
+			return "", fmt.Errorf("failed to resolve account %s: %w", accountID, err)
+		}
+	}
+
+	if accountHash, ok := accountHashes[accountID]; ok {
+		return accountHash, nil
+	}
+
+	return accountID, nil
+}
+
 // GetPositions retrieves positions for a specific account
 // Documentation: https://developer.schwab.com/products/trader-api--individual/details/specifications/Retail%20Trader%20API%20Production
 // Endpoint: GET /trader/v1/accounts/{accountId}
 func (c *Client) GetPositions(ctx context.Context, accountID string) ([]brokerage.Position, error) {
-	path := fmt.Sprintf("%s/%s?fields=positions", accountsPath, accountID)
+	accountHash, err := c.resolveAccountID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	path := fmt.Sprintf("%s/%s?fields=positions", accountsPath, accountHash)
 	resp, err := c.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, err
@@ -294,21 +958,12 @@ func (c *Client) GetPositions(ctx context.Context, accountID string) ([]brokerag
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("get positions failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError("get positions", resp, body)
 	}
 
 	var accountData struct {
 		SecuritiesAccount struct {
-			Positions []struct {
-				ShortQuantity        float64 `json:"shortQuantity"`
-				AveragePrice         float64 `json:"averagePrice"`
-				CurrentDayProfitLoss float64 `json:"currentDayProfitLoss"`
-				LongQuantity         float64 `json:"longQuantity"`
-				MarketValue          float64 `json:"marketValue"`
-				Instrument           struct {
-					Symbol string `json:"symbol"`
-				} `json:"instrument"`
-			} `json:"positions"`
+			Positions []schwabPosition `json:"positions"`
 		} `json:"securitiesAccount"`
 	}
 
@@ -318,65 +973,55 @@ func (c *Client) GetPositions(ctx context.Context, accountID string) ([]brokerag
 
 	positions := make([]brokerage.Position, 0, len(accountData.SecuritiesAccount.Positions))
 	for _, p := range accountData.SecuritiesAccount.Positions {
-		quantity := p.LongQuantity - p.ShortQuantity
-		currentPrice := 0.0
-		if quantity != 0 {
-			currentPrice = p.MarketValue / quantity
-		}
-
-		unrealizedPL := p.MarketValue - (p.AveragePrice * quantity)
-		unrealizedPLPct := 0.0
-		if p.AveragePrice != 0 {
-			unrealizedPLPct = (unrealizedPL / (p.AveragePrice * quantity)) * 100
-		}
-
-		positions = append(positions, brokerage.Position{
-			Symbol:          p.Instrument.Symbol,
-			Quantity:        quantity,
-			AveragePrice:    p.AveragePrice,
-			CurrentPrice:    currentPrice,
-			MarketValue:     p.MarketValue,
-			UnrealizedPL:    unrealizedPL,
-			UnrealizedPLPct: unrealizedPLPct,
-		})
+		positions = append(positions, p.position())
 	}
 
 	return positions, nil
 }
 
-// PlaceOrder submits a new order
+// PlaceOrder submits a new order. Orders sized by Amount are converted to
+// whole shares at the current quote, as the Trader API does not accept
+// fractional equity quantities; the returned Order carries that quantity.
+// With Config.DuplicateGuard a repeat of a recent order fails with
+// ErrDuplicateOrder instead.
 // Documentation: https://developer.schwab.com/products/trader-api--individual/details/specifications/Retail%20Trader%20API%20Production
 // Endpoint: POST /trader/v1/accounts/{accountId}/orders
 func (c *Client) PlaceOrder(ctx context.Context, accountID string, order brokerage.OrderRequest) (*brokerage.Order, error) {
-	// Build Schwab order structure
-	schwabOrder := map[string]interface{}{
-		"orderType":         string(order.Type),
-		"session":           "NORMAL",
-		"duration":          "DAY",
-		"orderStrategyType": "SINGLE",
-		"orderLegCollection": []map[string]interface{}{
-			{
-				"instruction": string(order.Action),
-				"quantity":    order.Quantity,
-				"instrument": map[string]interface{}{
-					"symbol":    order.Symbol,
-					"assetType": "EQUITY",
-				},
-			},
-		},
+	order, err := brokerage.ResolveAmount(ctx, c, order, false)
+	if err != nil {
+		return nil, err
 	}
 
-	// Add price for limit orders
-	if order.Type == brokerage.OrderTypeLimit && order.LimitPrice != nil {
-		schwabOrder["price"] = *order.LimitPrice
+	orderJSON, err := buildOrderJSON(order)
+	if err != nil {
+		return nil, err
+	}
+	if c.dryRun != nil {
+		return c.dryRun.Place(ctx, accountID, order)
 	}
 
-	orderJSON, err := json.Marshal(schwabOrder)
+	// An order that failed without an answer may still have reached Schwab,
+	// so only those refused outright are forgotten by the duplicate guard
+	forget := func() {}
+	if c.duplicates != nil && !order.AllowDuplicate {
+		key := duplicateKey(accountID, order)
+		if err := c.duplicates.reserve(key); err != nil {
+			return nil, err
+		}
+		forget = func() {
+			if err := c.duplicates.release(key); err != nil {
+				c.logger.WarnContext(ctx, "failed to forget refused order", slog.String("error", err.Error()))
+			}
+		}
+	}
+
+	accountHash, err := c.resolveAccountID(ctx, accountID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal order: %w", err)
+		forget()
+		return nil, err
 	}
 
-	path := fmt.Sprintf(ordersPath, accountID)
+	path := fmt.Sprintf(ordersPath, accountHash)
 	resp, err := c.makeRequest(ctx, "POST", path, strings.NewReader(string(orderJSON)))
 	if err != nil {
 		return nil, err
@@ -389,36 +1034,200 @@ func (c *Client) PlaceOrder(ctx context.Context, accountID string, order brokera
 	}
 
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("place order failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	// Extract order ID from Location header
-	orderID := ""
-	if location := resp.Header.Get("Location"); location != "" {
-		parts := strings.Split(location, "/")
-		if len(parts) > 0 {
-			orderID = parts[len(parts)-1]
-		}
+		forget()
+		return nil, newAPIError("place order", resp, body)
 	}
 
 	return &brokerage.Order{
-		ID:          orderID,
+		ID:          orderIDFromLocation(resp),
 		Symbol:      order.Symbol,
 		Action:      order.Action,
 		Type:        order.Type,
 		Quantity:    order.Quantity,
 		LimitPrice:  order.LimitPrice,
+		StopPrice:   order.StopPrice,
+		Status:      brokerage.OrderStatusPending,
+		SubmittedAt: c.clock.Now(),
+		RawResponse: newRawResponse(resp, body),
+	}, nil
+}
+
+// ReplaceOrder atomically replaces a working order with newOrder, keeping
+// the original order's place until the replacement is accepted. Orders that
+// are already filled, cancelled or rejected are refused without calling the
+// replace endpoint.
+// Documentation: https://developer.schwab.com/products/trader-api--individual/details/specifications/Retail%20Trader%20API%20Production
+// Endpoint: PUT /trader/v1/accounts/{accountId}/orders/{orderId}
+func (c *Client) ReplaceOrder(ctx context.Context, accountID string, orderID string, newOrder brokerage.OrderRequest) (*brokerage.Order, error) {
+	newOrder, err := brokerage.ResolveAmount(ctx, c, newOrder, false)
+	if err != nil {
+		return nil, err
+	}
+
+	orderJSON, err := buildOrderJSON(newOrder)
+	if err != nil {
+		return nil, err
+	}
+	if c.dryRun != nil {
+		return c.dryRun.Replace(ctx, accountID, orderID, newOrder)
+	}
+
+	existing, err := c.GetOrderStatus(ctx, accountID, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check order %s before replacing: %w", orderID, err)
+	}
+	if !existing.Status.IsOpen() {
+		return nil, fmt.Errorf("cannot replace order %s with status %s", orderID, existing.Status)
+	}
+
+	accountHash, err := c.resolveAccountID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	path := fmt.Sprintf("%s/%s/orders/%s", accountsPath, accountHash, orderID)
+	resp, err := c.makeRequest(ctx, "PUT", path, strings.NewReader(string(orderJSON)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replace order response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("replace order", resp, body)
+	}
+
+	return &brokerage.Order{
+		ID:          orderIDFromLocation(resp),
+		Symbol:      newOrder.Symbol,
+		Action:      newOrder.Action,
+		Type:        newOrder.Type,
+		Quantity:    newOrder.Quantity,
+		LimitPrice:  newOrder.LimitPrice,
+		StopPrice:   newOrder.StopPrice,
 		Status:      brokerage.OrderStatusPending,
-		SubmittedAt: time.Now(),
-		RawResponse: string(body),
+		SubmittedAt: c.clock.Now(),
+		RawResponse: newRawResponse(resp, body),
 	}, nil
 }
 
+// orderIDFromLocation extracts the new order's ID from the Location header
+// Schwab returns when an order is created
+func orderIDFromLocation(resp *http.Response) string {
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return ""
+	}
+	parts := strings.Split(location, "/")
+	return parts[len(parts)-1]
+}
+
+// buildOrderJSON validates an order request and encodes it as a Schwab
+// single-leg order. Duration and session default to DAY and NORMAL.
+func buildOrderJSON(order brokerage.OrderRequest) ([]byte, error) {
+	schwabOrder, err := buildOrderMap(order)
+	if err != nil {
+		return nil, err
+	}
+
+	orderJSON, err := json.Marshal(schwabOrder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal order: %w", err)
+	}
+
+	return orderJSON, nil
+}
+
+// buildOrderMap validates an order request and builds the Schwab SINGLE
+// order for it
+func buildOrderMap(order brokerage.OrderRequest) (map[string]interface{}, error) {
+	duration, err := convertOrderDuration(order.Duration)
+	if err != nil {
+		return nil, err
+	}
+
+	session := order.Session
+	if session == "" {
+		session = brokerage.OrderSessionNormal
+	}
+
+	// Schwab only accepts market orders for the regular session, as day orders
+	if order.Type == brokerage.OrderTypeMarket {
+		if session != brokerage.OrderSessionNormal {
+			return nil, fmt.Errorf("market orders are not accepted in the %s session, use a limit order", session)
+		}
+		if duration != "DAY" {
+			return nil, fmt.Errorf("market orders must be DAY orders, got %s", order.Duration)
+		}
+	}
+
+	// Build Schwab order structure
+	schwabOrder := map[string]interface{}{
+		"orderType":         string(order.Type),
+		"session":           string(session),
+		"duration":          duration,
+		"orderStrategyType": "SINGLE",
+		"orderLegCollection": []map[string]interface{}{
+			{
+				"instruction": string(order.Action),
+				"quantity":    jsonNumber(order.Quantity),
+				"instrument": map[string]interface{}{
+					"symbol":    order.Symbol,
+					"assetType": "EQUITY",
+				},
+			},
+		},
+	}
+
+	// Add price for limit orders and stop price for stop orders
+	if order.Type == brokerage.OrderTypeLimit {
+		if order.LimitPrice == nil || !order.LimitPrice.IsPositive() {
+			return nil, errors.New("limit orders require a positive limit price")
+		}
+		schwabOrder["price"] = jsonNumber(*order.LimitPrice)
+	}
+	if order.Type == brokerage.OrderTypeStop {
+		if order.StopPrice == nil || !order.StopPrice.IsPositive() {
+			return nil, errors.New("stop orders require a positive stop price")
+		}
+		schwabOrder["stopPrice"] = jsonNumber(*order.StopPrice)
+	}
+
+	return schwabOrder, nil
+}
+
+// convertOrderDuration converts our order duration to Schwab's, defaulting to DAY
+func convertOrderDuration(duration brokerage.OrderDuration) (string, error) {
+	switch duration {
+	case "", brokerage.OrderDurationDay:
+		return "DAY", nil
+	case brokerage.OrderDurationGTC:
+		return "GOOD_TILL_CANCEL", nil
+	default:
+		return "", fmt.Errorf("unsupported order duration %q", duration)
+	}
+}
+
 // GetOrder retrieves a specific order
 // Documentation: https://developer.schwab.com/products/trader-api--individual/details/specifications/Retail%20Trader%20API%20Production
 // Endpoint: GET /trader/v1/accounts/{accountId}/orders/{orderId}
 func (c *Client) GetOrderStatus(ctx context.Context, accountID string, orderID string) (*brokerage.Order, error) {
-	path := fmt.Sprintf("%s/%s/orders/%s", accountsPath, accountID, orderID)
+	if c.dryRun != nil {
+		if order, ok, err := c.dryRun.Status(ctx, orderID); ok {
+			return order, err
+		}
+	}
+
+	accountHash, err := c.resolveAccountID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	path := fmt.Sprintf("%s/%s/orders/%s", accountsPath, accountHash, orderID)
 	resp, err := c.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, err
@@ -431,58 +1240,33 @@ func (c *Client) GetOrderStatus(ctx context.Context, accountID string, orderID s
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("get order failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var schwabOrder struct {
-		OrderID            int64   `json:"orderId"`
-		Status             string  `json:"status"`
-		Quantity           float64 `json:"quantity"`
-		FilledQuantity     float64 `json:"filledQuantity"`
-		Price              float64 `json:"price"`
-		OrderType          string  `json:"orderType"`
-		EnteredTime        string  `json:"enteredTime"`
-		OrderLegCollection []struct {
-			Instruction string `json:"instruction"`
-			Instrument  struct {
-				Symbol string `json:"symbol"`
-			} `json:"instrument"`
-		} `json:"orderLegCollection"`
-	}
-
-	if err := json.Unmarshal(body, &schwabOrder); err != nil {
-		return nil, fmt.Errorf("failed to parse order response: %w", err)
-	}
-
-	order := &brokerage.Order{
-		ID:          fmt.Sprintf("%d", schwabOrder.OrderID),
-		Status:      c.convertOrderStatus(schwabOrder.Status),
-		Quantity:    schwabOrder.Quantity,
-		FilledQty:   schwabOrder.FilledQuantity,
-		FilledPrice: schwabOrder.Price,
-		Type:        brokerage.OrderType(schwabOrder.OrderType),
-		RawResponse: string(body),
+		return nil, newAPIError("get order", resp, body)
 	}
 
-	if len(schwabOrder.OrderLegCollection) > 0 {
-		order.Symbol = schwabOrder.OrderLegCollection[0].Instrument.Symbol
-		order.Action = brokerage.OrderAction(schwabOrder.OrderLegCollection[0].Instruction)
-	}
-
-	if schwabOrder.EnteredTime != "" {
-		if t, err := time.Parse(time.RFC3339, schwabOrder.EnteredTime); err == nil {
-			order.SubmittedAt = t
-		}
+	var so schwabOrder
+	if err := json.Unmarshal(body, &so); err != nil {
+		return nil, fmt.Errorf("failed to parse order response: %w", err)
 	}
 
-	return order, nil
+	order := c.convertOrder(so)
+	order.RawResponse = newRawResponse(resp, body)
+	return &order, nil
 }
 
 // CancelOrder cancels a pending order
 // Documentation: https://developer.schwab.com/products/trader-api--individual/details/specifications/Retail%20Trader%20API%20Production
 // Endpoint: DELETE /trader/v1/accounts/{accountId}/orders/{orderId}
 func (c *Client) CancelPendingOrder(ctx context.Context, accountID string, orderID string) error {
-	path := fmt.Sprintf("%s/%s/orders/%s", accountsPath, accountID, orderID)
+	if c.dryRun != nil {
+		return c.dryRun.Cancel(ctx, accountID, orderID)
+	}
+
+	accountHash, err := c.resolveAccountID(ctx, accountID)
+	if err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf("%s/%s/orders/%s", accountsPath, accountHash, orderID)
 	resp, err := c.makeRequest(ctx, "DELETE", path, nil)
 	if err != nil {
 		return err
@@ -491,17 +1275,45 @@ func (c *Client) CancelPendingOrder(ctx context.Context, accountID string, order
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("cancel order failed with status %d: %s", resp.StatusCode, string(body))
+		return brokerage.CheckOrderClosed(ctx, c, accountID, orderID, newAPIError("cancel order", resp, body))
 	}
 
 	return nil
 }
 
-// GetOrders retrieves recent orders
+// GetRecentOrders retrieves orders entered within the query's time range,
+// defaulting to the last defaultOrdersLookback. Schwab accepts a single
+// status filter; statuses without an exact Schwab equivalent are filtered
+// after fetching.
 // Documentation: https://developer.schwab.com/products/trader-api--individual/details/specifications/Retail%20Trader%20API%20Production
 // Endpoint: GET /trader/v1/accounts/{accountId}/orders
-func (c *Client) GetRecentOrders(ctx context.Context, accountID string, limit int) ([]brokerage.Order, error) {
-	path := fmt.Sprintf("%s/%s/orders?maxResults=%d", accountsPath, accountID, limit)
+func (c *Client) GetRecentOrders(ctx context.Context, accountID string, query brokerage.OrdersQuery) ([]brokerage.Order, error) {
+	accountHash, err := c.resolveAccountID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	to := query.To
+	if to.IsZero() {
+		to = c.clock.Now()
+	}
+	from := query.From
+	if from.IsZero() {
+		from = to.Add(-defaultOrdersLookback)
+	}
+
+	params := url.Values{}
+	params.Set("fromEnteredTime", from.UTC().Format(schwabTimeLayout))
+	params.Set("toEnteredTime", to.UTC().Format(schwabTimeLayout))
+	if query.MaxResults > 0 {
+		params.Set("maxResults", strconv.Itoa(query.MaxResults))
+	}
+	schwabStatus, filterOnServer := schwabOrderStatusFilter(query.Status)
+	if filterOnServer {
+		params.Set("status", schwabStatus)
+	}
+
+	path := fmt.Sprintf("%s/%s/orders?%s", accountsPath, accountHash, params.Encode())
 	resp, err := c.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, err
@@ -514,62 +1326,151 @@ func (c *Client) GetRecentOrders(ctx context.Context, accountID string, limit in
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("get orders failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var schwabOrders []struct {
-		OrderID            int64   `json:"orderId"`
-		Status             string  `json:"status"`
-		Quantity           float64 `json:"quantity"`
-		FilledQuantity     float64 `json:"filledQuantity"`
-		Price              float64 `json:"price"`
-		OrderType          string  `json:"orderType"`
-		EnteredTime        string  `json:"enteredTime"`
-		OrderLegCollection []struct {
-			Instruction string `json:"instruction"`
-			Instrument  struct {
-				Symbol string `json:"symbol"`
-			} `json:"instrument"`
-		} `json:"orderLegCollection"`
+		return nil, newAPIError("get orders", resp, body)
 	}
 
+	var schwabOrders []schwabOrder
 	if err := json.Unmarshal(body, &schwabOrders); err != nil {
 		return nil, fmt.Errorf("failed to parse orders response: %w", err)
 	}
 
 	orders := make([]brokerage.Order, 0, len(schwabOrders))
 	for _, so := range schwabOrders {
-		order := brokerage.Order{
-			ID:          fmt.Sprintf("%d", so.OrderID),
-			Status:      c.convertOrderStatus(so.Status),
-			Quantity:    so.Quantity,
-			FilledQty:   so.FilledQuantity,
-			FilledPrice: so.Price,
-			Type:        brokerage.OrderType(so.OrderType),
-		}
-
-		if len(so.OrderLegCollection) > 0 {
-			order.Symbol = so.OrderLegCollection[0].Instrument.Symbol
-			order.Action = brokerage.OrderAction(so.OrderLegCollection[0].Instruction)
-		}
-
-		if so.EnteredTime != "" {
-			if t, err := time.Parse(time.RFC3339, so.EnteredTime); err == nil {
-				order.SubmittedAt = t
-			}
+		order := c.convertOrder(so)
+		if query.Status != "" && !filterOnServer && order.Status != query.Status {
+			continue
 		}
 
 		orders = append(orders, order)
 	}
 
+	slices.SortStableFunc(orders, func(a, b brokerage.Order) int {
+		return b.SubmittedAt.Compare(a.SubmittedAt)
+	})
 	return orders, nil
 }
 
+// schwabOrderStatusFilter returns the Schwab status string to filter orders
+// by, or false if the status has no single Schwab equivalent (including the
+// empty status, meaning no filter)
+func schwabOrderStatusFilter(status brokerage.OrderStatus) (string, bool) {
+	switch status {
+	case brokerage.OrderStatusFilled:
+		return "FILLED", true
+	case brokerage.OrderStatusCancelled:
+		return "CANCELED", true
+	case brokerage.OrderStatusRejected:
+		return "REJECTED", true
+	case brokerage.OrderStatusExpired:
+		return "EXPIRED", true
+	case brokerage.OrderStatusReplaced:
+		return "REPLACED", true
+	default:
+		return "", false
+	}
+}
+
 // GetQuote retrieves a quote for a symbol
 // Documentation: https://developer.schwab.com/products/trader-api--individual/details/specifications/Retail%20Trader%20API%20Production
 // Endpoint: GET /marketdata/v1/quotes
-func (c *Client) GetQuote(ctx context.Context, symbol string) (map[string]interface{}, error) {
-	path := fmt.Sprintf("%s?symbols=%s", quotesPath, url.QueryEscape(symbol))
+func (c *Client) GetQuote(ctx context.Context, symbol string) (*brokerage.Quote, error) {
+	return c.GetQuoteWithFields(ctx, symbol)
+}
+
+// BatchesQuotes reports that GetQuotes fetches many symbols per request,
+// implementing brokerage.QuoteBatcher
+func (c *Client) BatchesQuotes() bool {
+	return true
+}
+
+// GetQuoteWithFields is GetQuote requesting only the given field groups,
+// or all of them when none are given
+func (c *Client) GetQuoteWithFields(ctx context.Context, symbol string, fields ...QuoteField) (*brokerage.Quote, error) {
+	quotes, err := c.getRawQuotes(ctx, []string{symbol}, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	rawQuote, ok := lookupRawQuote(quotes, symbol)
+	if !ok {
+		return nil, fmt.Errorf("no quote returned for symbol %s", symbol)
+	}
+
+	return parseQuote(symbol, rawQuote)
+}
+
+// GetQuotes retrieves quotes for multiple symbols, batching them into as few
+// requests as the API allows. Symbols missing from the response are reported
+// through a *brokerage.MissingQuotesError together with the quotes that were found.
+// Documentation: https://developer.schwab.com/products/trader-api--individual/details/specifications/Retail%20Trader%20API%20Production
+// Endpoint: GET /marketdata/v1/quotes
+func (c *Client) GetQuotes(ctx context.Context, symbols []string) (map[string]brokerage.Quote, error) {
+	return c.GetQuotesWithFields(ctx, symbols)
+}
+
+// GetQuotesWithFields is GetQuotes requesting only the given field groups,
+// or all of them when none are given. Fetching just QuoteFieldQuote is
+// noticeably faster for long symbol lists.
+func (c *Client) GetQuotesWithFields(ctx context.Context, symbols []string, fields ...QuoteField) (map[string]brokerage.Quote, error) {
+	quotes := make(map[string]brokerage.Quote, len(symbols))
+	var missing []string
+
+	for start := 0; start < len(symbols); start += maxSymbolsPerQuoteRequest {
+		end := min(start+maxSymbolsPerQuoteRequest, len(symbols))
+		batch := symbols[start:end]
+
+		rawQuotes, err := c.getRawQuotes(ctx, batch, fields)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, symbol := range batch {
+			rawQuote, ok := lookupRawQuote(rawQuotes, symbol)
+			if !ok {
+				missing = append(missing, symbol)
+				continue
+			}
+
+			quote, err := parseQuote(symbol, rawQuote)
+			if err != nil {
+				return nil, err
+			}
+			quotes[symbol] = *quote
+		}
+	}
+
+	if len(missing) > 0 {
+		return quotes, &brokerage.MissingQuotesError{Symbols: missing}
+	}
+
+	return quotes, nil
+}
+
+// QuoteField selects a group of fields returned by the quotes endpoint
+type QuoteField string
+
+const (
+	QuoteFieldQuote       QuoteField = "quote"
+	QuoteFieldFundamental QuoteField = "fundamental"
+	QuoteFieldExtended    QuoteField = "extended" // Pre-market and after-hours prices
+	QuoteFieldReference   QuoteField = "reference"
+	QuoteFieldRegular     QuoteField = "regular" // Regular session prices
+)
+
+// getRawQuotes fetches the quotes endpoint for a batch of symbols and returns
+// the per-symbol JSON objects keyed by symbol. No fields requests them all.
+func (c *Client) getRawQuotes(ctx context.Context, symbols []string, fields []QuoteField) (map[string]json.RawMessage, error) {
+	params := url.Values{}
+	params.Set("symbols", strings.Join(symbols, ","))
+	if len(fields) > 0 {
+		names := make([]string, len(fields))
+		for i, field := range fields {
+			names[i] = string(field)
+		}
+		params.Set("fields", strings.Join(names, ","))
+	}
+
+	path := fmt.Sprintf("%s?%s", quotesPath, params.Encode())
 	resp, err := c.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, err
@@ -582,27 +1483,230 @@ func (c *Client) GetQuote(ctx context.Context, symbol string) (map[string]interf
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("get quote failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError("get quote", resp, body)
 	}
 
-	var quotes map[string]interface{}
+	var quotes map[string]json.RawMessage
 	if err := json.Unmarshal(body, &quotes); err != nil {
 		return nil, fmt.Errorf("failed to parse quote response: %w", err)
 	}
 
+	// Unknown symbols are listed under an "errors" key rather than as quotes
+	delete(quotes, "errors")
+
 	return quotes, nil
 }
 
-// convertOrderStatus converts Schwab order status to our standard status
-func (c *Client) convertOrderStatus(status string) brokerage.OrderStatus {
+// lookupRawQuote finds the quote for symbol, tolerating callers that pass
+// lower-case symbols since Schwab keys the response in upper case
+func lookupRawQuote(quotes map[string]json.RawMessage, symbol string) (json.RawMessage, bool) {
+	if rawQuote, ok := quotes[symbol]; ok {
+		return rawQuote, true
+	}
+	rawQuote, ok := quotes[strings.ToUpper(symbol)]
+	return rawQuote, ok
+}
+
+// schwabQuote mirrors the per-symbol object returned by the quotes endpoint.
+// Prices Schwab omits (e.g. bid/ask after hours) are left empty and the
+// other fields are pointers, so that missing values can be told apart from
+// real zeros.
+type schwabQuote struct {
+	Symbol string `json:"symbol"`
+	Quote  struct {
+		BidPrice    json.Number `json:"bidPrice"`
+		AskPrice    json.Number `json:"askPrice"`
+		LastPrice   json.Number `json:"lastPrice"`
+		ClosePrice  json.Number `json:"closePrice"`
+		TotalVolume *int64      `json:"totalVolume"`
+		QuoteTime   *int64      `json:"quoteTime"`
+		TradeTime   *int64      `json:"tradeTime"`
+	} `json:"quote"`
+	Extended *struct {
+		BidPrice  json.Number `json:"bidPrice"`
+		AskPrice  json.Number `json:"askPrice"`
+		LastPrice json.Number `json:"lastPrice"`
+		QuoteTime *int64      `json:"quoteTime"`
+		TradeTime *int64      `json:"tradeTime"`
+	} `json:"extended"`
+	Reference struct {
+		Description string `json:"description"`
+	} `json:"reference"`
+}
+
+// parseQuote converts a single Schwab quote object into a brokerage.Quote.
+// Missing prices are left at zero, except Last which falls back to the close
+// price so symbols that only report a close (after hours, mutual funds) still
+// carry a usable price.
+func parseQuote(symbol string, raw json.RawMessage) (*brokerage.Quote, error) {
+	var sq schwabQuote
+	if err := json.Unmarshal(raw, &sq); err != nil {
+		return nil, fmt.Errorf("failed to parse quote for %s: %w", symbol, err)
+	}
+
+	quote := &brokerage.Quote{
+		Symbol:      symbol,
+		Description: sq.Reference.Description,
+		Raw:         string(raw),
+	}
+	if sq.Symbol != "" {
+		quote.Symbol = sq.Symbol
+	}
+
+	quote.Bid = decimalFrom(sq.Quote.BidPrice)
+	quote.Ask = decimalFrom(sq.Quote.AskPrice)
+	quote.Close = decimalFrom(sq.Quote.ClosePrice)
+	if sq.Quote.LastPrice != "" {
+		quote.Last = decimalFrom(sq.Quote.LastPrice)
+	} else {
+		quote.Last = quote.Close
+	}
+	if sq.Quote.TotalVolume != nil {
+		quote.Volume = *sq.Quote.TotalVolume
+	}
+
+	switch {
+	case sq.Quote.QuoteTime != nil:
+		quote.Timestamp = time.UnixMilli(*sq.Quote.QuoteTime)
+	case sq.Quote.TradeTime != nil:
+		quote.Timestamp = time.UnixMilli(*sq.Quote.TradeTime)
+	}
+
+	if ext := sq.Extended; ext != nil {
+		quote.Extended = &brokerage.ExtendedQuote{
+			Bid:  decimalFrom(ext.BidPrice),
+			Ask:  decimalFrom(ext.AskPrice),
+			Last: decimalFrom(ext.LastPrice),
+		}
+		switch {
+		case ext.QuoteTime != nil:
+			quote.Extended.Timestamp = time.UnixMilli(*ext.QuoteTime)
+		case ext.TradeTime != nil:
+			quote.Extended.Timestamp = time.UnixMilli(*ext.TradeTime)
+		}
+	}
+
+	return quote, nil
+}
+
+// schwabOrder is an order as returned by the Schwab orders endpoints
+type schwabOrder struct {
+	OrderID            int64       `json:"orderId"`
+	Status             string      `json:"status"`
+	Quantity           json.Number `json:"quantity"`
+	FilledQuantity     json.Number `json:"filledQuantity"`
+	Price              json.Number `json:"price"`
+	StopPrice          json.Number `json:"stopPrice"`
+	OrderType          string      `json:"orderType"`
+	EnteredTime        string      `json:"enteredTime"`
+	OrderStrategyType  string      `json:"orderStrategyType"`
+	OrderLegCollection []struct {
+		Instruction string `json:"instruction"`
+		Instrument  struct {
+			Symbol string `json:"symbol"`
+		} `json:"instrument"`
+	} `json:"orderLegCollection"`
+	OrderActivityCollection []struct {
+		ActivityType  string `json:"activityType"`
+		ExecutionLegs []struct {
+			Price    json.Number `json:"price"`
+			Quantity json.Number `json:"quantity"`
+			Time     string      `json:"time"`
+		} `json:"executionLegs"`
+	} `json:"orderActivityCollection"`
+	ChildOrderStrategies []schwabOrder `json:"childOrderStrategies"`
+}
+
+// convertOrder converts a Schwab order to our standard order. Fill details
+// come from the order's executions: FilledPrice is their volume-weighted
+// average price and FilledAt the time of the last one. The orders of an OCO
+// or TRIGGER strategy are converted into Children.
+func (c *Client) convertOrder(so schwabOrder) brokerage.Order {
+	order := brokerage.Order{
+		ID:        fmt.Sprintf("%d", so.OrderID),
+		Status:    c.convertOrderStatus(so.Status, decimalFrom(so.FilledQuantity)),
+		RawStatus: so.Status,
+		Quantity:  decimalFrom(so.Quantity),
+		FilledQty: decimalFrom(so.FilledQuantity),
+		Type:      brokerage.OrderType(so.OrderType),
+		Strategy:  brokerage.OrderStrategyType(so.OrderStrategyType),
+	}
+
+	for _, child := range so.ChildOrderStrategies {
+		order.Children = append(order.Children, c.convertOrder(child))
+	}
+
+	if order.Type == brokerage.OrderTypeLimit {
+		order.LimitPrice = brokerage.DecimalPtr(decimalFrom(so.Price))
+	}
+	if order.Type == brokerage.OrderTypeStop {
+		order.StopPrice = brokerage.DecimalPtr(decimalFrom(so.StopPrice))
+	}
+
+	if len(so.OrderLegCollection) > 0 {
+		order.Symbol = so.OrderLegCollection[0].Instrument.Symbol
+		order.Action = brokerage.OrderAction(so.OrderLegCollection[0].Instruction)
+	}
+
+	if so.EnteredTime != "" {
+		if t, err := parseSchwabTime(so.EnteredTime); err == nil {
+			order.SubmittedAt = t
+		}
+	}
+
+	var filledQty, filledValue decimal.Decimal
+	var lastFill time.Time
+	for _, activity := range so.OrderActivityCollection {
+		if activity.ActivityType != "EXECUTION" {
+			continue
+		}
+		for _, leg := range activity.ExecutionLegs {
+			quantity := decimalFrom(leg.Quantity)
+			filledQty = filledQty.Add(quantity)
+			filledValue = filledValue.Add(quantity.Mul(decimalFrom(leg.Price)))
+			if t, err := parseSchwabTime(leg.Time); err == nil && t.After(lastFill) {
+				lastFill = t
+			}
+		}
+	}
+
+	if filledQty.IsPositive() {
+		order.FilledQty = filledQty
+		order.FilledPrice = filledValue.Div(filledQty)
+	}
+	if !lastFill.IsZero() {
+		order.FilledAt = &lastFill
+	}
+
+	return order
+}
+
+// convertOrderStatus converts Schwab order status to our standard status.
+// Schwab has no partially filled status, a working order with a filled
+// quantity is reported as one.
+func (c *Client) convertOrderStatus(status string, filledQty decimal.Decimal) brokerage.OrderStatus {
 	switch strings.ToUpper(status) {
+	case "AWAITING_PARENT_ORDER", "AWAITING_CONDITION", "AWAITING_STOP_CONDITION",
+		"AWAITING_MANUAL_REVIEW", "AWAITING_UR_OUT", "AWAITING_RELEASE_TIME",
+		"ACCEPTED", "PENDING_ACTIVATION", "PENDING_ACKNOWLEDGEMENT", "QUEUED", "NEW":
+		return brokerage.OrderStatusPending
+	case "WORKING", "PENDING_CANCEL", "PENDING_REPLACE", "PENDING_RECALL":
+		// Pending cancels and replaces can still fill until Schwab confirms them
+		if filledQty.IsPositive() {
+			return brokerage.OrderStatusPartiallyFilled
+		}
+		return brokerage.OrderStatusWorking
 	case "FILLED":
 		return brokerage.OrderStatusFilled
 	case "CANCELED", "CANCELLED":
 		return brokerage.OrderStatusCancelled
 	case "REJECTED":
 		return brokerage.OrderStatusRejected
+	case "EXPIRED":
+		return brokerage.OrderStatusExpired
+	case "REPLACED":
+		return brokerage.OrderStatusReplaced
 	default:
-		return brokerage.OrderStatusPending
+		return brokerage.OrderStatusUnknown
 	}
 }
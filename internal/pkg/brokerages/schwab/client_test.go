@@ -0,0 +1,1455 @@
+package schwab_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab/schwabtest"
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+// tokenRequestForm returns the form of the last request to the mock's token
+// endpoint, along with its Authorization header
+func tokenRequestForm(t *testing.T, mock *schwabtest.Server) (url.Values, string) {
+	t.Helper()
+
+	req, ok := mock.LastRequest("POST", "/v1/oauth/token")
+	if !ok {
+		t.Fatal("no request reached the token endpoint")
+	}
+	form, err := url.ParseQuery(string(req.Body))
+	if err != nil {
+		t.Fatalf("failed to parse token request body %q: %v", req.Body, err)
+	}
+	return form, req.Header.Get("Authorization")
+}
+
+func TestRefreshTokenClientAuthentication(t *testing.T) {
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("test-client-id:test-client-secret"))
+
+	tests := []struct {
+		name         string
+		clientSecret string
+		wantAuth     string
+		wantClientID string
+	}{
+		{name: "confidential client uses basic auth", clientSecret: "test-client-secret", wantAuth: basic},
+		{name: "public client sends client_id", wantClientID: "test-client-id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := schwabtest.NewServer()
+			defer mock.Close()
+			config := mock.Config()
+			config.ClientSecret = tt.clientSecret
+			client := mock.NewClientWithConfig(config)
+
+			if err := client.Refresh(t.Context()); err != nil {
+				t.Fatalf("Refresh() error = %v", err)
+			}
+
+			form, auth := tokenRequestForm(t, mock)
+			if auth != tt.wantAuth {
+				t.Errorf("Authorization = %q, want %q", auth, tt.wantAuth)
+			}
+			if got := form.Get("client_id"); got != tt.wantClientID {
+				t.Errorf("client_id = %q, want %q", got, tt.wantClientID)
+			}
+			if got := form.Get("grant_type"); got != "refresh_token" {
+				t.Errorf("grant_type = %q, want refresh_token", got)
+			}
+			if got := form.Get("refresh_token"); got != schwabtest.RefreshToken {
+				t.Errorf("refresh_token = %q, want %q", got, schwabtest.RefreshToken)
+			}
+		})
+	}
+}
+
+func TestAuthURLCarriesPKCEChallenge(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient(schwab.WithCodeVerifierGenerator(func() string { return "fixed-verifier" }))
+
+	authURL, state := client.GetAuthURLWithState()
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("failed to parse auth URL %q: %v", authURL, err)
+	}
+	if !strings.HasPrefix(authURL, mock.URL+"/v1/oauth/authorize?") {
+		t.Errorf("auth URL = %q, want it on the configured AuthURL", authURL)
+	}
+
+	sum := sha256.Sum256([]byte("fixed-verifier"))
+	want := map[string]string{
+		"client_id":             "test-client-id",
+		"redirect_uri":          "https://127.0.0.1:8080",
+		"response_type":         "code",
+		"code_challenge":        base64.RawURLEncoding.EncodeToString(sum[:]),
+		"code_challenge_method": "S256",
+		"state":                 state,
+	}
+	query := parsed.Query()
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+	if state == "" {
+		t.Error("state is empty, want a random value")
+	}
+}
+
+func TestExchangeAuthCodeSendsCodeVerifier(t *testing.T) {
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("test-client-id:test-client-secret"))
+
+	tests := []struct {
+		name         string
+		clientSecret string
+		wantAuth     string
+		wantClientID string
+	}{
+		{name: "confidential client", clientSecret: "test-client-secret", wantAuth: basic},
+		{name: "public client", wantClientID: "test-client-id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := schwabtest.NewServer()
+			defer mock.Close()
+			config := mock.Config()
+			config.ClientSecret = tt.clientSecret
+			client := mock.NewClientWithConfig(config,
+				schwab.WithCodeVerifierGenerator(func() string { return "fixed-verifier" }))
+
+			client.GetAuthURLWithState()
+			if err := client.ExchangeAuthCodeForAccessToken(t.Context(), schwabtest.AuthCode); err != nil {
+				t.Fatalf("ExchangeAuthCodeForAccessToken() error = %v", err)
+			}
+
+			form, auth := tokenRequestForm(t, mock)
+			if auth != tt.wantAuth {
+				t.Errorf("Authorization = %q, want %q", auth, tt.wantAuth)
+			}
+			if got := form.Get("client_id"); got != tt.wantClientID {
+				t.Errorf("client_id = %q, want %q", got, tt.wantClientID)
+			}
+			if got := form.Get("code_verifier"); got != "fixed-verifier" {
+				t.Errorf("code_verifier = %q, want fixed-verifier", got)
+			}
+			if got := form.Get("code"); got != schwabtest.AuthCode {
+				t.Errorf("code = %q, want %q", got, schwabtest.AuthCode)
+			}
+		})
+	}
+}
+
+// The OAuth listener exchanges the code on its own goroutine while the
+// caller may be building another authorization URL; run with -race
+func TestAuthURLAndExchangeConcurrently(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			client.GetAuthURLWithState()
+		}()
+		go func() {
+			defer wg.Done()
+			if err := client.ExchangeAuthCodeForAccessToken(t.Context(), schwabtest.AuthCode); err != nil {
+				t.Errorf("ExchangeAuthCodeForAccessToken() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// tokenWithoutRefreshToken is a token response as Schwab sometimes sends
+// it, with an empty refresh_token
+const tokenWithoutRefreshToken = `{
+	"expires_in": 1800,
+	"token_type": "Bearer",
+	"scope": "api",
+	"refresh_token": "",
+	"access_token": "new-access-token",
+	"id_token": "new-id-token"
+}`
+
+func TestTokenResponseWithoutRefreshTokenKeepsTheOldOne(t *testing.T) {
+	tests := []struct {
+		name     string
+		exchange func(t *testing.T, client *schwab.Client) error
+	}{
+		{
+			name: "refresh",
+			exchange: func(t *testing.T, client *schwab.Client) error {
+				return client.Refresh(t.Context())
+			},
+		},
+		{
+			name: "authorization code",
+			exchange: func(t *testing.T, client *schwab.Client) error {
+				return client.ExchangeAuthCodeForAccessToken(t.Context(), schwabtest.AuthCode)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := schwabtest.NewServer()
+			defer mock.Close()
+			store := &schwab.MemoryTokenStore{}
+			client := mock.NewClient(schwab.WithTokenStore(store))
+			refreshExpiresAt := time.Now().Add(72 * time.Hour).Truncate(time.Second)
+			err := client.SetAccessToken(schwab.Token{
+				AccessToken:           "old-access-token",
+				RefreshToken:          schwabtest.RefreshToken,
+				TokenType:             "Bearer",
+				ExpiresAt:             time.Now().Add(time.Minute),
+				RefreshTokenExpiresAt: refreshExpiresAt,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mock.SetResponse("POST", "/v1/oauth/token", http.StatusOK, tokenWithoutRefreshToken)
+			if err := tt.exchange(t, client); err != nil {
+				t.Fatalf("token exchange error = %v", err)
+			}
+
+			saved, err := store.Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if saved.AccessToken != "new-access-token" {
+				t.Errorf("saved access token = %q, want new-access-token", saved.AccessToken)
+			}
+			if saved.RefreshToken != schwabtest.RefreshToken {
+				t.Errorf("saved refresh token = %q, want the previous %q", saved.RefreshToken, schwabtest.RefreshToken)
+			}
+			if !saved.RefreshTokenExpiresAt.Equal(refreshExpiresAt) {
+				t.Errorf("saved RefreshTokenExpiresAt = %s, want the previous %s", saved.RefreshTokenExpiresAt, refreshExpiresAt)
+			}
+
+			// The kept refresh token still works
+			mock.ClearResponse("POST", "/v1/oauth/token")
+			if err := client.Refresh(t.Context()); err != nil {
+				t.Errorf("Refresh() with the kept refresh token error = %v", err)
+			}
+			form, _ := tokenRequestForm(t, mock)
+			if got := form.Get("refresh_token"); got != schwabtest.RefreshToken {
+				t.Errorf("refresh_token sent = %q, want %q", got, schwabtest.RefreshToken)
+			}
+		})
+	}
+}
+
+// countRequests returns how many requests the mock received for method and
+// path
+func countRequests(mock *schwabtest.Server, method, path string) int {
+	n := 0
+	for _, req := range mock.Requests() {
+		if req.Method == method && req.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+// getAccountsConcurrently calls GetAccounts from n goroutines at once and
+// fails the test if any call fails
+func getAccountsConcurrently(t *testing.T, client *schwab.Client, n int) {
+	t.Helper()
+
+	start := make(chan struct{})
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := client.GetAccounts(t.Context())
+			errs <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("GetAccounts() error = %v", err)
+		}
+	}
+}
+
+func TestRefreshMarginBeforeExpiry(t *testing.T) {
+	tests := []struct {
+		name          string
+		elapsed       time.Duration // Since the 30 minute token was issued
+		refreshFails  bool
+		wantRefreshes int
+		wantErr       error // Nil when the request succeeds
+	}{
+		{name: "5m1s left", elapsed: 24*time.Minute + 59*time.Second},
+		{name: "exactly 5m left", elapsed: 25 * time.Minute, wantRefreshes: 1},
+		{name: "expired", elapsed: 31 * time.Minute, wantRefreshes: 1},
+		{name: "5m1s left and refresh fails", elapsed: 24*time.Minute + 59*time.Second, refreshFails: true},
+		{name: "exactly 5m left and refresh fails", elapsed: 25 * time.Minute, refreshFails: true, wantRefreshes: 1},
+		{name: "expired and refresh fails", elapsed: 31 * time.Minute, refreshFails: true, wantRefreshes: 1, wantErr: schwab.ErrTokenExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := schwabtest.NewServer()
+			defer mock.Close()
+			clock := schwabtest.NewClock(clockStart)
+			client := mock.NewClient(schwab.WithClock(clock))
+			token := freshToken(schwabtest.RefreshToken)
+			token.AccessToken = schwabtest.AccessToken
+			if err := client.SetAccessToken(token); err != nil {
+				t.Fatal(err)
+			}
+			if tt.refreshFails {
+				mock.SetResponse("POST", "/v1/oauth/token", http.StatusInternalServerError, `{"error": "server_error"}`)
+			}
+
+			clock.Advance(tt.elapsed)
+			_, err := client.GetQuote(t.Context(), "VTI")
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("GetQuote() error = %v", err)
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Fatalf("GetQuote() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if n := refreshes(mock); n != tt.wantRefreshes {
+				t.Errorf("refresh requests = %d, want %d", n, tt.wantRefreshes)
+			}
+			wantQuotes := 1
+			if tt.wantErr != nil {
+				wantQuotes = 0
+			}
+			if n := countRequests(mock, "GET", "/marketdata/v1/quotes"); n != wantQuotes {
+				t.Errorf("quote requests = %d, want %d", n, wantQuotes)
+			}
+		})
+	}
+}
+
+func TestConcurrentRequestsShareOneRefreshOfAnExpiredToken(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+	err := client.SetAccessToken(schwab.Token{
+		AccessToken:  "expired-access-token",
+		RefreshToken: schwabtest.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresAt:    time.Now().Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getAccountsConcurrently(t, client, 50)
+
+	if n := countRequests(mock, "POST", "/v1/oauth/token"); n != 1 {
+		t.Errorf("token endpoint got %d refresh requests, want 1", n)
+	}
+}
+
+func TestConcurrentRequestsShareOneRefreshAfterUnauthorized(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+	// Unexpired, so nothing refreshes until the mock rejects it
+	err := client.SetAccessToken(schwab.Token{
+		AccessToken:  "revoked-access-token",
+		RefreshToken: schwabtest.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresAt:    time.Now().Add(30 * time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getAccountsConcurrently(t, client, 50)
+
+	if n := countRequests(mock, "POST", "/v1/oauth/token"); n != 1 {
+		t.Errorf("token endpoint got %d refresh requests, want 1", n)
+	}
+}
+
+// tokenCallbacks registers an OnTokenRefreshed callback on client that
+// sends every token it is called with on the returned channel
+func tokenCallbacks(client *schwab.Client) <-chan schwab.Token {
+	tokens := make(chan schwab.Token, 100)
+	client.OnTokenRefreshed(func(token schwab.Token) { tokens <- token })
+	return tokens
+}
+
+// expectCallbacks fails the test unless exactly n tokens, all carrying the
+// mock's access token, arrive on tokens
+func expectCallbacks(t *testing.T, tokens <-chan schwab.Token, n int) {
+	t.Helper()
+
+	for i := range n {
+		select {
+		case token := <-tokens:
+			if token.AccessToken != schwabtest.AccessToken || token.RefreshToken != schwabtest.RefreshToken {
+				t.Errorf("callback %d token = %+v, want the token from the mock", i, token)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("got %d token callbacks, want %d", i, n)
+		}
+	}
+	select {
+	case token := <-tokens:
+		t.Errorf("got an extra token callback with %+v, want %d", token, n)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestOnTokenRefreshedFiresOncePerRefresh(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient(schwab.WithCodeVerifierGenerator(func() string { return "fixed-verifier" }))
+	err := client.SetAccessToken(schwab.Token{
+		AccessToken:  "expired-access-token",
+		RefreshToken: schwabtest.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresAt:    time.Now().Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := tokenCallbacks(client)
+
+	// Fifty requests share one refresh, and so one callback
+	getAccountsConcurrently(t, client, 50)
+	expectCallbacks(t, tokens, 1)
+
+	if err := client.Refresh(t.Context()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	expectCallbacks(t, tokens, 1)
+
+	client.GetAuthURLWithState()
+	if err := client.ExchangeAuthCodeForAccessToken(t.Context(), schwabtest.AuthCode); err != nil {
+		t.Fatalf("ExchangeAuthCodeForAccessToken() error = %v", err)
+	}
+	expectCallbacks(t, tokens, 1)
+
+	// A failed refresh reports no token
+	mock.SetResponse("POST", "/v1/oauth/token", http.StatusBadRequest, `{"error": "invalid_grant"}`)
+	if err := client.Refresh(t.Context()); err == nil {
+		t.Fatal("Refresh() error = nil, want the rejected refresh")
+	}
+	expectCallbacks(t, tokens, 0)
+}
+
+func TestOnTokenRefreshedCallbacksDoNotBlockOrPanic(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	var buf lockedBuffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	client := mock.NewClient(schwab.WithLogger(logger))
+
+	release := make(chan struct{})
+	defer close(release)
+	client.OnTokenRefreshed(func(schwab.Token) { <-release })
+	client.OnTokenRefreshed(func(schwab.Token) { panic("secrets manager unavailable") })
+	tokens := tokenCallbacks(client)
+
+	if err := client.Refresh(t.Context()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	// The blocked callback holds up neither requests nor the callbacks after it
+	if _, err := client.GetAccounts(t.Context()); err != nil {
+		t.Fatalf("GetAccounts() error = %v while a callback is blocked", err)
+	}
+	expectCallbacks(t, tokens, 1)
+
+	eventually(t, "the callback panic to be logged", func() bool {
+		return strings.Contains(buf.String(), `"msg":"schwab token refresh callback panicked","panic":"secrets manager unavailable"`)
+	})
+}
+
+// lockedBuffer is a bytes.Buffer safe for concurrent use, for loggers
+// written to from callback goroutines
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// flakyClient returns a client for mock whose first API requests with
+// method are answered with statuses instead of reaching the mock, 429s with
+// a zero Retry-After. Other requests, and every token request, reach the
+// mock.
+func flakyClient(t *testing.T, mock *schwabtest.Server, config schwab.Config, method string, statuses ...int) *schwab.Client {
+	t.Helper()
+
+	target, err := url.Parse(mock.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	var served atomic.Int32
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			proxy.ServeHTTP(w, r)
+			return
+		}
+		if n := int(served.Add(1)); n <= len(statuses) {
+			if statuses[n-1] == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "0")
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(statuses[n-1])
+			w.Write([]byte(`{"message":"` + http.StatusText(statuses[n-1]) + `"}`))
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(front.Close)
+
+	config.BaseURL = front.URL
+	config.RetryBaseDelayMillis = 1
+	return mock.NewClientWithConfig(config)
+}
+
+// requireJSONEqual fails the test unless got and want encode the same JSON
+// value, whatever the order of their keys
+func requireJSONEqual(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var gotValue, wantValue any
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("invalid JSON %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("invalid expected JSON %s: %v", want, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("JSON = %s\nwant %s", got, want)
+	}
+}
+
+// placeLimitOrder places a day limit buy of quantity VTI at limit and
+// returns its ID
+func placeLimitOrder(t *testing.T, client *schwab.Client, quantity int64, limit string) string {
+	t.Helper()
+
+	order, err := client.PlaceOrder(t.Context(), schwabtest.AccountNumber, brokerage.OrderRequest{
+		Symbol:     "VTI",
+		Action:     brokerage.OrderActionBuy,
+		Type:       brokerage.OrderTypeLimit,
+		Quantity:   decimal.NewFromInt(quantity),
+		LimitPrice: brokerage.DecimalPtr(decimal.RequireFromString(limit)),
+		Duration:   brokerage.OrderDurationDay,
+	})
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	return order.ID
+}
+
+func TestGetAccounts(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	mock.SetCash(5000)
+	mock.SetPositions(schwabtest.Position{Symbol: "VTI", Quantity: 10, AveragePrice: 200})
+	client := mock.NewClient()
+
+	accounts, err := client.GetAccounts(t.Context())
+	if err != nil {
+		t.Fatalf("GetAccounts() error = %v", err)
+	}
+	if len(accounts) != 1 {
+		t.Fatalf("GetAccounts() = %+v, want one account", accounts)
+	}
+
+	got := accounts[0]
+	if got.AccountNumber != schwabtest.AccountNumber || got.AccountHash != schwabtest.AccountHash {
+		t.Errorf("account number and hash = %q, %q, want %q, %q",
+			got.AccountNumber, got.AccountHash, schwabtest.AccountNumber, schwabtest.AccountHash)
+	}
+	if got.ID() != schwabtest.AccountHash {
+		t.Errorf("ID() = %q, want the hash", got.ID())
+	}
+	if got.Nickname != "Test Brokerage" || got.Type != "MARGIN" || got.Kind != brokerage.AccountKindMargin {
+		t.Errorf("nickname, type and kind = %q, %q, %q, want Test Brokerage, MARGIN, MARGIN", got.Nickname, got.Type, got.Kind)
+	}
+	for name, value := range map[string]struct{ got, want decimal.Decimal }{
+		"CashBalance": {got.CashBalance, decimal.NewFromInt(5000)},
+		"SettledCash": {got.SettledCash, decimal.NewFromInt(5000)},
+		"BuyingPower": {got.BuyingPower, decimal.NewFromInt(5000)},
+		"MarketValue": {got.MarketValue, decimal.NewFromInt(2500)},
+		"TotalValue":  {got.TotalValue, decimal.NewFromInt(7500)},
+	} {
+		if !value.got.Equal(value.want) {
+			t.Errorf("%s = %s, want %s", name, value.got, value.want)
+		}
+	}
+}
+
+func TestGetPositions(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	mock.SetPositions(
+		schwabtest.Position{Symbol: "VTI", Description: "Vanguard Total Stock Market", Quantity: 10, AveragePrice: 200, DayPL: 12.5},
+		schwabtest.Position{Symbol: "VXUS", Quantity: 4.5, AveragePrice: 55},
+	)
+	client := mock.NewClient()
+
+	positions, err := client.GetPositions(t.Context(), schwabtest.AccountNumber)
+	if err != nil {
+		t.Fatalf("GetPositions() error = %v", err)
+	}
+	if len(positions) != 2 {
+		t.Fatalf("GetPositions() = %+v, want two positions", positions)
+	}
+
+	vti := positions[0]
+	if vti.Symbol != "VTI" || vti.Description != "Vanguard Total Stock Market" || vti.AssetType != brokerage.AssetTypeEquity {
+		t.Errorf("VTI = %+v", vti)
+	}
+	if !vti.Quantity.Equal(decimal.NewFromInt(10)) || !vti.AveragePrice.Equal(decimal.NewFromInt(200)) ||
+		!vti.MarketValue.Equal(decimal.NewFromInt(2500)) || !vti.DayPL.Equal(decimal.RequireFromString("12.5")) {
+		t.Errorf("VTI quantity, price, value and day P/L = %s, %s, %s, %s, want 10, 200, 2500, 12.5",
+			vti.Quantity, vti.AveragePrice, vti.MarketValue, vti.DayPL)
+	}
+	if vxus := positions[1]; vxus.Symbol != "VXUS" || !vxus.Quantity.Equal(decimal.RequireFromString("4.5")) {
+		t.Errorf("VXUS = %+v, want 4.5 shares", vxus)
+	}
+
+	req, _ := mock.LastRequest("GET", "/trader/v1/accounts/"+schwabtest.AccountHash)
+	if req.Query != "fields=positions" {
+		t.Errorf("positions query = %q, want fields=positions", req.Query)
+	}
+}
+
+func TestAccountNumbersResolveToHashes(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+
+	// Both the plain number and the hash reach the hash's path, and the
+	// mapping is fetched only once
+	for _, accountID := range []string{schwabtest.AccountNumber, schwabtest.AccountHash, schwabtest.AccountNumber} {
+		if _, err := client.GetPositions(t.Context(), accountID); err != nil {
+			t.Fatalf("GetPositions(%q) error = %v", accountID, err)
+		}
+	}
+
+	if n := countRequests(mock, "GET", "/trader/v1/accounts/"+schwabtest.AccountHash); n != 3 {
+		t.Errorf("requests to the hash's path = %d, want 3", n)
+	}
+	if n := countRequests(mock, "GET", "/trader/v1/accounts/"+schwabtest.AccountNumber); n != 0 {
+		t.Errorf("requests to the plain number's path = %d, want 0", n)
+	}
+	if n := countRequests(mock, "GET", "/trader/v1/accounts/accountNumbers"); n != 1 {
+		t.Errorf("account number lookups = %d, want 1", n)
+	}
+}
+
+func TestPlaceOrderSendsSchwabOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		order brokerage.OrderRequest
+		want  string
+	}{
+		{
+			name: "market",
+			order: brokerage.OrderRequest{
+				Symbol:   "VTI",
+				Action:   brokerage.OrderActionBuy,
+				Type:     brokerage.OrderTypeMarket,
+				Quantity: decimal.NewFromInt(3),
+			},
+			want: `{
+				"orderType": "MARKET",
+				"session": "NORMAL",
+				"duration": "DAY",
+				"orderStrategyType": "SINGLE",
+				"orderLegCollection": [{
+					"instruction": "BUY",
+					"quantity": 3,
+					"instrument": {"symbol": "VTI", "assetType": "EQUITY"}
+				}]
+			}`,
+		},
+		{
+			name: "good till cancel limit",
+			order: brokerage.OrderRequest{
+				Symbol:     "VXUS",
+				Action:     brokerage.OrderActionSell,
+				Type:       brokerage.OrderTypeLimit,
+				Quantity:   decimal.NewFromInt(12),
+				LimitPrice: brokerage.DecimalPtr(decimal.RequireFromString("61.25")),
+				Duration:   brokerage.OrderDurationGTC,
+			},
+			want: `{
+				"orderType": "LIMIT",
+				"session": "NORMAL",
+				"duration": "GOOD_TILL_CANCEL",
+				"orderStrategyType": "SINGLE",
+				"price": 61.25,
+				"orderLegCollection": [{
+					"instruction": "SELL",
+					"quantity": 12,
+					"instrument": {"symbol": "VXUS", "assetType": "EQUITY"}
+				}]
+			}`,
+		},
+		{
+			name: "pre-market limit",
+			order: brokerage.OrderRequest{
+				Symbol:     "VTI",
+				Action:     brokerage.OrderActionBuy,
+				Type:       brokerage.OrderTypeLimit,
+				Quantity:   decimal.NewFromInt(2),
+				LimitPrice: brokerage.DecimalPtr(decimal.RequireFromString("279.5")),
+				Session:    brokerage.OrderSessionAM,
+			},
+			want: `{
+				"orderType": "LIMIT",
+				"session": "AM",
+				"duration": "DAY",
+				"orderStrategyType": "SINGLE",
+				"price": 279.5,
+				"orderLegCollection": [{
+					"instruction": "BUY",
+					"quantity": 2,
+					"instrument": {"symbol": "VTI", "assetType": "EQUITY"}
+				}]
+			}`,
+		},
+		{
+			name: "after hours limit",
+			order: brokerage.OrderRequest{
+				Symbol:     "VTI",
+				Action:     brokerage.OrderActionSell,
+				Type:       brokerage.OrderTypeLimit,
+				Quantity:   decimal.NewFromInt(2),
+				LimitPrice: brokerage.DecimalPtr(decimal.RequireFromString("281")),
+				Duration:   brokerage.OrderDurationDay,
+				Session:    brokerage.OrderSessionPM,
+			},
+			want: `{
+				"orderType": "LIMIT",
+				"session": "PM",
+				"duration": "DAY",
+				"orderStrategyType": "SINGLE",
+				"price": 281,
+				"orderLegCollection": [{
+					"instruction": "SELL",
+					"quantity": 2,
+					"instrument": {"symbol": "VTI", "assetType": "EQUITY"}
+				}]
+			}`,
+		},
+		{
+			name: "seamless good till cancel limit",
+			order: brokerage.OrderRequest{
+				Symbol:     "BND",
+				Action:     brokerage.OrderActionBuy,
+				Type:       brokerage.OrderTypeLimit,
+				Quantity:   decimal.NewFromInt(5),
+				LimitPrice: brokerage.DecimalPtr(decimal.RequireFromString("72.1")),
+				Duration:   brokerage.OrderDurationGTC,
+				Session:    brokerage.OrderSessionSeamless,
+			},
+			want: `{
+				"orderType": "LIMIT",
+				"session": "SEAMLESS",
+				"duration": "GOOD_TILL_CANCEL",
+				"orderStrategyType": "SINGLE",
+				"price": 72.1,
+				"orderLegCollection": [{
+					"instruction": "BUY",
+					"quantity": 5,
+					"instrument": {"symbol": "BND", "assetType": "EQUITY"}
+				}]
+			}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := schwabtest.NewServer()
+			defer mock.Close()
+			client := mock.NewClient()
+
+			order, err := client.PlaceOrder(t.Context(), schwabtest.AccountNumber, tt.order)
+			if err != nil {
+				t.Fatalf("PlaceOrder() error = %v", err)
+			}
+			if order.ID != "1001" || order.Status != brokerage.OrderStatusPending {
+				t.Errorf("order ID and status = %q, %s, want 1001 from the Location header, PENDING", order.ID, order.Status)
+			}
+
+			req, ok := mock.LastRequest("POST", "/trader/v1/accounts/"+schwabtest.AccountHash+"/orders")
+			if !ok {
+				t.Fatal("no order reached the mock")
+			}
+			if got := req.Header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			requireJSONEqual(t, req.Body, tt.want)
+		})
+	}
+}
+
+func TestPlaceOrderRejectsDurationAndSession(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration brokerage.OrderDuration
+		session  brokerage.OrderSession
+		wantErr  string
+	}{
+		{name: "market pre-market", session: brokerage.OrderSessionAM, wantErr: "market orders are not accepted in the AM session, use a limit order"},
+		{name: "market after hours", session: brokerage.OrderSessionPM, wantErr: "market orders are not accepted in the PM session, use a limit order"},
+		{name: "market seamless", session: brokerage.OrderSessionSeamless, wantErr: "market orders are not accepted in the SEAMLESS session, use a limit order"},
+		{name: "market good till cancel", duration: brokerage.OrderDurationGTC, wantErr: "market orders must be DAY orders, got GTC"},
+		{name: "unknown duration", duration: "FILL_OR_KILL", wantErr: `unsupported order duration "FILL_OR_KILL"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := schwabtest.NewServer()
+			defer mock.Close()
+			client := mock.NewClient()
+
+			_, err := client.PlaceOrder(t.Context(), schwabtest.AccountNumber, brokerage.OrderRequest{
+				Symbol:   "VTI",
+				Action:   brokerage.OrderActionBuy,
+				Type:     brokerage.OrderTypeMarket,
+				Quantity: decimal.NewFromInt(3),
+				Duration: tt.duration,
+				Session:  tt.session,
+			})
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("PlaceOrder() error = %v, want %q", err, tt.wantErr)
+			}
+			if n := countRequests(mock, "POST", "/trader/v1/accounts/"+schwabtest.AccountHash+"/orders"); n != 0 {
+				t.Errorf("sent %d orders, want the order rejected before reaching Schwab", n)
+			}
+		})
+	}
+}
+
+func TestGetOrderStatus(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+	id := placeLimitOrder(t, client, 4, "249.50")
+
+	order, err := client.GetOrderStatus(t.Context(), schwabtest.AccountNumber, id)
+	if err != nil {
+		t.Fatalf("GetOrderStatus() error = %v", err)
+	}
+	if order.Status != brokerage.OrderStatusWorking || order.Symbol != "VTI" || order.Action != brokerage.OrderActionBuy ||
+		!order.Quantity.Equal(decimal.NewFromInt(4)) || order.LimitPrice == nil || !order.LimitPrice.Equal(decimal.RequireFromString("249.5")) {
+		t.Errorf("working order = %+v", order)
+	}
+
+	if err := mock.SetOrderStatus(id, "FILLED", 249.25); err != nil {
+		t.Fatal(err)
+	}
+	order, err = client.GetOrderStatus(t.Context(), schwabtest.AccountNumber, id)
+	if err != nil {
+		t.Fatalf("GetOrderStatus() error = %v", err)
+	}
+	if order.Status != brokerage.OrderStatusFilled || !order.FilledQty.Equal(decimal.NewFromInt(4)) ||
+		!order.FilledPrice.Equal(decimal.RequireFromString("249.25")) || order.FilledAt == nil {
+		t.Errorf("filled order = %+v, want 4 filled at 249.25", order)
+	}
+
+	_, err = client.GetOrderStatus(t.Context(), schwabtest.AccountNumber, "999")
+	var apiErr *schwab.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "Order not found" {
+		t.Errorf("GetOrderStatus() of an unknown order error = %v, want a 404 APIError", err)
+	}
+}
+
+// orderRequestPath is where GetOrderStatus fetches order 1001 from
+const orderRequestPath = "/trader/v1/accounts/" + schwabtest.AccountHash + "/orders/1001"
+
+// orderFixture is a Schwab limit buy of 10 VTI as order 1001 with status,
+// filledQuantity and the given orderActivityCollection entries
+func orderFixture(status, filledQuantity string, activities ...string) string {
+	return `{
+		"orderId": 1001,
+		"status": "` + status + `",
+		"orderType": "LIMIT",
+		"orderStrategyType": "SINGLE",
+		"quantity": 10,
+		"filledQuantity": ` + filledQuantity + `,
+		"price": 100.5,
+		"enteredTime": "2025-03-10T14:30:00+0000",
+		"orderLegCollection": [{"instruction": "BUY", "quantity": 10, "instrument": {"symbol": "VTI", "assetType": "EQUITY"}}],
+		"orderActivityCollection": [` + strings.Join(activities, ",") + `]
+	}`
+}
+
+func TestGetOrderStatusConvertsStatus(t *testing.T) {
+	tests := []struct {
+		status         string
+		filledQuantity string
+		want           brokerage.OrderStatus
+	}{
+		{"AWAITING_PARENT_ORDER", "0", brokerage.OrderStatusPending},
+		{"AWAITING_CONDITION", "0", brokerage.OrderStatusPending},
+		{"AWAITING_STOP_CONDITION", "0", brokerage.OrderStatusPending},
+		{"AWAITING_MANUAL_REVIEW", "0", brokerage.OrderStatusPending},
+		{"AWAITING_UR_OUT", "0", brokerage.OrderStatusPending},
+		{"AWAITING_RELEASE_TIME", "0", brokerage.OrderStatusPending},
+		{"ACCEPTED", "0", brokerage.OrderStatusPending},
+		{"PENDING_ACTIVATION", "0", brokerage.OrderStatusPending},
+		{"PENDING_ACKNOWLEDGEMENT", "0", brokerage.OrderStatusPending},
+		{"QUEUED", "0", brokerage.OrderStatusPending},
+		{"NEW", "0", brokerage.OrderStatusPending},
+		{"WORKING", "0", brokerage.OrderStatusWorking},
+		{"WORKING", "4", brokerage.OrderStatusPartiallyFilled},
+		{"PENDING_CANCEL", "0", brokerage.OrderStatusWorking},
+		{"PENDING_CANCEL", "4", brokerage.OrderStatusPartiallyFilled},
+		{"PENDING_REPLACE", "0", brokerage.OrderStatusWorking},
+		{"PENDING_REPLACE", "4", brokerage.OrderStatusPartiallyFilled},
+		{"PENDING_RECALL", "0", brokerage.OrderStatusWorking},
+		{"PENDING_RECALL", "4", brokerage.OrderStatusPartiallyFilled},
+		{"FILLED", "10", brokerage.OrderStatusFilled},
+		{"CANCELED", "0", brokerage.OrderStatusCancelled},
+		{"CANCELLED", "0", brokerage.OrderStatusCancelled},
+		{"REJECTED", "0", brokerage.OrderStatusRejected},
+		{"EXPIRED", "0", brokerage.OrderStatusExpired},
+		{"REPLACED", "0", brokerage.OrderStatusReplaced},
+		{"working", "0", brokerage.OrderStatusWorking},
+		{"UNKNOWN", "0", brokerage.OrderStatusUnknown},
+		{"HALTED", "0", brokerage.OrderStatusUnknown},
+		{"", "0", brokerage.OrderStatusUnknown},
+	}
+
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+
+	for _, tt := range tests {
+		t.Run(tt.status+" filled "+tt.filledQuantity, func(t *testing.T) {
+			mock.SetResponse("GET", orderRequestPath, http.StatusOK, orderFixture(tt.status, tt.filledQuantity))
+
+			order, err := client.GetOrderStatus(t.Context(), schwabtest.AccountNumber, "1001")
+			if err != nil {
+				t.Fatalf("GetOrderStatus() error = %v", err)
+			}
+			if order.Status != tt.want || order.RawStatus != tt.status {
+				t.Errorf("GetOrderStatus() status = %s (raw %q), want %s (raw %q)", order.Status, order.RawStatus, tt.want, tt.status)
+			}
+		})
+	}
+}
+
+// execution is an EXECUTION activity of legs given as price, quantity and
+// time triples
+func execution(legs ...string) string {
+	var executionLegs []string
+	for i := 0; i+2 < len(legs); i += 3 {
+		executionLegs = append(executionLegs, fmt.Sprintf(`{"legId": 1, "price": %s, "quantity": %s, "time": %q}`, legs[i], legs[i+1], legs[i+2]))
+	}
+	return `{"activityType": "EXECUTION", "executionType": "FILL", "executionLegs": [` + strings.Join(executionLegs, ",") + `]}`
+}
+
+func TestGetOrderStatusFillPrice(t *testing.T) {
+	tests := []struct {
+		name            string
+		fixture         string
+		wantStatus      brokerage.OrderStatus
+		wantFilledQty   string
+		wantFilledPrice string
+		wantFilledAt    time.Time // Zero when FilledAt should be nil
+	}{
+		{
+			name:            "single fill",
+			fixture:         orderFixture("FILLED", "10", execution("100.25", "10", "2025-03-10T14:31:05+0000")),
+			wantStatus:      brokerage.OrderStatusFilled,
+			wantFilledQty:   "10",
+			wantFilledPrice: "100.25",
+			wantFilledAt:    time.Date(2025, time.March, 10, 14, 31, 5, 0, time.UTC),
+		},
+		{
+			name: "fills at different prices",
+			fixture: orderFixture("FILLED", "10",
+				execution("100.1", "3", "2025-03-10T14:31:05+0000", "100.2", "5", "2025-03-10T14:31:07+0000"),
+				`{"activityType": "ORDER_ACTION", "executionType": "ROUTE"}`,
+				execution("100.3", "2", "2025-03-10T14:32:00+0000")),
+			wantStatus: brokerage.OrderStatusFilled,
+			// (3 × 100.1 + 5 × 100.2 + 2 × 100.3) / 10
+			wantFilledQty:   "10",
+			wantFilledPrice: "100.19",
+			wantFilledAt:    time.Date(2025, time.March, 10, 14, 32, 0, 0, time.UTC),
+		},
+		{
+			name:            "partial fractional fills",
+			fixture:         orderFixture("WORKING", "2", execution("200.02", "0.5", "2025-03-10T15:00:00+0000", "200.1", "1.5", "2025-03-10T14:59:00+0000")),
+			wantStatus:      brokerage.OrderStatusPartiallyFilled,
+			wantFilledQty:   "2",
+			wantFilledPrice: "200.08",
+			wantFilledAt:    time.Date(2025, time.March, 10, 15, 0, 0, 0, time.UTC),
+		},
+		{
+			name:            "unfilled",
+			fixture:         orderFixture("WORKING", "0"),
+			wantStatus:      brokerage.OrderStatusWorking,
+			wantFilledQty:   "0",
+			wantFilledPrice: "0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := schwabtest.NewServer()
+			defer mock.Close()
+			mock.SetResponse("GET", orderRequestPath, http.StatusOK, tt.fixture)
+			client := mock.NewClient()
+
+			order, err := client.GetOrderStatus(t.Context(), schwabtest.AccountNumber, "1001")
+			if err != nil {
+				t.Fatalf("GetOrderStatus() error = %v", err)
+			}
+			if order.Status != tt.wantStatus {
+				t.Errorf("Status = %s, want %s", order.Status, tt.wantStatus)
+			}
+			if !order.FilledQty.Equal(decimal.RequireFromString(tt.wantFilledQty)) {
+				t.Errorf("FilledQty = %s, want %s", order.FilledQty, tt.wantFilledQty)
+			}
+			if !order.FilledPrice.Equal(decimal.RequireFromString(tt.wantFilledPrice)) {
+				t.Errorf("FilledPrice = %s, want exactly %s", order.FilledPrice, tt.wantFilledPrice)
+			}
+			switch {
+			case tt.wantFilledAt.IsZero() && order.FilledAt != nil:
+				t.Errorf("FilledAt = %s, want nil", order.FilledAt)
+			case !tt.wantFilledAt.IsZero() && (order.FilledAt == nil || !order.FilledAt.Equal(tt.wantFilledAt)):
+				t.Errorf("FilledAt = %v, want %s", order.FilledAt, tt.wantFilledAt)
+			}
+		})
+	}
+}
+
+func TestCancelPendingOrder(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+	id := placeLimitOrder(t, client, 4, "200")
+
+	if err := client.CancelPendingOrder(t.Context(), schwabtest.AccountNumber, id); err != nil {
+		t.Fatalf("CancelPendingOrder() error = %v", err)
+	}
+	order, err := client.GetOrderStatus(t.Context(), schwabtest.AccountNumber, id)
+	if err != nil {
+		t.Fatalf("GetOrderStatus() error = %v", err)
+	}
+	if order.Status != brokerage.OrderStatusCancelled {
+		t.Errorf("status after cancelling = %s, want CANCELLED", order.Status)
+	}
+
+	if err := client.CancelPendingOrder(t.Context(), schwabtest.AccountNumber, id); !errors.Is(err, brokerage.ErrOrderNotOpen) {
+		t.Errorf("second CancelPendingOrder() error = %v, want ErrOrderNotOpen", err)
+	}
+}
+
+func TestReplaceOrder(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+	id := placeLimitOrder(t, client, 4, "200")
+
+	replacement := brokerage.OrderRequest{
+		Symbol:     "VTI",
+		Action:     brokerage.OrderActionBuy,
+		Type:       brokerage.OrderTypeLimit,
+		Quantity:   decimal.NewFromInt(5),
+		LimitPrice: brokerage.DecimalPtr(decimal.RequireFromString("205")),
+	}
+	replaced, err := client.ReplaceOrder(t.Context(), schwabtest.AccountNumber, id, replacement)
+	if err != nil {
+		t.Fatalf("ReplaceOrder() error = %v", err)
+	}
+	if replaced.ID == id || replaced.ID == "" {
+		t.Errorf("replacement ID = %q, want a new order ID", replaced.ID)
+	}
+
+	req, ok := mock.LastRequest("PUT", "/trader/v1/accounts/"+schwabtest.AccountHash+"/orders/"+id)
+	if !ok {
+		t.Fatal("no replacement reached the mock")
+	}
+	requireJSONEqual(t, req.Body, `{
+		"orderType": "LIMIT",
+		"session": "NORMAL",
+		"duration": "DAY",
+		"orderStrategyType": "SINGLE",
+		"price": 205,
+		"orderLegCollection": [{
+			"instruction": "BUY",
+			"quantity": 5,
+			"instrument": {"symbol": "VTI", "assetType": "EQUITY"}
+		}]
+	}`)
+
+	original, err := client.GetOrderStatus(t.Context(), schwabtest.AccountNumber, id)
+	if err != nil {
+		t.Fatalf("GetOrderStatus() error = %v", err)
+	}
+	if original.Status != brokerage.OrderStatusReplaced {
+		t.Errorf("original status = %s, want REPLACED", original.Status)
+	}
+
+	// A closed order is refused without calling the replace endpoint
+	puts := countRequests(mock, "PUT", "/trader/v1/accounts/"+schwabtest.AccountHash+"/orders/"+id)
+	if _, err := client.ReplaceOrder(t.Context(), schwabtest.AccountNumber, id, replacement); err == nil {
+		t.Error("ReplaceOrder() of a replaced order succeeded, want an error")
+	}
+	if n := countRequests(mock, "PUT", "/trader/v1/accounts/"+schwabtest.AccountHash+"/orders/"+id); n != puts {
+		t.Errorf("replacing a closed order sent %d PUT requests, want none", n-puts)
+	}
+}
+
+func TestGetRecentOrders(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+	first := placeLimitOrder(t, client, 1, "200")
+	second := placeLimitOrder(t, client, 2, "201")
+	if err := mock.SetOrderStatus(first, "FILLED", 200); err != nil {
+		t.Fatal(err)
+	}
+
+	orders, err := client.GetRecentOrders(t.Context(), schwabtest.AccountNumber, brokerage.OrdersQuery{})
+	if err != nil {
+		t.Fatalf("GetRecentOrders() error = %v", err)
+	}
+	var ids []string
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+	}
+	if want := []string{second, first}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("order IDs = %v, want newest first %v", ids, want)
+	}
+
+	filled, err := client.GetRecentOrders(t.Context(), schwabtest.AccountNumber, brokerage.OrdersQuery{Status: brokerage.OrderStatusFilled})
+	if err != nil {
+		t.Fatalf("GetRecentOrders() error = %v", err)
+	}
+	if len(filled) != 1 || filled[0].ID != first {
+		t.Errorf("filled orders = %+v, want only %s", filled, first)
+	}
+	req, _ := mock.LastRequest("GET", "/trader/v1/accounts/"+schwabtest.AccountHash+"/orders")
+	query, _ := url.ParseQuery(req.Query)
+	if query.Get("status") != "FILLED" || query.Get("fromEnteredTime") == "" || query.Get("toEnteredTime") == "" {
+		t.Errorf("orders query = %q, want a FILLED status filter and a time range", req.Query)
+	}
+}
+
+func TestOrderTimesFollowClock(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	clock := schwabtest.NewClock(clockStart)
+	client := mock.NewClient(schwab.WithClock(clock))
+
+	placed, err := client.PlaceOrder(t.Context(), schwabtest.AccountNumber, limitBuy(4, "200"))
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	if !placed.SubmittedAt.Equal(clockStart) {
+		t.Errorf("PlaceOrder() SubmittedAt = %s, want %s", placed.SubmittedAt, clockStart)
+	}
+
+	clock.Advance(time.Minute)
+	replaced, err := client.ReplaceOrder(t.Context(), schwabtest.AccountNumber, placed.ID, limitBuy(5, "205"))
+	if err != nil {
+		t.Fatalf("ReplaceOrder() error = %v", err)
+	}
+	if want := clockStart.Add(time.Minute); !replaced.SubmittedAt.Equal(want) {
+		t.Errorf("ReplaceOrder() SubmittedAt = %s, want %s", replaced.SubmittedAt, want)
+	}
+
+	if _, err := client.GetRecentOrders(t.Context(), schwabtest.AccountNumber, brokerage.OrdersQuery{}); err != nil {
+		t.Fatalf("GetRecentOrders() error = %v", err)
+	}
+	req, _ := mock.LastRequest("GET", "/trader/v1/accounts/"+schwabtest.AccountHash+"/orders")
+	query, _ := url.ParseQuery(req.Query)
+	if got, want := query.Get("toEnteredTime"), "2025-03-03T14:01:00.000Z"; got != want {
+		t.Errorf("toEnteredTime = %q, want the clock's time %q", got, want)
+	}
+}
+
+func TestGetQuote(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+
+	quote, err := client.GetQuote(t.Context(), "VTI")
+	if err != nil {
+		t.Fatalf("GetQuote() error = %v", err)
+	}
+	if quote.Symbol != "VTI" || quote.Description != "VTI test security" {
+		t.Errorf("quote = %+v", quote)
+	}
+	for name, value := range map[string]struct{ got, want decimal.Decimal }{
+		"Bid":  {quote.Bid, decimal.RequireFromString("249.99")},
+		"Ask":  {quote.Ask, decimal.RequireFromString("250.01")},
+		"Last": {quote.Last, decimal.NewFromInt(250)},
+	} {
+		if !value.got.Equal(value.want) {
+			t.Errorf("%s = %s, want %s", name, value.got, value.want)
+		}
+	}
+
+	if _, err := client.GetQuote(t.Context(), "NOPE"); err == nil {
+		t.Error("GetQuote() of an unknown symbol succeeded, want an error")
+	}
+}
+
+func TestGetQuoteAfterHours(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	// The regular session closed at 16:00 while VTI kept trading after hours
+	closed := time.Date(2025, 3, 14, 20, 0, 0, 0, time.UTC)
+	traded := time.Date(2025, 3, 14, 23, 30, 0, 0, time.UTC)
+	mock.SetResponse("GET", "/marketdata/v1/quotes", http.StatusOK, fmt.Sprintf(`{"VTI": {
+		"symbol": "VTI",
+		"quote": {"bidPrice": 249.99, "askPrice": 250.01, "lastPrice": 250, "closePrice": 250, "quoteTime": %d},
+		"extended": {"bidPrice": 251.4, "askPrice": 251.6, "lastPrice": 251.5, "tradeTime": %d}
+	}}`, closed.UnixMilli(), traded.UnixMilli()))
+	client := mock.NewClient()
+
+	quote, err := client.GetQuoteWithFields(t.Context(), "VTI", schwab.QuoteFieldQuote, schwab.QuoteFieldExtended)
+	if err != nil {
+		t.Fatalf("GetQuoteWithFields() error = %v", err)
+	}
+	if quote.Extended == nil {
+		t.Fatalf("quote = %+v, want the extended-hours prices", quote)
+	}
+	if !quote.Timestamp.Equal(closed) || !quote.Extended.Timestamp.Equal(traded) {
+		t.Errorf("timestamps = %s and %s, want the close at %s and the trade at %s",
+			quote.Timestamp, quote.Extended.Timestamp, closed, traded)
+	}
+	for name, value := range map[string]struct{ got, want decimal.Decimal }{
+		"Last":          {quote.Last, decimal.NewFromInt(250)},
+		"Extended.Bid":  {quote.Extended.Bid, decimal.RequireFromString("251.4")},
+		"Extended.Ask":  {quote.Extended.Ask, decimal.RequireFromString("251.6")},
+		"Extended.Last": {quote.Extended.Last, decimal.RequireFromString("251.5")},
+		"Price(false)":  {quote.Price(false), decimal.NewFromInt(250)},
+		"Price(true)":   {quote.Price(true), decimal.RequireFromString("251.5")},
+	} {
+		if !value.got.Equal(value.want) {
+			t.Errorf("%s = %s, want %s", name, value.got, value.want)
+		}
+	}
+
+	request, _ := mock.LastRequest("GET", "/marketdata/v1/quotes")
+	query, _ := url.ParseQuery(request.Query)
+	if fields := query.Get("fields"); fields != "quote,extended" {
+		t.Errorf("fields = %q, want quote,extended", fields)
+	}
+}
+
+func TestUnauthorizedRefreshesOnce(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	client := flakyClient(t, mock, mock.Config(), "GET", http.StatusUnauthorized)
+
+	if _, err := client.GetPositions(t.Context(), schwabtest.AccountHash); err != nil {
+		t.Fatalf("GetPositions() error = %v, want success after refreshing", err)
+	}
+	if n := countRequests(mock, "POST", "/v1/oauth/token"); n != 1 {
+		t.Errorf("refresh requests = %d, want 1", n)
+	}
+}
+
+func TestRepeatedUnauthorizedFails(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	client := flakyClient(t, mock, mock.Config(), "GET", http.StatusUnauthorized, http.StatusUnauthorized)
+
+	_, err := client.GetPositions(t.Context(), schwabtest.AccountHash)
+	if !errors.Is(err, schwab.ErrNotAuthenticated) {
+		t.Errorf("GetPositions() error = %v, want ErrNotAuthenticated", err)
+	}
+	if n := countRequests(mock, "POST", "/v1/oauth/token"); n != 1 {
+		t.Errorf("refresh requests = %d, want 1", n)
+	}
+}
+
+func TestRateLimitedRequestsAreRetried(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		retries  int
+		statuses []int
+		wantErr  bool
+	}{
+		{name: "get within the retry limit", method: "GET", statuses: []int{429, 429}},
+		{name: "order within the retry limit", method: "POST", statuses: []int{429}},
+		{name: "beyond the retry limit", method: "GET", retries: 1, statuses: []int{429, 429}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := schwabtest.NewServer()
+			defer mock.Close()
+			config := mock.Config()
+			config.MaxRateLimitRetries = tt.retries
+			client := flakyClient(t, mock, config, tt.method, tt.statuses...)
+
+			var err error
+			if tt.method == "POST" {
+				_, err = client.PlaceOrder(t.Context(), schwabtest.AccountHash, brokerage.OrderRequest{
+					Symbol:   "VTI",
+					Action:   brokerage.OrderActionBuy,
+					Type:     brokerage.OrderTypeMarket,
+					Quantity: decimal.NewFromInt(1),
+				})
+			} else {
+				_, err = client.GetPositions(t.Context(), schwabtest.AccountHash)
+			}
+
+			var apiErr *schwab.APIError
+			switch {
+			case tt.wantErr && !(errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests):
+				t.Errorf("error = %v, want the 429 once retries run out", err)
+			case !tt.wantErr && err != nil:
+				t.Errorf("error = %v, want success after retrying", err)
+			}
+		})
+	}
+}
+
+func TestServerErrorsAreRetried(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	config := mock.Config()
+	config.MaxRetryAttempts = 3
+	client := flakyClient(t, mock, config, "GET", http.StatusServiceUnavailable, http.StatusBadGateway)
+
+	if _, err := client.GetPositions(t.Context(), schwabtest.AccountHash); err != nil {
+		t.Errorf("GetPositions() error = %v, want success on the third attempt", err)
+	}
+}
+
+func TestOrdersAreNotRetriedAfterServerErrors(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	client := flakyClient(t, mock, mock.Config(), "POST", http.StatusServiceUnavailable)
+
+	_, err := client.PlaceOrder(t.Context(), schwabtest.AccountHash, brokerage.OrderRequest{
+		Symbol:   "VTI",
+		Action:   brokerage.OrderActionBuy,
+		Type:     brokerage.OrderTypeMarket,
+		Quantity: decimal.NewFromInt(1),
+	})
+	var apiErr *schwab.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("PlaceOrder() error = %v, want the 503 without a retry", err)
+	}
+	if n := countRequests(mock, "POST", "/trader/v1/accounts/"+schwabtest.AccountHash+"/orders"); n != 0 {
+		t.Errorf("orders reaching the mock = %d, want 0", n)
+	}
+}
+
+func TestAPIErrorDecoding(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		status      int
+		body        string
+		call        func(*schwab.Client) error
+		wantID      string
+		wantMessage string
+	}{
+		{
+			name:        "trader error message",
+			path:        "/trader/v1/accounts/" + schwabtest.AccountHash,
+			status:      http.StatusBadRequest,
+			body:        `{"message":"Invalid account","errors":["account is closed"]}`,
+			wantMessage: "Invalid account; account is closed",
+		},
+		{
+			name:        "market data error list",
+			path:        "/marketdata/v1/quotes",
+			status:      http.StatusBadRequest,
+			body:        `{"errors":[{"id":"a1b2","title":"Bad Request","detail":"symbols is required"}]}`,
+			wantID:      "a1b2",
+			wantMessage: "symbols is required",
+		},
+		{
+			name:   "unparsed body",
+			path:   "/trader/v1/accounts/" + schwabtest.AccountHash,
+			status: http.StatusForbidden,
+			body:   `forbidden`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := schwabtest.NewServer()
+			defer mock.Close()
+			client := mock.NewClient()
+			mock.SetResponse("GET", tt.path, tt.status, tt.body)
+
+			var err error
+			if strings.HasPrefix(tt.path, "/marketdata") {
+				_, err = client.GetQuote(t.Context(), "VTI")
+			} else {
+				_, err = client.GetPositions(t.Context(), schwabtest.AccountHash)
+			}
+
+			var apiErr *schwab.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error = %v, want an APIError", err)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.ErrorID != tt.wantID || apiErr.Message != tt.wantMessage || apiErr.Body != tt.body {
+				t.Errorf("APIError = %+v, want status %d, id %q, message %q and the raw body",
+					apiErr, tt.status, tt.wantID, tt.wantMessage)
+			}
+			if !strings.Contains(err.Error(), strconv.Itoa(tt.status)) {
+				t.Errorf("error %q does not mention the status", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package schwab
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/oauth"
+)
+
+// ParseConfig decodes a Config from JSON, failing on fields it does not
+// know so that a misspelt one is caught here rather than as a 401 later
+func ParseConfig(raw json.RawMessage) (Config, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+
+	var config Config
+	if err := decoder.Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("failed to parse schwab config: %w", err)
+	}
+	return config, nil
+}
+
+// Validate checks that the required fields are set, that the redirect and
+// endpoint URLs are well formed and that the directories of the token and
+// duplicate guard files are writable. Every problem found is returned,
+// joined into one error.
+func (c Config) Validate() error {
+	var errs []error
+	if c.ClientID == "" {
+		errs = append(errs, errors.New("client_id is required"))
+	}
+	if c.RedirectURI == "" {
+		errs = append(errs, errors.New("redirect_uri is required"))
+	} else if _, err := oauth.ParseRedirectURI(c.RedirectURI); err != nil {
+		errs = append(errs, fmt.Errorf("invalid redirect_uri: %w", err))
+	}
+	if c.CallbackAddr != "" {
+		if _, _, err := net.SplitHostPort(c.CallbackAddr); err != nil {
+			errs = append(errs, fmt.Errorf("invalid callback_addr %q: %w", c.CallbackAddr, err))
+		}
+	}
+	if c.TokenFile != "" {
+		if err := checkWritableDir(filepath.Dir(c.TokenFile)); err != nil {
+			errs = append(errs, fmt.Errorf("token_file %s cannot be saved: %w", c.TokenFile, err))
+		}
+	}
+	if c.DuplicateWindowSeconds < 0 {
+		errs = append(errs, fmt.Errorf("duplicate_window_seconds must not be negative, got %d", c.DuplicateWindowSeconds))
+	}
+	if c.DuplicateGuardFile != "" {
+		if err := checkWritableDir(filepath.Dir(c.DuplicateGuardFile)); err != nil {
+			errs = append(errs, fmt.Errorf("duplicate_guard_file %s cannot be saved: %w", c.DuplicateGuardFile, err))
+		}
+	}
+
+	withDefaults := c
+	for _, endpoint := range []struct {
+		value    *string
+		fallback string
+	}{
+		{&withDefaults.BaseURL, defaultBaseURL},
+		{&withDefaults.AuthURL, defaultAuthURL},
+		{&withDefaults.TokenURL, defaultTokenURL},
+	} {
+		if *endpoint.value == "" {
+			*endpoint.value = endpoint.fallback
+		}
+	}
+	errs = append(errs, validateEndpoints(withDefaults)...)
+
+	return errors.Join(errs...)
+}
+
+// checkWritableDir fails unless a file can be created in dir
+func checkWritableDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	probe, err := os.CreateTemp(dir, ".money-pies-write-check-*")
+	if err != nil {
+		return fmt.Errorf("directory %s is not writable: %w", dir, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
@@ -0,0 +1,32 @@
+package schwab_test
+
+import (
+	"testing"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab/schwabtest"
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/alysoliman1/money-pies/pkg/pies/piestest"
+)
+
+// The mock leaves market orders working, so CancelFilledOrder is skipped
+func TestConformance(t *testing.T) {
+	piestest.RunBrokerageConformanceTests(t, func() brokerage.BrokerageClient {
+		mock := schwabtest.NewServer()
+		t.Cleanup(mock.Close)
+		return mock.NewClient()
+	})
+}
+
+func TestUnauthenticatedConformance(t *testing.T) {
+	piestest.RunUnauthenticatedConformanceTests(t, func() brokerage.BrokerageClient {
+		mock := schwabtest.NewServer()
+		t.Cleanup(mock.Close)
+
+		client, err := schwab.NewClient(mock.Config(), schwab.WithTokenStore(&schwab.MemoryTokenStore{}))
+		if err != nil {
+			t.Fatalf("NewClient() error = %v", err)
+		}
+		return client
+	})
+}
@@ -0,0 +1,71 @@
+package schwab
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+// Version is reported in the default User-Agent. Release builds set it with
+// -ldflags "-X github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab.Version=v1.2.3".
+var Version = "dev"
+
+// correlationIDHeader carries the ID Schwab support uses to trace a request
+const correlationIDHeader = "Schwab-Client-CorrelId"
+
+// correlationIDKey is the context key for WithCorrelationID
+type correlationIDKey struct{}
+
+// WithCorrelationID makes every API request made with ctx carry id as its
+// correlation ID, so a caller can log the ID of the requests behind an
+// operation before making them. Without it each request gets a fresh UUID.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationID returns the correlation ID set on ctx with WithCorrelationID
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// requestCorrelationID returns the correlation ID for a request made with ctx
+func requestCorrelationID(ctx context.Context) string {
+	if id := CorrelationID(ctx); id != "" {
+		return id
+	}
+	return newCorrelationID()
+}
+
+// newCorrelationID returns a random version 4 UUID
+func newCorrelationID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// responseCorrelationID returns the correlation ID sent with the request
+// that produced resp
+func responseCorrelationID(resp *http.Response) string {
+	if resp == nil || resp.Request == nil {
+		return ""
+	}
+	return resp.Request.Header.Get(correlationIDHeader)
+}
+
+// RawResponse is the RawResponse of orders returned by the client
+type RawResponse struct {
+	Body          string
+	CorrelationID string // The correlation ID of the request
+}
+
+// newRawResponse pairs a response body with its request's correlation ID
+func newRawResponse(resp *http.Response, body []byte) RawResponse {
+	return RawResponse{
+		Body:          string(body),
+		CorrelationID: responseCorrelationID(resp),
+	}
+}
@@ -0,0 +1,204 @@
+package schwab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// defaultDuplicateWindow is how long a placed order blocks an identical one
+// when Config.DuplicateWindowSeconds is zero
+const defaultDuplicateWindow = 60 * time.Second
+
+const (
+	// guardLockWait is how long to wait for another process to release the
+	// duplicate guard file's lock
+	guardLockWait = 10 * time.Second
+
+	// guardLockStale is how old a lock must be to be taken for one left
+	// behind by a process that died holding it
+	guardLockStale = 30 * time.Second
+)
+
+// ErrDuplicateOrder is returned by PlaceOrder, with Config.DuplicateGuard
+// set, for an order identical to one placed within the duplicate window. It
+// is brokerage.ErrDuplicateOrder.
+var ErrDuplicateOrder = brokerage.ErrDuplicateOrder
+
+// duplicateGuard remembers the orders placed recently, keyed by
+// duplicateKey, to refuse a repeat within window. With a path the record is
+// kept in that file too, so separate processes see each other's orders. A
+// lock file next to it, the path with ".lock" appended, keeps processes from
+// checking and recording at the same time.
+type duplicateGuard struct {
+	window time.Duration
+	path   string
+	clock  Clock
+
+	mu     sync.Mutex
+	placed map[string]time.Time
+}
+
+// newDuplicateGuard returns the guard config asks for, or nil when
+// DuplicateGuard is off
+func newDuplicateGuard(config Config, clock Clock) *duplicateGuard {
+	if !config.DuplicateGuard {
+		return nil
+	}
+	window := defaultDuplicateWindow
+	if config.DuplicateWindowSeconds > 0 {
+		window = time.Duration(config.DuplicateWindowSeconds) * time.Second
+	}
+	return &duplicateGuard{
+		window: window,
+		path:   config.DuplicateGuardFile,
+		clock:  clock,
+		placed: map[string]time.Time{},
+	}
+}
+
+// duplicateKey identifies order in accountID by what it trades: the symbol,
+// action, quantity and its limit or stop price
+func duplicateKey(accountID string, order brokerage.OrderRequest) string {
+	price := ""
+	switch {
+	case order.LimitPrice != nil:
+		price = order.LimitPrice.String()
+	case order.StopPrice != nil:
+		price = order.StopPrice.String()
+	}
+	return strings.Join([]string{accountID, order.Symbol, string(order.Action), order.Quantity.String(), price}, "|")
+}
+
+// reserve records an order under key, failing with ErrDuplicateOrder when
+// one was recorded within the window. Checking and recording happen under
+// one lock, so of two concurrent identical orders only one gets through.
+func (g *duplicateGuard) reserve(key string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	unlock, err := g.lockFileLocked()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := g.loadLocked(); err != nil {
+		return err
+	}
+	now := g.clock.Now()
+	g.pruneLocked(now)
+	if placedAt, ok := g.placed[key]; ok {
+		return fmt.Errorf("an identical order was placed %s ago, set AllowDuplicate to place it again: %w",
+			now.Sub(placedAt).Round(time.Second), ErrDuplicateOrder)
+	}
+	g.placed[key] = now
+	return g.saveLocked()
+}
+
+// release forgets the order recorded under key, for orders Schwab is known
+// to have refused, so that placing them again is not taken for a duplicate
+func (g *duplicateGuard) release(key string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	unlock, err := g.lockFileLocked()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := g.loadLocked(); err != nil {
+		return err
+	}
+	delete(g.placed, key)
+	return g.saveLocked()
+}
+
+// pruneLocked drops the orders placed longer than the window before now.
+// The caller must hold mu.
+func (g *duplicateGuard) pruneLocked(now time.Time) {
+	for key, placedAt := range g.placed {
+		if now.Sub(placedAt) >= g.window {
+			delete(g.placed, key)
+		}
+	}
+}
+
+// lockFileLocked takes the lock shared with other processes using the file,
+// by creating the lock file, and returns the function that releases it. It
+// waits up to guardLockWait for another holder, and removes a lock older
+// than guardLockStale. Without a file there is nothing to lock. The caller
+// must hold mu.
+func (g *duplicateGuard) lockFileLocked() (func(), error) {
+	if g.path == "" {
+		return func() {}, nil
+	}
+
+	lockPath := g.path + ".lock"
+	deadline := time.Now().Add(guardLockWait)
+	for {
+		lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			lock.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("failed to lock duplicate guard file: %w", err)
+		}
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > guardLockStale {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for duplicate guard lock %s", lockPath)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// loadLocked replaces the in-memory record with the file's, when there is
+// a file. A missing file is an empty record. The caller must hold mu.
+func (g *duplicateGuard) loadLocked() error {
+	if g.path == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(g.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		g.placed = map[string]time.Time{}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read duplicate guard file: %w", err)
+	}
+
+	placed := map[string]time.Time{}
+	if err := json.Unmarshal(raw, &placed); err != nil {
+		return fmt.Errorf("failed to parse duplicate guard file %s: %w", g.path, err)
+	}
+	g.placed = placed
+	return nil
+}
+
+// saveLocked writes the record to the file, when there is one. The caller
+// must hold mu.
+func (g *duplicateGuard) saveLocked() error {
+	if g.path == "" {
+		return nil
+	}
+	raw, err := json.Marshal(g.placed)
+	if err != nil {
+		return fmt.Errorf("failed to marshal duplicate guard record: %w", err)
+	}
+	if err := writeFileAtomic(g.path, raw, 0600); err != nil {
+		return fmt.Errorf("failed to save duplicate guard file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,300 @@
+package schwab_test
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab/schwabtest"
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+const ordersRequestPath = "/trader/v1/accounts/" + schwabtest.AccountHash + "/orders"
+
+// guardedClient returns a client for mock with the duplicate guard on,
+// using a window of windowSeconds and the record file path, if any
+func guardedClient(mock *schwabtest.Server, clock *schwabtest.Clock, windowSeconds int, path string) *schwab.Client {
+	config := mock.Config()
+	config.DuplicateGuard = true
+	config.DuplicateWindowSeconds = windowSeconds
+	config.DuplicateGuardFile = path
+	return mock.NewClientWithConfig(config, schwab.WithClock(clock))
+}
+
+// limitBuy returns a day limit buy of quantity VTI at limit
+func limitBuy(quantity int64, limit string) brokerage.OrderRequest {
+	return brokerage.OrderRequest{
+		Symbol:     "VTI",
+		Action:     brokerage.OrderActionBuy,
+		Type:       brokerage.OrderTypeLimit,
+		Quantity:   decimal.NewFromInt(quantity),
+		LimitPrice: brokerage.DecimalPtr(decimal.RequireFromString(limit)),
+		Duration:   brokerage.OrderDurationDay,
+	}
+}
+
+func TestDuplicateGuardRefusesRepeats(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	clock := schwabtest.NewClock(clockStart)
+	client := guardedClient(mock, clock, 60, "")
+
+	if _, err := client.PlaceOrder(t.Context(), schwabtest.AccountNumber, limitBuy(10, "250")); err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+
+	clock.Advance(10 * time.Second)
+	_, err := client.PlaceOrder(t.Context(), schwabtest.AccountNumber, limitBuy(10, "250"))
+	if !errors.Is(err, schwab.ErrDuplicateOrder) || !errors.Is(err, brokerage.ErrDuplicateOrder) {
+		t.Fatalf("PlaceOrder() error = %v, want ErrDuplicateOrder", err)
+	}
+	if want := "an identical order was placed 10s ago, set AllowDuplicate to place it again: duplicate order"; err.Error() != want {
+		t.Errorf("PlaceOrder() error = %q, want %q", err, want)
+	}
+	if n := countRequests(mock, "POST", ordersRequestPath); n != 1 {
+		t.Errorf("sent %d orders to Schwab, want 1", n)
+	}
+
+	// Orders that differ in what they trade are not duplicates
+	different := map[string]brokerage.OrderRequest{
+		"quantity": limitBuy(11, "250"),
+		"price":    limitBuy(10, "249.99"),
+		"symbol": func() brokerage.OrderRequest {
+			o := limitBuy(10, "250")
+			o.Symbol = "VXUS"
+			return o
+		}(),
+		"action": func() brokerage.OrderRequest {
+			o := limitBuy(10, "250")
+			o.Action = brokerage.OrderActionSell
+			return o
+		}(),
+	}
+	for name, order := range different {
+		t.Run(name, func(t *testing.T) {
+			if _, err := client.PlaceOrder(t.Context(), schwabtest.AccountNumber, order); err != nil {
+				t.Errorf("PlaceOrder() error = %v, want nil", err)
+			}
+		})
+	}
+
+	// AllowDuplicate places the repeat anyway
+	order := limitBuy(10, "250")
+	order.AllowDuplicate = true
+	if _, err := client.PlaceOrder(t.Context(), schwabtest.AccountNumber, order); err != nil {
+		t.Errorf("PlaceOrder() with AllowDuplicate error = %v, want nil", err)
+	}
+}
+
+func TestDuplicateGuardWindowExpiry(t *testing.T) {
+	tests := []struct {
+		name          string
+		windowSeconds int
+		window        time.Duration
+	}{
+		{name: "default window", windowSeconds: 0, window: 60 * time.Second},
+		{name: "configured window", windowSeconds: 5, window: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := schwabtest.NewServer()
+			defer mock.Close()
+			clock := schwabtest.NewClock(clockStart)
+			client := guardedClient(mock, clock, tt.windowSeconds, "")
+
+			if _, err := client.PlaceOrder(t.Context(), schwabtest.AccountNumber, limitBuy(10, "250")); err != nil {
+				t.Fatalf("PlaceOrder() error = %v", err)
+			}
+
+			clock.Advance(tt.window - time.Second)
+			if _, err := client.PlaceOrder(t.Context(), schwabtest.AccountNumber, limitBuy(10, "250")); !errors.Is(err, schwab.ErrDuplicateOrder) {
+				t.Fatalf("PlaceOrder() a second inside the window error = %v, want ErrDuplicateOrder", err)
+			}
+
+			// The refused repeat does not restart the window, which ends
+			// exactly window after the first order
+			clock.Advance(time.Second)
+			if _, err := client.PlaceOrder(t.Context(), schwabtest.AccountNumber, limitBuy(10, "250")); err != nil {
+				t.Fatalf("PlaceOrder() once the window passed error = %v, want nil", err)
+			}
+
+			// and the order placed then opens a new one
+			clock.Advance(time.Second)
+			if _, err := client.PlaceOrder(t.Context(), schwabtest.AccountNumber, limitBuy(10, "250")); !errors.Is(err, schwab.ErrDuplicateOrder) {
+				t.Errorf("PlaceOrder() after the repeat error = %v, want ErrDuplicateOrder", err)
+			}
+			if n := countRequests(mock, "POST", ordersRequestPath); n != 2 {
+				t.Errorf("sent %d orders to Schwab, want 2", n)
+			}
+		})
+	}
+}
+
+func TestDuplicateGuardOff(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+
+	for range 3 {
+		if _, err := client.PlaceOrder(t.Context(), schwabtest.AccountNumber, limitBuy(10, "250")); err != nil {
+			t.Fatalf("PlaceOrder() without the guard error = %v, want nil", err)
+		}
+	}
+}
+
+func TestDuplicateGuardForgetsRefusedOrders(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	clock := schwabtest.NewClock(clockStart)
+	client := guardedClient(mock, clock, 60, "")
+
+	mock.SetResponse("POST", ordersRequestPath, http.StatusBadRequest, `{"message": "insufficient buying power"}`)
+	if _, err := client.PlaceOrder(t.Context(), schwabtest.AccountNumber, limitBuy(10, "250")); err == nil || errors.Is(err, schwab.ErrDuplicateOrder) {
+		t.Fatalf("PlaceOrder() error = %v, want Schwab's refusal", err)
+	}
+
+	mock.ClearResponse("POST", ordersRequestPath)
+	if _, err := client.PlaceOrder(t.Context(), schwabtest.AccountNumber, limitBuy(10, "250")); err != nil {
+		t.Errorf("PlaceOrder() after a refused order error = %v, want nil", err)
+	}
+}
+
+func TestDuplicateGuardFileIsShared(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	clock := schwabtest.NewClock(clockStart)
+	path := filepath.Join(t.TempDir(), "placed-orders.json")
+	first := guardedClient(mock, clock, 60, path)
+	second := guardedClient(mock, clock, 60, path)
+
+	if _, err := first.PlaceOrder(t.Context(), schwabtest.AccountNumber, limitBuy(10, "250")); err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	if _, err := second.PlaceOrder(t.Context(), schwabtest.AccountNumber, limitBuy(10, "250")); !errors.Is(err, schwab.ErrDuplicateOrder) {
+		t.Fatalf("PlaceOrder() from another client error = %v, want ErrDuplicateOrder", err)
+	}
+
+	clock.Advance(time.Minute)
+	if _, err := second.PlaceOrder(t.Context(), schwabtest.AccountNumber, limitBuy(10, "250")); err != nil {
+		t.Errorf("PlaceOrder() from another client once the window passed error = %v, want nil", err)
+	}
+}
+
+func TestDuplicateGuardConcurrentOrders(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	clock := schwabtest.NewClock(clockStart)
+	client := guardedClient(mock, clock, 60, "")
+
+	const n = 10
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = client.PlaceOrder(t.Context(), schwabtest.AccountNumber, limitBuy(10, "250"))
+		}()
+	}
+	wg.Wait()
+
+	placed := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			placed++
+		case !errors.Is(err, schwab.ErrDuplicateOrder):
+			t.Errorf("PlaceOrder() error = %v, want nil or ErrDuplicateOrder", err)
+		}
+	}
+	if placed != 1 {
+		t.Errorf("placed %d of %d identical concurrent orders, want 1", placed, n)
+	}
+	if got := countRequests(mock, "POST", ordersRequestPath); got != 1 {
+		t.Errorf("sent %d orders to Schwab, want 1", got)
+	}
+}
+
+func TestDuplicateGuardFileConcurrentClients(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	clock := schwabtest.NewClock(clockStart)
+	path := filepath.Join(t.TempDir(), "placed-orders.json")
+
+	// Each client stands in for a separate process sharing the file
+	const n = 10
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		client := guardedClient(mock, clock, 60, path)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = client.PlaceOrder(t.Context(), schwabtest.AccountNumber, limitBuy(10, "250"))
+		}()
+	}
+	wg.Wait()
+
+	placed := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			placed++
+		case !errors.Is(err, schwab.ErrDuplicateOrder):
+			t.Errorf("PlaceOrder() error = %v, want nil or ErrDuplicateOrder", err)
+		}
+	}
+	if placed != 1 {
+		t.Errorf("placed %d of %d identical orders from clients sharing a file, want 1", placed, n)
+	}
+	if _, err := os.Stat(path + ".lock"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("lock file left behind, Stat() error = %v", err)
+	}
+}
+
+func TestDuplicateGuardFileLock(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	clock := schwabtest.NewClock(clockStart)
+	path := filepath.Join(t.TempDir(), "placed-orders.json")
+	client := guardedClient(mock, clock, 60, path)
+
+	// A lock held by another process is waited for
+	if err := os.WriteFile(path+".lock", nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	released := make(chan struct{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(released)
+		os.Remove(path + ".lock")
+	}()
+	if _, err := client.PlaceOrder(t.Context(), schwabtest.AccountNumber, limitBuy(10, "250")); err != nil {
+		t.Fatalf("PlaceOrder() once the lock was released error = %v", err)
+	}
+	select {
+	case <-released:
+	default:
+		t.Error("PlaceOrder() went ahead while another process held the lock")
+	}
+
+	// A lock left behind by a process that died holding it is taken over
+	if err := os.WriteFile(path+".lock", nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path+".lock", old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.PlaceOrder(t.Context(), schwabtest.AccountNumber, limitBuy(20, "250")); err != nil {
+		t.Errorf("PlaceOrder() with a stale lock error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,152 @@
+package schwab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// ErrNotAuthenticated is returned when the client has no usable access token
+// or Schwab rejects it even after a refresh. It is
+// brokerage.ErrNotAuthenticated.
+var ErrNotAuthenticated = brokerage.ErrNotAuthenticated
+
+// ErrTokenExpired is returned when the access token has expired and could not
+// be refreshed. It matches brokerage.ErrNotAuthenticated with errors.Is.
+var ErrTokenExpired error = &authError{"access token expired"}
+
+// authError is an authentication failure with its own message that matches
+// brokerage.ErrNotAuthenticated
+type authError struct {
+	message string
+}
+
+func (e *authError) Error() string {
+	return e.message
+}
+
+func (e *authError) Is(target error) bool {
+	return target == brokerage.ErrNotAuthenticated
+}
+
+// ErrRefreshTokenExpired is returned when the access token can no longer be
+// refreshed and the user has to complete the OAuth flow again. It matches
+// ErrTokenExpired with errors.Is.
+var ErrRefreshTokenExpired = fmt.Errorf("refresh token expired, re-authentication required: %w", ErrTokenExpired)
+
+// IsAuthError reports whether err means the user has to (re-)run the OAuth
+// flow before the client can be used
+func IsAuthError(err error) bool {
+	return errors.Is(err, ErrNotAuthenticated) || errors.Is(err, ErrTokenExpired)
+}
+
+// APIError is returned when Schwab answers a request with an unexpected
+// status code. Schwab's error payload is parsed into ErrorID and Message
+// when the body has one of its standard shapes.
+type APIError struct {
+	Op         string // The operation that failed, e.g. "get accounts"
+	StatusCode int
+	ErrorID    string
+	Message    string
+	Body       string
+
+	// CorrelationID identifies the request to Schwab support. It is empty
+	// for token requests.
+	CorrelationID string
+}
+
+func (e *APIError) Error() string {
+	detail := e.Message
+	if detail == "" {
+		detail = e.Body
+	}
+	if e.CorrelationID != "" {
+		return fmt.Sprintf("%s failed with status %d: %s (correlation id %s)", e.Op, e.StatusCode, detail, e.CorrelationID)
+	}
+	return fmt.Sprintf("%s failed with status %d: %s", e.Op, e.StatusCode, detail)
+}
+
+// newAPIError builds an APIError from a failed response and its body
+func newAPIError(op string, resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		Op:            op,
+		StatusCode:    resp.StatusCode,
+		Body:          string(body),
+		CorrelationID: responseCorrelationID(resp),
+	}
+	apiErr.ErrorID, apiErr.Message = parseErrorBody(body)
+	return apiErr
+}
+
+// parseErrorBody extracts an error ID and message from the error payloads
+// Schwab uses: the market data {"errors":[{id,title,detail}]} form, the
+// trader {"message","errors":[...]} form, and the OAuth
+// {"error","error_description"} form
+func parseErrorBody(body []byte) (string, string) {
+	var payload struct {
+		Message          string            `json:"message"`
+		Error            string            `json:"error"`
+		ErrorDescription string            `json:"error_description"`
+		Errors           []json.RawMessage `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", ""
+	}
+
+	var ids, messages []string
+	for _, rawError := range payload.Errors {
+		var detailed struct {
+			ID     string `json:"id"`
+			Title  string `json:"title"`
+			Detail string `json:"detail"`
+		}
+		var text string
+		switch {
+		case json.Unmarshal(rawError, &text) == nil:
+			messages = append(messages, text)
+		case json.Unmarshal(rawError, &detailed) == nil:
+			if detailed.ID != "" {
+				ids = append(ids, detailed.ID)
+			}
+			if detailed.Detail != "" {
+				messages = append(messages, detailed.Detail)
+			} else if detailed.Title != "" {
+				messages = append(messages, detailed.Title)
+			}
+		}
+	}
+
+	switch {
+	case payload.Message != "":
+		messages = append([]string{payload.Message}, messages...)
+	case payload.Error != "":
+		ids = append(ids, payload.Error)
+		if payload.ErrorDescription != "" {
+			messages = append(messages, payload.ErrorDescription)
+		} else {
+			messages = append(messages, payload.Error)
+		}
+	}
+
+	return strings.Join(ids, ", "), strings.Join(messages, "; ")
+}
+
+// IsRateLimited reports whether err is an APIError for a 429 response
+func IsRateLimited(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
+
+// IsNotFound reports whether err is an APIError for a 404 response
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// hasStatus reports whether err wraps an APIError with the given status code
+func hasStatus(err error, statusCode int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == statusCode
+}
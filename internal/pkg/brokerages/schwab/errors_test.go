@@ -0,0 +1,129 @@
+package schwab_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab/schwabtest"
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+func TestAuthErrorsMatchThroughWrapping(t *testing.T) {
+	// expiredToken returns a token whose access token expired a minute ago
+	// and whose refresh token expires at refreshExpiresAt
+	expiredToken := func(refreshExpiresAt time.Time) schwab.Token {
+		token := freshToken(schwabtest.RefreshToken)
+		token.ExpiresAt = clockStart.Add(-time.Minute)
+		token.RefreshTokenExpiresAt = refreshExpiresAt
+		return token
+	}
+
+	tests := []struct {
+		name string
+		// client returns a client for mock whose next GetAccounts fails
+		client           func(t *testing.T, mock *schwabtest.Server) *schwab.Client
+		wantTokenExpired bool
+		wantStatus       int // Status of the wrapped APIError, 0 for none
+	}{
+		{
+			name: "no token",
+			client: func(t *testing.T, mock *schwabtest.Server) *schwab.Client {
+				client, err := schwab.NewClient(mock.Config(), schwab.WithTokenStore(&schwab.MemoryTokenStore{}))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := client.LoadToken(); !errors.Is(err, schwab.ErrNoToken) || !errors.Is(err, schwab.ErrNotAuthenticated) {
+					t.Errorf("LoadToken() error = %v, want ErrNoToken matching ErrNotAuthenticated", err)
+				}
+				return client
+			},
+		},
+		{
+			name: "refresh rejected",
+			client: func(t *testing.T, mock *schwabtest.Server) *schwab.Client {
+				mock.SetResponse("POST", "/v1/oauth/token", http.StatusBadRequest,
+					`{"error": "invalid_grant", "error_description": "refresh token revoked"}`)
+				client := mock.NewClient(schwab.WithClock(schwabtest.NewClock(clockStart)))
+				if err := client.SetAccessToken(expiredToken(clockStart.Add(24 * time.Hour))); err != nil {
+					t.Fatal(err)
+				}
+				return client
+			},
+			wantTokenExpired: true,
+			wantStatus:       http.StatusBadRequest,
+		},
+		{
+			name: "refresh token expired",
+			client: func(t *testing.T, mock *schwabtest.Server) *schwab.Client {
+				client := mock.NewClient(schwab.WithClock(schwabtest.NewClock(clockStart)))
+				if err := client.SetAccessToken(expiredToken(clockStart.Add(-time.Hour))); err != nil {
+					t.Fatal(err)
+				}
+				return client
+			},
+			wantTokenExpired: true,
+		},
+		{
+			name: "unauthorized and refresh rejected",
+			client: func(t *testing.T, mock *schwabtest.Server) *schwab.Client {
+				client := flakyClient(t, mock, mock.Config(), "GET", http.StatusUnauthorized)
+				mock.SetResponse("POST", "/v1/oauth/token", http.StatusUnauthorized, `{"error": "invalid_client"}`)
+				return client
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "unauthorized twice",
+			client: func(t *testing.T, mock *schwabtest.Server) *schwab.Client {
+				return flakyClient(t, mock, mock.Config(), "GET", http.StatusUnauthorized, http.StatusUnauthorized)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := schwabtest.NewServer()
+			defer mock.Close()
+			client := tt.client(t, mock)
+
+			_, err := client.GetAccounts(t.Context())
+			if err == nil {
+				t.Fatal("GetAccounts() error = nil, want an authentication error")
+			}
+
+			// Callers such as the CLIs see the error wrapped further
+			wrapped := fmt.Errorf("failed to get pie status: %w", fmt.Errorf("failed to get accounts: %w", err))
+			for _, err := range []error{err, wrapped} {
+				if !errors.Is(err, schwab.ErrNotAuthenticated) || !errors.Is(err, brokerage.ErrNotAuthenticated) || !schwab.IsAuthError(err) {
+					t.Errorf("error %q does not match ErrNotAuthenticated", err)
+				}
+				if got := errors.Is(err, schwab.ErrTokenExpired); got != tt.wantTokenExpired {
+					t.Errorf("errors.Is(%q, ErrTokenExpired) = %t, want %t", err, got, tt.wantTokenExpired)
+				}
+				var apiErr *schwab.APIError
+				if got := errors.As(err, &apiErr); got != (tt.wantStatus != 0) || got && apiErr.StatusCode != tt.wantStatus {
+					t.Errorf("error %q wraps APIError %v, want status %d", err, apiErr, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestAPIErrorsAreNotAuthErrors(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	mock.SetResponse("GET", "/trader/v1/accounts", http.StatusBadRequest, `{"message": "bad request"}`)
+	client := mock.NewClient()
+
+	_, err := client.GetAccounts(t.Context())
+	if err == nil || schwab.IsAuthError(err) || errors.Is(err, brokerage.ErrNotAuthenticated) {
+		t.Errorf("GetAccounts() error = %v, want an error that is not an authentication error", err)
+	}
+	if schwab.IsAuthError(nil) {
+		t.Error("IsAuthError(nil) = true, want false")
+	}
+}
@@ -0,0 +1,103 @@
+package schwab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+const instrumentsPath = "/marketdata/v1/instruments"
+
+// Instrument search projections accepted by SearchInstruments
+const (
+	ProjectionSymbolSearch = "symbol-search"
+	ProjectionSymbolRegex  = "symbol-regex"
+	ProjectionDescSearch   = "desc-search"
+	ProjectionDescRegex    = "desc-regex"
+	ProjectionSearch       = "search"
+	ProjectionFundamental  = "fundamental"
+)
+
+// SearchInstruments looks up instruments by symbol or description according
+// to projection. With ProjectionFundamental the query must be a symbol and
+// the result carries fundamental data.
+// Documentation: https://developer.schwab.com/products/trader-api--individual/details/specifications/Market%20Data%20Production
+// Endpoint: GET /marketdata/v1/instruments
+func (c *Client) SearchInstruments(ctx context.Context, query string, projection string) ([]brokerage.Instrument, error) {
+	params := url.Values{}
+	params.Set("symbol", query)
+	params.Set("projection", projection)
+
+	path := instrumentsPath + "?" + params.Encode()
+	resp, err := c.makeRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read instruments response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("search instruments", resp, body)
+	}
+
+	var instrumentsResponse struct {
+		Instruments []struct {
+			CUSIP       string `json:"cusip"`
+			Symbol      string `json:"symbol"`
+			Description string `json:"description"`
+			Exchange    string `json:"exchange"`
+			AssetType   string `json:"assetType"`
+			Fundamental *struct {
+				PERatio           float64 `json:"peRatio"`
+				EPS               float64 `json:"eps"`
+				DividendYield     float64 `json:"dividendYield"`
+				DividendAmount    float64 `json:"dividendAmount"`
+				MarketCap         float64 `json:"marketCap"`
+				High52            float64 `json:"high52"`
+				Low52             float64 `json:"low52"`
+				SharesOutstanding float64 `json:"sharesOutstanding"`
+			} `json:"fundamental"`
+		} `json:"instruments"`
+	}
+
+	if err := json.Unmarshal(body, &instrumentsResponse); err != nil {
+		return nil, fmt.Errorf("failed to parse instruments response: %w", err)
+	}
+
+	instruments := make([]brokerage.Instrument, 0, len(instrumentsResponse.Instruments))
+	for _, si := range instrumentsResponse.Instruments {
+		instrument := brokerage.Instrument{
+			Symbol:      si.Symbol,
+			CUSIP:       si.CUSIP,
+			Description: si.Description,
+			Exchange:    si.Exchange,
+			AssetType:   si.AssetType,
+		}
+
+		if f := si.Fundamental; f != nil {
+			instrument.Fundamental = &brokerage.Fundamentals{
+				PERatio:           f.PERatio,
+				EPS:               f.EPS,
+				DividendYield:     f.DividendYield,
+				DividendAmount:    f.DividendAmount,
+				MarketCap:         f.MarketCap,
+				High52Week:        f.High52,
+				Low52Week:         f.Low52,
+				SharesOutstanding: f.SharesOutstanding,
+			}
+		}
+
+		instruments = append(instruments, instrument)
+	}
+
+	return instruments, nil
+}
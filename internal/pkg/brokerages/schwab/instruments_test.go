@@ -0,0 +1,158 @@
+package schwab_test
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab/schwabtest"
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+const instrumentsRequestPath = "/marketdata/v1/instruments"
+
+// symbolSearchFixture is the answer to a symbol search for VTI
+const symbolSearchFixture = `{
+	"instruments": [
+		{
+			"cusip": "922908769",
+			"symbol": "VTI",
+			"description": "VANGUARD TOTAL STOCK MARKET ETF",
+			"exchange": "NYSE Arca",
+			"assetType": "ETF"
+		}
+	]
+}`
+
+// descSearchFixture is the answer to a description search for "vanguard
+// total", matching more than one fund
+const descSearchFixture = `{
+	"instruments": [
+		{"cusip": "922908769", "symbol": "VTI", "description": "VANGUARD TOTAL STOCK MARKET ETF", "exchange": "NYSE Arca", "assetType": "ETF"},
+		{"cusip": "921909768", "symbol": "VXUS", "description": "VANGUARD TOTAL INTERNATIONAL STOCK ETF", "exchange": "NASDAQ", "assetType": "ETF"},
+		{"cusip": "921937835", "symbol": "BND", "description": "VANGUARD TOTAL BOND MARKET ETF", "exchange": "NASDAQ", "assetType": "ETF"}
+	]
+}`
+
+// fundamentalFixture is the answer to a fundamental projection for AAPL
+const fundamentalFixture = `{
+	"instruments": [
+		{
+			"fundamental": {
+				"symbol": "AAPL",
+				"high52": 260.1,
+				"low52": 164.08,
+				"dividendAmount": 1,
+				"dividendYield": 0.44,
+				"peRatio": 37.42,
+				"eps": 6.08,
+				"marketCap": 3421232000000,
+				"sharesOutstanding": 15115823000
+			},
+			"cusip": "037833100",
+			"symbol": "AAPL",
+			"description": "Apple Inc",
+			"exchange": "NASDAQ",
+			"assetType": "EQUITY"
+		}
+	]
+}`
+
+func TestSearchInstruments(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		projection string
+		fixture    string
+		want       []brokerage.Instrument
+	}{
+		{
+			name:       "symbol search",
+			query:      "VTI",
+			projection: schwab.ProjectionSymbolSearch,
+			fixture:    symbolSearchFixture,
+			want: []brokerage.Instrument{
+				{Symbol: "VTI", CUSIP: "922908769", Description: "VANGUARD TOTAL STOCK MARKET ETF", Exchange: "NYSE Arca", AssetType: "ETF"},
+			},
+		},
+		{
+			name:       "description search",
+			query:      "vanguard total",
+			projection: schwab.ProjectionDescSearch,
+			fixture:    descSearchFixture,
+			want: []brokerage.Instrument{
+				{Symbol: "VTI", CUSIP: "922908769", Description: "VANGUARD TOTAL STOCK MARKET ETF", Exchange: "NYSE Arca", AssetType: "ETF"},
+				{Symbol: "VXUS", CUSIP: "921909768", Description: "VANGUARD TOTAL INTERNATIONAL STOCK ETF", Exchange: "NASDAQ", AssetType: "ETF"},
+				{Symbol: "BND", CUSIP: "921937835", Description: "VANGUARD TOTAL BOND MARKET ETF", Exchange: "NASDAQ", AssetType: "ETF"},
+			},
+		},
+		{
+			name:       "fundamental",
+			query:      "AAPL",
+			projection: schwab.ProjectionFundamental,
+			fixture:    fundamentalFixture,
+			want: []brokerage.Instrument{
+				{
+					Symbol: "AAPL", CUSIP: "037833100", Description: "Apple Inc", Exchange: "NASDAQ", AssetType: "EQUITY",
+					Fundamental: &brokerage.Fundamentals{
+						PERatio:           37.42,
+						EPS:               6.08,
+						DividendYield:     0.44,
+						DividendAmount:    1,
+						MarketCap:         3421232000000,
+						High52Week:        260.1,
+						Low52Week:         164.08,
+						SharesOutstanding: 15115823000,
+					},
+				},
+			},
+		},
+		{
+			name:       "no match",
+			query:      "NOPE",
+			projection: schwab.ProjectionSymbolSearch,
+			fixture:    `{"instruments": []}`,
+			want:       []brokerage.Instrument{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := schwabtest.NewServer()
+			defer mock.Close()
+			mock.SetResponse("GET", instrumentsRequestPath, http.StatusOK, tt.fixture)
+			client := mock.NewClient()
+
+			instruments, err := client.SearchInstruments(t.Context(), tt.query, tt.projection)
+			if err != nil {
+				t.Fatalf("SearchInstruments() error = %v", err)
+			}
+			if !reflect.DeepEqual(instruments, tt.want) {
+				t.Errorf("SearchInstruments() = %+v, want %+v", instruments, tt.want)
+			}
+
+			req, _ := mock.LastRequest("GET", instrumentsRequestPath)
+			query, err := url.ParseQuery(req.Query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if query.Get("symbol") != tt.query || query.Get("projection") != tt.projection {
+				t.Errorf("query = %v, want symbol %q and projection %q", query, tt.query, tt.projection)
+			}
+		})
+	}
+}
+
+func TestSearchInstrumentsValidatesPies(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	mock.SetResponse("GET", instrumentsRequestPath, http.StatusOK, symbolSearchFixture)
+	client := mock.NewClient()
+
+	pie := brokerage.Pie{Name: "total market", Slices: []brokerage.Slice{{Weight: 100, Asset: brokerage.Asset{Symbol: "VTI"}}}}
+	if err := brokerage.ValidatePie(t.Context(), client, pie); err != nil {
+		t.Errorf("ValidatePie() error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,97 @@
+package schwab
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/logging"
+)
+
+// redacted replaces secret values in log output
+const redacted = logging.Redacted
+
+// logResponse records the outcome of a single API request attempt. Response
+// bodies of failed requests are only read at debug level, and are put back so
+// the caller can still consume them.
+func (c *Client) logResponse(ctx context.Context, method, path, correlationID string, resp *http.Response, err error, latency time.Duration, secrets ...string) {
+	if err != nil {
+		c.logger.WarnContext(ctx, "schwab request failed",
+			slog.String("method", method),
+			slog.String("path", path),
+			slog.String("correlation_id", correlationID),
+			slog.Duration("latency", latency),
+			slog.String("error", c.maskAccounts(redact(err.Error(), secrets...), path)),
+		)
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("path", path),
+		slog.String("correlation_id", correlationID),
+		slog.Int("status", resp.StatusCode),
+		slog.Duration("latency", latency),
+	}
+
+	success := resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !success && c.logger.Enabled(ctx, slog.LevelDebug) {
+		body, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		if readErr == nil {
+			attrs = append(attrs, slog.String("body", c.maskAccounts(redact(string(body), secrets...), path)))
+		}
+	}
+
+	level := slog.LevelInfo
+	if !success {
+		level = slog.LevelWarn
+	}
+	c.logger.LogAttrs(ctx, level, "schwab request", attrs...)
+}
+
+// redact removes every non-empty secret from s
+func redact(s string, secrets ...string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, redacted)
+		}
+	}
+	return s
+}
+
+// maskAccounts cuts the account numbers and hashes in s, those the client
+// has resolved and the one in path, to their last four characters. Logged
+// paths are masked by the logger itself, but error messages and response
+// bodies are free text.
+func (c *Client) maskAccounts(s, path string) string {
+	var accounts []string
+	if _, rest, ok := strings.Cut(path, "/accounts/"); ok {
+		account, _, _ := strings.Cut(rest, "/")
+		account, _, _ = strings.Cut(account, "?")
+		if account != "accountNumbers" {
+			accounts = append(accounts, account)
+		}
+	}
+	c.accountHashesMu.Lock()
+	for number, hash := range c.accountHashes {
+		accounts = append(accounts, number, hash)
+	}
+	c.accountHashesMu.Unlock()
+
+	// Longest first, as an account number can be part of a hash
+	slices.SortFunc(accounts, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+	for _, account := range accounts {
+		if account != "" {
+			s = strings.ReplaceAll(s, account, logging.MaskAccount(account))
+		}
+	}
+	return logging.MaskAccountsInPath(s)
+}
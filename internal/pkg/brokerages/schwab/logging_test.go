@@ -0,0 +1,91 @@
+package schwab_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab/schwabtest"
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// logRecords decodes the JSON log lines in buf
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var records []map[string]any
+	for line := range strings.Lines(buf.String()) {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestWithLoggerRedactsSecrets(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client := mock.NewClient(schwab.WithLogger(logger))
+
+	if _, err := client.GetAccounts(t.Context()); err != nil {
+		t.Fatalf("GetAccounts() error = %v", err)
+	}
+	if err := client.Refresh(t.Context()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	client.GetAuthURLWithState()
+	if err := client.ExchangeAuthCodeForAccessToken(t.Context(), schwabtest.AuthCode); err != nil {
+		t.Fatalf("ExchangeAuthCodeForAccessToken() error = %v", err)
+	}
+
+	// A failed request is logged with its body at debug level, even when
+	// the body echoes the token and the account
+	ordersPath := "/trader/v1/accounts/" + schwabtest.AccountHash + "/orders"
+	mock.SetResponse("GET", ordersPath, http.StatusBadRequest, `{"message": "bad request for account `+
+		schwabtest.AccountHash+` with token `+schwabtest.AccessToken+`"}`)
+	if _, err := client.GetRecentOrders(t.Context(), schwabtest.AccountHash, brokerage.OrdersQuery{}); err == nil {
+		t.Fatal("GetRecentOrders() error = nil, want the mock's 400")
+	}
+
+	output := buf.String()
+	for name, secret := range map[string]string{
+		"access token":  schwabtest.AccessToken,
+		"refresh token": schwabtest.RefreshToken,
+		"auth code":     schwabtest.AuthCode,
+		"client secret": mock.Config().ClientSecret,
+		"account hash":  schwabtest.AccountHash,
+		"authorization": "Bearer ",
+	} {
+		if strings.Contains(output, secret) {
+			t.Errorf("log output contains the %s %q:\n%s", name, secret, output)
+		}
+	}
+
+	// What is logged is still useful
+	var sawRequest, sawBody bool
+	for _, record := range logRecords(t, &buf) {
+		if record["msg"] != "schwab request" {
+			continue
+		}
+		if record["method"] == nil || record["status"] == nil || record["latency"] == nil {
+			t.Errorf("request record = %v, want method, status and latency", record)
+		}
+		if path, _ := record["path"].(string); strings.HasPrefix(path, "/trader/v1/accounts/****6789/orders?") {
+			sawRequest = true
+			if body, _ := record["body"].(string); strings.Contains(body, "bad request for account") {
+				sawBody = true
+			}
+		}
+	}
+	if !sawRequest || !sawBody {
+		t.Errorf("no record of the failed request with its masked path and body:\n%s", output)
+	}
+}
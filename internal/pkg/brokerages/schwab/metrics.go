@@ -0,0 +1,68 @@
+package schwab
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Metrics receives measurements of the client's API usage. Endpoints are
+// request paths with account hashes and order IDs replaced by placeholders,
+// e.g. "/trader/v1/accounts/{accountHash}/orders", so they are safe to use as
+// metric labels. Implementations must be safe for concurrent use.
+type Metrics interface {
+	// RequestCompleted is called once per request attempt with the status
+	// class ("2xx", "4xx", ...), or "error" if no response was received
+	RequestCompleted(endpoint string, statusClass string, latency time.Duration)
+
+	// TokenRefreshed is called after every attempt to refresh the access token
+	TokenRefreshed(success bool)
+
+	// RateLimited is called whenever Schwab answers a request with 429
+	RateLimited(endpoint string)
+}
+
+// WithMetrics reports request counts, latencies, token refreshes and rate
+// limiting to metrics
+func WithMetrics(metrics Metrics) Option {
+	return func(c *Client) {
+		c.metrics = metrics
+	}
+}
+
+// noopMetrics is used when WithMetrics is not given
+type noopMetrics struct{}
+
+func (noopMetrics) RequestCompleted(string, string, time.Duration) {}
+func (noopMetrics) TokenRefreshed(bool)                            {}
+func (noopMetrics) RateLimited(string)                             {}
+
+// endpointLabel strips the query and the identifiers from an API path
+func endpointLabel(path string) string {
+	path, _, _ = strings.Cut(path, "?")
+
+	segments := strings.Split(path, "/")
+	for i := 1; i < len(segments); i++ {
+		switch segments[i-1] {
+		case "accounts":
+			if segments[i] != "accountNumbers" {
+				segments[i] = "{accountHash}"
+			}
+		case "orders":
+			segments[i] = "{orderId}"
+		case "instruments":
+			segments[i] = "{cusip}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// statusClass groups a response status for RequestCompleted
+func statusClass(resp *http.Response, err error) string {
+	if err != nil || resp == nil {
+		return "error"
+	}
+	return fmt.Sprintf("%dxx", resp.StatusCode/100)
+}
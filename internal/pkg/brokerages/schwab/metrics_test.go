@@ -0,0 +1,155 @@
+package schwab_test
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab/schwabtest"
+)
+
+// fakeMetrics records every measurement it receives
+type fakeMetrics struct {
+	mu          sync.Mutex
+	requests    []string // "endpoint statusClass"
+	latencies   []time.Duration
+	refreshes   []bool
+	rateLimited []string
+}
+
+func (m *fakeMetrics) RequestCompleted(endpoint string, statusClass string, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, endpoint+" "+statusClass)
+	m.latencies = append(m.latencies, latency)
+}
+
+func (m *fakeMetrics) TokenRefreshed(success bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refreshes = append(m.refreshes, success)
+}
+
+func (m *fakeMetrics) RateLimited(endpoint string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rateLimited = append(m.rateLimited, endpoint)
+}
+
+func TestMetricsRequestsByEndpoint(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	metrics := &fakeMetrics{}
+	client := mock.NewClient(schwab.WithMetrics(metrics))
+
+	id := placeLimitOrder(t, client, 1, "250")
+	if _, err := client.GetOrderStatus(t.Context(), schwabtest.AccountNumber, id); err != nil {
+		t.Fatalf("GetOrderStatus() error = %v", err)
+	}
+	if _, err := client.GetOrderStatus(t.Context(), schwabtest.AccountNumber, "999"); err == nil {
+		t.Fatal("GetOrderStatus() of an unknown order succeeded")
+	}
+	if _, err := client.GetQuote(t.Context(), "VTI"); err != nil {
+		t.Fatalf("GetQuote() error = %v", err)
+	}
+
+	want := []string{
+		"/trader/v1/accounts/accountNumbers 2xx",
+		"/trader/v1/accounts/{accountHash}/orders 2xx",
+		"/trader/v1/accounts/{accountHash}/orders/{orderId} 2xx",
+		"/trader/v1/accounts/{accountHash}/orders/{orderId} 4xx",
+		"/marketdata/v1/quotes 2xx",
+	}
+	if !reflect.DeepEqual(metrics.requests, want) {
+		t.Errorf("RequestCompleted() calls = %q, want %q", metrics.requests, want)
+	}
+	if slices.ContainsFunc(metrics.latencies, func(latency time.Duration) bool { return latency <= 0 }) {
+		t.Errorf("latencies = %v, want every one positive", metrics.latencies)
+	}
+	if len(metrics.refreshes) != 0 || len(metrics.rateLimited) != 0 {
+		t.Errorf("refreshes = %v, rate limited = %v, want none", metrics.refreshes, metrics.rateLimited)
+	}
+}
+
+func TestMetricsRateLimited(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	mock.SetResponse("GET", "/marketdata/v1/quotes", http.StatusTooManyRequests, `{"message": "Too Many Requests"}`)
+	metrics := &fakeMetrics{}
+	config := mock.Config()
+	config.RetryBaseDelayMillis = 1
+	client := mock.NewClientWithConfig(config, schwab.WithMetrics(metrics))
+
+	if _, err := client.GetQuote(t.Context(), "VTI"); !schwab.IsRateLimited(err) {
+		t.Fatalf("GetQuote() error = %v, want rate limited", err)
+	}
+
+	attempts := countRequests(mock, "GET", "/marketdata/v1/quotes")
+	if attempts < 2 {
+		t.Fatalf("quote requests = %d, want the 429 retried", attempts)
+	}
+	if want := slices.Repeat([]string{"/marketdata/v1/quotes"}, attempts); !reflect.DeepEqual(metrics.rateLimited, want) {
+		t.Errorf("RateLimited() calls = %q, want one per attempt", metrics.rateLimited)
+	}
+	if want := slices.Repeat([]string{"/marketdata/v1/quotes 4xx"}, attempts); !reflect.DeepEqual(metrics.requests, want) {
+		t.Errorf("RequestCompleted() calls = %q, want one 4xx per attempt", metrics.requests)
+	}
+}
+
+func TestMetricsTokenRefreshes(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	metrics := &fakeMetrics{}
+	client := mock.NewClient(schwab.WithMetrics(metrics))
+
+	if err := client.Refresh(t.Context()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	mock.SetResponse("POST", "/v1/oauth/token", http.StatusBadRequest, `{"error": "invalid_grant"}`)
+	if err := client.Refresh(t.Context()); err == nil {
+		t.Fatal("Refresh() error = nil, want the rejected refresh")
+	}
+
+	if want := []bool{true, false}; !reflect.DeepEqual(metrics.refreshes, want) {
+		t.Errorf("TokenRefreshed() calls = %v, want %v", metrics.refreshes, want)
+	}
+	if len(metrics.requests) != 0 {
+		t.Errorf("RequestCompleted() calls = %q, want token requests left out", metrics.requests)
+	}
+}
+
+func TestMetricsTransportErrors(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	metrics := &fakeMetrics{}
+	failing := &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})}
+	config := mock.Config()
+	config.RetryBaseDelayMillis = 1
+	client := mock.NewClientWithConfig(config, schwab.WithMetrics(metrics), schwab.WithHTTPClient(failing))
+
+	if _, err := client.GetQuote(t.Context(), "VTI"); err == nil {
+		t.Fatal("GetQuote() error = nil, want the transport error")
+	}
+	if len(metrics.requests) == 0 {
+		t.Fatal("RequestCompleted() was not called")
+	}
+	for _, request := range metrics.requests {
+		if request != "/marketdata/v1/quotes error" {
+			t.Errorf("RequestCompleted() call = %q, want the error status class", request)
+		}
+	}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,156 @@
+package schwab
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// RoundTripperFunc sends a single API request and returns Schwab's response
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps the sending of API requests with cross-cutting behaviour.
+// It may inspect or modify the request, call next, and inspect the response,
+// or return without calling next to short-circuit the request.
+type Middleware func(next RoundTripperFunc) RoundTripperFunc
+
+// WithMiddleware adds middleware to the chain every API request goes
+// through. The chain, from the outside in, is:
+//
+//  1. the client's own rate limiting, retry, 401 refresh and 429 handling,
+//     which run once per request
+//  2. the middleware, in the order registered: the first is outermost
+//  3. the http.Client
+//
+// Middleware therefore runs once per attempt and sees each request with its
+// Authorization header already set. The same rules as for WithHTTPClient
+// apply: a middleware must not swallow 401 or 429 responses or resend
+// requests itself. Token exchanges and refreshes bypass the chain.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(c *Client) {
+		c.middleware = append(c.middleware, middleware...)
+	}
+}
+
+// chain wraps send in middleware, the first of which ends up outermost
+func chain(send RoundTripperFunc, middleware []Middleware) RoundTripperFunc {
+	for _, mw := range slices.Backward(middleware) {
+		send = mw(send)
+	}
+	return send
+}
+
+// RequestLoggingMiddleware logs the method, path, status and latency of
+// every request attempt to logger. Headers are not logged.
+func RequestLoggingMiddleware(logger *slog.Logger) Middleware {
+	return func(next RoundTripperFunc) RoundTripperFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next(req)
+
+			attrs := []slog.Attr{
+				slog.String("method", req.Method),
+				slog.String("path", req.URL.Path),
+				slog.Duration("latency", time.Since(start)),
+			}
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+			} else {
+				attrs = append(attrs, slog.Int("status", resp.StatusCode))
+			}
+			logger.LogAttrs(req.Context(), slog.LevelInfo, "schwab request attempt", attrs...)
+
+			return resp, err
+		}
+	}
+}
+
+// DefaultLatencyBuckets are the upper bounds used by NewLatencyHistogram when
+// no buckets are given
+var DefaultLatencyBuckets = []time.Duration{
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// LatencyHistogram counts request latencies into buckets. Use its Middleware
+// to record every request attempt.
+type LatencyHistogram struct {
+	mu      sync.Mutex
+	buckets []time.Duration
+	counts  []uint64 // One per bucket, plus one for latencies above the last
+	sum     time.Duration
+}
+
+// LatencyBucket is the number of observations at or below UpperBound, not
+// counting those in smaller buckets. The overflow bucket has no UpperBound.
+type LatencyBucket struct {
+	UpperBound time.Duration
+	Count      uint64
+}
+
+// NewLatencyHistogram creates a histogram with the given ascending bucket
+// upper bounds, or DefaultLatencyBuckets if none are given
+func NewLatencyHistogram(buckets ...time.Duration) *LatencyHistogram {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = slices.Sorted(slices.Values(buckets))
+
+	return &LatencyHistogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)+1),
+	}
+}
+
+// Observe records a single latency
+func (h *LatencyHistogram) Observe(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	i, _ := slices.BinarySearch(h.buckets, latency)
+	h.counts[i]++
+	h.sum += latency
+}
+
+// Buckets returns a snapshot of the histogram
+func (h *LatencyHistogram) Buckets() []LatencyBucket {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	buckets := make([]LatencyBucket, len(h.counts))
+	for i, count := range h.counts {
+		buckets[i].Count = count
+		if i < len(h.buckets) {
+			buckets[i].UpperBound = h.buckets[i]
+		}
+	}
+	return buckets
+}
+
+// Sum returns the total of all observed latencies
+func (h *LatencyHistogram) Sum() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.sum
+}
+
+// Middleware records the latency of every request attempt, including failed ones
+func (h *LatencyHistogram) Middleware() Middleware {
+	return func(next RoundTripperFunc) RoundTripperFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next(req)
+			h.Observe(time.Since(start))
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package schwab_test
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab/schwabtest"
+)
+
+// callRecorder records the calls of the middleware it creates
+type callRecorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *callRecorder) record(call string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+// middleware returns a middleware recording "name before" and "name after"
+// around the request, with the Authorization header it saw
+func (r *callRecorder) middleware(name string) schwab.Middleware {
+	return func(next schwab.RoundTripperFunc) schwab.RoundTripperFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			r.record(name + " before " + req.Header.Get("Authorization"))
+			resp, err := next(req)
+			r.record(name + " after")
+			return resp, err
+		}
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	var recorder callRecorder
+	client := mock.NewClient(
+		schwab.WithMiddleware(recorder.middleware("first")),
+		schwab.WithMiddleware(recorder.middleware("second")),
+	)
+
+	if _, err := client.GetQuote(t.Context(), "VTI"); err != nil {
+		t.Fatalf("GetQuote() error = %v", err)
+	}
+
+	bearer := "Bearer " + schwabtest.AccessToken
+	want := []string{"first before " + bearer, "second before " + bearer, "second after", "first after"}
+	if !reflect.DeepEqual(recorder.calls, want) {
+		t.Errorf("middleware calls = %q, want %q", recorder.calls, want)
+	}
+}
+
+func TestMiddlewareShortCircuits(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	var recorder callRecorder
+
+	// cached answers quote requests itself, never calling next
+	cached := func(next schwab.RoundTripperFunc) schwab.RoundTripperFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path != "/marketdata/v1/quotes" {
+				return next(req)
+			}
+			recorder.record("cached")
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body: io.NopCloser(strings.NewReader(
+					`{"VTI": {"symbol": "VTI", "assetType": "EQUITY", "quote": {"bidPrice": 99.99, "askPrice": 100.01, "lastPrice": 100}}}`)),
+				Request: req,
+			}, nil
+		}
+	}
+	client := mock.NewClient(schwab.WithMiddleware(recorder.middleware("outer"), cached, recorder.middleware("inner")))
+
+	quote, err := client.GetQuote(t.Context(), "VTI")
+	if err != nil {
+		t.Fatalf("GetQuote() error = %v", err)
+	}
+	if quote.Last.String() != "100" {
+		t.Errorf("quote = %+v, want the cached price of 100", quote)
+	}
+
+	want := []string{"outer before Bearer " + schwabtest.AccessToken, "cached", "outer after"}
+	if !reflect.DeepEqual(recorder.calls, want) {
+		t.Errorf("middleware calls = %q, want %q without the inner middleware", recorder.calls, want)
+	}
+	if n := countRequests(mock, "GET", "/marketdata/v1/quotes"); n != 0 {
+		t.Errorf("quote requests reaching Schwab = %d, want 0", n)
+	}
+}
@@ -0,0 +1,123 @@
+package schwab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const moversPath = "/marketdata/v1/movers/%s"
+
+// moverIndexes are the index symbols accepted by the movers endpoint
+var moverIndexes = []string{
+	"$DJI",
+	"$COMPX",
+	"$SPX",
+	"NYSE",
+	"NASDAQ",
+	"OTCBB",
+	"INDEX_ALL",
+	"EQUITY_ALL",
+	"OPTION_ALL",
+	"OPTION_PUT",
+	"OPTION_CALL",
+}
+
+// moverSorts are the sort orders accepted by the movers endpoint
+var moverSorts = []string{
+	"VOLUME",
+	"TRADES",
+	"PERCENT_CHANGE_UP",
+	"PERCENT_CHANGE_DOWN",
+}
+
+// moverFrequencies are the minimum percent-change filters accepted by the
+// movers endpoint
+var moverFrequencies = []int{0, 1, 5, 10, 30, 60}
+
+// Mover is a security among the top movers of an index
+type Mover struct {
+	Symbol           string
+	Description      string
+	LastPrice        float64
+	NetChange        float64
+	NetPercentChange float64
+	Volume           int64
+	Trades           int64
+}
+
+// GetMovers retrieves the top movers of an index. sort is optional; frequency
+// filters movers by minimum percent change and must be one of 0, 1, 5, 10, 30
+// or 60.
+// Documentation: https://developer.schwab.com/products/trader-api--individual/details/specifications/Market%20Data%20Production
+// Endpoint: GET /marketdata/v1/movers/{symbol_id}
+func (c *Client) GetMovers(ctx context.Context, index string, sort string, frequency int) ([]Mover, error) {
+	if !slices.Contains(moverIndexes, index) {
+		return nil, fmt.Errorf("unsupported movers index %q, must be one of: %s", index, strings.Join(moverIndexes, ", "))
+	}
+	if sort != "" && !slices.Contains(moverSorts, sort) {
+		return nil, fmt.Errorf("unsupported movers sort %q, must be one of: %s", sort, strings.Join(moverSorts, ", "))
+	}
+	if !slices.Contains(moverFrequencies, frequency) {
+		return nil, fmt.Errorf("unsupported movers frequency %d, must be one of: 0, 1, 5, 10, 30, 60", frequency)
+	}
+
+	query := url.Values{}
+	if sort != "" {
+		query.Set("sort", sort)
+	}
+	query.Set("frequency", strconv.Itoa(frequency))
+
+	path := fmt.Sprintf(moversPath, url.PathEscape(index)) + "?" + query.Encode()
+	resp, err := c.makeRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read movers response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("get movers", resp, body)
+	}
+
+	var moversResponse struct {
+		Screeners []struct {
+			Symbol           string  `json:"symbol"`
+			Description      string  `json:"description"`
+			LastPrice        float64 `json:"lastPrice"`
+			NetChange        float64 `json:"netChange"`
+			NetPercentChange float64 `json:"netPercentChange"`
+			Volume           int64   `json:"volume"`
+			Trades           int64   `json:"trades"`
+		} `json:"screeners"`
+	}
+
+	if err := json.Unmarshal(body, &moversResponse); err != nil {
+		return nil, fmt.Errorf("failed to parse movers response: %w", err)
+	}
+
+	movers := make([]Mover, 0, len(moversResponse.Screeners))
+	for _, sm := range moversResponse.Screeners {
+		movers = append(movers, Mover{
+			Symbol:           sm.Symbol,
+			Description:      sm.Description,
+			LastPrice:        sm.LastPrice,
+			NetChange:        sm.NetChange,
+			NetPercentChange: sm.NetPercentChange,
+			Volume:           sm.Volume,
+			Trades:           sm.Trades,
+		})
+	}
+
+	return movers, nil
+}
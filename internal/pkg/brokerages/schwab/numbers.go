@@ -0,0 +1,32 @@
+package schwab
+
+import (
+	"encoding/json"
+
+	"github.com/shopspring/decimal"
+)
+
+// Schwab's responses and requests carry prices and quantities as JSON
+// numbers. Responses are decoded into json.Number so that the digits Schwab
+// sent reach decimal.Decimal without passing through a float64, and
+// requests are built with json.Number so that decimals are sent as numbers
+// rather than the strings decimal.Decimal marshals to.
+
+// decimalFrom converts a number decoded from a response, treating a missing
+// or null value as zero. The decoder has already checked that n is a valid
+// JSON number, which decimal always parses.
+func decimalFrom(n json.Number) decimal.Decimal {
+	if n == "" {
+		return decimal.Zero
+	}
+	d, err := decimal.NewFromString(n.String())
+	if err != nil {
+		return decimal.Zero
+	}
+	return d
+}
+
+// jsonNumber converts d for a request body
+func jsonNumber(d decimal.Decimal) json.Number {
+	return json.Number(d.String())
+}
@@ -0,0 +1,110 @@
+package schwab
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/logging"
+)
+
+// defaultTimeout is the HTTP timeout used when WithTimeout is not given
+const defaultTimeout = 30 * time.Second
+
+// Option configures optional behaviour of a Client
+type Option func(*Client)
+
+// Clock tells the client the current time when it evaluates token expiry
+type Clock interface {
+	Now() time.Time
+}
+
+// TimerClock is a Clock that can also wait for time to pass. Given one,
+// WithClock drives the timers of StartAutoRefresh as well.
+type TimerClock interface {
+	Clock
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock used when WithClock is not given
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// WithTimeout sets the timeout applied to every HTTP request made by the
+// client. A timeout of 0 disables the timeout entirely. It has no effect when
+// combined with WithHTTPClient.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithHTTPClient makes the client send all requests, including token
+// exchanges and refreshes, through httpClient. Use it to supply a proxy,
+// custom TLS settings or an instrumented Transport.
+//
+// Rate limiting, retries and the refresh-on-401 logic run above
+// httpClient.Do, so a wrapping Transport sees every individual attempt. It
+// must pass the Authorization header through untouched and return Schwab's
+// responses as-is: swallowing a 401 or retrying POSTs itself would defeat the
+// client's token refresh and duplicate-order protection.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithCodeVerifierGenerator replaces the function used to generate PKCE code
+// verifiers, allowing deterministic verifiers in tests
+func WithCodeVerifierGenerator(generate func() string) Option {
+	return func(c *Client) {
+		c.generateCodeVerifier = generate
+	}
+}
+
+// WithLogger logs every API request (method, path, status and latency) and
+// token refresh to logger. At debug level the bodies of non-2xx responses are
+// logged too. Authorization headers and token values are never logged.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logging.RedactLogger(logger)
+	}
+}
+
+// WithTokenStore persists tokens in store instead of the Config.TokenFile
+// file. Refreshed tokens are saved through the store as well, so other
+// processes sharing it always see the latest refresh token.
+func WithTokenStore(store TokenStore) Option {
+	return func(c *Client) {
+		c.tokenStore = store
+	}
+}
+
+// WithAuthTimeout sets how long Authenticate waits for the user to complete
+// the OAuth login. It defaults to defaultAuthTimeout.
+func WithAuthTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.authTimeout = timeout
+	}
+}
+
+// WithURLOpener replaces the function Authenticate uses to send the user to
+// the authorization URL, which by default opens the system browser. Tests can
+// use it to follow the redirect themselves.
+func WithURLOpener(open func(url string) error) Option {
+	return func(c *Client) {
+		c.openURL = open
+	}
+}
+
+// WithClock replaces the clock used to stamp and check token expiry, so tests
+// can move time across the refresh threshold without sleeping. A TimerClock
+// also decides when StartAutoRefresh wakes up.
+func WithClock(clock Clock) Option {
+	return func(c *Client) {
+		c.clock = clock
+	}
+}
@@ -0,0 +1,132 @@
+package schwab_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab/schwabtest"
+)
+
+// slowClient returns a client whose API requests go to a server that takes
+// delay to answer each one, or blocks until the test ends when delay is
+// negative
+func slowClient(t *testing.T, delay time.Duration, opts ...schwab.Option) *schwab.Client {
+	t.Helper()
+
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if delay < 0 {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[]`))
+	}))
+	t.Cleanup(func() {
+		close(release)
+		slow.Close()
+	})
+
+	mock := schwabtest.NewServer()
+	t.Cleanup(mock.Close)
+	config := mock.Config()
+	config.BaseURL = slow.URL
+	config.MaxRetryAttempts = 1
+	return mock.NewClientWithConfig(config, opts...)
+}
+
+func TestWithTimeoutAbandonsHungServer(t *testing.T) {
+	client := slowClient(t, -1, schwab.WithTimeout(50*time.Millisecond))
+
+	start := time.Now()
+	_, err := client.GetAccounts(t.Context())
+	if err == nil {
+		t.Fatal("GetAccounts() succeeded against a hung server, want a timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("GetAccounts() returned after %v, want it abandoned after about 50ms", elapsed)
+	}
+}
+
+func TestWithTimeoutAllowsSlowResponseWithinTimeout(t *testing.T) {
+	client := slowClient(t, 50*time.Millisecond, schwab.WithTimeout(2*time.Second))
+
+	if _, err := client.GetAccounts(t.Context()); err != nil {
+		t.Fatalf("GetAccounts() error = %v, want the slow response within the timeout", err)
+	}
+}
+
+func TestWithTimeoutZeroDisablesTimeout(t *testing.T) {
+	// Slower than the tightest timeout above, but a zero timeout waits
+	client := slowClient(t, 200*time.Millisecond, schwab.WithTimeout(0))
+
+	if _, err := client.GetAccounts(t.Context()); err != nil {
+		t.Fatalf("GetAccounts() error = %v, want no timeout with WithTimeout(0)", err)
+	}
+}
+
+// countingTransport records the requests it sends on to http.DefaultTransport
+type countingTransport struct {
+	mu       sync.Mutex
+	requests []string // Method and path of each request
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.requests = append(t.requests, req.Method+" "+req.URL.Path)
+	t.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func (t *countingTransport) sent() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.requests)
+}
+
+func TestWithHTTPClientSendsThroughTransport(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	transport := &countingTransport{}
+	client := mock.NewClient(schwab.WithHTTPClient(&http.Client{Transport: transport}))
+
+	if _, err := client.GetAccounts(t.Context()); err != nil {
+		t.Fatalf("GetAccounts() error = %v", err)
+	}
+	if err := client.Refresh(t.Context()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	// Every request the mock received went through the transport, token
+	// refreshes included
+	var received []string
+	for _, req := range mock.Requests() {
+		received = append(received, req.Method+" "+req.Path)
+	}
+	sent := transport.sent()
+	if !slices.Equal(sent, received) {
+		t.Errorf("transport sent %v, want what the mock received, %v", sent, received)
+	}
+	if !slices.Contains(sent, "POST /v1/oauth/token") {
+		t.Errorf("transport sent %v, want the token refresh among them", sent)
+	}
+}
+
+func TestWithHTTPClientIgnoresWithTimeout(t *testing.T) {
+	// The injected client's own timeout, none here, applies instead
+	client := slowClient(t, 200*time.Millisecond,
+		schwab.WithHTTPClient(&http.Client{}), schwab.WithTimeout(50*time.Millisecond))
+
+	if _, err := client.GetAccounts(t.Context()); err != nil {
+		t.Fatalf("GetAccounts() error = %v, want WithTimeout ignored with WithHTTPClient", err)
+	}
+}
@@ -0,0 +1,142 @@
+package schwab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// PlaceOrderStrategy submits an OCO or TRIGGER strategy, e.g. a buy with an
+// attached stop. The returned Order is the strategy's top-level order, with
+// one child per nested strategy; Schwab only reports the top-level order ID
+// on submission, so use GetOrderStatus to learn the children's IDs.
+// Documentation: https://developer.schwab.com/products/trader-api--individual/details/specifications/Retail%20Trader%20API%20Production
+// Endpoint: POST /trader/v1/accounts/{accountId}/orders
+func (c *Client) PlaceOrderStrategy(ctx context.Context, accountID string, strategy brokerage.OrderStrategy) (*brokerage.Order, error) {
+	if err := strategy.Validate(); err != nil {
+		return nil, err
+	}
+	if c.dryRun != nil {
+		return nil, errors.New("order strategies are not supported in dry-run mode")
+	}
+
+	strategy, err := c.resolveStrategyAmounts(ctx, strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	schwabStrategy, err := buildStrategyMap(strategy)
+	if err != nil {
+		return nil, err
+	}
+	strategyJSON, err := json.Marshal(schwabStrategy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal order strategy: %w", err)
+	}
+
+	accountHash, err := c.resolveAccountID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	path := fmt.Sprintf(ordersPath, accountHash)
+	resp, err := c.makeRequest(ctx, "POST", path, bytes.NewReader(strategyJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read order response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("place order strategy", resp, body)
+	}
+
+	order := submittedStrategyOrder(strategy, c.clock.Now())
+	order.ID = orderIDFromLocation(resp)
+	order.RawResponse = newRawResponse(resp, body)
+	return &order, nil
+}
+
+// resolveStrategyAmounts converts every dollar-denominated order in strategy
+// to whole shares
+func (c *Client) resolveStrategyAmounts(ctx context.Context, strategy brokerage.OrderStrategy) (brokerage.OrderStrategy, error) {
+	if strategy.Order != nil {
+		order, err := brokerage.ResolveAmount(ctx, c, *strategy.Order, false)
+		if err != nil {
+			return strategy, err
+		}
+		strategy.Order = &order
+	}
+
+	children := make([]brokerage.OrderStrategy, len(strategy.Children))
+	for i, child := range strategy.Children {
+		resolved, err := c.resolveStrategyAmounts(ctx, child)
+		if err != nil {
+			return strategy, err
+		}
+		children[i] = resolved
+	}
+	strategy.Children = children
+
+	return strategy, nil
+}
+
+// buildStrategyMap builds the Schwab order for a validated strategy
+func buildStrategyMap(strategy brokerage.OrderStrategy) (map[string]interface{}, error) {
+	schwabOrder := map[string]interface{}{}
+	if strategy.Order != nil {
+		var err error
+		if schwabOrder, err = buildOrderMap(*strategy.Order); err != nil {
+			return nil, err
+		}
+	}
+	schwabOrder["orderStrategyType"] = string(strategy.Type)
+
+	if len(strategy.Children) > 0 {
+		children := make([]map[string]interface{}, 0, len(strategy.Children))
+		for _, child := range strategy.Children {
+			childOrder, err := buildStrategyMap(child)
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, childOrder)
+		}
+		schwabOrder["childOrderStrategies"] = children
+	}
+
+	return schwabOrder, nil
+}
+
+// submittedStrategyOrder describes a strategy Schwab has just accepted
+func submittedStrategyOrder(strategy brokerage.OrderStrategy, submittedAt time.Time) brokerage.Order {
+	order := brokerage.Order{
+		Status:      brokerage.OrderStatusPending,
+		Strategy:    strategy.Type,
+		SubmittedAt: submittedAt,
+	}
+	if strategy.Order != nil {
+		order.Symbol = strategy.Order.Symbol
+		order.Action = strategy.Order.Action
+		order.Type = strategy.Order.Type
+		order.Quantity = strategy.Order.Quantity
+		order.LimitPrice = strategy.Order.LimitPrice
+		order.StopPrice = strategy.Order.StopPrice
+	}
+
+	for _, child := range strategy.Children {
+		order.Children = append(order.Children, submittedStrategyOrder(child, submittedAt))
+	}
+
+	return order
+}
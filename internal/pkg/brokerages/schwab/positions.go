@@ -0,0 +1,91 @@
+package schwab
+
+import (
+	"encoding/json"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// navPlaces is the number of decimal places the price of a cash
+// equivalent, a fund's net asset value, is given to
+const navPlaces = 4
+
+// schwabPosition is a position as the accounts endpoint returns it
+type schwabPosition struct {
+	LongQuantity      json.Number `json:"longQuantity"`
+	ShortQuantity     json.Number `json:"shortQuantity"`
+	AveragePrice      json.Number `json:"averagePrice"`
+	AverageLongPrice  json.Number `json:"averageLongPrice"`
+	AverageShortPrice json.Number `json:"averageShortPrice"`
+	MarketValue       json.Number `json:"marketValue"`
+	DayProfitLoss     json.Number `json:"currentDayProfitLoss"`
+	Instrument        struct {
+		Symbol      string `json:"symbol"`
+		Description string `json:"description"`
+		AssetType   string `json:"assetType"`
+		Type        string `json:"type"` // Refines AssetType, e.g. EXCHANGE_TRADED_FUND
+	} `json:"instrument"`
+}
+
+// assetType maps the instrument's type onto the brokerage.AssetType
+// constants. Schwab files ETFs as collective investments, and the other
+// types it shares with brokerage pass through unchanged.
+func (p schwabPosition) assetType() string {
+	if p.Instrument.AssetType == "COLLECTIVE_INVESTMENT" && p.Instrument.Type == "EXCHANGE_TRADED_FUND" {
+		return brokerage.AssetTypeETF
+	}
+	return p.Instrument.AssetType
+}
+
+// position maps p onto a brokerage.Position. The side with shares sets the
+// average price, falling back to averagePrice for responses without the
+// per-side averages, and the market value takes the sign of the net
+// quantity so that shorts are valued, and their P/L worked out, as
+// negative holdings.
+func (p schwabPosition) position() brokerage.Position {
+	long := decimalFrom(p.LongQuantity)
+	short := decimalFrom(p.ShortQuantity)
+	quantity := long.Sub(short)
+
+	averageLongPrice := decimalFrom(p.AverageLongPrice)
+	if p.AverageLongPrice == "" {
+		averageLongPrice = decimalFrom(p.AveragePrice)
+	}
+	averageShortPrice := decimalFrom(p.AverageShortPrice)
+	if p.AverageShortPrice == "" {
+		averageShortPrice = decimalFrom(p.AveragePrice)
+	}
+
+	position := brokerage.Position{
+		Symbol:        p.Instrument.Symbol,
+		Description:   p.Instrument.Description,
+		AssetType:     p.assetType(),
+		Quantity:      quantity,
+		LongQuantity:  long,
+		ShortQuantity: short,
+		AveragePrice:  averageLongPrice,
+		MarketValue:   decimalFrom(p.MarketValue).Abs(),
+		DayPL:         decimalFrom(p.DayProfitLoss),
+	}
+	if quantity.IsNegative() {
+		position.AveragePrice = averageShortPrice
+		position.MarketValue = position.MarketValue.Neg()
+	}
+
+	if !quantity.IsZero() {
+		if position.IsCashEquivalent() {
+			position.CurrentPrice = position.MarketValue.Abs().DivRound(quantity.Abs(), navPlaces)
+		} else {
+			position.CurrentPrice = position.MarketValue.Abs().Div(quantity.Abs())
+		}
+	}
+
+	// What was paid for the long shares less what was received for the
+	// short ones
+	cost := averageLongPrice.Mul(long).Sub(averageShortPrice.Mul(short))
+	position.UnrealizedPL = position.MarketValue.Sub(cost)
+	if !cost.IsZero() {
+		position.UnrealizedPLPct = position.UnrealizedPL.Div(cost.Abs()).InexactFloat64() * 100
+	}
+	return position
+}
@@ -0,0 +1,162 @@
+package schwab_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab/schwabtest"
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+// positionsFixture is an accounts response holding a long ETF, a short
+// stock, a sweep money market fund and a position from a response without
+// the per-side average prices. Schwab reports the market value of shorts as
+// a negative number.
+const positionsFixture = `{
+	"securitiesAccount": {
+		"type": "MARGIN",
+		"accountNumber": "12345678",
+		"positions": [
+			{
+				"shortQuantity": 0,
+				"averagePrice": 201.5,
+				"currentDayProfitLoss": 12.5,
+				"longQuantity": 10,
+				"averageLongPrice": 200,
+				"marketValue": 2500,
+				"instrument": {
+					"assetType": "COLLECTIVE_INVESTMENT",
+					"type": "EXCHANGE_TRADED_FUND",
+					"symbol": "VTI",
+					"description": "Vanguard Total Stock Market ETF"
+				}
+			},
+			{
+				"shortQuantity": 5,
+				"averagePrice": 50,
+				"currentDayProfitLoss": -7.5,
+				"longQuantity": 0,
+				"averageShortPrice": 50,
+				"marketValue": -200,
+				"instrument": {
+					"assetType": "EQUITY",
+					"symbol": "XYZ",
+					"description": "XYZ Corp"
+				}
+			},
+			{
+				"shortQuantity": 0,
+				"averagePrice": 1,
+				"currentDayProfitLoss": 0,
+				"longQuantity": 1234.5678,
+				"averageLongPrice": 1,
+				"marketValue": 1234.5678,
+				"instrument": {
+					"assetType": "CASH_EQUIVALENT",
+					"type": "MONEY_MARKET_FUND",
+					"symbol": "SWVXX",
+					"description": "Schwab Value Advantage Money Fund"
+				}
+			},
+			{
+				"shortQuantity": 0,
+				"averagePrice": 80,
+				"currentDayProfitLoss": 0,
+				"longQuantity": 3,
+				"marketValue": 210,
+				"instrument": {
+					"assetType": "EQUITY",
+					"symbol": "OLD"
+				}
+			}
+		]
+	}
+}`
+
+func TestGetPositionsFixture(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	mock.SetResponse("GET", "/trader/v1/accounts/"+schwabtest.AccountHash, http.StatusOK, positionsFixture)
+	client := mock.NewClient()
+
+	positions, err := client.GetPositions(t.Context(), schwabtest.AccountHash)
+	if err != nil {
+		t.Fatalf("GetPositions() error = %v", err)
+	}
+
+	tests := []struct {
+		symbol          string
+		assetType       string
+		quantity        string
+		long, short     string
+		averagePrice    string
+		currentPrice    string
+		marketValue     string
+		unrealizedPL    string
+		unrealizedPLPct float64
+		cashEquivalent  bool
+	}{
+		{
+			symbol: "VTI", assetType: brokerage.AssetTypeETF,
+			quantity: "10", long: "10", short: "0",
+			averagePrice: "200", currentPrice: "250", marketValue: "2500",
+			unrealizedPL: "500", unrealizedPLPct: 25,
+		},
+		{
+			// Shorted at 50 and now at 40: a 50 dollar gain
+			symbol: "XYZ", assetType: brokerage.AssetTypeEquity,
+			quantity: "-5", long: "0", short: "5",
+			averagePrice: "50", currentPrice: "40", marketValue: "-200",
+			unrealizedPL: "50", unrealizedPLPct: 20,
+		},
+		{
+			symbol: "SWVXX", assetType: brokerage.AssetTypeCashEquivalent,
+			quantity: "1234.5678", long: "1234.5678", short: "0",
+			averagePrice: "1", currentPrice: "1", marketValue: "1234.5678",
+			unrealizedPL: "0", unrealizedPLPct: 0, cashEquivalent: true,
+		},
+		{
+			// Without averageLongPrice the average price is used
+			symbol: "OLD", assetType: brokerage.AssetTypeEquity,
+			quantity: "3", long: "3", short: "0",
+			averagePrice: "80", currentPrice: "70", marketValue: "210",
+			unrealizedPL: "-30", unrealizedPLPct: -12.5,
+		},
+	}
+
+	if len(positions) != len(tests) {
+		t.Fatalf("GetPositions() returned %d positions, want %d", len(positions), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.symbol, func(t *testing.T) {
+			got := positions[i]
+			if got.Symbol != tt.symbol || got.AssetType != tt.assetType {
+				t.Errorf("position %d = %s %s, want %s %s", i, got.Symbol, got.AssetType, tt.symbol, tt.assetType)
+			}
+			for _, field := range []struct {
+				name string
+				got  decimal.Decimal
+				want string
+			}{
+				{"Quantity", got.Quantity, tt.quantity},
+				{"LongQuantity", got.LongQuantity, tt.long},
+				{"ShortQuantity", got.ShortQuantity, tt.short},
+				{"AveragePrice", got.AveragePrice, tt.averagePrice},
+				{"CurrentPrice", got.CurrentPrice, tt.currentPrice},
+				{"MarketValue", got.MarketValue, tt.marketValue},
+				{"UnrealizedPL", got.UnrealizedPL, tt.unrealizedPL},
+			} {
+				if !field.got.Equal(decimal.RequireFromString(field.want)) {
+					t.Errorf("%s = %s, want %s", field.name, field.got, field.want)
+				}
+			}
+			if got.UnrealizedPLPct != tt.unrealizedPLPct {
+				t.Errorf("UnrealizedPLPct = %v, want %v", got.UnrealizedPLPct, tt.unrealizedPLPct)
+			}
+			if got.IsCashEquivalent() != tt.cashEquivalent {
+				t.Errorf("IsCashEquivalent() = %t, want %t", got.IsCashEquivalent(), tt.cashEquivalent)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package schwab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+const previewOrderPath = "/trader/v1/accounts/%s/previewOrder"
+
+// schwabValidationMessage is an entry of Schwab's orderValidationResult
+type schwabValidationMessage struct {
+	ValidationRuleName string `json:"validationRuleName"`
+	Message            string `json:"message"`
+	ActivityMessage    string `json:"activityMessage"`
+}
+
+// text returns the most descriptive message Schwab gave for a validation rule
+func (m schwabValidationMessage) text() string {
+	switch {
+	case m.Message != "":
+		return m.Message
+	case m.ActivityMessage != "":
+		return m.ActivityMessage
+	default:
+		return m.ValidationRuleName
+	}
+}
+
+// PreviewOrder asks Schwab to validate an order and estimate its cost without
+// placing it. When Schwab would reject the order, the preview is returned
+// together with a *brokerage.OrderRejectedError listing the reasons.
+// Documentation: https://developer.schwab.com/products/trader-api--individual/details/specifications/Retail%20Trader%20API%20Production
+// Endpoint: POST /trader/v1/accounts/{accountId}/previewOrder
+func (c *Client) PreviewOrder(ctx context.Context, accountID string, order brokerage.OrderRequest) (*brokerage.OrderPreview, error) {
+	order, err := brokerage.ResolveAmount(ctx, c, order, false)
+	if err != nil {
+		return nil, err
+	}
+
+	orderJSON, err := buildOrderJSON(order)
+	if err != nil {
+		return nil, err
+	}
+
+	accountHash, err := c.resolveAccountID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	path := fmt.Sprintf(previewOrderPath, accountHash)
+	resp, err := c.makeRequest(ctx, "POST", path, strings.NewReader(string(orderJSON)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read preview order response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("preview order", resp, body)
+	}
+
+	var schwabPreview struct {
+		OrderStrategy struct {
+			OrderBalance struct {
+				OrderValue             json.Number `json:"orderValue"`
+				ProjectedAvailableFund json.Number `json:"projectedAvailableFund"`
+				ProjectedBuyingPower   json.Number `json:"projectedBuyingPower"`
+				ProjectedCommission    json.Number `json:"projectedCommission"`
+			} `json:"orderBalance"`
+		} `json:"orderStrategy"`
+		OrderValidationResult struct {
+			Alerts  []schwabValidationMessage `json:"alerts"`
+			Warns   []schwabValidationMessage `json:"warns"`
+			Reviews []schwabValidationMessage `json:"reviews"`
+			Rejects []schwabValidationMessage `json:"rejects"`
+		} `json:"orderValidationResult"`
+		CommissionAndFee struct {
+			Commission struct {
+				CommissionLegs []struct {
+					CommissionValues []struct {
+						Value json.Number `json:"value"`
+					} `json:"commissionValues"`
+				} `json:"commissionLegs"`
+			} `json:"commission"`
+			Fee struct {
+				FeeLegs []struct {
+					FeeValues []struct {
+						Value json.Number `json:"value"`
+					} `json:"feeValues"`
+				} `json:"feeLegs"`
+			} `json:"fee"`
+		} `json:"commissionAndFee"`
+	}
+
+	if err := json.Unmarshal(body, &schwabPreview); err != nil {
+		return nil, fmt.Errorf("failed to parse preview order response: %w", err)
+	}
+
+	balance := schwabPreview.OrderStrategy.OrderBalance
+	preview := &brokerage.OrderPreview{
+		EstimatedTotal:          decimalFrom(balance.OrderValue),
+		ProjectedBuyingPower:    decimalFrom(balance.ProjectedBuyingPower),
+		ProjectedAvailableFunds: decimalFrom(balance.ProjectedAvailableFund),
+		RawResponse:             newRawResponse(resp, body),
+	}
+
+	for _, leg := range schwabPreview.CommissionAndFee.Commission.CommissionLegs {
+		for _, value := range leg.CommissionValues {
+			preview.Commission = preview.Commission.Add(decimalFrom(value.Value))
+		}
+	}
+	for _, leg := range schwabPreview.CommissionAndFee.Fee.FeeLegs {
+		for _, value := range leg.FeeValues {
+			preview.Fees = preview.Fees.Add(decimalFrom(value.Value))
+		}
+	}
+
+	validation := schwabPreview.OrderValidationResult
+	for _, messages := range [][]schwabValidationMessage{validation.Alerts, validation.Warns, validation.Reviews} {
+		for _, message := range messages {
+			preview.Warnings = append(preview.Warnings, message.text())
+		}
+	}
+	for _, message := range validation.Rejects {
+		preview.Rejections = append(preview.Rejections, message.text())
+	}
+
+	if len(preview.Rejections) > 0 {
+		return preview, &brokerage.OrderRejectedError{
+			Symbol:   order.Symbol,
+			Messages: slices.Concat(preview.Rejections, preview.Warnings),
+		}
+	}
+
+	return preview, nil
+}
@@ -0,0 +1,157 @@
+package schwab_test
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab/schwabtest"
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+const previewOrderRequestPath = "/trader/v1/accounts/" + schwabtest.AccountHash + "/previewOrder"
+
+// acceptedPreviewFixture previews a buy of 10 VTI at $280 with a review
+// message, commission and a regulatory fee
+const acceptedPreviewFixture = `{
+	"orderId": 0,
+	"orderStrategy": {
+		"accountNumber": "12345678",
+		"orderType": "LIMIT",
+		"session": "NORMAL",
+		"duration": "DAY",
+		"price": 280,
+		"orderBalance": {
+			"orderValue": 2800,
+			"projectedAvailableFund": 2199.99,
+			"projectedBuyingPower": 2199.99,
+			"projectedCommission": 0
+		}
+	},
+	"orderValidationResult": {
+		"reviews": [{"validationRuleName": "NEAR_CLOSE", "message": "The market closes in less than 15 minutes"}]
+	},
+	"commissionAndFee": {
+		"commission": {"commissionLegs": [{"commissionValues": [{"value": 0, "type": "COMMISSION"}]}]},
+		"fee": {"feeLegs": [{"feeValues": [{"value": 0.01, "type": "SEC_FEE"}, {"value": 0, "type": "OPT_REG_FEE"}]}]}
+	}
+}`
+
+// rejectedPreviewFixture previews a buy the account cannot afford
+const rejectedPreviewFixture = `{
+	"orderStrategy": {
+		"orderBalance": {
+			"orderValue": 28000,
+			"projectedAvailableFund": -22999.99,
+			"projectedBuyingPower": -22999.99,
+			"projectedCommission": 0
+		}
+	},
+	"orderValidationResult": {
+		"warns": [{"validationRuleName": "DAY_TRADE", "activityMessage": "This order may result in a day trade"}],
+		"rejects": [{"validationRuleName": "BUYING_POWER", "message": "Insufficient buying power for this order"}]
+	}
+}`
+
+// previewBuy returns a limit buy of quantity VTI at $280
+func previewBuy(quantity int64) brokerage.OrderRequest {
+	return brokerage.OrderRequest{
+		Symbol:     "VTI",
+		Action:     brokerage.OrderActionBuy,
+		Type:       brokerage.OrderTypeLimit,
+		Quantity:   decimal.NewFromInt(quantity),
+		LimitPrice: brokerage.DecimalPtr(decimal.NewFromInt(280)),
+	}
+}
+
+func TestPreviewOrderAccepted(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	mock.SetResponse("POST", previewOrderRequestPath, http.StatusOK, acceptedPreviewFixture)
+	client := mock.NewClient()
+
+	preview, err := client.PreviewOrder(t.Context(), schwabtest.AccountNumber, previewBuy(10))
+	if err != nil {
+		t.Fatalf("PreviewOrder() error = %v", err)
+	}
+
+	for name, check := range map[string]struct{ got, want string }{
+		"EstimatedTotal":          {preview.EstimatedTotal.String(), "2800"},
+		"Commission":              {preview.Commission.String(), "0"},
+		"Fees":                    {preview.Fees.String(), "0.01"},
+		"ProjectedBuyingPower":    {preview.ProjectedBuyingPower.String(), "2199.99"},
+		"ProjectedAvailableFunds": {preview.ProjectedAvailableFunds.String(), "2199.99"},
+	} {
+		if check.got != check.want {
+			t.Errorf("%s = %s, want %s", name, check.got, check.want)
+		}
+	}
+	if want := []string{"The market closes in less than 15 minutes"}; !reflect.DeepEqual(preview.Warnings, want) {
+		t.Errorf("Warnings = %q, want %q", preview.Warnings, want)
+	}
+	if len(preview.Rejections) != 0 || preview.InsufficientBuyingPower() {
+		t.Errorf("preview = %+v, want it accepted", preview)
+	}
+
+	req, ok := mock.LastRequest("POST", previewOrderRequestPath)
+	if !ok {
+		t.Fatal("no preview request reached the mock")
+	}
+	requireJSONEqual(t, req.Body, `{
+		"orderType": "LIMIT",
+		"session": "NORMAL",
+		"duration": "DAY",
+		"orderStrategyType": "SINGLE",
+		"price": 280,
+		"orderLegCollection": [{
+			"instruction": "BUY",
+			"quantity": 10,
+			"instrument": {"symbol": "VTI", "assetType": "EQUITY"}
+		}]
+	}`)
+	if n := countRequests(mock, "POST", "/trader/v1/accounts/"+schwabtest.AccountHash+"/orders"); n != 0 {
+		t.Errorf("placed %d orders, want only a preview", n)
+	}
+}
+
+func TestPreviewOrderRejected(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	mock.SetResponse("POST", previewOrderRequestPath, http.StatusOK, rejectedPreviewFixture)
+	client := mock.NewClient()
+
+	preview, err := client.PreviewOrder(t.Context(), schwabtest.AccountNumber, previewBuy(100))
+
+	var rejected *brokerage.OrderRejectedError
+	if !errors.As(err, &rejected) {
+		t.Fatalf("PreviewOrder() error = %v, want an OrderRejectedError", err)
+	}
+	wantMessages := []string{"Insufficient buying power for this order", "This order may result in a day trade"}
+	if rejected.Symbol != "VTI" || !reflect.DeepEqual(rejected.Messages, wantMessages) {
+		t.Errorf("rejection = %+v, want VTI with %q", rejected, wantMessages)
+	}
+	if want := "order for VTI would be rejected: Insufficient buying power for this order; This order may result in a day trade"; err.Error() != want {
+		t.Errorf("PreviewOrder() error = %q, want %q", err, want)
+	}
+
+	if preview == nil {
+		t.Fatal("PreviewOrder() returned no preview with the rejection")
+	}
+	if !preview.ProjectedBuyingPower.Equal(decimal.RequireFromString("-22999.99")) || !preview.InsufficientBuyingPower() {
+		t.Errorf("preview = %+v, want negative projected buying power", preview)
+	}
+}
+
+func TestPreviewOrderAPIError(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	mock.SetResponse("POST", previewOrderRequestPath, http.StatusBadRequest, `{"message": "invalid order"}`)
+	client := mock.NewClient()
+
+	preview, err := client.PreviewOrder(t.Context(), schwabtest.AccountNumber, previewBuy(10))
+	if preview != nil || err == nil {
+		t.Errorf("PreviewOrder() = %+v, %v, want an error and no preview", preview, err)
+	}
+}
@@ -0,0 +1,152 @@
+package schwab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+	_ "time/tzdata" // Candles are reported in exchange time regardless of the host's zoneinfo
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+const priceHistoryPath = "/marketdata/v1/pricehistory"
+
+// exchangeLocation is the timezone US equity exchanges report in
+var exchangeLocation = mustLoadLocation("America/New_York")
+
+// PeriodType is the unit of PriceHistoryOptions.Period
+type PeriodType string
+
+const (
+	PeriodTypeDay   PeriodType = "day"
+	PeriodTypeMonth PeriodType = "month"
+	PeriodTypeYear  PeriodType = "year"
+	PeriodTypeYTD   PeriodType = "ytd"
+)
+
+// FrequencyType is the unit of PriceHistoryOptions.Frequency
+type FrequencyType string
+
+const (
+	FrequencyTypeMinute  FrequencyType = "minute"
+	FrequencyTypeDaily   FrequencyType = "daily"
+	FrequencyTypeWeekly  FrequencyType = "weekly"
+	FrequencyTypeMonthly FrequencyType = "monthly"
+)
+
+// PriceHistoryOptions selects the range and candle size of a price history
+// request. Zero values are omitted so Schwab's defaults apply; StartDate and
+// EndDate take precedence over Period when set.
+type PriceHistoryOptions struct {
+	PeriodType    PeriodType
+	Period        int
+	FrequencyType FrequencyType
+	Frequency     int
+	StartDate     time.Time
+	EndDate       time.Time
+	ExtendedHours bool
+}
+
+// GetPriceHistory retrieves historical candles for a symbol. Candle times are
+// converted to the exchange's timezone (America/New_York). An unknown symbol
+// returns no candles rather than an error.
+// Documentation: https://developer.schwab.com/products/trader-api--individual/details/specifications/Market%20Data%20Production
+// Endpoint: GET /marketdata/v1/pricehistory
+func (c *Client) GetPriceHistory(ctx context.Context, symbol string, opts PriceHistoryOptions) ([]brokerage.Candle, error) {
+	query := url.Values{}
+	query.Set("symbol", symbol)
+	if opts.PeriodType != "" {
+		query.Set("periodType", string(opts.PeriodType))
+	}
+	if opts.Period > 0 {
+		query.Set("period", strconv.Itoa(opts.Period))
+	}
+	if opts.FrequencyType != "" {
+		query.Set("frequencyType", string(opts.FrequencyType))
+	}
+	if opts.Frequency > 0 {
+		query.Set("frequency", strconv.Itoa(opts.Frequency))
+	}
+	if !opts.StartDate.IsZero() {
+		query.Set("startDate", strconv.FormatInt(opts.StartDate.UnixMilli(), 10))
+	}
+	if !opts.EndDate.IsZero() {
+		query.Set("endDate", strconv.FormatInt(opts.EndDate.UnixMilli(), 10))
+	}
+	if opts.ExtendedHours {
+		query.Set("needExtendedHoursData", "true")
+	}
+
+	path := priceHistoryPath + "?" + query.Encode()
+	resp, err := c.makeRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read price history response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("get price history", resp, body)
+	}
+
+	var priceHistory struct {
+		Symbol  string `json:"symbol"`
+		Empty   bool   `json:"empty"`
+		Candles []struct {
+			Open     float64 `json:"open"`
+			High     float64 `json:"high"`
+			Low      float64 `json:"low"`
+			Close    float64 `json:"close"`
+			Volume   int64   `json:"volume"`
+			Datetime int64   `json:"datetime"`
+		} `json:"candles"`
+	}
+
+	if err := json.Unmarshal(body, &priceHistory); err != nil {
+		return nil, fmt.Errorf("failed to parse price history response: %w", err)
+	}
+
+	candles := make([]brokerage.Candle, 0, len(priceHistory.Candles))
+	for _, pc := range priceHistory.Candles {
+		candles = append(candles, brokerage.Candle{
+			Time:   time.UnixMilli(pc.Datetime).In(exchangeLocation),
+			Open:   pc.Open,
+			High:   pc.High,
+			Low:    pc.Low,
+			Close:  pc.Close,
+			Volume: pc.Volume,
+		})
+	}
+
+	return candles, nil
+}
+
+// GetDailyCandles retrieves the daily candles of symbol from start to end,
+// implementing brokerage.PriceHistorySource
+func (c *Client) GetDailyCandles(ctx context.Context, symbol string, start, end time.Time) ([]brokerage.Candle, error) {
+	return c.GetPriceHistory(ctx, symbol, PriceHistoryOptions{
+		PeriodType:    PeriodTypeYear,
+		FrequencyType: FrequencyTypeDaily,
+		Frequency:     1,
+		StartDate:     start,
+		EndDate:       end,
+	})
+}
+
+// mustLoadLocation loads a timezone from the embedded tzdata
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(fmt.Sprintf("failed to load timezone %s: %v", name, err))
+	}
+	return loc
+}
@@ -0,0 +1,175 @@
+package schwab_test
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab/schwabtest"
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+const priceHistoryRequestPath = "/marketdata/v1/pricehistory"
+
+// dailyCandlesFixture holds three daily candles, the last after the switch
+// to daylight saving time, stamped at midnight in New York
+const dailyCandlesFixture = `{
+	"symbol": "VTI",
+	"empty": false,
+	"candles": [
+		{"open": 280.1, "high": 282.5, "low": 279.05, "close": 281.98, "volume": 3456789, "datetime": 1741237200000},
+		{"open": 281.5, "high": 283, "low": 277.6, "close": 282.37, "volume": 4012345, "datetime": 1741323600000},
+		{"open": 279, "high": 279.5, "low": 272.12, "close": 273.08, "volume": 6789012, "datetime": 1741579200000}
+	],
+	"previousClose": 280.01,
+	"previousCloseDate": 1741150800000
+}`
+
+// minuteCandlesFixture holds the first two minute candles of a session
+const minuteCandlesFixture = `{
+	"symbol": "VTI",
+	"empty": false,
+	"candles": [
+		{"open": 279, "high": 279.2, "low": 278.5, "close": 278.75, "volume": 51234, "datetime": 1741613400000},
+		{"open": 278.75, "high": 278.9, "low": 278.1, "close": 278.2, "volume": 40321, "datetime": 1741613460000}
+	]
+}`
+
+// emptyCandlesFixture is Schwab's answer for a symbol it does not know
+const emptyCandlesFixture = `{"symbol": "NOPE", "empty": true, "candles": []}`
+
+func TestGetPriceHistory(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, time.March, 11, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		symbol    string
+		opts      schwab.PriceHistoryOptions
+		fixture   string
+		wantQuery url.Values
+		want      []brokerage.Candle
+	}{
+		{
+			name:   "daily",
+			symbol: "VTI",
+			opts: schwab.PriceHistoryOptions{
+				PeriodType:    schwab.PeriodTypeMonth,
+				FrequencyType: schwab.FrequencyTypeDaily,
+				Frequency:     1,
+				StartDate:     start,
+				EndDate:       end,
+			},
+			fixture: dailyCandlesFixture,
+			wantQuery: url.Values{
+				"symbol":        {"VTI"},
+				"periodType":    {"month"},
+				"frequencyType": {"daily"},
+				"frequency":     {"1"},
+				"startDate":     {"1740960000000"},
+				"endDate":       {"1741651200000"},
+			},
+			want: []brokerage.Candle{
+				{Time: time.Date(2025, time.March, 6, 0, 0, 0, 0, newYork), Open: 280.1, High: 282.5, Low: 279.05, Close: 281.98, Volume: 3456789},
+				{Time: time.Date(2025, time.March, 7, 0, 0, 0, 0, newYork), Open: 281.5, High: 283, Low: 277.6, Close: 282.37, Volume: 4012345},
+				{Time: time.Date(2025, time.March, 10, 0, 0, 0, 0, newYork), Open: 279, High: 279.5, Low: 272.12, Close: 273.08, Volume: 6789012},
+			},
+		},
+		{
+			name:   "minute",
+			symbol: "VTI",
+			opts: schwab.PriceHistoryOptions{
+				PeriodType:    schwab.PeriodTypeDay,
+				Period:        1,
+				FrequencyType: schwab.FrequencyTypeMinute,
+				Frequency:     1,
+				ExtendedHours: true,
+			},
+			fixture: minuteCandlesFixture,
+			wantQuery: url.Values{
+				"symbol":                {"VTI"},
+				"periodType":            {"day"},
+				"period":                {"1"},
+				"frequencyType":         {"minute"},
+				"frequency":             {"1"},
+				"needExtendedHoursData": {"true"},
+			},
+			want: []brokerage.Candle{
+				{Time: time.Date(2025, time.March, 10, 9, 30, 0, 0, newYork), Open: 279, High: 279.2, Low: 278.5, Close: 278.75, Volume: 51234},
+				{Time: time.Date(2025, time.March, 10, 9, 31, 0, 0, newYork), Open: 278.75, High: 278.9, Low: 278.1, Close: 278.2, Volume: 40321},
+			},
+		},
+		{
+			name:      "unknown symbol",
+			symbol:    "NOPE",
+			fixture:   emptyCandlesFixture,
+			wantQuery: url.Values{"symbol": {"NOPE"}},
+			want:      []brokerage.Candle{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := schwabtest.NewServer()
+			defer mock.Close()
+			mock.SetResponse("GET", priceHistoryRequestPath, http.StatusOK, tt.fixture)
+			client := mock.NewClient()
+
+			candles, err := client.GetPriceHistory(t.Context(), tt.symbol, tt.opts)
+			if err != nil {
+				t.Fatalf("GetPriceHistory() error = %v", err)
+			}
+
+			if len(candles) != len(tt.want) {
+				t.Fatalf("GetPriceHistory() returned %d candles, want %d", len(candles), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := candles[i]
+				if !got.Time.Equal(want.Time) || got.Time.Location().String() != "America/New_York" {
+					t.Errorf("candle %d time = %s, want %s in New York", i, got.Time, want.Time)
+				}
+				got.Time, want.Time = time.Time{}, time.Time{}
+				if got != want {
+					t.Errorf("candle %d = %+v, want %+v", i, got, want)
+				}
+			}
+
+			req, ok := mock.LastRequest("GET", priceHistoryRequestPath)
+			if !ok {
+				t.Fatal("no price history request was sent")
+			}
+			query, err := url.ParseQuery(req.Query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(query, tt.wantQuery) {
+				t.Errorf("query = %v, want %v", query, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestGetPriceHistoryErrors(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	mock.SetResponse("GET", priceHistoryRequestPath, http.StatusBadRequest,
+		`{"errors": [{"status": "400", "title": "Bad Request", "detail": "Invalid frequency for period type"}]}`)
+	client := mock.NewClient()
+
+	_, err := client.GetPriceHistory(t.Context(), "VTI", schwab.PriceHistoryOptions{
+		PeriodType:    schwab.PeriodTypeYear,
+		FrequencyType: schwab.FrequencyTypeMinute,
+	})
+	var apiErr *schwab.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("GetPriceHistory() error = %v, want a 400 APIError", err)
+	}
+}
@@ -0,0 +1,77 @@
+package schwab
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// defaultRequestsPerSecond matches Schwab's published limit of 120 requests
+// per minute
+const defaultRequestsPerSecond = 2.0
+
+// rateLimiter is a token bucket shared by every request a Client makes.
+// Tokens accrue at rate per second up to burst; each request spends one.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a limiter allowing requestsPerSecond requests, or nil
+// (no limit) when requestsPerSecond is negative
+func newRateLimiter(requestsPerSecond float64) *rateLimiter {
+	if requestsPerSecond < 0 {
+		return nil
+	}
+	if requestsPerSecond == 0 {
+		requestsPerSecond = defaultRequestsPerSecond
+	}
+
+	burst := math.Max(1, math.Floor(requestsPerSecond))
+	return &rateLimiter{
+		rate:   requestsPerSecond,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a request may be sent or ctx is done. A nil limiter never
+// blocks.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	// Reserve a token up front; when the bucket is empty this drives the
+	// balance negative so later callers queue up behind this one
+	l.tokens--
+	wait := time.Duration(0)
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+
+	if err := sleepContext(ctx, wait); err != nil {
+		// Give the reservation back so a cancelled caller doesn't delay others
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package schwab_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab/schwabtest"
+)
+
+// limitedClient returns an authenticated client for mock limited to
+// requestsPerSecond, unlike the mock's own clients which are not limited
+func limitedClient(t *testing.T, mock *schwabtest.Server, requestsPerSecond float64) *schwab.Client {
+	t.Helper()
+
+	store := &schwab.MemoryTokenStore{}
+	store.Save(&schwab.Token{
+		AccessToken:  schwabtest.AccessToken,
+		RefreshToken: schwabtest.RefreshToken,
+		ExpiresIn:    1800,
+		TokenType:    "Bearer",
+		ExpiresAt:    time.Now().Add(30 * time.Minute),
+	})
+	config := mock.Config()
+	config.RequestsPerSecond = requestsPerSecond
+	client, err := schwab.NewClient(config, schwab.WithTokenStore(store))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if err := client.LoadToken(); err != nil {
+		t.Fatalf("LoadToken() error = %v", err)
+	}
+	return client
+}
+
+// getAccountNumbers makes n requests with client, failing the test if any
+// fails, and returns how long they took
+func getAccountNumbers(t *testing.T, client *schwab.Client, n int) time.Duration {
+	t.Helper()
+
+	start := time.Now()
+	for range n {
+		if _, err := client.GetAccountNumbers(t.Context()); err != nil {
+			t.Fatalf("GetAccountNumbers() error = %v", err)
+		}
+	}
+	return time.Since(start)
+}
+
+func TestRateLimiterSpacesRequestsAfterTheBurst(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	client := limitedClient(t, mock, 20)
+
+	// The first 20 requests spend the burst
+	if elapsed := getAccountNumbers(t, client, 20); elapsed > 200*time.Millisecond {
+		t.Errorf("the burst of 20 requests took %v, want no waiting", elapsed)
+	}
+
+	// after which each one waits 50ms for its turn
+	if elapsed := getAccountNumbers(t, client, 5); elapsed < 200*time.Millisecond || elapsed > time.Second {
+		t.Errorf("5 requests after the burst took %v, want about 250ms", elapsed)
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	client := limitedClient(t, mock, -1)
+
+	if elapsed := getAccountNumbers(t, client, 50); elapsed > time.Second {
+		t.Errorf("50 requests without a limit took %v, want no waiting", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := client.GetAccountNumbers(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetAccountNumbers() with a cancelled context error = %v, want context.Canceled", err)
+	}
+}
+
+func TestRateLimiterWaitIsCancelled(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+
+	// A request every 2/3 of a second, with a burst of one
+	client := limitedClient(t, mock, 1.5)
+	start := time.Now()
+	getAccountNumbers(t, client, 1)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetAccountNumbers(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetAccountNumbers() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("the cancelled request returned after %v, want as soon as its context was done", elapsed)
+	}
+	if n := len(mock.Requests()); n != 1 {
+		t.Errorf("the mock received %d requests, want only the first", n)
+	}
+
+	// The cancelled request gave its turn back, so the next one waits
+	// for the first's interval and not for two of them
+	getAccountNumbers(t, client, 1)
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond || elapsed > time.Second {
+		t.Errorf("the request after the cancelled one was sent %v in, want about 667ms", elapsed)
+	}
+}
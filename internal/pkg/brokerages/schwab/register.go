@@ -0,0 +1,34 @@
+package schwab
+
+import (
+	"encoding/json"
+	"log/slog"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+func init() {
+	brokerage.RegisterBrokerage("schwab", newBrokerage)
+}
+
+// newBrokerage creates a client from a Config in JSON, logging to
+// slog.Default, and loads its saved token. A client without a usable token is returned together with the
+// LoadToken error, which matches brokerage.ErrNotAuthenticated.
+func newBrokerage(raw json.RawMessage) (brokerage.BrokerageClient, error) {
+	config, err := ParseConfig(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := NewClient(config, WithLogger(slog.Default()))
+	if err != nil {
+		return nil, err
+	}
+	if err := client.LoadToken(); err != nil {
+		if IsAuthError(err) {
+			return client, err
+		}
+		return nil, err
+	}
+	return client, nil
+}
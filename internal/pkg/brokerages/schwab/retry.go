@@ -0,0 +1,107 @@
+package schwab
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaxRetryAttempts    = 3
+	defaultRetryBaseDelay      = 500 * time.Millisecond
+	defaultMaxRateLimitRetries = 3
+)
+
+// maxAttempts returns how many times a request with the given method may be
+// attempted
+func (c *Client) maxAttempts(method string) int {
+	if method == http.MethodPost && !c.config.RetryOrders {
+		return 1
+	}
+	if c.config.MaxRetryAttempts > 0 {
+		return c.config.MaxRetryAttempts
+	}
+	return defaultMaxRetryAttempts
+}
+
+// maxRateLimitRetries returns how many times a 429 response may be retried
+func (c *Client) maxRateLimitRetries() int {
+	switch {
+	case c.config.MaxRateLimitRetries < 0:
+		return 0
+	case c.config.MaxRateLimitRetries > 0:
+		return c.config.MaxRateLimitRetries
+	default:
+		return defaultMaxRateLimitRetries
+	}
+}
+
+// retryAfter parses the Retry-After header of resp, which holds either a
+// number of seconds or an HTTP date
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+
+	return 0, false
+}
+
+// retryDelay returns the backoff before retry number attempt (starting at 1):
+// the base delay doubled per attempt, with up to half of it replaced by jitter
+// so concurrent callers don't retry in lockstep
+func (c *Client) retryDelay(attempt int) time.Duration {
+	base := defaultRetryBaseDelay
+	if c.config.RetryBaseDelayMillis > 0 {
+		base = time.Duration(c.config.RetryBaseDelayMillis) * time.Millisecond
+	}
+
+	delay := base << (attempt - 1)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// shouldRetry reports whether a request attempt failed transiently: a
+// connection-level error or a 500/502/503/504 response
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, ErrNoFixture)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
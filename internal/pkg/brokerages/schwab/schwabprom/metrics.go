@@ -0,0 +1,68 @@
+// Package schwabprom exports the schwab client's metrics to Prometheus. It
+// lives in its own package so the schwab package does not depend on the
+// Prometheus client.
+package schwabprom
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics implements schwab.Metrics with Prometheus collectors
+type Metrics struct {
+	requests       *prometheus.CounterVec
+	latency        *prometheus.HistogramVec
+	tokenRefreshes *prometheus.CounterVec
+	rateLimited    *prometheus.CounterVec
+}
+
+// New creates the collectors and registers them with registerer
+func New(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "schwab",
+			Name:      "requests_total",
+			Help:      "Schwab API request attempts by endpoint and status class.",
+		}, []string{"endpoint", "status_class"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "schwab",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of Schwab API request attempts by endpoint.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"endpoint"}),
+		tokenRefreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "schwab",
+			Name:      "token_refreshes_total",
+			Help:      "Schwab access token refresh attempts by outcome.",
+		}, []string{"success"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "schwab",
+			Name:      "rate_limited_total",
+			Help:      "Schwab API requests answered with 429 by endpoint.",
+		}, []string{"endpoint"}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.requests, m.latency, m.tokenRefreshes, m.rateLimited} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register schwab metrics: %w", err)
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Metrics) RequestCompleted(endpoint string, statusClass string, latency time.Duration) {
+	m.requests.WithLabelValues(endpoint, statusClass).Inc()
+	m.latency.WithLabelValues(endpoint).Observe(latency.Seconds())
+}
+
+func (m *Metrics) TokenRefreshed(success bool) {
+	m.tokenRefreshes.WithLabelValues(strconv.FormatBool(success)).Inc()
+}
+
+func (m *Metrics) RateLimited(endpoint string) {
+	m.rateLimited.WithLabelValues(endpoint).Inc()
+}
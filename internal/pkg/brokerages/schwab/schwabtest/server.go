@@ -0,0 +1,583 @@
+// Package schwabtest provides an in-memory mock of the Schwab API for
+// exercising the schwab client without credentials or network access.
+package schwabtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+)
+
+const (
+	// AccountNumber and AccountHash identify the mock's single account
+	AccountNumber = "12345678"
+	AccountHash   = "ABCDEF0123456789"
+
+	// AccessToken and RefreshToken are the tokens the mock issues and accepts
+	AccessToken  = "test-access-token"
+	RefreshToken = "test-refresh-token"
+
+	// AuthCode is the only authorization code the token endpoint accepts
+	AuthCode = "test-auth-code"
+)
+
+const (
+	// enteredTimeLayout formats the times of orders, queryTimeLayout the
+	// times of order queries
+	enteredTimeLayout = "2006-01-02T15:04:05-0700"
+	queryTimeLayout   = "2006-01-02T15:04:05.000Z"
+)
+
+// Request is a request received by the mock
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   []byte
+}
+
+// Position is a holding of the mock account
+type Position struct {
+	Symbol       string
+	Description  string
+	AssetType    string // Empty is EQUITY
+	Quantity     float64
+	AveragePrice float64
+	DayPL        float64
+}
+
+// Server is a mock Schwab API. Orders placed through it are kept in memory
+// and can be read back, replaced and cancelled; their status can be changed
+// with SetOrderStatus to simulate fills.
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	requests    []Request
+	overrides   map[string]cannedResponse
+	cash        float64
+	positions   []Position
+	quotes      map[string]float64
+	orders      map[int64]map[string]any
+	nextOrderID int64
+}
+
+type cannedResponse struct {
+	status int
+	body   string
+}
+
+// NewServer starts a mock with $10,000 in cash, no positions and quotes for
+// VTI and VXUS. Call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		overrides:   make(map[string]cannedResponse),
+		cash:        10_000,
+		quotes:      map[string]float64{"VTI": 250, "VXUS": 60},
+		orders:      make(map[int64]map[string]any),
+		nextOrderID: 1000,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/oauth/token", s.handleToken)
+	mux.HandleFunc("GET /trader/v1/accounts", s.authorized(s.handleAccounts))
+	mux.HandleFunc("GET /trader/v1/accounts/accountNumbers", s.authorized(s.handleAccountNumbers))
+	mux.HandleFunc("GET /trader/v1/accounts/{hash}", s.authorized(s.handlePositions))
+	mux.HandleFunc("POST /trader/v1/accounts/{hash}/orders", s.authorized(s.handlePlaceOrder))
+	mux.HandleFunc("GET /trader/v1/accounts/{hash}/orders", s.authorized(s.handleListOrders))
+	mux.HandleFunc("GET /trader/v1/accounts/{hash}/orders/{id}", s.authorized(s.handleGetOrder))
+	mux.HandleFunc("PUT /trader/v1/accounts/{hash}/orders/{id}", s.authorized(s.handleReplaceOrder))
+	mux.HandleFunc("DELETE /trader/v1/accounts/{hash}/orders/{id}", s.authorized(s.handleCancelOrder))
+	mux.HandleFunc("GET /trader/v1/userPreference", s.authorized(s.handleUserPreference))
+	mux.HandleFunc("GET /marketdata/v1/quotes", s.authorized(s.handleQuotes))
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(strings.NewReader(string(body)))
+
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.RawQuery,
+			Header: r.Header.Clone(),
+			Body:   body,
+		})
+		override, ok := s.overrides[r.Method+" "+r.URL.Path]
+		s.mu.Unlock()
+
+		if ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(override.status)
+			io.WriteString(w, override.body)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+
+	return s
+}
+
+// Config returns a client configuration pointing every endpoint at the mock
+func (s *Server) Config() schwab.Config {
+	return schwab.Config{
+		ClientID:      "test-client-id",
+		ClientSecret:  "test-client-secret",
+		RedirectURI:   "https://127.0.0.1:8080",
+		BaseURL:       s.URL,
+		AuthURL:       s.URL + "/v1/oauth/authorize",
+		TokenURL:      s.URL + "/v1/oauth/token",
+		AllowInsecure: true,
+	}
+}
+
+// NewClient returns a client for the mock that is already authenticated,
+// keeps its token in memory and is not rate limited
+func (s *Server) NewClient(opts ...schwab.Option) *schwab.Client {
+	return s.NewClientWithConfig(s.Config(), opts...)
+}
+
+// NewClientWithConfig is NewClient with config, e.g. s.Config() with the
+// duplicate guard turned on, in place of s.Config()
+func (s *Server) NewClientWithConfig(config schwab.Config, opts ...schwab.Option) *schwab.Client {
+	config.RequestsPerSecond = -1
+
+	store := &schwab.MemoryTokenStore{}
+	store.Save(&schwab.Token{
+		AccessToken:  AccessToken,
+		RefreshToken: RefreshToken,
+		ExpiresIn:    1800,
+		TokenType:    "Bearer",
+		ExpiresAt:    time.Now().Add(30 * time.Minute),
+	})
+
+	client, err := schwab.NewClient(config, append([]schwab.Option{schwab.WithTokenStore(store)}, opts...)...)
+	if err != nil {
+		panic(fmt.Sprintf("schwabtest: %v", err))
+	}
+	client.LoadToken()
+	return client
+}
+
+// Requests returns every request received so far, oldest first
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// LastRequest returns the most recent request to method and path, or false
+func (s *Server) LastRequest(method, path string) (Request, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(s.requests) - 1; i >= 0; i-- {
+		if s.requests[i].Method == method && s.requests[i].Path == path {
+			return s.requests[i], true
+		}
+	}
+	return Request{}, false
+}
+
+// SetResponse makes every request to method and path return status and body
+// instead of the mock's own response, e.g. to simulate errors
+func (s *Server) SetResponse(method, path string, status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.overrides[method+" "+path] = cannedResponse{status: status, body: body}
+}
+
+// ClearResponse removes a response set with SetResponse
+func (s *Server) ClearResponse(method, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.overrides, method+" "+path)
+}
+
+// SetCash sets the account's cash balance
+func (s *Server) SetCash(cash float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cash = cash
+}
+
+// SetPositions replaces the account's holdings
+func (s *Server) SetPositions(positions ...Position) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.positions = append([]Position(nil), positions...)
+}
+
+// SetQuote sets the last price quoted for symbol
+func (s *Server) SetQuote(symbol string, price float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.quotes[symbol] = price
+}
+
+// SetOrderStatus changes the Schwab status of a placed order. Setting it to
+// FILLED records a single execution of the whole order at price.
+func (s *Server) SetOrderStatus(orderID string, status string, price float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, _ := strconv.ParseInt(orderID, 10, 64)
+	order, ok := s.orders[id]
+	if !ok {
+		return fmt.Errorf("no order %s", orderID)
+	}
+
+	order["status"] = status
+	if status == "FILLED" {
+		quantity := order["quantity"]
+		order["filledQuantity"] = quantity
+		order["remainingQuantity"] = 0
+		order["orderActivityCollection"] = []map[string]any{{
+			"activityType":  "EXECUTION",
+			"executionType": "FILL",
+			"quantity":      quantity,
+			"executionLegs": []map[string]any{{
+				"legId":    1,
+				"price":    price,
+				"quantity": quantity,
+				"time":     time.Now().UTC().Format("2006-01-02T15:04:05-0700"),
+			}},
+		}}
+	}
+	return nil
+}
+
+// authorized rejects requests without the mock's access token
+func (s *Server) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+AccessToken {
+			writeError(w, http.StatusUnauthorized, "Client not authorized")
+			return
+		}
+		if hash := r.PathValue("hash"); hash != "" && hash != AccountHash && hash != "accountNumbers" {
+			writeError(w, http.StatusNotFound, "Account not found")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, "invalid_request", err.Error())
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		if r.PostForm.Get("code") != AuthCode {
+			writeOAuthError(w, "invalid_grant", "unknown authorization code")
+			return
+		}
+	case "refresh_token":
+		if r.PostForm.Get("refresh_token") != RefreshToken {
+			writeOAuthError(w, "invalid_grant", "unknown refresh token")
+			return
+		}
+	default:
+		writeOAuthError(w, "unsupported_grant_type", r.PostForm.Get("grant_type"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token":  AccessToken,
+		"refresh_token": RefreshToken,
+		"expires_in":    1800,
+		"token_type":    "Bearer",
+		"scope":         "api",
+	})
+}
+
+func (s *Server) handleAccounts(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, []any{s.accountLocked(false)})
+}
+
+func (s *Server) handleAccountNumbers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, []map[string]string{
+		{"accountNumber": AccountNumber, "hashValue": AccountHash},
+	})
+}
+
+func (s *Server) handlePositions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, s.accountLocked(r.URL.Query().Get("fields") == "positions"))
+}
+
+// accountLocked renders the account, with its positions if requested. The
+// caller must hold mu.
+func (s *Server) accountLocked(withPositions bool) map[string]any {
+	var marketValue float64
+	positions := make([]map[string]any, 0, len(s.positions))
+	for _, p := range s.positions {
+		value := p.Quantity * s.quotes[p.Symbol]
+		marketValue += value
+		assetType := p.AssetType
+		if assetType == "" {
+			assetType = "EQUITY"
+		}
+		positions = append(positions, map[string]any{
+			"longQuantity":         p.Quantity,
+			"shortQuantity":        0,
+			"averagePrice":         p.AveragePrice,
+			"averageLongPrice":     p.AveragePrice,
+			"marketValue":          value,
+			"currentDayProfitLoss": p.DayPL,
+			"instrument": map[string]any{
+				"symbol":      p.Symbol,
+				"description": p.Description,
+				"assetType":   assetType,
+			},
+		})
+	}
+
+	account := map[string]any{
+		"type":          "MARGIN",
+		"accountNumber": AccountNumber,
+		"currentBalances": map[string]any{
+			"cashBalance":             s.cash,
+			"cashAvailableForTrading": s.cash,
+			"buyingPower":             s.cash,
+			"longMarketValue":         marketValue,
+		},
+	}
+	if withPositions {
+		account["positions"] = positions
+	}
+
+	return map[string]any{"securitiesAccount": account}
+}
+
+func (s *Server) handlePlaceOrder(w http.ResponseWriter, r *http.Request) {
+	var order map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid order: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	id := s.addOrderLocked(order)
+	s.mu.Unlock()
+
+	w.Header().Set("Location", fmt.Sprintf("%s/trader/v1/accounts/%s/orders/%d", s.URL, AccountHash, id))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) handleReplaceOrder(w http.ResponseWriter, r *http.Request) {
+	var order map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid order: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	existing, ok := s.orderLocked(r.PathValue("id"))
+	if !ok {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "Order not found")
+		return
+	}
+	existing["status"] = "REPLACED"
+	id := s.addOrderLocked(order)
+	s.mu.Unlock()
+
+	w.Header().Set("Location", fmt.Sprintf("%s/trader/v1/accounts/%s/orders/%d", s.URL, AccountHash, id))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// addOrderLocked stores a submitted order as WORKING. The caller must hold mu.
+func (s *Server) addOrderLocked(order map[string]any) int64 {
+	s.nextOrderID++
+	id := s.nextOrderID
+
+	var quantity float64
+	if legs, ok := order["orderLegCollection"].([]any); ok && len(legs) > 0 {
+		if leg, ok := legs[0].(map[string]any); ok {
+			quantity, _ = leg["quantity"].(float64)
+		}
+	}
+
+	order["orderId"] = id
+	order["accountNumber"] = AccountNumber
+	order["status"] = "WORKING"
+	order["quantity"] = quantity
+	order["filledQuantity"] = 0
+	order["remainingQuantity"] = quantity
+	order["enteredTime"] = time.Now().UTC().Format(enteredTimeLayout)
+	s.orders[id] = order
+	return id
+}
+
+// orderLocked returns the order with the given ID. The caller must hold mu.
+func (s *Server) orderLocked(orderID string) (map[string]any, bool) {
+	id, err := strconv.ParseInt(orderID, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	order, ok := s.orders[id]
+	return order, ok
+}
+
+func (s *Server) handleGetOrder(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orderLocked(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "Order not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, order)
+}
+
+func (s *Server) handleListOrders(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := r.URL.Query()
+	status := query.Get("status")
+	from, _ := time.Parse(queryTimeLayout, query.Get("fromEnteredTime"))
+	to, _ := time.Parse(queryTimeLayout, query.Get("toEnteredTime"))
+	maxResults, _ := strconv.Atoi(query.Get("maxResults"))
+
+	orders := make([]map[string]any, 0, len(s.orders))
+	for id := s.nextOrderID; id > 0; id-- {
+		order, ok := s.orders[id]
+		if !ok {
+			continue
+		}
+		entered, _ := time.Parse(enteredTimeLayout, order["enteredTime"].(string))
+		switch {
+		case status != "" && order["status"] != status:
+			continue
+		case !from.IsZero() && entered.Before(from.Truncate(time.Second)):
+			continue
+		case !to.IsZero() && entered.After(to):
+			continue
+		}
+		orders = append(orders, order)
+		if maxResults > 0 && len(orders) == maxResults {
+			break
+		}
+	}
+	writeJSON(w, http.StatusOK, orders)
+}
+
+func (s *Server) handleCancelOrder(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orderLocked(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "Order not found")
+		return
+	}
+	if order["status"] != "WORKING" {
+		writeError(w, http.StatusBadRequest, "Order is not cancelable")
+		return
+	}
+	order["status"] = "CANCELED"
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleUserPreference(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"accounts": []map[string]any{{
+			"accountNumber":  AccountNumber,
+			"nickName":       "Test Brokerage",
+			"primaryAccount": true,
+			"type":           "BROKERAGE",
+		}},
+		"streamerInfo": []map[string]any{{
+			"streamerSocketUrl":      strings.Replace(s.URL, "http", "ws", 1) + "/ws",
+			"schwabClientCustomerId": "test-customer",
+			"schwabClientCorrelId":   "test-correl",
+			"schwabClientChannel":    "N9",
+			"schwabClientFunctionId": "APIAPP",
+		}},
+	})
+}
+
+func (s *Server) handleQuotes(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	quotes := make(map[string]any)
+	var missing []string
+	for _, symbol := range strings.Split(r.URL.Query().Get("symbols"), ",") {
+		price, ok := s.quotes[symbol]
+		if !ok {
+			missing = append(missing, symbol)
+			continue
+		}
+		now := time.Now().UnixMilli()
+		quotes[symbol] = map[string]any{
+			"symbol":    symbol,
+			"assetType": "EQUITY",
+			"quote": map[string]any{
+				"bidPrice":    price - 0.01,
+				"askPrice":    price + 0.01,
+				"lastPrice":   price,
+				"closePrice":  price,
+				"totalVolume": 1_000_000,
+				"quoteTime":   now,
+				"tradeTime":   now,
+			},
+			"extended": map[string]any{
+				"bidPrice":  price - 0.01,
+				"askPrice":  price + 0.01,
+				"lastPrice": price,
+				"quoteTime": now,
+				"tradeTime": now,
+			},
+			"reference": map[string]any{
+				"description": symbol + " test security",
+			},
+		}
+	}
+	if len(missing) > 0 {
+		quotes["errors"] = map[string]any{"invalidSymbols": missing}
+	}
+
+	writeJSON(w, http.StatusOK, quotes)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes the trader API's error payload
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]any{"message": message})
+}
+
+// writeOAuthError writes the token endpoint's error payload
+func writeOAuthError(w http.ResponseWriter, code, description string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code, "error_description": description})
+}
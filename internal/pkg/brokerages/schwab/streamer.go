@@ -0,0 +1,330 @@
+package schwab
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// levelOneEquityFields are the LEVELONE_EQUITIES fields subscribed to:
+	// symbol, bid, ask, last, total volume, close, description, quote time
+	// and trade time
+	levelOneEquityFields = "0,1,2,3,8,12,15,34,35"
+
+	// streamerHeartbeatTimeout is how long the connection may stay silent
+	// before it is considered dead; Schwab sends heartbeats every few seconds
+	streamerHeartbeatTimeout = 30 * time.Second
+
+	streamerMinReconnectDelay = time.Second
+	streamerMaxReconnectDelay = time.Minute
+)
+
+// Streamer delivers live LEVELONE_EQUITIES quotes from the Schwab streamer
+// websocket. It logs in with the credentials from the user preference
+// endpoint, reconnects and resubscribes when the connection drops, and stops
+// when the context passed to Run is cancelled.
+type Streamer struct {
+	client  *Client
+	dialer  *websocket.Dialer
+	symbols []string
+	quotes  chan brokerage.Quote
+
+	// latest merges the partial updates Schwab sends into full quotes
+	latest map[string]*brokerage.Quote
+
+	// writeMu serializes writes, which may come from the shutdown goroutine
+	writeMu   sync.Mutex
+	requestID int
+}
+
+// NewStreamer creates a streamer for the given symbols. Call Run to start
+// streaming and read updates from Quotes.
+func NewStreamer(client *Client, symbols []string) *Streamer {
+	return &Streamer{
+		client:  client,
+		dialer:  websocket.DefaultDialer,
+		symbols: symbols,
+		quotes:  make(chan brokerage.Quote, len(symbols)),
+		latest:  make(map[string]*brokerage.Quote),
+	}
+}
+
+// Quotes returns the channel quote updates are delivered on. It is closed
+// when Run returns.
+func (s *Streamer) Quotes() <-chan brokerage.Quote {
+	return s.quotes
+}
+
+// Run connects to the streamer and delivers quotes until ctx is cancelled,
+// reconnecting with backoff whenever the connection is lost. It returns
+// ctx.Err() on shutdown, or an error if the streamer cannot be used at all
+// (e.g. Schwab returns no streamer credentials or rejects the login).
+func (s *Streamer) Run(ctx context.Context) error {
+	defer close(s.quotes)
+
+	delay := streamerMinReconnectDelay
+	for {
+		connected, err := s.runConnection(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var loginErr *streamerLoginError
+		if errors.As(err, &loginErr) || errors.Is(err, errNoStreamerInfo) {
+			return err
+		}
+
+		if connected {
+			delay = streamerMinReconnectDelay
+		}
+		s.client.logger.WarnContext(ctx, "schwab streamer disconnected, reconnecting",
+			slog.String("error", err.Error()),
+			slog.Duration("delay", delay))
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+		delay = min(delay*2, streamerMaxReconnectDelay)
+	}
+}
+
+var errNoStreamerInfo = errors.New("schwab returned no streamer info")
+
+// streamerLoginError reports a login rejected by the streamer
+type streamerLoginError struct {
+	code int
+	msg  string
+}
+
+func (e *streamerLoginError) Error() string {
+	return fmt.Sprintf("streamer login failed with code %d: %s", e.code, e.msg)
+}
+
+// runConnection runs one streamer session: it connects, logs in, subscribes
+// and then reads until the connection fails or ctx is cancelled. connected
+// reports whether the session got as far as subscribing.
+func (s *Streamer) runConnection(ctx context.Context) (connected bool, err error) {
+	preferences, err := s.client.GetUserPreferences(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get streamer info: %w", err)
+	}
+	info := preferences.StreamerInfo
+	if info == nil {
+		return false, errNoStreamerInfo
+	}
+
+	accessToken, err := s.client.validAccessToken(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	conn, _, err := s.dialer.DialContext(ctx, info.StreamerSocketURL, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to streamer: %w", err)
+	}
+
+	// Close the connection on shutdown to unblock the read loop
+	var closeOnce sync.Once
+	closeConn := func() { closeOnce.Do(func() { conn.Close() }) }
+	defer closeConn()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.send(conn, info, "ADMIN", "LOGOUT", nil)
+			closeConn()
+		case <-done:
+		}
+	}()
+
+	if err := s.login(conn, info, accessToken); err != nil {
+		return false, err
+	}
+
+	if err := s.send(conn, info, "LEVELONE_EQUITIES", "SUBS", map[string]string{
+		"keys":   strings.Join(s.symbols, ","),
+		"fields": levelOneEquityFields,
+	}); err != nil {
+		return false, fmt.Errorf("failed to subscribe to quotes: %w", err)
+	}
+
+	for {
+		message, err := s.read(conn)
+		if err != nil {
+			return true, err
+		}
+
+		for _, quote := range s.handleData(message.Data) {
+			select {
+			case s.quotes <- quote:
+			case <-ctx.Done():
+				return true, ctx.Err()
+			}
+		}
+	}
+}
+
+// streamerRequest is a single command sent to the streamer
+type streamerRequest struct {
+	Service                string `json:"service"`
+	RequestID              string `json:"requestid"`
+	Command                string `json:"command"`
+	SchwabClientCustomerID string `json:"SchwabClientCustomerId"`
+	SchwabClientCorrelID   string `json:"SchwabClientCorrelId"`
+	Parameters             any    `json:"parameters,omitempty"`
+}
+
+// streamerMessage is a frame received from the streamer. Command responses,
+// data and heartbeats arrive under different keys.
+type streamerMessage struct {
+	Response []struct {
+		Service string `json:"service"`
+		Command string `json:"command"`
+		Content struct {
+			Code int    `json:"code"`
+			Msg  string `json:"msg"`
+		} `json:"content"`
+	} `json:"response"`
+	Data   []streamerData    `json:"data"`
+	Notify []json.RawMessage `json:"notify"`
+}
+
+// streamerData carries updates for one service. Each content entry is keyed
+// by field number, with the symbol under "key".
+type streamerData struct {
+	Service string                       `json:"service"`
+	Content []map[string]json.RawMessage `json:"content"`
+}
+
+// login authenticates the connection and waits for the LOGIN response
+func (s *Streamer) login(conn *websocket.Conn, info *StreamerInfo, accessToken string) error {
+	if err := s.send(conn, info, "ADMIN", "LOGIN", map[string]string{
+		"Authorization":          accessToken,
+		"SchwabClientChannel":    info.SchwabClientChannel,
+		"SchwabClientFunctionId": info.SchwabClientFunctionID,
+	}); err != nil {
+		return fmt.Errorf("failed to send streamer login: %w", err)
+	}
+
+	for {
+		message, err := s.read(conn)
+		if err != nil {
+			return fmt.Errorf("failed waiting for streamer login: %w", err)
+		}
+
+		for _, response := range message.Response {
+			if response.Service != "ADMIN" || response.Command != "LOGIN" {
+				continue
+			}
+			if response.Content.Code != 0 {
+				return &streamerLoginError{code: response.Content.Code, msg: response.Content.Msg}
+			}
+			return nil
+		}
+	}
+}
+
+// send writes a single request to the streamer
+func (s *Streamer) send(conn *websocket.Conn, info *StreamerInfo, service, command string, parameters any) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.requestID++
+	return conn.WriteJSON(map[string][]streamerRequest{
+		"requests": {{
+			Service:                service,
+			RequestID:              strconv.Itoa(s.requestID),
+			Command:                command,
+			SchwabClientCustomerID: info.SchwabClientCustomerID,
+			SchwabClientCorrelID:   info.SchwabClientCorrelID,
+			Parameters:             parameters,
+		}},
+	})
+}
+
+// read waits for the next frame, failing if nothing (not even a heartbeat)
+// arrives within streamerHeartbeatTimeout
+func (s *Streamer) read(conn *websocket.Conn) (*streamerMessage, error) {
+	conn.SetReadDeadline(time.Now().Add(streamerHeartbeatTimeout))
+
+	var message streamerMessage
+	if err := conn.ReadJSON(&message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// handleData merges LEVELONE_EQUITIES updates into the latest known quotes
+// and returns the quotes that changed
+func (s *Streamer) handleData(data []streamerData) []brokerage.Quote {
+	var updated []brokerage.Quote
+	for _, d := range data {
+		if d.Service != "LEVELONE_EQUITIES" {
+			continue
+		}
+
+		for _, content := range d.Content {
+			var symbol string
+			if err := json.Unmarshal(content["key"], &symbol); err != nil || symbol == "" {
+				continue
+			}
+
+			quote, ok := s.latest[symbol]
+			if !ok {
+				quote = &brokerage.Quote{Symbol: symbol}
+				s.latest[symbol] = quote
+			}
+			applyLevelOneFields(quote, content)
+			updated = append(updated, *quote)
+		}
+	}
+	return updated
+}
+
+// applyLevelOneFields copies the numbered LEVELONE_EQUITIES fields present in
+// content onto quote
+func applyLevelOneFields(quote *brokerage.Quote, content map[string]json.RawMessage) {
+	priceField := func(field string, dst *decimal.Decimal) {
+		if raw, ok := content[field]; ok {
+			var price json.Number
+			if json.Unmarshal(raw, &price) == nil {
+				*dst = decimalFrom(price)
+			}
+		}
+	}
+	timeField := func(field string) {
+		if raw, ok := content[field]; ok {
+			var millis int64
+			if json.Unmarshal(raw, &millis) == nil {
+				quote.Timestamp = time.UnixMilli(millis)
+			}
+		}
+	}
+
+	priceField("1", &quote.Bid)
+	priceField("2", &quote.Ask)
+	priceField("3", &quote.Last)
+	priceField("12", &quote.Close)
+	if raw, ok := content["8"]; ok {
+		json.Unmarshal(raw, &quote.Volume)
+	}
+	if raw, ok := content["15"]; ok {
+		json.Unmarshal(raw, &quote.Description)
+	}
+	timeField("35")
+	timeField("34")
+}
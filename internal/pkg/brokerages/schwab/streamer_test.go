@@ -0,0 +1,238 @@
+package schwab_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab/schwabtest"
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// fakeStreamer is a websocket server standing in for the Schwab streamer.
+// Every connection it accepts is handed to the test through conns.
+type fakeStreamer struct {
+	*httptest.Server
+	conns chan *websocket.Conn
+}
+
+func newFakeStreamer(t *testing.T) *fakeStreamer {
+	t.Helper()
+	fake := &fakeStreamer{conns: make(chan *websocket.Conn, 4)}
+	upgrader := websocket.Upgrader{}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		fake.conns <- conn
+	}))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+// accept waits for the streamer to connect
+func (f *fakeStreamer) accept(t *testing.T) *websocket.Conn {
+	t.Helper()
+	select {
+	case conn := <-f.conns:
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	case <-time.After(5 * time.Second):
+		t.Fatal("the streamer did not connect")
+		return nil
+	}
+}
+
+// streamerClient returns a client whose user preferences point the streamer
+// at fake
+func streamerClient(t *testing.T, fake *fakeStreamer) *schwab.Client {
+	t.Helper()
+	mock := schwabtest.NewServer()
+	t.Cleanup(mock.Close)
+	mock.SetResponse("GET", userPreferenceRequestPath, http.StatusOK, fmt.Sprintf(`[{
+		"accounts": [],
+		"streamerInfo": [{
+			"streamerSocketUrl": %q,
+			"schwabClientCustomerId": "customer-id",
+			"schwabClientCorrelId": "correl-id",
+			"schwabClientChannel": "N9",
+			"schwabClientFunctionId": "APIAPP"
+		}]
+	}]`, "ws"+strings.TrimPrefix(fake.URL, "http")))
+	return mock.NewClient()
+}
+
+// streamerRequest is the part of a streamer command the tests check
+type streamerRequest struct {
+	Service                string            `json:"service"`
+	Command                string            `json:"command"`
+	SchwabClientCustomerID string            `json:"SchwabClientCustomerId"`
+	Parameters             map[string]string `json:"parameters"`
+}
+
+// readRequest reads the next command sent by the streamer and checks its
+// service and command
+func readRequest(t *testing.T, conn *websocket.Conn, service, command string) streamerRequest {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var frame struct {
+		Requests []streamerRequest `json:"requests"`
+	}
+	if err := conn.ReadJSON(&frame); err != nil {
+		t.Fatalf("reading %s %s request: %v", service, command, err)
+	}
+	if len(frame.Requests) != 1 {
+		t.Fatalf("got %d requests in a frame, want 1", len(frame.Requests))
+	}
+	req := frame.Requests[0]
+	if req.Service != service || req.Command != command {
+		t.Fatalf("got %s %s request, want %s %s", req.Service, req.Command, service, command)
+	}
+	if req.SchwabClientCustomerID != "customer-id" {
+		t.Errorf("%s request customer ID = %q, want customer-id", command, req.SchwabClientCustomerID)
+	}
+	return req
+}
+
+// writeFrame sends a raw JSON frame to the streamer
+func writeFrame(t *testing.T, conn *websocket.Conn, frame string) {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+		t.Fatalf("writing frame: %v", err)
+	}
+}
+
+// loginAndSubscribe answers the streamer's login with code and, if it was
+// accepted, expects the quote subscription that follows
+func loginAndSubscribe(t *testing.T, conn *websocket.Conn, code int) streamerRequest {
+	t.Helper()
+	login := readRequest(t, conn, "ADMIN", "LOGIN")
+	if login.Parameters["Authorization"] != schwabtest.AccessToken {
+		t.Errorf("login Authorization = %q, want %q", login.Parameters["Authorization"], schwabtest.AccessToken)
+	}
+	if login.Parameters["SchwabClientChannel"] != "N9" || login.Parameters["SchwabClientFunctionId"] != "APIAPP" {
+		t.Errorf("login parameters = %v, want channel N9 and function APIAPP", login.Parameters)
+	}
+	writeFrame(t, conn, fmt.Sprintf(
+		`{"response": [{"service": "ADMIN", "command": "LOGIN", "requestid": "1", "content": {"code": %d, "msg": "status=%d"}}]}`,
+		code, code))
+	if code != 0 {
+		return streamerRequest{}
+	}
+	return readRequest(t, conn, "LEVELONE_EQUITIES", "SUBS")
+}
+
+// nextQuote waits for the next quote update
+func nextQuote(t *testing.T, streamer *schwab.Streamer) brokerage.Quote {
+	t.Helper()
+	select {
+	case quote, ok := <-streamer.Quotes():
+		if !ok {
+			t.Fatal("Quotes() closed, want a quote")
+		}
+		return quote
+	case <-time.After(5 * time.Second):
+		t.Fatal("no quote was delivered")
+		return brokerage.Quote{}
+	}
+}
+
+func TestStreamer(t *testing.T) {
+	fake := newFakeStreamer(t)
+	streamer := schwab.NewStreamer(streamerClient(t, fake), []string{"VTI", "VXUS"})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	result := make(chan error, 1)
+	go func() { result <- streamer.Run(ctx) }()
+
+	conn := fake.accept(t)
+	subs := loginAndSubscribe(t, conn, 0)
+	if subs.Parameters["keys"] != "VTI,VXUS" || subs.Parameters["fields"] != "0,1,2,3,8,12,15,34,35" {
+		t.Errorf("subscription parameters = %v, want VTI and VXUS level one fields", subs.Parameters)
+	}
+
+	// Heartbeats carry no quotes, and a full update is delivered as is
+	writeFrame(t, conn, `{"notify": [{"heartbeat": "1741617000000"}]}`)
+	writeFrame(t, conn, `{"data": [{"service": "LEVELONE_EQUITIES", "timestamp": 1741617000000, "command": "SUBS", "content": [
+		{"key": "VTI", "1": 278.1, "2": 278.2, "3": 278.15, "8": 51234, "12": 280.01, "15": "VANGUARD TOTAL STOCK MARKET ETF", "34": 1741617000000, "35": 1741616999000}
+	]}]}`)
+	quote := nextQuote(t, streamer)
+	if quote.Symbol != "VTI" || quote.Bid.String() != "278.1" || quote.Ask.String() != "278.2" || quote.Last.String() != "278.15" ||
+		quote.Close.String() != "280.01" || quote.Volume != 51234 || quote.Description != "VANGUARD TOTAL STOCK MARKET ETF" ||
+		!quote.Timestamp.Equal(time.UnixMilli(1741617000000)) {
+		t.Errorf("first quote = %+v, want the full VTI update", quote)
+	}
+
+	// A partial update is merged into the previous quote
+	writeFrame(t, conn, `{"data": [{"service": "LEVELONE_EQUITIES", "content": [{"key": "VTI", "3": 278.3, "35": 1741617001000}]}]}`)
+	quote = nextQuote(t, streamer)
+	if quote.Last.String() != "278.3" || quote.Bid.String() != "278.1" || quote.Ask.String() != "278.2" ||
+		quote.Description != "VANGUARD TOTAL STOCK MARKET ETF" || !quote.Timestamp.Equal(time.UnixMilli(1741617001000)) {
+		t.Errorf("merged quote = %+v, want the new last price over the earlier update", quote)
+	}
+
+	// Dropping the connection makes the streamer log in and subscribe again
+	conn.Close()
+	conn = fake.accept(t)
+	if subs := loginAndSubscribe(t, conn, 0); subs.Parameters["keys"] != "VTI,VXUS" {
+		t.Errorf("resubscription keys = %q, want VTI,VXUS", subs.Parameters["keys"])
+	}
+	writeFrame(t, conn, `{"data": [{"service": "LEVELONE_EQUITIES", "content": [{"key": "VXUS", "3": 61.02}]}]}`)
+	if quote := nextQuote(t, streamer); quote.Symbol != "VXUS" || quote.Last.String() != "61.02" {
+		t.Errorf("quote after reconnecting = %+v, want VXUS at 61.02", quote)
+	}
+
+	// Cancelling logs out and closes the quotes channel
+	cancel()
+	readRequest(t, conn, "ADMIN", "LOGOUT")
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after cancelling")
+	}
+	if _, ok := <-streamer.Quotes(); ok {
+		t.Error("Quotes() is still open after Run returned")
+	}
+}
+
+func TestStreamerLoginRejected(t *testing.T) {
+	fake := newFakeStreamer(t)
+	streamer := schwab.NewStreamer(streamerClient(t, fake), []string{"VTI"})
+
+	result := make(chan error, 1)
+	go func() { result <- streamer.Run(t.Context()) }()
+
+	loginAndSubscribe(t, fake.accept(t), 3)
+	select {
+	case err := <-result:
+		if err == nil || !strings.Contains(err.Error(), "code 3") {
+			t.Errorf("Run() error = %v, want the rejected login", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() kept reconnecting after the login was rejected")
+	}
+}
+
+func TestStreamerWithoutStreamerInfo(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	mock.SetResponse("GET", userPreferenceRequestPath, http.StatusOK, `[{"accounts": []}]`)
+	streamer := schwab.NewStreamer(mock.NewClient(), []string{"VTI"})
+
+	if err := streamer.Run(t.Context()); err == nil || !strings.Contains(err.Error(), "no streamer info") {
+		t.Errorf("Run() error = %v, want no streamer info", err)
+	}
+}
@@ -0,0 +1,177 @@
+package schwab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/zalando/go-keyring"
+)
+
+// ErrNoToken is returned by a TokenStore that has no token saved yet. It
+// matches ErrNotAuthenticated with errors.Is.
+var ErrNoToken = fmt.Errorf("no token stored: %w", ErrNotAuthenticated)
+
+// TokenStore persists OAuth tokens between runs. Stores may implement
+// fmt.Stringer to describe where they keep the token.
+type TokenStore interface {
+	// Load returns the saved token, or an error wrapping ErrNoToken if
+	// nothing has been saved
+	Load() (*Token, error)
+
+	// Save replaces the saved token
+	Save(token *Token) error
+}
+
+// FileTokenStore keeps the token as JSON in a file. Writes are atomic, so a
+// crash never leaves a truncated token behind.
+type FileTokenStore struct {
+	Path string
+}
+
+func (s *FileTokenStore) Load() (*Token, error) {
+	rawToken, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s does not exist", ErrNoToken, s.Path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+
+	var token Token
+	if err := json.Unmarshal(rawToken, &token); err != nil {
+		return nil, fmt.Errorf("token file %s is corrupted, delete it and authenticate again: %w", s.Path, err)
+	}
+
+	return &token, nil
+}
+
+func (s *FileTokenStore) String() string {
+	return "file " + s.Path
+}
+
+func (s *FileTokenStore) Save(token *Token) error {
+	rawToken, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %w", err)
+	}
+
+	return writeFileAtomic(s.Path, rawToken, 0600)
+}
+
+// writeFileAtomic replaces path with data without ever leaving a partially
+// written file behind: the data is written and synced to a temporary file in
+// the same directory, which is then renamed over the target.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	// Sync the directory so the rename itself survives a crash. Not every
+	// platform supports this, so failures here are not fatal.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
+
+// MemoryTokenStore keeps the token in memory only, e.g. for tests or when an
+// external secrets manager owns persistence
+type MemoryTokenStore struct {
+	mu    sync.Mutex
+	token *Token
+}
+
+func (s *MemoryTokenStore) Load() (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == nil {
+		return nil, ErrNoToken
+	}
+	token := *s.token
+	return &token, nil
+}
+
+func (s *MemoryTokenStore) String() string {
+	return "memory"
+}
+
+func (s *MemoryTokenStore) Save(token *Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := *token
+	s.token = &saved
+	return nil
+}
+
+// KeyringTokenStore keeps the token in the operating system's keyring
+// (macOS Keychain, Windows Credential Manager or the Secret Service on Linux)
+type KeyringTokenStore struct {
+	Service string
+	User    string
+}
+
+func (s *KeyringTokenStore) Load() (*Token, error) {
+	secret, err := keyring.Get(s.Service, s.User)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, fmt.Errorf("%w: no keyring entry for %s/%s", ErrNoToken, s.Service, s.User)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token from keyring: %w", err)
+	}
+
+	var token Token
+	if err := json.Unmarshal([]byte(secret), &token); err != nil {
+		return nil, fmt.Errorf("failed to parse token from keyring: %w", err)
+	}
+
+	return &token, nil
+}
+
+func (s *KeyringTokenStore) String() string {
+	return fmt.Sprintf("keyring entry %s/%s", s.Service, s.User)
+}
+
+func (s *KeyringTokenStore) Save(token *Token) error {
+	rawToken, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %w", err)
+	}
+
+	if err := keyring.Set(s.Service, s.User, string(rawToken)); err != nil {
+		return fmt.Errorf("failed to write token to keyring: %w", err)
+	}
+
+	return nil
+}
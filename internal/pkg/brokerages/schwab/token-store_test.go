@@ -0,0 +1,89 @@
+package schwab_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+)
+
+func TestFileTokenStoreRoundTrip(t *testing.T) {
+	store := &schwab.FileTokenStore{Path: filepath.Join(t.TempDir(), "token.json")}
+	want := &schwab.Token{
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		ExpiresIn:    1800,
+		TokenType:    "Bearer",
+		ExpiresAt:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	if err := store.Save(want); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	got, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got.AccessToken != want.AccessToken || got.RefreshToken != want.RefreshToken || !got.ExpiresAt.Equal(want.ExpiresAt) {
+		t.Errorf("Load() = %+v, want %+v", got, want)
+	}
+
+	info, err := os.Stat(store.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("token file mode = %v, want 0600", perm)
+	}
+}
+
+func TestFileTokenStoreFailedSaveKeepsPreviousToken(t *testing.T) {
+	// The name fits, but the temporary file written beside it does not, so
+	// the save fails before anything is renamed over the token
+	dir := t.TempDir()
+	store := &schwab.FileTokenStore{Path: filepath.Join(dir, strings.Repeat("t", 250))}
+	if err := store.Save(&schwab.Token{AccessToken: "old", RefreshToken: "old-refresh"}); err == nil {
+		t.Fatal("Save() succeeded, want the temporary file name to be too long")
+	}
+
+	previous := []byte(`{"access_token":"old","refresh_token":"old-refresh"}`)
+	if err := os.WriteFile(store.Path, previous, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(&schwab.Token{AccessToken: "new", RefreshToken: "new-refresh"}); err == nil {
+		t.Fatal("Save() succeeded, want an error")
+	}
+
+	got, err := os.ReadFile(store.Path)
+	if err != nil {
+		t.Fatalf("token file is gone after a failed save: %v", err)
+	}
+	if string(got) != string(previous) {
+		t.Errorf("token file = %s, want the previous token %s", got, previous)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("directory holds %v, want only the token file", names)
+	}
+}
+
+func TestFileTokenStoreLoadMissingFile(t *testing.T) {
+	store := &schwab.FileTokenStore{Path: filepath.Join(t.TempDir(), "missing.json")}
+
+	if _, err := store.Load(); !errors.Is(err, schwab.ErrNoToken) {
+		t.Errorf("Load() error = %v, want ErrNoToken", err)
+	}
+}
@@ -0,0 +1,233 @@
+package schwab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+const (
+	transactionsPath = "/trader/v1/accounts/%s/transactions"
+
+	// Schwab rejects transaction queries spanning more than a year and
+	// truncates responses at maxTransactionsPerRequest entries
+	maxTransactionRange       = 365 * 24 * time.Hour
+	maxTransactionsPerRequest = 3000
+	minTransactionRange       = 24 * time.Hour
+
+	// schwabTimeLayout is the timestamp format Schwab accepts in query parameters
+	schwabTimeLayout = "2006-01-02T15:04:05.000Z"
+)
+
+// allTransactionTypes are the Schwab activity types queried when the caller
+// does not restrict them
+var allTransactionTypes = []string{
+	"TRADE",
+	"RECEIVE_AND_DELIVER",
+	"DIVIDEND_OR_INTEREST",
+	"ACH_RECEIPT",
+	"ACH_DISBURSEMENT",
+	"CASH_RECEIPT",
+	"CASH_DISBURSEMENT",
+	"ELECTRONIC_FUND",
+	"WIRE_OUT",
+	"WIRE_IN",
+	"JOURNAL",
+	"MEMORANDUM",
+	"MARGIN_CALL",
+	"MONEY_MARKET",
+	"SMA_ADJUSTMENT",
+}
+
+// schwabTransaction mirrors an entry returned by the transactions endpoint
+type schwabTransaction struct {
+	ActivityID    int64       `json:"activityId"`
+	Time          string      `json:"time"`
+	Type          string      `json:"type"`
+	Description   string      `json:"description"`
+	NetAmount     json.Number `json:"netAmount"`
+	TransferItems []struct {
+		Instrument struct {
+			AssetType string `json:"assetType"`
+			Symbol    string `json:"symbol"`
+		} `json:"instrument"`
+		Amount  json.Number `json:"amount"`
+		Cost    json.Number `json:"cost"`
+		Price   json.Number `json:"price"`
+		FeeType string      `json:"feeType"`
+	} `json:"transferItems"`
+}
+
+// GetTransactions retrieves account activity between from and to, optionally
+// restricted to the given Schwab activity types (e.g. TRADE,
+// DIVIDEND_OR_INTEREST). Ranges longer than Schwab allows per request are
+// split internally and results are returned oldest first.
+// Documentation: https://developer.schwab.com/products/trader-api--individual/details/specifications/Retail%20Trader%20API%20Production
+// Endpoint: GET /trader/v1/accounts/{accountNumber}/transactions
+func (c *Client) GetTransactions(ctx context.Context, accountID string, from, to time.Time, types []string) ([]brokerage.Transaction, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("invalid transaction range: from %s is not before to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+
+	accountHash, err := c.resolveAccountID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(types) == 0 {
+		types = allTransactionTypes
+	}
+
+	seen := make(map[int64]bool)
+	var transactions []brokerage.Transaction
+	for start := from; start.Before(to); start = start.Add(maxTransactionRange) {
+		end := start.Add(maxTransactionRange)
+		if end.After(to) {
+			end = to
+		}
+
+		schwabTransactions, err := c.getTransactionsInRange(ctx, accountHash, start, end, types)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, st := range schwabTransactions {
+			if seen[st.ActivityID] {
+				continue
+			}
+			seen[st.ActivityID] = true
+			transactions = append(transactions, convertTransaction(st))
+		}
+	}
+
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].Date.Before(transactions[j].Date)
+	})
+
+	return transactions, nil
+}
+
+// getTransactionsInRange fetches a single date range. When Schwab truncates
+// the response, the range is halved and each half fetched separately.
+func (c *Client) getTransactionsInRange(ctx context.Context, accountHash string, from, to time.Time, types []string) ([]schwabTransaction, error) {
+	query := url.Values{}
+	query.Set("startDate", from.UTC().Format(schwabTimeLayout))
+	query.Set("endDate", to.UTC().Format(schwabTimeLayout))
+	query.Set("types", strings.Join(types, ","))
+
+	path := fmt.Sprintf(transactionsPath, accountHash) + "?" + query.Encode()
+	resp, err := c.makeRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transactions response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("get transactions", resp, body)
+	}
+
+	var schwabTransactions []schwabTransaction
+	if err := json.Unmarshal(body, &schwabTransactions); err != nil {
+		return nil, fmt.Errorf("failed to parse transactions response: %w", err)
+	}
+
+	if len(schwabTransactions) < maxTransactionsPerRequest || to.Sub(from) <= minTransactionRange {
+		return schwabTransactions, nil
+	}
+
+	mid := from.Add(to.Sub(from) / 2)
+	first, err := c.getTransactionsInRange(ctx, accountHash, from, mid, types)
+	if err != nil {
+		return nil, err
+	}
+	second, err := c.getTransactionsInRange(ctx, accountHash, mid, to, types)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(first, second...), nil
+}
+
+// convertTransaction maps a Schwab activity onto the brokerage Transaction.
+// The traded security, if any, is the first transfer item that isn't cash;
+// transfer items carrying a fee type are summed into Fees.
+func convertTransaction(st schwabTransaction) brokerage.Transaction {
+	transaction := brokerage.Transaction{
+		ID:          fmt.Sprintf("%d", st.ActivityID),
+		RawType:     st.Type,
+		Description: st.Description,
+		Amount:      decimalFrom(st.NetAmount),
+		RawResponse: st,
+	}
+
+	if t, err := parseSchwabTime(st.Time); err == nil {
+		transaction.Date = t
+	}
+
+	for _, item := range st.TransferItems {
+		switch {
+		case item.FeeType != "":
+			transaction.Fees = transaction.Fees.Add(decimalFrom(item.Cost).Abs())
+		case item.Instrument.AssetType != "CURRENCY" && transaction.Symbol == "":
+			transaction.Symbol = item.Instrument.Symbol
+			transaction.Quantity = decimalFrom(item.Amount)
+			transaction.Price = decimalFrom(item.Price)
+		}
+	}
+
+	transaction.Type = convertTransactionType(st.Type, st.Description, transaction.Symbol)
+	return transaction
+}
+
+// convertTransactionType normalizes Schwab's activity type. Schwab reports
+// dividends and interest under one type, so they are told apart by the
+// description and whether a security is involved.
+func convertTransactionType(schwabType, description, symbol string) brokerage.TransactionType {
+	switch schwabType {
+	case "TRADE":
+		return brokerage.TransactionTypeTrade
+	case "DIVIDEND_OR_INTEREST":
+		if symbol == "" || strings.Contains(strings.ToUpper(description), "INTEREST") {
+			return brokerage.TransactionTypeInterest
+		}
+		return brokerage.TransactionTypeDividend
+	case "ACH_RECEIPT", "ACH_DISBURSEMENT", "CASH_RECEIPT", "CASH_DISBURSEMENT",
+		"ELECTRONIC_FUND", "WIRE_OUT", "WIRE_IN", "JOURNAL", "RECEIVE_AND_DELIVER":
+		return brokerage.TransactionTypeTransfer
+	default:
+		return brokerage.TransactionTypeOther
+	}
+}
+
+// parseSchwabTime parses the timestamp formats found in Schwab responses,
+// which use either RFC 3339 or a numeric zone offset without a colon
+func parseSchwabTime(value string) (time.Time, error) {
+	layouts := []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05-0700",
+		"2006-01-02T15:04:05.000-0700",
+	}
+
+	var err error
+	for _, layout := range layouts {
+		var t time.Time
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, err
+}
@@ -0,0 +1,306 @@
+package schwab_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab/schwabtest"
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+const transactionsRequestPath = "/trader/v1/accounts/" + schwabtest.AccountHash + "/transactions"
+
+// transactionsFixture is a transactions response holding, newest first, a
+// deposit, a buy with a commission, a dividend and interest on cash
+const transactionsFixture = `[
+	{
+		"activityId": 4004,
+		"time": "2025-03-20T09:00:00+0000",
+		"accountNumber": "12345678",
+		"type": "ACH_RECEIPT",
+		"status": "VALID",
+		"subAccount": "CASH",
+		"description": "ACH DEPOSIT",
+		"netAmount": 1000,
+		"transferItems": [
+			{"instrument": {"assetType": "CURRENCY", "symbol": "CURRENCY_USD"}, "amount": 1000, "cost": 1000}
+		]
+	},
+	{
+		"activityId": 4003,
+		"time": "2025-03-14T14:30:05+0000",
+		"accountNumber": "12345678",
+		"type": "TRADE",
+		"status": "VALID",
+		"subAccount": "CASH",
+		"tradeDate": "2025-03-14T14:30:05+0000",
+		"netAmount": -2500.65,
+		"transferItems": [
+			{"instrument": {"assetType": "CURRENCY", "symbol": "CURRENCY_USD"}, "amount": 0, "cost": -0.65, "feeType": "COMMISSION"},
+			{"instrument": {"assetType": "CURRENCY", "symbol": "CURRENCY_USD"}, "amount": 0, "cost": 0, "feeType": "SEC_FEE"},
+			{
+				"instrument": {"assetType": "COLLECTIVE_INVESTMENT", "symbol": "VTI", "description": "VANGUARD TOTAL STOCK MARKET ETF"},
+				"amount": 10,
+				"cost": -2500,
+				"price": 250,
+				"positionEffect": "OPENING"
+			}
+		]
+	},
+	{
+		"activityId": 4002,
+		"time": "2025-03-10T00:00:00+0000",
+		"accountNumber": "12345678",
+		"type": "DIVIDEND_OR_INTEREST",
+		"status": "VALID",
+		"subAccount": "CASH",
+		"description": "ORDINARY DIVIDEND",
+		"netAmount": 12.34,
+		"transferItems": [
+			{"instrument": {"assetType": "EQUITY", "symbol": "SCHD"}, "amount": 0, "cost": 0}
+		]
+	},
+	{
+		"activityId": 4001,
+		"time": "2025-02-28T00:00:00+0000",
+		"accountNumber": "12345678",
+		"type": "DIVIDEND_OR_INTEREST",
+		"status": "VALID",
+		"subAccount": "CASH",
+		"description": "INTEREST INCOME",
+		"netAmount": 1.02,
+		"transferItems": [
+			{"instrument": {"assetType": "CURRENCY", "symbol": "CURRENCY_USD"}, "amount": 1.02, "cost": 1.02}
+		]
+	}
+]`
+
+// transactionQueries returns the queries of the transaction requests the
+// mock received
+func transactionQueries(t *testing.T, mock *schwabtest.Server) []url.Values {
+	t.Helper()
+
+	var queries []url.Values
+	for _, req := range mock.Requests() {
+		if req.Path != transactionsRequestPath {
+			continue
+		}
+		query, err := url.ParseQuery(req.Query)
+		if err != nil {
+			t.Fatalf("transactions query %q: %v", req.Query, err)
+		}
+		queries = append(queries, query)
+	}
+	return queries
+}
+
+func TestGetTransactionsFixture(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	mock.SetResponse("GET", transactionsRequestPath, http.StatusOK, transactionsFixture)
+	client := mock.NewClient()
+
+	from := time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)
+	transactions, err := client.GetTransactions(t.Context(), schwabtest.AccountNumber, from, from.AddDate(0, 2, 0), nil)
+	if err != nil {
+		t.Fatalf("GetTransactions() error = %v", err)
+	}
+
+	tests := []struct {
+		id          string
+		date        time.Time
+		kind        brokerage.TransactionType
+		rawType     string
+		symbol      string
+		quantity    string
+		price       string
+		amount      string
+		fees        string
+		description string
+	}{
+		{
+			id: "4001", date: time.Date(2025, time.February, 28, 0, 0, 0, 0, time.UTC),
+			kind: brokerage.TransactionTypeInterest, rawType: "DIVIDEND_OR_INTEREST",
+			quantity: "0", price: "0", amount: "1.02", fees: "0", description: "INTEREST INCOME",
+		},
+		{
+			id: "4002", date: time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC),
+			kind: brokerage.TransactionTypeDividend, rawType: "DIVIDEND_OR_INTEREST", symbol: "SCHD",
+			quantity: "0", price: "0", amount: "12.34", fees: "0", description: "ORDINARY DIVIDEND",
+		},
+		{
+			id: "4003", date: time.Date(2025, time.March, 14, 14, 30, 5, 0, time.UTC),
+			kind: brokerage.TransactionTypeTrade, rawType: "TRADE", symbol: "VTI",
+			quantity: "10", price: "250", amount: "-2500.65", fees: "0.65",
+		},
+		{
+			id: "4004", date: time.Date(2025, time.March, 20, 9, 0, 0, 0, time.UTC),
+			kind: brokerage.TransactionTypeTransfer, rawType: "ACH_RECEIPT",
+			quantity: "0", price: "0", amount: "1000", fees: "0", description: "ACH DEPOSIT",
+		},
+	}
+
+	// Schwab lists the newest first; they are returned oldest first
+	if len(transactions) != len(tests) {
+		t.Fatalf("GetTransactions() returned %d transactions, want %d", len(transactions), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			got := transactions[i]
+			if got.ID != tt.id || !got.Date.Equal(tt.date) || got.Type != tt.kind || got.RawType != tt.rawType ||
+				got.Symbol != tt.symbol || got.Description != tt.description {
+				t.Errorf("transaction %d = %s %s %s (%s) %q %q, want %s %s %s (%s) %q %q", i,
+					got.ID, got.Date, got.Type, got.RawType, got.Symbol, got.Description,
+					tt.id, tt.date, tt.kind, tt.rawType, tt.symbol, tt.description)
+			}
+			for _, field := range []struct {
+				name string
+				got  decimal.Decimal
+				want string
+			}{
+				{"Quantity", got.Quantity, tt.quantity},
+				{"Price", got.Price, tt.price},
+				{"Amount", got.Amount, tt.amount},
+				{"Fees", got.Fees, tt.fees},
+			} {
+				if !field.got.Equal(decimal.RequireFromString(field.want)) {
+					t.Errorf("%s = %s, want %s", field.name, field.got, field.want)
+				}
+			}
+		})
+	}
+
+	queries := transactionQueries(t, mock)
+	if len(queries) != 1 {
+		t.Fatalf("sent %d transaction requests, want 1", len(queries))
+	}
+	if got := queries[0].Get("startDate"); got != "2025-02-01T00:00:00.000Z" {
+		t.Errorf("startDate = %s, want 2025-02-01T00:00:00.000Z", got)
+	}
+	if got := queries[0].Get("endDate"); got != "2025-04-01T00:00:00.000Z" {
+		t.Errorf("endDate = %s, want 2025-04-01T00:00:00.000Z", got)
+	}
+	if types := queries[0].Get("types"); !strings.Contains(types, "TRADE") || !strings.Contains(types, "DIVIDEND_OR_INTEREST") {
+		t.Errorf("types = %s, want every activity type", types)
+	}
+}
+
+func TestGetTransactionsTypes(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	mock.SetResponse("GET", transactionsRequestPath, http.StatusOK, `[]`)
+	client := mock.NewClient()
+
+	from := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	transactions, err := client.GetTransactions(t.Context(), schwabtest.AccountHash, from, from.AddDate(0, 1, 0),
+		[]string{"TRADE", "DIVIDEND_OR_INTEREST"})
+	if err != nil {
+		t.Fatalf("GetTransactions() error = %v", err)
+	}
+	if len(transactions) != 0 {
+		t.Errorf("GetTransactions() = %v, want none", transactions)
+	}
+	if queries := transactionQueries(t, mock); len(queries) != 1 || queries[0].Get("types") != "TRADE,DIVIDEND_OR_INTEREST" {
+		t.Errorf("transaction queries = %v, want types TRADE,DIVIDEND_OR_INTEREST", queries)
+	}
+}
+
+func TestGetTransactionsSplitsLongRanges(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	mock.SetResponse("GET", transactionsRequestPath, http.StatusOK, transactionsFixture)
+	client := mock.NewClient()
+
+	from := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(2, 6, 0)
+	transactions, err := client.GetTransactions(t.Context(), schwabtest.AccountHash, from, to, nil)
+	if err != nil {
+		t.Fatalf("GetTransactions() error = %v", err)
+	}
+
+	// Every chunk got the same response, and its repeats are dropped
+	if len(transactions) != 4 {
+		t.Errorf("GetTransactions() returned %d transactions, want the fixture's 4 once each", len(transactions))
+	}
+
+	// Two and a half years take three requests of at most a year, each
+	// starting where the one before ended
+	queries := transactionQueries(t, mock)
+	if len(queries) != 3 {
+		t.Fatalf("sent %d transaction requests, want 3", len(queries))
+	}
+	next := from
+	for i, query := range queries {
+		start, err := time.Parse(time.RFC3339, query.Get("startDate"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		end, err := time.Parse(time.RFC3339, query.Get("endDate"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !start.Equal(next) {
+			t.Errorf("request %d starts %s, want %s", i, start, next)
+		}
+		if end.Sub(start) > 365*24*time.Hour {
+			t.Errorf("request %d spans %s to %s, more than a year", i, start, end)
+		}
+		next = end
+	}
+	if !next.Equal(to) {
+		t.Errorf("the last request ends %s, want %s", next, to)
+	}
+}
+
+func TestGetTransactionsSplitsTruncatedResponses(t *testing.T) {
+	// A response of 3000 entries is Schwab's cap, so the range is halved
+	// until a day is left
+	full := make([]map[string]any, 3000)
+	for i := range full {
+		full[i] = map[string]any{"activityId": i + 1, "time": "2025-03-01T12:00:00+0000", "type": "TRADE", "netAmount": 1}
+	}
+	body, err := json.Marshal(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	mock.SetResponse("GET", transactionsRequestPath, http.StatusOK, string(body))
+	client := mock.NewClient()
+
+	from := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	transactions, err := client.GetTransactions(t.Context(), schwabtest.AccountHash, from, from.AddDate(0, 0, 4), nil)
+	if err != nil {
+		t.Fatalf("GetTransactions() error = %v", err)
+	}
+	if len(transactions) != 3000 {
+		t.Errorf("GetTransactions() returned %d transactions, want 3000", len(transactions))
+	}
+
+	// 4 days, then 2 halves of 2 days, then 4 quarters of a day
+	if n := len(transactionQueries(t, mock)); n != 7 {
+		t.Errorf("sent %d transaction requests, want 7", n)
+	}
+}
+
+func TestGetTransactionsInvalidRange(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+
+	from := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	for _, to := range []time.Time{from, from.Add(-time.Hour)} {
+		if _, err := client.GetTransactions(t.Context(), schwabtest.AccountHash, from, to, nil); err == nil {
+			t.Errorf("GetTransactions(%s, %s) error = nil, want an invalid range error", from, to)
+		}
+	}
+	if n := len(transactionQueries(t, mock)); n != 0 {
+		t.Errorf("sent %d transaction requests for invalid ranges, want 0", n)
+	}
+}
@@ -0,0 +1,95 @@
+package schwab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const userPreferencePath = "/trader/v1/userPreference"
+
+// UserPreferences holds the account display settings and streamer
+// connection details returned by the user preference endpoint
+type UserPreferences struct {
+	Accounts []AccountPreference
+	// StreamerInfo is nil when Schwab returns no streamer details
+	StreamerInfo *StreamerInfo
+}
+
+// AccountPreference is the display configuration of one account
+type AccountPreference struct {
+	AccountNumber  string `json:"accountNumber"`
+	NickName       string `json:"nickName"`
+	PrimaryAccount bool   `json:"primaryAccount"`
+	Type           string `json:"type"`
+	DisplayAcctID  string `json:"displayAcctId"`
+}
+
+// StreamerInfo holds the credentials needed to log into the streamer websocket
+type StreamerInfo struct {
+	StreamerSocketURL      string `json:"streamerSocketUrl"`
+	SchwabClientCustomerID string `json:"schwabClientCustomerId"`
+	SchwabClientCorrelID   string `json:"schwabClientCorrelId"`
+	SchwabClientChannel    string `json:"schwabClientChannel"`
+	SchwabClientFunctionID string `json:"schwabClientFunctionId"`
+}
+
+// GetUserPreferences retrieves account nicknames and streamer connection info
+// Documentation: https://developer.schwab.com/products/trader-api--individual/details/specifications/Retail%20Trader%20API%20Production
+// Endpoint: GET /trader/v1/userPreference
+func (c *Client) GetUserPreferences(ctx context.Context) (*UserPreferences, error) {
+	resp, err := c.makeRequest(ctx, "GET", userPreferencePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user preference response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("get user preference", resp, body)
+	}
+
+	// Schwab has returned both a bare object and a single-element array here,
+	// and likewise for streamerInfo, so both shapes are accepted
+	var schwabPreferences []struct {
+		Accounts     []AccountPreference `json:"accounts"`
+		StreamerInfo json.RawMessage     `json:"streamerInfo"`
+	}
+	if err := json.Unmarshal(asJSONArray(body), &schwabPreferences); err != nil {
+		return nil, fmt.Errorf("failed to parse user preference response: %w", err)
+	}
+
+	preferences := &UserPreferences{}
+	if len(schwabPreferences) == 0 {
+		return preferences, nil
+	}
+	preferences.Accounts = schwabPreferences[0].Accounts
+
+	if rawStreamerInfo := schwabPreferences[0].StreamerInfo; len(rawStreamerInfo) > 0 && string(rawStreamerInfo) != "null" {
+		var streamerInfo []StreamerInfo
+		if err := json.Unmarshal(asJSONArray(rawStreamerInfo), &streamerInfo); err != nil {
+			return nil, fmt.Errorf("failed to parse streamer info: %w", err)
+		}
+		if len(streamerInfo) > 0 {
+			preferences.StreamerInfo = &streamerInfo[0]
+		}
+	}
+
+	return preferences, nil
+}
+
+// asJSONArray wraps a JSON object in an array, leaving arrays untouched
+func asJSONArray(raw []byte) []byte {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return append(append([]byte{'['}, trimmed...), ']')
+	}
+	return trimmed
+}
@@ -0,0 +1,153 @@
+package schwab_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab"
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/schwab/schwabtest"
+)
+
+const userPreferenceRequestPath = "/trader/v1/userPreference"
+
+// userPreferenceFixture is a user preference response for two accounts,
+// as Schwab sends it wrapped in an array
+const userPreferenceFixture = `[
+	{
+		"accounts": [
+			{
+				"accountNumber": "12345678",
+				"primaryAccount": true,
+				"type": "BROKERAGE",
+				"nickName": "Individual",
+				"displayAcctId": "...678",
+				"autoPositionEffect": false,
+				"accountColor": "Green"
+			},
+			{
+				"accountNumber": "87654321",
+				"primaryAccount": false,
+				"type": "IRA",
+				"nickName": "Roth IRA",
+				"displayAcctId": "...321",
+				"autoPositionEffect": false,
+				"accountColor": "Blue"
+			}
+		],
+		"streamerInfo": [
+			{
+				"streamerSocketUrl": "wss://streamer-api.schwab.com/ws",
+				"schwabClientCustomerId": "customer-id",
+				"schwabClientCorrelId": "correl-id",
+				"schwabClientChannel": "N9",
+				"schwabClientFunctionId": "APIAPP"
+			}
+		],
+		"offers": [{"level2Permissions": true, "mktDataPermission": "NP"}]
+	}
+]`
+
+func TestGetUserPreferences(t *testing.T) {
+	accounts := []schwab.AccountPreference{
+		{AccountNumber: "12345678", NickName: "Individual", PrimaryAccount: true, Type: "BROKERAGE", DisplayAcctID: "...678"},
+		{AccountNumber: "87654321", NickName: "Roth IRA", Type: "IRA", DisplayAcctID: "...321"},
+	}
+	streamerInfo := &schwab.StreamerInfo{
+		StreamerSocketURL:      "wss://streamer-api.schwab.com/ws",
+		SchwabClientCustomerID: "customer-id",
+		SchwabClientCorrelID:   "correl-id",
+		SchwabClientChannel:    "N9",
+		SchwabClientFunctionID: "APIAPP",
+	}
+	accountsJSON := `[
+		{"accountNumber": "12345678", "primaryAccount": true, "type": "BROKERAGE", "nickName": "Individual", "displayAcctId": "...678"},
+		{"accountNumber": "87654321", "primaryAccount": false, "type": "IRA", "nickName": "Roth IRA", "displayAcctId": "...321"}
+	]`
+	streamerJSON := `{
+		"streamerSocketUrl": "wss://streamer-api.schwab.com/ws",
+		"schwabClientCustomerId": "customer-id",
+		"schwabClientCorrelId": "correl-id",
+		"schwabClientChannel": "N9",
+		"schwabClientFunctionId": "APIAPP"
+	}`
+
+	tests := []struct {
+		name    string
+		fixture string
+		want    *schwab.UserPreferences
+	}{
+		{
+			name:    "array",
+			fixture: userPreferenceFixture,
+			want:    &schwab.UserPreferences{Accounts: accounts, StreamerInfo: streamerInfo},
+		},
+		{
+			name:    "bare object and streamer info",
+			fixture: `{"accounts": ` + accountsJSON + `, "streamerInfo": ` + streamerJSON + `}`,
+			want:    &schwab.UserPreferences{Accounts: accounts, StreamerInfo: streamerInfo},
+		},
+		{
+			name:    "null streamer info",
+			fixture: `[{"accounts": ` + accountsJSON + `, "streamerInfo": null}]`,
+			want:    &schwab.UserPreferences{Accounts: accounts},
+		},
+		{
+			name:    "no streamer info",
+			fixture: `[{"accounts": ` + accountsJSON + `}]`,
+			want:    &schwab.UserPreferences{Accounts: accounts},
+		},
+		{
+			name:    "empty streamer info",
+			fixture: `[{"accounts": ` + accountsJSON + `, "streamerInfo": []}]`,
+			want:    &schwab.UserPreferences{Accounts: accounts},
+		},
+		{
+			name:    "empty response",
+			fixture: `[]`,
+			want:    &schwab.UserPreferences{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := schwabtest.NewServer()
+			defer mock.Close()
+			mock.SetResponse("GET", userPreferenceRequestPath, http.StatusOK, tt.fixture)
+			client := mock.NewClient()
+
+			preferences, err := client.GetUserPreferences(t.Context())
+			if err != nil {
+				t.Fatalf("GetUserPreferences() error = %v", err)
+			}
+			if !reflect.DeepEqual(preferences, tt.want) {
+				t.Errorf("GetUserPreferences() = %+v, want %+v", preferences, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetAccountsMergesNicknames(t *testing.T) {
+	mock := schwabtest.NewServer()
+	defer mock.Close()
+	mock.SetResponse("GET", userPreferenceRequestPath, http.StatusOK, userPreferenceFixture)
+	client := mock.NewClient()
+
+	accounts, err := client.GetAccounts(t.Context())
+	if err != nil {
+		t.Fatalf("GetAccounts() error = %v", err)
+	}
+	if len(accounts) != 1 || accounts[0].AccountNumber != schwabtest.AccountNumber || accounts[0].Nickname != "Individual" {
+		t.Errorf("GetAccounts() = %+v, want account %s nicknamed Individual", accounts, schwabtest.AccountNumber)
+	}
+
+	// Without preferences the accounts are still returned, unnamed
+	mock.SetResponse("GET", userPreferenceRequestPath, http.StatusForbidden, `{"message": "forbidden"}`)
+	accounts, err = client.GetAccounts(t.Context())
+	if err != nil {
+		t.Fatalf("GetAccounts() without preferences error = %v", err)
+	}
+	if len(accounts) != 1 || accounts[0].Nickname != "" {
+		t.Errorf("GetAccounts() without preferences = %+v, want the account without a nickname", accounts)
+	}
+}
@@ -0,0 +1,35 @@
+package tradier
+
+import (
+	"strings"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// retirementKinds maps the classifications of Tradier's retirement accounts
+// onto brokerage.AccountKind
+var retirementKinds = map[string]brokerage.AccountKind{
+	"traditional_ira": brokerage.AccountKindTraditionalIRA,
+	"rollover_ira":    brokerage.AccountKindTraditionalIRA,
+	"sep_ira":         brokerage.AccountKindTraditionalIRA,
+	"simple_ira":      brokerage.AccountKindTraditionalIRA,
+	"roth_ira":        brokerage.AccountKindRothIRA,
+}
+
+// accountKind classifies an account from its type (cash, margin or pdt) and
+// its classification, which names the registration, e.g. individual or
+// roth_ira. Retirement classifications take precedence over the type.
+func accountKind(accountType, classification string) brokerage.AccountKind {
+	if kind, ok := retirementKinds[strings.ToLower(strings.TrimSpace(classification))]; ok {
+		return kind
+	}
+
+	switch strings.ToLower(strings.TrimSpace(accountType)) {
+	case "cash":
+		return brokerage.AccountKindCash
+	case "margin", "pdt":
+		return brokerage.AccountKindMargin
+	default:
+		return brokerage.AccountKindUnknown
+	}
+}
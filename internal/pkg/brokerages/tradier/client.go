@@ -0,0 +1,423 @@
+// Package tradier implements the brokerage.BrokerageClient interface for
+// Tradier's brokerage API.
+package tradier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+// Tradier API Documentation Links:
+// Accounts: https://documentation.tradier.com/brokerage-api/user/get-profile
+// Trading: https://documentation.tradier.com/brokerage-api/trading/getting-started
+// Market Data: https://documentation.tradier.com/brokerage-api/markets/get-quotes
+
+const (
+	// Tradier endpoints, used when Config.BaseURL is empty
+	productionBaseURL = "https://api.tradier.com/v1"
+	sandboxBaseURL    = "https://sandbox.tradier.com/v1"
+
+	// Tradier API paths
+	profilePath   = "/user/profile"
+	accountsPath  = "/accounts"
+	quotesPath    = "/markets/quotes"
+	balancesPath  = accountsPath + "/%s/balances"
+	positionsPath = accountsPath + "/%s/positions"
+	ordersPath    = accountsPath + "/%s/orders"
+)
+
+// Version is reported in the default User-Agent. Release builds set it with
+// -ldflags "-X github.com/alysoliman1/money-pies/internal/pkg/brokerages/tradier.Version=v1.2.3".
+var Version = "dev"
+
+// Config holds Tradier API configuration
+type Config struct {
+	// AccessToken is a personal access token from the Tradier dashboard.
+	// Sandbox accounts have their own token.
+	AccessToken string `json:"access_token"`
+
+	// Sandbox trades in Tradier's sandbox environment, whose quotes are
+	// delayed
+	Sandbox bool `json:"sandbox"`
+
+	// BaseURL overrides the API endpoint, including its /v1 prefix, e.g. to
+	// point the client at a local mock server. Empty uses the production or
+	// sandbox endpoint according to Sandbox.
+	BaseURL string `json:"base_url"`
+	// AllowInsecure permits a plain http BaseURL for local testing
+	AllowInsecure bool `json:"allow_insecure"`
+
+	// UserAgent identifies the process in API requests. Empty uses
+	// "money-pies/<Version>".
+	UserAgent string `json:"user_agent"`
+
+	// DryRun logs orders to the WithLogger logger instead of submitting them.
+	// PlaceOrder, ReplaceOrder and CancelPendingOrder never reach Tradier;
+	// their simulated orders fill at the current quote when GetOrderStatus
+	// is called. Reads and previews are unaffected.
+	DryRun bool `json:"dry_run"`
+}
+
+// Client implements the brokerage.BrokerageClient interface for Tradier.
+// Accounts are identified by their account number.
+type Client struct {
+	config     Config
+	httpClient *http.Client
+	timeout    time.Duration
+	logger     *slog.Logger
+
+	// dryRun simulates order submission when Config.DryRun is set
+	dryRun *brokerage.DryRunOrders
+
+	// endpointErr records an invalid BaseURL, reported by every request
+	endpointErr error
+}
+
+// NewClient creates a new Tradier client. Unless WithHTTPClient is given, the
+// underlying HTTP client uses defaultTimeout or the WithTimeout value.
+func NewClient(config Config, opts ...Option) *Client {
+	if config.BaseURL == "" {
+		config.BaseURL = productionBaseURL
+		if config.Sandbox {
+			config.BaseURL = sandboxBaseURL
+		}
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.UserAgent == "" {
+		config.UserAgent = "money-pies/" + Version
+	}
+
+	c := &Client{
+		config:      config,
+		timeout:     defaultTimeout,
+		logger:      slog.New(slog.DiscardHandler),
+		endpointErr: validateBaseURL(config),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if config.DryRun {
+		c.dryRun = brokerage.NewDryRunOrders(c, c.logger)
+	}
+
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{
+			Timeout: c.timeout,
+		}
+	}
+
+	return c
+}
+
+// validateBaseURL checks that the configured endpoint is absolute and uses
+// https, unless AllowInsecure permits plain http
+func validateBaseURL(config Config) error {
+	u, err := url.Parse(config.BaseURL)
+	if err != nil {
+		return fmt.Errorf("invalid base_url %q: %w", config.BaseURL, err)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid base_url %q: must be an absolute URL", config.BaseURL)
+	}
+
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && config.AllowInsecure:
+	default:
+		return fmt.Errorf("invalid base_url %q: scheme must be https unless allow_insecure is set", config.BaseURL)
+	}
+	return nil
+}
+
+// IsAuthenticated reports whether the client has an access token. Personal
+// access tokens do not expire, so a configured token is only found to be
+// wrong by a request.
+func (c *Client) IsAuthenticated() bool {
+	return c.config.AccessToken != ""
+}
+
+// SupportsFractionalShares reports that Tradier only trades whole shares
+func (c *Client) SupportsFractionalShares() bool {
+	return false
+}
+
+// request sends an API request to path with form, if any, as its
+// form-encoded body and returns the response body. Responses other than 2xx
+// fail with an *APIError, which also matches ErrNotAuthenticated for a
+// rejected token.
+func (c *Client) request(ctx context.Context, op, method, path string, form url.Values) ([]byte, error) {
+	if c.endpointErr != nil {
+		return nil, c.endpointErr
+	}
+	if !c.IsAuthenticated() {
+		return nil, ErrNotAuthenticated
+	}
+
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.config.BaseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.config.AccessToken)
+	req.Header.Set("User-Agent", c.config.UserAgent)
+	req.Header.Set("Accept", "application/json")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		c.logger.WarnContext(ctx, "tradier request failed",
+			slog.String("method", method),
+			slog.String("path", path),
+			slog.Duration("latency", latency),
+			slog.String("error", err.Error()))
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	level := slog.LevelInfo
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		level = slog.LevelWarn
+	}
+	c.logger.LogAttrs(ctx, level, "tradier request",
+		slog.String("method", method),
+		slog.String("path", path),
+		slog.Int("status", resp.StatusCode),
+		slog.Duration("latency", latency))
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response: %w", op, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(op, resp, respBody)
+	}
+
+	return respBody, nil
+}
+
+// tradierAccount is an account of the user profile
+type tradierAccount struct {
+	AccountNumber  string `json:"account_number"`
+	Classification string `json:"classification"`
+	Status         string `json:"status"`
+	Type           string `json:"type"`
+}
+
+// tradierBalances mirrors the balances object. Only the section matching
+// the account type, cash, margin or pdt, is present.
+type tradierBalances struct {
+	AccountType     string          `json:"account_type"`
+	TotalEquity     decimal.Decimal `json:"total_equity"`
+	TotalCash       decimal.Decimal `json:"total_cash"`
+	LongMarketValue decimal.Decimal `json:"long_market_value"`
+	Cash            *struct {
+		CashAvailable  decimal.Decimal `json:"cash_available"`
+		UnsettledFunds decimal.Decimal `json:"unsettled_funds"`
+	} `json:"cash"`
+	Margin *tradierMarginBalances `json:"margin"`
+	PDT    *tradierMarginBalances `json:"pdt"`
+}
+
+// tradierMarginBalances is the margin or pattern day trader section of the
+// balances
+type tradierMarginBalances struct {
+	StockBuyingPower decimal.Decimal `json:"stock_buying_power"`
+}
+
+// GetAccounts retrieves the user's accounts from their profile, each with
+// its balances. Closed accounts are skipped.
+// Documentation: https://documentation.tradier.com/brokerage-api/user/get-profile
+// Endpoint: GET /v1/user/profile, then GET /v1/accounts/{account_id}/balances per account
+func (c *Client) GetAccounts(ctx context.Context) ([]brokerage.Account, error) {
+	body, err := c.request(ctx, "get profile", "GET", profilePath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var profile struct {
+		Profile object[struct {
+			Account list[tradierAccount] `json:"account"`
+		}] `json:"profile"`
+	}
+	if err := json.Unmarshal(body, &profile); err != nil {
+		return nil, fmt.Errorf("failed to parse profile response: %w", err)
+	}
+
+	var accounts []brokerage.Account
+	for _, ta := range profile.Profile.Value.Account {
+		if strings.EqualFold(ta.Status, "closed") {
+			continue
+		}
+
+		balances, err := c.getBalances(ctx, ta.AccountNumber)
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, brokerage.Account{
+			AccountID:     ta.AccountNumber,
+			AccountNumber: ta.AccountNumber,
+			Nickname:      accountNickname(ta),
+			Type:          strings.ToUpper(ta.Type),
+			Kind:          accountKind(ta.Type, ta.Classification),
+			CashBalance:   balances.TotalCash,
+			SettledCash:   balances.settledCash(),
+			BuyingPower:   balances.buyingPower(),
+			MarketValue:   balances.LongMarketValue,
+			TotalValue:    balances.TotalEquity,
+		})
+	}
+
+	return accounts, nil
+}
+
+// buyingPower returns what the account can spend on stock: the cash
+// available in a cash account, or its stock buying power on margin
+func (b tradierBalances) buyingPower() decimal.Decimal {
+	switch {
+	case b.Cash != nil:
+		return b.Cash.CashAvailable
+	case b.Margin != nil:
+		return b.Margin.StockBuyingPower
+	case b.PDT != nil:
+		return b.PDT.StockBuyingPower
+	default:
+		return b.TotalCash
+	}
+}
+
+// settledCash returns the cash available without waiting for trades to
+// settle, which for margin accounts is all of it
+func (b tradierBalances) settledCash() decimal.Decimal {
+	if b.Cash != nil {
+		return b.Cash.CashAvailable
+	}
+	return b.TotalCash
+}
+
+// getBalances retrieves an account's balances
+// Documentation: https://documentation.tradier.com/brokerage-api/accounts/get-account-balance
+// Endpoint: GET /v1/accounts/{account_id}/balances
+func (c *Client) getBalances(ctx context.Context, accountID string) (tradierBalances, error) {
+	body, err := c.request(ctx, "get balances", "GET", fmt.Sprintf(balancesPath, url.PathEscape(accountID)), nil)
+	if err != nil {
+		return tradierBalances{}, err
+	}
+
+	var response struct {
+		Balances tradierBalances `json:"balances"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return tradierBalances{}, fmt.Errorf("failed to parse balances response: %w", err)
+	}
+	return response.Balances, nil
+}
+
+// accountNickname names an account after its classification, since Tradier
+// accounts have no nickname, e.g. "Individual" or "Roth IRA"
+func accountNickname(ta tradierAccount) string {
+	words := strings.Fields(strings.ReplaceAll(ta.Classification, "_", " "))
+	if len(words) == 0 {
+		return ta.AccountNumber
+	}
+	for i, word := range words {
+		if word == "ira" {
+			words[i] = "IRA"
+		} else {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// tradierPosition is a holding of an account. Tradier does not price
+// positions, only their cost basis.
+type tradierPosition struct {
+	Symbol    string          `json:"symbol"`
+	Quantity  decimal.Decimal `json:"quantity"`
+	CostBasis decimal.Decimal `json:"cost_basis"`
+}
+
+// GetPositions retrieves an account's positions, priced with a quote for
+// each symbol. Positions whose symbol could not be quoted keep a zero price
+// and value. Short positions have a negative quantity.
+// Documentation: https://documentation.tradier.com/brokerage-api/accounts/get-account-positions
+// Endpoint: GET /v1/accounts/{account_id}/positions
+func (c *Client) GetPositions(ctx context.Context, accountID string) ([]brokerage.Position, error) {
+	body, err := c.request(ctx, "get positions", "GET", fmt.Sprintf(positionsPath, url.PathEscape(accountID)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Positions object[struct {
+			Position list[tradierPosition] `json:"position"`
+		}] `json:"positions"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse positions response: %w", err)
+	}
+	tradierPositions := response.Positions.Value.Position
+	if len(tradierPositions) == 0 {
+		return []brokerage.Position{}, nil
+	}
+
+	symbols := make([]string, len(tradierPositions))
+	for i, p := range tradierPositions {
+		symbols[i] = p.Symbol
+	}
+	quotes, err := c.GetQuotes(ctx, symbols)
+	var missing *brokerage.MissingQuotesError
+	if err != nil && !errors.As(err, &missing) {
+		return nil, fmt.Errorf("failed to price positions: %w", err)
+	}
+
+	positions := make([]brokerage.Position, 0, len(tradierPositions))
+	for _, p := range tradierPositions {
+		position := brokerage.Position{
+			Symbol:   p.Symbol,
+			Quantity: p.Quantity,
+		}
+		position.LongQuantity, position.ShortQuantity = brokerage.LongShort(p.Quantity)
+		if !p.Quantity.IsZero() {
+			position.AveragePrice = p.CostBasis.Div(p.Quantity).Abs()
+		}
+		if quote, ok := quotes[p.Symbol]; ok {
+			position.Description = quote.Description
+			if tq, ok := quote.Raw.(tradierQuote); ok {
+				position.AssetType = tq.assetType()
+			}
+			position.DayPL = quote.DayChange(p.Quantity)
+			position.CurrentPrice = quote.Last
+			position.MarketValue = p.Quantity.Mul(quote.Last)
+			position.UnrealizedPL = position.MarketValue.Sub(p.CostBasis)
+			if !p.CostBasis.IsZero() {
+				position.UnrealizedPLPct = position.UnrealizedPL.Div(p.CostBasis.Abs()).InexactFloat64() * 100
+			}
+		}
+		positions = append(positions, position)
+	}
+
+	return positions, nil
+}
@@ -0,0 +1,673 @@
+package tradier_test
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/tradier"
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/tradier/tradiertest"
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+// Responses in testdata follow the examples of Tradier's documentation,
+// including the single element lists and "null" containers that Tradier's
+// JSON converted from XML has
+
+// serveFixture makes the mock answer method and path with status and the
+// testdata file name
+func serveFixture(t *testing.T, mock *tradiertest.Server, method, path string, status int, name string) {
+	t.Helper()
+
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.SetResponse(method, path, status, string(body))
+}
+
+// accountPath returns the path of an account endpoint of the mock account
+func accountPath(endpoint string) string {
+	return "/v1/accounts/" + tradiertest.AccountNumber + endpoint
+}
+
+// requireDecimal fails the test unless got is want
+func requireDecimal(t *testing.T, name string, got decimal.Decimal, want string) {
+	t.Helper()
+
+	if !got.Equal(decimal.RequireFromString(want)) {
+		t.Errorf("%s = %s, want %s", name, got, want)
+	}
+}
+
+// placeLimitOrder places a day limit buy of quantity VTI shares and returns
+// its ID
+func placeLimitOrder(t *testing.T, client *tradier.Client, quantity int64, limit string) string {
+	t.Helper()
+
+	order, err := client.PlaceOrder(t.Context(), tradiertest.AccountNumber, brokerage.OrderRequest{
+		Symbol:     "VTI",
+		Action:     brokerage.OrderActionBuy,
+		Type:       brokerage.OrderTypeLimit,
+		Quantity:   decimal.NewFromInt(quantity),
+		LimitPrice: brokerage.DecimalPtr(decimal.RequireFromString(limit)),
+	})
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	return order.ID
+}
+
+func TestGetAccountsFixture(t *testing.T) {
+	mock := tradiertest.NewServer()
+	defer mock.Close()
+	serveFixture(t, mock, "GET", "/v1/user/profile", http.StatusOK, "profile.json")
+	serveFixture(t, mock, "GET", "/v1/accounts/VA000001/balances", http.StatusOK, "balances-margin.json")
+	serveFixture(t, mock, "GET", "/v1/accounts/VA000002/balances", http.StatusOK, "balances-cash.json")
+	client := mock.NewClient()
+
+	accounts, err := client.GetAccounts(t.Context())
+	if err != nil {
+		t.Fatalf("GetAccounts() error = %v", err)
+	}
+	if len(accounts) != 2 {
+		t.Fatalf("GetAccounts() = %+v, want the two open accounts", accounts)
+	}
+
+	margin := accounts[0]
+	if margin.ID() != "VA000001" || margin.Nickname != "Individual" || margin.Type != "MARGIN" || margin.Kind != brokerage.AccountKindMargin {
+		t.Errorf("first account = %q %q %q %q, want VA000001 Individual MARGIN MARGIN", margin.ID(), margin.Nickname, margin.Type, margin.Kind)
+	}
+	requireDecimal(t, "margin CashBalance", margin.CashBalance, "6363.86")
+	requireDecimal(t, "margin SettledCash", margin.SettledCash, "6363.86")
+	requireDecimal(t, "margin BuyingPower", margin.BuyingPower, "12727.72")
+	requireDecimal(t, "margin MarketValue", margin.MarketValue, "11434.5")
+	requireDecimal(t, "margin TotalValue", margin.TotalValue, "17798.36")
+
+	roth := accounts[1]
+	if roth.ID() != "VA000002" || roth.Nickname != "Roth IRA" || roth.Type != "CASH" || roth.Kind != brokerage.AccountKindRothIRA {
+		t.Errorf("second account = %q %q %q %q, want VA000002 Roth IRA CASH ROTH_IRA", roth.ID(), roth.Nickname, roth.Type, roth.Kind)
+	}
+	requireDecimal(t, "roth CashBalance", roth.CashBalance, "1012.34")
+	requireDecimal(t, "roth SettledCash", roth.SettledCash, "812.34")
+	requireDecimal(t, "roth BuyingPower", roth.BuyingPower, "812.34")
+}
+
+func TestGetAccountsSingleAccount(t *testing.T) {
+	tests := []struct {
+		accountType    string
+		classification string
+		wantKind       brokerage.AccountKind
+		wantBP         string
+	}{
+		{accountType: "cash", classification: "individual", wantKind: brokerage.AccountKindCash, wantBP: "10000"},
+		{accountType: "margin", classification: "joint", wantKind: brokerage.AccountKindMargin, wantBP: "20000"},
+		{accountType: "pdt", classification: "individual", wantKind: brokerage.AccountKindMargin, wantBP: "20000"},
+		{accountType: "cash", classification: "traditional_ira", wantKind: brokerage.AccountKindTraditionalIRA, wantBP: "10000"},
+		{accountType: "margin", classification: "rollover_ira", wantKind: brokerage.AccountKindTraditionalIRA, wantBP: "20000"},
+		{accountType: "cash", classification: "roth_ira", wantKind: brokerage.AccountKindRothIRA, wantBP: "10000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accountType+" "+tt.classification, func(t *testing.T) {
+			mock := tradiertest.NewServer()
+			defer mock.Close()
+			mock.SetAccount(tt.accountType, tt.classification)
+			client := mock.NewClient()
+
+			accounts, err := client.GetAccounts(t.Context())
+			if err != nil {
+				t.Fatalf("GetAccounts() error = %v", err)
+			}
+			if len(accounts) != 1 {
+				t.Fatalf("GetAccounts() = %+v, want the profile's single account", accounts)
+			}
+			if got := accounts[0].Kind; got != tt.wantKind {
+				t.Errorf("Kind = %q, want %q", got, tt.wantKind)
+			}
+			requireDecimal(t, "BuyingPower", accounts[0].BuyingPower, tt.wantBP)
+		})
+	}
+}
+
+func TestGetPositionsFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []string // Symbols
+	}{
+		{fixture: "positions-one.json", want: []string{"VTI"}},
+		{fixture: "positions-many.json", want: []string{"VTI", "VXUS", "DELISTED"}},
+		{fixture: "positions-empty.json", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			mock := tradiertest.NewServer()
+			defer mock.Close()
+			serveFixture(t, mock, "GET", accountPath("/positions"), http.StatusOK, tt.fixture)
+			client := mock.NewClient()
+
+			positions, err := client.GetPositions(t.Context(), tradiertest.AccountNumber)
+			if err != nil {
+				t.Fatalf("GetPositions() error = %v", err)
+			}
+			if positions == nil {
+				t.Fatal("GetPositions() = nil, want a non-nil slice")
+			}
+			symbols := []string{}
+			for _, position := range positions {
+				symbols = append(symbols, position.Symbol)
+			}
+			if !slices.Equal(symbols, tt.want) {
+				t.Errorf("GetPositions() symbols = %q, want %q", symbols, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPositionsPricesHoldings(t *testing.T) {
+	mock := tradiertest.NewServer()
+	defer mock.Close()
+	serveFixture(t, mock, "GET", accountPath("/positions"), http.StatusOK, "positions-many.json")
+	client := mock.NewClient()
+
+	positions, err := client.GetPositions(t.Context(), tradiertest.AccountNumber)
+	if err != nil {
+		t.Fatalf("GetPositions() error = %v", err)
+	}
+	if len(positions) != 3 {
+		t.Fatalf("GetPositions() = %+v, want three positions", positions)
+	}
+
+	vti := positions[0]
+	if vti.AssetType != brokerage.AssetTypeETF || vti.Description != "VTI test security" {
+		t.Errorf("VTI asset type and description = %q, %q", vti.AssetType, vti.Description)
+	}
+	requireDecimal(t, "VTI LongQuantity", vti.LongQuantity, "10")
+	requireDecimal(t, "VTI AveragePrice", vti.AveragePrice, "207")
+	requireDecimal(t, "VTI CurrentPrice", vti.CurrentPrice, "250")
+	requireDecimal(t, "VTI MarketValue", vti.MarketValue, "2500")
+	requireDecimal(t, "VTI UnrealizedPL", vti.UnrealizedPL, "430")
+
+	// Short 20 VXUS sold at $66, now $60
+	vxus := positions[1]
+	requireDecimal(t, "VXUS Quantity", vxus.Quantity, "-20")
+	requireDecimal(t, "VXUS ShortQuantity", vxus.ShortQuantity, "20")
+	requireDecimal(t, "VXUS AveragePrice", vxus.AveragePrice, "66")
+	requireDecimal(t, "VXUS MarketValue", vxus.MarketValue, "-1200")
+	requireDecimal(t, "VXUS UnrealizedPL", vxus.UnrealizedPL, "120")
+	if vxus.UnrealizedPLPct < 9.09 || vxus.UnrealizedPLPct > 9.10 {
+		t.Errorf("VXUS UnrealizedPLPct = %v, want a gain of about 9.09%%", vxus.UnrealizedPLPct)
+	}
+
+	// Unquoted symbols keep their cost basis but no price
+	delisted := positions[2]
+	requireDecimal(t, "DELISTED AveragePrice", delisted.AveragePrice, "100")
+	if !delisted.CurrentPrice.IsZero() || !delisted.MarketValue.IsZero() {
+		t.Errorf("DELISTED price and value = %s, %s, want zero", delisted.CurrentPrice, delisted.MarketValue)
+	}
+}
+
+func TestGetOrderStatusFixture(t *testing.T) {
+	mock := tradiertest.NewServer()
+	defer mock.Close()
+	serveFixture(t, mock, "GET", accountPath("/orders/228175"), http.StatusOK, "order.json")
+	client := mock.NewClient()
+
+	order, err := client.GetOrderStatus(t.Context(), tradiertest.AccountNumber, "228175")
+	if err != nil {
+		t.Fatalf("GetOrderStatus() error = %v", err)
+	}
+	if order.ID != "228175" || order.Symbol != "VTI" || order.Action != brokerage.OrderActionBuy ||
+		order.Type != brokerage.OrderTypeLimit || order.Status != brokerage.OrderStatusFilled || order.RawStatus != "filled" {
+		t.Errorf("GetOrderStatus() = %+v, want filled limit buy of VTI", order)
+	}
+	if order.LimitPrice == nil || !order.LimitPrice.Equal(decimal.NewFromInt(22)) || order.StopPrice != nil {
+		t.Errorf("prices = %v, %v, want a limit of 22 and no stop", order.LimitPrice, order.StopPrice)
+	}
+	requireDecimal(t, "FilledQty", order.FilledQty, "50")
+	requireDecimal(t, "FilledPrice", order.FilledPrice, "21.97")
+	wantFilled := time.Date(2018, 6, 1, 12, 30, 2, 385_000_000, time.UTC)
+	if order.FilledAt == nil || !order.FilledAt.Equal(wantFilled) {
+		t.Errorf("FilledAt = %v, want %v", order.FilledAt, wantFilled)
+	}
+	if order.RawResponse == "" {
+		t.Error("RawResponse is empty")
+	}
+}
+
+func TestGetRecentOrdersFixture(t *testing.T) {
+	mock := tradiertest.NewServer()
+	defer mock.Close()
+	serveFixture(t, mock, "GET", accountPath("/orders"), http.StatusOK, "orders.json")
+	client := mock.NewClient()
+
+	orders, err := client.GetRecentOrders(t.Context(), tradiertest.AccountNumber, brokerage.OrdersQuery{})
+	if err != nil {
+		t.Fatalf("GetRecentOrders() error = %v", err)
+	}
+	var ids []string
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+	}
+	if want := []string{"1031", "229063", "228175"}; !slices.Equal(ids, want) {
+		t.Fatalf("GetRecentOrders() = %v, want %v, newest first", ids, want)
+	}
+
+	oco := orders[0]
+	if oco.Strategy != brokerage.OrderStrategyOCO || len(oco.Children) != 2 {
+		t.Fatalf("OCO order = %+v, want two legs", oco)
+	}
+	if leg := oco.Children[0]; leg.ID != "1032" || leg.LimitPrice == nil || !leg.LimitPrice.Equal(decimal.NewFromInt(260)) {
+		t.Errorf("first leg = %+v, want a limit at 260", leg)
+	}
+	if leg := oco.Children[1]; leg.ID != "1033" || leg.StopPrice == nil || !leg.StopPrice.Equal(decimal.NewFromInt(190)) {
+		t.Errorf("second leg = %+v, want a stop at 190", leg)
+	}
+
+	short := orders[1]
+	if short.Action != brokerage.OrderActionSell || short.Status != brokerage.OrderStatusCancelled || short.FilledAt != nil {
+		t.Errorf("short sale = %+v, want a cancelled sell", short)
+	}
+
+	tests := []struct {
+		name  string
+		query brokerage.OrdersQuery
+		want  []string
+	}{
+		{name: "by status", query: brokerage.OrdersQuery{Status: brokerage.OrderStatusFilled}, want: []string{"228175"}},
+		{
+			name:  "by date",
+			query: brokerage.OrdersQuery{From: time.Date(2018, 6, 10, 0, 0, 0, 0, time.UTC), To: time.Date(2018, 6, 15, 0, 0, 0, 0, time.UTC)},
+			want:  []string{"229063"},
+		},
+		{name: "at most two", query: brokerage.OrdersQuery{MaxResults: 2}, want: []string{"1031", "229063"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, err := client.GetRecentOrders(t.Context(), tradiertest.AccountNumber, tt.query)
+			if err != nil {
+				t.Fatalf("GetRecentOrders() error = %v", err)
+			}
+			var ids []string
+			for _, order := range orders {
+				ids = append(ids, order.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("GetRecentOrders() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRecentOrdersEmpty(t *testing.T) {
+	mock := tradiertest.NewServer()
+	defer mock.Close()
+	serveFixture(t, mock, "GET", accountPath("/orders"), http.StatusOK, "orders-empty.json")
+	client := mock.NewClient()
+
+	orders, err := client.GetRecentOrders(t.Context(), tradiertest.AccountNumber, brokerage.OrdersQuery{})
+	if err != nil {
+		t.Fatalf("GetRecentOrders() error = %v", err)
+	}
+	if orders == nil || len(orders) != 0 {
+		t.Errorf("GetRecentOrders() = %#v, want an empty, non-nil slice", orders)
+	}
+}
+
+func TestGetQuotesFixture(t *testing.T) {
+	mock := tradiertest.NewServer()
+	defer mock.Close()
+	serveFixture(t, mock, "POST", "/v1/markets/quotes", http.StatusOK, "quotes.json")
+	client := mock.NewClient()
+
+	quotes, err := client.GetQuotes(t.Context(), []string{"VTI", "NOPE", "aapl"})
+	var missing *brokerage.MissingQuotesError
+	if !errors.As(err, &missing) || !slices.Equal(missing.Symbols, []string{"NOPE"}) {
+		t.Fatalf("GetQuotes() error = %v, want NOPE missing", err)
+	}
+	if len(quotes) != 2 {
+		t.Fatalf("GetQuotes() = %+v, want VTI and aapl", quotes)
+	}
+
+	vti := quotes["VTI"]
+	requireDecimal(t, "VTI Last", vti.Last, "208.21")
+	requireDecimal(t, "VTI Bid", vti.Bid, "208.2")
+	requireDecimal(t, "VTI Ask", vti.Ask, "208.22")
+	requireDecimal(t, "VTI Close", vti.Close, "209.39")
+	if vti.Volume != 25288395 || !vti.Timestamp.Equal(time.UnixMilli(1557950460000)) {
+		t.Errorf("VTI volume and time = %d, %v", vti.Volume, vti.Timestamp)
+	}
+
+	// Keyed by the symbol asked for, timed by the bid before any trade
+	aapl, ok := quotes["aapl"]
+	if !ok || aapl.Symbol != "aapl" || !aapl.Timestamp.Equal(time.UnixMilli(1557950400000)) {
+		t.Errorf("aapl = %+v, want the AAPL quote at its bid time", aapl)
+	}
+
+	req, _ := mock.LastRequest("POST", "/v1/markets/quotes")
+	form, err := url.ParseQuery(string(req.Body))
+	if err != nil || form.Get("symbols") != "VTI,NOPE,aapl" {
+		t.Errorf("quotes form = %q, want the symbols in one request", req.Body)
+	}
+}
+
+func TestGetQuoteFixture(t *testing.T) {
+	mock := tradiertest.NewServer()
+	defer mock.Close()
+	serveFixture(t, mock, "GET", "/v1/markets/quotes", http.StatusOK, "quote-one.json")
+	client := mock.NewClient()
+
+	quote, err := client.GetQuote(t.Context(), "VFIAX")
+	if err != nil {
+		t.Fatalf("GetQuote() error = %v", err)
+	}
+	if quote.Description != "Vanguard 500 Index Admiral" || !quote.Timestamp.IsZero() {
+		t.Errorf("GetQuote() = %+v", quote)
+	}
+	// Funds without a trade today are priced at the previous close
+	requireDecimal(t, "Last", quote.Last, "451.23")
+
+	if _, err := client.GetQuote(t.Context(), "VTI"); err == nil || err.Error() != "no quote returned for symbol VTI" {
+		t.Errorf("GetQuote() of a symbol missing from the response error = %v", err)
+	}
+}
+
+func TestPlaceOrderSendsTradierForm(t *testing.T) {
+	tests := []struct {
+		name  string
+		order brokerage.OrderRequest
+		want  url.Values
+	}{
+		{
+			name: "market",
+			order: brokerage.OrderRequest{
+				Symbol:   "VTI",
+				Action:   brokerage.OrderActionBuy,
+				Type:     brokerage.OrderTypeMarket,
+				Quantity: decimal.NewFromInt(3),
+			},
+			want: url.Values{"class": {"equity"}, "symbol": {"VTI"}, "side": {"buy"}, "quantity": {"3"}, "type": {"market"}, "duration": {"day"}},
+		},
+		{
+			name: "good till cancel limit",
+			order: brokerage.OrderRequest{
+				Symbol:     "VXUS",
+				Action:     brokerage.OrderActionSell,
+				Type:       brokerage.OrderTypeLimit,
+				Quantity:   decimal.NewFromInt(12),
+				LimitPrice: brokerage.DecimalPtr(decimal.RequireFromString("61.25")),
+				Duration:   brokerage.OrderDurationGTC,
+			},
+			want: url.Values{"class": {"equity"}, "symbol": {"VXUS"}, "side": {"sell"}, "quantity": {"12"}, "type": {"limit"}, "price": {"61.25"}, "duration": {"gtc"}},
+		},
+		{
+			name: "stop",
+			order: brokerage.OrderRequest{
+				Symbol:    "VTI",
+				Action:    brokerage.OrderActionSell,
+				Type:      brokerage.OrderTypeStop,
+				Quantity:  decimal.NewFromInt(2),
+				StopPrice: brokerage.DecimalPtr(decimal.NewFromInt(230)),
+			},
+			want: url.Values{"class": {"equity"}, "symbol": {"VTI"}, "side": {"sell"}, "quantity": {"2"}, "type": {"stop"}, "stop": {"230"}, "duration": {"day"}},
+		},
+		{
+			name: "pre-market limit",
+			order: brokerage.OrderRequest{
+				Symbol:     "VTI",
+				Action:     brokerage.OrderActionBuy,
+				Type:       brokerage.OrderTypeLimit,
+				Quantity:   decimal.NewFromInt(1),
+				LimitPrice: brokerage.DecimalPtr(decimal.NewFromInt(249)),
+				Session:    brokerage.OrderSessionAM,
+			},
+			want: url.Values{"class": {"equity"}, "symbol": {"VTI"}, "side": {"buy"}, "quantity": {"1"}, "type": {"limit"}, "price": {"249"}, "duration": {"pre"}},
+		},
+		{
+			name: "amount in whole shares",
+			order: brokerage.OrderRequest{
+				Symbol: "VXUS",
+				Action: brokerage.OrderActionBuy,
+				Type:   brokerage.OrderTypeMarket,
+				Amount: brokerage.DecimalPtr(decimal.NewFromInt(200)),
+			},
+			want: url.Values{"class": {"equity"}, "symbol": {"VXUS"}, "side": {"buy"}, "quantity": {"3"}, "type": {"market"}, "duration": {"day"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := tradiertest.NewServer()
+			defer mock.Close()
+			client := mock.NewClient()
+
+			order, err := client.PlaceOrder(t.Context(), tradiertest.AccountNumber, tt.order)
+			if err != nil {
+				t.Fatalf("PlaceOrder() error = %v", err)
+			}
+			if order.ID != "1001" || order.Status != brokerage.OrderStatusPending {
+				t.Errorf("order ID and status = %q, %s, want 1001, PENDING", order.ID, order.Status)
+			}
+
+			req, ok := mock.LastRequest("POST", accountPath("/orders"))
+			if !ok {
+				t.Fatal("no order reached the mock")
+			}
+			if got := req.Header.Get("Content-Type"); got != "application/x-www-form-urlencoded" {
+				t.Errorf("Content-Type = %q, want a form", got)
+			}
+			form, err := url.ParseQuery(string(req.Body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if form.Encode() != tt.want.Encode() {
+				t.Errorf("form = %s, want %s", form.Encode(), tt.want.Encode())
+			}
+		})
+	}
+}
+
+func TestPlaceOrderRejectsUnsupportedOrders(t *testing.T) {
+	mock := tradiertest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+
+	_, err := client.PlaceOrder(t.Context(), tradiertest.AccountNumber, brokerage.OrderRequest{
+		Symbol:   "VTI",
+		Action:   brokerage.OrderActionBuy,
+		Type:     brokerage.OrderTypeMarket,
+		Quantity: decimal.NewFromInt(1),
+		Session:  brokerage.OrderSessionSeamless,
+	})
+	if err == nil || !strings.Contains(err.Error(), `unsupported order session "SEAMLESS"`) {
+		t.Errorf("PlaceOrder() in the seamless session error = %v", err)
+	}
+	if _, ok := mock.LastRequest("POST", accountPath("/orders")); ok {
+		t.Error("an unsupported order reached the mock")
+	}
+}
+
+func TestPlaceOrderErrorFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []string
+	}{
+		{fixture: "errors.json", want: []string{"Backoffice rejected override of the order.", "Not enough buying power for this order."}},
+		{fixture: "error-one.json", want: []string{"Invalid Parameter: symbol"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			mock := tradiertest.NewServer()
+			defer mock.Close()
+			serveFixture(t, mock, "POST", accountPath("/orders"), http.StatusBadRequest, tt.fixture)
+			client := mock.NewClient()
+
+			_, err := client.PlaceOrder(t.Context(), tradiertest.AccountNumber, brokerage.OrderRequest{
+				Symbol:   "VTI",
+				Action:   brokerage.OrderActionBuy,
+				Type:     brokerage.OrderTypeMarket,
+				Quantity: decimal.NewFromInt(1),
+			})
+			var apiErr *tradier.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || !slices.Equal(apiErr.Messages, tt.want) {
+				t.Errorf("PlaceOrder() error = %v, want a 400 APIError with %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviewOrder(t *testing.T) {
+	order := brokerage.OrderRequest{
+		Symbol:     "VTI",
+		Action:     brokerage.OrderActionBuy,
+		Type:       brokerage.OrderTypeLimit,
+		Quantity:   decimal.NewFromInt(5),
+		LimitPrice: brokerage.DecimalPtr(decimal.NewFromInt(210)),
+	}
+
+	t.Run("accepted", func(t *testing.T) {
+		mock := tradiertest.NewServer()
+		defer mock.Close()
+		serveFixture(t, mock, "POST", accountPath("/orders"), http.StatusOK, "preview.json")
+		client := mock.NewClient()
+
+		preview, err := client.PreviewOrder(t.Context(), tradiertest.AccountNumber, order)
+		if err != nil {
+			t.Fatalf("PreviewOrder() error = %v", err)
+		}
+		requireDecimal(t, "EstimatedTotal", preview.EstimatedTotal, "1050")
+		requireDecimal(t, "Fees", preview.Fees, "0.35")
+		requireDecimal(t, "ProjectedBuyingPower", preview.ProjectedBuyingPower, "8950")
+		requireDecimal(t, "ProjectedAvailableFunds", preview.ProjectedAvailableFunds, "8950")
+
+		req, _ := mock.LastRequest("POST", accountPath("/orders"))
+		if form, _ := url.ParseQuery(string(req.Body)); form.Get("preview") != "true" {
+			t.Errorf("preview form = %q, want preview=true", req.Body)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		mock := tradiertest.NewServer()
+		defer mock.Close()
+		serveFixture(t, mock, "POST", accountPath("/orders"), http.StatusBadRequest, "errors.json")
+		client := mock.NewClient()
+
+		preview, err := client.PreviewOrder(t.Context(), tradiertest.AccountNumber, order)
+		var rejected *brokerage.OrderRejectedError
+		if !errors.As(err, &rejected) || rejected.Symbol != "VTI" {
+			t.Fatalf("PreviewOrder() error = %v, want an OrderRejectedError", err)
+		}
+		if preview == nil || len(preview.Rejections) != 2 || preview.Rejections[1] != "Not enough buying power for this order." {
+			t.Errorf("preview = %+v, want Tradier's two reasons", preview)
+		}
+	})
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	mock := tradiertest.NewServer()
+	defer mock.Close()
+	client := mock.NewClient()
+	id := placeLimitOrder(t, client, 4, "249.50")
+
+	order, err := client.GetOrderStatus(t.Context(), tradiertest.AccountNumber, id)
+	if err != nil {
+		t.Fatalf("GetOrderStatus() error = %v", err)
+	}
+	if order.Status != brokerage.OrderStatusWorking || !order.LimitPrice.Equal(decimal.RequireFromString("249.5")) {
+		t.Errorf("open order = %+v, want WORKING at 249.5", order)
+	}
+
+	// Tradier modifies the order in place and cannot change its quantity
+	replacement := brokerage.OrderRequest{
+		Symbol:     "VTI",
+		Action:     brokerage.OrderActionBuy,
+		Type:       brokerage.OrderTypeLimit,
+		Quantity:   decimal.NewFromInt(4),
+		LimitPrice: brokerage.DecimalPtr(decimal.NewFromInt(251)),
+		Duration:   brokerage.OrderDurationGTC,
+	}
+	replaced, err := client.ReplaceOrder(t.Context(), tradiertest.AccountNumber, id, replacement)
+	if err != nil {
+		t.Fatalf("ReplaceOrder() error = %v", err)
+	}
+	if replaced.ID != id {
+		t.Errorf("replaced order ID = %q, want %q", replaced.ID, id)
+	}
+	req, _ := mock.LastRequest("PUT", accountPath("/orders/"+id))
+	if form, _ := url.ParseQuery(string(req.Body)); form.Encode() != "duration=gtc&price=251&type=limit" {
+		t.Errorf("modify form = %s, want only the type, duration and price", form.Encode())
+	}
+
+	replacement.Quantity = decimal.NewFromInt(5)
+	if _, err := client.ReplaceOrder(t.Context(), tradiertest.AccountNumber, id, replacement); err == nil ||
+		!strings.Contains(err.Error(), "only changes the type, duration and prices") {
+		t.Errorf("ReplaceOrder() changing the quantity error = %v", err)
+	}
+
+	if err := client.CancelPendingOrder(t.Context(), tradiertest.AccountNumber, id); err != nil {
+		t.Fatalf("CancelPendingOrder() error = %v", err)
+	}
+	if err := client.CancelPendingOrder(t.Context(), tradiertest.AccountNumber, id); !errors.Is(err, brokerage.ErrOrderNotOpen) {
+		t.Errorf("second CancelPendingOrder() error = %v, want ErrOrderNotOpen", err)
+	}
+}
+
+func TestUnauthenticated(t *testing.T) {
+	mock := tradiertest.NewServer()
+	defer mock.Close()
+
+	config := mock.Config()
+	config.AccessToken = ""
+	client := tradier.NewClient(config)
+	if client.IsAuthenticated() {
+		t.Error("IsAuthenticated() = true without a token")
+	}
+	if _, err := client.GetAccounts(t.Context()); !errors.Is(err, brokerage.ErrNotAuthenticated) {
+		t.Errorf("GetAccounts() without a token error = %v, want ErrNotAuthenticated", err)
+	}
+
+	config.AccessToken = "wrong"
+	client = tradier.NewClient(config)
+	_, err := client.GetAccounts(t.Context())
+	var apiErr *tradier.APIError
+	if !errors.Is(err, brokerage.ErrNotAuthenticated) || !errors.As(err, &apiErr) || !strings.Contains(err.Error(), "Invalid Access Token") {
+		t.Errorf("GetAccounts() with a rejected token error = %v, want ErrNotAuthenticated carrying Tradier's message", err)
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  tradier.Config
+		wantErr string
+	}{
+		{
+			name:    "plain http",
+			config:  tradier.Config{AccessToken: "t", BaseURL: "http://localhost:1234/v1"},
+			wantErr: `invalid base_url "http://localhost:1234/v1": scheme must be https unless allow_insecure is set`,
+		},
+		{
+			name:    "relative",
+			config:  tradier.Config{AccessToken: "t", BaseURL: "sandbox.tradier.com/v1"},
+			wantErr: `invalid base_url "sandbox.tradier.com/v1": must be an absolute URL`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := tradier.NewClient(tt.config)
+			if _, err := client.GetAccounts(t.Context()); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("GetAccounts() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package tradier
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// ErrNotAuthenticated is returned when the client has no access token or
+// Tradier rejects it. It is brokerage.ErrNotAuthenticated.
+var ErrNotAuthenticated = brokerage.ErrNotAuthenticated
+
+// APIError is returned when Tradier answers a request with an unexpected
+// status code. The messages of Tradier's {"errors":{"error":[...]}} payload
+// are parsed into Messages.
+type APIError struct {
+	Op         string // The operation that failed, e.g. "get balances"
+	StatusCode int
+	Messages   []string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	detail := strings.Join(e.Messages, "; ")
+	if detail == "" {
+		detail = strings.TrimSpace(e.Body)
+	}
+	return fmt.Sprintf("%s failed with status %d: %s", e.Op, e.StatusCode, detail)
+}
+
+// Is makes an APIError for a rejected access token match ErrNotAuthenticated
+func (e *APIError) Is(target error) bool {
+	return target == ErrNotAuthenticated && e.StatusCode == http.StatusUnauthorized
+}
+
+// newAPIError builds an APIError from a failed response and its body
+func newAPIError(op string, resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		Op:         op,
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+	}
+
+	var payload struct {
+		Errors object[struct {
+			Error list[string] `json:"error"`
+		}] `json:"errors"`
+	}
+	if json.Unmarshal(body, &payload) == nil {
+		apiErr.Messages = payload.Errors.Value.Error
+	}
+	return apiErr
+}
+
+// IsNotFound reports whether err is an APIError for a 404 response
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,56 @@
+package tradier
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Tradier's JSON is converted from XML: a list with one element is sent as
+// that element rather than an array, and an empty container is sent as the
+// string "null". object and list decode both quirks.
+
+// object decodes a Tradier object, leaving Value zero when Tradier sends
+// "null" in its place
+type object[T any] struct {
+	Value T
+}
+
+func (o *object[T]) UnmarshalJSON(data []byte) error {
+	if isNull(data) {
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+// list decodes a Tradier list, which is a single element, an array of them
+// or "null" when empty
+type list[T any] []T
+
+func (l *list[T]) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case isNull(data):
+		*l = nil
+		return nil
+	case data[0] == '[':
+		var items []T
+		if err := json.Unmarshal(data, &items); err != nil {
+			return err
+		}
+		*l = items
+		return nil
+	default:
+		var item T
+		if err := json.Unmarshal(data, &item); err != nil {
+			return err
+		}
+		*l = list[T]{item}
+		return nil
+	}
+}
+
+// isNull reports whether data is an empty Tradier value
+func isNull(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) == 0 || string(data) == "null" || string(data) == `"null"`
+}
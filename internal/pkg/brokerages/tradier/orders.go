@@ -0,0 +1,484 @@
+package tradier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+// maxOrdersPerRequest is the most orders requested from the orders endpoint
+const maxOrdersPerRequest = 500
+
+// tradierOrder mirrors an order as returned by the orders endpoints. The
+// legs of OCO and OTO orders are listed under leg.
+type tradierOrder struct {
+	ID              int64              `json:"id"`
+	Class           string             `json:"class"`
+	Symbol          string             `json:"symbol"`
+	Side            string             `json:"side"`
+	Type            string             `json:"type"`
+	Status          string             `json:"status"`
+	Quantity        decimal.Decimal    `json:"quantity"`
+	ExecQuantity    decimal.Decimal    `json:"exec_quantity"`
+	AvgFillPrice    decimal.Decimal    `json:"avg_fill_price"`
+	Price           decimal.Decimal    `json:"price"`
+	StopPrice       decimal.Decimal    `json:"stop_price"`
+	CreateDate      time.Time          `json:"create_date"`
+	TransactionDate time.Time          `json:"transaction_date"`
+	Legs            list[tradierOrder] `json:"leg"`
+}
+
+// buildOrderForm validates an order request and converts it to the form
+// fields of a Tradier equity order. Duration and session default to DAY and
+// NORMAL. The AM and PM sessions are Tradier's pre and post durations, which
+// only take limit orders; SEAMLESS is not supported.
+func buildOrderForm(order brokerage.OrderRequest) (url.Values, error) {
+	if order.Amount != nil {
+		return nil, fmt.Errorf("order for %s must be sized in shares", order.Symbol)
+	}
+	if err := order.Validate(); err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("class", "equity")
+	form.Set("symbol", order.Symbol)
+	form.Set("quantity", order.Quantity.String())
+
+	switch order.Action {
+	case brokerage.OrderActionBuy, brokerage.OrderActionSell:
+		form.Set("side", strings.ToLower(string(order.Action)))
+	default:
+		return nil, fmt.Errorf("unsupported order action %q", order.Action)
+	}
+
+	switch order.Type {
+	case brokerage.OrderTypeMarket:
+		form.Set("type", "market")
+	case brokerage.OrderTypeLimit:
+		if order.LimitPrice == nil || !order.LimitPrice.IsPositive() {
+			return nil, errors.New("limit orders require a positive limit price")
+		}
+		form.Set("type", "limit")
+		form.Set("price", order.LimitPrice.String())
+	case brokerage.OrderTypeStop:
+		if order.StopPrice == nil || !order.StopPrice.IsPositive() {
+			return nil, errors.New("stop orders require a positive stop price")
+		}
+		form.Set("type", "stop")
+		form.Set("stop", order.StopPrice.String())
+	default:
+		return nil, fmt.Errorf("unsupported order type %q", order.Type)
+	}
+
+	duration, err := convertDuration(order)
+	if err != nil {
+		return nil, err
+	}
+	form.Set("duration", duration)
+
+	return form, nil
+}
+
+// convertDuration returns the Tradier duration for an order's duration and
+// session
+func convertDuration(order brokerage.OrderRequest) (string, error) {
+	switch order.Session {
+	case "", brokerage.OrderSessionNormal:
+		switch order.Duration {
+		case "", brokerage.OrderDurationDay:
+			return "day", nil
+		case brokerage.OrderDurationGTC:
+			return "gtc", nil
+		default:
+			return "", fmt.Errorf("unsupported order duration %q", order.Duration)
+		}
+	case brokerage.OrderSessionAM, brokerage.OrderSessionPM:
+		if order.Type != brokerage.OrderTypeLimit || (order.Duration != "" && order.Duration != brokerage.OrderDurationDay) {
+			return "", fmt.Errorf("orders in the %s session must be DAY limit orders", order.Session)
+		}
+		if order.Session == brokerage.OrderSessionAM {
+			return "pre", nil
+		}
+		return "post", nil
+	default:
+		return "", fmt.Errorf("unsupported order session %q", order.Session)
+	}
+}
+
+// PlaceOrder submits a new equity order. Orders sized by Amount are
+// converted to whole shares at the current quote.
+// Documentation: https://documentation.tradier.com/brokerage-api/trading/place-equity-order
+// Endpoint: POST /v1/accounts/{account_id}/orders
+func (c *Client) PlaceOrder(ctx context.Context, accountID string, order brokerage.OrderRequest) (*brokerage.Order, error) {
+	order, err := brokerage.ResolveAmount(ctx, c, order, false)
+	if err != nil {
+		return nil, err
+	}
+
+	form, err := buildOrderForm(order)
+	if err != nil {
+		return nil, err
+	}
+	if c.dryRun != nil {
+		return c.dryRun.Place(ctx, accountID, order)
+	}
+
+	body, err := c.request(ctx, "place order", "POST", fmt.Sprintf(ordersPath, url.PathEscape(accountID)), form)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := parseOrderID("place order", body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &brokerage.Order{
+		ID:          id,
+		Symbol:      order.Symbol,
+		Action:      order.Action,
+		Type:        order.Type,
+		Quantity:    order.Quantity,
+		LimitPrice:  order.LimitPrice,
+		StopPrice:   order.StopPrice,
+		Status:      brokerage.OrderStatusPending,
+		SubmittedAt: time.Now(),
+		RawResponse: string(body),
+	}, nil
+}
+
+// parseOrderID returns the ID of the order Tradier accepted from a place or
+// modify order response, {"order":{"id":...,"status":"ok"}}
+func parseOrderID(op string, body []byte) (string, error) {
+	var response struct {
+		Order struct {
+			ID     int64  `json:"id"`
+			Status string `json:"status"`
+		} `json:"order"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("failed to parse %s response: %w", op, err)
+	}
+	if response.Order.Status != "ok" {
+		return "", fmt.Errorf("%s returned status %q", op, response.Order.Status)
+	}
+	return strconv.FormatInt(response.Order.ID, 10), nil
+}
+
+// PreviewOrder asks Tradier to validate an order and estimate its cost
+// without placing it. Tradier does not project buying power, so it is
+// estimated from the account's balances. When Tradier would reject the
+// order, the preview is returned together with a
+// *brokerage.OrderRejectedError listing the reasons.
+// Documentation: https://documentation.tradier.com/brokerage-api/trading/preview-order
+// Endpoint: POST /v1/accounts/{account_id}/orders with preview=true
+func (c *Client) PreviewOrder(ctx context.Context, accountID string, order brokerage.OrderRequest) (*brokerage.OrderPreview, error) {
+	order, err := brokerage.ResolveAmount(ctx, c, order, false)
+	if err != nil {
+		return nil, err
+	}
+
+	form, err := buildOrderForm(order)
+	if err != nil {
+		return nil, err
+	}
+	form.Set("preview", "true")
+
+	balances, err := c.getBalances(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.request(ctx, "preview order", "POST", fmt.Sprintf(ordersPath, url.PathEscape(accountID)), form)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest && len(apiErr.Messages) > 0 {
+		preview := &brokerage.OrderPreview{
+			Rejections:  apiErr.Messages,
+			RawResponse: apiErr.Body,
+		}
+		return preview, &brokerage.OrderRejectedError{Symbol: order.Symbol, Messages: preview.Rejections}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Order struct {
+			Result     bool            `json:"result"`
+			OrderCost  decimal.Decimal `json:"order_cost"`
+			Commission decimal.Decimal `json:"commission"`
+			Fees       decimal.Decimal `json:"fees"`
+			Cost       decimal.Decimal `json:"cost"`
+		} `json:"order"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse preview order response: %w", err)
+	}
+
+	result := response.Order
+	preview := &brokerage.OrderPreview{
+		EstimatedTotal:          result.OrderCost,
+		Commission:              result.Commission,
+		Fees:                    result.Fees,
+		ProjectedBuyingPower:    balances.buyingPower(),
+		ProjectedAvailableFunds: balances.settledCash(),
+		RawResponse:             string(body),
+	}
+	if order.Action == brokerage.OrderActionBuy {
+		preview.ProjectedBuyingPower = preview.ProjectedBuyingPower.Sub(result.Cost)
+		preview.ProjectedAvailableFunds = preview.ProjectedAvailableFunds.Sub(result.Cost)
+	}
+
+	if !result.Result {
+		preview.Rejections = append(preview.Rejections, "order failed tradier's validation")
+		return preview, &brokerage.OrderRejectedError{Symbol: order.Symbol, Messages: preview.Rejections}
+	}
+
+	return preview, nil
+}
+
+// ReplaceOrder modifies a working order in place, so the returned order
+// keeps orderID. Tradier can change an order's type, duration and prices
+// but not its symbol, side or quantity; replacements changing those are
+// refused.
+// Documentation: https://documentation.tradier.com/brokerage-api/trading/change-order
+// Endpoint: PUT /v1/accounts/{account_id}/orders/{order_id}
+func (c *Client) ReplaceOrder(ctx context.Context, accountID string, orderID string, newOrder brokerage.OrderRequest) (*brokerage.Order, error) {
+	newOrder, err := brokerage.ResolveAmount(ctx, c, newOrder, false)
+	if err != nil {
+		return nil, err
+	}
+
+	form, err := buildOrderForm(newOrder)
+	if err != nil {
+		return nil, err
+	}
+	if c.dryRun != nil {
+		return c.dryRun.Replace(ctx, accountID, orderID, newOrder)
+	}
+
+	existing, err := c.GetOrderStatus(ctx, accountID, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check order %s before replacing: %w", orderID, err)
+	}
+	if !existing.Status.IsOpen() {
+		return nil, fmt.Errorf("cannot replace order %s with status %s", orderID, existing.Status)
+	}
+	if !strings.EqualFold(existing.Symbol, newOrder.Symbol) || existing.Action != newOrder.Action || !existing.Quantity.Equal(newOrder.Quantity) {
+		return nil, fmt.Errorf("cannot replace order %s: tradier only changes the type, duration and prices of an order", orderID)
+	}
+
+	// The modify endpoint takes only the fields that may change
+	for _, field := range []string{"class", "symbol", "side", "quantity"} {
+		form.Del(field)
+	}
+
+	path := fmt.Sprintf(ordersPath, url.PathEscape(accountID)) + "/" + url.PathEscape(orderID)
+	body, err := c.request(ctx, "replace order", "PUT", path, form)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := parseOrderID("replace order", body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &brokerage.Order{
+		ID:          id,
+		Symbol:      newOrder.Symbol,
+		Action:      newOrder.Action,
+		Type:        newOrder.Type,
+		Quantity:    newOrder.Quantity,
+		LimitPrice:  newOrder.LimitPrice,
+		StopPrice:   newOrder.StopPrice,
+		Status:      brokerage.OrderStatusPending,
+		FilledQty:   existing.FilledQty,
+		FilledPrice: existing.FilledPrice,
+		SubmittedAt: existing.SubmittedAt,
+		RawResponse: string(body),
+	}, nil
+}
+
+// GetOrderStatus retrieves an order by its ID
+// Documentation: https://documentation.tradier.com/brokerage-api/accounts/get-account-order
+// Endpoint: GET /v1/accounts/{account_id}/orders/{order_id}
+func (c *Client) GetOrderStatus(ctx context.Context, accountID string, orderID string) (*brokerage.Order, error) {
+	if c.dryRun != nil {
+		if order, ok, err := c.dryRun.Status(ctx, orderID); ok {
+			return order, err
+		}
+	}
+
+	path := fmt.Sprintf(ordersPath, url.PathEscape(accountID)) + "/" + url.PathEscape(orderID)
+	body, err := c.request(ctx, "get order", "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Order object[tradierOrder] `json:"order"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse order response: %w", err)
+	}
+	if response.Order.Value.ID == 0 {
+		return nil, fmt.Errorf("no order %s returned", orderID)
+	}
+
+	order := convertOrder(response.Order.Value)
+	order.RawResponse = string(body)
+	return &order, nil
+}
+
+// CancelPendingOrder cancels an open order
+// Documentation: https://documentation.tradier.com/brokerage-api/trading/cancel-order
+// Endpoint: DELETE /v1/accounts/{account_id}/orders/{order_id}
+func (c *Client) CancelPendingOrder(ctx context.Context, accountID string, orderID string) error {
+	if c.dryRun != nil {
+		return c.dryRun.Cancel(ctx, accountID, orderID)
+	}
+
+	path := fmt.Sprintf(ordersPath, url.PathEscape(accountID)) + "/" + url.PathEscape(orderID)
+	_, err := c.request(ctx, "cancel order", "DELETE", path, nil)
+	if err != nil {
+		return brokerage.CheckOrderClosed(ctx, c, accountID, orderID, err)
+	}
+	return nil
+}
+
+// GetRecentOrders retrieves the account's orders matching query, newest
+// first. Tradier does not filter orders, so up to 500 are fetched and
+// filtered by date and status here.
+// Documentation: https://documentation.tradier.com/brokerage-api/accounts/get-account-orders
+// Endpoint: GET /v1/accounts/{account_id}/orders
+func (c *Client) GetRecentOrders(ctx context.Context, accountID string, query brokerage.OrdersQuery) ([]brokerage.Order, error) {
+	params := url.Values{}
+	params.Set("page", "1")
+	params.Set("limit", strconv.Itoa(maxOrdersPerRequest))
+	params.Set("includeTags", "true")
+
+	path := fmt.Sprintf(ordersPath, url.PathEscape(accountID)) + "?" + params.Encode()
+	body, err := c.request(ctx, "get orders", "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Orders object[struct {
+			Order list[tradierOrder] `json:"order"`
+		}] `json:"orders"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse orders response: %w", err)
+	}
+
+	orders := make([]brokerage.Order, 0, len(response.Orders.Value.Order))
+	for _, to := range response.Orders.Value.Order {
+		order := convertOrder(to)
+		switch {
+		case query.Status != "" && order.Status != query.Status:
+			continue
+		case !query.From.IsZero() && order.SubmittedAt.Before(query.From):
+			continue
+		case !query.To.IsZero() && order.SubmittedAt.After(query.To):
+			continue
+		}
+		orders = append(orders, order)
+	}
+
+	slices.SortStableFunc(orders, func(a, b brokerage.Order) int {
+		return b.SubmittedAt.Compare(a.SubmittedAt)
+	})
+	if query.MaxResults > 0 && len(orders) > query.MaxResults {
+		orders = orders[:query.MaxResults]
+	}
+
+	return orders, nil
+}
+
+// convertOrder converts a Tradier order to our standard order. The legs of
+// OCO and OTO orders are converted into Children.
+func convertOrder(to tradierOrder) brokerage.Order {
+	order := brokerage.Order{
+		ID:          strconv.FormatInt(to.ID, 10),
+		Symbol:      to.Symbol,
+		Action:      convertSide(to.Side),
+		Type:        brokerage.OrderType(strings.ToUpper(to.Type)),
+		Quantity:    to.Quantity,
+		Status:      convertOrderStatus(to.Status),
+		RawStatus:   to.Status,
+		FilledQty:   to.ExecQuantity,
+		FilledPrice: to.AvgFillPrice,
+		SubmittedAt: to.CreateDate,
+	}
+
+	switch order.Type {
+	case brokerage.OrderTypeLimit:
+		order.LimitPrice = brokerage.DecimalPtr(to.Price)
+	case brokerage.OrderTypeStop:
+		order.StopPrice = brokerage.DecimalPtr(to.StopPrice)
+	}
+	if order.Status == brokerage.OrderStatusFilled && !to.TransactionDate.IsZero() {
+		filledAt := to.TransactionDate
+		order.FilledAt = &filledAt
+	}
+
+	switch to.Class {
+	case "oco":
+		order.Strategy = brokerage.OrderStrategyOCO
+	case "oto", "otoco":
+		order.Strategy = brokerage.OrderStrategyTrigger
+	}
+	for _, leg := range to.Legs {
+		order.Children = append(order.Children, convertOrder(leg))
+	}
+
+	return order
+}
+
+// convertSide converts a Tradier order side to our order action. Covering
+// a short is a buy and selling short a sell.
+func convertSide(side string) brokerage.OrderAction {
+	switch side {
+	case "buy", "buy_to_cover":
+		return brokerage.OrderActionBuy
+	case "sell", "sell_short":
+		return brokerage.OrderActionSell
+	default:
+		return brokerage.OrderAction(strings.ToUpper(side))
+	}
+}
+
+// convertOrderStatus converts a Tradier order status to our standard status
+func convertOrderStatus(status string) brokerage.OrderStatus {
+	switch status {
+	case "pending", "calculated", "accepted_for_bidding", "held":
+		return brokerage.OrderStatusPending
+	case "open":
+		return brokerage.OrderStatusWorking
+	case "partially_filled":
+		return brokerage.OrderStatusPartiallyFilled
+	case "filled":
+		return brokerage.OrderStatusFilled
+	case "canceled":
+		return brokerage.OrderStatusCancelled
+	case "rejected", "error":
+		return brokerage.OrderStatusRejected
+	case "expired":
+		return brokerage.OrderStatusExpired
+	default:
+		return brokerage.OrderStatusUnknown
+	}
+}
@@ -0,0 +1,161 @@
+package tradier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	brokerage "github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+// tradierQuote mirrors an equity quote. Times are Unix milliseconds.
+type tradierQuote struct {
+	Symbol      string          `json:"symbol"`
+	Description string          `json:"description"`
+	Type        string          `json:"type"` // stock, etf, mutual_fund, ...
+	Last        decimal.Decimal `json:"last"`
+	Bid         decimal.Decimal `json:"bid"`
+	Ask         decimal.Decimal `json:"ask"`
+	PrevClose   decimal.Decimal `json:"prevclose"`
+	Volume      int64           `json:"volume"`
+	TradeDate   int64           `json:"trade_date"`
+	BidDate     int64           `json:"bid_date"`
+}
+
+// assetType maps the quote's type onto the brokerage.AssetType constants,
+// upper-casing the types it has no constant for
+func (tq tradierQuote) assetType() string {
+	switch tq.Type {
+	case "stock":
+		return brokerage.AssetTypeEquity
+	case "etf":
+		return brokerage.AssetTypeETF
+	case "mutual_fund":
+		return brokerage.AssetTypeMutualFund
+	default:
+		return strings.ToUpper(tq.Type)
+	}
+}
+
+// GetQuote retrieves a quote for a symbol
+// Documentation: https://documentation.tradier.com/brokerage-api/markets/get-quotes
+// Endpoint: GET /v1/markets/quotes
+func (c *Client) GetQuote(ctx context.Context, symbol string) (*brokerage.Quote, error) {
+	params := url.Values{}
+	params.Set("symbols", symbol)
+
+	body, err := c.request(ctx, "get quote", "GET", quotesPath+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes, err := parseQuotes(body)
+	if err != nil {
+		return nil, err
+	}
+	for _, tq := range quotes {
+		if strings.EqualFold(tq.Symbol, symbol) {
+			quote := convertQuote(symbol, tq)
+			return &quote, nil
+		}
+	}
+	return nil, fmt.Errorf("no quote returned for symbol %s", symbol)
+}
+
+// BatchesQuotes reports that GetQuotes fetches many symbols per request,
+// implementing brokerage.QuoteBatcher
+func (c *Client) BatchesQuotes() bool {
+	return true
+}
+
+// GetQuotes retrieves quotes for multiple symbols in a single request, sent
+// as a POST so that long symbol lists fit. Symbols Tradier does not match
+// are reported through a *brokerage.MissingQuotesError together with the
+// quotes that were found.
+// Documentation: https://documentation.tradier.com/brokerage-api/markets/post-quotes
+// Endpoint: POST /v1/markets/quotes
+func (c *Client) GetQuotes(ctx context.Context, symbols []string) (map[string]brokerage.Quote, error) {
+	quotes := make(map[string]brokerage.Quote, len(symbols))
+	if len(symbols) == 0 {
+		return quotes, nil
+	}
+
+	form := url.Values{}
+	form.Set("symbols", strings.Join(symbols, ","))
+
+	body, err := c.request(ctx, "get quotes", "POST", quotesPath, form)
+	if err != nil {
+		return nil, err
+	}
+
+	tradierQuotes, err := parseQuotes(body)
+	if err != nil {
+		return nil, err
+	}
+	bySymbol := make(map[string]tradierQuote, len(tradierQuotes))
+	for _, tq := range tradierQuotes {
+		bySymbol[strings.ToUpper(tq.Symbol)] = tq
+	}
+
+	var missing []string
+	for _, symbol := range symbols {
+		tq, ok := bySymbol[strings.ToUpper(symbol)]
+		if !ok {
+			missing = append(missing, symbol)
+			continue
+		}
+		quotes[symbol] = convertQuote(symbol, tq)
+	}
+
+	if len(missing) > 0 {
+		return quotes, &brokerage.MissingQuotesError{Symbols: missing}
+	}
+
+	return quotes, nil
+}
+
+// parseQuotes decodes the quotes of a quotes response. Symbols Tradier
+// could not match are listed separately under unmatched_symbols, and are
+// simply absent from the result.
+func parseQuotes(body []byte) ([]tradierQuote, error) {
+	var response struct {
+		Quotes object[struct {
+			Quote list[tradierQuote] `json:"quote"`
+		}] `json:"quotes"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse quotes response: %w", err)
+	}
+	return response.Quotes.Value.Quote, nil
+}
+
+// convertQuote converts a Tradier quote to our standard quote. Close is the
+// previous day's close, which also stands in for Last before the first
+// trade of a new symbol.
+func convertQuote(symbol string, tq tradierQuote) brokerage.Quote {
+	quote := brokerage.Quote{
+		Symbol:      symbol,
+		Description: tq.Description,
+		Bid:         tq.Bid,
+		Ask:         tq.Ask,
+		Last:        tq.Last,
+		Close:       tq.PrevClose,
+		Volume:      tq.Volume,
+		Raw:         tq,
+	}
+	if quote.Last.IsZero() {
+		quote.Last = tq.PrevClose
+	}
+
+	switch {
+	case tq.TradeDate > 0:
+		quote.Timestamp = time.UnixMilli(tq.TradeDate)
+	case tq.BidDate > 0:
+		quote.Timestamp = time.UnixMilli(tq.BidDate)
+	}
+	return quote
+}
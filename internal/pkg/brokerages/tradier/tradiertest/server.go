@@ -0,0 +1,607 @@
+// Package tradiertest provides an in-memory mock of the Tradier brokerage API
+// for exercising the tradier client without a token or network access. Its
+// responses have the shapes of Tradier's JSON, including its quirks: a list
+// with one element is sent as that element, and an empty list as "null".
+package tradiertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/tradier"
+)
+
+const (
+	// AccountNumber identifies the mock's single account
+	AccountNumber = "VA00000001"
+
+	// AccessToken is the only token the mock accepts
+	AccessToken = "test-access-token"
+)
+
+// Request is a request received by the mock
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   []byte
+}
+
+// Position is a holding of the mock account
+type Position struct {
+	Symbol       string
+	Quantity     float64
+	AveragePrice float64
+}
+
+// Server is a mock Tradier API serving under /v1. Orders placed through it
+// are kept in memory and can be read back, modified and cancelled; their
+// status can be changed with SetOrderStatus to simulate fills.
+type Server struct {
+	*httptest.Server
+
+	mu             sync.Mutex
+	requests       []Request
+	overrides      map[string]cannedResponse
+	cash           float64
+	accountType    string
+	classification string
+	positions      []Position
+	quotes         map[string]float64
+	orders         map[int64]map[string]any
+	nextOrderID    int64
+}
+
+type cannedResponse struct {
+	status int
+	body   string
+}
+
+// NewServer starts a mock individual cash account with $10,000 in cash, no
+// positions and quotes for VTI and VXUS. Call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		overrides:      make(map[string]cannedResponse),
+		cash:           10_000,
+		accountType:    "cash",
+		classification: "individual",
+		quotes:         map[string]float64{"VTI": 250, "VXUS": 60},
+		orders:         make(map[int64]map[string]any),
+		nextOrderID:    1000,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/user/profile", s.authorized(s.handleProfile))
+	mux.HandleFunc("GET /v1/accounts/{account}/balances", s.authorized(s.handleBalances))
+	mux.HandleFunc("GET /v1/accounts/{account}/positions", s.authorized(s.handlePositions))
+	mux.HandleFunc("POST /v1/accounts/{account}/orders", s.authorized(s.handlePlaceOrder))
+	mux.HandleFunc("GET /v1/accounts/{account}/orders", s.authorized(s.handleListOrders))
+	mux.HandleFunc("GET /v1/accounts/{account}/orders/{id}", s.authorized(s.handleGetOrder))
+	mux.HandleFunc("PUT /v1/accounts/{account}/orders/{id}", s.authorized(s.handleModifyOrder))
+	mux.HandleFunc("DELETE /v1/accounts/{account}/orders/{id}", s.authorized(s.handleCancelOrder))
+	mux.HandleFunc("GET /v1/markets/quotes", s.authorized(s.handleQuotes))
+	mux.HandleFunc("POST /v1/markets/quotes", s.authorized(s.handleQuotes))
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(strings.NewReader(string(body)))
+
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.RawQuery,
+			Header: r.Header.Clone(),
+			Body:   body,
+		})
+		override, ok := s.overrides[r.Method+" "+r.URL.Path]
+		s.mu.Unlock()
+
+		if ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(override.status)
+			io.WriteString(w, override.body)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+
+	return s
+}
+
+// Config returns a client configuration pointing the client at the mock
+func (s *Server) Config() tradier.Config {
+	return tradier.Config{
+		AccessToken:   AccessToken,
+		Sandbox:       true,
+		BaseURL:       s.URL + "/v1",
+		AllowInsecure: true,
+	}
+}
+
+// NewClient returns a client for the mock
+func (s *Server) NewClient(opts ...tradier.Option) *tradier.Client {
+	return tradier.NewClient(s.Config(), opts...)
+}
+
+// Requests returns every request received so far, oldest first
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// LastRequest returns the most recent request to method and path, or false
+func (s *Server) LastRequest(method, path string) (Request, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(s.requests) - 1; i >= 0; i-- {
+		if s.requests[i].Method == method && s.requests[i].Path == path {
+			return s.requests[i], true
+		}
+	}
+	return Request{}, false
+}
+
+// SetResponse makes every request to method and path return status and body
+// instead of the mock's own response, e.g. to simulate errors
+func (s *Server) SetResponse(method, path string, status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.overrides[method+" "+path] = cannedResponse{status: status, body: body}
+}
+
+// ClearResponse removes a response set with SetResponse
+func (s *Server) ClearResponse(method, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.overrides, method+" "+path)
+}
+
+// SetCash sets the account's cash balance
+func (s *Server) SetCash(cash float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cash = cash
+}
+
+// SetAccount sets the account's type (cash, margin or pdt) and its
+// classification, e.g. individual or roth_ira
+func (s *Server) SetAccount(accountType, classification string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.accountType = accountType
+	s.classification = classification
+}
+
+// SetPositions replaces the account's holdings
+func (s *Server) SetPositions(positions ...Position) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.positions = append([]Position(nil), positions...)
+}
+
+// SetQuote sets the last price quoted for symbol
+func (s *Server) SetQuote(symbol string, price float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.quotes[symbol] = price
+}
+
+// SetOrderStatus changes the Tradier status of a placed order. Setting it to
+// filled fills the whole order at price.
+func (s *Server) SetOrderStatus(orderID string, status string, price float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orderLocked(orderID)
+	if !ok {
+		return fmt.Errorf("no order %s", orderID)
+	}
+
+	order["status"] = status
+	order["transaction_date"] = timestamp()
+	if status == "filled" {
+		order["exec_quantity"] = order["quantity"]
+		order["remaining_quantity"] = 0
+		order["avg_fill_price"] = price
+		order["last_fill_price"] = price
+		order["last_fill_quantity"] = order["quantity"]
+	}
+	return nil
+}
+
+// authorized rejects requests without the mock's access token or for
+// another account
+func (s *Server) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+AccessToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, "Invalid Access Token")
+			return
+		}
+		if account := r.PathValue("account"); account != "" && account != AccountNumber {
+			writeErrors(w, http.StatusBadRequest, "Invalid account: "+account)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"profile": map[string]any{
+			"id":   "id-test-user",
+			"name": "Test User",
+			"account": map[string]any{
+				"account_number":   AccountNumber,
+				"classification":   s.classification,
+				"date_created":     "2020-01-02T15:04:05.000Z",
+				"day_trader":       s.accountType == "pdt",
+				"option_level":     2,
+				"status":           "active",
+				"type":             s.accountType,
+				"last_update_date": "2020-01-02T15:04:05.000Z",
+			},
+		},
+	})
+}
+
+func (s *Server) handleBalances(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var marketValue float64
+	for _, p := range s.positions {
+		marketValue += p.Quantity * s.quotes[p.Symbol]
+	}
+
+	balances := map[string]any{
+		"account_number":    AccountNumber,
+		"account_type":      s.accountType,
+		"total_equity":      s.cash + marketValue,
+		"total_cash":        s.cash,
+		"long_market_value": marketValue,
+		"market_value":      marketValue,
+	}
+	switch s.accountType {
+	case "cash":
+		balances["cash"] = map[string]any{"cash_available": s.cash, "sweep": 0, "unsettled_funds": 0}
+	default:
+		balances[s.accountType] = map[string]any{
+			"fed_call":            0,
+			"maintenance_call":    0,
+			"option_buying_power": s.cash,
+			"stock_buying_power":  2 * s.cash,
+			"stock_short_value":   0,
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"balances": balances})
+}
+
+func (s *Server) handlePositions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	positions := make([]any, 0, len(s.positions))
+	for i, p := range s.positions {
+		positions = append(positions, map[string]any{
+			"id":            i + 1,
+			"symbol":        p.Symbol,
+			"quantity":      p.Quantity,
+			"cost_basis":    p.Quantity * p.AveragePrice,
+			"date_acquired": "2020-01-02T15:04:05.000Z",
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"positions": container("position", positions)})
+}
+
+func (s *Server) handlePlaceOrder(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	order, err := parseOrderForm(r.PostForm)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.PostForm.Get("preview") == "true" {
+		s.previewLocked(w, order)
+		return
+	}
+
+	s.nextOrderID++
+	order["id"] = s.nextOrderID
+	order["status"] = "open"
+	order["exec_quantity"] = 0
+	order["avg_fill_price"] = 0
+	order["remaining_quantity"] = order["quantity"]
+	order["create_date"] = timestamp()
+	order["transaction_date"] = order["create_date"]
+	s.orders[s.nextOrderID] = order
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"order": map[string]any{"id": s.nextOrderID, "status": "ok", "partner_id": "test-partner"},
+	})
+}
+
+// previewLocked answers an order preview, rejecting buys the account cannot
+// afford. The caller must hold mu.
+func (s *Server) previewLocked(w http.ResponseWriter, order map[string]any) {
+	price := s.quotes[order["symbol"].(string)]
+	if limit, ok := order["price"].(float64); ok {
+		price = limit
+	}
+	cost := order["quantity"].(float64) * price
+
+	if order["side"] == "buy" && cost > s.cash {
+		writeErrors(w, http.StatusBadRequest, "Backoffice rejected override of the order.", "Not enough buying power for this order.")
+		return
+	}
+
+	preview := map[string]any{
+		"status":         "ok",
+		"result":         true,
+		"commission":     0,
+		"fees":           0,
+		"cost":           cost,
+		"order_cost":     cost,
+		"margin_change":  0,
+		"request_date":   timestamp(),
+		"extended_hours": order["duration"] == "pre" || order["duration"] == "post",
+		"strategy":       "equity",
+		"day_trades":     0,
+	}
+	for key, value := range order {
+		preview[key] = value
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"order": preview})
+}
+
+// parseOrderForm converts the form fields of an equity order into an order
+// object
+func parseOrderForm(form map[string][]string) (map[string]any, error) {
+	get := func(key string) string {
+		if values := form[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	if get("class") != "equity" {
+		return nil, fmt.Errorf("unsupported class %q", get("class"))
+	}
+
+	quantity, err := strconv.ParseFloat(get("quantity"), 64)
+	if err != nil || quantity <= 0 || quantity != float64(int64(quantity)) {
+		return nil, fmt.Errorf("invalid quantity %q", get("quantity"))
+	}
+
+	order := map[string]any{
+		"class":    "equity",
+		"symbol":   get("symbol"),
+		"side":     get("side"),
+		"type":     get("type"),
+		"duration": get("duration"),
+		"quantity": quantity,
+	}
+	if err := setPrices(order, form); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// setPrices copies the price and stop form fields into an order object
+func setPrices(order map[string]any, form map[string][]string) error {
+	for field, key := range map[string]string{"price": "price", "stop": "stop_price"} {
+		values := form[field]
+		if len(values) == 0 {
+			continue
+		}
+		price, err := strconv.ParseFloat(values[0], 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", field, values[0])
+		}
+		order[key] = price
+	}
+	return nil
+}
+
+func (s *Server) handleModifyOrder(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orderLocked(r.PathValue("id"))
+	if !ok {
+		writeErrors(w, http.StatusBadRequest, "Order not found")
+		return
+	}
+	if order["status"] != "open" {
+		writeErrors(w, http.StatusBadRequest, "Order is not open")
+		return
+	}
+	for _, field := range []string{"type", "duration"} {
+		if value := r.PostForm.Get(field); value != "" {
+			order[field] = value
+		}
+	}
+	if err := setPrices(order, r.PostForm); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"order": map[string]any{"id": order["id"], "status": "ok"},
+	})
+}
+
+// orderLocked returns the order with the given ID. The caller must hold mu.
+func (s *Server) orderLocked(orderID string) (map[string]any, bool) {
+	id, err := strconv.ParseInt(orderID, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	order, ok := s.orders[id]
+	return order, ok
+}
+
+func (s *Server) handleGetOrder(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orderLocked(r.PathValue("id"))
+	if !ok {
+		writeErrors(w, http.StatusBadRequest, "Order not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"order": order})
+}
+
+func (s *Server) handleListOrders(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	orders := make([]any, 0, len(s.orders))
+	for id := int64(0); id <= s.nextOrderID; id++ {
+		if order, ok := s.orders[id]; ok {
+			orders = append(orders, order)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"orders": container("order", orders)})
+}
+
+func (s *Server) handleCancelOrder(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orderLocked(r.PathValue("id"))
+	if !ok {
+		writeErrors(w, http.StatusBadRequest, "Order not found")
+		return
+	}
+	if order["status"] != "open" && order["status"] != "pending" {
+		writeErrors(w, http.StatusBadRequest, "Order is not cancelable")
+		return
+	}
+	order["status"] = "canceled"
+	order["transaction_date"] = timestamp()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"order": map[string]any{"id": order["id"], "status": "ok"},
+	})
+}
+
+func (s *Server) handleQuotes(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var quotes, unmatched []any
+	now := time.Now().UnixMilli()
+	for _, symbol := range strings.Split(r.Form.Get("symbols"), ",") {
+		price, ok := s.quotes[symbol]
+		if !ok {
+			unmatched = append(unmatched, symbol)
+			continue
+		}
+		quotes = append(quotes, map[string]any{
+			"symbol":      symbol,
+			"description": symbol + " test security",
+			"exch":        "Q",
+			"type":        "etf",
+			"last":        price,
+			"change":      0,
+			"volume":      1_000_000,
+			"open":        price,
+			"high":        price,
+			"low":         price,
+			"close":       nil,
+			"bid":         price - 0.01,
+			"ask":         price + 0.01,
+			"prevclose":   price,
+			"trade_date":  now,
+			"bid_date":    now,
+			"ask_date":    now,
+		})
+	}
+
+	body := map[string]any{}
+	if len(quotes) > 0 {
+		body["quote"] = list(quotes)
+	}
+	if len(unmatched) > 0 {
+		body["unmatched_symbols"] = map[string]any{"symbol": list(unmatched)}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"quotes": body})
+}
+
+// container renders items the way Tradier does, as {key: items} or the
+// string "null" when there are none
+func container(key string, items []any) any {
+	if len(items) == 0 {
+		return "null"
+	}
+	return map[string]any{key: list(items)}
+}
+
+// list renders a non-empty list the way Tradier does, as the element itself
+// when there is only one
+func list(items []any) any {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return items
+}
+
+// timestamp returns the current time in Tradier's format
+func timestamp() string {
+	return time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeErrors writes Tradier's error payload
+func writeErrors(w http.ResponseWriter, status int, messages ...string) {
+	errs := make([]any, len(messages))
+	for i, message := range messages {
+		errs[i] = message
+	}
+	writeJSON(w, status, map[string]any{"errors": map[string]any{"error": list(errs)}})
+}
@@ -0,0 +1,111 @@
+// Package cli holds what the commands share: the safety checks every command
+// placing live orders runs before submitting them, so that they all guard
+// orders the same way, and the helpers printing their errors and results.
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+// ErrDeclined is returned by Confirm when the orders were not confirmed
+var ErrDeclined = errors.New("orders not confirmed")
+
+// Safety holds the flags guarding live orders
+type Safety struct {
+	Yes      bool    // Place the orders without asking
+	MaxTotal float64 // Refuse plans trading more dollars than this, 0 for no cap
+	RiskFile string  // Risk limits file, see pies.LoadRiskLimits; empty for the pie's limits alone
+}
+
+// SafetyFlags registers --yes, --max-total and --risk-config on flags and
+// returns the safety settings they select
+func SafetyFlags(flags *flag.FlagSet) *Safety {
+	var s Safety
+	flags.BoolVar(&s.Yes, "yes", false, "place the orders without asking, needed when stdin is not a terminal")
+	flags.Float64Var(&s.MaxTotal, "max-total", 0, "refuse to trade more than this many dollars in one run; 0 sets no cap")
+	flags.StringVar(&s.RiskFile, "risk-config", "", "file of pre-trade risk limits orders are checked against; the pie's own risk limits take precedence")
+	return &s
+}
+
+// CheckTotal fails when the estimated value of the plan's orders, buys and
+// sells together, exceeds --max-total
+func (s *Safety) CheckTotal(plan *pies.RebalancePlan) error {
+	if s.MaxTotal <= 0 {
+		return nil
+	}
+	buys, sells := totals(plan)
+	total := buys.Add(sells)
+	if limit := pies.NewDecimal(s.MaxTotal); total.GreaterThan(limit) {
+		return fmt.Errorf("plan trades an estimated %s, more than --max-total %s", total.StringFixed(2), limit.StringFixed(2))
+	}
+	return nil
+}
+
+// Confirm writes the estimated totals of the plan's orders to w and has them
+// confirmed by typing "yes" or the last four digits of the account number
+// on in. It fails with ErrDeclined for any other answer, and without asking
+// when in is not a terminal. With --yes it only writes the totals.
+func (s *Safety) Confirm(w io.Writer, in *os.File, account pies.Account, plan *pies.RebalancePlan) error {
+	buys, sells := totals(plan)
+	fmt.Fprintln(w)
+	if buys.IsPositive() {
+		fmt.Fprintf(w, "Estimated buys: %s\n", buys.StringFixed(2))
+	}
+	if sells.IsPositive() {
+		fmt.Fprintf(w, "Estimated sells: %s\n", sells.StringFixed(2))
+	}
+	if s.Yes {
+		return nil
+	}
+	if !isTerminal(in) {
+		return errors.New("refusing to place live orders without --yes when stdin is not a terminal")
+	}
+
+	fmt.Fprintf(w, "\nPlace these %d orders in account %s? Type yes or the last four digits of the account number to confirm: ",
+		len(plan.Orders), account.ID())
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "yes" || (answer != "" && answer == lastFour(account)) {
+		return nil
+	}
+	return ErrDeclined
+}
+
+// totals returns the estimated dollar values of the plan's buys and sells
+func totals(plan *pies.RebalancePlan) (buys, sells decimal.Decimal) {
+	for _, planned := range plan.Orders {
+		if planned.Order.Action == pies.OrderActionSell {
+			sells = sells.Add(planned.Value)
+		} else {
+			buys = buys.Add(planned.Value)
+		}
+	}
+	return buys, sells
+}
+
+// lastFour returns the last four characters of the account number
+func lastFour(account pies.Account) string {
+	number := account.AccountNumber
+	if number == "" {
+		number = account.AccountID
+	}
+	return number[max(len(number)-4, 0):]
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages"
+	"github.com/alysoliman1/money-pies/internal/pkg/output"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// PrintError writes err, pointing at money-pies auth login when the
+// brokerage needs signing in to again
+func PrintError(w io.Writer, brokerageName string, err error) {
+	if errors.Is(err, pies.ErrNotAuthenticated) {
+		fmt.Fprintln(w, brokerages.NotAuthenticatedMessage(brokerageName))
+		return
+	}
+	fmt.Fprintln(w, err)
+}
+
+// WriteResult writes v to stdout when the output format is JSON, exiting
+// when it cannot
+func WriteResult(format output.Format, v any) {
+	if !format.IsJSON() {
+		return
+	}
+	if err := output.WriteJSON(os.Stdout, v); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// SplitList splits a comma-separated flag value, dropping empty items
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
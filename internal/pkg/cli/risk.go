@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// RiskChecker returns the checker of the --risk-config limits merged with
+// the pie's, or nil when neither sets any
+func (s *Safety) RiskChecker(pie pies.Pie) (*pies.RiskChecker, error) {
+	var limits pies.RiskLimits
+	if s.RiskFile != "" {
+		var err error
+		if limits, err = pies.LoadRiskLimits(s.RiskFile); err != nil {
+			return nil, err
+		}
+	}
+	return pies.NewRiskChecker(limits, pie), nil
+}
+
+// CheckRisk runs the investor's risk checks on the plan before it is
+// confirmed, writing the warnings to w. It fails when an order breaches a
+// rule that refuses it; ExecutePlan checks again before placing anything.
+func CheckRisk(ctx context.Context, w io.Writer, investor *pies.Investor, plan *pies.RebalancePlan) error {
+	warnings, err := investor.CheckRisk(ctx, plan)
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "Risk warning: %s\n", warning.Message)
+	}
+	return err
+}
@@ -0,0 +1,180 @@
+// Package config loads the brokerage configuration shared by the commands.
+// The schwab settings can also be given as flags and environment variables,
+// which take precedence over the config file in that order, so containers
+// can pass credentials without writing them into the file.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+const (
+	// PathEnv names the environment variable holding the path of the
+	// brokerage config file, {"brokerage": "schwab", "config": {...}}
+	PathEnv = "BROKERAGE_CONFIG"
+
+	// SchwabPathEnv names the environment variable holding the path of a
+	// bare schwab.Config file, which the commands read before PathEnv
+	// existed. It is only used when PathEnv is not set.
+	SchwabPathEnv = "SCHWAB_CLIENT_CONFIG"
+
+	// Environment variables overriding the fields of the schwab config
+	SchwabClientIDEnv     = "SCHWAB_CLIENT_ID"
+	SchwabClientSecretEnv = "SCHWAB_CLIENT_SECRET"
+	SchwabRedirectURIEnv  = "SCHWAB_REDIRECT_URI"
+	SchwabTokenFileEnv    = "SCHWAB_TOKEN_FILE"
+)
+
+// Overrides are settings given on the command line. Empty fields override
+// nothing.
+type Overrides struct {
+	Path             string // Config file read instead of the one named by PathEnv
+	ClientID         string
+	ClientSecretFile string // File holding the client secret, e.g. a mounted secret
+	RedirectURI      string
+	TokenFile        string
+}
+
+// Flags registers the flags setting the overrides on flags and returns them
+func Flags(flags *flag.FlagSet) *Overrides {
+	var o Overrides
+	flags.StringVar(&o.Path, "config", "", "path to the brokerage config file, defaults to $"+PathEnv)
+	flags.StringVar(&o.ClientID, "client-id", "", "schwab client ID, overriding $"+SchwabClientIDEnv+" and the config file")
+	flags.StringVar(&o.ClientSecretFile, "client-secret-file", "", "file holding the schwab client secret, overriding $"+SchwabClientSecretEnv+" and the config file")
+	flags.StringVar(&o.RedirectURI, "redirect-uri", "", "schwab redirect URI, overriding $"+SchwabRedirectURIEnv+" and the config file")
+	flags.StringVar(&o.TokenFile, "token-file", "", "schwab token file, overriding $"+SchwabTokenFileEnv+" and the config file")
+	return &o
+}
+
+// schwabField is a field of the schwab config that can be overridden
+type schwabField struct {
+	key   string // The field's key in the config JSON
+	env   string
+	value func(o Overrides) (string, error) // The flag's value, empty when not given
+}
+
+var schwabFields = []schwabField{
+	{"client_id", SchwabClientIDEnv, func(o Overrides) (string, error) { return o.ClientID, nil }},
+	{"client_secret", SchwabClientSecretEnv, Overrides.clientSecret},
+	{"redirect_uri", SchwabRedirectURIEnv, func(o Overrides) (string, error) { return o.RedirectURI, nil }},
+	{"token_file", SchwabTokenFileEnv, func(o Overrides) (string, error) { return o.TokenFile, nil }},
+}
+
+// clientSecret reads the secret from ClientSecretFile, if given
+func (o Overrides) clientSecret() (string, error) {
+	if o.ClientSecretFile == "" {
+		return "", nil
+	}
+	raw, err := os.ReadFile(o.ClientSecretFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read client secret file: %w", err)
+	}
+	secret := strings.TrimSpace(string(raw))
+	if secret == "" {
+		return "", fmt.Errorf("client secret file %s is empty", o.ClientSecretFile)
+	}
+	return secret, nil
+}
+
+// Load returns the brokerage config: the file given by overrides.Path,
+// PathEnv or SchwabPathEnv, with the schwab fields replaced by their
+// environment variables and then by overrides. Without a file the schwab
+// fields alone make up a schwab config.
+func Load(overrides Overrides) (pies.BrokerageConfig, error) {
+	config, found, err := loadFile(overrides.Path)
+	if err != nil {
+		return pies.BrokerageConfig{}, err
+	}
+
+	values := make(map[string]string)
+	for _, field := range schwabFields {
+		if value := os.Getenv(field.env); value != "" {
+			values[field.key] = value
+		}
+	}
+	overridden := false
+	for _, field := range schwabFields {
+		value, err := field.value(overrides)
+		if err != nil {
+			return pies.BrokerageConfig{}, err
+		}
+		if value != "" {
+			values[field.key] = value
+			overridden = true
+		}
+	}
+
+	switch {
+	case !found && len(values) == 0:
+		return pies.BrokerageConfig{}, fmt.Errorf("brokerage config not specified, set %s", PathEnv)
+	case !found:
+		config = pies.BrokerageConfig{Brokerage: "schwab", Config: json.RawMessage("{}")}
+	case config.Brokerage != "schwab" && overridden:
+		return pies.BrokerageConfig{}, fmt.Errorf("the schwab flags do not apply to the %s brokerage", config.Brokerage)
+	case config.Brokerage != "schwab":
+		// The environment may hold schwab settings for other runs
+		return config, nil
+	}
+
+	merged, err := mergeFields(config.Config, values)
+	if err != nil {
+		return pies.BrokerageConfig{}, err
+	}
+	config.Config = merged
+	return config, nil
+}
+
+// loadFile reads the brokerage config file at path, or the one named by the
+// environment when path is empty, reporting whether there was one
+func loadFile(path string) (pies.BrokerageConfig, bool, error) {
+	if path == "" {
+		path = os.Getenv(PathEnv)
+	}
+	if path != "" {
+		config, err := pies.LoadBrokerageConfig(path)
+		return config, err == nil, err
+	}
+
+	if path := os.Getenv(SchwabPathEnv); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return pies.BrokerageConfig{}, false, fmt.Errorf("failed to read config file: %w", err)
+		}
+		return pies.BrokerageConfig{Brokerage: "schwab", Config: raw}, true, nil
+	}
+	return pies.BrokerageConfig{}, false, nil
+}
+
+// mergeFields sets the top-level keys of the JSON object raw to values,
+// leaving its other keys untouched. Errors never quote the values, which
+// may be secrets.
+func mergeFields(raw json.RawMessage, values map[string]string) (json.RawMessage, error) {
+	if len(values) == 0 {
+		return raw, nil
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse schwab config: %w", err)
+	}
+	for key, value := range values {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, errors.New("failed to encode " + key)
+		}
+		fields[key] = encoded
+	}
+
+	merged, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode schwab config: %w", err)
+	}
+	return merged, nil
+}
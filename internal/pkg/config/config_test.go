@@ -0,0 +1,225 @@
+package config_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/config"
+)
+
+// clearEnv unsets every variable Load reads for the duration of the test
+func clearEnv(t *testing.T) {
+	t.Helper()
+
+	for _, env := range []string{
+		config.PathEnv,
+		config.SchwabPathEnv,
+		config.SchwabClientIDEnv,
+		config.SchwabClientSecretEnv,
+		config.SchwabRedirectURIEnv,
+		config.SchwabTokenFileEnv,
+	} {
+		t.Setenv(env, "")
+	}
+}
+
+// writeFile writes contents to a file in a temporary directory and returns
+// its path
+func writeFile(t *testing.T, name, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const schwabFile = `{
+	"brokerage": "schwab",
+	"config": {
+		"client_id": "file-id",
+		"client_secret": "file-secret",
+		"redirect_uri": "https://127.0.0.1:8182",
+		"token_file": "file-token.json",
+		"requests_per_second": 2
+	}
+}`
+
+// schwabFields decodes the schwab config Load returned
+func schwabFields(t *testing.T, raw json.RawMessage) map[string]any {
+	t.Helper()
+
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		t.Fatalf("failed to decode config %s: %v", raw, err)
+	}
+	return fields
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      bool
+		env       map[string]string
+		overrides config.Overrides
+		want      map[string]any
+	}{
+		{
+			name: "file",
+			file: true,
+			want: map[string]any{"client_id": "file-id", "client_secret": "file-secret", "token_file": "file-token.json"},
+		},
+		{
+			name: "env over file",
+			file: true,
+			env:  map[string]string{config.SchwabClientIDEnv: "env-id", config.SchwabTokenFileEnv: "env-token.json"},
+			want: map[string]any{"client_id": "env-id", "client_secret": "file-secret", "token_file": "env-token.json"},
+		},
+		{
+			name:      "flag over file",
+			file:      true,
+			overrides: config.Overrides{ClientID: "flag-id"},
+			want:      map[string]any{"client_id": "flag-id", "client_secret": "file-secret", "token_file": "file-token.json"},
+		},
+		{
+			name:      "flag over env over file",
+			file:      true,
+			env:       map[string]string{config.SchwabClientIDEnv: "env-id", config.SchwabRedirectURIEnv: "https://127.0.0.1:9000"},
+			overrides: config.Overrides{ClientID: "flag-id"},
+			want:      map[string]any{"client_id": "flag-id", "redirect_uri": "https://127.0.0.1:9000", "token_file": "file-token.json"},
+		},
+		{
+			name: "env without file",
+			env:  map[string]string{config.SchwabClientIDEnv: "env-id", config.SchwabClientSecretEnv: "env-secret"},
+			want: map[string]any{"client_id": "env-id", "client_secret": "env-secret"},
+		},
+		{
+			name:      "flag over env without file",
+			env:       map[string]string{config.SchwabClientIDEnv: "env-id", config.SchwabTokenFileEnv: "env-token.json"},
+			overrides: config.Overrides{ClientID: "flag-id", TokenFile: "flag-token.json"},
+			want:      map[string]any{"client_id": "flag-id", "token_file": "flag-token.json"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			if tt.file {
+				t.Setenv(config.PathEnv, writeFile(t, "brokerage.json", schwabFile))
+			}
+			for env, value := range tt.env {
+				t.Setenv(env, value)
+			}
+
+			got, err := config.Load(tt.overrides)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if got.Brokerage != "schwab" {
+				t.Errorf("Brokerage = %q, want schwab", got.Brokerage)
+			}
+			fields := schwabFields(t, got.Config)
+			for key, want := range tt.want {
+				if fields[key] != want {
+					t.Errorf("%s = %v, want %v", key, fields[key], want)
+				}
+			}
+			if tt.file && fields["requests_per_second"] != float64(2) {
+				t.Errorf("requests_per_second = %v, want the file's 2 untouched", fields["requests_per_second"])
+			}
+		})
+	}
+}
+
+func TestLoadConfigFlagOverridesPathEnv(t *testing.T) {
+	clearEnv(t)
+	t.Setenv(config.PathEnv, writeFile(t, "env.json", `{"brokerage": "paper", "config": {}}`))
+
+	got, err := config.Load(config.Overrides{Path: writeFile(t, "flag.json", schwabFile)})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got.Brokerage != "schwab" {
+		t.Errorf("Brokerage = %q, want the --config file's schwab", got.Brokerage)
+	}
+}
+
+func TestLoadLegacySchwabFile(t *testing.T) {
+	clearEnv(t)
+	t.Setenv(config.SchwabPathEnv, writeFile(t, "schwab.json", `{"client_id": "legacy-id"}`))
+	t.Setenv(config.SchwabClientSecretEnv, "env-secret")
+
+	got, err := config.Load(config.Overrides{})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	fields := schwabFields(t, got.Config)
+	if fields["client_id"] != "legacy-id" || fields["client_secret"] != "env-secret" {
+		t.Errorf("Load() config = %s, want the legacy client ID and the env secret", got.Config)
+	}
+}
+
+func TestLoadClientSecretFile(t *testing.T) {
+	clearEnv(t)
+	t.Setenv(config.PathEnv, writeFile(t, "brokerage.json", schwabFile))
+	t.Setenv(config.SchwabClientSecretEnv, "env-secret")
+
+	got, err := config.Load(config.Overrides{ClientSecretFile: writeFile(t, "secret", "mounted-secret\n")})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if secret := schwabFields(t, got.Config)["client_secret"]; secret != "mounted-secret" {
+		t.Errorf("client_secret = %v, want mounted-secret", secret)
+	}
+
+	_, err = config.Load(config.Overrides{ClientSecretFile: writeFile(t, "empty", "\n")})
+	if err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Errorf("Load() with an empty secret file error = %v, want it reported empty", err)
+	}
+}
+
+func TestLoadOtherBrokerage(t *testing.T) {
+	clearEnv(t)
+	t.Setenv(config.PathEnv, writeFile(t, "brokerage.json", `{"brokerage": "paper", "config": {"starting_cash": "1000"}}`))
+	t.Setenv(config.SchwabClientIDEnv, "env-id")
+
+	got, err := config.Load(config.Overrides{})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got.Brokerage != "paper" || strings.Contains(string(got.Config), "env-id") {
+		t.Errorf("Load() = %s %s, want the paper config without the schwab env", got.Brokerage, got.Config)
+	}
+
+	_, err = config.Load(config.Overrides{ClientID: "flag-id"})
+	if err == nil || !strings.Contains(err.Error(), "do not apply to the paper brokerage") {
+		t.Errorf("Load() with a schwab flag error = %v, want it refused", err)
+	}
+}
+
+func TestLoadWithoutConfig(t *testing.T) {
+	clearEnv(t)
+
+	_, err := config.Load(config.Overrides{})
+	if err == nil || !strings.Contains(err.Error(), config.PathEnv) {
+		t.Errorf("Load() error = %v, want it to name %s", err, config.PathEnv)
+	}
+}
+
+func TestLoadErrorsDoNotLeakSecrets(t *testing.T) {
+	clearEnv(t)
+	t.Setenv(config.SchwabPathEnv, writeFile(t, "schwab.json", `{"client_id": `))
+	t.Setenv(config.SchwabClientSecretEnv, "env-secret-value")
+
+	_, err := config.Load(config.Overrides{})
+	if err == nil {
+		t.Fatal("Load() of a malformed config succeeded, want an error")
+	}
+	if strings.Contains(err.Error(), "env-secret-value") {
+		t.Errorf("Load() error = %v, quotes the secret", err)
+	}
+}
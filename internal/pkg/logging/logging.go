@@ -0,0 +1,86 @@
+// Package logging sets up the slog logger of the commands from their
+// --log-level and --log-format flags. Every logger it makes redacts secrets
+// and account numbers, see Redact.
+package logging
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Format selects how log records are written
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+)
+
+func (f *Format) String() string {
+	return string(*f)
+}
+
+func (f *Format) Set(value string) error {
+	switch Format(value) {
+	case FormatText, FormatJSON:
+		*f = Format(value)
+		return nil
+	default:
+		return fmt.Errorf("unknown log format %q, want text or json", value)
+	}
+}
+
+// Options are the logging flags of a command
+type Options struct {
+	Level  slog.Level
+	Format Format
+}
+
+// Flags registers --log-level and --log-format on flags and returns the
+// options they set, logging at level in text by default
+func Flags(flags *flag.FlagSet, level slog.Level) *Options {
+	o := &Options{Level: level, Format: FormatText}
+	flags.TextVar(&o.Level, "log-level", level, "lowest level logged: debug, info, warn or error")
+	flags.Var(&o.Format, "log-format", "log format, text or json")
+	return o
+}
+
+// New returns a logger writing records of o.Level and above to w in
+// o.Format, with Redact applied
+func (o Options) New(w io.Writer) *slog.Logger {
+	handlerOptions := &slog.HandlerOptions{Level: o.Level}
+	var handler slog.Handler
+	if o.Format == FormatJSON {
+		handler = slog.NewJSONHandler(w, handlerOptions)
+	} else {
+		handler = slog.NewTextHandler(w, handlerOptions)
+	}
+	return slog.New(Redact(handler))
+}
+
+// Setup makes the logger of o, writing to w, the slog default and returns it
+func (o Options) Setup(w io.Writer) *slog.Logger {
+	logger := o.New(w)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// Discard returns a logger that logs nothing
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+// MaskAccount cuts an account number or ID down to its last four
+// characters, e.g. "****6789"
+func MaskAccount(account string) string {
+	if account == "" {
+		return ""
+	}
+	if len(account) <= 4 {
+		return strings.Repeat("*", len(account))
+	}
+	return "****" + account[len(account)-4:]
+}
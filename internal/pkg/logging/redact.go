@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces secret values in log records
+const Redacted = "[REDACTED]"
+
+// secretKeys are attribute keys whose values are never logged
+var secretKeys = map[string]bool{
+	"access_token":  true,
+	"refresh_token": true,
+	"id_token":      true,
+	"token":         true,
+	"client_secret": true,
+	"password":      true,
+	"authorization": true,
+	"code":          true,
+	"code_verifier": true,
+}
+
+// accountKeys are attribute keys whose values are account numbers or IDs,
+// logged by their last four characters
+var accountKeys = map[string]bool{
+	"account":        true,
+	"account_id":     true,
+	"account_number": true,
+	"account_hash":   true,
+}
+
+// accountInPath matches the account segment of API paths such as
+// /trader/v1/accounts/{hash}/orders
+var accountInPath = regexp.MustCompile(`(/accounts/)([^/?#]+)`)
+
+// Redact wraps handler so that, whatever logs through it, the values of
+// attributes holding secrets are replaced by Redacted and account numbers
+// are cut to their last four characters, including the account segment of
+// logged paths and URLs. Wrapping a handler twice has no further effect.
+func Redact(handler slog.Handler) slog.Handler {
+	if _, ok := handler.(redactor); ok {
+		return handler
+	}
+	return redactor{handler}
+}
+
+// RedactLogger applies Redact to the handler of logger
+func RedactLogger(logger *slog.Logger) *slog.Logger {
+	if _, ok := logger.Handler().(redactor); ok {
+		return logger
+	}
+	return slog.New(Redact(logger.Handler()))
+}
+
+// redactor is the handler returned by Redact
+type redactor struct {
+	slog.Handler
+}
+
+func (r redactor) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(attr))
+		return true
+	})
+	return r.Handler.Handle(ctx, redacted)
+}
+
+func (r redactor) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = redactAttr(attr)
+	}
+	return redactor{r.Handler.WithAttrs(redacted)}
+}
+
+func (r redactor) WithGroup(name string) slog.Handler {
+	return redactor{r.Handler.WithGroup(name)}
+}
+
+// redactAttr returns attr with its value redacted as its key requires,
+// descending into groups
+func redactAttr(attr slog.Attr) slog.Attr {
+	attr.Value = attr.Value.Resolve()
+	key := strings.ToLower(attr.Key)
+	switch {
+	case attr.Value.Kind() == slog.KindGroup:
+		group := attr.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, member := range group {
+			redacted[i] = redactAttr(member)
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(redacted...)}
+	case secretKeys[key]:
+		return slog.String(attr.Key, Redacted)
+	case accountKeys[key]:
+		return slog.String(attr.Key, MaskAccount(attr.Value.String()))
+	case key == "path" || key == "url":
+		return slog.String(attr.Key, MaskAccountsInPath(attr.Value.String()))
+	}
+	return attr
+}
+
+// MaskAccountsInPath cuts the account segment of paths such as
+// /trader/v1/accounts/{hash}/orders to its last four characters
+func MaskAccountsInPath(path string) string {
+	return accountInPath.ReplaceAllStringFunc(path, func(match string) string {
+		parts := accountInPath.FindStringSubmatch(match)
+		if parts[2] == "accountNumbers" {
+			// Schwab's listing of the account numbers, not an account
+			return match
+		}
+		return parts[1] + MaskAccount(parts[2])
+	})
+}
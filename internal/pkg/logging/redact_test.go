@@ -0,0 +1,171 @@
+package logging_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/logging"
+)
+
+// capture returns a logger writing text records through Redact to the
+// returned buffer
+func capture() (*slog.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if attr.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return attr
+		},
+	})
+	return slog.New(logging.Redact(handler)), &buf
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(logger *slog.Logger)
+		want string
+	}{
+		{
+			name: "tokens",
+			log: func(logger *slog.Logger) {
+				logger.Info("token", slog.String("access_token", "secret-access"), slog.String("refresh_token", "secret-refresh"))
+			},
+			want: `level=INFO msg=token access_token=[REDACTED] refresh_token=[REDACTED]`,
+		},
+		{
+			name: "client secret and authorization",
+			log: func(logger *slog.Logger) {
+				logger.Info("request", slog.String("client_secret", "s3cret"), slog.String("Authorization", "Bearer abc"))
+			},
+			want: `level=INFO msg=request client_secret=[REDACTED] Authorization=[REDACTED]`,
+		},
+		{
+			name: "oauth code",
+			log: func(logger *slog.Logger) {
+				logger.Info("callback", slog.String("code", "auth-code"), slog.String("code_verifier", "verifier"))
+			},
+			want: `level=INFO msg=callback code=[REDACTED] code_verifier=[REDACTED]`,
+		},
+		{
+			name: "account numbers",
+			log: func(logger *slog.Logger) {
+				logger.Info("order", slog.String("account", "12345678"), slog.String("account_hash", "ABCDEF0123456789"),
+					slog.String("account_id", "123"))
+			},
+			want: `level=INFO msg=order account=****5678 account_hash=****6789 account_id=***`,
+		},
+		{
+			name: "paths and urls",
+			log: func(logger *slog.Logger) {
+				logger.Info("request", slog.String("path", "/trader/v1/accounts/ABCDEF0123456789/orders?maxResults=10"),
+					slog.String("url", "https://api.schwabapi.com/trader/v1/accounts/accountNumbers"))
+			},
+			want: `level=INFO msg=request path="/trader/v1/accounts/****6789/orders?maxResults=10" url=https://api.schwabapi.com/trader/v1/accounts/accountNumbers`,
+		},
+		{
+			name: "groups",
+			log: func(logger *slog.Logger) {
+				logger.Info("token", slog.Group("token", slog.String("access_token", "secret-access"), slog.Int("expires_in", 1800)))
+			},
+			want: `level=INFO msg=token token.access_token=[REDACTED] token.expires_in=1800`,
+		},
+		{
+			name: "with attrs",
+			log: func(logger *slog.Logger) {
+				logger.With(slog.String("account", "12345678"), slog.String("token", "secret")).Warn("refresh failed")
+			},
+			want: `level=WARN msg="refresh failed" account=****5678 token=[REDACTED]`,
+		},
+		{
+			name: "with group",
+			log: func(logger *slog.Logger) {
+				logger.WithGroup("schwab").Info("token", slog.String("refresh_token", "secret-refresh"))
+			},
+			want: `level=INFO msg=token schwab.refresh_token=[REDACTED]`,
+		},
+		{
+			name: "values resolved first",
+			log: func(logger *slog.Logger) {
+				logger.Info("token", slog.Any("access_token", secretValuer("secret-access")))
+			},
+			want: `level=INFO msg=token access_token=[REDACTED]`,
+		},
+		{
+			name: "other attributes",
+			log: func(logger *slog.Logger) {
+				logger.Info("order", slog.String("symbol", "VTI"), slog.String("order_id", "1001"))
+			},
+			want: `level=INFO msg=order symbol=VTI order_id=1001`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, buf := capture()
+			tt.log(logger)
+			if got := strings.TrimSpace(buf.String()); got != tt.want {
+				t.Errorf("logged %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// secretValuer is a slog.LogValuer resolving to a secret
+type secretValuer string
+
+func (v secretValuer) LogValue() slog.Value {
+	return slog.StringValue(string(v))
+}
+
+func TestRedactLoggerOnce(t *testing.T) {
+	logger, _ := capture()
+	if again := logging.RedactLogger(logger); again != logger {
+		t.Error("RedactLogger() wrapped an already redacting logger again")
+	}
+	if handler := logger.Handler(); logging.Redact(handler) != handler {
+		t.Error("Redact() wrapped an already redacting handler again")
+	}
+}
+
+func TestOptionsNewRedacts(t *testing.T) {
+	for _, format := range []logging.Format{logging.FormatText, logging.FormatJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := logging.Options{Level: slog.LevelInfo, Format: format}.New(&buf)
+			logger.Debug("hidden")
+			logger.Info("token", slog.String("access_token", "secret-access"), slog.String("account", "12345678"))
+
+			got := buf.String()
+			if strings.Contains(got, "hidden") {
+				t.Errorf("logged a debug record at info level: %s", got)
+			}
+			if strings.Contains(got, "secret-access") || strings.Contains(got, "12345678") {
+				t.Errorf("logged %s, want the token and account redacted", got)
+			}
+			if !strings.Contains(got, "****5678") {
+				t.Errorf("logged %s, want the account's last four characters", got)
+			}
+		})
+	}
+}
+
+func TestMaskAccount(t *testing.T) {
+	tests := map[string]string{
+		"":                 "",
+		"1":                "*",
+		"1234":             "****",
+		"12345":            "****2345",
+		"ABCDEF0123456789": "****6789",
+	}
+	for account, want := range tests {
+		if got := logging.MaskAccount(account); got != want {
+			t.Errorf("MaskAccount(%q) = %q, want %q", account, got, want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// defaultSMTPTimeout is how long sending an email may take when the
+// context has no deadline
+const defaultSMTPTimeout = 30 * time.Second
+
+// EmailConfig configures the SMTP server events are emailed through
+type EmailConfig struct {
+	Host         string           `json:"host"`
+	Port         int              `json:"port,omitempty"` // Zero uses 465 with TLS and 587 without
+	Username     string           `json:"username,omitempty"`
+	Password     string           `json:"password,omitempty"`
+	PasswordFile string           `json:"password_file,omitempty"` // Read instead of Password, e.g. a mounted secret
+	From         string           `json:"from"`
+	To           []string         `json:"to"`
+	Events       []pies.EventKind `json:"events,omitempty"` // Empty emails every event
+
+	// TLS connects with TLS from the start, as port 465 expects. Without
+	// it the connection is upgraded with STARTTLS when the server offers
+	// it, which it must for the password to be sent.
+	TLS bool `json:"tls,omitempty"`
+}
+
+// Email sends events as plain text emails
+type Email struct {
+	host     string
+	addr     string
+	tls      bool
+	auth     smtp.Auth // Nil sends without authenticating
+	from     string
+	to       []string
+	hostname string // Sent in the message ID
+}
+
+// NewEmail creates the email notifier configured by config
+func NewEmail(config EmailConfig) (*Email, error) {
+	if config.Host == "" {
+		return nil, errors.New("host is required")
+	}
+	if _, err := mail.ParseAddress(config.From); err != nil {
+		return nil, fmt.Errorf("invalid from address: %w", err)
+	}
+	if len(config.To) == 0 {
+		return nil, errors.New("to needs at least one address")
+	}
+	for _, to := range config.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return nil, fmt.Errorf("invalid to address %q: %w", to, err)
+		}
+	}
+	if err := checkKinds(config.Events); err != nil {
+		return nil, err
+	}
+
+	port := config.Port
+	if port == 0 {
+		port = 587
+		if config.TLS {
+			port = 465
+		}
+	}
+
+	password := config.Password
+	if config.PasswordFile != "" {
+		raw, err := os.ReadFile(config.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read password file: %w", err)
+		}
+		password = strings.TrimSpace(string(raw))
+	}
+
+	e := &Email{
+		host: config.Host,
+		addr: net.JoinHostPort(config.Host, strconv.Itoa(port)),
+		tls:  config.TLS,
+		from: config.From,
+		to:   config.To,
+	}
+	if config.Username != "" {
+		// PlainAuth refuses to send the password over an unencrypted
+		// connection to anything but localhost
+		e.auth = smtp.PlainAuth("", config.Username, password, config.Host)
+	}
+	if e.hostname, _ = os.Hostname(); e.hostname == "" {
+		e.hostname = "localhost"
+	}
+	return e, nil
+}
+
+func (e *Email) Notify(ctx context.Context, event pies.Event) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultSMTPTimeout)
+	}
+
+	conn, err := e.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start smtp session: %w", err)
+	}
+	defer client.Close()
+
+	if !e.tls {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: e.host}); err != nil {
+				return fmt.Errorf("failed to start tls: %w", err)
+			}
+		}
+	}
+	if e.auth != nil {
+		if err := client.Auth(e.auth); err != nil {
+			return fmt.Errorf("failed to authenticate with smtp server: %w", err)
+		}
+	}
+
+	if err := client.Mail(e.from); err != nil {
+		return fmt.Errorf("smtp server refused the sender: %w", err)
+	}
+	for _, to := range e.to {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("smtp server refused recipient %s: %w", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if _, err := w.Write(e.message(event)); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return client.Quit()
+}
+
+// dial connects to the server, with TLS when configured
+func (e *Email) dial(ctx context.Context) (net.Conn, error) {
+	if e.tls {
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: e.host}}
+		return dialer.DialContext(ctx, "tcp", e.addr)
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", e.addr)
+}
+
+// message formats event as an email
+func (e *Email) message(event pies.Event) []byte {
+	subject := "money-pies: " + strings.ReplaceAll(string(event.Kind), "_", " ")
+	if event.Pie != "" {
+		subject += " for " + event.Pie
+	}
+
+	var b strings.Builder
+	header := func(name, value string) {
+		fmt.Fprintf(&b, "%s: %s\n", name, value)
+	}
+	header("From", e.from)
+	header("To", strings.Join(e.to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", event.Time.Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%d.%s@%s>", event.Time.UnixNano(), event.Kind, e.hostname))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	b.WriteString("\n")
+
+	lines := []string{event.Message}
+	if event.Plan != nil && len(event.Plan.Orders) > 0 && event.Report == nil {
+		lines = append(lines, "", "Planned orders:")
+		for _, planned := range event.Plan.Orders {
+			lines = append(lines, fmt.Sprintf("  %s %v %s, about %s",
+				strings.ToLower(string(planned.Order.Action)), planned.Order.Quantity, planned.Order.Symbol, planned.Value.StringFixed(2)))
+		}
+	}
+	if event.Report != nil {
+		lines = append(lines, "", "Orders:")
+		for _, executed := range event.Report.Orders {
+			line := fmt.Sprintf("  %s %v %s: %s, filled %v at %s",
+				strings.ToLower(string(executed.Request.Action)), executed.Request.Quantity, executed.Request.Symbol,
+				executed.Status, executed.FilledQty, executed.FilledPrice.StringFixed(2))
+			if executed.Error != "" {
+				line += ", " + executed.Error
+			}
+			lines = append(lines, line)
+		}
+	}
+	// The data writer turns the line endings into CRLF and escapes lines
+	// starting with a dot
+	b.WriteString(strings.Join(lines, "\n") + "\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,119 @@
+// Package notify delivers pies events to the user, by webhook or by email,
+// as configured in the "notifications" section of the brokerage config.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// Config configures the notifiers, e.g.
+//
+//	{"webhooks": [{"url": "https://hooks.slack.com/..."}],
+//	 "email": {"host": "smtp.example.com", "port": 587, "from": "...", "to": ["..."]}}
+type Config struct {
+	Webhooks []WebhookConfig `json:"webhooks,omitempty"`
+	Email    *EmailConfig    `json:"email,omitempty"` // Nil sends no email
+}
+
+// New creates the notifier configured by raw, a JSON Config. It returns nil
+// when raw configures no notifier.
+func New(raw json.RawMessage) (pies.Notifier, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	var config Config
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("failed to parse notifications config: %w", err)
+	}
+
+	var notifiers Multi
+	for i, webhook := range config.Webhooks {
+		notifier, err := NewWebhook(webhook)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook %d: %w", i+1, err)
+		}
+		notifiers = append(notifiers, filter(notifier, webhook.Events))
+	}
+	if config.Email != nil {
+		notifier, err := NewEmail(*config.Email)
+		if err != nil {
+			return nil, fmt.Errorf("invalid email config: %w", err)
+		}
+		notifiers = append(notifiers, filter(notifier, config.Email.Events))
+	}
+
+	switch len(notifiers) {
+	case 0:
+		return nil, nil
+	case 1:
+		return notifiers[0], nil
+	default:
+		return notifiers, nil
+	}
+}
+
+// Multi sends every event to each of its notifiers, failing with all of
+// their errors joined
+type Multi []pies.Notifier
+
+func (m Multi) Notify(ctx context.Context, event pies.Event) error {
+	var errs []error
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// filtered passes on only the events of the listed kinds
+type filtered struct {
+	notifier pies.Notifier
+	kinds    []pies.EventKind
+}
+
+// eventKinds are the kinds of event that can be listed in a notifier's
+// events
+var eventKinds = []pies.EventKind{
+	pies.EventDriftBreach,
+	pies.EventPlanComputed,
+	pies.EventExecutionCompleted,
+	pies.EventExecutionFailed,
+	pies.EventTokenExpiring,
+}
+
+// checkKinds fails for kinds of event that do not exist
+func checkKinds(kinds []pies.EventKind) error {
+	for _, kind := range kinds {
+		if !slices.Contains(eventKinds, kind) {
+			return fmt.Errorf("unknown event %q", kind)
+		}
+	}
+	return nil
+}
+
+// filter restricts notifier to events of kinds, or passes on every event
+// when kinds is empty
+func filter(notifier pies.Notifier, kinds []pies.EventKind) pies.Notifier {
+	if len(kinds) == 0 {
+		return notifier
+	}
+	return filtered{notifier: notifier, kinds: kinds}
+}
+
+func (f filtered) Notify(ctx context.Context, event pies.Event) error {
+	if !slices.Contains(f.kinds, event.Kind) {
+		return nil
+	}
+	return f.notifier.Notify(ctx, event)
+}
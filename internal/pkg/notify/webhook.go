@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// defaultWebhookTimeout is how long a webhook may take to answer when
+// WebhookConfig.Timeout is zero
+const defaultWebhookTimeout = 10 * time.Second
+
+// WebhookConfig configures a webhook events are posted to
+type WebhookConfig struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"` // Added to every request, e.g. for authorization
+	Events  []pies.EventKind  `json:"events,omitempty"`  // Empty posts every event
+	Timeout string            `json:"timeout,omitempty"` // A Go duration, empty waits 10 seconds
+}
+
+// WebhookPayload is the JSON body posted for each event. Slack reads the
+// text field and Discord the content field, both holding the event's
+// message, while other services can read the whole event.
+type WebhookPayload struct {
+	Text    string     `json:"text"`
+	Content string     `json:"content"`
+	Event   pies.Event `json:"event"`
+}
+
+// Webhook posts events as a WebhookPayload
+type Webhook struct {
+	url        string
+	headers    map[string]string
+	httpClient *http.Client
+}
+
+// NewWebhook creates the webhook configured by config
+func NewWebhook(config WebhookConfig) (*Webhook, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil || u.Scheme != "https" && u.Scheme != "http" || u.Host == "" {
+		return nil, errors.New("url must be an http or https url")
+	}
+	if err := checkKinds(config.Events); err != nil {
+		return nil, err
+	}
+
+	timeout := defaultWebhookTimeout
+	if config.Timeout != "" {
+		timeout, err = time.ParseDuration(config.Timeout)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q", config.Timeout)
+		}
+	}
+
+	return &Webhook{
+		url:        config.URL,
+		headers:    config.Headers,
+		httpClient: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (w *Webhook) Notify(ctx context.Context, event pies.Event) error {
+	body, err := json.Marshal(WebhookPayload{Text: event.Message, Content: event.Message, Event: event})
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		// The url may hold a token, as Slack's and Discord's do, so the
+		// error is reported without it
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to post notification: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
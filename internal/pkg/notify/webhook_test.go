@@ -0,0 +1,298 @@
+package notify_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/notify"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var eventTime = time.Date(2025, time.March, 3, 14, 30, 0, 0, time.UTC)
+
+// hook is a webhook receiver recording the requests posted to it
+type hook struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newHook(t *testing.T, status int) *hook {
+	t.Helper()
+
+	h := &hook{}
+	h.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		h.mu.Lock()
+		h.requests = append(h.requests, r)
+		h.bodies = append(h.bodies, body)
+		h.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(h.Close)
+	return h
+}
+
+func (h *hook) posted() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.bodies)
+}
+
+func newWebhook(t *testing.T, config notify.WebhookConfig) *notify.Webhook {
+	t.Helper()
+
+	webhook, err := notify.NewWebhook(config)
+	if err != nil {
+		t.Fatalf("NewWebhook() error = %v", err)
+	}
+	return webhook
+}
+
+// requireGolden compares the indented JSON body with testdata/name, or
+// rewrites the file when the tests run with -update
+func requireGolden(t *testing.T, name string, body []byte) {
+	t.Helper()
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err != nil {
+		t.Fatalf("payload is not JSON: %v\n%s", err, body)
+	}
+	indented.WriteByte('\n')
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, indented.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file, run with -update to create it: %v", err)
+	}
+	if got := indented.String(); got != string(want) {
+		t.Errorf("%s changed, run with -update if that is intended\n got: %s\nwant: %s", path, got, want)
+	}
+}
+
+func goldenEvents() map[string]pies.Event {
+	quantity := decimal.NewFromInt(5)
+	price := decimal.NewFromInt(100)
+	sell := pies.OrderRequest{Symbol: "VTI", Action: pies.OrderActionSell, Type: pies.OrderTypeMarket, Quantity: quantity, Duration: pies.OrderDurationDay}
+	buy := pies.OrderRequest{Symbol: "BRK/B", Action: pies.OrderActionBuy, Type: pies.OrderTypeMarket, Quantity: quantity, Duration: pies.OrderDurationDay}
+	plan := &pies.RebalancePlan{
+		Pie:       "Three Fund",
+		PieID:     "three-fund",
+		AccountID: "12345678",
+		Orders: []pies.PlannedOrder{
+			{Order: sell, Price: price, Value: decimal.NewFromInt(500)},
+			{Order: buy, Price: price, Value: decimal.NewFromInt(500)},
+		},
+		Cash:      decimal.Zero,
+		CashAfter: decimal.Zero,
+	}
+	report := &pies.ExecutionReport{
+		Pie:        plan.Pie,
+		PieID:      plan.PieID,
+		AccountID:  plan.AccountID,
+		StartedAt:  eventTime,
+		FinishedAt: eventTime.Add(2 * time.Second),
+		Orders: []pies.ExecutedOrder{
+			{Planned: plan.Orders[0], Request: sell, OrderID: "1001", Status: pies.OrderStatusFilled, FilledQty: quantity, FilledPrice: price},
+			{Planned: plan.Orders[1], Request: buy, OrderID: "1002", Status: pies.OrderStatusRejected, Error: "insufficient buying power"},
+		},
+		SellProceeds: decimal.NewFromInt(500),
+		BuyingCash:   decimal.NewFromInt(500),
+	}
+	expiresAt := eventTime.Add(24 * time.Hour)
+
+	return map[string]pies.Event{
+		"drift-breach": {
+			Kind:      pies.EventDriftBreach,
+			Time:      eventTime,
+			Pie:       "Three Fund",
+			Message:   "Three Fund: VTI is outside its drift band",
+			Breaching: []string{"VTI"},
+		},
+		"plan-computed": {
+			Kind:    pies.EventPlanComputed,
+			Time:    eventTime,
+			Pie:     "Three Fund",
+			Message: "Three Fund: planned 2 orders",
+			Plan:    plan,
+		},
+		"execution-completed": {
+			Kind:    pies.EventExecutionCompleted,
+			Time:    eventTime,
+			Pie:     "Three Fund",
+			Message: "Three Fund: rebalanced, 2 orders placed",
+			Plan:    plan,
+			Report:  report,
+		},
+		"execution-failed": {
+			Kind:    pies.EventExecutionFailed,
+			Time:    eventTime,
+			Pie:     "Three Fund",
+			Message: "Three Fund: 1 of 2 orders failed",
+			Report:  report,
+			Error:   "BUY BRK/B: insufficient buying power",
+		},
+		"token-expiring": {
+			Kind:      pies.EventTokenExpiring,
+			Time:      eventTime,
+			Message:   "The schwab sign-in expires in 24 hours",
+			ExpiresAt: &expiresAt,
+		},
+	}
+}
+
+func TestWebhookPayloads(t *testing.T) {
+	for name, event := range goldenEvents() {
+		t.Run(name, func(t *testing.T) {
+			h := newHook(t, http.StatusOK)
+			webhook := newWebhook(t, notify.WebhookConfig{URL: h.URL})
+
+			if err := webhook.Notify(t.Context(), event); err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+			if h.posted() != 1 {
+				t.Fatalf("posted %d payloads, want 1", h.posted())
+			}
+			if got := h.requests[0].Header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			requireGolden(t, "webhook-"+name+".json", h.bodies[0])
+		})
+	}
+}
+
+func TestWebhookHeaders(t *testing.T) {
+	h := newHook(t, http.StatusNoContent)
+	webhook := newWebhook(t, notify.WebhookConfig{
+		URL:     h.URL,
+		Headers: map[string]string{"Authorization": "Bearer hook-token"},
+	})
+
+	if err := webhook.Notify(t.Context(), goldenEvents()["drift-breach"]); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if got := h.requests[0].Header.Get("Authorization"); got != "Bearer hook-token" {
+		t.Errorf("Authorization = %q, want the configured header", got)
+	}
+}
+
+func TestWebhookErrors(t *testing.T) {
+	h := newHook(t, http.StatusForbidden)
+	webhook := newWebhook(t, notify.WebhookConfig{URL: h.URL + "/services/secret-token"})
+
+	err := webhook.Notify(t.Context(), goldenEvents()["drift-breach"])
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Notify() error = %v, want the 403 status", err)
+	}
+
+	h.Close()
+	err = webhook.Notify(t.Context(), goldenEvents()["drift-breach"])
+	if err == nil {
+		t.Fatal("Notify() to a closed server succeeded, want an error")
+	}
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Notify() error = %v, quotes the webhook url", err)
+	}
+}
+
+func TestNewWebhookValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config notify.WebhookConfig
+	}{
+		{name: "no url", config: notify.WebhookConfig{}},
+		{name: "not http", config: notify.WebhookConfig{URL: "ftp://example.com/hook"}},
+		{name: "unknown event", config: notify.WebhookConfig{URL: "https://example.com/hook", Events: []pies.EventKind{"fill"}}},
+		{name: "bad timeout", config: notify.WebhookConfig{URL: "https://example.com/hook", Timeout: "soon"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := notify.NewWebhook(tt.config); err == nil {
+				t.Error("NewWebhook() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestNewFiltersEvents(t *testing.T) {
+	failing := newHook(t, http.StatusOK)
+	everything := newHook(t, http.StatusOK)
+	raw, _ := json.Marshal(notify.Config{Webhooks: []notify.WebhookConfig{
+		{URL: failing.URL, Events: []pies.EventKind{pies.EventExecutionFailed}},
+		{URL: everything.URL},
+	}})
+
+	notifier, err := notify.New(raw)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	for _, event := range goldenEvents() {
+		if err := notifier.Notify(t.Context(), event); err != nil {
+			t.Fatalf("Notify(%s) error = %v", event.Kind, err)
+		}
+	}
+
+	if got := failing.posted(); got != 1 {
+		t.Errorf("execution_failed webhook received %d events, want 1", got)
+	}
+	if got := everything.posted(); got != len(goldenEvents()) {
+		t.Errorf("unfiltered webhook received %d events, want %d", got, len(goldenEvents()))
+	}
+}
+
+func TestNewWithoutNotifiers(t *testing.T) {
+	for _, raw := range []string{"", "{}"} {
+		notifier, err := notify.New(json.RawMessage(raw))
+		if err != nil || notifier != nil {
+			t.Errorf("New(%q) = %v, %v, want no notifier", raw, notifier, err)
+		}
+	}
+
+	if _, err := notify.New(json.RawMessage(`{"slack": "https://hooks.slack.com"}`)); err == nil {
+		t.Error("New() with an unknown field succeeded, want an error")
+	}
+}
+
+func TestMultiJoinsErrors(t *testing.T) {
+	errOne, errTwo := errors.New("one"), errors.New("two")
+	multi := notify.Multi{failingNotifier{errOne}, failingNotifier{nil}, failingNotifier{errTwo}}
+
+	err := multi.Notify(t.Context(), goldenEvents()["drift-breach"])
+	if !errors.Is(err, errOne) || !errors.Is(err, errTwo) {
+		t.Errorf("Notify() error = %v, want both errors", err)
+	}
+}
+
+type failingNotifier struct {
+	err error
+}
+
+func (n failingNotifier) Notify(ctx context.Context, event pies.Event) error {
+	return n.err
+}
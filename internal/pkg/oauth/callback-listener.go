@@ -0,0 +1,164 @@
+// Package oauth implements the local HTTPS listener that receives the
+// redirect of an OAuth authorization code flow, for the brokerages that
+// sign in with one, and the parsing of codes pasted by hand on machines that
+// cannot receive the redirect.
+package oauth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// callback carries the outcome of the OAuth redirect
+type callback struct {
+	code string
+	err  error
+}
+
+// CallbackListener serves the OAuth redirect of a single authorization
+// attempt until it is closed
+type CallbackListener struct {
+	listener  net.Listener
+	server    *http.Server
+	callbacks chan callback
+	serveErr  chan error
+}
+
+// Listen starts an HTTPS listener on addr, serving the certificate in
+// certFile and keyFile, for the redirect to path of the authorization
+// attempt that issued state. Requests to other paths get a 404. Call Close
+// when done.
+func Listen(addr, path, certFile, keyFile, state string) (*CallbackListener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for oauth callback: %w", err)
+	}
+
+	l := &CallbackListener{
+		listener:  listener,
+		callbacks: make(chan callback, 1),
+		serveErr:  make(chan error, 1),
+	}
+	l.server = &http.Server{
+		Handler:           callbackHandler(path, state, l.callbacks),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		l.serveErr <- l.server.ServeTLS(listener, certFile, keyFile)
+	}()
+
+	return l, nil
+}
+
+// Addr returns the address the listener is serving on
+func (l *CallbackListener) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+// Wait waits for the redirect and returns its authorization code. A denied
+// authorization, a redirect carrying another attempt's state, a failed
+// server and ctx ending are all returned as errors.
+func (l *CallbackListener) Wait(ctx context.Context) (string, error) {
+	select {
+	case callback := <-l.callbacks:
+		return callback.code, callback.err
+	case err := <-l.serveErr:
+		return "", fmt.Errorf("oauth callback server failed: %w", err)
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("authorization timed out waiting for the oauth callback: %w", ctx.Err())
+		}
+		return "", fmt.Errorf("authorization cancelled: %w", ctx.Err())
+	}
+}
+
+// Close shuts the listener down, waiting up to 5 seconds for requests in
+// flight
+func (l *CallbackListener) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return l.server.Shutdown(ctx)
+}
+
+// callbackHandler handles the OAuth redirect to path, reporting the first
+// callback that carries a code or an error on callbacks. Later callbacks
+// are answered with a page saying the sign-in already completed.
+func callbackHandler(path, expectedState string, callbacks chan<- callback) http.Handler {
+	var completed atomic.Bool
+	report := func(c callback) bool {
+		if !completed.CompareAndSwap(false, true) {
+			return false
+		}
+		callbacks <- c
+		return true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		code := query.Get("code")
+		oauthErr := query.Get("error")
+		if code == "" && oauthErr == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if completed.Load() {
+			writePage(w, http.StatusOK, "Sign-in already completed", "This sign-in has already completed. You can close this window.")
+			return
+		}
+
+		switch {
+		case oauthErr != "":
+			if report(callback{err: fmt.Errorf("authorization denied: %s", oauthErr)}) {
+				writePage(w, http.StatusBadRequest, "Sign-in failed", "Authorization failed: "+oauthErr)
+				return
+			}
+		case !validState(query.Get("state"), expectedState):
+			if report(callback{err: errors.New("authorization callback state mismatch, refusing to exchange code")}) {
+				writePage(w, http.StatusBadRequest, "Sign-in failed", "The state parameter does not match this login attempt. Please restart the sign-in.")
+				return
+			}
+		default:
+			if report(callback{code: code}) {
+				writePage(w, http.StatusOK, "Sign-in completed", "Authentication completed. You can close this window.")
+				return
+			}
+		}
+		writePage(w, http.StatusOK, "Sign-in already completed", "This sign-in has already completed. You can close this window.")
+	})
+}
+
+// writePage writes an HTML page with title and message. The page never
+// repeats the query, so the code stays out of what the browser shows.
+func writePage(w http.ResponseWriter, status int, title, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>%[1]s</title></head>
+<body style="font-family: sans-serif; margin: 4em auto; max-width: 32em; text-align: center">
+<h1>%[1]s</h1>
+<p>%[2]s</p>
+</body>
+</html>
+`, html.EscapeString(title), html.EscapeString(message))
+}
+
+// validState reports whether the state returned in the callback matches the
+// one issued with the authorization URL
+func validState(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
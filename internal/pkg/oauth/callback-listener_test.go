@@ -0,0 +1,93 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serveCallback sends one redirect with query to handler and returns the
+// response
+func serveCallback(handler http.Handler, query string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/callback?"+query, nil))
+	return rec
+}
+
+func TestCallbackHandlerRejectsMismatchedState(t *testing.T) {
+	callbacks := make(chan callback, 1)
+	handler := callbackHandler("/callback", "expected-state", callbacks)
+
+	rec := serveCallback(handler, "code=the-code&state=other-state")
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	body := rec.Body.String()
+	if strings.Contains(body, "Sign-in completed") {
+		t.Errorf("mismatched state rendered the success page:\n%s", body)
+	}
+	if !strings.Contains(body, "does not match") {
+		t.Errorf("page does not explain the state mismatch:\n%s", body)
+	}
+
+	select {
+	case c := <-callbacks:
+		if c.err == nil || c.code != "" {
+			t.Errorf("callback = {code: %q, err: %v}, want a state mismatch error and no code", c.code, c.err)
+		}
+	default:
+		t.Fatal("mismatched state was not reported")
+	}
+}
+
+func TestCallbackHandlerAcceptsMatchingState(t *testing.T) {
+	callbacks := make(chan callback, 1)
+	handler := callbackHandler("/callback", "expected-state", callbacks)
+
+	rec := serveCallback(handler, "code=the-code&state=expected-state")
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "Sign-in completed") {
+		t.Errorf("want the success page, got:\n%s", body)
+	}
+	if strings.Contains(body, "the-code") {
+		t.Errorf("page repeats the authorization code:\n%s", body)
+	}
+
+	c := <-callbacks
+	if c.err != nil || c.code != "the-code" {
+		t.Errorf("callback = {code: %q, err: %v}, want code the-code", c.code, c.err)
+	}
+
+	// A second redirect, e.g. a reload, is not reported again
+	rec = serveCallback(handler, "code=the-code&state=expected-state")
+	if !strings.Contains(rec.Body.String(), "already completed") {
+		t.Errorf("second callback page = %q, want the already completed page", rec.Body.String())
+	}
+	select {
+	case c := <-callbacks:
+		t.Errorf("second callback was reported: %+v", c)
+	default:
+	}
+}
+
+func TestCallbackHandlerIgnoresOtherRequests(t *testing.T) {
+	callbacks := make(chan callback, 1)
+	handler := callbackHandler("/callback", "expected-state", callbacks)
+
+	for _, target := range []string{"/favicon.ico", "/callback", "/callback?state=expected-state"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s status = %d, want %d", target, rec.Code, http.StatusNotFound)
+		}
+	}
+	if len(callbacks) != 0 {
+		t.Errorf("requests without a code or error were reported")
+	}
+}
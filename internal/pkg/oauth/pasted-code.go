@@ -0,0 +1,76 @@
+package oauth
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrMalformedPaste is matched by the errors of ParsePastedCode for input
+// holding no usable code, which is worth asking for again
+var ErrMalformedPaste = errors.New("no authorization code in the pasted text")
+
+// ParsePastedCode extracts the authorization code from text pasted by the
+// user: either the whole URL the browser was redirected to, its query, or
+// the bare code. Surrounding whitespace and quotes are ignored, and the code
+// is URL-decoded. A redirect carrying a state other than expectedState, or
+// carrying an authorization error, fails without matching ErrMalformedPaste.
+func ParsePastedCode(text, expectedState string) (string, error) {
+	text = strings.Trim(strings.TrimSpace(text), "\"'`<>")
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", fmt.Errorf("%w: nothing was pasted", ErrMalformedPaste)
+	}
+
+	rawQuery := text
+	switch {
+	case strings.Contains(text, "://"):
+		u, err := url.Parse(text)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrMalformedPaste, err)
+		}
+		rawQuery = u.RawQuery
+	case strings.HasPrefix(text, "?"):
+		rawQuery = text[1:]
+	case !strings.HasPrefix(text, "code=") && !strings.Contains(text, "&code=") && !strings.HasPrefix(text, "error="):
+		// A bare code, possibly followed by the rest of the query such as
+		// &session=...
+		code, _, _ := strings.Cut(text, "&")
+		return decodeCode(code)
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMalformedPaste, err)
+	}
+	if oauthErr := query.Get("error"); oauthErr != "" {
+		return "", fmt.Errorf("authorization denied: %s", oauthErr)
+	}
+	if state := query.Get("state"); state != "" && !validState(state, expectedState) {
+		return "", errors.New("pasted redirect is for another login attempt, its state does not match")
+	}
+	code := query.Get("code")
+	if code == "" {
+		return "", fmt.Errorf("%w: the url has no code parameter", ErrMalformedPaste)
+	}
+	return checkCode(code)
+}
+
+// decodeCode URL-decodes a bare pasted code
+func decodeCode(code string) (string, error) {
+	decoded, err := url.QueryUnescape(code)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMalformedPaste, err)
+	}
+	return checkCode(decoded)
+}
+
+// checkCode fails for codes that cannot have been issued, such as ones
+// holding whitespace left by a line break in the paste
+func checkCode(code string) (string, error) {
+	if strings.ContainsAny(code, " \t\r\n") {
+		return "", fmt.Errorf("%w: the code contains whitespace", ErrMalformedPaste)
+	}
+	return code, nil
+}
@@ -0,0 +1,67 @@
+package oauth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Redirect is where a registered redirect URI sends the authorization
+// callback
+type Redirect struct {
+	Addr     string // The redirect URI's host and port, 443 when it has none
+	Path     string // The path the callback arrives on, "/" when it has none
+	Secure   bool   // Whether the redirect URI is https
+	Loopback bool   // Whether the redirect URI's host is this machine
+}
+
+// ParseRedirectURI returns where the redirect URI raw sends the
+// authorization callback
+func ParseRedirectURI(raw string) (Redirect, error) {
+	if raw == "" {
+		return Redirect{}, errors.New("redirect uri not configured")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Redirect{}, fmt.Errorf("failed to parse redirect uri %q: %w", raw, err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" || u.Hostname() == "" {
+		return Redirect{}, fmt.Errorf("redirect uri %q is not an http or https url", raw)
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	host := u.Hostname()
+	ip := net.ParseIP(host)
+	return Redirect{
+		Addr:     net.JoinHostPort(host, port),
+		Path:     path,
+		Secure:   u.Scheme == "https",
+		Loopback: strings.EqualFold(host, "localhost") || ip != nil && ip.IsLoopback(),
+	}, nil
+}
+
+// Warnings describes what about the redirect may keep the callback from
+// reaching the listener safely
+func (r Redirect) Warnings() []string {
+	var warnings []string
+	if !r.Secure {
+		warnings = append(warnings, "the redirect uri is not https, so the authorization code travels unencrypted")
+	}
+	if !r.Loopback {
+		warnings = append(warnings, "the redirect uri does not point at this machine, so the callback only arrives here through a proxy forwarding it")
+	}
+	return warnings
+}
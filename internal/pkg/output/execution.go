@@ -0,0 +1,100 @@
+package output
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+// Confirm asks question on w and reports whether the answer read from r is
+// yes
+func Confirm(w io.Writer, r io.Reader, question string) (bool, error) {
+	fmt.Fprintf(w, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// OrderSize formats the size of an order: its shares, or its dollar value
+// for orders sized by Amount
+func OrderSize(order pies.OrderRequest) string {
+	if order.Amount != nil {
+		return "$" + order.Amount.StringFixed(2)
+	}
+	return order.Quantity.String()
+}
+
+// Progress returns a pies.ExecuteOptions.OnUpdate callback writing a line
+// to w as each order is placed and as it settles
+func Progress(w io.Writer) func(pies.ExecutedOrder) {
+	return func(executed pies.ExecutedOrder) {
+		order := executed.Request
+		switch {
+		case executed.Duplicate:
+			fmt.Fprintf(w, "%s %s %s not placed, it repeats an order placed moments before\n", order.Action, OrderSize(order), order.Symbol)
+		case executed.Error != "":
+			fmt.Fprintf(w, "%s %s %s failed: %s\n", order.Action, OrderSize(order), order.Symbol, executed.Error)
+		case executed.Status == pies.OrderStatusFilled:
+			fmt.Fprintf(w, "%s %s %s filled at %s\n", order.Action, executed.FilledQty, order.Symbol, executed.FilledPrice.StringFixed(2))
+		default:
+			fmt.Fprintf(w, "%s %s %s %s, order %s\n", order.Action, OrderSize(order), order.Symbol, strings.ToLower(string(executed.Status)), executed.OrderID)
+		}
+	}
+}
+
+// PrintReport writes the outcome of every order of an executed plan followed
+// by the totals, the risk warnings, the trades skipped and the orders that
+// failed or were refused as duplicates
+func PrintReport(w io.Writer, report *pies.ExecutionReport) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Symbol\tAction\tOrdered\tStatus\tFilled\tPrice\tValue\t")
+	var bought, sold decimal.Decimal
+	for _, executed := range report.Orders {
+		status := string(executed.Status)
+		switch {
+		case executed.Duplicate:
+			status = "DUPLICATE"
+		case executed.Error != "":
+			status = "FAILED"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			executed.Request.Symbol, executed.Request.Action, OrderSize(executed.Request), status,
+			executed.FilledQty, executed.FilledPrice.StringFixed(2), executed.FilledValue().StringFixed(2))
+		if executed.Request.Action == pies.OrderActionSell {
+			sold = sold.Add(executed.FilledValue())
+		} else {
+			bought = bought.Add(executed.FilledValue())
+		}
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nSold: %s\n", sold.StringFixed(2))
+	fmt.Fprintf(w, "Bought: %s\n", bought.StringFixed(2))
+	for _, warning := range report.RiskWarnings {
+		fmt.Fprintf(w, "Risk warning: %s\n", warning.Message)
+	}
+	for _, skipped := range report.Skipped {
+		fmt.Fprintf(w, "Skipped %s %s %s: %s\n", skipped.Action, skipped.Quantity, skipped.Symbol, skipped.Reason)
+	}
+	for _, executed := range report.Orders {
+		switch {
+		case executed.Duplicate:
+			fmt.Fprintf(w, "%s %s not placed: %s\n", executed.Request.Action, executed.Request.Symbol, executed.Error)
+		case executed.Error != "":
+			fmt.Fprintf(w, "%s %s failed: %s\n", executed.Request.Action, executed.Request.Symbol, executed.Error)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Package output lets commands write their results either as text for
+// people or as JSON for scripts. JSON output is the command's result struct
+// encoded with its snake_case tags: money and share quantities are strings
+// holding the exact decimal, weights are numbers in percent and times are
+// RFC 3339. Fields are only ever added, so scripts can rely on the existing
+// ones.
+//
+// It also holds the text reports shared by the commands that execute
+// rebalance plans.
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Format selects how a command writes its result
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+	FormatCSV  Format = "csv" // Only accepted by the flag of FlagWithCSV
+)
+
+// Flag registers the --output flag on flags and returns the format it
+// selects, text by default
+func Flag(flags *flag.FlagSet) *Format {
+	format := FormatText
+	flags.Var(&formatFlag{format: &format}, "output", "output format, text or json")
+	return &format
+}
+
+// FlagWithCSV is Flag for commands that can also write their result as CSV
+func FlagWithCSV(flags *flag.FlagSet) *Format {
+	format := FormatText
+	flags.Var(&formatFlag{format: &format, csv: true}, "output", "output format, text, json or csv")
+	return &format
+}
+
+// formatFlag is the flag.Value of --output
+type formatFlag struct {
+	format *Format
+	csv    bool // Whether csv is accepted
+}
+
+func (f *formatFlag) String() string {
+	if f.format == nil {
+		return ""
+	}
+	return string(*f.format)
+}
+
+func (f *formatFlag) Set(value string) error {
+	switch Format(value) {
+	case FormatText, FormatJSON:
+		*f.format = Format(value)
+		return nil
+	case FormatCSV:
+		if f.csv {
+			*f.format = FormatCSV
+			return nil
+		}
+		return errors.New("csv output is not supported, want text or json")
+	default:
+		if f.csv {
+			return fmt.Errorf("unknown output format %q, want text, json or csv", value)
+		}
+		return fmt.Errorf("unknown output format %q, want text or json", value)
+	}
+}
+
+// IsJSON reports whether the result is written as JSON
+func (f Format) IsJSON() bool {
+	return f == FormatJSON
+}
+
+// IsCSV reports whether the result is written as CSV
+func (f Format) IsCSV() bool {
+	return f == FormatCSV
+}
+
+// Messages returns where to write everything but the result: stdout for
+// text, stderr for JSON and CSV so that stdout holds nothing but the result
+func (f Format) Messages() io.Writer {
+	if f.IsJSON() || f.IsCSV() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// WriteJSON writes v to w as indented JSON followed by a newline
+func WriteJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to write json output: %w", err)
+	}
+	return nil
+}
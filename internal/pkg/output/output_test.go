@@ -0,0 +1,138 @@
+package output_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/output"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/alysoliman1/money-pies/pkg/pies/piestest"
+	"github.com/shopspring/decimal"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// requireGolden compares v written as JSON with testdata/name, or rewrites
+// the file when the tests run with -update
+func requireGolden(t *testing.T, name string, v any) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := output.WriteJSON(&buf, v); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file, run with -update to create it: %v", err)
+	}
+	if got := buf.String(); got != string(want) {
+		t.Errorf("%s changed, run with -update if that is intended\n got: %s\nwant: %s", path, got, want)
+	}
+}
+
+// goldenInvestor returns an investor over a three fund portfolio whose
+// first slice is five points overweight
+func goldenInvestor() (*pies.Investor, pies.Pie) {
+	pie := pies.Pie{
+		Name: "Three Fund",
+		ID:   "three-fund",
+		Slices: []pies.Slice{
+			{Weight: 60, Asset: pies.Asset{Symbol: "VTI"}},
+			{Weight: 30, Asset: pies.Asset{Symbol: "VXUS"}},
+			{Weight: 10, Asset: pies.Asset{Symbol: "BND"}},
+		},
+	}
+	b := piestest.DriftedPortfolio(pie, decimal.NewFromInt(10_000), 5)
+	return &pies.Investor{BrokerageClient: b}, pie
+}
+
+func TestAccountsJSON(t *testing.T) {
+	investor, _ := goldenInvestor()
+	accounts, err := investor.GetAccounts(t.Context())
+	if err != nil {
+		t.Fatalf("GetAccounts() error = %v", err)
+	}
+	requireGolden(t, "accounts.json", accounts)
+}
+
+func TestPieStatusJSON(t *testing.T) {
+	investor, pie := goldenInvestor()
+	status, err := investor.GetPieStatus(t.Context(), pie)
+	if err != nil {
+		t.Fatalf("GetPieStatus() error = %v", err)
+	}
+	requireGolden(t, "pie-status.json", status)
+}
+
+func TestRebalancePlanJSON(t *testing.T) {
+	investor, pie := goldenInvestor()
+	plan, err := investor.ComputeRebalancePlan(t.Context(), pie, pies.RebalanceOptions{AllowSells: true})
+	if err != nil {
+		t.Fatalf("ComputeRebalancePlan() error = %v", err)
+	}
+	requireGolden(t, "rebalance-plan.json", plan)
+}
+
+func TestExecutionReportJSON(t *testing.T) {
+	investor, pie := goldenInvestor()
+	plan, err := investor.ComputeRebalancePlan(t.Context(), pie, pies.RebalanceOptions{AllowSells: true})
+	if err != nil {
+		t.Fatalf("ComputeRebalancePlan() error = %v", err)
+	}
+	report, err := investor.ExecutePlan(t.Context(), plan, pies.ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("ExecutePlan() error = %v", err)
+	}
+
+	// The report is stamped with the wall clock
+	report.StartedAt = piestest.Epoch
+	report.FinishedAt = piestest.Epoch
+	requireGolden(t, "execution-report.json", report)
+}
+
+func TestFlag(t *testing.T) {
+	tests := []struct {
+		args    []string
+		csv     bool
+		want    output.Format
+		wantErr bool
+	}{
+		{args: nil, want: output.FormatText},
+		{args: []string{"--output", "json"}, want: output.FormatJSON},
+		{args: []string{"--output", "csv"}, wantErr: true},
+		{args: []string{"--output", "csv"}, csv: true, want: output.FormatCSV},
+		{args: []string{"--output", "yaml"}, csv: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags.SetOutput(&bytes.Buffer{})
+		var format *output.Format
+		if tt.csv {
+			format = output.FlagWithCSV(flags)
+		} else {
+			format = output.Flag(flags)
+		}
+
+		err := flags.Parse(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%v) error = %v, wantErr %t", tt.args, err, tt.wantErr)
+			continue
+		}
+		if err == nil && *format != tt.want {
+			t.Errorf("Parse(%v) format = %s, want %s", tt.args, *format, tt.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"unicode"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// schemaVersionKey holds the schema version in the meta bucket
+var schemaVersionKey = []byte("schema_version")
+
+// migrations bring the database from one schema version to the next:
+// migrations[n] upgrades version n to n+1. A new database is at version 0.
+// Append to the list to change the schema, never edit a released migration.
+var migrations = []func(tx *bolt.Tx) error{
+	createBuckets,
+	snakeCaseSnapshots,
+}
+
+// migrate runs every migration the database has not had yet
+func migrate(tx *bolt.Tx) error {
+	meta, err := tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return fmt.Errorf("failed to create meta bucket: %w", err)
+	}
+
+	var version uint64
+	if raw := meta.Get(schemaVersionKey); raw != nil {
+		version = binary.BigEndian.Uint64(raw)
+	}
+	if version > uint64(len(migrations)) {
+		return fmt.Errorf("schema version %d is newer than the supported version %d", version, len(migrations))
+	}
+
+	for ; version < uint64(len(migrations)); version++ {
+		if err := migrations[version](tx); err != nil {
+			return fmt.Errorf("failed to migrate schema to version %d: %w", version+1, err)
+		}
+	}
+
+	raw := make([]byte, 8)
+	binary.BigEndian.PutUint64(raw, version)
+	return meta.Put(schemaVersionKey, raw)
+}
+
+// createBuckets sets up the first version of the schema
+func createBuckets(tx *bolt.Tx) error {
+	for _, name := range [][]byte{piesBucket, plansBucket, executionsBucket, snapshotsBucket} {
+		if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+			return fmt.Errorf("failed to create %s bucket: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// snakeCaseSnapshots renames the keys of the statuses in snapshot records,
+// saved before pies.PieStatus had JSON tags, from the Go field names to the
+// snake_case tags
+func snakeCaseSnapshots(tx *bolt.Tx) error {
+	return tx.Bucket(snapshotsBucket).ForEachBucket(func(pie []byte) error {
+		records := tx.Bucket(snapshotsBucket).Bucket(pie)
+
+		// Bolt buckets must not be modified while iterating over them
+		updated := make(map[string][]byte)
+		err := records.ForEach(func(key, raw []byte) error {
+			var record map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &record); err != nil {
+				return fmt.Errorf("snapshot of pie %s: %w", pie, err)
+			}
+			var status any
+			if err := json.Unmarshal(record["status"], &status); err != nil {
+				return fmt.Errorf("snapshot of pie %s: %w", pie, err)
+			}
+			rawStatus, err := json.Marshal(snakeCaseKeys(status))
+			if err != nil {
+				return fmt.Errorf("snapshot of pie %s: %w", pie, err)
+			}
+			record["status"] = rawStatus
+
+			if updated[string(key)], err = json.Marshal(record); err != nil {
+				return fmt.Errorf("snapshot of pie %s: %w", pie, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for key, raw := range updated {
+			if err := records.Put([]byte(key), raw); err != nil {
+				return fmt.Errorf("failed to save snapshot of pie %s: %w", pie, err)
+			}
+		}
+		return nil
+	})
+}
+
+// snakeCaseKeys renames the keys of every object in v with snakeCase
+func snakeCaseKeys(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		renamed := make(map[string]any, len(v))
+		for key, value := range v {
+			renamed[snakeCase(key)] = snakeCaseKeys(value)
+		}
+		return renamed
+	case []any:
+		for i, value := range v {
+			v[i] = snakeCaseKeys(value)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// snakeCase turns a Go field name into its snake_case JSON tag, keeping
+// initialisms together: PieID becomes pie_id and UnrealizedPLPct becomes
+// unrealized_pl_pct
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var snake []rune
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 &&
+			(unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			snake = append(snake, '_')
+		}
+		snake = append(snake, unicode.ToLower(r))
+	}
+	return string(snake)
+}
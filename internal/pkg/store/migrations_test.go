@@ -0,0 +1,113 @@
+package store
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// createAtVersion creates a database migrated to version, then runs seed
+// against it
+func createAtVersion(t *testing.T, version int, seed func(tx *bolt.Tx) error) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "pies.db")
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations[:min(version, len(migrations))] {
+			if err := migration(tx); err != nil {
+				return err
+			}
+		}
+		raw := make([]byte, 8)
+		binary.BigEndian.PutUint64(raw, uint64(version))
+		if err := meta.Put(schemaVersionKey, raw); err != nil {
+			return err
+		}
+		if seed != nil {
+			return seed(tx)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to set up version %d: %v", version, err)
+	}
+	return path
+}
+
+func TestOpenRefusesNewerSchema(t *testing.T) {
+	path := createAtVersion(t, len(migrations)+1, nil)
+
+	_, err := Open(path)
+	if err == nil || !strings.Contains(err.Error(), "is newer than the supported version") {
+		t.Errorf("Open() error = %v, want the newer schema refused", err)
+	}
+}
+
+func TestOpenMigratesSnapshotKeys(t *testing.T) {
+	// Version 1 saved statuses with Go field names
+	path := createAtVersion(t, 1, func(tx *bolt.Tx) error {
+		records, err := tx.Bucket(snapshotsBucket).CreateBucket([]byte("core"))
+		if err != nil {
+			return err
+		}
+		return records.Put(recordKey(time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC), 1), []byte(`{"time":"2026-03-02T15:00:00Z","status":{"Pie":"Core","PieID":"core","PieValue":"1000","Slices":[{"Symbol":"VTI","CurrentWeight":100}]}}`))
+	})
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { s.Close() }()
+
+	snapshots, err := s.Snapshots("core")
+	if err != nil {
+		t.Fatalf("Snapshots() error = %v", err)
+	}
+	if len(snapshots) != 1 {
+		t.Fatalf("Snapshots() = %+v, want one", snapshots)
+	}
+	status := snapshots[0].Status
+	if status.PieID != "core" || status.PieValue.String() != "1000" || len(status.Slices) != 1 || status.Slices[0].CurrentWeight != 100 {
+		t.Errorf("migrated status = %+v, want its fields read from the renamed keys", status)
+	}
+
+	// Opening again does not run the migration twice
+	s.Close()
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("second Open() error = %v", err)
+	}
+	if snapshots, err := s.Snapshots("core"); err != nil || len(snapshots) != 1 || snapshots[0].Status.PieID != "core" {
+		t.Errorf("Snapshots() after reopening = %+v, %v, want the migrated snapshot", snapshots, err)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"Pie":             "pie",
+		"PieID":           "pie_id",
+		"CurrentWeight":   "current_weight",
+		"UnrealizedPLPct": "unrealized_pl_pct",
+		"DayPL":           "day_pl",
+		"IsCash":          "is_cash",
+	}
+	for name, want := range tests {
+		if got := snakeCase(name); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Package store keeps pies, rebalance plans, execution reports and pie status
+// snapshots in a bbolt database, so that there is a record of every
+// rebalance once it is done. Records are kept per pie, by pie ID or by name
+// for pies without one, in the order they were saved.
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	bolt "go.etcd.io/bbolt"
+)
+
+// openTimeout is how long Open waits for another process to release the
+// database before giving up
+const openTimeout = time.Second
+
+// ErrNotFound is returned when the store holds no record by the given key
+var ErrNotFound = errors.New("not found")
+
+var (
+	metaBucket       = []byte("meta")
+	piesBucket       = []byte("pies")
+	plansBucket      = []byte("plans")
+	executionsBucket = []byte("executions")
+	snapshotsBucket  = []byte("snapshots")
+)
+
+// Store is a database of pies and their history. It is safe for concurrent
+// use, but only one process can open the database at a time.
+type Store struct {
+	db *bolt.DB
+}
+
+// PlanRecord is a rebalance plan and when it was saved
+type PlanRecord struct {
+	Time time.Time           `json:"time"`
+	Plan *pies.RebalancePlan `json:"plan"`
+}
+
+// ExecutionRecord is an executed plan, what happened to its orders and when
+// the execution started
+type ExecutionRecord struct {
+	Time   time.Time             `json:"time"`
+	Plan   *pies.RebalancePlan   `json:"plan"`
+	Report *pies.ExecutionReport `json:"report"`
+}
+
+// SnapshotRecord is a pie's status at a point in time
+type SnapshotRecord struct {
+	Time   time.Time       `json:"time"`
+	Status *pies.PieStatus `json:"status"`
+}
+
+// Open opens the database at path, creating it if needed, and brings its
+// schema up to date
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store %s: %w", path, err)
+	}
+
+	if err := db.Update(migrate); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate store %s: %w", path, err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close releases the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// SavePie validates the pie and saves it, replacing any pie with the same
+// key
+func (s *Store) SavePie(pie pies.Pie) error {
+	if err := pie.Validate(); err != nil {
+		return fmt.Errorf("invalid pie %s: %w", pie.Name, err)
+	}
+
+	raw, err := json.Marshal(pie)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pie: %w", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(piesBucket).Put([]byte(pieKey(pie.ID, pie.Name)), raw)
+	})
+}
+
+// LoadPie returns the pie saved under key, its ID or its name if it has no
+// ID
+func (s *Store) LoadPie(key string) (pies.Pie, error) {
+	var pie pies.Pie
+	err := s.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(piesBucket).Get([]byte(key))
+		if raw == nil {
+			return fmt.Errorf("pie %s: %w", key, ErrNotFound)
+		}
+		return json.Unmarshal(raw, &pie)
+	})
+	return pie, err
+}
+
+// Pies returns every saved pie, ordered by key
+func (s *Store) Pies() ([]pies.Pie, error) {
+	var all []pies.Pie
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(piesBucket).ForEach(func(key, raw []byte) error {
+			var pie pies.Pie
+			if err := json.Unmarshal(raw, &pie); err != nil {
+				return fmt.Errorf("pie %s: %w", key, err)
+			}
+			all = append(all, pie)
+			return nil
+		})
+	})
+	return all, err
+}
+
+// SavePlan records a rebalance plan
+func (s *Store) SavePlan(plan *pies.RebalancePlan) error {
+	record := PlanRecord{Time: time.Now(), Plan: plan}
+	return s.append(plansBucket, pieKey(plan.PieID, plan.Pie), record.Time, record)
+}
+
+// Plans returns the plans saved for the pie with key, or for every pie if
+// key is empty, oldest first
+func (s *Store) Plans(key string) ([]PlanRecord, error) {
+	return list[PlanRecord](s, plansBucket, key, func(r PlanRecord) time.Time { return r.Time })
+}
+
+// SaveExecution records an executed plan and its report, implementing
+// pies.HistoryStore
+func (s *Store) SaveExecution(plan *pies.RebalancePlan, report *pies.ExecutionReport) error {
+	record := ExecutionRecord{Time: report.StartedAt, Plan: plan, Report: report}
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	return s.append(executionsBucket, pieKey(report.PieID, report.Pie), record.Time, record)
+}
+
+// Executions returns the executions saved for the pie with key, or for every
+// pie if key is empty, oldest first
+func (s *Store) Executions(key string) ([]ExecutionRecord, error) {
+	return list[ExecutionRecord](s, executionsBucket, key, func(r ExecutionRecord) time.Time { return r.Time })
+}
+
+// SaveSnapshot records a pie's current status
+func (s *Store) SaveSnapshot(status *pies.PieStatus) error {
+	record := SnapshotRecord{Time: time.Now(), Status: status}
+	return s.append(snapshotsBucket, pieKey(status.PieID, status.Pie), record.Time, record)
+}
+
+// Snapshots returns the snapshots saved for the pie with key, or for every
+// pie if key is empty, oldest first
+func (s *Store) Snapshots(key string) ([]SnapshotRecord, error) {
+	return list[SnapshotRecord](s, snapshotsBucket, key, func(r SnapshotRecord) time.Time { return r.Time })
+}
+
+// append adds record to the pie's sub-bucket of bucket, keyed by time and a
+// sequence number so records saved in the same instant are all kept
+func (s *Store) append(bucket []byte, pie string, at time.Time, record any) error {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s record: %w", bucket, err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		records, err := tx.Bucket(bucket).CreateBucketIfNotExists([]byte(pie))
+		if err != nil {
+			return fmt.Errorf("failed to create %s bucket for pie %s: %w", bucket, pie, err)
+		}
+		seq, err := records.NextSequence()
+		if err != nil {
+			return fmt.Errorf("failed to number %s record: %w", bucket, err)
+		}
+		return records.Put(recordKey(at, seq), raw)
+	})
+}
+
+// list decodes the records of the pie's sub-bucket of bucket, or of every
+// pie's if pie is empty, ordered by time
+func list[T any](s *Store, bucket []byte, pie string, at func(T) time.Time) ([]T, error) {
+	var records []T
+	err := s.db.View(func(tx *bolt.Tx) error {
+		decode := func(pie []byte, sub *bolt.Bucket) error {
+			return sub.ForEach(func(key, raw []byte) error {
+				var record T
+				if err := json.Unmarshal(raw, &record); err != nil {
+					return fmt.Errorf("%s record of pie %s: %w", bucket, pie, err)
+				}
+				records = append(records, record)
+				return nil
+			})
+		}
+
+		if pie != "" {
+			sub := tx.Bucket(bucket).Bucket([]byte(pie))
+			if sub == nil {
+				return nil
+			}
+			return decode([]byte(pie), sub)
+		}
+		return tx.Bucket(bucket).ForEachBucket(func(pie []byte) error {
+			return decode(pie, tx.Bucket(bucket).Bucket(pie))
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(records, func(a, b int) bool {
+		return at(records[a]).Before(at(records[b]))
+	})
+	return records, nil
+}
+
+// pieKey is the key records of a pie are kept under
+func pieKey(id, name string) string {
+	if id != "" {
+		return id
+	}
+	return name
+}
+
+// recordKey sorts records by time, then by the order they were saved in
+func recordKey(at time.Time, seq uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[:8], uint64(at.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
@@ -0,0 +1,292 @@
+package store_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/store"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+// openStore opens a new store in a temporary directory, closed when the test
+// ends
+func openStore(t *testing.T) (*store.Store, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "pies.db")
+	s, err := store.Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, path
+}
+
+// requireSameJSON fails the test unless got and want marshal to the same
+// JSON, which compares decimals by value rather than by representation
+func requireSameJSON(t *testing.T, got, want any) {
+	t.Helper()
+
+	rawGot, err := json.Marshal(got)
+	if err != nil {
+		t.Fatalf("failed to marshal %+v: %v", got, err)
+	}
+	rawWant, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("failed to marshal %+v: %v", want, err)
+	}
+	if string(rawGot) != string(rawWant) {
+		t.Errorf("got  %s\nwant %s", rawGot, rawWant)
+	}
+}
+
+// testPlan returns a plan for the pie with id buying quantity shares of VTI
+func testPlan(id string, quantity int64) *pies.RebalancePlan {
+	order := pies.OrderRequest{
+		Symbol:   "VTI",
+		Action:   pies.OrderActionBuy,
+		Type:     pies.OrderTypeMarket,
+		Quantity: decimal.NewFromInt(quantity),
+		Duration: pies.OrderDurationDay,
+	}
+	return &pies.RebalancePlan{
+		Pie:       "Core",
+		PieID:     id,
+		AccountID: "123",
+		Orders:    []pies.PlannedOrder{{Order: order, Price: decimal.RequireFromString("250.25"), Value: decimal.NewFromInt(quantity).Mul(decimal.RequireFromString("250.25"))}},
+		Cash:      decimal.NewFromInt(10_000),
+		CashAfter: decimal.NewFromInt(10_000).Sub(decimal.NewFromInt(quantity).Mul(decimal.RequireFromString("250.25"))),
+	}
+}
+
+func TestPieRoundTrip(t *testing.T) {
+	s, path := openStore(t)
+
+	pie := pies.Pie{
+		ID:          "core",
+		Name:        "Core",
+		Description: "Three fund pie",
+		Band:        pies.DriftBand{Absolute: 5},
+		Benchmarks:  []string{"VT"},
+		Risk:        &pies.RiskLimits{MaxOrderValue: pies.RiskRule{Limit: 10_000}},
+		Slices: []pies.Slice{
+			{Weight: 60, Asset: pies.Asset{Symbol: "VTI", Name: "Total Stock Market"}, Band: &pies.DriftBand{Relative: 0.25}},
+			{Weight: 40, Asset: pies.Asset{Symbol: "BND"}},
+		},
+	}
+	unnamed := pies.Pie{Name: "Satellite", Slices: []pies.Slice{{Weight: 100, Asset: pies.Asset{Symbol: "QQQ"}}}}
+	for _, p := range []pies.Pie{pie, unnamed} {
+		if err := s.SavePie(p); err != nil {
+			t.Fatalf("SavePie(%s) error = %v", p.Name, err)
+		}
+	}
+
+	// Pies survive reopening the database
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	s, err := store.Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+
+	loaded, err := s.LoadPie("core")
+	if err != nil {
+		t.Fatalf("LoadPie() error = %v", err)
+	}
+	requireSameJSON(t, loaded, pie)
+
+	// A pie without an ID is kept by name
+	if loaded, err := s.LoadPie("Satellite"); err != nil || loaded.Name != "Satellite" {
+		t.Errorf("LoadPie(Satellite) = %+v, %v, want the pie saved by name", loaded, err)
+	}
+	if _, err := s.LoadPie("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("LoadPie(missing) error = %v, want ErrNotFound", err)
+	}
+
+	all, err := s.Pies()
+	if err != nil {
+		t.Fatalf("Pies() error = %v", err)
+	}
+	if len(all) != 2 || all[0].Name != "Satellite" || all[1].Name != "Core" {
+		t.Errorf("Pies() = %+v, want Satellite and Core in key order", all)
+	}
+}
+
+func TestSavePieRejectsInvalidPie(t *testing.T) {
+	s, _ := openStore(t)
+
+	pie := pies.Pie{Name: "bad", Slices: []pies.Slice{{Weight: 50, Asset: pies.Asset{Symbol: "VTI"}}}}
+	if err := s.SavePie(pie); err == nil {
+		t.Fatal("SavePie() of a pie summing to 50 error = nil")
+	}
+	if all, err := s.Pies(); err != nil || len(all) != 0 {
+		t.Errorf("Pies() = %+v, %v, want none", all, err)
+	}
+}
+
+func TestHistoryRoundTrip(t *testing.T) {
+	s, _ := openStore(t)
+
+	plan := testPlan("core", 4)
+	other := testPlan("other", 2)
+	for _, p := range []*pies.RebalancePlan{plan, other} {
+		if err := s.SavePlan(p); err != nil {
+			t.Fatalf("SavePlan() error = %v", err)
+		}
+	}
+
+	started := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	report := &pies.ExecutionReport{
+		Pie:        plan.Pie,
+		PieID:      plan.PieID,
+		AccountID:  plan.AccountID,
+		StartedAt:  started,
+		FinishedAt: started.Add(time.Minute),
+		Orders: []pies.ExecutedOrder{{
+			Planned:     plan.Orders[0],
+			Request:     plan.Orders[0].Order,
+			OrderID:     "1001",
+			Status:      pies.OrderStatusFilled,
+			FilledQty:   decimal.NewFromInt(4),
+			FilledPrice: decimal.RequireFromString("250.10"),
+		}},
+		SellProceeds: decimal.Zero,
+		BuyingCash:   decimal.NewFromInt(10_000),
+	}
+	if err := s.SaveExecution(plan, report); err != nil {
+		t.Fatalf("SaveExecution() error = %v", err)
+	}
+
+	status := &pies.PieStatus{
+		Pie:      "Core",
+		PieID:    "core",
+		Slices:   []pies.SliceStatus{{Symbol: "VTI", Quantity: decimal.NewFromInt(4), Value: decimal.RequireFromString("1000.40"), CurrentWeight: 100, TargetWeight: 60}},
+		PieValue: decimal.RequireFromString("1000.40"),
+		Cash:     decimal.RequireFromString("8999.60"),
+	}
+	if err := s.SaveSnapshot(status); err != nil {
+		t.Fatalf("SaveSnapshot() error = %v", err)
+	}
+
+	plans, err := s.Plans("core")
+	if err != nil {
+		t.Fatalf("Plans() error = %v", err)
+	}
+	if len(plans) != 1 || plans[0].Time.IsZero() {
+		t.Fatalf("Plans(core) = %+v, want one timed plan", plans)
+	}
+	requireSameJSON(t, plans[0].Plan, plan)
+	if all, err := s.Plans(""); err != nil || len(all) != 2 {
+		t.Errorf("Plans(\"\") = %d plans, %v, want both pies'", len(all), err)
+	}
+
+	executions, err := s.Executions("core")
+	if err != nil {
+		t.Fatalf("Executions() error = %v", err)
+	}
+	if len(executions) != 1 || !executions[0].Time.Equal(started) {
+		t.Fatalf("Executions(core) = %+v, want one at %s", executions, started)
+	}
+	requireSameJSON(t, executions[0].Report, report)
+	requireSameJSON(t, executions[0].Plan, plan)
+
+	snapshots, err := s.Snapshots("core")
+	if err != nil {
+		t.Fatalf("Snapshots() error = %v", err)
+	}
+	if len(snapshots) != 1 {
+		t.Fatalf("Snapshots(core) = %+v, want one", snapshots)
+	}
+	requireSameJSON(t, snapshots[0].Status, status)
+
+	if none, err := s.Executions("missing"); err != nil || len(none) != 0 {
+		t.Errorf("Executions(missing) = %+v, %v, want none", none, err)
+	}
+}
+
+func TestExecutionsAreOrderedByStart(t *testing.T) {
+	s, _ := openStore(t)
+
+	// Saved out of order and twice in the same instant
+	base := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	for i, offset := range []time.Duration{2 * time.Hour, 0, time.Hour, time.Hour} {
+		report := &pies.ExecutionReport{Pie: "Core", PieID: "core", AccountID: fmt.Sprint(i), StartedAt: base.Add(offset)}
+		if err := s.SaveExecution(testPlan("core", 1), report); err != nil {
+			t.Fatalf("SaveExecution() error = %v", err)
+		}
+	}
+
+	executions, err := s.Executions("core")
+	if err != nil {
+		t.Fatalf("Executions() error = %v", err)
+	}
+	var order []string
+	for _, execution := range executions {
+		order = append(order, execution.Report.AccountID)
+	}
+	if fmt.Sprint(order) != "[1 2 3 0]" {
+		t.Errorf("executions in order %v, want [1 2 3 0]", order)
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	s, _ := openStore(t)
+
+	const writers, perWriter = 8, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*perWriter*2)
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := fmt.Sprintf("pie-%d", w%2)
+			for i := range perWriter {
+				if err := s.SavePlan(testPlan(id, int64(i+1))); err != nil {
+					errs <- err
+				}
+				// Readers run alongside the writers
+				if _, err := s.Plans(id); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent access error = %v", err)
+	}
+
+	// Records saved in the same instant are all kept
+	all, err := s.Plans("")
+	if err != nil {
+		t.Fatalf("Plans() error = %v", err)
+	}
+	if len(all) != writers*perWriter {
+		t.Errorf("Plans() = %d plans, want %d", len(all), writers*perWriter)
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].Time.Before(all[i-1].Time) {
+			t.Fatalf("plan %d saved at %s comes after one saved at %s", i, all[i].Time, all[i-1].Time)
+		}
+	}
+}
+
+func TestOpenFailsWhileAnotherHandleHoldsTheDatabase(t *testing.T) {
+	_, path := openStore(t)
+
+	// bbolt locks the file, so a second handle waits and then gives up
+	second, err := store.Open(path)
+	if err == nil {
+		second.Close()
+		t.Fatal("second Open() error = nil, want a timeout")
+	}
+}
@@ -0,0 +1,113 @@
+package pies
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// AccountKind classifies an account by how it is taxed and funded, which
+// the brokerage's own account type strings do not do consistently. Each
+// brokerage maps its account types onto these kinds.
+type AccountKind string
+
+const (
+	AccountKindTaxable        AccountKind = "TAXABLE" // Taxable, but the brokerage does not say whether cash or margin
+	AccountKindTraditionalIRA AccountKind = "TRADITIONAL_IRA"
+	AccountKindRothIRA        AccountKind = "ROTH_IRA"
+	AccountKindMargin         AccountKind = "MARGIN"
+	AccountKindCash           AccountKind = "CASH"
+	AccountKindUnknown        AccountKind = "UNKNOWN"
+)
+
+// TaxAdvantaged reports whether selling in the account realizes no taxable
+// gains
+func (k AccountKind) TaxAdvantaged() bool {
+	return k == AccountKindTraditionalIRA || k == AccountKindRothIRA
+}
+
+// Taxable reports whether gains realized in the account are taxed
+func (k AccountKind) Taxable() bool {
+	return k == AccountKindTaxable || k == AccountKindMargin || k == AccountKindCash
+}
+
+// Matches reports whether an account of kind k is one of kind want.
+// Taxable matches cash and margin accounts as well, and the empty kind
+// matches every account.
+func (k AccountKind) Matches(want AccountKind) bool {
+	switch want {
+	case "":
+		return true
+	case AccountKindTaxable:
+		return k.Taxable()
+	default:
+		return k == want
+	}
+}
+
+// GetAccounts returns the brokerage's accounts matching any of kinds, or
+// every account when no kinds are given
+func (i *Investor) GetAccounts(ctx context.Context, kinds ...AccountKind) ([]Account, error) {
+	accounts, err := i.BrokerageClient.GetAccounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	}
+	if len(kinds) == 0 {
+		return accounts, nil
+	}
+
+	return slices.DeleteFunc(accounts, func(account Account) bool {
+		return !slices.ContainsFunc(kinds, account.Kind.Matches)
+	}), nil
+}
+
+// SelectAccount makes the brokerage account of kind with nickname the
+// investor's account and returns it. An empty kind or nickname matches any
+// account; the nickname is compared ignoring case. It fails unless exactly
+// one account matches.
+func (i *Investor) SelectAccount(ctx context.Context, kind AccountKind, nickname string) (Account, error) {
+	accounts, err := i.GetAccounts(ctx, kind)
+	if err != nil {
+		return Account{}, err
+	}
+	if nickname != "" {
+		accounts = slices.DeleteFunc(accounts, func(account Account) bool {
+			return !strings.EqualFold(account.Nickname, nickname)
+		})
+	}
+
+	if len(accounts) != 1 {
+		description := "accounts"
+		if kind != "" {
+			description = strings.ToLower(strings.ReplaceAll(string(kind), "_", " ")) + " accounts"
+		}
+		if nickname != "" {
+			description += fmt.Sprintf(" named %q", nickname)
+		}
+		return Account{}, fmt.Errorf("brokerage has %d %s, expected one", len(accounts), description)
+	}
+
+	i.Account = accounts[0]
+	return i.Account, nil
+}
+
+// SelectAccountByName makes the brokerage account whose number, ID or
+// nickname is name the investor's account and returns it. The nickname is
+// compared ignoring case. It fails unless exactly one account matches.
+func (i *Investor) SelectAccountByName(ctx context.Context, name string) (Account, error) {
+	accounts, err := i.GetAccounts(ctx)
+	if err != nil {
+		return Account{}, err
+	}
+	accounts = slices.DeleteFunc(accounts, func(account Account) bool {
+		return account.AccountNumber != name && account.AccountID != name && !strings.EqualFold(account.Nickname, name)
+	})
+
+	if len(accounts) != 1 {
+		return Account{}, fmt.Errorf("brokerage has %d accounts numbered or named %q, expected one", len(accounts), name)
+	}
+
+	i.Account = accounts[0]
+	return i.Account, nil
+}
@@ -0,0 +1,169 @@
+package pies_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/alysoliman1/money-pies/pkg/pies/piestest"
+	"github.com/shopspring/decimal"
+)
+
+// householdFake returns a fake holding a cash, a margin, a traditional IRA
+// and two Roth IRA accounts, identified by their nicknames
+func householdFake() *piestest.Brokerage {
+	b := piestest.New()
+	for _, account := range []struct {
+		nickname string
+		kind     pies.AccountKind
+	}{
+		{"Checking Sweep", pies.AccountKindCash},
+		{"Trading", pies.AccountKindMargin},
+		{"Rollover", pies.AccountKindTraditionalIRA},
+		{"Roth", pies.AccountKindRothIRA},
+		{"Spouse Roth", pies.AccountKindRothIRA},
+	} {
+		b.AddAccount(pies.Account{
+			AccountID: account.nickname,
+			Nickname:  account.nickname,
+			Kind:      account.kind,
+		})
+	}
+	return b
+}
+
+// nicknames returns the nicknames of accounts, in order
+func nicknames(accounts []pies.Account) []string {
+	var names []string
+	for _, account := range accounts {
+		names = append(names, account.Nickname)
+	}
+	return names
+}
+
+func TestAccountKindMatches(t *testing.T) {
+	tests := []struct {
+		kind pies.AccountKind
+		want []pies.AccountKind // Kinds that match, of every kind and ""
+	}{
+		{kind: pies.AccountKindCash, want: []pies.AccountKind{"", pies.AccountKindTaxable, pies.AccountKindCash}},
+		{kind: pies.AccountKindMargin, want: []pies.AccountKind{"", pies.AccountKindTaxable, pies.AccountKindMargin}},
+		{kind: pies.AccountKindTaxable, want: []pies.AccountKind{"", pies.AccountKindTaxable}},
+		{kind: pies.AccountKindTraditionalIRA, want: []pies.AccountKind{"", pies.AccountKindTraditionalIRA}},
+		{kind: pies.AccountKindRothIRA, want: []pies.AccountKind{"", pies.AccountKindRothIRA}},
+		{kind: pies.AccountKindUnknown, want: []pies.AccountKind{"", pies.AccountKindUnknown}},
+	}
+	all := []pies.AccountKind{
+		"",
+		pies.AccountKindTaxable,
+		pies.AccountKindTraditionalIRA,
+		pies.AccountKindRothIRA,
+		pies.AccountKindMargin,
+		pies.AccountKindCash,
+		pies.AccountKindUnknown,
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			for _, want := range all {
+				if got := tt.kind.Matches(want); got != slices.Contains(tt.want, want) {
+					t.Errorf("%q.Matches(%q) = %t", tt.kind, want, got)
+				}
+			}
+			if tt.kind.Taxable() == tt.kind.TaxAdvantaged() && tt.kind != pies.AccountKindUnknown {
+				t.Errorf("%q is both or neither taxable and tax advantaged", tt.kind)
+			}
+		})
+	}
+}
+
+func TestGetAccountsFiltersByKind(t *testing.T) {
+	tests := []struct {
+		name  string
+		kinds []pies.AccountKind
+		want  []string
+	}{
+		{name: "no kinds", want: []string{"Checking Sweep", "Trading", "Rollover", "Roth", "Spouse Roth"}},
+		{name: "taxable", kinds: []pies.AccountKind{pies.AccountKindTaxable}, want: []string{"Checking Sweep", "Trading"}},
+		{name: "roth", kinds: []pies.AccountKind{pies.AccountKindRothIRA}, want: []string{"Roth", "Spouse Roth"}},
+		{
+			name:  "either IRA",
+			kinds: []pies.AccountKind{pies.AccountKindTraditionalIRA, pies.AccountKindRothIRA},
+			want:  []string{"Rollover", "Roth", "Spouse Roth"},
+		},
+		{name: "none held", kinds: []pies.AccountKind{pies.AccountKindUnknown}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			investor := &pies.Investor{BrokerageClient: householdFake()}
+			accounts, err := investor.GetAccounts(t.Context(), tt.kinds...)
+			if err != nil {
+				t.Fatalf("GetAccounts() error = %v", err)
+			}
+			if got := nicknames(accounts); !slices.Equal(got, tt.want) {
+				t.Errorf("GetAccounts(%v) = %q, want %q", tt.kinds, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectAccount(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     pies.AccountKind
+		nickname string
+		want     string // Nickname of the selected account
+		wantErr  string
+	}{
+		{name: "only margin account", kind: pies.AccountKindMargin, want: "Trading"},
+		{name: "nickname ignoring case", kind: pies.AccountKindRothIRA, nickname: "spouse roth", want: "Spouse Roth"},
+		{name: "nickname of any kind", nickname: "Rollover", want: "Rollover"},
+		{name: "two roth IRAs", kind: pies.AccountKindRothIRA, wantErr: "brokerage has 2 roth ira accounts, expected one"},
+		{
+			name:     "nickname of another kind",
+			kind:     pies.AccountKindTaxable,
+			nickname: "Roth",
+			wantErr:  `brokerage has 0 taxable accounts named "Roth", expected one`,
+		},
+		{name: "every account", wantErr: "brokerage has 5 accounts, expected one"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			investor := &pies.Investor{BrokerageClient: householdFake()}
+			account, err := investor.SelectAccount(t.Context(), tt.kind, tt.nickname)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("SelectAccount() error = %v, want %q", err, tt.wantErr)
+				}
+				if investor.Account.Nickname != "" {
+					t.Errorf("Account = %q after a failed selection, want it unchanged", investor.Account.Nickname)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SelectAccount() error = %v", err)
+			}
+			if account.Nickname != tt.want || investor.Account.Nickname != tt.want {
+				t.Errorf("SelectAccount() = %q, Account = %q, want %q", account.Nickname, investor.Account.Nickname, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectAccountByName(t *testing.T) {
+	b := householdFake()
+	b.AddAccount(pies.Account{AccountID: "hash-123", AccountNumber: "123", Nickname: "Kids", CashBalance: decimal.NewFromInt(1)})
+	investor := &pies.Investor{BrokerageClient: b}
+
+	for _, name := range []string{"123", "hash-123", "KIDS"} {
+		account, err := investor.SelectAccountByName(t.Context(), name)
+		if err != nil || account.Nickname != "Kids" {
+			t.Errorf("SelectAccountByName(%q) = %q, %v, want Kids", name, account.Nickname, err)
+		}
+	}
+	if _, err := investor.SelectAccountByName(t.Context(), "999"); err == nil {
+		t.Error("SelectAccountByName(999) succeeded, want an error")
+	}
+}
@@ -0,0 +1,485 @@
+package pies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ErrNotAuthenticated is matched by the errors brokerages return when they
+	// have no usable credentials and the user has to sign in again
+	ErrNotAuthenticated = errors.New("not authenticated")
+
+	// ErrOrderNotOpen is matched by the errors of cancelling an order that
+	// has already filled, been cancelled or otherwise closed
+	ErrOrderNotOpen = errors.New("order is not open")
+
+	// ErrDuplicateOrder is matched by the errors of placing an order that
+	// repeats one placed moments before, when the brokerage guards against
+	// duplicates and the request does not set AllowDuplicate. Nothing new
+	// has been placed.
+	ErrDuplicateOrder = errors.New("duplicate order")
+)
+
+// OrderType represents the type of order (market, limit, etc.)
+type OrderType string
+
+const (
+	OrderTypeMarket OrderType = "MARKET"
+	OrderTypeLimit  OrderType = "LIMIT"
+	OrderTypeStop   OrderType = "STOP" // Market order once the stop price trades
+)
+
+// OrderAction represents buy or sell
+type OrderAction string
+
+const (
+	OrderActionBuy  OrderAction = "BUY"
+	OrderActionSell OrderAction = "SELL"
+)
+
+// OrderDuration represents how long an order remains working
+type OrderDuration string
+
+const (
+	OrderDurationDay OrderDuration = "DAY"
+	OrderDurationGTC OrderDuration = "GTC" // Good till cancelled
+)
+
+// OrderSession represents the trading session an order is eligible for
+type OrderSession string
+
+const (
+	OrderSessionNormal   OrderSession = "NORMAL"
+	OrderSessionAM       OrderSession = "AM"       // Pre-market
+	OrderSessionPM       OrderSession = "PM"       // After hours
+	OrderSessionSeamless OrderSession = "SEAMLESS" // All sessions
+)
+
+// OrderStatus represents the current status of an order
+type OrderStatus string
+
+const (
+	OrderStatusPending         OrderStatus = "PENDING" // Accepted but not yet working at the exchange
+	OrderStatusWorking         OrderStatus = "WORKING"
+	OrderStatusPartiallyFilled OrderStatus = "PARTIALLY_FILLED" // Still working with some quantity filled
+	OrderStatusFilled          OrderStatus = "FILLED"
+	OrderStatusCancelled       OrderStatus = "CANCELLED"
+	OrderStatusRejected        OrderStatus = "REJECTED"
+	OrderStatusExpired         OrderStatus = "EXPIRED"
+	OrderStatusReplaced        OrderStatus = "REPLACED"
+	OrderStatusUnknown         OrderStatus = "UNKNOWN" // See Order.RawStatus
+)
+
+// IsOpen reports whether an order with this status may still fill
+func (s OrderStatus) IsOpen() bool {
+	switch s {
+	case OrderStatusPending, OrderStatusWorking, OrderStatusPartiallyFilled:
+		return true
+	default:
+		return false
+	}
+}
+
+// Order represents a trade order
+type Order struct {
+	ID          string            `json:"id"`
+	Symbol      string            `json:"symbol"`
+	Action      OrderAction       `json:"action"`
+	Type        OrderType         `json:"type"`
+	Quantity    decimal.Decimal   `json:"quantity"`
+	LimitPrice  *decimal.Decimal  `json:"limit_price,omitempty"` // Only for limit orders
+	StopPrice   *decimal.Decimal  `json:"stop_price,omitempty"`  // Only for stop orders
+	Status      OrderStatus       `json:"status"`
+	RawStatus   string            `json:"raw_status,omitempty"` // The brokerage's own status, for debugging and for when Status is UNKNOWN
+	FilledQty   decimal.Decimal   `json:"filled_qty"`
+	FilledPrice decimal.Decimal   `json:"filled_price"`
+	SubmittedAt time.Time         `json:"submitted_at"`
+	FilledAt    *time.Time        `json:"filled_at,omitempty"`
+	Strategy    OrderStrategyType `json:"strategy,omitempty"` // Empty for brokerages without order strategies
+	Children    []Order           `json:"children,omitempty"` // Orders of an OCO or TRIGGER strategy
+	RawResponse any               `json:"-"`                  // Original response from brokerage
+}
+
+// OrderRequest represents a request to place an order. The order size is
+// given either as a number of shares in Quantity or as a dollar value in
+// Amount, never both.
+type OrderRequest struct {
+	Symbol     string           `json:"symbol"`
+	Action     OrderAction      `json:"action"`
+	Type       OrderType        `json:"type"`
+	Quantity   decimal.Decimal  `json:"quantity,omitzero"`
+	Amount     *decimal.Decimal `json:"amount,omitempty"`      // Dollar value to trade instead of Quantity
+	LimitPrice *decimal.Decimal `json:"limit_price,omitempty"` // Required for limit orders
+	StopPrice  *decimal.Decimal `json:"stop_price,omitempty"`  // Required for stop orders
+	Duration   OrderDuration    `json:"duration,omitempty"`    // Defaults to DAY when empty
+	Session    OrderSession     `json:"session,omitempty"`     // Defaults to NORMAL when empty
+
+	// AllowDuplicate places the order even when it repeats one placed
+	// moments before, for brokerages that guard against duplicates
+	AllowDuplicate bool `json:"allow_duplicate,omitempty"`
+}
+
+// Validate reports the first invalid field of the order
+func (r OrderRequest) Validate() error {
+	switch {
+	case strings.TrimSpace(r.Symbol) == "":
+		return errors.New("order has no symbol")
+	case r.Action != OrderActionBuy && r.Action != OrderActionSell:
+		return fmt.Errorf("order for %s has action %q, want BUY or SELL", r.Symbol, r.Action)
+	case r.Amount != nil && !r.Quantity.IsZero():
+		return fmt.Errorf("order for %s sets both quantity and amount", r.Symbol)
+	case r.Amount != nil && !r.Amount.IsPositive():
+		return fmt.Errorf("order for %s has non-positive amount %s", r.Symbol, r.Amount.StringFixed(centPlaces))
+	case r.Amount == nil && !r.Quantity.IsPositive():
+		return fmt.Errorf("order for %s needs a positive quantity or an amount", r.Symbol)
+	}
+
+	switch r.Type {
+	case OrderTypeMarket:
+		if r.LimitPrice != nil {
+			return fmt.Errorf("market order for %s sets a limit price", r.Symbol)
+		}
+	case OrderTypeLimit:
+		if err := checkOrderPrice(r.Symbol, "limit", r.LimitPrice); err != nil {
+			return err
+		}
+	case OrderTypeStop:
+		if err := checkOrderPrice(r.Symbol, "stop", r.StopPrice); err != nil {
+			return err
+		}
+		if r.LimitPrice != nil {
+			return fmt.Errorf("stop order for %s sets a limit price, stop limit orders are not supported", r.Symbol)
+		}
+	default:
+		return fmt.Errorf("order for %s has type %q, want MARKET, LIMIT or STOP", r.Symbol, r.Type)
+	}
+	if r.StopPrice != nil && r.Type != OrderTypeStop {
+		return fmt.Errorf("%s order for %s sets a stop price, only stop orders take one", strings.ToLower(string(r.Type)), r.Symbol)
+	}
+	return nil
+}
+
+// checkOrderPrice checks that the kind price an order type needs, limit or
+// stop, is set and positive
+func checkOrderPrice(symbol, kind string, price *decimal.Decimal) error {
+	switch {
+	case price == nil:
+		return fmt.Errorf("%s order for %s needs a %s price", kind, symbol, kind)
+	case !price.IsPositive():
+		return fmt.Errorf("%s order for %s has non-positive %s price %s", kind, symbol, kind, price)
+	}
+	return nil
+}
+
+// OrderPreview is the brokerage's assessment of an order that has not been placed
+type OrderPreview struct {
+	EstimatedTotal          decimal.Decimal // Order value before commissions and fees
+	Commission              decimal.Decimal
+	Fees                    decimal.Decimal
+	ProjectedBuyingPower    decimal.Decimal // Buying power left after the order
+	ProjectedAvailableFunds decimal.Decimal
+	Warnings                []string
+	Rejections              []string
+	RawResponse             any // Original response from brokerage
+}
+
+// InsufficientBuyingPower reports whether the account could not afford the order
+func (p OrderPreview) InsufficientBuyingPower() bool {
+	if p.ProjectedBuyingPower.IsNegative() {
+		return true
+	}
+	for _, rejection := range p.Rejections {
+		if strings.Contains(strings.ToLower(rejection), "buying power") {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderRejectedError reports an order preview the brokerage would reject
+type OrderRejectedError struct {
+	Symbol   string
+	Messages []string
+}
+
+func (e *OrderRejectedError) Error() string {
+	return fmt.Sprintf("order for %s would be rejected: %s", e.Symbol, strings.Join(e.Messages, "; "))
+}
+
+// OrdersQuery filters the orders returned by GetRecentOrders. Zero values
+// leave the corresponding filter to the brokerage's default.
+type OrdersQuery struct {
+	From       time.Time
+	To         time.Time
+	Status     OrderStatus
+	MaxResults int
+}
+
+// Position represents a current position in a security. Quantity is the
+// net of LongQuantity and ShortQuantity, and MarketValue is negative for a
+// short position, whose UnrealizedPL is positive when the price has fallen.
+type Position struct {
+	Symbol          string          `json:"symbol"`
+	Description     string          `json:"description,omitempty"`
+	AssetType       string          `json:"asset_type,omitempty"` // One of the AssetType constants or the brokerage's own type, empty when not reported
+	Quantity        decimal.Decimal `json:"quantity"`
+	LongQuantity    decimal.Decimal `json:"long_quantity"`
+	ShortQuantity   decimal.Decimal `json:"short_quantity"`
+	AveragePrice    decimal.Decimal `json:"average_price"` // Paid for a long position, received for a short one
+	CurrentPrice    decimal.Decimal `json:"current_price"`
+	MarketValue     decimal.Decimal `json:"market_value"`
+	UnrealizedPL    decimal.Decimal `json:"unrealized_pl"`
+	UnrealizedPLPct float64         `json:"unrealized_pl_pct"` // Of the cost basis, whatever the side
+	DayPL           decimal.Decimal `json:"day_pl"`            // Change in MarketValue since the previous close
+}
+
+// The asset types of positions shared by all brokerages
+const (
+	AssetTypeEquity         = "EQUITY"
+	AssetTypeETF            = "ETF"
+	AssetTypeMutualFund     = "MUTUAL_FUND"
+	AssetTypeCashEquivalent = "CASH_EQUIVALENT" // Money market and sweep funds, cash kept in a fund
+)
+
+// IsCashEquivalent reports whether the position is a money market or sweep
+// fund
+func (p Position) IsCashEquivalent() bool {
+	return p.AssetType == AssetTypeCashEquivalent
+}
+
+// LongShort splits a net quantity into the LongQuantity and ShortQuantity
+// of a Position, for brokerages that report only the net quantity
+func LongShort(quantity decimal.Decimal) (long, short decimal.Decimal) {
+	if quantity.IsNegative() {
+		return decimal.Zero, quantity.Neg()
+	}
+	return quantity, decimal.Zero
+}
+
+// Account represents account information
+type Account struct {
+	AccountID     string          `json:"account_id"`
+	AccountNumber string          `json:"account_number"`
+	AccountHash   string          `json:"account_hash"` // Brokerage-issued opaque identifier, if any
+	Nickname      string          `json:"nickname"`
+	Type          string          `json:"type"` // The brokerage's own account type
+	Kind          AccountKind     `json:"kind"` // Type mapped onto the kinds shared by all brokerages
+	CashBalance   decimal.Decimal `json:"cash_balance"`
+	SettledCash   decimal.Decimal `json:"settled_cash"` // Cash a cash account can spend without waiting for trades to settle
+	BuyingPower   decimal.Decimal `json:"buying_power"`
+	MarketValue   decimal.Decimal `json:"market_value"`
+	TotalValue    decimal.Decimal `json:"total_value"`
+}
+
+// ID returns the identifier to pass to account-specific BrokerageClient
+// methods, preferring the brokerage-issued hash when there is one
+func (a Account) ID() string {
+	switch {
+	case a.AccountHash != "":
+		return a.AccountHash
+	case a.AccountNumber != "":
+		return a.AccountNumber
+	default:
+		return a.AccountID
+	}
+}
+
+// Quote represents the current market quote for a security
+type Quote struct {
+	Symbol      string          `json:"symbol"`
+	Description string          `json:"description"`
+	Bid         decimal.Decimal `json:"bid"`
+	Ask         decimal.Decimal `json:"ask"`
+	Last        decimal.Decimal `json:"last"`
+	Close       decimal.Decimal `json:"close"`
+	Volume      int64           `json:"volume"`
+	Timestamp   time.Time       `json:"timestamp"`
+	Extended    *ExtendedQuote  `json:"extended,omitempty"` // Pre-market/after-hours prices, nil when not reported
+	Raw         any             `json:"-"`                  // Original response from brokerage
+}
+
+// ExtendedQuote holds prices from the extended-hours sessions. Outside of
+// them it usually repeats the last extended-hours trade.
+type ExtendedQuote struct {
+	Bid       decimal.Decimal `json:"bid"`
+	Ask       decimal.Decimal `json:"ask"`
+	Last      decimal.Decimal `json:"last"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// Price returns the price to value the symbol at: the extended-hours last
+// price when extendedHours is set and one is available, otherwise Last
+func (q Quote) Price(extendedHours bool) decimal.Decimal {
+	if extendedHours && q.Extended != nil && q.Extended.Last.IsPositive() {
+		return q.Extended.Last
+	}
+	return q.Last
+}
+
+// DayChange returns how much the value of quantity shares has changed since
+// the previous close, zero when the quote has no last price or close
+func (q Quote) DayChange(quantity decimal.Decimal) decimal.Decimal {
+	if !q.Last.IsPositive() || !q.Close.IsPositive() {
+		return decimal.Zero
+	}
+	return q.Last.Sub(q.Close).Mul(quantity)
+}
+
+// Candle represents price activity for a security over one interval
+type Candle struct {
+	Time   time.Time // Start of the interval
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume int64
+}
+
+// Instrument describes a tradable security as known to the brokerage
+type Instrument struct {
+	Symbol      string
+	CUSIP       string
+	Description string
+	Exchange    string
+	AssetType   string
+	Fundamental *Fundamentals // Only populated when fundamentals are requested
+}
+
+// Fundamentals holds basic fundamental data for an instrument
+type Fundamentals struct {
+	PERatio           float64
+	EPS               float64
+	DividendYield     float64
+	DividendAmount    float64
+	MarketCap         float64
+	High52Week        float64
+	Low52Week         float64
+	SharesOutstanding float64
+}
+
+// TransactionType classifies account activity
+type TransactionType string
+
+const (
+	TransactionTypeTrade    TransactionType = "TRADE"
+	TransactionTypeDividend TransactionType = "DIVIDEND"
+	TransactionTypeInterest TransactionType = "INTEREST"
+	TransactionTypeTransfer TransactionType = "TRANSFER"
+	TransactionTypeOther    TransactionType = "OTHER"
+)
+
+// Transaction represents a single entry in an account's activity history
+type Transaction struct {
+	ID          string
+	Date        time.Time
+	Type        TransactionType
+	RawType     string // Brokerage-specific activity type
+	Symbol      string
+	Description string
+	Quantity    decimal.Decimal
+	Price       decimal.Decimal
+	Amount      decimal.Decimal // Net cash impact on the account
+	Fees        decimal.Decimal
+	RawResponse any // Original response from brokerage
+}
+
+// MissingQuotesError reports symbols for which no quote could be retrieved
+type MissingQuotesError struct {
+	Symbols []string
+	Errors  map[string]error // Why each symbol's quote failed, when known
+}
+
+func (e *MissingQuotesError) Error() string {
+	return fmt.Sprintf("no quotes returned for symbols: %s", strings.Join(e.Symbols, ", "))
+}
+
+// Unwrap returns the errors of the symbols whose quotes failed
+func (e *MissingQuotesError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, symbol := range e.Symbols {
+		if err, ok := e.Errors[symbol]; ok {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// OrderPreviewer is implemented by brokerages that can preview an order's
+// cost and validation result without placing it. A preview the brokerage
+// would reject is returned together with an *OrderRejectedError.
+type OrderPreviewer interface {
+	PreviewOrder(ctx context.Context, accountID string, order OrderRequest) (*OrderPreview, error)
+}
+
+// FractionalTrader is implemented by brokerages that can trade fractional
+// shares. Brokerages that do not implement it are assumed to trade whole
+// shares only.
+type FractionalTrader interface {
+	SupportsFractionalShares() bool
+}
+
+// CheckOrderClosed returns an error matching ErrOrderNotOpen when err, the
+// failure to cancel orderID, is due to the order having closed, and err
+// otherwise. It is for brokerages whose APIs do not tell a closed order
+// apart from other failures.
+func CheckOrderClosed(ctx context.Context, client BrokerageClient, accountID, orderID string, err error) error {
+	if errors.Is(err, ErrNotAuthenticated) {
+		return err
+	}
+
+	order, statusErr := client.GetOrderStatus(ctx, accountID, orderID)
+	if statusErr != nil || order.Status.IsOpen() {
+		return err
+	}
+	return fmt.Errorf("order %s is already %s: %w", orderID, order.Status, ErrOrderNotOpen)
+}
+
+// Brokerage is the main interface that all brokerage implementations must
+// satisfy. Besides the semantics documented on each method, every method of
+// a client without usable credentials fails with an error matching
+// ErrNotAuthenticated. piestest.RunBrokerageConformanceTests checks an
+// implementation against this contract.
+type BrokerageClient interface {
+	// IsAuthenticated checks if the client has valid authentication
+	IsAuthenticated() bool
+
+	// GetAccounts retrieves all accounts for the authenticated user
+	GetAccounts(ctx context.Context) ([]Account, error)
+
+	// GetPositions retrieves all positions for a specific account. An account
+	// without positions returns an empty, non-nil slice.
+	GetPositions(ctx context.Context, accountID string) ([]Position, error)
+
+	// PlaceOrder submits a new order. Orders failing OrderRequest.Validate,
+	// and limit and stop orders without a positive price, are refused
+	// without submitting anything.
+	PlaceOrder(ctx context.Context, accountID string, order OrderRequest) (*Order, error)
+
+	// ReplaceOrder atomically replaces a working order, returning the new order
+	ReplaceOrder(ctx context.Context, accountID string, orderID string, newOrder OrderRequest) (*Order, error)
+
+	// GetOrderStatus retrieves the status of a specific order
+	GetOrderStatus(ctx context.Context, accountID string, orderID string) (*Order, error)
+
+	// CancelPendingOrder cancels an open order. Cancelling an order that has
+	// already closed fails with an error matching ErrOrderNotOpen.
+	CancelPendingOrder(ctx context.Context, accountID string, orderID string) error
+
+	// GetRecentOrders retrieves recent orders for an account matching the
+	// query, newest first. No matching orders is an empty, non-nil slice.
+	GetRecentOrders(ctx context.Context, accountID string, query OrdersQuery) ([]Order, error)
+
+	// GetQuote retrieves the current quote for a symbol
+	GetQuote(ctx context.Context, symbol string) (*Quote, error)
+
+	// GetQuotes retrieves current quotes for several symbols, keyed by symbol.
+	// Symbols the brokerage could not price are reported via a
+	// *MissingQuotesError alongside the quotes that were found.
+	GetQuotes(ctx context.Context, symbols []string) (map[string]Quote, error)
+}
@@ -0,0 +1,134 @@
+package pies_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+func TestOrderRequestValidate(t *testing.T) {
+	price := func(s string) *decimal.Decimal {
+		return pies.DecimalPtr(decimal.RequireFromString(s))
+	}
+	// order returns a valid market buy of 10 VTI changed by edit
+	order := func(edit func(o *pies.OrderRequest)) pies.OrderRequest {
+		o := pies.OrderRequest{
+			Symbol:   "VTI",
+			Action:   pies.OrderActionBuy,
+			Type:     pies.OrderTypeMarket,
+			Quantity: decimal.NewFromInt(10),
+		}
+		edit(&o)
+		return o
+	}
+
+	tests := []struct {
+		name    string
+		order   pies.OrderRequest
+		wantErr string // Empty when the order is valid
+	}{
+		{name: "market buy", order: order(func(o *pies.OrderRequest) {})},
+		{name: "market sell", order: order(func(o *pies.OrderRequest) { o.Action = pies.OrderActionSell })},
+		{name: "fractional quantity", order: order(func(o *pies.OrderRequest) { o.Quantity = decimal.RequireFromString("0.000001") })},
+		{name: "amount", order: order(func(o *pies.OrderRequest) { o.Quantity = decimal.Zero; o.Amount = price("500") })},
+		{name: "limit", order: order(func(o *pies.OrderRequest) { o.Type = pies.OrderTypeLimit; o.LimitPrice = price("250.01") })},
+		{name: "stop", order: order(func(o *pies.OrderRequest) { o.Type = pies.OrderTypeStop; o.StopPrice = price("230") })},
+
+		{name: "no symbol", order: order(func(o *pies.OrderRequest) { o.Symbol = "" }), wantErr: "order has no symbol"},
+		{name: "blank symbol", order: order(func(o *pies.OrderRequest) { o.Symbol = "  " }), wantErr: "order has no symbol"},
+		{name: "no action", order: order(func(o *pies.OrderRequest) { o.Action = "" }), wantErr: `order for VTI has action "", want BUY or SELL`},
+		{name: "unknown action", order: order(func(o *pies.OrderRequest) { o.Action = "SELL_SHORT" }), wantErr: `order for VTI has action "SELL_SHORT", want BUY or SELL`},
+		{name: "zero quantity", order: order(func(o *pies.OrderRequest) { o.Quantity = decimal.Zero }), wantErr: "order for VTI needs a positive quantity or an amount"},
+		{name: "negative quantity", order: order(func(o *pies.OrderRequest) { o.Quantity = decimal.NewFromInt(-1) }), wantErr: "order for VTI needs a positive quantity or an amount"},
+		{name: "quantity and amount", order: order(func(o *pies.OrderRequest) { o.Amount = price("500") }), wantErr: "order for VTI sets both quantity and amount"},
+		{name: "zero amount", order: order(func(o *pies.OrderRequest) { o.Quantity = decimal.Zero; o.Amount = price("0") }), wantErr: "order for VTI has non-positive amount 0.00"},
+		{name: "negative amount", order: order(func(o *pies.OrderRequest) { o.Quantity = decimal.Zero; o.Amount = price("-5") }), wantErr: "order for VTI has non-positive amount -5.00"},
+		{name: "no type", order: order(func(o *pies.OrderRequest) { o.Type = "" }), wantErr: `order for VTI has type "", want MARKET, LIMIT or STOP`},
+		{name: "unknown type", order: order(func(o *pies.OrderRequest) { o.Type = "TRAILING_STOP" }), wantErr: `order for VTI has type "TRAILING_STOP", want MARKET, LIMIT or STOP`},
+		{name: "market with limit price", order: order(func(o *pies.OrderRequest) { o.LimitPrice = price("250") }), wantErr: "market order for VTI sets a limit price"},
+		{name: "market with stop price", order: order(func(o *pies.OrderRequest) { o.StopPrice = price("250") }), wantErr: "market order for VTI sets a stop price, only stop orders take one"},
+		{name: "limit without price", order: order(func(o *pies.OrderRequest) { o.Type = pies.OrderTypeLimit }), wantErr: "limit order for VTI needs a limit price"},
+		{name: "limit at zero", order: order(func(o *pies.OrderRequest) { o.Type = pies.OrderTypeLimit; o.LimitPrice = price("0") }), wantErr: "limit order for VTI has non-positive limit price 0"},
+		{name: "negative limit", order: order(func(o *pies.OrderRequest) { o.Type = pies.OrderTypeLimit; o.LimitPrice = price("-1") }), wantErr: "limit order for VTI has non-positive limit price -1"},
+		{
+			name: "limit with stop price",
+			order: order(func(o *pies.OrderRequest) {
+				o.Type = pies.OrderTypeLimit
+				o.LimitPrice = price("250")
+				o.StopPrice = price("240")
+			}),
+			wantErr: "limit order for VTI sets a stop price, only stop orders take one",
+		},
+		{name: "stop without price", order: order(func(o *pies.OrderRequest) { o.Type = pies.OrderTypeStop }), wantErr: "stop order for VTI needs a stop price"},
+		{name: "stop at zero", order: order(func(o *pies.OrderRequest) { o.Type = pies.OrderTypeStop; o.StopPrice = price("0") }), wantErr: "stop order for VTI has non-positive stop price 0"},
+		{
+			name: "stop limit",
+			order: order(func(o *pies.OrderRequest) {
+				o.Type = pies.OrderTypeStop
+				o.StopPrice = price("230")
+				o.LimitPrice = price("229")
+			}),
+			wantErr: "stop order for VTI sets a limit price, stop limit orders are not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.order.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate() error = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOrderRequestDurationAndSessionJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration pies.OrderDuration
+		session  pies.OrderSession
+		want     string // Fields expected in the JSON, empty ones are omitted
+	}{
+		{name: "defaults"},
+		{name: "good till cancel", duration: pies.OrderDurationGTC, want: `"duration":"GTC"`},
+		{name: "after hours day", duration: pies.OrderDurationDay, session: pies.OrderSessionPM, want: `"duration":"DAY","session":"PM"`},
+		{name: "seamless", session: pies.OrderSessionSeamless, want: `"session":"SEAMLESS"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := pies.OrderRequest{
+				Symbol:   "VTI",
+				Action:   pies.OrderActionBuy,
+				Type:     pies.OrderTypeLimit,
+				Duration: tt.duration,
+				Session:  tt.session,
+			}
+			data, err := json.Marshal(order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := string(data)
+			if tt.want != "" && !strings.Contains(got, tt.want) {
+				t.Errorf("json.Marshal() = %s, want it to contain %s", got, tt.want)
+			}
+			if tt.duration == "" && strings.Contains(got, `"duration"`) || tt.session == "" && strings.Contains(got, `"session"`) {
+				t.Errorf("json.Marshal() = %s, want empty duration and session omitted", got)
+			}
+
+			var decoded pies.OrderRequest
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Duration != tt.duration || decoded.Session != tt.session {
+				t.Errorf("round trip = %s, %s, want %s, %s", decoded.Duration, decoded.Session, tt.duration, tt.session)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package pies
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// BrokerageFactory creates a BrokerageClient from the brokerage's own JSON
+// configuration. A client that needs its user to sign in again is returned
+// together with an error matching ErrNotAuthenticated, so that long-running
+// callers can keep it and retry later.
+type BrokerageFactory func(config json.RawMessage) (BrokerageClient, error)
+
+var (
+	brokeragesMu sync.RWMutex
+	brokerages   = make(map[string]BrokerageFactory)
+)
+
+// RegisterBrokerage makes a brokerage available to NewBrokerage under name.
+// Brokerage packages call it from init; registering a name twice panics.
+func RegisterBrokerage(name string, factory BrokerageFactory) {
+	brokeragesMu.Lock()
+	defer brokeragesMu.Unlock()
+
+	if factory == nil {
+		panic("pies: RegisterBrokerage factory is nil")
+	}
+	if _, ok := brokerages[name]; ok {
+		panic("pies: RegisterBrokerage called twice for brokerage " + name)
+	}
+	brokerages[name] = factory
+}
+
+// Brokerages returns the names of the registered brokerages in sorted order
+func Brokerages() []string {
+	brokeragesMu.RLock()
+	defer brokeragesMu.RUnlock()
+
+	return slices.Sorted(maps.Keys(brokerages))
+}
+
+// NewBrokerage creates a client for the brokerage registered as name from
+// its JSON configuration. See BrokerageFactory for how a client needing a
+// new sign-in is returned.
+func NewBrokerage(name string, config json.RawMessage) (BrokerageClient, error) {
+	brokeragesMu.RLock()
+	factory, ok := brokerages[name]
+	brokeragesMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown brokerage %q, registered brokerages: %s", name, strings.Join(Brokerages(), ", "))
+	}
+	return factory(config)
+}
+
+// BrokerageConfig is the brokerage configuration file shared by the
+// commands: the registered name of a brokerage and its own configuration,
+// e.g. {"brokerage": "schwab", "config": {...}}
+type BrokerageConfig struct {
+	Brokerage string          `json:"brokerage"`
+	Config    json.RawMessage `json:"config"`
+
+	// Notifications configures the notify package, empty for none
+	Notifications json.RawMessage `json:"notifications,omitempty"`
+}
+
+// LoadBrokerageConfig reads a brokerage configuration file
+func LoadBrokerageConfig(path string) (BrokerageConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return BrokerageConfig{}, fmt.Errorf("failed to read brokerage config: %w", err)
+	}
+
+	var config BrokerageConfig
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return BrokerageConfig{}, fmt.Errorf("failed to parse brokerage config %s: %w", path, err)
+	}
+	if config.Brokerage == "" {
+		return BrokerageConfig{}, fmt.Errorf("brokerage config %s does not name a brokerage", path)
+	}
+	return config, nil
+}
+
+// NewClient creates the configured brokerage's client with NewBrokerage
+func (c BrokerageConfig) NewClient() (BrokerageClient, error) {
+	return NewBrokerage(c.Brokerage, c.Config)
+}
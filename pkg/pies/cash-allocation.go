@@ -0,0 +1,157 @@
+package pies
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// AllocateCash plans buys spreading amount of the account's cash across pie
+// without selling anything. The cash goes to the most underweight slices
+// first, raising them together until they catch up with the next slice, so
+// an amount smaller than the pie's drift only buys the laggards and an
+// amount larger than it is spread by weight once every slice is on target.
+//
+// A cash slice takes its share like any other slice, counting the account's
+// cash beyond amount as its value, and that share is left uninvested.
+//
+// Unless the brokerage implements FractionalTrader and supports fractional
+// shares, the plan buys whole shares and reports the cash they cannot
+// absorb in Leftover. With a Screener, plans buying a rejected symbol fail
+// with a *ScreeningError.
+func (i *Investor) AllocateCash(ctx context.Context, pie Pie, amount decimal.Decimal) (*RebalancePlan, error) {
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("amount to allocate must be positive, got %s", amount.StringFixed(centPlaces))
+	}
+
+	status, holdings, err := i.getPieStatus(ctx, pie, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if amount.GreaterThan(status.Cash) {
+		return nil, fmt.Errorf("amount %s exceeds the account's cash of %s", amount.StringFixed(centPlaces), status.Cash.StringFixed(centPlaces))
+	}
+	if len(status.Unpriced) > 0 {
+		return nil, fmt.Errorf("cannot allocate cash without quotes for %s", strings.Join(status.Unpriced, ", "))
+	}
+
+	fractional := false
+	if trader, ok := i.BrokerageClient.(FractionalTrader); ok {
+		fractional = trader.SupportsFractionalShares()
+	}
+
+	plan := allocateCash(status, holdings.quotes, holdings.extendedHours, amount, fractional)
+	if err := i.screenPlan(ctx, plan, nil); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// allocateCash plans the buys for AllocateCash, priced at extended-hours
+// quotes with extendedHours. The share of slices without a usable price is
+// left uninvested.
+func allocateCash(status *PieStatus, quotes map[string]Quote, extendedHours bool, amount decimal.Decimal, fractional bool) *RebalancePlan {
+	plan := &RebalancePlan{
+		Pie:       status.Pie,
+		PieID:     status.PieID,
+		AccountID: status.Account.ID(),
+		Orders:    []PlannedOrder{},
+		Cash:      status.Cash,
+	}
+
+	// Only the cash beyond amount counts as the cash slice's current value,
+	// since amount is about to be invested
+	slices := append([]SliceStatus(nil), status.Slices...)
+	for idx := range slices {
+		if slices[idx].IsCash {
+			slices[idx].Value = decimal.Max(status.Cash.Sub(amount), decimal.Zero)
+		}
+	}
+
+	allocations := fillUnderweight(slices, amount)
+	targets := make(map[string]decimal.Decimal, len(slices))
+	prices := make(map[string]decimal.Decimal, len(slices))
+	invest := amount
+	for idx, slice := range slices {
+		if slice.IsCash {
+			invest = invest.Sub(allocations[idx])
+			continue
+		}
+		price := quotePrice(quotes[slice.Symbol], OrderActionBuy, extendedHours)
+		if !price.IsPositive() {
+			plan.unpriced(slice.Symbol, OrderActionBuy)
+			invest = invest.Sub(allocations[idx])
+			continue
+		}
+		targets[slice.Symbol] = allocations[idx]
+		prices[slice.Symbol] = price
+	}
+
+	var wholeShares map[string]int
+	remaining := invest
+	if !fractional {
+		wholeShares, remaining = AllocateWholeShares(targets, prices, invest)
+	}
+
+	for _, slice := range slices {
+		price, ok := prices[slice.Symbol]
+		if slice.IsCash || !ok {
+			continue
+		}
+		quantity := decimal.NewFromInt(int64(wholeShares[slice.Symbol]))
+		if fractional {
+			quantity = RoundShares(targets[slice.Symbol].Div(price), true)
+			remaining = remaining.Sub(quantity.Mul(price))
+		}
+		if quantity.IsPositive() {
+			plan.add(slice, OrderActionBuy, quantity, price, RebalanceOptions{})
+		}
+	}
+
+	plan.CashAfter = status.Cash.Sub(invest.Sub(remaining))
+	plan.Leftover = remaining
+	return plan
+}
+
+// fillUnderweight splits amount across slices so that the value-to-weight
+// ratio of the most underweight slices is raised to a common level, and
+// returns the dollars allocated to each slice
+func fillUnderweight(slices []SliceStatus, amount decimal.Decimal) []decimal.Decimal {
+	order := make([]int, len(slices))
+	for idx := range order {
+		order[idx] = idx
+	}
+	weight := func(idx int) decimal.Decimal {
+		return decimal.NewFromFloat(slices[idx].TargetWeight)
+	}
+	ratio := func(idx int) decimal.Decimal {
+		return slices[idx].Value.Div(weight(idx))
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return ratio(order[a]).LessThan(ratio(order[b]))
+	})
+
+	// Raise the k lowest ratios until their level reaches the next slice's
+	// ratio or the cash runs out
+	var level, weights, values decimal.Decimal
+	count := len(order)
+	for k, idx := range order {
+		weights = weights.Add(weight(idx))
+		values = values.Add(slices[idx].Value)
+		level = amount.Add(values).Div(weights)
+		if k+1 < len(order) && level.LessThanOrEqual(ratio(order[k+1])) {
+			count = k + 1
+			break
+		}
+	}
+
+	allocations := make([]decimal.Decimal, len(slices))
+	for _, idx := range order[:count] {
+		allocations[idx] = decimal.Max(level.Mul(weight(idx)).Sub(slices[idx].Value), decimal.Zero)
+	}
+	return allocations
+}
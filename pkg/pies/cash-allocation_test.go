@@ -0,0 +1,208 @@
+package pies
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// slicesAt returns the statuses of slices given as symbol, target weight
+// and value triples. The symbol "CASH" makes a cash slice.
+func slicesAt(t *testing.T, triples ...any) []SliceStatus {
+	t.Helper()
+
+	var slices []SliceStatus
+	for i := 0; i < len(triples); i += 3 {
+		symbol := triples[i].(string)
+		slices = append(slices, SliceStatus{
+			Symbol:       symbol,
+			TargetWeight: float64(triples[i+1].(int)),
+			Value:        decimal.RequireFromString(triples[i+2].(string)),
+			IsCash:       symbol == cashLabel,
+		})
+	}
+	return slices
+}
+
+func TestFillUnderweight(t *testing.T) {
+	tests := []struct {
+		name   string
+		slices []SliceStatus
+		amount string
+		want   []string // Dollars allocated to each slice
+	}{
+		{
+			// BND is at 5 dollars per weight point and VTI at 10, so 100
+			// only raises BND to 7.50
+			name:   "one slice absorbs the whole deposit",
+			slices: slicesAt(t, "VTI", 60, "600", "BND", 40, "200"),
+			amount: "100",
+			want:   []string{"0", "100"},
+		},
+		{
+			name:   "deposit equal to the drift",
+			slices: slicesAt(t, "VTI", 60, "600", "BND", 40, "200"),
+			amount: "200",
+			want:   []string{"0", "200"},
+		},
+		{
+			// Once BND catches up, the remaining 400 is split 60/40
+			name:   "deposit larger than the drift spills over pro rata",
+			slices: slicesAt(t, "VTI", 60, "600", "BND", 40, "200"),
+			amount: "600",
+			want:   []string{"240", "360"},
+		},
+		{
+			// VXUS at 2 dollars per weight point catches up with BND at 4,
+			// and then both rise to 6, short of VTI at 10
+			name:   "laggards rise together",
+			slices: slicesAt(t, "VTI", 50, "500", "BND", 25, "100", "VXUS", 25, "50"),
+			amount: "150",
+			want:   []string{"0", "50", "100"},
+		},
+		{
+			name:   "empty pie is funded by weight",
+			slices: slicesAt(t, "VTI", 60, "0", "BND", 40, "0"),
+			amount: "1000",
+			want:   []string{"600", "400"},
+		},
+		{
+			name:   "cash slice takes its share",
+			slices: slicesAt(t, "VTI", 50, "500", cashLabel, 50, "500"),
+			amount: "500",
+			want:   []string{"250", "250"},
+		},
+		{
+			name:   "zero amount",
+			slices: slicesAt(t, "VTI", 60, "600", "BND", 40, "200"),
+			amount: "0",
+			want:   []string{"0", "0"},
+		},
+		{
+			name:   "negative amount",
+			slices: slicesAt(t, "VTI", 60, "600", "BND", 40, "200"),
+			amount: "-100",
+			want:   []string{"0", "0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocations := fillUnderweight(tt.slices, decimal.RequireFromString(tt.amount))
+			if len(allocations) != len(tt.want) {
+				t.Fatalf("fillUnderweight() = %v, want %v", allocations, tt.want)
+			}
+			for idx, allocation := range allocations {
+				if !allocation.Equal(decimal.RequireFromString(tt.want[idx])) {
+					t.Errorf("fillUnderweight() = %v, want %v", allocations, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestAllocateCash(t *testing.T) {
+	// Every symbol asks 100
+	quotes := map[string]Quote{
+		"VTI": {Symbol: "VTI", Bid: decimal.NewFromInt(99), Ask: decimal.NewFromInt(100), Last: decimal.NewFromInt(100)},
+		"BND": {Symbol: "BND", Bid: decimal.NewFromInt(99), Ask: decimal.NewFromInt(100), Last: decimal.NewFromInt(100)},
+	}
+
+	tests := []struct {
+		name         string
+		slices       []SliceStatus
+		cash         string
+		amount       string
+		fractional   bool
+		want         []string // "SYMBOL QUANTITY"
+		wantCash     string
+		wantLeftover string
+	}{
+		{
+			name:         "one slice absorbs the whole deposit",
+			slices:       slicesAt(t, "VTI", 60, "600", "BND", 40, "200"),
+			cash:         "200",
+			amount:       "200",
+			fractional:   true,
+			want:         []string{"BND 2"},
+			wantCash:     "0",
+			wantLeftover: "0",
+		},
+		{
+			name:         "deposit larger than the drift spills over pro rata",
+			slices:       slicesAt(t, "VTI", 60, "600", "BND", 40, "200"),
+			cash:         "600",
+			amount:       "600",
+			fractional:   true,
+			want:         []string{"VTI 2.4", "BND 3.6"},
+			wantCash:     "0",
+			wantLeftover: "0",
+		},
+		{
+			name:         "whole shares leave the remainder",
+			slices:       slicesAt(t, "VTI", 60, "600", "BND", 40, "200"),
+			cash:         "650",
+			amount:       "250",
+			want:         []string{"BND 2"},
+			wantCash:     "450",
+			wantLeftover: "50",
+		},
+		{
+			// Only the cash beyond amount counts towards the cash slice, and
+			// its share of amount stays uninvested
+			name:         "cash slice keeps its share",
+			slices:       slicesAt(t, "VTI", 50, "500", cashLabel, 50, "1000"),
+			cash:         "1000",
+			amount:       "500",
+			fractional:   true,
+			want:         []string{"VTI 2.5"},
+			wantCash:     "750",
+			wantLeftover: "0",
+		},
+		{
+			name:         "amount smaller than a share",
+			slices:       slicesAt(t, "VTI", 60, "600", "BND", 40, "200"),
+			cash:         "50",
+			amount:       "50",
+			want:         nil,
+			wantCash:     "50",
+			wantLeftover: "50",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &PieStatus{Pie: "test", Slices: tt.slices, Cash: decimal.RequireFromString(tt.cash)}
+			plan := allocateCash(status, quotes, false, decimal.RequireFromString(tt.amount), tt.fractional)
+
+			var got []string
+			for _, planned := range plan.Orders {
+				if planned.Order.Action != OrderActionBuy {
+					t.Errorf("planned %s %s, want only buys", planned.Order.Action, planned.Order.Symbol)
+				}
+				got = append(got, planned.Order.Symbol+" "+planned.Order.Quantity.String())
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("allocateCash() orders = %q, want %q", got, tt.want)
+			}
+			if !plan.CashAfter.Equal(decimal.RequireFromString(tt.wantCash)) {
+				t.Errorf("CashAfter = %s, want %s", plan.CashAfter, tt.wantCash)
+			}
+			if !plan.Leftover.Equal(decimal.RequireFromString(tt.wantLeftover)) {
+				t.Errorf("Leftover = %s, want %s", plan.Leftover, tt.wantLeftover)
+			}
+		})
+	}
+}
+
+func TestAllocateCashRejectsNonPositiveAmounts(t *testing.T) {
+	investor := &Investor{}
+	for _, amount := range []string{"0", "-100"} {
+		_, err := investor.AllocateCash(t.Context(), Pie{}, decimal.RequireFromString(amount))
+		if want := "amount to allocate must be positive"; err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("AllocateCash(%s) error = %v, want %q", amount, err, want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package pies
+
+import "strings"
+
+// AssetTypeCash marks a slice whose target is uninvested cash rather than a
+// tradable symbol. Its value is the account's cash balance, it needs no
+// symbol, and rebalancing and cash allocation never spend the cash it
+// targets.
+const AssetTypeCash = "CASH"
+
+// cashLabel is the symbol reported for a cash slice without one
+const cashLabel = "CASH"
+
+// IsCash reports whether the slice targets uninvested cash
+func (s Slice) IsCash() bool {
+	return s.Pie == nil && strings.EqualFold(s.Asset.TypeName, AssetTypeCash)
+}
+
+// key identifies the slice's holding when matching slices across a pie. Cash
+// slices share the empty key, which no validated symbol can have.
+func (s Slice) key() string {
+	if s.IsCash() {
+		return ""
+	}
+	return normalizeSymbol(s.Asset.Symbol)
+}
+
+// key identifies the slice's holding like Slice.key
+func (s SliceStatus) key() string {
+	if s.IsCash {
+		return ""
+	}
+	return s.Symbol
+}
+
+// cashSlice returns the status of the pie's cash slice, if it has one
+func (s PieStatus) cashSlice() (SliceStatus, bool) {
+	for _, slice := range s.Slices {
+		if slice.IsCash {
+			return slice, true
+		}
+	}
+	return SliceStatus{}, false
+}
@@ -0,0 +1,200 @@
+package pies
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// The columns written by the CSV exporters. Columns are only ever added at
+// the end, so spreadsheets built on an export keep working.
+var (
+	positionsCSVColumns = []string{
+		"symbol", "quantity", "average_price", "current_price", "market_value", "unrealized_pl", "unrealized_pl_pct",
+		"long_quantity", "short_quantity", "cash_equivalent", "asset_type", "description", "day_pl",
+	}
+	ordersCSVColumns = []string{
+		"id", "parent_id", "symbol", "action", "type", "quantity", "limit_price", "stop_price",
+		"status", "raw_status", "filled_qty", "filled_price", "submitted_at", "filled_at", "strategy",
+	}
+	executionCSVColumns = []string{
+		"started_at", "finished_at", "pie", "pie_id", "account_id", "order_id", "symbol", "action",
+		"filled_qty", "filled_price", "filled_value", "cash_impact", "status",
+	}
+)
+
+// WritePositionsCSV writes one row per position under a header row. Money
+// and quantities are written as exact decimals.
+func WritePositionsCSV(w io.Writer, positions []Position) error {
+	return writeCSV(w, positionsCSVColumns, func(write func([]string) error) error {
+		for _, position := range positions {
+			err := write([]string{
+				csvText(position.Symbol),
+				position.Quantity.String(),
+				position.AveragePrice.String(),
+				position.CurrentPrice.String(),
+				position.MarketValue.String(),
+				position.UnrealizedPL.String(),
+				strconv.FormatFloat(position.UnrealizedPLPct, 'f', -1, 64),
+				position.LongQuantity.String(),
+				position.ShortQuantity.String(),
+				strconv.FormatBool(position.IsCashEquivalent()),
+				position.AssetType,
+				csvText(position.Description),
+				position.DayPL.String(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// WriteOrdersCSV writes one row per order under a header row, each order
+// followed by the orders of its strategy with their parent_id set. Times
+// are RFC 3339 and empty when not known.
+func WriteOrdersCSV(w io.Writer, orders []Order) error {
+	return writeCSV(w, ordersCSVColumns, func(write func([]string) error) error {
+		var writeOrder func(order Order, parentID string) error
+		writeOrder = func(order Order, parentID string) error {
+			var filledAt string
+			if order.FilledAt != nil {
+				filledAt = csvTime(*order.FilledAt)
+			}
+			err := write([]string{
+				csvText(order.ID),
+				csvText(parentID),
+				csvText(order.Symbol),
+				string(order.Action),
+				string(order.Type),
+				order.Quantity.String(),
+				csvOptionalDecimal(order.LimitPrice),
+				csvOptionalDecimal(order.StopPrice),
+				string(order.Status),
+				csvText(order.RawStatus),
+				order.FilledQty.String(),
+				order.FilledPrice.String(),
+				csvTime(order.SubmittedAt),
+				filledAt,
+				string(order.Strategy),
+			})
+			if err != nil {
+				return err
+			}
+			for _, child := range order.Children {
+				if err := writeOrder(child, order.ID); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		for _, order := range orders {
+			if err := writeOrder(order, ""); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// WriteExecutionReportCSV writes one row per order of the reports that
+// filled any shares, under a single header row. The brokerages report an
+// order's fills by their total quantity and average price, so each row
+// holds all of an order's fills. cash_impact is the cash the fills moved:
+// positive for sells and negative for buys.
+func WriteExecutionReportCSV(w io.Writer, reports ...*ExecutionReport) error {
+	return writeCSV(w, executionCSVColumns, func(write func([]string) error) error {
+		for _, report := range reports {
+			if report == nil {
+				continue
+			}
+			for _, executed := range report.Orders {
+				if !executed.FilledQty.IsPositive() {
+					continue
+				}
+
+				value := RoundCents(executed.FilledValue())
+				impact := value.Neg()
+				if executed.Request.Action == OrderActionSell {
+					impact = value
+				}
+				err := write([]string{
+					csvTime(report.StartedAt),
+					csvTime(report.FinishedAt),
+					csvText(report.Pie),
+					csvText(report.PieID),
+					csvText(report.AccountID),
+					csvText(executed.OrderID),
+					csvText(executed.Request.Symbol),
+					string(executed.Request.Action),
+					executed.FilledQty.String(),
+					executed.FilledPrice.String(),
+					value.StringFixed(centPlaces),
+					impact.StringFixed(centPlaces),
+					string(executed.Status),
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// writeCSV writes the header row columns followed by the rows passed to
+// write by rows
+func writeCSV(w io.Writer, columns []string, rows func(write func([]string) error) error) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(columns); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+	err := rows(func(row []string) error {
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("failed to write csv: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+	return nil
+}
+
+// csvText escapes free text so that spreadsheets do not run it as a
+// formula. Fields holding commas, quotes or line breaks are quoted by the
+// csv writer, and symbols such as BRK/B need no quoting.
+func csvText(s string) string {
+	if s != "" && strings.ContainsAny(s[:1], "=+-@\t\r") {
+		return "'" + s
+	}
+	return s
+}
+
+// csvTime formats t in RFC 3339, or empty when it is zero
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// csvOptionalDecimal formats d, or empty when it is nil
+func csvOptionalDecimal(d *decimal.Decimal) string {
+	if d == nil {
+		return ""
+	}
+	return d.String()
+}
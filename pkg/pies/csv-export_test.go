@@ -0,0 +1,161 @@
+package pies_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// requireGoldenCSV compares got with testdata/name, or rewrites the file
+// when the tests run with -update
+func requireGoldenCSV(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file, run with -update to create it: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s changed, run with -update if that is intended\n got:\n%s\nwant:\n%s", path, got, want)
+	}
+
+	// Every row must read back with the header's number of columns
+	rows, err := csv.NewReader(bytes.NewReader(got)).ReadAll()
+	if err != nil {
+		t.Fatalf("export does not read back as csv: %v", err)
+	}
+	for i, row := range rows[1:] {
+		if len(row) != len(rows[0]) {
+			t.Errorf("row %d has %d columns, want %d", i+1, len(row), len(rows[0]))
+		}
+	}
+}
+
+func TestWritePositionsCSV(t *testing.T) {
+	positions := []pies.Position{
+		{
+			Symbol: "VTI", Description: "Vanguard Total Stock Market ETF", AssetType: pies.AssetTypeEquity,
+			Quantity: dec(t, "10.5"), LongQuantity: dec(t, "10.5"), AveragePrice: dec(t, "200"),
+			CurrentPrice: dec(t, "250.25"), MarketValue: dec(t, "2627.625"), UnrealizedPL: dec(t, "527.625"),
+			UnrealizedPLPct: 25.125, DayPL: dec(t, "-3.15"),
+		},
+		{
+			Symbol: "BRK/B", Description: "Berkshire Hathaway, Class B", AssetType: pies.AssetTypeEquity,
+			Quantity: dec(t, "-2"), ShortQuantity: dec(t, "2"), AveragePrice: dec(t, "400"),
+			CurrentPrice: dec(t, "410"), MarketValue: dec(t, "-820"), UnrealizedPL: dec(t, "-20"),
+			UnrealizedPLPct: -2.5,
+		},
+		{
+			Symbol: "SWVXX", Description: `Schwab "Value Advantage" Money Fund`, AssetType: pies.AssetTypeCashEquivalent,
+			Quantity: dec(t, "1500"), LongQuantity: dec(t, "1500"), AveragePrice: dec(t, "1"),
+			CurrentPrice: dec(t, "1"), MarketValue: dec(t, "1500"),
+		},
+		{
+			Symbol: "XYZ", Description: "=HYPERLINK(\"http://example.com\")", AssetType: pies.AssetTypeEquity,
+			Quantity: dec(t, "1"), LongQuantity: dec(t, "1"),
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := pies.WritePositionsCSV(&buf, positions); err != nil {
+		t.Fatalf("WritePositionsCSV() error = %v", err)
+	}
+	requireGoldenCSV(t, "positions.csv", buf.Bytes())
+}
+
+func TestWriteOrdersCSV(t *testing.T) {
+	submitted := time.Date(2025, time.March, 3, 14, 30, 0, 0, time.UTC)
+	filled := submitted.Add(90 * time.Second)
+	limit := dec(t, "260")
+	stop := dec(t, "230")
+
+	orders := []pies.Order{
+		{
+			ID: "1002", Symbol: "BRK/B", Action: pies.OrderActionBuy, Type: pies.OrderTypeMarket,
+			Quantity: dec(t, "3"), Status: pies.OrderStatusFilled, RawStatus: "FILLED",
+			FilledQty: dec(t, "3"), FilledPrice: dec(t, "410.12"), SubmittedAt: submitted, FilledAt: &filled,
+		},
+		{
+			ID: "1001", Symbol: "VTI", Action: pies.OrderActionSell, Type: pies.OrderTypeLimit,
+			Quantity: dec(t, "10"), Status: pies.OrderStatusWorking, RawStatus: "WORKING",
+			SubmittedAt: submitted.Add(-time.Hour), Strategy: pies.OrderStrategyOCO,
+			Children: []pies.Order{
+				{
+					ID: "1001-1", Symbol: "VTI", Action: pies.OrderActionSell, Type: pies.OrderTypeLimit,
+					Quantity: dec(t, "10"), LimitPrice: &limit, Status: pies.OrderStatusWorking,
+					SubmittedAt: submitted.Add(-time.Hour),
+				},
+				{
+					ID: "1001-2", Symbol: "VTI", Action: pies.OrderActionSell, Type: pies.OrderTypeStop,
+					Quantity: dec(t, "10"), StopPrice: &stop, Status: pies.OrderStatusWorking,
+					SubmittedAt: submitted.Add(-time.Hour),
+				},
+			},
+		},
+		{
+			ID: "1000", Symbol: "BND", Action: pies.OrderActionBuy, Type: pies.OrderTypeMarket,
+			Quantity: dec(t, "5"), Status: pies.OrderStatusUnknown, RawStatus: "AWAITING_UR_OUT, partial",
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := pies.WriteOrdersCSV(&buf, orders); err != nil {
+		t.Fatalf("WriteOrdersCSV() error = %v", err)
+	}
+	requireGoldenCSV(t, "orders.csv", buf.Bytes())
+}
+
+func TestWriteExecutionReportCSV(t *testing.T) {
+	started := time.Date(2025, time.March, 3, 14, 30, 0, 0, time.UTC)
+	order := func(id, symbol string, action pies.OrderAction, status pies.OrderStatus, qty, price string) pies.ExecutedOrder {
+		return pies.ExecutedOrder{
+			Request:     pies.OrderRequest{Symbol: symbol, Action: action, Type: pies.OrderTypeMarket},
+			OrderID:     id,
+			Status:      status,
+			FilledQty:   dec(t, qty),
+			FilledPrice: dec(t, price),
+		}
+	}
+
+	reports := []*pies.ExecutionReport{
+		{
+			Pie: "Three Fund", PieID: "three-fund", AccountID: "12345678",
+			StartedAt: started, FinishedAt: started.Add(5 * time.Second),
+			Orders: []pies.ExecutedOrder{
+				order("1001", "VTI", pies.OrderActionSell, pies.OrderStatusFilled, "5", "250.005"),
+				order("1002", "BRK/B", pies.OrderActionBuy, pies.OrderStatusPartiallyFilled, "1.5", "410.10"),
+				order("1003", "BND", pies.OrderActionBuy, pies.OrderStatusRejected, "0", "0"),
+			},
+		},
+		nil,
+		{
+			Pie: "+Income", AccountID: "12345678",
+			StartedAt: started.Add(24 * time.Hour), FinishedAt: started.Add(24*time.Hour + time.Second),
+			Orders: []pies.ExecutedOrder{
+				order("2001", "SCHD", pies.OrderActionBuy, pies.OrderStatusFilled, "10", "27.333"),
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := pies.WriteExecutionReportCSV(&buf, reports...); err != nil {
+		t.Fatalf("WriteExecutionReportCSV() error = %v", err)
+	}
+	requireGoldenCSV(t, "execution-report.csv", buf.Bytes())
+}
@@ -0,0 +1,134 @@
+package pies
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// csvWeightNoise is how far the weights of an imported pie may sum away from
+// TotalWeight and still be normalized rather than rejected, to absorb
+// exports that round each weight
+const csvWeightNoise = 1.0
+
+// csvColumns maps the header names ImportCSV recognizes to the column they
+// hold, after lower-casing and trimming
+var csvColumns = map[string]string{
+	"symbol":        "symbol",
+	"ticker":        "symbol",
+	"weight":        "weight",
+	"target":        "weight",
+	"target %":      "weight",
+	"target weight": "weight",
+	"target (%)":    "weight",
+	"percentage":    "weight",
+	"percent":       "weight",
+	"allocation":    "weight",
+	"%":             "weight",
+	"name":          "name",
+	"security":      "name",
+	"description":   "name",
+}
+
+// ImportCSV reads a pie from a CSV export such as M1 Finance's, with one
+// slice per row. The header row names the columns: "symbol" or "ticker",
+// "weight", "target", "target %", "percentage" or "%", and optionally
+// "name". Other columns are ignored. Weights may carry a % sign and are
+// normalized when rounding leaves their sum slightly off 100. The returned
+// pie has no name.
+func ImportCSV(r io.Reader) (Pie, error) {
+	// Spreadsheet exports often start with a byte order mark, which the csv
+	// reader takes as part of a quoted first field
+	buffered := bufio.NewReader(r)
+	if bom, err := buffered.Peek(3); err == nil && string(bom) == "\ufeff" {
+		buffered.Discard(3)
+	}
+
+	reader := csv.NewReader(buffered)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return Pie{}, errors.New("csv has no header row")
+	}
+	if err != nil {
+		return Pie{}, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for idx, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if column, ok := csvColumns[name]; ok {
+			if _, seen := columns[column]; !seen {
+				columns[column] = idx
+			}
+		}
+	}
+	if _, ok := columns["symbol"]; !ok {
+		return Pie{}, errors.New("csv header has no symbol or ticker column")
+	}
+	if _, ok := columns["weight"]; !ok {
+		return Pie{}, errors.New("csv header has no weight, target or percentage column")
+	}
+
+	var pie Pie
+	seen := make(map[string]int)
+	var total float64
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Pie{}, fmt.Errorf("failed to read csv: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		symbol := normalizeSymbol(csvField(record, columns["symbol"]))
+		rawWeight := csvField(record, columns["weight"])
+		if symbol == "" && rawWeight == "" {
+			continue
+		}
+		if symbol == "" {
+			return Pie{}, fmt.Errorf("line %d: symbol is empty", line)
+		}
+		if first, ok := seen[symbol]; ok {
+			return Pie{}, fmt.Errorf("line %d: %s is already on line %d", line, symbol, first)
+		}
+		seen[symbol] = line
+
+		weight, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(rawWeight, "%")), 64)
+		if err != nil {
+			return Pie{}, fmt.Errorf("line %d: invalid weight %q for %s", line, rawWeight, symbol)
+		}
+
+		slice := Slice{Weight: weight, Asset: Asset{Symbol: symbol}}
+		if idx, ok := columns["name"]; ok {
+			slice.Asset.Name = csvField(record, idx)
+		}
+		pie.Slices = append(pie.Slices, slice)
+		total += weight
+	}
+
+	if len(pie.Slices) > 0 && math.Abs(total-TotalWeight) <= csvWeightNoise {
+		pie = pie.Normalize()
+	}
+	if err := pie.Validate(); err != nil {
+		return Pie{}, err
+	}
+	return pie, nil
+}
+
+// csvField returns the trimmed field at idx, or "" for short records
+func csvField(record []string, idx int) string {
+	if idx >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[idx])
+}
@@ -0,0 +1,89 @@
+package pies_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+func TestImportCSVFixtures(t *testing.T) {
+	tests := []struct {
+		file string
+		want []string // "SYMBOL WEIGHT NAME", weights to six digits
+	}{
+		{
+			// M1's pie export: a BOM, quoted fields, rounded target
+			// percentages and columns that are not imported
+			file: "testdata/m1-export.csv",
+			want: []string{
+				"VTI 33.3333 Vanguard Total Stock Market ETF",
+				"VXUS 33.3333 Vanguard Total International Stock ETF",
+				"BND 33.3333 Vanguard Total Bond Market ETF",
+			},
+		},
+		{
+			// A hand-made sheet: CRLF line endings, padded headers and
+			// fields, lower-case symbols, % signs and trailing empty rows
+			file: "testdata/spreadsheet.csv",
+			want: []string{"VTI 60", "VXUS 25", "BND 15"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			f, err := os.Open(tt.file)
+			if err != nil {
+				t.Fatalf("failed to open fixture: %v", err)
+			}
+			defer f.Close()
+
+			pie, err := pies.ImportCSV(f)
+			if err != nil {
+				t.Fatalf("ImportCSV() error = %v", err)
+			}
+
+			var got []string
+			var total float64
+			for _, slice := range pie.Slices {
+				got = append(got, strings.TrimSpace(fmt.Sprintf("%s %.6g %s", slice.Asset.Symbol, slice.Weight, slice.Asset.Name)))
+				total += slice.Weight
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("slices =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			if total != pies.TotalWeight {
+				t.Errorf("weights sum to %v, want %v", total, pies.TotalWeight)
+			}
+		})
+	}
+}
+
+func TestImportCSVErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		wantErr string
+	}{
+		{name: "empty", csv: "", wantErr: "csv has no header row"},
+		{name: "no symbol column", csv: "fund,weight\nVTI,100\n", wantErr: "csv header has no symbol or ticker column"},
+		{name: "no weight column", csv: "symbol,name\nVTI,Total\n", wantErr: "csv header has no weight, target or percentage column"},
+		{name: "duplicate symbol", csv: "symbol,weight\nVTI,50\nBND,25\n vti ,25\n", wantErr: "line 4: VTI is already on line 2"},
+		{name: "missing symbol", csv: "symbol,weight\nVTI,50\n,50\n", wantErr: "line 3: symbol is empty"},
+		{name: "invalid weight", csv: "symbol,weight\nVTI,sixty\nBND,40\n", wantErr: `line 2: invalid weight "sixty" for VTI`},
+		{name: "no rows", csv: "symbol,weight\n", wantErr: "pie has no slices"},
+		{name: "weights far from 100", csv: "symbol,weight\nVTI,60\nBND,30\n", wantErr: "slice weights sum to 90, want 100"},
+		{name: "fractions", csv: "symbol,weight\nVTI,0.6\nBND,0.4\n", wantErr: "slice weights sum to 1, want 100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := pies.ImportCSV(strings.NewReader(tt.csv))
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ImportCSV() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
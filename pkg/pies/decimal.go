@@ -0,0 +1,62 @@
+package pies
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// Money, prices and share quantities are decimal.Decimal values so that
+// sums and products are exact. They are rounded only where a brokerage
+// needs a fixed precision: share quantities with RoundShares and dollar
+// amounts with RoundCents. Weights stay float64 percentages.
+
+// centPlaces is the number of decimal places dollar amounts are rounded to
+const centPlaces = 2
+
+// floatPlaces is the number of decimal places NewDecimal keeps of a float
+const floatPlaces = 9
+
+// hundred converts between percentages and fractions
+var hundred = decimal.NewFromInt(100)
+
+// basisPoints converts between basis points and fractions
+var basisPoints = decimal.NewFromInt(10000)
+
+// RoundShares rounds a share quantity towards zero to what can be traded:
+// whole shares, or fractional shares to six decimal places
+func RoundShares(quantity decimal.Decimal, fractional bool) decimal.Decimal {
+	if !fractional {
+		return quantity.Truncate(0)
+	}
+	return quantity.Truncate(fractionalPrecision)
+}
+
+// RoundCents rounds a dollar amount to whole cents, half to even
+func RoundCents(amount decimal.Decimal) decimal.Decimal {
+	return amount.RoundBank(centPlaces)
+}
+
+// NewDecimal converts a float64 to a decimal rounded to nine decimal
+// places, so that float noise such as 9.999999999999998 does not survive
+// the conversion
+func NewDecimal(f float64) decimal.Decimal {
+	return decimal.NewFromFloat(f).Round(floatPlaces)
+}
+
+// DecimalPtr returns a pointer to d, for the optional fields of
+// OrderRequest
+func DecimalPtr(d decimal.Decimal) *decimal.Decimal {
+	return &d
+}
+
+// weightOf returns weight percent of total
+func weightOf(total decimal.Decimal, weight float64) decimal.Decimal {
+	return total.Mul(decimal.NewFromFloat(weight)).Div(hundred)
+}
+
+// percentOf returns part as a percentage of total, or 0 when total is zero
+func percentOf(part, total decimal.Decimal) float64 {
+	if total.IsZero() {
+		return 0
+	}
+	return part.Mul(hundred).Div(total).InexactFloat64()
+}
@@ -0,0 +1,129 @@
+package pies_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/alysoliman1/money-pies/pkg/pies/piestest"
+	"github.com/shopspring/decimal"
+)
+
+func TestRoundShares(t *testing.T) {
+	tests := []struct {
+		quantity   string
+		fractional bool
+		want       string
+	}{
+		{quantity: "9.999999999", fractional: false, want: "9"},
+		{quantity: "9.999999999", fractional: true, want: "9.999999"},
+		{quantity: "10", fractional: false, want: "10"},
+		{quantity: "0.9999999", fractional: false, want: "0"},
+		{quantity: "1.2345675", fractional: true, want: "1.234567"},
+		{quantity: "-3.7", fractional: false, want: "-3"},
+		{quantity: "-1.2345678", fractional: true, want: "-1.234567"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s fractional=%t", tt.quantity, tt.fractional), func(t *testing.T) {
+			got := pies.RoundShares(dec(t, tt.quantity), tt.fractional)
+			if !got.Equal(dec(t, tt.want)) {
+				t.Errorf("RoundShares(%s, %t) = %s, want %s", tt.quantity, tt.fractional, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundCents(t *testing.T) {
+	tests := []struct {
+		amount string
+		want   string
+	}{
+		{amount: "10.004", want: "10"},
+		{amount: "10.006", want: "10.01"},
+		{amount: "10.005", want: "10"},
+		{amount: "10.015", want: "10.02"},
+		{amount: "10.0051", want: "10.01"},
+		{amount: "-10.015", want: "-10.02"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.amount, func(t *testing.T) {
+			got := pies.RoundCents(dec(t, tt.amount))
+			if !got.Equal(dec(t, tt.want)) {
+				t.Errorf("RoundCents(%s) = %s, want %s", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDecimalDropsFloatNoise(t *testing.T) {
+	tests := []struct {
+		f    float64
+		want string
+	}{
+		{f: 0.1 + 0.2, want: "0.3"},
+		{f: 9.999999999999998, want: "10"},
+		{f: 1.005, want: "1.005"},
+		{f: 123.456789012, want: "123.456789012"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got := pies.NewDecimal(tt.f)
+			if !got.Equal(dec(t, tt.want)) {
+				t.Errorf("NewDecimal(%v) = %s, want %s", tt.f, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocatingAcrossManySlicesKeepsEveryCent(t *testing.T) {
+	// 30 slices whose weights and dollar targets have no exact binary
+	// representation
+	pie := pies.Pie{Name: "thirty"}
+	for i := range 30 {
+		weight := 3.5
+		if i < 10 {
+			weight = 3
+		}
+		pie.Slices = append(pie.Slices, pies.Slice{Weight: weight, Asset: pies.Asset{Symbol: fmt.Sprintf("S%02d", i)}})
+	}
+
+	b := piestest.EmptyAccount(dec(t, "10000.01"))
+	b.SetFractional(true)
+	for _, slice := range pie.Slices {
+		b.SetPrice(slice.Asset.Symbol, dec(t, "33.33"))
+	}
+	investor := &pies.Investor{BrokerageClient: b}
+
+	amount := dec(t, "1000.01")
+	plan, err := investor.AllocateCash(context.Background(), pie, amount)
+	if err != nil {
+		t.Fatalf("AllocateCash() error = %v", err)
+	}
+	if len(plan.Orders) != len(pie.Slices) {
+		t.Fatalf("AllocateCash() planned %d orders, want %d", len(plan.Orders), len(pie.Slices))
+	}
+
+	spent := decimal.Zero
+	for _, planned := range plan.Orders {
+		quantity := planned.Order.Quantity
+		if !quantity.Equal(pies.RoundShares(quantity, true)) {
+			t.Errorf("%s: quantity %s has more than six decimal places", planned.Order.Symbol, quantity)
+		}
+		spent = spent.Add(quantity.Mul(planned.Price))
+	}
+
+	// Every dollar is either spent or left over, exactly
+	if got := spent.Add(plan.Leftover); !got.Equal(amount) {
+		t.Errorf("spent %s + leftover %s = %s, want %s", spent, plan.Leftover, got, amount)
+	}
+	if got, want := plan.CashAfter, plan.Cash.Sub(spent); !got.Equal(want) {
+		t.Errorf("CashAfter = %s, want %s", got, want)
+	}
+	if plan.Leftover.IsNegative() || plan.Leftover.GreaterThan(dec(t, "0.01")) {
+		t.Errorf("Leftover = %s, want at most a cent", plan.Leftover)
+	}
+}
@@ -0,0 +1,78 @@
+package pies
+
+import (
+	"fmt"
+	"math"
+)
+
+// DriftBand is how far a slice may drift from its target weight before it
+// needs rebalancing. A slice breaches its band when its drift exceeds either
+// threshold that is set; a slice sitting exactly on the edge of its band
+// does not. A band with neither threshold set is breached by any drift.
+type DriftBand struct {
+	// Absolute is the allowed drift in percentage points, e.g. 5 lets a 60%
+	// slice range from 55% to 65%. Zero disables it.
+	Absolute float64 `json:"absolute"`
+
+	// Relative is the allowed drift as a fraction of the target weight, e.g.
+	// 0.25 lets a 60% slice range from 45% to 75%. Zero disables it.
+	Relative float64 `json:"relative"`
+}
+
+// validate checks that the band's thresholds are not negative
+func (b DriftBand) validate() error {
+	if !(b.Absolute >= 0) || !(b.Relative >= 0) {
+		return fmt.Errorf("drift band thresholds must not be negative, got absolute %v and relative %v", b.Absolute, b.Relative)
+	}
+	return nil
+}
+
+// limit returns the allowed drift in percentage points for a slice with
+// targetWeight: the tighter of the thresholds that are set
+func (b DriftBand) limit(targetWeight float64) float64 {
+	limit := math.Inf(1)
+	if b.Absolute > 0 {
+		limit = b.Absolute
+	}
+	if b.Relative > 0 {
+		limit = math.Min(limit, b.Relative*targetWeight)
+	}
+	if math.IsInf(limit, 1) {
+		return 0
+	}
+	return limit
+}
+
+// bandFor returns the band that applies to slice
+func (p Pie) bandFor(slice Slice) DriftBand {
+	if slice.Band != nil {
+		return *slice.Band
+	}
+	return p.Band
+}
+
+// Breaches reports whether the slice has drifted outside its band
+func (s SliceStatus) Breaches() bool {
+	return math.Abs(s.Drift)-s.Band.limit(s.TargetWeight) > weightTolerance
+}
+
+// BandEdge returns the weight at the edge of the slice's band on the side it
+// has drifted to
+func (s SliceStatus) BandEdge() float64 {
+	limit := s.Band.limit(s.TargetWeight)
+	if s.Drift < 0 {
+		return s.TargetWeight - limit
+	}
+	return s.TargetWeight + limit
+}
+
+// NeedsRebalance returns the slices that have drifted outside their band
+func (s PieStatus) NeedsRebalance() []SliceStatus {
+	var breaching []SliceStatus
+	for _, slice := range s.Slices {
+		if slice.Breaches() {
+			breaching = append(breaching, slice)
+		}
+	}
+	return breaching
+}
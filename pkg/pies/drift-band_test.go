@@ -0,0 +1,117 @@
+package pies_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+func TestSliceStatusBreaches(t *testing.T) {
+	tests := []struct {
+		name   string
+		target float64
+		weight float64
+		band   pies.DriftBand
+		want   bool
+	}{
+		{name: "inside an absolute band", target: 60, weight: 63, band: pies.DriftBand{Absolute: 5}},
+		{name: "on the upper absolute edge", target: 60, weight: 65, band: pies.DriftBand{Absolute: 5}},
+		{name: "on the lower absolute edge", target: 60, weight: 55, band: pies.DriftBand{Absolute: 5}},
+		{name: "just past the absolute edge", target: 60, weight: 65.001, band: pies.DriftBand{Absolute: 5}, want: true},
+		{name: "just below the lower edge", target: 60, weight: 54.999, band: pies.DriftBand{Absolute: 5}, want: true},
+		{name: "on the relative edge", target: 60, weight: 75, band: pies.DriftBand{Relative: 0.25}},
+		{name: "past the relative edge", target: 60, weight: 75.01, band: pies.DriftBand{Relative: 0.25}, want: true},
+		{name: "on the tighter of both edges", target: 20, weight: 25, band: pies.DriftBand{Absolute: 10, Relative: 0.25}},
+		{name: "past the tighter of both edges", target: 20, weight: 25.1, band: pies.DriftBand{Absolute: 10, Relative: 0.25}, want: true},
+		// 35 - 33.3 is 1.7000000000000028 in float64
+		{name: "on an edge with rounding error", target: 33.3, weight: 35, band: pies.DriftBand{Absolute: 1.7}},
+		{name: "on target without a band", target: 60, weight: 60},
+		{name: "any drift without a band", target: 60, weight: 60.01, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slice := pies.SliceStatus{
+				Symbol:        "VTI",
+				CurrentWeight: tt.weight,
+				TargetWeight:  tt.target,
+				Drift:         tt.weight - tt.target,
+				Band:          tt.band,
+			}
+			if got := slice.Breaches(); got != tt.want {
+				t.Errorf("Breaches() = %v, want %v for drift %v", got, tt.want, slice.Drift)
+			}
+		})
+	}
+}
+
+func TestBandEdge(t *testing.T) {
+	band := pies.DriftBand{Absolute: 5}
+	over := pies.SliceStatus{TargetWeight: 60, Drift: 8, Band: band}
+	under := pies.SliceStatus{TargetWeight: 60, Drift: -8, Band: band}
+
+	if got := over.BandEdge(); got != 65 {
+		t.Errorf("BandEdge() of an overweight slice = %v, want 65", got)
+	}
+	if got := under.BandEdge(); got != 55 {
+		t.Errorf("BandEdge() of an underweight slice = %v, want 55", got)
+	}
+}
+
+func TestBandBoundaryAcrossStatusAndRebalancing(t *testing.T) {
+	pie := testPie("VTI", 60, "BND", 40)
+	pie.Band = pies.DriftBand{Absolute: 10}
+
+	tests := []struct {
+		name      string
+		vti, bnd  string
+		breaching []string
+		all       []string // Plan with RebalanceBreaching
+		edge      []string // Plan with RebalanceBreaching and ToBandEdge
+	}{
+		{name: "inside the band", vti: "65", bnd: "35"},
+		{name: "exactly on the edge", vti: "70", bnd: "30"},
+		{
+			name: "past the edge", vti: "72", bnd: "28",
+			breaching: []string{"VTI", "BND"},
+			all:       []string{"SELL 12 VTI", "BUY 12 BND"},
+			edge:      []string{"SELL 2 VTI", "BUY 2 BND"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := holdingFake(t, pies.AccountKindCash, "0", "VTI", tt.vti, "BND", tt.bnd)
+			investor := &pies.Investor{BrokerageClient: b}
+
+			status, err := investor.GetPieStatus(t.Context(), pie)
+			if err != nil {
+				t.Fatalf("GetPieStatus() error = %v", err)
+			}
+			var breaching []string
+			for _, slice := range status.NeedsRebalance() {
+				breaching = append(breaching, slice.Symbol)
+			}
+			if strings.Join(breaching, ",") != strings.Join(tt.breaching, ",") {
+				t.Errorf("NeedsRebalance() = %v, want %v", breaching, tt.breaching)
+			}
+
+			for _, mode := range []struct {
+				opts pies.RebalanceOptions
+				want []string
+			}{
+				{pies.RebalanceOptions{Mode: pies.RebalanceBreaching, AllowSells: true}, tt.all},
+				{pies.RebalanceOptions{Mode: pies.RebalanceBreaching, ToBandEdge: true, AllowSells: true}, tt.edge},
+			} {
+				plan, err := investor.ComputeRebalancePlan(t.Context(), pie, mode.opts)
+				if err != nil {
+					t.Fatalf("ComputeRebalancePlan(%+v) error = %v", mode.opts, err)
+				}
+				if got := plannedTrades(plan); strings.Join(got, ", ") != strings.Join(mode.want, ", ") {
+					t.Errorf("ComputeRebalancePlan(%+v) orders = %v, want %v", mode.opts, got, mode.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package pies
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/logging"
+	"github.com/shopspring/decimal"
+)
+
+// dryRunIDPrefix marks the IDs of orders that were never sent to a brokerage
+const dryRunIDPrefix = "dry-run-"
+
+// QuoteSource provides the prices simulated orders fill at
+type QuoteSource interface {
+	GetQuote(ctx context.Context, symbol string) (*Quote, error)
+}
+
+// DryRunOrders records simulated orders in place of submitting them. Orders
+// are remembered in memory and fill at the price reported by Quotes the first
+// time their status is checked, so code waiting for fills behaves as it would
+// against a brokerage.
+type DryRunOrders struct {
+	Quotes QuoteSource
+	Logger *slog.Logger
+
+	// Fractional sizes orders given by Amount in fractional shares instead
+	// of whole ones
+	Fractional bool
+
+	mu     sync.Mutex
+	nextID int
+	orders map[string]*Order
+}
+
+// NewDryRunOrders creates an empty order book filling at prices from quotes.
+// Simulated orders are logged to logger, or to slog.Default if it is nil.
+func NewDryRunOrders(quotes QuoteSource, logger *slog.Logger) *DryRunOrders {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &DryRunOrders{
+		Quotes: quotes,
+		Logger: logging.RedactLogger(logger),
+		orders: make(map[string]*Order),
+	}
+}
+
+// IsDryRunOrderID reports whether orderID belongs to a simulated order
+func IsDryRunOrderID(orderID string) bool {
+	return strings.HasPrefix(orderID, dryRunIDPrefix)
+}
+
+// Place records order as submitted and returns it with a synthetic ID.
+// Orders sized by Amount are converted to shares at the current quote, whole
+// ones unless Fractional is set.
+func (d *DryRunOrders) Place(ctx context.Context, accountID string, order OrderRequest) (*Order, error) {
+	order, err := ResolveAmount(ctx, d.Quotes, order, d.Fractional)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.nextID++
+	placed := Order{
+		ID:          fmt.Sprintf("%s%d", dryRunIDPrefix, d.nextID),
+		Symbol:      order.Symbol,
+		Action:      order.Action,
+		Type:        order.Type,
+		Quantity:    order.Quantity,
+		LimitPrice:  order.LimitPrice,
+		StopPrice:   order.StopPrice,
+		Status:      OrderStatusWorking,
+		SubmittedAt: time.Now(),
+	}
+	d.orders[placed.ID] = &placed
+
+	d.Logger.InfoContext(ctx, "dry run: order not submitted",
+		slog.String("account", accountID),
+		slog.String("order_id", placed.ID),
+		slog.String("symbol", order.Symbol),
+		slog.String("action", string(order.Action)),
+		slog.String("type", string(order.Type)),
+		slog.String("quantity", order.Quantity.String()))
+
+	result := placed
+	return &result, nil
+}
+
+// Replace marks the simulated order orderID, if there is one, as replaced
+// and records newOrder in its place
+func (d *DryRunOrders) Replace(ctx context.Context, accountID string, orderID string, newOrder OrderRequest) (*Order, error) {
+	d.Logger.InfoContext(ctx, "dry run: order not replaced",
+		slog.String("account", accountID),
+		slog.String("order_id", orderID))
+	if err := d.close(orderID, OrderStatusReplaced); err != nil {
+		return nil, err
+	}
+
+	return d.Place(ctx, accountID, newOrder)
+}
+
+// Cancel marks the simulated order orderID as cancelled. Cancelling an order
+// that was placed for real is only logged.
+func (d *DryRunOrders) Cancel(ctx context.Context, accountID string, orderID string) error {
+	d.Logger.InfoContext(ctx, "dry run: order not cancelled",
+		slog.String("account", accountID),
+		slog.String("order_id", orderID))
+
+	return d.close(orderID, OrderStatusCancelled)
+}
+
+// close moves the open simulated order orderID to status
+func (d *DryRunOrders) close(orderID string, status OrderStatus) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	order, ok := d.orders[orderID]
+	if !ok {
+		return nil
+	}
+	if !order.Status.IsOpen() {
+		return fmt.Errorf("order %s is already %s: %w", orderID, order.Status, ErrOrderNotOpen)
+	}
+
+	order.Status = status
+	return nil
+}
+
+// Status returns the simulated order orderID, filling it if the current
+// quote allows. The boolean is false when orderID is not a simulated order.
+func (d *DryRunOrders) Status(ctx context.Context, orderID string) (*Order, bool, error) {
+	d.mu.Lock()
+	order, ok := d.orders[orderID]
+	open := ok && order.Status.IsOpen()
+	d.mu.Unlock()
+	if !ok {
+		return nil, false, nil
+	}
+
+	if open {
+		quote, err := d.Quotes.GetQuote(ctx, order.Symbol)
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to get quote for dry run order %s: %w", orderID, err)
+		}
+
+		d.mu.Lock()
+		if order.Status.IsOpen() {
+			fillDryRunOrder(order, quote.Last)
+		}
+		d.mu.Unlock()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result := *order
+	return &result, true, nil
+}
+
+// fillDryRunOrder fills order at price unless its limit or stop price has
+// not been reached yet
+func fillDryRunOrder(order *Order, price decimal.Decimal) {
+	if order.Type == OrderTypeLimit && order.LimitPrice != nil {
+		limit := *order.LimitPrice
+		if (order.Action == OrderActionBuy && price.GreaterThan(limit)) ||
+			(order.Action == OrderActionSell && price.LessThan(limit)) {
+			return
+		}
+	}
+	if order.Type == OrderTypeStop && order.StopPrice != nil {
+		stop := *order.StopPrice
+		if (order.Action == OrderActionBuy && price.LessThan(stop)) ||
+			(order.Action == OrderActionSell && price.GreaterThan(stop)) {
+			return
+		}
+	}
+
+	now := time.Now()
+	order.Status = OrderStatusFilled
+	order.FilledQty = order.Quantity
+	order.FilledPrice = price
+	order.FilledAt = &now
+}
+
+// DryRunClient wraps a BrokerageClient so that orders are simulated with
+// DryRunOrders instead of being submitted, while every read goes through to
+// the wrapped client
+type DryRunClient struct {
+	BrokerageClient
+
+	orders *DryRunOrders
+}
+
+// NewDryRunClient wraps client in dry-run mode. Simulated orders fill at
+// client's quotes and are logged to logger, or to slog.Default if it is nil.
+// They are sized in fractional shares when client trades them.
+func NewDryRunClient(client BrokerageClient, logger *slog.Logger) *DryRunClient {
+	orders := NewDryRunOrders(client, logger)
+	if trader, ok := client.(FractionalTrader); ok {
+		orders.Fractional = trader.SupportsFractionalShares()
+	}
+
+	return &DryRunClient{
+		BrokerageClient: client,
+		orders:          orders,
+	}
+}
+
+// SupportsFractionalShares reports whether the wrapped client trades
+// fractional shares
+func (c *DryRunClient) SupportsFractionalShares() bool {
+	return c.orders.Fractional
+}
+
+func (c *DryRunClient) PlaceOrder(ctx context.Context, accountID string, order OrderRequest) (*Order, error) {
+	return c.orders.Place(ctx, accountID, order)
+}
+
+func (c *DryRunClient) ReplaceOrder(ctx context.Context, accountID string, orderID string, newOrder OrderRequest) (*Order, error) {
+	return c.orders.Replace(ctx, accountID, orderID, newOrder)
+}
+
+func (c *DryRunClient) CancelPendingOrder(ctx context.Context, accountID string, orderID string) error {
+	return c.orders.Cancel(ctx, accountID, orderID)
+}
+
+func (c *DryRunClient) GetOrderStatus(ctx context.Context, accountID string, orderID string) (*Order, error) {
+	if order, ok, err := c.orders.Status(ctx, orderID); ok {
+		return order, err
+	}
+
+	return c.BrokerageClient.GetOrderStatus(ctx, accountID, orderID)
+}
@@ -0,0 +1,432 @@
+package pies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	defaultPollInterval    = time.Second
+	defaultMaxPollInterval = 30 * time.Second
+	defaultFillTimeout     = 5 * time.Minute
+)
+
+// ExecuteOptions controls how ExecutePlan submits a plan's orders
+type ExecuteOptions struct {
+	// PollInterval is the first wait between order status checks, doubling
+	// after each check up to MaxPollInterval. Zero values use 1s and 30s.
+	PollInterval    time.Duration
+	MaxPollInterval time.Duration
+
+	// FillTimeout is how long to wait for each batch of orders to fill.
+	// Zero uses 5 minutes.
+	FillTimeout time.Duration
+
+	// CancelUnfilled cancels orders still open once FillTimeout has passed.
+	// Without it they are left working and reported as open.
+	CancelUnfilled bool
+
+	// CashAccount limits the buys to the account's settled cash plus the
+	// proceeds of the plan's sells, as required by accounts without margin
+	CashAccount bool
+
+	// OnUpdate is called with an order once it is placed, or fails to be,
+	// and again once waiting for it ends, e.g. to show progress. Optional.
+	OnUpdate func(ExecutedOrder)
+}
+
+// withDefaults fills in the zero values of opts
+func (o ExecuteOptions) withDefaults() ExecuteOptions {
+	if o.PollInterval <= 0 {
+		o.PollInterval = defaultPollInterval
+	}
+	if o.MaxPollInterval <= 0 {
+		o.MaxPollInterval = defaultMaxPollInterval
+	}
+	if o.FillTimeout <= 0 {
+		o.FillTimeout = defaultFillTimeout
+	}
+	return o
+}
+
+// ExecutionReport records what happened to every order of an executed plan
+type ExecutionReport struct {
+	Pie          string          `json:"pie"`
+	PieID        string          `json:"pie_id,omitempty"`
+	AccountID    string          `json:"account_id"`
+	StartedAt    time.Time       `json:"started_at"`
+	FinishedAt   time.Time       `json:"finished_at"`
+	Orders       []ExecutedOrder `json:"orders"`
+	Skipped      []SkippedTrade  `json:"skipped,omitempty"` // Buys scaled down to nothing
+	SellProceeds decimal.Decimal `json:"sell_proceeds"`     // Value of the sells' actual fills
+	BuyingCash   decimal.Decimal `json:"buying_cash"`       // Cash the buys were limited to
+
+	// RiskWarnings are the breaches of the investor's RiskChecker rules that
+	// only warn
+	RiskWarnings []RiskViolation `json:"risk_warnings,omitempty"`
+}
+
+// ExecutedOrder is a planned order and its outcome. OrderID is set for every
+// order the brokerage accepted, even if waiting for it failed afterwards.
+type ExecutedOrder struct {
+	Planned     PlannedOrder    `json:"planned"`
+	Request     OrderRequest    `json:"request"` // As submitted, after any scaling
+	OrderID     string          `json:"order_id,omitempty"`
+	Status      OrderStatus     `json:"status,omitempty"`
+	FilledQty   decimal.Decimal `json:"filled_qty"`
+	FilledPrice decimal.Decimal `json:"filled_price"`
+	Error       string          `json:"error,omitempty"`
+	Duplicate   bool            `json:"duplicate,omitempty"` // Refused as a repeat of a recent order, see ErrDuplicateOrder
+}
+
+// FilledValue returns the dollar value of the order's fills
+func (o ExecutedOrder) FilledValue() decimal.Decimal {
+	return o.FilledQty.Mul(o.FilledPrice)
+}
+
+// HistoryStore keeps a record of the plans the investor executes, see the
+// store package
+type HistoryStore interface {
+	SaveExecution(plan *RebalancePlan, report *ExecutionReport) error
+}
+
+// ExecutePlan places the plan's sells, waits for them to fill and then
+// places its buys, scaled down proportionally when the sells brought in less
+// than planned. Orders are polled with exponential backoff until they close
+// or ExecuteOptions.FillTimeout passes.
+//
+// The report lists every order placed, so when the context is cancelled or
+// an order fails the caller still knows which orders are at the brokerage.
+// An error is returned alongside the report if any order failed. Orders the
+// brokerage refused as duplicates of ones placed moments before are marked
+// Duplicate, and the error then matches ErrDuplicateOrder, so the caller can
+// tell that nothing new was placed for them.
+//
+// With a RiskChecker the plan is checked, see CheckRisk, before any order
+// is placed. A breach of a rule that refuses orders stops the execution
+// with a *RiskError; the warnings are logged and recorded in the report.
+//
+// With a PositionLedger the fills are recorded against the plan's pie. An
+// order still open when ExecutePlan returns is recorded as far as it has
+// filled, so its later fills show up as discrepancies. With a HistoryStore
+// the plan and its report are saved once it has placed any order. With a
+// Notifier the user is told how the execution went.
+func (i *Investor) ExecutePlan(ctx context.Context, plan *RebalancePlan, opts ExecuteOptions) (*ExecutionReport, error) {
+	if i.BrokerageClient == nil {
+		return nil, errors.New("investor has no brokerage client")
+	}
+
+	report := &ExecutionReport{
+		Pie:       plan.Pie,
+		PieID:     plan.PieID,
+		AccountID: plan.AccountID,
+		StartedAt: time.Now(),
+		Orders:    []ExecutedOrder{},
+	}
+	err := i.executePlan(ctx, plan, report, opts.withDefaults())
+	report.FinishedAt = time.Now()
+
+	if i.History != nil && len(report.Orders) > 0 {
+		if saveErr := i.History.SaveExecution(plan, report); saveErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to save execution history: %w", saveErr))
+		}
+	}
+	i.notifyExecution(ctx, plan, report, err)
+	return report, err
+}
+
+// notifyExecution tells the investor's Notifier how executing plan went
+func (i *Investor) notifyExecution(ctx context.Context, plan *RebalancePlan, report *ExecutionReport, err error) {
+	event := Event{
+		Kind:    EventExecutionCompleted,
+		Time:    report.FinishedAt,
+		Pie:     plan.Pie,
+		Message: fmt.Sprintf("Rebalanced %s: %s", plan.Pie, describeOrders(plan)),
+		Plan:    plan,
+		Report:  report,
+	}
+	if err != nil {
+		event.Kind = EventExecutionFailed
+		event.Message = fmt.Sprintf("Rebalance of %s failed: %v", plan.Pie, err)
+		event.Error = err.Error()
+	}
+	i.notify(ctx, event)
+}
+
+// executePlan places the plan's orders for ExecutePlan, adding them to report
+func (i *Investor) executePlan(ctx context.Context, plan *RebalancePlan, report *ExecutionReport, opts ExecuteOptions) error {
+	account, err := i.findAccount(ctx, plan.AccountID)
+	if err != nil {
+		return err
+	}
+	if i.Risk != nil {
+		warnings, err := i.checkRisk(ctx, plan, account)
+		report.RiskWarnings = warnings
+		for _, warning := range warnings {
+			i.logger().WarnContext(ctx, "order breaches a risk limit", slog.String("account", report.AccountID),
+				slog.String("rule", warning.Rule), slog.String("message", warning.Message))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	cash := account.CashBalance
+	if opts.CashAccount {
+		cash = account.SettledCash
+	}
+
+	var sells, buys []ExecutedOrder
+	for _, planned := range plan.Orders {
+		executed := ExecutedOrder{Planned: planned, Request: planned.Order}
+		if planned.Order.Action == OrderActionSell {
+			sells = append(sells, executed)
+		} else {
+			buys = append(buys, executed)
+		}
+	}
+
+	if err := i.executeBatch(ctx, report, sells, opts); err != nil {
+		return err
+	}
+	for _, executed := range report.Orders {
+		report.SellProceeds = report.SellProceeds.Add(executed.FilledValue())
+	}
+	report.BuyingCash = decimal.Max(cash.Add(report.SellProceeds), decimal.Zero)
+
+	buys = i.scaleBuys(report, buys, report.BuyingCash)
+	if err := i.executeBatch(ctx, report, buys, opts); err != nil {
+		return err
+	}
+
+	var failed, duplicates int
+	for _, executed := range report.Orders {
+		switch {
+		case executed.Duplicate:
+			duplicates++
+		case executed.Error != "":
+			failed++
+		}
+	}
+	var errs []error
+	if failed > 0 {
+		errs = append(errs, fmt.Errorf("%d of %d orders failed", failed, len(report.Orders)))
+	}
+	if duplicates > 0 {
+		errs = append(errs, fmt.Errorf("%d of %d orders were not placed, as they repeat orders placed moments before: %w",
+			duplicates, len(report.Orders), ErrDuplicateOrder))
+	}
+	return errors.Join(errs...)
+}
+
+// findAccount returns the investor's brokerage account with the given ID
+func (i *Investor) findAccount(ctx context.Context, id string) (Account, error) {
+	accounts, err := i.BrokerageClient.GetAccounts(ctx)
+	if err != nil {
+		return Account{}, fmt.Errorf("failed to get accounts: %w", err)
+	}
+	for _, account := range accounts {
+		if account.ID() == id {
+			return account, nil
+		}
+	}
+	return Account{}, fmt.Errorf("account %s not found", id)
+}
+
+// scaleBuys shrinks the buys to fit cash, recording those that shrink to
+// nothing as skipped
+func (i *Investor) scaleBuys(report *ExecutionReport, buys []ExecutedOrder, cash decimal.Decimal) []ExecutedOrder {
+	var planned decimal.Decimal
+	for _, buy := range buys {
+		planned = planned.Add(buy.Planned.Value)
+	}
+	if planned.LessThanOrEqual(cash) {
+		return buys
+	}
+
+	fractional := false
+	if trader, ok := i.BrokerageClient.(FractionalTrader); ok {
+		fractional = trader.SupportsFractionalShares()
+	}
+
+	scaled := make([]ExecutedOrder, 0, len(buys))
+	for _, buy := range buys {
+		// Orders sized in dollars scale their amount, the rest their shares
+		if buy.Request.Amount != nil {
+			if amount := RoundCents(buy.Request.Amount.Mul(cash).Div(planned)); amount.IsPositive() {
+				buy.Request.Amount = &amount
+				scaled = append(scaled, buy)
+				continue
+			}
+		} else if quantity := RoundShares(buy.Request.Quantity.Mul(cash).Div(planned), fractional); quantity.IsPositive() {
+			buy.Request.Quantity = quantity
+			scaled = append(scaled, buy)
+			continue
+		}
+
+		report.Skipped = append(report.Skipped, SkippedTrade{
+			Symbol:   buy.Request.Symbol,
+			Action:   buy.Request.Action,
+			Quantity: buy.Request.Quantity,
+			Value:    buy.Planned.Value,
+			Reason:   "sells did not raise enough cash",
+		})
+	}
+	return scaled
+}
+
+// executeBatch places and waits for the orders, then records whatever they
+// filled in the investor's ledger, even when waiting was cut short
+func (i *Investor) executeBatch(ctx context.Context, report *ExecutionReport, orders []ExecutedOrder, opts ExecuteOptions) error {
+	first := len(report.Orders)
+	err := i.placeAndWait(ctx, report, orders, opts)
+	if recordErr := i.recordFills(report, report.Orders[first:]); recordErr != nil {
+		return errors.Join(err, recordErr)
+	}
+	return err
+}
+
+// placeAndWait places every order and then waits for each of them to close,
+// adding them to the report as soon as they are placed
+func (i *Investor) placeAndWait(ctx context.Context, report *ExecutionReport, orders []ExecutedOrder, opts ExecuteOptions) error {
+	first := len(report.Orders)
+	for _, executed := range orders {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		order, err := i.BrokerageClient.PlaceOrder(ctx, report.AccountID, executed.Request)
+		if order != nil {
+			executed.OrderID = order.ID
+			executed.update(order)
+		}
+		switch {
+		case errors.Is(err, ErrDuplicateOrder):
+			executed.Error = err.Error()
+			executed.Duplicate = true
+			i.logger().WarnContext(ctx, "skipped duplicate order", orderAttrs(report.AccountID, executed,
+				slog.String("error", err.Error()))...)
+		case err != nil:
+			executed.Error = err.Error()
+			i.logger().WarnContext(ctx, "failed to place order", orderAttrs(report.AccountID, executed,
+				slog.String("error", err.Error()))...)
+		default:
+			i.logger().InfoContext(ctx, "placed order", orderAttrs(report.AccountID, executed)...)
+		}
+		report.Orders = append(report.Orders, executed)
+		opts.notify(executed)
+	}
+
+	deadline := time.Now().Add(opts.FillTimeout)
+	for idx := first; idx < len(report.Orders); idx++ {
+		executed := &report.Orders[idx]
+		if executed.OrderID == "" || (executed.Status != "" && !executed.Status.IsOpen()) {
+			continue
+		}
+
+		if err := i.waitAndSettle(ctx, report.AccountID, executed, deadline, opts); err != nil {
+			return err
+		}
+		level, extra := slog.LevelInfo, []any{
+			slog.String("filled_qty", executed.FilledQty.String()),
+			slog.String("filled_price", executed.FilledPrice.String()),
+		}
+		if executed.Error != "" {
+			level, extra = slog.LevelWarn, append(extra, slog.String("error", executed.Error))
+		}
+		i.logger().Log(ctx, level, "order settled", orderAttrs(report.AccountID, *executed, extra...)...)
+		opts.notify(*executed)
+	}
+
+	return nil
+}
+
+// waitAndSettle waits for the order to close, cancelling it when it is still
+// open past deadline and opts say so. Failures are recorded on the order;
+// only the context ending is returned.
+func (i *Investor) waitAndSettle(ctx context.Context, accountID string, executed *ExecutedOrder, deadline time.Time, opts ExecuteOptions) error {
+	order, err := i.waitForOrder(ctx, accountID, executed.OrderID, deadline, opts)
+	if order != nil {
+		executed.update(order)
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if err != nil {
+		executed.Error = err.Error()
+		return nil
+	}
+
+	if opts.CancelUnfilled && executed.Status.IsOpen() {
+		if err := i.BrokerageClient.CancelPendingOrder(ctx, accountID, executed.OrderID); err != nil {
+			executed.Error = fmt.Sprintf("failed to cancel unfilled order: %v", err)
+			return nil
+		}
+		if order, err := i.BrokerageClient.GetOrderStatus(ctx, accountID, executed.OrderID); err == nil {
+			executed.update(order)
+		}
+	}
+	return nil
+}
+
+// orderAttrs returns the log attributes identifying executed, followed by
+// extra
+func orderAttrs(accountID string, executed ExecutedOrder, extra ...any) []any {
+	attrs := []any{
+		slog.String("account", accountID),
+		slog.String("order_id", executed.OrderID),
+		slog.String("symbol", executed.Request.Symbol),
+		slog.String("action", string(executed.Request.Action)),
+		slog.String("quantity", executed.Request.Quantity.String()),
+		slog.String("status", string(executed.Status)),
+	}
+	return append(attrs, extra...)
+}
+
+// notify passes the order to OnUpdate, if set
+func (o ExecuteOptions) notify(executed ExecutedOrder) {
+	if o.OnUpdate != nil {
+		o.OnUpdate(executed)
+	}
+}
+
+// update copies the order's latest status and fills
+func (o *ExecutedOrder) update(order *Order) {
+	o.Status = order.Status
+	o.FilledQty = order.FilledQty
+	o.FilledPrice = order.FilledPrice
+}
+
+// waitForOrder polls the order until it closes or deadline passes, and
+// returns its last known state
+func (i *Investor) waitForOrder(ctx context.Context, accountID, orderID string, deadline time.Time, opts ExecuteOptions) (*Order, error) {
+	interval := opts.PollInterval
+	for {
+		order, err := i.BrokerageClient.GetOrderStatus(ctx, accountID, orderID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get status of order %s: %w", orderID, err)
+		}
+		if !order.Status.IsOpen() {
+			return order, nil
+		}
+
+		wait := min(interval, time.Until(deadline))
+		if wait <= 0 {
+			return order, nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return order, ctx.Err()
+		case <-timer.C:
+		}
+		interval = min(interval*2, opts.MaxPollInterval)
+	}
+}
@@ -0,0 +1,191 @@
+package pies_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/paper"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+// paperAccount returns a paper account holding 70 VTI and 30 BND at $100
+// and no cash, matched with config's MaxFillQuantity from then on
+func paperAccount(t *testing.T, config paper.Config) *paper.Client {
+	t.Helper()
+
+	quotes := paper.NewFixedQuotes(map[string]decimal.Decimal{
+		"VTI": decimal.NewFromInt(100),
+		"BND": decimal.NewFromInt(100),
+	})
+	config.StateFile = filepath.Join(t.TempDir(), "paper.json")
+	config.StartingCash = decimal.NewFromInt(10_000)
+
+	// Fund the account without the fill cap, then reopen it with the cap
+	seed, err := paper.NewClient(paper.Config{StartingCash: config.StartingCash, StateFile: config.StateFile}, quotes)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	for symbol, quantity := range map[string]int64{"VTI": 70, "BND": 30} {
+		order := pies.OrderRequest{
+			Symbol:   symbol,
+			Action:   pies.OrderActionBuy,
+			Type:     pies.OrderTypeMarket,
+			Quantity: decimal.NewFromInt(quantity),
+			Duration: pies.OrderDurationDay,
+		}
+		if _, err := seed.PlaceOrder(t.Context(), "paper", order); err != nil {
+			t.Fatalf("PlaceOrder() error = %v", err)
+		}
+	}
+
+	client, err := paper.NewClient(config, quotes)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return client
+}
+
+// holdings returns the paper account's cash and share quantities by symbol
+func holdings(t *testing.T, client *paper.Client) (decimal.Decimal, map[string]string) {
+	t.Helper()
+
+	accounts, err := client.GetAccounts(t.Context())
+	if err != nil {
+		t.Fatalf("GetAccounts() error = %v", err)
+	}
+	positions, err := client.GetPositions(t.Context(), "paper")
+	if err != nil {
+		t.Fatalf("GetPositions() error = %v", err)
+	}
+	quantities := make(map[string]string, len(positions))
+	for _, position := range positions {
+		quantities[position.Symbol] = position.Quantity.String()
+	}
+	return accounts[0].CashBalance, quantities
+}
+
+// planFor computes the plan rebalancing the account to 60% VTI and 40% BND
+func planFor(t *testing.T, investor *pies.Investor) *pies.RebalancePlan {
+	t.Helper()
+
+	plan, err := investor.ComputeRebalancePlan(t.Context(), testPie("VTI", 60, "BND", 40), pies.RebalanceOptions{AllowSells: true})
+	if err != nil {
+		t.Fatalf("ComputeRebalancePlan() error = %v", err)
+	}
+	return plan
+}
+
+func TestExecutePlanAgainstPaper(t *testing.T) {
+	client := paperAccount(t, paper.Config{})
+	investor := &pies.Investor{BrokerageClient: client}
+
+	report, err := investor.ExecutePlan(t.Context(), planFor(t, investor), pies.ExecuteOptions{PollInterval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("ExecutePlan() error = %v", err)
+	}
+
+	if len(report.Orders) != 2 {
+		t.Fatalf("report orders = %+v, want a sell and a buy", report.Orders)
+	}
+	for i, want := range []string{"SELL VTI", "BUY BND"} {
+		executed := report.Orders[i]
+		if got := string(executed.Request.Action) + " " + executed.Request.Symbol; got != want {
+			t.Errorf("order %d = %s, want %s", i, got, want)
+		}
+		if executed.OrderID == "" || executed.Status != pies.OrderStatusFilled || !executed.FilledQty.Equal(decimal.NewFromInt(10)) ||
+			!executed.FilledPrice.Equal(decimal.NewFromInt(100)) {
+			t.Errorf("order %d = %+v, want 10 filled at 100 with an order ID", i, executed)
+		}
+	}
+	if !report.SellProceeds.Equal(decimal.NewFromInt(1000)) || !report.BuyingCash.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("SellProceeds = %s and BuyingCash = %s, want 1000 each", report.SellProceeds, report.BuyingCash)
+	}
+
+	cash, held := holdings(t, client)
+	if !cash.IsZero() || held["VTI"] != "60" || held["BND"] != "40" {
+		t.Errorf("account holds %s cash and %v, want 0, 60 VTI and 40 BND", cash, held)
+	}
+}
+
+func TestExecutePlanPartiallyFilledSell(t *testing.T) {
+	// Each match fills at most 4 shares: the 10 share sell fills 4 when
+	// placed and 4 more when polled, then times out and is cancelled
+	client := paperAccount(t, paper.Config{MaxFillQuantity: decimal.NewFromInt(4)})
+	investor := &pies.Investor{BrokerageClient: client}
+
+	report, err := investor.ExecutePlan(t.Context(), planFor(t, investor), pies.ExecuteOptions{
+		PollInterval:   time.Millisecond,
+		FillTimeout:    time.Nanosecond,
+		CancelUnfilled: true,
+	})
+	if err != nil {
+		t.Fatalf("ExecutePlan() error = %v", err)
+	}
+	if len(report.Orders) != 2 {
+		t.Fatalf("report orders = %+v, want a sell and a buy", report.Orders)
+	}
+
+	sell, buy := report.Orders[0], report.Orders[1]
+	if sell.Status != pies.OrderStatusCancelled || !sell.FilledQty.Equal(decimal.NewFromInt(8)) {
+		t.Errorf("sell = %s with %s filled, want CANCELLED with 8", sell.Status, sell.FilledQty)
+	}
+	if !report.SellProceeds.Equal(decimal.NewFromInt(800)) {
+		t.Errorf("SellProceeds = %s, want the 800 actually filled", report.SellProceeds)
+	}
+
+	// The buy is scaled down to what the sell brought in
+	if !buy.Planned.Order.Quantity.Equal(decimal.NewFromInt(10)) || !buy.Request.Quantity.Equal(decimal.NewFromInt(8)) {
+		t.Errorf("buy planned %s and submitted %s, want 10 scaled to 8", buy.Planned.Order.Quantity, buy.Request.Quantity)
+	}
+	if buy.OrderID == "" || buy.Status != pies.OrderStatusFilled || !buy.FilledQty.Equal(decimal.NewFromInt(8)) {
+		t.Errorf("buy = %+v, want 8 filled", buy)
+	}
+
+	cash, held := holdings(t, client)
+	if !cash.IsZero() || held["VTI"] != "62" || held["BND"] != "38" {
+		t.Errorf("account holds %s cash and %v, want 0, 62 VTI and 38 BND", cash, held)
+	}
+}
+
+func TestExecutePlanStopsOnCancellation(t *testing.T) {
+	client := paperAccount(t, paper.Config{})
+	investor := &pies.Investor{BrokerageClient: client}
+
+	// A sell limited above the market stays working until the context ends
+	plan := planFor(t, investor)
+	high := decimal.NewFromInt(200)
+	plan.Orders[0].Order.Type = pies.OrderTypeLimit
+	plan.Orders[0].Order.LimitPrice = &high
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	report, err := investor.ExecutePlan(ctx, plan, pies.ExecuteOptions{
+		PollInterval: time.Millisecond,
+		OnUpdate: func(executed pies.ExecutedOrder) {
+			if executed.OrderID != "" {
+				cancel()
+			}
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ExecutePlan() error = %v, want context.Canceled", err)
+	}
+
+	// The working sell is in the report and the buy was never placed
+	if len(report.Orders) != 1 || report.Orders[0].OrderID == "" || report.Orders[0].Status != pies.OrderStatusWorking {
+		t.Fatalf("report orders = %+v, want only the working sell", report.Orders)
+	}
+	orders, err := client.GetRecentOrders(t.Context(), "paper", pies.OrdersQuery{})
+	if err != nil {
+		t.Fatalf("GetRecentOrders() error = %v", err)
+	}
+	for _, order := range orders {
+		if order.Status.IsOpen() && order.ID != report.Orders[0].OrderID {
+			t.Errorf("open order %s is missing from the report", order.ID)
+		}
+	}
+}
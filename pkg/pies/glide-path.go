@@ -0,0 +1,152 @@
+package pies
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// GlidePath shifts a pie's weights over time, e.g. out of equities as a
+// retirement date nears. The weights are those of the nearest anchor before
+// the first and after the last one, and move linearly between anchors.
+type GlidePath struct {
+	Anchors []GlideAnchor // In date order
+}
+
+// GlideAnchor sets the weights of a pie's slices on a date. Weights are
+// keyed by symbol, by child pie name for slices holding a child pie, or by
+// CASH for a cash slice; slices without one have no weight on that date.
+type GlideAnchor struct {
+	Date    time.Time
+	Weights map[string]float64
+}
+
+// EffectiveAt returns the pie with the weights its glide path gives on t,
+// and no glide path. Slices the path gives no weight on t are left out.
+// Child pies are materialized the same way, and pies without a glide path
+// keep their weights.
+func (p Pie) EffectiveAt(t time.Time) Pie {
+	return p.effectiveAt(t, 0)
+}
+
+// effectiveAt materializes the pie nested depth levels deep. Pies nested
+// too deep, which Validate rejects, are returned as they are so that a pie
+// containing itself does not recurse forever.
+func (p Pie) effectiveAt(t time.Time, depth int) Pie {
+	if depth > MaxPieDepth {
+		return p
+	}
+
+	var weights map[string]float64
+	if p.GlidePath != nil {
+		weights = p.GlidePath.weightsAt(t)
+	}
+
+	effective := p
+	effective.GlidePath = nil
+	effective.Slices = make([]Slice, 0, len(p.Slices))
+	for _, slice := range p.Slices {
+		if slice.Pie != nil {
+			child := slice.Pie.effectiveAt(t, depth+1)
+			slice.Pie = &child
+		}
+		if weights != nil {
+			slice.Weight = weights[glideKey(slice)]
+			if slice.Weight <= 0 {
+				continue
+			}
+		}
+		effective.Slices = append(effective.Slices, slice)
+	}
+	return effective
+}
+
+// weightsAt interpolates the weights on t between the anchors around it
+func (g GlidePath) weightsAt(t time.Time) map[string]float64 {
+	if len(g.Anchors) == 0 {
+		return nil
+	}
+
+	first, last := g.Anchors[0], g.Anchors[len(g.Anchors)-1]
+	if !t.After(first.Date) {
+		return normalizeWeights(first.Weights)
+	}
+	if !t.Before(last.Date) {
+		return normalizeWeights(last.Weights)
+	}
+
+	for idx := 1; idx < len(g.Anchors); idx++ {
+		before, after := g.Anchors[idx-1], g.Anchors[idx]
+		if t.After(after.Date) {
+			continue
+		}
+
+		progress := float64(t.Sub(before.Date)) / float64(after.Date.Sub(before.Date))
+		from, to := normalizeWeights(before.Weights), normalizeWeights(after.Weights)
+		weights := make(map[string]float64, len(from)+len(to))
+		for _, set := range []map[string]float64{from, to} {
+			for key := range set {
+				weights[key] = from[key] + progress*(to[key]-from[key])
+			}
+		}
+		return weights
+	}
+	return normalizeWeights(last.Weights)
+}
+
+// validate checks that the anchors are in date order and that each one
+// gives non-negative weights, summing to TotalWeight, to slices of pie
+func (g GlidePath) validate(pie Pie) error {
+	if len(g.Anchors) == 0 {
+		return errors.New("glide path has no anchors")
+	}
+
+	keys := make(map[string]bool, len(pie.Slices))
+	for _, slice := range pie.Slices {
+		keys[glideKey(slice)] = true
+	}
+
+	for i, anchor := range g.Anchors {
+		date := anchor.Date.Format(time.DateOnly)
+		if i > 0 && !anchor.Date.After(g.Anchors[i-1].Date) {
+			return fmt.Errorf("glide path anchor %s is not after %s", date, g.Anchors[i-1].Date.Format(time.DateOnly))
+		}
+
+		var total float64
+		for key, weight := range normalizeWeights(anchor.Weights) {
+			if !keys[key] {
+				return fmt.Errorf("glide path anchor %s: %s is not a slice of the pie", date, key)
+			}
+			if !(weight >= 0) || math.IsInf(weight, 0) {
+				return fmt.Errorf("glide path anchor %s: weight of %s must be >= 0, got %v", date, key, weight)
+			}
+			total += weight
+		}
+		if math.Abs(total-TotalWeight) > weightTolerance {
+			return fmt.Errorf("glide path anchor %s: weights sum to %v, want %v", date, total, TotalWeight)
+		}
+	}
+	return nil
+}
+
+// glideKey is the key a glide path anchor gives the slice's weight under
+func glideKey(slice Slice) string {
+	switch {
+	case slice.Pie != nil:
+		return normalizeSymbol(slice.Pie.Name)
+	case slice.IsCash():
+		return cashLabel
+	default:
+		return normalizeSymbol(slice.Asset.Symbol)
+	}
+}
+
+// normalizeWeights returns anchor weights keyed like glideKey
+func normalizeWeights(weights map[string]float64) map[string]float64 {
+	normalized := make(map[string]float64, len(weights))
+	for key, weight := range weights {
+		normalized[normalizeSymbol(key)] += weight
+	}
+	return normalized
+}
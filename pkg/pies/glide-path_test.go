@@ -0,0 +1,167 @@
+package pies_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// date returns midnight UTC on the day
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// glidePie returns a pie of VTI, BND and BNDX moving from stocks to bonds
+// between 2030 and 2050. BNDX only joins after 2040.
+func glidePie() pies.Pie {
+	pie := testPie("VTI", 0, "BND", 0, "BNDX", 0)
+	pie.Name = "Target 2050"
+	pie.GlidePath = &pies.GlidePath{Anchors: []pies.GlideAnchor{
+		{Date: date(2030, 1, 1), Weights: map[string]float64{"VTI": 90, "BND": 10}},
+		{Date: date(2040, 1, 1), Weights: map[string]float64{"VTI": 70, "BND": 30}},
+		{Date: date(2050, 1, 1), Weights: map[string]float64{"vti": 40, "BND": 50, "BNDX": 10}},
+	}}
+	return pie
+}
+
+// effectiveWeights returns the weight of every slice of the pie on t
+func effectiveWeights(pie pies.Pie, t time.Time) map[string]float64 {
+	weights := make(map[string]float64)
+	for _, slice := range pie.EffectiveAt(t).Slices {
+		weights[slice.Asset.Symbol] = slice.Weight
+	}
+	return weights
+}
+
+func TestGlidePathWeights(t *testing.T) {
+	// 2045 is 1827 of the 3653 days from 2040 to 2050
+	progress := 1827.0 / 3653
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want map[string]float64
+	}{
+		{name: "years before the first anchor", at: date(2020, 6, 1), want: map[string]float64{"VTI": 90, "BND": 10}},
+		{name: "just before the first anchor", at: date(2030, 1, 1).Add(-time.Nanosecond), want: map[string]float64{"VTI": 90, "BND": 10}},
+		{name: "on the first anchor", at: date(2030, 1, 1), want: map[string]float64{"VTI": 90, "BND": 10}},
+		// Both halves of the decade are 1826 days long
+		{name: "halfway between anchors", at: date(2035, 1, 1), want: map[string]float64{"VTI": 80, "BND": 20}},
+		{name: "on a middle anchor", at: date(2040, 1, 1), want: map[string]float64{"VTI": 70, "BND": 30}},
+		{name: "just after a middle anchor", at: date(2040, 1, 1).Add(time.Nanosecond), want: map[string]float64{"VTI": 70, "BND": 30, "BNDX": 0}},
+		{
+			name: "between anchors introducing a slice",
+			at:   date(2045, 1, 1),
+			want: map[string]float64{"VTI": 70 - 30*progress, "BND": 30 + 20*progress, "BNDX": 10 * progress},
+		},
+		{name: "on the last anchor", at: date(2050, 1, 1), want: map[string]float64{"VTI": 40, "BND": 50, "BNDX": 10}},
+		{name: "after the last anchor", at: date(2070, 1, 1), want: map[string]float64{"VTI": 40, "BND": 50, "BNDX": 10}},
+	}
+
+	pie := glidePie()
+	if err := pie.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := effectiveWeights(pie, tt.at)
+
+			var total float64
+			for symbol, weight := range got {
+				total += weight
+				if math.Abs(weight-tt.want[symbol]) > 1e-9 {
+					t.Errorf("weight of %s = %v, want %v", symbol, weight, tt.want[symbol])
+				}
+			}
+			for symbol, weight := range tt.want {
+				if _, ok := got[symbol]; !ok && weight > 0 {
+					t.Errorf("%s left out, want weight %v", symbol, weight)
+				}
+			}
+			if math.Abs(total-pies.TotalWeight) > 1e-9 {
+				t.Errorf("weights sum to %v, want %v", total, pies.TotalWeight)
+			}
+		})
+	}
+}
+
+func TestGlidePathOnAnchorsIsExact(t *testing.T) {
+	pie := glidePie()
+	for _, anchor := range pie.GlidePath.Anchors {
+		got := effectiveWeights(pie, anchor.Date)
+		if len(got) != len(anchor.Weights) {
+			t.Errorf("on %s slices = %v, want %v", anchor.Date.Format(time.DateOnly), got, anchor.Weights)
+		}
+		for symbol, weight := range anchor.Weights {
+			if got[strings.ToUpper(symbol)] != weight {
+				t.Errorf("on %s weight of %s = %v, want exactly %v", anchor.Date.Format(time.DateOnly), symbol, got[strings.ToUpper(symbol)], weight)
+			}
+		}
+	}
+}
+
+func TestEffectiveAtWithoutGlidePath(t *testing.T) {
+	pie := testPie("VTI", 60, "BND", 40)
+	got := effectiveWeights(pie, date(2040, 1, 1))
+	if len(got) != 2 || got["VTI"] != 60 || got["BND"] != 40 {
+		t.Errorf("weights = %v, want the pie's own 60/40", got)
+	}
+}
+
+func TestGlidePathValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		anchors []pies.GlideAnchor
+		wantErr string
+	}{
+		{
+			name:    "no anchors",
+			wantErr: "glide path has no anchors",
+		},
+		{
+			name: "out of order",
+			anchors: []pies.GlideAnchor{
+				{Date: date(2040, 1, 1), Weights: map[string]float64{"VTI": 100}},
+				{Date: date(2030, 1, 1), Weights: map[string]float64{"VTI": 100}},
+			},
+			wantErr: "glide path anchor 2030-01-01 is not after 2040-01-01",
+		},
+		{
+			name: "two anchors on one date",
+			anchors: []pies.GlideAnchor{
+				{Date: date(2030, 1, 1), Weights: map[string]float64{"VTI": 100}},
+				{Date: date(2030, 1, 1), Weights: map[string]float64{"BND": 100}},
+			},
+			wantErr: "glide path anchor 2030-01-01 is not after 2030-01-01",
+		},
+		{
+			name:    "weights not summing to 100",
+			anchors: []pies.GlideAnchor{{Date: date(2030, 1, 1), Weights: map[string]float64{"VTI": 60, "BND": 30}}},
+			wantErr: "glide path anchor 2030-01-01: weights sum to 90, want 100",
+		},
+		{
+			name:    "negative weight",
+			anchors: []pies.GlideAnchor{{Date: date(2030, 1, 1), Weights: map[string]float64{"VTI": 110, "BND": -10}}},
+			wantErr: "glide path anchor 2030-01-01: weight of BND must be >= 0, got -10",
+		},
+		{
+			name:    "symbol outside the pie",
+			anchors: []pies.GlideAnchor{{Date: date(2030, 1, 1), Weights: map[string]float64{"VTI": 60, "QQQ": 40}}},
+			wantErr: "glide path anchor 2030-01-01: QQQ is not a slice of the pie",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pie := testPie("VTI", 0, "BND", 0)
+			pie.GlidePath = &pies.GlidePath{Anchors: tt.anchors}
+			if err := pie.Validate(); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package pies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/logging"
+)
+
+type Pie struct {
+	ID          string
+	Name        string
+	Description string
+	Slices      []Slice
+	Band        DriftBand   // Default band for slices without their own
+	GlidePath   *GlidePath  // Replaces the slice weights over time, see EffectiveAt
+	Benchmarks  []string    // Symbols to compare the pie's returns with, see GetPieStatusWithReturns
+	Risk        *RiskLimits // Pre-trade checks of the pie's orders, see RiskLimits.Merge
+}
+
+// Slice holds either an Asset or, in a nested pie, a child Pie
+type Slice struct {
+	Weight float64
+	Asset  Asset
+	Pie    *Pie       // Child pie held instead of Asset
+	Band   *DriftBand // Overrides Pie.Band when set
+}
+
+type Asset struct {
+	TypeName string
+	ID       string
+	IsActive bool
+	Name     string
+	Symbol   string
+	Status   string
+}
+
+type Investor struct {
+	Account         Account
+	BrokerageClient BrokerageClient
+	Ledger          PositionLedger // Optional, see GetPieStatusAttributed
+	History         HistoryStore   // Optional, see ExecutePlan
+	Notifier        Notifier       // Optional, see ExecutePlan
+	Logger          *slog.Logger   // Nil logs nothing
+	Screener        Screener       // Optional, see ComputeRebalancePlan
+	Risk            *RiskChecker   // Optional, see ExecutePlan
+
+	// QuoteConcurrency is the QuoteFetcher concurrency used to price pies
+	QuoteConcurrency int
+
+	// ExtendedHours values pies and sizes their orders at extended-hours
+	// prices where the brokerage reports them, for use outside the regular
+	// session
+	ExtendedHours bool
+}
+
+// logger returns the investor's Logger, redacted, or a logger discarding
+// everything
+func (i *Investor) logger() *slog.Logger {
+	if i.Logger == nil {
+		return logging.Discard()
+	}
+	return logging.RedactLogger(i.Logger)
+}
+
+// PreviewOrders previews every order against the investor's account and
+// aborts at the first one that the account lacks the buying power for.
+// The brokerage client must implement OrderPreviewer.
+func (i *Investor) PreviewOrders(ctx context.Context, orders []OrderRequest) ([]OrderPreview, error) {
+	previewer, ok := i.BrokerageClient.(OrderPreviewer)
+	if !ok {
+		return nil, errors.New("brokerage does not support order previews")
+	}
+
+	previews := make([]OrderPreview, 0, len(orders))
+	for _, order := range orders {
+		preview, err := previewer.PreviewOrder(ctx, i.Account.ID(), order)
+		if preview != nil && preview.InsufficientBuyingPower() {
+			return previews, fmt.Errorf("insufficient buying power for %s %s", order.Action, order.Symbol)
+		}
+		if err != nil {
+			return previews, fmt.Errorf("failed to preview %s %s: %w", order.Action, order.Symbol, err)
+		}
+		previews = append(previews, *preview)
+	}
+
+	return previews, nil
+}
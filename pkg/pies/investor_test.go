@@ -0,0 +1,200 @@
+package pies_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/paper"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/alysoliman1/money-pies/pkg/pies/piestest"
+	"github.com/shopspring/decimal"
+)
+
+// orderPreviewer is a brokerage returning canned previews by symbol. Only
+// PreviewOrder is implemented.
+type orderPreviewer struct {
+	pies.BrokerageClient
+	previews  map[string]pies.OrderPreview
+	errs      map[string]error
+	previewed []string
+}
+
+func (p *orderPreviewer) PreviewOrder(ctx context.Context, accountID string, order pies.OrderRequest) (*pies.OrderPreview, error) {
+	p.previewed = append(p.previewed, order.Symbol)
+	preview := p.previews[order.Symbol]
+	return &preview, p.errs[order.Symbol]
+}
+
+func TestPreviewOrders(t *testing.T) {
+	buy := func(symbol string) pies.OrderRequest {
+		return pies.OrderRequest{Symbol: symbol, Action: pies.OrderActionBuy, Type: pies.OrderTypeMarket, Quantity: decimal.NewFromInt(1)}
+	}
+	orders := []pies.OrderRequest{buy("VTI"), buy("VXUS"), buy("BND")}
+	affordable := pies.OrderPreview{EstimatedTotal: decimal.NewFromInt(100), ProjectedBuyingPower: decimal.NewFromInt(900)}
+
+	tests := []struct {
+		name          string
+		previews      map[string]pies.OrderPreview
+		errs          map[string]error
+		wantPreviewed []string
+		wantPreviews  int
+		wantErr       string // Empty when every order previews
+	}{
+		{
+			name:          "all affordable",
+			previews:      map[string]pies.OrderPreview{"VTI": affordable, "VXUS": affordable, "BND": affordable},
+			wantPreviewed: []string{"VTI", "VXUS", "BND"},
+			wantPreviews:  3,
+		},
+		{
+			name: "negative buying power",
+			previews: map[string]pies.OrderPreview{
+				"VTI":  affordable,
+				"VXUS": {ProjectedBuyingPower: decimal.NewFromInt(-50)},
+			},
+			wantPreviewed: []string{"VTI", "VXUS"},
+			wantPreviews:  1,
+			wantErr:       "insufficient buying power for BUY VXUS",
+		},
+		{
+			name:     "buying power rejection",
+			previews: map[string]pies.OrderPreview{"VTI": {Rejections: []string{"Insufficient Buying Power for this order"}}},
+			errs: map[string]error{
+				"VTI": &pies.OrderRejectedError{Symbol: "VTI", Messages: []string{"Insufficient Buying Power for this order"}},
+			},
+			wantPreviewed: []string{"VTI"},
+			wantErr:       "insufficient buying power for BUY VTI",
+		},
+		{
+			name:     "other rejection",
+			previews: map[string]pies.OrderPreview{"VTI": affordable, "VXUS": {Rejections: []string{"Symbol is not tradable"}}},
+			errs: map[string]error{
+				"VXUS": &pies.OrderRejectedError{Symbol: "VXUS", Messages: []string{"Symbol is not tradable"}},
+			},
+			wantPreviewed: []string{"VTI", "VXUS"},
+			wantPreviews:  1,
+			wantErr:       "failed to preview BUY VXUS: order for VXUS would be rejected: Symbol is not tradable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previewer := &orderPreviewer{previews: tt.previews, errs: tt.errs}
+			investor := &pies.Investor{BrokerageClient: previewer}
+
+			previews, err := investor.PreviewOrders(t.Context(), orders)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("PreviewOrders() error = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Fatalf("PreviewOrders() error = %v, want %q", err, tt.wantErr)
+			}
+			if len(previews) != tt.wantPreviews {
+				t.Errorf("PreviewOrders() returned %d previews, want %d", len(previews), tt.wantPreviews)
+			}
+			if !reflect.DeepEqual(previewer.previewed, tt.wantPreviewed) {
+				t.Errorf("previewed %v, want %v and nothing after an abort", previewer.previewed, tt.wantPreviewed)
+			}
+		})
+	}
+}
+
+func TestPreviewOrdersUnsupported(t *testing.T) {
+	investor := &pies.Investor{BrokerageClient: paperAccount(t, paper.Config{})}
+	_, err := investor.PreviewOrders(t.Context(), nil)
+	if err == nil || err.Error() != "brokerage does not support order previews" {
+		t.Errorf("PreviewOrders() error = %v, want previews unsupported", err)
+	}
+}
+
+func TestGetPieStatusExtendedHours(t *testing.T) {
+	b := holdingFake(t, pies.AccountKindTaxable, "0", "VTI", "10", "BND", "10")
+	b.SetQuote(pies.Quote{
+		Symbol:   "VTI",
+		Bid:      decimal.NewFromInt(99),
+		Ask:      decimal.NewFromInt(101),
+		Last:     decimal.NewFromInt(100),
+		Extended: &pies.ExtendedQuote{Bid: decimal.NewFromInt(119), Ask: decimal.NewFromInt(121), Last: decimal.NewFromInt(120)},
+	})
+
+	tests := []struct {
+		name          string
+		extendedHours bool
+		wantVTI       string // Price of VTI
+		wantValue     string // Value of the pie
+	}{
+		{name: "regular session", wantVTI: "100", wantValue: "2000"},
+		{name: "extended hours", extendedHours: true, wantVTI: "120", wantValue: "2200"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			investor := &pies.Investor{BrokerageClient: b, ExtendedHours: tt.extendedHours}
+			status, err := investor.GetPieStatus(t.Context(), testPie("VTI", 50, "BND", 50))
+			if err != nil {
+				t.Fatalf("GetPieStatus() error = %v", err)
+			}
+
+			vti, _ := status.Slice("VTI")
+			bnd, _ := status.Slice("BND")
+			if !vti.Price.Equal(dec(t, tt.wantVTI)) || !vti.Value.Equal(dec(t, tt.wantVTI).Mul(decimal.NewFromInt(10))) {
+				t.Errorf("VTI price = %s, value = %s, want priced at %s", vti.Price, vti.Value, tt.wantVTI)
+			}
+			if !bnd.Price.Equal(decimal.NewFromInt(piestest.DefaultPrice)) {
+				t.Errorf("BND price = %s, want the regular price without an extended quote", bnd.Price)
+			}
+			if !status.PieValue.Equal(dec(t, tt.wantValue)) {
+				t.Errorf("PieValue = %s, want %s", status.PieValue, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestPlansAtExtendedHoursOnlyQuotes(t *testing.T) {
+	// VTI has only traded after hours, at 120 with a 121 ask
+	b := holdingFake(t, pies.AccountKindTaxable, "1000", "VTI", "10", "BND", "10")
+	b.SetQuote(pies.Quote{
+		Symbol:   "VTI",
+		Extended: &pies.ExtendedQuote{Bid: decimal.NewFromInt(119), Ask: decimal.NewFromInt(121), Last: decimal.NewFromInt(120)},
+	})
+	pie := testPie("VTI", 50, "BND", 50)
+
+	plans := map[string]func(investor *pies.Investor) (*pies.RebalancePlan, error){
+		"ComputeRebalancePlan": func(investor *pies.Investor) (*pies.RebalancePlan, error) {
+			return investor.ComputeRebalancePlan(t.Context(), pie, pies.RebalanceOptions{})
+		},
+		"AllocateCash": func(investor *pies.Investor) (*pies.RebalancePlan, error) {
+			return investor.AllocateCash(t.Context(), pie, decimal.NewFromInt(500))
+		},
+	}
+
+	for name, plan := range plans {
+		t.Run(name, func(t *testing.T) {
+			_, err := plan(&pies.Investor{BrokerageClient: b})
+			if want := "without quotes for VTI"; err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("%s() during the regular session error = %v, want %q", name, err, want)
+			}
+
+			got, err := plan(&pies.Investor{BrokerageClient: b, ExtendedHours: true})
+			if err != nil {
+				t.Fatalf("%s() error = %v", name, err)
+			}
+			var bought bool
+			for _, planned := range got.Orders {
+				if planned.Order.Symbol != "VTI" {
+					continue
+				}
+				bought = true
+				if planned.Order.Action != pies.OrderActionBuy || !planned.Price.Equal(decimal.NewFromInt(121)) {
+					t.Errorf("%s() planned %s VTI at %s, want a buy at the extended-hours ask of 121",
+						name, planned.Order.Action, planned.Price)
+				}
+			}
+			if !bought {
+				t.Errorf("%s() orders = %v, want a VTI buy", name, plannedTrades(got))
+			}
+		})
+	}
+}
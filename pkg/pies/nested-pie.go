@@ -0,0 +1,129 @@
+package pies
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// GroupStatus compares one top-level slice of a nested pie, which may hold a
+// whole child pie, with its target
+type GroupStatus struct {
+	Name          string          `json:"name"` // The child pie's name, or the symbol for a plain slice
+	Value         decimal.Decimal `json:"value"`
+	CurrentWeight float64         `json:"current_weight"`
+	TargetWeight  float64         `json:"target_weight"`
+	TargetValue   decimal.Decimal `json:"target_value"`
+	Drift         float64         `json:"drift"`       // CurrentWeight - TargetWeight, in percentage points
+	DriftValue    decimal.Decimal `json:"drift_value"` // Value - TargetValue, in dollars
+}
+
+// IsNested reports whether any slice of the pie holds a child pie
+func (p Pie) IsNested() bool {
+	for _, slice := range p.Slices {
+		if slice.Pie != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Flatten replaces every child pie with its own slices, their weights
+// multiplied by the weight of the slice holding the child, so the result
+// only holds assets. A symbol held in several branches becomes one slice
+// with the summed weight. Leaves without a band of their own take the
+// nearest one above them: the child pie's default band, then the band of the
+// slice holding it. Glide paths are materialized at the current time.
+func (p Pie) Flatten() (Pie, error) {
+	if err := p.Validate(); err != nil {
+		return Pie{}, err
+	}
+	p = p.EffectiveAt(time.Now())
+
+	flat := Pie{
+		ID:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Band:        p.Band,
+	}
+	p.flattenInto(&flat, make(map[string]int), 1, nil)
+	return flat, nil
+}
+
+// flattenInto appends the leaves of p to flat, scaling their weights by scale
+func (p Pie) flattenInto(flat *Pie, index map[string]int, scale float64, band *DriftBand) {
+	for _, slice := range p.Slices {
+		weight := slice.Weight * scale
+		if slice.Pie != nil {
+			childBand := band
+			if slice.Band != nil {
+				childBand = slice.Band
+			}
+			if slice.Pie.Band != (DriftBand{}) {
+				childBand = &slice.Pie.Band
+			}
+			slice.Pie.flattenInto(flat, index, weight/TotalWeight, childBand)
+			continue
+		}
+
+		key := slice.key()
+		if idx, ok := index[key]; ok {
+			flat.Slices[idx].Weight += weight
+			continue
+		}
+
+		leaf := slice
+		leaf.Weight = weight
+		if leaf.Band == nil {
+			leaf.Band = band
+		}
+		index[key] = len(flat.Slices)
+		flat.Slices = append(flat.Slices, leaf)
+	}
+}
+
+// computeGroups measures the drift of every top-level slice of pie from the
+// status of its flattened form. A symbol held in several branches is split
+// between them in proportion to the weight each branch gives it.
+func computeGroups(pie Pie, status *PieStatus) []GroupStatus {
+	flatWeights := make(map[string]float64, len(status.Slices))
+	values := make(map[string]decimal.Decimal, len(status.Slices))
+	for _, slice := range status.Slices {
+		flatWeights[slice.key()] = slice.TargetWeight
+		values[slice.key()] = slice.Value
+	}
+
+	groups := make([]GroupStatus, 0, len(pie.Slices))
+	for _, slice := range pie.Slices {
+		group := GroupStatus{
+			Name:         normalizeSymbol(slice.Asset.Symbol),
+			TargetWeight: slice.Weight,
+			TargetValue:  weightOf(status.PieValue, slice.Weight),
+		}
+		switch {
+		case slice.Pie != nil:
+			group.Name = slice.Pie.Name
+		case slice.IsCash() && group.Name == "":
+			group.Name = cashLabel
+		}
+
+		var branch Pie
+		Pie{Slices: []Slice{slice}}.flattenInto(&branch, make(map[string]int), 1, nil)
+		for _, leaf := range branch.Slices {
+			key := leaf.key()
+			if flatWeights[key] > 0 {
+				share := decimal.NewFromFloat(leaf.Weight).Div(decimal.NewFromFloat(flatWeights[key]))
+				group.Value = group.Value.Add(values[key].Mul(share))
+			}
+		}
+
+		if status.PieValue.IsPositive() {
+			group.CurrentWeight = percentOf(group.Value, status.PieValue)
+		}
+		group.Drift = group.CurrentWeight - group.TargetWeight
+		group.DriftValue = group.Value.Sub(group.TargetValue)
+		groups = append(groups, group)
+	}
+
+	return groups
+}
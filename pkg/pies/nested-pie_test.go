@@ -0,0 +1,188 @@
+package pies_test
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// childSlice returns a slice of weight holding child, renamed to name
+func childSlice(weight float64, name string, child pies.Pie) pies.Slice {
+	child.Name = name
+	return pies.Slice{Weight: weight, Pie: &child}
+}
+
+// nestedPie returns a pie nested depth levels deep, each level holding VTI
+// and the next level
+func nestedPie(depth int) pies.Pie {
+	pie := testPie("BND", 100)
+	for level := 0; level < depth; level++ {
+		pie = pies.Pie{Name: "level", Slices: []pies.Slice{
+			{Weight: 50, Asset: pies.Asset{Symbol: "VTI"}},
+			childSlice(50, "level", pie),
+		}}
+	}
+	return pie
+}
+
+func TestFlatten(t *testing.T) {
+	tight := &pies.DriftBand{Absolute: 1}
+	loose := &pies.DriftBand{Absolute: 10}
+	relative := pies.DriftBand{Relative: 0.25}
+
+	// leaf is a slice of the flattened pie
+	type leaf struct {
+		symbol string
+		weight float64
+		band   *pies.DriftBand
+	}
+
+	tests := []struct {
+		name string
+		pie  pies.Pie
+		want []leaf
+	}{
+		{
+			name: "flat pie is unchanged",
+			pie:  testPie("VTI", 60, "BND", 40),
+			want: []leaf{{"VTI", 60, nil}, {"BND", 40, nil}},
+		},
+		{
+			name: "child weights are multiplied by the slice holding it",
+			pie: pies.Pie{Name: "test", Slices: []pies.Slice{
+				{Weight: 60, Asset: pies.Asset{Symbol: "VTI"}},
+				childSlice(40, "bonds", testPie("BND", 75, "BNDX", 25)),
+			}},
+			want: []leaf{{"VTI", 60, nil}, {"BND", 30, nil}, {"BNDX", 10, nil}},
+		},
+		{
+			name: "weights are multiplied through every level",
+			pie: pies.Pie{Name: "test", Slices: []pies.Slice{
+				{Weight: 50, Asset: pies.Asset{Symbol: "VTI"}},
+				childSlice(50, "rest", pies.Pie{Slices: []pies.Slice{
+					{Weight: 50, Asset: pies.Asset{Symbol: "VXUS"}},
+					childSlice(50, "bonds", testPie("BND", 80, "BNDX", 20)),
+				}}),
+			}},
+			want: []leaf{{"VTI", 50, nil}, {"VXUS", 25, nil}, {"BND", 20, nil}, {"BNDX", 5, nil}},
+		},
+		{
+			name: "duplicate leaves are merged in the first one's place",
+			pie: pies.Pie{Name: "test", Slices: []pies.Slice{
+				{Weight: 50, Asset: pies.Asset{Symbol: "VTI"}},
+				childSlice(30, "mixed", testPie("BND", 50, "vti", 50)),
+				childSlice(20, "bonds", testPie("BND", 100)),
+			}},
+			want: []leaf{{"VTI", 65, nil}, {"BND", 35, nil}},
+		},
+		{
+			name: "leaves inherit the band of the slice holding their pie",
+			pie: pies.Pie{Name: "test", Slices: []pies.Slice{
+				{Weight: 50, Asset: pies.Asset{Symbol: "VTI"}},
+				{Weight: 50, Pie: &pies.Pie{Name: "bonds", Slices: testPie("BND", 100).Slices}, Band: loose},
+			}},
+			want: []leaf{{"VTI", 50, nil}, {"BND", 50, loose}},
+		},
+		{
+			name: "child pie's default band beats the holding slice's",
+			pie: pies.Pie{Name: "test", Slices: []pies.Slice{
+				{Weight: 50, Asset: pies.Asset{Symbol: "VTI"}},
+				{Weight: 50, Pie: &pies.Pie{Name: "bonds", Slices: testPie("BND", 100).Slices, Band: relative}, Band: loose},
+			}},
+			want: []leaf{{"VTI", 50, nil}, {"BND", 50, &relative}},
+		},
+		{
+			name: "leaf's own band beats every inherited one",
+			pie: pies.Pie{Name: "test", Slices: []pies.Slice{
+				{Weight: 50, Asset: pies.Asset{Symbol: "VTI"}},
+				{Weight: 50, Band: loose, Pie: &pies.Pie{Name: "bonds", Band: relative, Slices: []pies.Slice{
+					{Weight: 50, Asset: pies.Asset{Symbol: "BND"}, Band: tight},
+					{Weight: 50, Asset: pies.Asset{Symbol: "BNDX"}},
+				}}},
+			}},
+			want: []leaf{{"VTI", 50, nil}, {"BND", 25, tight}, {"BNDX", 25, &relative}},
+		},
+		{
+			name: "bands pass down through pies without one",
+			pie: pies.Pie{Name: "test", Slices: []pies.Slice{
+				{Weight: 50, Asset: pies.Asset{Symbol: "VTI"}},
+				{Weight: 50, Band: loose, Pie: &pies.Pie{Name: "rest", Slices: []pies.Slice{
+					{Weight: 50, Asset: pies.Asset{Symbol: "VXUS"}},
+					childSlice(50, "bonds", testPie("BND", 100)),
+				}}},
+			}},
+			want: []leaf{{"VTI", 50, nil}, {"VXUS", 25, loose}, {"BND", 25, loose}},
+		},
+		{
+			name: "nested as deep as allowed",
+			pie:  nestedPie(pies.MaxPieDepth),
+			want: []leaf{{"VTI", 93.75, nil}, {"BND", 6.25, nil}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flat, err := tt.pie.Flatten()
+			if err != nil {
+				t.Fatalf("Flatten() error = %v", err)
+			}
+			if flat.IsNested() {
+				t.Errorf("Flatten() = %+v, want no child pies", flat)
+			}
+			if len(flat.Slices) != len(tt.want) {
+				t.Fatalf("Flatten() has %d slices, want %d", len(flat.Slices), len(tt.want))
+			}
+			for idx, want := range tt.want {
+				got := flat.Slices[idx]
+				if !strings.EqualFold(got.Asset.Symbol, want.symbol) || math.Abs(got.Weight-want.weight) > 1e-9 {
+					t.Errorf("slice %d = %s at %v, want %s at %v", idx, got.Asset.Symbol, got.Weight, want.symbol, want.weight)
+				}
+				if !reflect.DeepEqual(got.Band, want.band) {
+					t.Errorf("slice %d band = %+v, want %+v", idx, got.Band, want.band)
+				}
+			}
+		})
+	}
+}
+
+func TestFlattenRejectsInvalidNesting(t *testing.T) {
+	// cyclic holds VTI and itself
+	cyclic := &pies.Pie{Name: "cyclic", Slices: []pies.Slice{{Weight: 50, Asset: pies.Asset{Symbol: "VTI"}}}}
+	cyclic.Slices = append(cyclic.Slices, pies.Slice{Weight: 50, Pie: cyclic})
+
+	tests := []struct {
+		name    string
+		pie     pies.Pie
+		wantErr string
+	}{
+		{
+			name:    "pie containing itself",
+			pie:     pies.Pie{Name: "test", Slices: []pies.Slice{{Weight: 100, Pie: cyclic}}},
+			wantErr: "pie contains itself",
+		},
+		{
+			name:    "nested too deep",
+			pie:     nestedPie(pies.MaxPieDepth + 1),
+			wantErr: "nested more than 4 levels deep",
+		},
+		{
+			name: "child weights not summing to 100",
+			pie: pies.Pie{Name: "test", Slices: []pies.Slice{
+				{Weight: 50, Asset: pies.Asset{Symbol: "VTI"}},
+				childSlice(50, "bonds", testPie("BND", 60, "BNDX", 30)),
+			}},
+			wantErr: "slice weights sum to 90",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.pie.Flatten(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Flatten() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package pies
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// notifyTimeout bounds how long delivering a notification may take
+const notifyTimeout = 30 * time.Second
+
+// EventKind is what an Event reports
+type EventKind string
+
+const (
+	EventDriftBreach        EventKind = "drift_breach"        // Slices are outside their drift band
+	EventPlanComputed       EventKind = "plan_computed"       // A rebalance plan was computed, and not executed yet
+	EventExecutionCompleted EventKind = "execution_completed" // Every order of a plan was placed and waited for
+	EventExecutionFailed    EventKind = "execution_failed"    // A rebalance, or some of its orders, failed
+	EventTokenExpiring      EventKind = "token_expiring"      // The brokerage sign-in expires soon, or has
+)
+
+// Event is something about a pie the user is told about. Only the fields
+// relevant to its kind are set.
+type Event struct {
+	Kind      EventKind        `json:"kind"`
+	Time      time.Time        `json:"time"`
+	Pie       string           `json:"pie,omitempty"`
+	Message   string           `json:"message"`             // One line for people
+	Breaching []string         `json:"breaching,omitempty"` // Symbols outside their drift band
+	Plan      *RebalancePlan   `json:"plan,omitempty"`
+	Report    *ExecutionReport `json:"report,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	ExpiresAt *time.Time       `json:"expires_at,omitempty"` // When the sign-in expires, for EventTokenExpiring
+}
+
+// Notifier delivers events to the user, see the notify package
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// RefreshTokenExpirer is implemented by brokerage clients that know when
+// their credentials expire for good
+type RefreshTokenExpirer interface {
+	// RefreshTokenExpiresAt returns when the refresh token expires, zero
+	// when it is not known
+	RefreshTokenExpiresAt() time.Time
+}
+
+// AutoRefresher is implemented by brokerage clients that can keep their
+// access token fresh in the background, for long-running processes
+type AutoRefresher interface {
+	// SetNotifier makes the client tell notifier when it cannot refresh
+	SetNotifier(notifier Notifier)
+	// StartAutoRefresh refreshes in the background until ctx is cancelled,
+	// closing the returned channel once it has stopped
+	StartAutoRefresh(ctx context.Context) <-chan struct{}
+}
+
+// deliver sends event to notifier, logging to logger when it fails: failing
+// to notify never fails what is being reported. The event is still sent
+// once ctx is cancelled, since an interrupted rebalance is worth knowing
+// about.
+func deliver(ctx context.Context, notifier Notifier, logger *slog.Logger, event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notifyTimeout)
+	defer cancel()
+	if err := notifier.Notify(ctx, event); err != nil {
+		logger.ErrorContext(ctx, "failed to send notification", slog.String("kind", string(event.Kind)), slog.String("error", err.Error()))
+	}
+}
+
+// notify sends event to the investor's Notifier, if any
+func (i *Investor) notify(ctx context.Context, event Event) {
+	if i.Notifier != nil {
+		deliver(ctx, i.Notifier, i.logger(), event)
+	}
+}
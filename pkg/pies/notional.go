@@ -0,0 +1,130 @@
+package pies
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// ResolveAmount converts a dollar-denominated order into a share quantity
+// using a fresh quote from quotes. Limit orders are sized at their limit
+// price, market buys at the ask and market sells at the bid. Unless
+// fractional is set the quantity is rounded down to whole shares, so the
+// order never trades more than Amount. Orders sized by Quantity are returned
+// unchanged.
+func ResolveAmount(ctx context.Context, quotes QuoteSource, order OrderRequest, fractional bool) (OrderRequest, error) {
+	if err := order.Validate(); err != nil {
+		return order, err
+	}
+	if order.Amount == nil {
+		return order, nil
+	}
+
+	price, err := notionalPrice(ctx, quotes, order)
+	if err != nil {
+		return order, err
+	}
+
+	quantity := RoundShares(order.Amount.Div(price), fractional)
+	if !quantity.IsPositive() {
+		return order, fmt.Errorf("amount %s buys no whole shares of %s at %s", order.Amount.StringFixed(centPlaces), order.Symbol, price)
+	}
+
+	order.Quantity = quantity
+	order.Amount = nil
+	return order, nil
+}
+
+// notionalPrice is the per-share price a dollar-denominated order is sized at
+func notionalPrice(ctx context.Context, quotes QuoteSource, order OrderRequest) (decimal.Decimal, error) {
+	if order.Type == OrderTypeLimit && order.LimitPrice != nil {
+		return *order.LimitPrice, nil
+	}
+
+	quote, err := quotes.GetQuote(ctx, order.Symbol)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to get quote to size order for %s: %w", order.Symbol, err)
+	}
+
+	price := quotePrice(*quote, order.Action, false)
+	if !price.IsPositive() {
+		return decimal.Zero, fmt.Errorf("no usable price in quote for %s", order.Symbol)
+	}
+
+	return price, nil
+}
+
+// quotePrice is the price a market order for action is expected to trade
+// at: the ask for buys and the bid for sells, falling back to the last price.
+// With extendedHours the quote's extended-hours prices are tried first, the
+// same way, see Quote.Price.
+func quotePrice(quote Quote, action OrderAction, extendedHours bool) decimal.Decimal {
+	if ext := quote.Extended; extendedHours && ext != nil {
+		switch {
+		case action == OrderActionBuy && ext.Ask.IsPositive():
+			return ext.Ask
+		case action == OrderActionSell && ext.Bid.IsPositive():
+			return ext.Bid
+		case ext.Last.IsPositive():
+			return ext.Last
+		}
+	}
+
+	switch {
+	case action == OrderActionBuy && quote.Ask.IsPositive():
+		return quote.Ask
+	case action == OrderActionSell && quote.Bid.IsPositive():
+		return quote.Bid
+	default:
+		return quote.Last
+	}
+}
+
+// NotionalAllowed reports whether an order for an asset of assetType may be
+// sized in dollars. Mutual funds and cash equivalents are bought and sold
+// in dollars anywhere; other assets only where fractional shares trade.
+func NotionalAllowed(assetType string, fractional bool) bool {
+	switch assetType {
+	case AssetTypeMutualFund, AssetTypeCashEquivalent:
+		return true
+	default:
+		return fractional
+	}
+}
+
+// Notional returns a copy of the plan with its orders sized in dollars, their
+// planned value rounded to cents, instead of in shares, for the orders
+// NotionalAllowed allows given whether the brokerage trades fractional
+// shares. Brokerages taking dollar orders then trade the whole value rather
+// than the shares it bought at the planned price; the others convert it
+// back at the quote when the order is placed. Orders worth less than a cent
+// are skipped.
+func (p RebalancePlan) Notional(fractional bool) *RebalancePlan {
+	notional := p
+	notional.Orders = make([]PlannedOrder, 0, len(p.Orders))
+	notional.Skipped = append([]SkippedTrade(nil), p.Skipped...)
+	for _, planned := range p.Orders {
+		if !NotionalAllowed(planned.AssetType, fractional) {
+			notional.Orders = append(notional.Orders, planned)
+			continue
+		}
+
+		amount := RoundCents(planned.Value)
+		if !amount.IsPositive() {
+			notional.Skipped = append(notional.Skipped, SkippedTrade{
+				Symbol:   planned.Order.Symbol,
+				Action:   planned.Order.Action,
+				Quantity: planned.Order.Quantity,
+				Value:    planned.Value,
+				Reason:   "worth less than a cent",
+			})
+			continue
+		}
+
+		planned.Order.Quantity = decimal.Zero
+		planned.Order.Amount = &amount
+		notional.Orders = append(notional.Orders, planned)
+	}
+	return &notional
+}
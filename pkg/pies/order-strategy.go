@@ -0,0 +1,94 @@
+package pies
+
+import (
+	"errors"
+	"fmt"
+)
+
+// OrderStrategyType describes how the orders of an OrderStrategy relate
+type OrderStrategyType string
+
+const (
+	OrderStrategySingle  OrderStrategyType = "SINGLE"  // A single order
+	OrderStrategyOCO     OrderStrategyType = "OCO"     // One cancels other: the first child to fill cancels the rest
+	OrderStrategyTrigger OrderStrategyType = "TRIGGER" // Children are submitted once the order fills
+)
+
+// MaxOrderStrategyDepth is how deeply strategies may be nested, enough for a
+// trigger that submits an OCO bracket
+const MaxOrderStrategyDepth = 2
+
+// OrderStrategy composes orders into a conditional strategy. SINGLE and
+// TRIGGER strategies have an Order; a TRIGGER's Children are submitted once
+// it fills. OCO strategies have no Order of their own, only Children.
+type OrderStrategy struct {
+	Type     OrderStrategyType
+	Order    *OrderRequest
+	Children []OrderStrategy
+}
+
+// SingleOrder wraps order in a SINGLE strategy
+func SingleOrder(order OrderRequest) OrderStrategy {
+	return OrderStrategy{Type: OrderStrategySingle, Order: &order}
+}
+
+// TriggerOrder submits children once order fills, e.g. to attach a stop to a buy
+func TriggerOrder(order OrderRequest, children ...OrderStrategy) OrderStrategy {
+	return OrderStrategy{Type: OrderStrategyTrigger, Order: &order, Children: children}
+}
+
+// OCOOrder cancels the remaining children as soon as one of them fills
+func OCOOrder(children ...OrderStrategy) OrderStrategy {
+	return OrderStrategy{Type: OrderStrategyOCO, Children: children}
+}
+
+// Validate checks the strategy's shape and that it is nested no deeper than
+// MaxOrderStrategyDepth
+func (s OrderStrategy) Validate() error {
+	return s.validate(0)
+}
+
+func (s OrderStrategy) validate(depth int) error {
+	if depth > MaxOrderStrategyDepth {
+		return fmt.Errorf("order strategies cannot be nested more than %d levels deep", MaxOrderStrategyDepth)
+	}
+
+	switch s.Type {
+	case OrderStrategySingle:
+		if s.Order == nil {
+			return errors.New("SINGLE strategy needs an order")
+		}
+		if len(s.Children) > 0 {
+			return errors.New("SINGLE strategy cannot have children, use TRIGGER")
+		}
+	case OrderStrategyTrigger:
+		if s.Order == nil {
+			return errors.New("TRIGGER strategy needs an order")
+		}
+		if len(s.Children) == 0 {
+			return errors.New("TRIGGER strategy needs at least one child")
+		}
+	case OrderStrategyOCO:
+		if s.Order != nil {
+			return errors.New("OCO strategy cannot have an order of its own")
+		}
+		if len(s.Children) < 2 {
+			return errors.New("OCO strategy needs at least two children")
+		}
+	default:
+		return fmt.Errorf("unsupported order strategy type %q", s.Type)
+	}
+
+	if s.Order != nil {
+		if err := s.Order.Validate(); err != nil {
+			return err
+		}
+	}
+	for i, child := range s.Children {
+		if err := child.validate(depth + 1); err != nil {
+			return fmt.Errorf("child %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
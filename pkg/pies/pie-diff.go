@@ -0,0 +1,184 @@
+package pies
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PieDiff lists how the slices of one pie differ from another's. Slices are
+// matched by symbol, so an asset that was only renamed shows up as changed
+// rather than as removed and added.
+type PieDiff struct {
+	Added   []SliceChange `json:"added"`
+	Removed []SliceChange `json:"removed"`
+	Changed []SliceChange `json:"changed"` // In both pies with a different weight or name
+}
+
+// SliceChange is one slice's weight and asset name before and after
+type SliceChange struct {
+	Symbol    string  `json:"symbol"`
+	OldWeight float64 `json:"old_weight"`
+	NewWeight float64 `json:"new_weight"`
+	OldName   string  `json:"old_name"`
+	NewName   string  `json:"new_name"`
+}
+
+// WeightChange returns the change in weight in percentage points
+func (c SliceChange) WeightChange() float64 {
+	return c.NewWeight - c.OldWeight
+}
+
+// Renamed reports whether the slice's asset name changed
+func (c SliceChange) Renamed() bool {
+	return c.OldName != "" && c.NewName != "" && c.OldName != c.NewName
+}
+
+// IsEmpty reports whether the pies hold the same slices at the same weights
+func (d PieDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares the symbols of two pies in their flattened form, so a
+// change inside a child pie shows up as changes to its symbols' weights.
+// Glide paths are compared at today's weights. Pies that fail validation
+// are compared by their top-level slices.
+func Diff(old, new Pie) PieDiff {
+	before, after := diffSlices(old), diffSlices(new)
+
+	var diff PieDiff
+	for key, slice := range after {
+		previous, ok := before[key]
+		change := SliceChange{
+			Symbol:    diffLabel(slice),
+			OldWeight: previous.Weight,
+			NewWeight: slice.Weight,
+			OldName:   previous.Asset.Name,
+			NewName:   slice.Asset.Name,
+		}
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, change)
+		case math.Abs(change.WeightChange()) > weightTolerance || change.Renamed():
+			diff.Changed = append(diff.Changed, change)
+		}
+	}
+	for key, slice := range before {
+		if _, ok := after[key]; !ok {
+			diff.Removed = append(diff.Removed, SliceChange{
+				Symbol:    diffLabel(slice),
+				OldWeight: slice.Weight,
+				OldName:   slice.Asset.Name,
+			})
+		}
+	}
+
+	for _, changes := range [][]SliceChange{diff.Added, diff.Removed, diff.Changed} {
+		sort.Slice(changes, func(a, b int) bool {
+			return changes[a].Symbol < changes[b].Symbol
+		})
+	}
+	return diff
+}
+
+// diffSlices returns the slices Diff compares, keyed like Slice.key
+func diffSlices(pie Pie) map[string]Slice {
+	slices := pie.Slices
+	if flat, err := pie.Flatten(); err == nil {
+		slices = flat.Slices
+	}
+
+	keyed := make(map[string]Slice, len(slices))
+	for _, slice := range slices {
+		key := slice.key()
+		if slice.Pie != nil {
+			key = "pie " + slice.Pie.Name
+		}
+		keyed[key] = slice
+	}
+	return keyed
+}
+
+// diffLabel names the slice in a SliceChange
+func diffLabel(slice Slice) string {
+	switch {
+	case slice.Pie != nil:
+		return slice.Pie.Name
+	case slice.IsCash():
+		return cashLabel
+	default:
+		return normalizeSymbol(slice.Asset.Symbol)
+	}
+}
+
+// PlanForPieChange plans the trades that move the account from old to new:
+// the holdings of symbols new no longer has are sold in full, and their
+// proceeds and the account's cash are spent rebalancing towards new as
+// ComputeRebalancePlan would with opts.
+func (i *Investor) PlanForPieChange(ctx context.Context, old, new Pie, opts RebalanceOptions) (*RebalancePlan, error) {
+	if err := new.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid pie %s: %w", new.Name, err)
+	}
+	flat, err := new.EffectiveAt(time.Now()).Flatten()
+	if err != nil {
+		return nil, fmt.Errorf("invalid pie %s: %w", new.Name, err)
+	}
+
+	// Quote the removed symbols along with the new pie's
+	removed := Diff(old, new).Removed
+	quoted := flat
+	quoted.Slices = append([]Slice(nil), flat.Slices...)
+	for _, change := range removed {
+		if change.Symbol != cashLabel {
+			quoted.Slices = append(quoted.Slices, Slice{Asset: Asset{Symbol: change.Symbol}})
+		}
+	}
+
+	holdings, err := i.getHoldings(ctx, quoted)
+	if err != nil {
+		return nil, err
+	}
+
+	var unpriced []string
+	removal := &RebalancePlan{}
+	var proceeds decimal.Decimal
+	for _, change := range removed {
+		position, held := holdings.positions[change.Symbol]
+		if !held || !position.Quantity.IsPositive() {
+			continue
+		}
+
+		price := quotePrice(holdings.quotes[change.Symbol], OrderActionSell, holdings.extendedHours)
+		if !price.IsPositive() {
+			unpriced = append(unpriced, change.Symbol)
+			continue
+		}
+		if planned, ok := removal.add(SliceStatus{Symbol: change.Symbol, AssetType: position.AssetType}, OrderActionSell, position.Quantity, price, opts); ok {
+			proceeds = proceeds.Add(planned.Value)
+		}
+	}
+
+	// The removed holdings count as cash once sold
+	funded := *holdings
+	funded.account.CashBalance = funded.account.CashBalance.Add(proceeds)
+	status := computePieStatus(flat, &funded)
+	unpriced = append(status.Unpriced, unpriced...)
+	if len(unpriced) > 0 {
+		return nil, fmt.Errorf("cannot rebalance without quotes for %s", strings.Join(unpriced, ", "))
+	}
+
+	plan := computeRebalancePlan(status, holdings.quotes, holdings.extendedHours, opts)
+	plan.Cash = holdings.account.CashBalance
+	plan.Orders = append(removal.Orders, plan.Orders...)
+	plan.Skipped = append(removal.Skipped, plan.Skipped...)
+
+	if err := i.screenPlan(ctx, plan, opts.AcknowledgeUnscreened); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
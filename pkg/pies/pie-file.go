@@ -0,0 +1,385 @@
+package pies
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PieFileVersion is the version of the pie file schema written by Save
+const PieFileVersion = 1
+
+// pieFile is the on-disk form of a Pie. In JSON:
+//
+//	{
+//	  "version": 1,
+//	  "id": "core",                      // optional
+//	  "name": "Core",
+//	  "description": "Three fund pie",   // optional
+//	  "band": {"absolute": 5},           // optional default drift band
+//	  "slices": [
+//	    {"symbol": "VTI", "weight": 60, "band": {"relative": 0.25}},
+//	    {"symbol": "VXUS", "weight": 30},
+//	    {"symbol": "BND", "weight": 10, "name": "Total Bond Market"}
+//	  ]
+//	}
+//
+// Weights are percentages summing to 100. Bands take an absolute threshold in
+// percentage points and a relative one as a fraction of the target weight;
+// see DriftBand. Slices may also carry the asset's "name", "asset_type",
+// "asset_id", "active" and "status". In a nested pie a slice holds a child
+// pie, written without a version, instead of a symbol:
+//
+//	{"weight": 60, "pie": {"name": "US equity", "slices": [...]}}
+//
+// A slice with the asset type "CASH" and no symbol targets uninvested cash,
+// see AssetTypeCash:
+//
+//	{"asset_type": "CASH", "weight": 5}
+//
+// A pie with a "glide_path" takes its weights from the path's anchors, see
+// GlidePath, and its slices need no weights of their own:
+//
+//	"glide_path": [
+//	  {"date": "2030-01-01", "weights": {"VTI": 90, "BND": 10}},
+//	  {"date": "2055-01-01", "weights": {"VTI": 40, "BND": 60}}
+//	]
+//
+// "benchmarks" lists symbols, e.g. ["VT", "SPY"], that the pie's returns are
+// compared with, see Investor.GetPieStatusWithReturns.
+//
+// "risk" sets the pre-trade checks of the pie's orders, in the schema of
+// RiskLimits:
+//
+//	"risk": {"max_order_value": {"limit": 10000}}
+//
+// YAML files use the same keys. The "shared" key is ignored, so it can hold
+// anchors for blocks that several slices or pies have in common:
+//
+//	version: 1
+//	name: Core
+//	shared:
+//	  bonds: &bonds {symbol: BND, weight: 10, band: {absolute: 2}}
+//	slices:
+//	  - {symbol: VTI, weight: 60}
+//	  - {symbol: VXUS, weight: 30}
+//	  - *bonds
+type pieFile struct {
+	Version     int          `json:"version,omitempty" yaml:"version,omitempty"` // Only set on the top-level pie
+	ID          string       `json:"id,omitempty" yaml:"id,omitempty"`
+	Name        string       `json:"name" yaml:"name"`
+	Description string       `json:"description,omitempty" yaml:"description,omitempty"`
+	Band        *bandFile    `json:"band,omitempty" yaml:"band,omitempty"`
+	GlidePath   []anchorFile `json:"glide_path,omitempty" yaml:"glide_path,omitempty"`
+	Benchmarks  []string     `json:"benchmarks,omitempty" yaml:"benchmarks,omitempty"`
+	Risk        *RiskLimits  `json:"risk,omitempty" yaml:"risk,omitempty"`
+	Slices      []sliceFile  `json:"slices" yaml:"slices"`
+	Shared      any          `json:"shared,omitempty" yaml:"shared,omitempty"` // Ignored, see above
+}
+
+type sliceFile struct {
+	Symbol    string    `json:"symbol,omitempty" yaml:"symbol,omitempty"`
+	Weight    float64   `json:"weight,omitempty" yaml:"weight,omitempty"` // Omitted under a glide path
+	Name      string    `json:"name,omitempty" yaml:"name,omitempty"`
+	AssetType string    `json:"asset_type,omitempty" yaml:"asset_type,omitempty"`
+	AssetID   string    `json:"asset_id,omitempty" yaml:"asset_id,omitempty"`
+	Active    bool      `json:"active,omitempty" yaml:"active,omitempty"`
+	Status    string    `json:"status,omitempty" yaml:"status,omitempty"`
+	Band      *bandFile `json:"band,omitempty" yaml:"band,omitempty"`
+	Pie       *pieFile  `json:"pie,omitempty" yaml:"pie,omitempty"` // Child pie held instead of a symbol
+}
+
+type anchorFile struct {
+	Date    string             `json:"date" yaml:"date"` // 2006-01-02
+	Weights map[string]float64 `json:"weights" yaml:"weights"`
+}
+
+type bandFile struct {
+	Absolute float64 `json:"absolute,omitempty" yaml:"absolute,omitempty"`
+	Relative float64 `json:"relative,omitempty" yaml:"relative,omitempty"`
+}
+
+// LoadPie reads a pie saved at path and validates it. Files ending in .yaml
+// or .yml are read as YAML and files ending in .json as JSON; any other file
+// is read as JSON if it starts with '{' and as YAML otherwise. Both formats
+// share the schema documented on pieFile, and YAML files may use comments,
+// anchors and aliases. Unknown fields are rejected so that misspelt keys do
+// not silently go missing.
+func LoadPie(path string) (Pie, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Pie{}, fmt.Errorf("failed to read pie file: %w", err)
+	}
+
+	var file pieFile
+	if isYAMLPieFile(path, raw) {
+		decoder := yaml.NewDecoder(bytes.NewReader(raw))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&file); err != nil {
+			return Pie{}, fmt.Errorf("failed to parse pie file %s: %w", path, err)
+		}
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&file); err != nil {
+			return Pie{}, fmt.Errorf("failed to parse pie file %s: %w", path, describeJSONError(raw, err))
+		}
+	}
+
+	pie, err := file.toPie()
+	if err != nil {
+		return Pie{}, fmt.Errorf("invalid pie file %s: %w", path, err)
+	}
+	return pie, nil
+}
+
+// isYAMLPieFile reports whether the pie file at path holds YAML
+func isYAMLPieFile(path string, raw []byte) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	case ".json":
+		return false
+	default:
+		return !bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{"))
+	}
+}
+
+// Save validates the pie and writes it to path as JSON, replacing the file
+// atomically
+func (p Pie) Save(path string) error {
+	if err := p.Validate(); err != nil {
+		return fmt.Errorf("invalid pie %s: %w", p.Name, err)
+	}
+
+	raw, err := json.MarshalIndent(newPieFile(p), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal pie: %w", err)
+	}
+	raw = append(raw, '\n')
+
+	if err := writeFileAtomic(path, raw); err != nil {
+		return fmt.Errorf("failed to save pie file: %w", err)
+	}
+	return nil
+}
+
+// SaveYAML validates the pie and writes it to path as YAML, replacing the
+// file atomically
+func (p Pie) SaveYAML(path string) error {
+	if err := p.Validate(); err != nil {
+		return fmt.Errorf("invalid pie %s: %w", p.Name, err)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(newPieFile(p)); err != nil {
+		return fmt.Errorf("failed to marshal pie: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to marshal pie: %w", err)
+	}
+
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to save pie file: %w", err)
+	}
+	return nil
+}
+
+// MarshalJSON encodes the pie in the pie file schema, see pieFile
+func (p Pie) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newPieFile(p))
+}
+
+// UnmarshalJSON decodes and validates a pie in the pie file schema
+func (p *Pie) UnmarshalJSON(raw []byte) error {
+	var file pieFile
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return fmt.Errorf("failed to parse pie: %w", err)
+	}
+
+	pie, err := file.toPie()
+	if err != nil {
+		return err
+	}
+	*p = pie
+	return nil
+}
+
+// newPieFile converts a pie to its on-disk form
+func newPieFile(p Pie) pieFile {
+	file := encodePie(p)
+	file.Version = PieFileVersion
+	return file
+}
+
+// encodePie converts a pie and its child pies to the on-disk form
+func encodePie(p Pie) pieFile {
+	file := pieFile{
+		ID:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Benchmarks:  p.Benchmarks,
+		Risk:        p.Risk,
+		Slices:      make([]sliceFile, 0, len(p.Slices)),
+	}
+	if p.Band != (DriftBand{}) {
+		file.Band = &bandFile{Absolute: p.Band.Absolute, Relative: p.Band.Relative}
+	}
+	if p.GlidePath != nil {
+		for _, anchor := range p.GlidePath.Anchors {
+			file.GlidePath = append(file.GlidePath, anchorFile{
+				Date:    anchor.Date.Format(time.DateOnly),
+				Weights: anchor.Weights,
+			})
+		}
+	}
+
+	for _, slice := range p.Slices {
+		sf := sliceFile{
+			Symbol:    slice.Asset.Symbol,
+			Weight:    slice.Weight,
+			Name:      slice.Asset.Name,
+			AssetType: slice.Asset.TypeName,
+			AssetID:   slice.Asset.ID,
+			Active:    slice.Asset.IsActive,
+			Status:    slice.Asset.Status,
+		}
+		if slice.Band != nil {
+			sf.Band = &bandFile{Absolute: slice.Band.Absolute, Relative: slice.Band.Relative}
+		}
+		if slice.Pie != nil {
+			child := encodePie(*slice.Pie)
+			sf.Pie = &child
+		}
+		file.Slices = append(file.Slices, sf)
+	}
+
+	return file
+}
+
+// toPie converts the on-disk form back to a pie and validates it
+func (f pieFile) toPie() (Pie, error) {
+	if f.Version != PieFileVersion {
+		return Pie{}, fmt.Errorf("unsupported version %d, want %d", f.Version, PieFileVersion)
+	}
+
+	pie, err := f.decode()
+	if err != nil {
+		return Pie{}, err
+	}
+	if err := pie.Validate(); err != nil {
+		return Pie{}, err
+	}
+	return pie, nil
+}
+
+// decode converts the on-disk form of a pie and its child pies to a Pie
+func (f pieFile) decode() (Pie, error) {
+	pie := Pie{
+		ID:          f.ID,
+		Name:        f.Name,
+		Description: f.Description,
+		Benchmarks:  f.Benchmarks,
+		Risk:        f.Risk,
+		Slices:      make([]Slice, 0, len(f.Slices)),
+	}
+	if f.Band != nil {
+		pie.Band = DriftBand{Absolute: f.Band.Absolute, Relative: f.Band.Relative}
+	}
+	if f.GlidePath != nil {
+		pie.GlidePath = &GlidePath{}
+		for _, af := range f.GlidePath {
+			date, err := time.Parse(time.DateOnly, af.Date)
+			if err != nil {
+				return Pie{}, fmt.Errorf("pie %s: invalid glide path date %q, want YYYY-MM-DD", f.Name, af.Date)
+			}
+			pie.GlidePath.Anchors = append(pie.GlidePath.Anchors, GlideAnchor{Date: date, Weights: af.Weights})
+		}
+	}
+
+	for _, sf := range f.Slices {
+		slice := Slice{
+			Weight: sf.Weight,
+			Asset: Asset{
+				TypeName: sf.AssetType,
+				ID:       sf.AssetID,
+				IsActive: sf.Active,
+				Name:     sf.Name,
+				Symbol:   sf.Symbol,
+				Status:   sf.Status,
+			},
+		}
+		if sf.Band != nil {
+			slice.Band = &DriftBand{Absolute: sf.Band.Absolute, Relative: sf.Band.Relative}
+		}
+		if sf.Pie != nil {
+			child, err := sf.Pie.decode()
+			if err != nil {
+				return Pie{}, err
+			}
+			slice.Pie = &child
+		}
+		pie.Slices = append(pie.Slices, slice)
+	}
+
+	return pie, nil
+}
+
+// describeJSONError adds the line and column to JSON syntax and type errors
+func describeJSONError(raw []byte, err error) error {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// The offset counts the offending byte, the column points at it
+		offset = syntaxErr.Offset - 1
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return err
+	}
+
+	line, column := 1, 1
+	for _, b := range raw[:min(max(int(offset), 0), len(raw))] {
+		if b == '\n' {
+			line, column = line+1, 1
+		} else {
+			column++
+		}
+	}
+	return fmt.Errorf("line %d, column %d: %w", line, column, err)
+}
+
+// writeFileAtomic replaces path with data so readers never see a partial file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
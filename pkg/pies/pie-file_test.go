@@ -0,0 +1,391 @@
+package pies_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// writePieFile writes content to a file called name in a temporary directory
+// and returns its path
+func writePieFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// fullPie returns a pie that sets every field the pie file schema holds
+func fullPie() pies.Pie {
+	return pies.Pie{
+		ID:          "core",
+		Name:        "Core",
+		Description: "Three fund pie",
+		Band:        pies.DriftBand{Absolute: 5},
+		Benchmarks:  []string{"VT", "SPY"},
+		Risk: &pies.RiskLimits{
+			MaxOrderValue:     pies.RiskRule{Limit: 10_000},
+			MaxPriceDeviation: pies.RiskRule{Limit: 2, Warn: true},
+		},
+		Slices: []pies.Slice{
+			{
+				Weight: 55,
+				Band:   &pies.DriftBand{Relative: 0.25},
+				Asset: pies.Asset{
+					TypeName: "EQUITY",
+					ID:       "asset-vti",
+					IsActive: true,
+					Name:     "Vanguard Total Stock Market ETF",
+					Symbol:   "VTI",
+					Status:   "active",
+				},
+			},
+			{
+				Weight: 40,
+				Pie: &pies.Pie{
+					Name: "Bonds",
+					Band: pies.DriftBand{Absolute: 1, Relative: 0.1},
+					Slices: []pies.Slice{
+						{Weight: 70, Asset: pies.Asset{Symbol: "BND"}},
+						{Weight: 30, Asset: pies.Asset{Symbol: "BNDX"}},
+					},
+				},
+			},
+			{Weight: 5, Asset: pies.Asset{TypeName: pies.AssetTypeCash}},
+		},
+	}
+}
+
+func TestPieFileRoundTrip(t *testing.T) {
+	glide := testPie("VTI", 0, "BND", 0)
+	glide.GlidePath = &pies.GlidePath{Anchors: []pies.GlideAnchor{
+		{Date: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), Weights: map[string]float64{"VTI": 90, "BND": 10}},
+		{Date: time.Date(2055, 1, 1, 0, 0, 0, 0, time.UTC), Weights: map[string]float64{"VTI": 40, "BND": 60}},
+	}}
+
+	for name, pie := range map[string]pies.Pie{"every field": fullPie(), "glide path": glide} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pie.json")
+			if err := pie.Save(path); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			loaded, err := pies.LoadPie(path)
+			if err != nil {
+				t.Fatalf("LoadPie() error = %v", err)
+			}
+			if !reflect.DeepEqual(loaded, pie) {
+				t.Errorf("LoadPie() = %+v\nwant %+v", loaded, pie)
+			}
+		})
+	}
+}
+
+func TestSaveRejectsInvalidPie(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pie.json")
+	pie := testPie("VTI", 60, "BND", 30)
+
+	if err := pie.Save(path); err == nil || !strings.Contains(err.Error(), "slice weights sum to 90") {
+		t.Errorf("Save() error = %v, want the weight sum error", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Save() of an invalid pie wrote a file, stat error = %v", err)
+	}
+}
+
+func TestLoadPieErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "zero weight",
+			content: `{"version": 1, "name": "p", "slices": [{"symbol": "VTI", "weight": 60}, {"symbol": "VXUS", "weight": 40}, {"symbol": "BND", "weight": 0}]}`,
+			wantErr: "slice 2: weight of BND must be > 0, got 0",
+		},
+		{
+			name:    "negative weight",
+			content: `{"version": 1, "name": "p", "slices": [{"symbol": "VTI", "weight": 110}, {"symbol": "BND", "weight": -10}]}`,
+			wantErr: "slice 1: weight of BND must be > 0, got -10",
+		},
+		{
+			name:    "weights not summing to 100",
+			content: `{"version": 1, "name": "p", "slices": [{"symbol": "VTI", "weight": 60}, {"symbol": "BND", "weight": 30}]}`,
+			wantErr: "slice weights sum to 90, want 100",
+		},
+		{
+			name:    "missing symbol",
+			content: `{"version": 1, "name": "p", "slices": [{"symbol": "VTI", "weight": 60}, {"weight": 40}]}`,
+			wantErr: "slice 1: symbol is empty",
+		},
+		{
+			name:    "duplicate symbol",
+			content: `{"version": 1, "name": "p", "slices": [{"symbol": "VTI", "weight": 60}, {"symbol": "vti", "weight": 40}]}`,
+			wantErr: "slice 1: VTI is already in slice 0",
+		},
+		{
+			name:    "missing version",
+			content: `{"name": "p", "slices": [{"symbol": "VTI", "weight": 100}]}`,
+			wantErr: "unsupported version 0, want 1",
+		},
+		{
+			name:    "future version",
+			content: `{"version": 2, "name": "p", "slices": [{"symbol": "VTI", "weight": 100}]}`,
+			wantErr: "unsupported version 2, want 1",
+		},
+		{
+			name:    "misspelt key",
+			content: `{"version": 1, "name": "p", "slices": [{"symbol": "VTI", "wieght": 100}]}`,
+			wantErr: `unknown field "wieght"`,
+		},
+		{
+			name:    "syntax error",
+			content: "{\"version\": 1,\n  \"name\": \"p\",\n  \"slices\": [{\"symbol\": \"VTI\", \"weight\": 100},]\n}",
+			wantErr: "line 3, column 47",
+		},
+		{
+			name:    "weight as a string",
+			content: "{\"version\": 1,\n  \"slices\": [{\"symbol\": \"VTI\", \"weight\": \"100\"}]}",
+			wantErr: "pieFile.slices.0.weight of type float64",
+		},
+		{
+			name:    "invalid glide path date",
+			content: `{"version": 1, "name": "p", "glide_path": [{"date": "01/01/2030", "weights": {"VTI": 100}}], "slices": [{"symbol": "VTI"}]}`,
+			wantErr: `pie p: invalid glide path date "01/01/2030", want YYYY-MM-DD`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writePieFile(t, "pie.json", tt.content)
+			_, err := pies.LoadPie(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("LoadPie() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), path) {
+				t.Errorf("LoadPie() error = %v, want it to name the file", err)
+			}
+		})
+	}
+}
+
+func TestLoadPieMissingFile(t *testing.T) {
+	_, err := pies.LoadPie(filepath.Join(t.TempDir(), "missing.json"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("LoadPie() error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestYAMLMatchesJSON(t *testing.T) {
+	jsonPie := `{
+  "version": 1,
+  "id": "core",
+  "name": "Core",
+  "description": "Three fund pie",
+  "band": {"absolute": 5},
+  "benchmarks": ["VT"],
+  "risk": {"max_order_value": {"limit": 10000}},
+  "slices": [
+    {"symbol": "VTI", "weight": 60, "band": {"relative": 0.25}, "name": "Total Stock Market"},
+    {"weight": 35, "pie": {"name": "Bonds", "slices": [
+      {"symbol": "BND", "weight": 70},
+      {"symbol": "BNDX", "weight": 30}
+    ]}},
+    {"asset_type": "CASH", "weight": 5}
+  ]
+}`
+	yamlPie := `# Hand-edited
+version: 1
+id: core
+name: Core
+description: Three fund pie
+band: {absolute: 5}
+benchmarks: [VT]
+risk:
+  max_order_value: {limit: 10000}
+slices:
+  - symbol: VTI
+    weight: 60 # Keep US equity above 50
+    band: {relative: 0.25}
+    name: Total Stock Market
+  - weight: 35
+    pie:
+      name: Bonds
+      slices:
+        - {symbol: BND, weight: 70}
+        - {symbol: BNDX, weight: 30}
+  - {asset_type: CASH, weight: 5}
+`
+
+	want, err := pies.LoadPie(writePieFile(t, "core.json", jsonPie))
+	if err != nil {
+		t.Fatalf("LoadPie() of JSON error = %v", err)
+	}
+	// Both the extension and, without one, the content select YAML
+	for _, name := range []string{"core.yaml", "core.yml", "core"} {
+		got, err := pies.LoadPie(writePieFile(t, name, yamlPie))
+		if err != nil {
+			t.Fatalf("LoadPie(%s) error = %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("LoadPie(%s) = %+v\nwant the JSON pie %+v", name, got, want)
+		}
+	}
+}
+
+func TestSaveYAMLRoundTrip(t *testing.T) {
+	pie := fullPie()
+	path := filepath.Join(t.TempDir(), "pie.yaml")
+	if err := pie.SaveYAML(path); err != nil {
+		t.Fatalf("SaveYAML() error = %v", err)
+	}
+	loaded, err := pies.LoadPie(path)
+	if err != nil {
+		t.Fatalf("LoadPie() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded, pie) {
+		t.Errorf("LoadPie() = %+v\nwant %+v", loaded, pie)
+	}
+}
+
+func TestYAMLAnchorsShareSlices(t *testing.T) {
+	// A family of pies shares its bond slices through anchors
+	shared := `shared:
+  bond: &bond {symbol: BND, weight: 30, band: {absolute: 2}}
+  bonds: &bonds
+    - {symbol: BND, weight: 70}
+    - {symbol: BNDX, weight: 30}
+  weights: &weights {VTI: 70, BND: 30}
+`
+	tests := []struct {
+		name    string
+		content string
+		want    pies.Pie
+	}{
+		{
+			name: "mapping alias as a slice",
+			content: "version: 1\nname: Aggressive\n" + shared + `slices:
+  - {symbol: VTI, weight: 70}
+  - *bond
+`,
+			want: pies.Pie{Name: "Aggressive", Slices: []pies.Slice{
+				{Weight: 70, Asset: pies.Asset{Symbol: "VTI"}},
+				{Weight: 30, Asset: pies.Asset{Symbol: "BND"}, Band: &pies.DriftBand{Absolute: 2}},
+			}},
+		},
+		{
+			name:    "sequence alias as the slice list",
+			content: "version: 1\nname: Bonds\n" + shared + "slices: *bonds\n",
+			want: pies.Pie{Name: "Bonds", Slices: []pies.Slice{
+				{Weight: 70, Asset: pies.Asset{Symbol: "BND"}},
+				{Weight: 30, Asset: pies.Asset{Symbol: "BNDX"}},
+			}},
+		},
+		{
+			name: "mapping alias as glide path weights",
+			content: "version: 1\nname: Glide\n" + shared + `glide_path:
+  - {date: 2030-01-01, weights: *weights}
+  - {date: 2040-01-01, weights: *weights}
+slices: [{symbol: VTI}, {symbol: BND}]
+`,
+			want: pies.Pie{
+				Name:   "Glide",
+				Slices: []pies.Slice{{Asset: pies.Asset{Symbol: "VTI"}}, {Asset: pies.Asset{Symbol: "BND"}}},
+				GlidePath: &pies.GlidePath{Anchors: []pies.GlideAnchor{
+					{Date: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), Weights: map[string]float64{"VTI": 70, "BND": 30}},
+					{Date: time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC), Weights: map[string]float64{"VTI": 70, "BND": 30}},
+				}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pie, err := pies.LoadPie(writePieFile(t, "pie.yaml", tt.content))
+			if err != nil {
+				t.Fatalf("LoadPie() error = %v", err)
+			}
+			if !reflect.DeepEqual(pie, tt.want) {
+				t.Errorf("LoadPie() = %+v\nwant %+v", pie, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadYAMLPieErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "weights not summing to 100",
+			content: `version: 1
+name: p
+slices:
+  - {symbol: VTI, weight: 60}
+  - {symbol: BND, weight: 30}
+`,
+			wantErr: "slice weights sum to 90, want 100",
+		},
+		{
+			name: "zero weight",
+			content: `version: 1
+name: p
+slices:
+  - {symbol: VTI, weight: 60}
+  - {symbol: VXUS, weight: 40}
+  - {symbol: BND, weight: 0}
+`,
+			wantErr: "slice 2: weight of BND must be > 0, got 0",
+		},
+		{
+			name: "invalid child pie",
+			content: `version: 1
+name: p
+slices:
+  - {symbol: VTI, weight: 60}
+  - weight: 40
+    pie:
+      name: Bonds
+      slices:
+        - {symbol: BND, weight: 50}
+        - {symbol: BND, weight: 50}
+`,
+			wantErr: "slice 1: pie Bonds: slice 1: BND is already in slice 0",
+		},
+		{
+			name: "misspelt key",
+			content: `version: 1
+name: p
+slices:
+  - {symbol: VTI, wieght: 100}
+`,
+			wantErr: "line 4: field wieght not found",
+		},
+		{
+			name:    "weight as a string",
+			content: "version: 1\nname: p\nslices:\n  - {symbol: VTI, weight: heavy}\n",
+			wantErr: "line 4: cannot unmarshal !!str `heavy` into float64",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := pies.LoadPie(writePieFile(t, "pie.yaml", tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadPie() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
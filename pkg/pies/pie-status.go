@@ -0,0 +1,317 @@
+package pies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PieStatus describes how an account's holdings compare to a pie. Weights
+// and drift are percentages of the pie's value, which is the market value
+// of the account's holdings in pie symbols; holdings outside the pie are
+// reported separately and do not count towards it. Cash only counts towards
+// the pie's value when the pie has a cash slice. Money market and sweep
+// funds outside the pie are cash kept in a fund rather than holdings, so
+// they are reported apart from Other.
+type PieStatus struct {
+	Pie                 string          `json:"pie"`
+	PieID               string          `json:"pie_id"`
+	Account             Account         `json:"account"`
+	Slices              []SliceStatus   `json:"slices"`                     // One per symbol of the flattened pie
+	Groups              []GroupStatus   `json:"groups"`                     // One per top-level slice, only for nested pies
+	PieValue            decimal.Decimal `json:"pie_value"`                  // Market value of the holdings in pie symbols
+	Cash                decimal.Decimal `json:"cash"`                       // Uninvested cash in the account
+	Other               []Position      `json:"other"`                      // Holdings in symbols that are not part of the pie
+	OtherValue          decimal.Decimal `json:"other_value"`                // Market value of Other
+	CashEquivalents     []Position      `json:"cash_equivalents,omitempty"` // Cash equivalent holdings that are not part of the pie
+	CashEquivalentValue decimal.Decimal `json:"cash_equivalent_value"`      // Market value of CashEquivalents
+	Unpriced            []string        `json:"unpriced"`                   // Pie symbols without a usable quote
+	Missing             []string        `json:"missing"`                    // Pie symbols the account holds no shares of
+
+	// Discrepancies lists the symbols whose shares in the account differ
+	// from the position ledger, only set by GetPieStatusAttributed
+	Discrepancies []Discrepancy `json:"discrepancies,omitempty"`
+
+	// Returns holds the pie's return followed by its benchmarks', only set
+	// by GetPieStatusWithReturns
+	Returns []PeriodReturn `json:"returns,omitempty"`
+}
+
+// SliceStatus compares one slice's holding with its target
+type SliceStatus struct {
+	Symbol        string          `json:"symbol"`
+	Quantity      decimal.Decimal `json:"quantity"`
+	Price         decimal.Decimal `json:"price"` // Zero when the symbol is unpriced and not held
+	Value         decimal.Decimal `json:"value"`
+	CurrentWeight float64         `json:"current_weight"`
+	TargetWeight  float64         `json:"target_weight"`
+	TargetValue   decimal.Decimal `json:"target_value"`
+	Drift         float64         `json:"drift"`       // CurrentWeight - TargetWeight, in percentage points
+	DriftValue    decimal.Decimal `json:"drift_value"` // Value - TargetValue, in dollars
+	Band          DriftBand       `json:"band"`
+	IsCash        bool            `json:"is_cash"`              // The slice targets uninvested cash, see AssetTypeCash
+	AssetType     string          `json:"asset_type,omitempty"` // The position's, or the slice's when not held
+}
+
+// TotalValue returns the value of the whole account: pie holdings, other
+// holdings, cash equivalents and cash
+func (s PieStatus) TotalValue() decimal.Decimal {
+	return s.PieValue.Add(s.OtherValue).Add(s.CashEquivalentValue).Add(s.Cash)
+}
+
+// Slice returns the status of the slice for symbol
+func (s PieStatus) Slice(symbol string) (SliceStatus, bool) {
+	for _, slice := range s.Slices {
+		if strings.EqualFold(slice.Symbol, symbol) {
+			return slice, true
+		}
+	}
+	return SliceStatus{}, false
+}
+
+// GetPieStatus compares the investor's account with pie. Symbols the
+// brokerage cannot quote are valued at the position's reported market value
+// and listed in Unpriced. Nested pies are compared in their flattened form,
+// with the drift of each top-level slice reported in Groups, and pies with a
+// glide path at today's weights.
+func (i *Investor) GetPieStatus(ctx context.Context, pie Pie) (*PieStatus, error) {
+	status, _, err := i.getPieStatus(ctx, pie, time.Now())
+	return status, err
+}
+
+// getPieStatus compares the investor's account with the pie, flattened and
+// with its glide path at now, and returns the holdings it was computed from
+func (i *Investor) getPieStatus(ctx context.Context, pie Pie, now time.Time) (*PieStatus, *holdings, error) {
+	if err := pie.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid pie %s: %w", pie.Name, err)
+	}
+	pie = pie.EffectiveAt(now)
+
+	flat, err := pie.Flatten()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid pie %s: %w", pie.Name, err)
+	}
+
+	holdings, err := i.getHoldings(ctx, flat)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	status := computePieStatus(flat, holdings)
+	if pie.IsNested() {
+		status.Groups = computeGroups(pie, status)
+	}
+	return status, holdings, nil
+}
+
+// holdings is a snapshot of an account taken to compare it with a pie
+type holdings struct {
+	account   Account
+	positions map[string]Position // Keyed by upper-case symbol
+	quotes    map[string]Quote    // Keyed by upper-case symbol
+
+	extendedHours bool // Value at extended-hours prices, see Investor.ExtendedHours
+}
+
+// getHoldings fetches the investor's account, its positions and quotes for
+// every pie symbol
+func (i *Investor) getHoldings(ctx context.Context, pie Pie) (*holdings, error) {
+	if i.BrokerageClient == nil {
+		return nil, errors.New("investor has no brokerage client")
+	}
+
+	account, err := i.getAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	positions, err := i.BrokerageClient.GetPositions(ctx, account.ID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %w", err)
+	}
+
+	symbols := make([]string, 0, len(pie.Slices))
+	for _, slice := range pie.Slices {
+		if !slice.IsCash() {
+			symbols = append(symbols, normalizeSymbol(slice.Asset.Symbol))
+		}
+	}
+
+	fetcher := QuoteFetcher{Client: i.BrokerageClient, Concurrency: i.QuoteConcurrency}
+	quotes, err := fetcher.Fetch(ctx, symbols)
+	var missingErr *MissingQuotesError
+	if err != nil && !errors.As(err, &missingErr) {
+		return nil, fmt.Errorf("failed to get quotes: %w", err)
+	}
+
+	h := &holdings{
+		account:   account,
+		positions: make(map[string]Position, len(positions)),
+		quotes:    make(map[string]Quote, len(quotes)),
+
+		extendedHours: i.ExtendedHours,
+	}
+	for _, position := range positions {
+		symbol := normalizeSymbol(position.Symbol)
+		if existing, ok := h.positions[symbol]; ok {
+			position.Quantity = position.Quantity.Add(existing.Quantity)
+			position.MarketValue = position.MarketValue.Add(existing.MarketValue)
+		}
+		h.positions[symbol] = position
+	}
+	for symbol, quote := range quotes {
+		h.quotes[normalizeSymbol(symbol)] = quote
+	}
+
+	return h, nil
+}
+
+// getAccount returns a fresh copy of the investor's account. An investor
+// without an account uses the brokerage's only account.
+func (i *Investor) getAccount(ctx context.Context) (Account, error) {
+	accounts, err := i.BrokerageClient.GetAccounts(ctx)
+	if err != nil {
+		return Account{}, fmt.Errorf("failed to get accounts: %w", err)
+	}
+
+	id := i.Account.ID()
+	if id == "" {
+		if len(accounts) != 1 {
+			return Account{}, fmt.Errorf("investor has no account and the brokerage has %d", len(accounts))
+		}
+		return accounts[0], nil
+	}
+
+	for _, account := range accounts {
+		if account.ID() == id || (i.Account.AccountNumber != "" && account.AccountNumber == i.Account.AccountNumber) {
+			return account, nil
+		}
+	}
+	return Account{}, fmt.Errorf("account %s not found", id)
+}
+
+// computePieStatus values every slice of pie and measures its drift
+func computePieStatus(pie Pie, h *holdings) *PieStatus {
+	status := &PieStatus{
+		Pie:     pie.Name,
+		PieID:   pie.ID,
+		Account: h.account,
+		Cash:    h.account.CashBalance,
+		Slices:  make([]SliceStatus, 0, len(pie.Slices)),
+	}
+
+	inPie := make(map[string]bool, len(pie.Slices))
+	for _, slice := range pie.Slices {
+		if slice.IsCash() {
+			status.PieValue = status.PieValue.Add(decimal.Max(status.Cash, decimal.Zero))
+			status.Slices = append(status.Slices, cashSliceStatus(pie, slice, status.Cash))
+			continue
+		}
+
+		symbol := normalizeSymbol(slice.Asset.Symbol)
+		inPie[symbol] = true
+
+		position, held := h.positions[symbol]
+		sliceStatus := SliceStatus{
+			Symbol:       symbol,
+			Quantity:     position.Quantity,
+			TargetWeight: slice.Weight,
+			Band:         pie.bandFor(slice),
+			AssetType:    position.AssetType,
+		}
+		if sliceStatus.AssetType == "" {
+			sliceStatus.AssetType = slice.Asset.TypeName
+		}
+
+		price := h.quotes[symbol].Price(h.extendedHours)
+		if !price.IsPositive() {
+			price = position.CurrentPrice
+			status.Unpriced = append(status.Unpriced, symbol)
+		}
+		sliceStatus.Price = price
+		if price.IsPositive() {
+			sliceStatus.Value = position.Quantity.Mul(price)
+		} else {
+			sliceStatus.Value = position.MarketValue
+		}
+
+		if !held || position.Quantity.IsZero() {
+			status.Missing = append(status.Missing, symbol)
+		}
+
+		status.PieValue = status.PieValue.Add(sliceStatus.Value)
+		status.Slices = append(status.Slices, sliceStatus)
+	}
+
+	for symbol, position := range h.positions {
+		if !inPie[symbol] {
+			status.addOutside(position)
+		}
+	}
+	status.sortOutside()
+
+	// With nothing invested every slice sits at 0%, so the drift is the whole
+	// target weight but there are no dollars to be off by
+	for idx := range status.Slices {
+		slice := &status.Slices[idx]
+		slice.TargetValue = weightOf(status.PieValue, slice.TargetWeight)
+		if status.PieValue.IsPositive() {
+			slice.CurrentWeight = percentOf(slice.Value, status.PieValue)
+		}
+		slice.Drift = slice.CurrentWeight - slice.TargetWeight
+		slice.DriftValue = slice.Value.Sub(slice.TargetValue)
+	}
+
+	return status
+}
+
+// addOutside records a position held outside the pie in Other, or in
+// CashEquivalents when it is a cash equivalent
+func (s *PieStatus) addOutside(position Position) {
+	if position.IsCashEquivalent() {
+		s.CashEquivalents = append(s.CashEquivalents, position)
+		s.CashEquivalentValue = s.CashEquivalentValue.Add(position.MarketValue)
+		return
+	}
+	s.Other = append(s.Other, position)
+	s.OtherValue = s.OtherValue.Add(position.MarketValue)
+}
+
+// sortOutside puts Other and CashEquivalents in symbol order
+func (s *PieStatus) sortOutside() {
+	for _, positions := range [][]Position{s.Other, s.CashEquivalents} {
+		sort.Slice(positions, func(a, b int) bool {
+			return positions[a].Symbol < positions[b].Symbol
+		})
+	}
+}
+
+// cashSliceStatus values a cash slice at the account's cash, one dollar per
+// unit
+func cashSliceStatus(pie Pie, slice Slice, cash decimal.Decimal) SliceStatus {
+	symbol := normalizeSymbol(slice.Asset.Symbol)
+	if symbol == "" {
+		symbol = cashLabel
+	}
+	cash = decimal.Max(cash, decimal.Zero)
+	return SliceStatus{
+		Symbol:       symbol,
+		Quantity:     cash,
+		Price:        decimal.NewFromInt(1),
+		Value:        cash,
+		TargetWeight: slice.Weight,
+		Band:         pie.bandFor(slice),
+		IsCash:       true,
+	}
+}
+
+// normalizeSymbol puts symbols in the form used to match positions, quotes
+// and slices
+func normalizeSymbol(symbol string) string {
+	return strings.ToUpper(strings.TrimSpace(symbol))
+}
@@ -0,0 +1,744 @@
+// Package piestest provides a scriptable fake pies.BrokerageClient for
+// testing code built on the pies package without a brokerage. The fake is
+// deterministic and starts no goroutines.
+package piestest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ErrOrderNotFound is returned for order IDs the fake never issued
+	ErrOrderNotFound = errors.New("order not found")
+
+	// ErrAccountNotFound is returned for account IDs the fake does not hold
+	ErrAccountNotFound = errors.New("account not found")
+)
+
+// Epoch is the time the fake's clock starts at
+var Epoch = time.Date(2025, time.January, 2, 15, 0, 0, 0, time.UTC)
+
+// Call is a BrokerageClient method call received by the fake
+type Call struct {
+	Method    string             // The method name, e.g. "PlaceOrder"
+	AccountID string             // Empty for calls not about an account
+	OrderID   string             // Set for calls about an existing order
+	Symbols   []string           // Set for GetQuote and GetQuotes
+	Order     *pies.OrderRequest // Set for PlaceOrder and ReplaceOrder
+}
+
+// OrderBehavior scripts what happens to the orders placed with the fake
+type OrderBehavior struct {
+	// FillAfterPolls is how many GetOrderStatus calls report an order
+	// working before it fills. Zero fills orders as they are placed, and a
+	// negative value leaves them working until they are cancelled.
+	FillAfterPolls int
+
+	// Reject, when set, makes PlaceOrder record the order as rejected and
+	// return it together with this error
+	Reject error
+}
+
+// FillImmediately fills orders as they are placed
+func FillImmediately() OrderBehavior {
+	return OrderBehavior{}
+}
+
+// FillAfterPolls fills orders on the first GetOrderStatus call after polls
+// calls have reported them working
+func FillAfterPolls(polls int) OrderBehavior {
+	return OrderBehavior{FillAfterPolls: polls}
+}
+
+// NeverFill leaves orders working until they are cancelled
+func NeverFill() OrderBehavior {
+	return OrderBehavior{FillAfterPolls: -1}
+}
+
+// Reject rejects orders with err
+func Reject(err error) OrderBehavior {
+	return OrderBehavior{Reject: err}
+}
+
+// Brokerage is a fake pies.BrokerageClient holding its accounts, positions
+// and quotes in memory. Market and stop orders fill at the quote's last
+// price, and limit orders at their limit price once the last price reaches
+// it. Fills move cash and shares between the account's balance and
+// positions without checking that the account can afford them; script
+// rejections with Reject instead. Orders are stamped with the fake's clock,
+// which starts at Epoch and advances a second for each order placed.
+type Brokerage struct {
+	mu             sync.Mutex
+	calls          []Call
+	unauthorized   bool
+	fractional     bool
+	accounts       []*account
+	quotes         map[string]pies.Quote
+	behavior       OrderBehavior
+	symbolBehavior map[string]OrderBehavior
+	errs           map[string]error
+	orders         []*order
+	now            time.Time
+}
+
+type account struct {
+	info      pies.Account
+	positions []pies.Position
+}
+
+type order struct {
+	accountID string
+	order     pies.Order
+	behavior  OrderBehavior
+	polls     int
+}
+
+// New returns an authenticated fake with no accounts or quotes that fills
+// orders as they are placed
+func New() *Brokerage {
+	return &Brokerage{
+		quotes:         make(map[string]pies.Quote),
+		symbolBehavior: make(map[string]OrderBehavior),
+		errs:           make(map[string]error),
+		now:            Epoch,
+	}
+}
+
+// AddAccount adds an account holding positions. The account is looked up
+// by account.ID(), and its market and total values are computed from the
+// positions.
+func (b *Brokerage) AddAccount(info pies.Account, positions ...pies.Position) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.accounts = append(b.accounts, &account{info: info, positions: slices.Clone(positions)})
+}
+
+// SetCash sets the cash balance, settled cash and buying power of an account
+func (b *Brokerage) SetCash(accountID string, cash decimal.Decimal) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	a, err := b.accountLocked(accountID)
+	if err != nil {
+		return err
+	}
+	a.info.CashBalance = cash
+	a.info.SettledCash = cash
+	a.info.BuyingPower = cash
+	return nil
+}
+
+// SetPositions replaces the positions of an account
+func (b *Brokerage) SetPositions(accountID string, positions ...pies.Position) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	a, err := b.accountLocked(accountID)
+	if err != nil {
+		return err
+	}
+	a.positions = slices.Clone(positions)
+	return nil
+}
+
+// SetPrice quotes symbol with price as its bid, ask, last and close
+func (b *Brokerage) SetPrice(symbol string, price decimal.Decimal) {
+	b.SetQuote(pies.Quote{
+		Symbol:    symbol,
+		Bid:       price,
+		Ask:       price,
+		Last:      price,
+		Close:     price,
+		Timestamp: Epoch,
+	})
+}
+
+// SetQuote sets the quote returned for quote.Symbol
+func (b *Brokerage) SetQuote(quote pies.Quote) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.quotes[strings.ToUpper(quote.Symbol)] = quote
+}
+
+// RemoveQuote stops quoting symbol, so that it is reported missing
+func (b *Brokerage) RemoveQuote(symbol string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.quotes, strings.ToUpper(symbol))
+}
+
+// SetAuthenticated sets whether the fake is signed in. While it is not,
+// every method fails with pies.ErrNotAuthenticated.
+func (b *Brokerage) SetAuthenticated(authenticated bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.unauthorized = !authenticated
+}
+
+// SetFractional sets whether the fake trades fractional shares
+func (b *Brokerage) SetFractional(fractional bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.fractional = fractional
+}
+
+// SetOrderBehavior sets what happens to the orders placed from now on
+func (b *Brokerage) SetOrderBehavior(behavior OrderBehavior) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.behavior = behavior
+}
+
+// SetSymbolOrderBehavior overrides SetOrderBehavior for orders in symbol
+func (b *Brokerage) SetSymbolOrderBehavior(symbol string, behavior OrderBehavior) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.symbolBehavior[strings.ToUpper(symbol)] = behavior
+}
+
+// SetError makes every call to method, e.g. "GetPositions", fail with err.
+// A nil err clears it.
+func (b *Brokerage) SetError(method string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		delete(b.errs, method)
+		return
+	}
+	b.errs[method] = err
+}
+
+// SetClock sets the time stamped on the next order placed
+func (b *Brokerage) SetClock(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.now = now
+}
+
+// Calls returns every call received so far, oldest first
+func (b *Brokerage) Calls() []Call {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return slices.Clone(b.calls)
+}
+
+// CallsTo returns the calls received to method, oldest first
+func (b *Brokerage) CallsTo(method string) []Call {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var calls []Call
+	for _, call := range b.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// PlacedOrders returns the requests passed to PlaceOrder, oldest first
+func (b *Brokerage) PlacedOrders() []pies.OrderRequest {
+	var orders []pies.OrderRequest
+	for _, call := range b.CallsTo("PlaceOrder") {
+		orders = append(orders, *call.Order)
+	}
+	return orders
+}
+
+// AssertCalled fails t unless method was called times times
+func (b *Brokerage) AssertCalled(t testing.TB, method string, times int) {
+	t.Helper()
+
+	if got := len(b.CallsTo(method)); got != times {
+		t.Errorf("%s called %d times, want %d", method, got, times)
+	}
+}
+
+// AssertPlaced fails t unless PlaceOrder was called with exactly the want
+// orders, in order. Orders are compared by symbol, action, type, quantity,
+// amount and limit and stop prices.
+func (b *Brokerage) AssertPlaced(t testing.TB, want ...pies.OrderRequest) {
+	t.Helper()
+
+	got := b.PlacedOrders()
+	if len(got) != len(want) {
+		t.Errorf("placed %d orders, want %d:\n got: %s\nwant: %s", len(got), len(want), formatOrders(got), formatOrders(want))
+		return
+	}
+	for i := range got {
+		if !sameOrder(got[i], want[i]) {
+			t.Errorf("order %d is %s, want %s", i, formatOrder(got[i]), formatOrder(want[i]))
+		}
+	}
+}
+
+// IsAuthenticated reports whether the fake is signed in, see SetAuthenticated
+func (b *Brokerage) IsAuthenticated() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return !b.unauthorized
+}
+
+// SupportsFractionalShares reports the SetFractional setting, implementing
+// pies.FractionalTrader
+func (b *Brokerage) SupportsFractionalShares() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.fractional
+}
+
+// GetAccounts returns the accounts added with AddAccount
+func (b *Brokerage) GetAccounts(ctx context.Context) ([]pies.Account, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.callLocked(Call{Method: "GetAccounts"}); err != nil {
+		return nil, err
+	}
+
+	accounts := make([]pies.Account, 0, len(b.accounts))
+	for _, a := range b.accounts {
+		info := a.info
+		info.MarketValue = decimal.Zero
+		for _, position := range b.valuePositionsLocked(a.positions) {
+			info.MarketValue = info.MarketValue.Add(position.MarketValue)
+		}
+		info.TotalValue = info.CashBalance.Add(info.MarketValue)
+		accounts = append(accounts, info)
+	}
+	return accounts, nil
+}
+
+// GetPositions returns an account's positions valued at the current quotes.
+// An account without positions returns an empty, non-nil slice.
+func (b *Brokerage) GetPositions(ctx context.Context, accountID string) ([]pies.Position, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.callLocked(Call{Method: "GetPositions", AccountID: accountID}); err != nil {
+		return nil, err
+	}
+
+	a, err := b.accountLocked(accountID)
+	if err != nil {
+		return nil, err
+	}
+	return b.valuePositionsLocked(a.positions), nil
+}
+
+// PlaceOrder places an order and applies the scripted OrderBehavior. Orders
+// sized by Amount are converted to a quantity at the current quote.
+func (b *Brokerage) PlaceOrder(ctx context.Context, accountID string, request pies.OrderRequest) (*pies.Order, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.callLocked(Call{Method: "PlaceOrder", AccountID: accountID, Order: &request}); err != nil {
+		return nil, err
+	}
+	if _, err := b.accountLocked(accountID); err != nil {
+		return nil, err
+	}
+
+	placed, err := b.placeOrderLocked(ctx, accountID, request)
+	if placed == nil {
+		return nil, err
+	}
+	result := placed.order
+	return &result, err
+}
+
+// ReplaceOrder marks a working order as replaced and places newOrder in its
+// place
+func (b *Brokerage) ReplaceOrder(ctx context.Context, accountID string, orderID string, newOrder pies.OrderRequest) (*pies.Order, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.callLocked(Call{Method: "ReplaceOrder", AccountID: accountID, OrderID: orderID, Order: &newOrder}); err != nil {
+		return nil, err
+	}
+
+	old, err := b.orderLocked(accountID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if !old.order.Status.IsOpen() {
+		return nil, fmt.Errorf("cannot replace order %s with status %s: %w", orderID, old.order.Status, pies.ErrOrderNotOpen)
+	}
+
+	placed, err := b.placeOrderLocked(ctx, accountID, newOrder)
+	if placed == nil {
+		return nil, err
+	}
+	old.order.Status = pies.OrderStatusReplaced
+	result := placed.order
+	return &result, err
+}
+
+// GetOrderStatus returns an order, filling it once it has been polled as
+// many times as its OrderBehavior asks for
+func (b *Brokerage) GetOrderStatus(ctx context.Context, accountID string, orderID string) (*pies.Order, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.callLocked(Call{Method: "GetOrderStatus", AccountID: accountID, OrderID: orderID}); err != nil {
+		return nil, err
+	}
+
+	o, err := b.orderLocked(accountID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if o.order.Status.IsOpen() && o.behavior.FillAfterPolls >= 0 {
+		if o.polls >= o.behavior.FillAfterPolls {
+			if err := b.fillLocked(o); err != nil {
+				return nil, err
+			}
+		}
+		o.polls++
+	}
+
+	result := o.order
+	return &result, nil
+}
+
+// CancelPendingOrder cancels an open order. Orders that have already
+// closed are not changed and fail with pies.ErrOrderNotOpen.
+func (b *Brokerage) CancelPendingOrder(ctx context.Context, accountID string, orderID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.callLocked(Call{Method: "CancelPendingOrder", AccountID: accountID, OrderID: orderID}); err != nil {
+		return err
+	}
+
+	o, err := b.orderLocked(accountID, orderID)
+	if err != nil {
+		return err
+	}
+	if !o.order.Status.IsOpen() {
+		return fmt.Errorf("cannot cancel order %s with status %s: %w", orderID, o.order.Status, pies.ErrOrderNotOpen)
+	}
+	o.order.Status = pies.OrderStatusCancelled
+	return nil
+}
+
+// GetRecentOrders returns an account's orders matching query, newest first
+func (b *Brokerage) GetRecentOrders(ctx context.Context, accountID string, query pies.OrdersQuery) ([]pies.Order, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.callLocked(Call{Method: "GetRecentOrders", AccountID: accountID}); err != nil {
+		return nil, err
+	}
+	if _, err := b.accountLocked(accountID); err != nil {
+		return nil, err
+	}
+
+	orders := []pies.Order{}
+	for _, o := range slices.Backward(b.orders) {
+		switch {
+		case o.accountID != accountID:
+			continue
+		case query.Status != "" && o.order.Status != query.Status:
+			continue
+		case !query.From.IsZero() && o.order.SubmittedAt.Before(query.From):
+			continue
+		case !query.To.IsZero() && o.order.SubmittedAt.After(query.To):
+			continue
+		}
+		orders = append(orders, o.order)
+		if query.MaxResults > 0 && len(orders) == query.MaxResults {
+			break
+		}
+	}
+	return orders, nil
+}
+
+// GetQuote returns the quote set for symbol
+func (b *Brokerage) GetQuote(ctx context.Context, symbol string) (*pies.Quote, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.callLocked(Call{Method: "GetQuote", Symbols: []string{symbol}}); err != nil {
+		return nil, err
+	}
+	return b.quoteLocked(symbol)
+}
+
+// GetQuotes returns the quotes set for symbols. Symbols without one are
+// reported through a *pies.MissingQuotesError together with the others.
+func (b *Brokerage) GetQuotes(ctx context.Context, symbols []string) (map[string]pies.Quote, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.callLocked(Call{Method: "GetQuotes", Symbols: slices.Clone(symbols)}); err != nil {
+		return nil, err
+	}
+
+	quotes := make(map[string]pies.Quote, len(symbols))
+	var missing []string
+	for _, symbol := range symbols {
+		quote, ok := b.quotes[strings.ToUpper(symbol)]
+		if !ok {
+			missing = append(missing, symbol)
+			continue
+		}
+		quote.Symbol = symbol
+		quotes[symbol] = quote
+	}
+	if len(missing) > 0 {
+		return quotes, &pies.MissingQuotesError{Symbols: missing}
+	}
+	return quotes, nil
+}
+
+// callLocked records call and returns the error it should fail with, if any
+func (b *Brokerage) callLocked(call Call) error {
+	b.calls = append(b.calls, call)
+
+	if b.unauthorized {
+		return pies.ErrNotAuthenticated
+	}
+	return b.errs[call.Method]
+}
+
+func (b *Brokerage) accountLocked(accountID string) (*account, error) {
+	for _, a := range b.accounts {
+		if a.info.ID() == accountID {
+			return a, nil
+		}
+	}
+	return nil, fmt.Errorf("account %s: %w", accountID, ErrAccountNotFound)
+}
+
+func (b *Brokerage) orderLocked(accountID, orderID string) (*order, error) {
+	for _, o := range b.orders {
+		if o.order.ID == orderID && o.accountID == accountID {
+			return o, nil
+		}
+	}
+	return nil, fmt.Errorf("order %s: %w", orderID, ErrOrderNotFound)
+}
+
+func (b *Brokerage) quoteLocked(symbol string) (*pies.Quote, error) {
+	quote, ok := b.quotes[strings.ToUpper(symbol)]
+	if !ok {
+		return nil, &pies.MissingQuotesError{Symbols: []string{symbol}}
+	}
+	quote.Symbol = symbol
+	return &quote, nil
+}
+
+// placeOrderLocked records a new order and applies its behavior. A
+// rejected order is returned together with its error.
+func (b *Brokerage) placeOrderLocked(ctx context.Context, accountID string, request pies.OrderRequest) (*order, error) {
+	request, err := pies.ResolveAmount(ctx, lockedQuotes{b}, request, b.fractional)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case request.Type == pies.OrderTypeLimit && (request.LimitPrice == nil || !request.LimitPrice.IsPositive()):
+		return nil, fmt.Errorf("limit order for %s needs a positive limit price", request.Symbol)
+	case request.Type == pies.OrderTypeStop && (request.StopPrice == nil || !request.StopPrice.IsPositive()):
+		return nil, fmt.Errorf("stop order for %s needs a positive stop price", request.Symbol)
+	}
+
+	behavior, ok := b.symbolBehavior[strings.ToUpper(request.Symbol)]
+	if !ok {
+		behavior = b.behavior
+	}
+
+	o := &order{
+		accountID: accountID,
+		behavior:  behavior,
+		order: pies.Order{
+			ID:          strconv.Itoa(len(b.orders) + 1),
+			Symbol:      request.Symbol,
+			Action:      request.Action,
+			Type:        request.Type,
+			Quantity:    request.Quantity,
+			LimitPrice:  request.LimitPrice,
+			StopPrice:   request.StopPrice,
+			Status:      pies.OrderStatusWorking,
+			SubmittedAt: b.now,
+		},
+	}
+	b.orders = append(b.orders, o)
+	b.now = b.now.Add(time.Second)
+
+	switch {
+	case behavior.Reject != nil:
+		o.order.Status = pies.OrderStatusRejected
+		return o, behavior.Reject
+	case behavior.FillAfterPolls == 0:
+		if err := b.fillLocked(o); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// fillLocked fills an order completely and moves its value between the
+// account's cash and positions. Limit orders the last price has not reached
+// are left working.
+func (b *Brokerage) fillLocked(o *order) error {
+	quote, err := b.quoteLocked(o.order.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to price fill of order %s: %w", o.order.ID, err)
+	}
+	price := quote.Last
+	if limit := o.order.LimitPrice; limit != nil {
+		buy := o.order.Action == pies.OrderActionBuy
+		if (buy && price.GreaterThan(*limit)) || (!buy && price.LessThan(*limit)) {
+			return nil
+		}
+		price = *limit
+	}
+
+	a, err := b.accountLocked(o.accountID)
+	if err != nil {
+		return err
+	}
+
+	quantity := o.order.Quantity
+	value := quantity.Mul(price)
+	if o.order.Action == pies.OrderActionSell {
+		quantity = quantity.Neg()
+		value = value.Neg()
+	}
+	a.info.CashBalance = a.info.CashBalance.Sub(value)
+	a.info.SettledCash = a.info.SettledCash.Sub(value)
+	a.info.BuyingPower = a.info.BuyingPower.Sub(value)
+
+	idx := slices.IndexFunc(a.positions, func(p pies.Position) bool {
+		return strings.EqualFold(p.Symbol, o.order.Symbol)
+	})
+	if idx < 0 {
+		a.positions = append(a.positions, pies.Position{Symbol: o.order.Symbol, AveragePrice: price})
+		idx = len(a.positions) - 1
+	}
+	position := &a.positions[idx]
+	held := position.Quantity.Add(quantity)
+	switch {
+	case !held.IsPositive():
+		a.positions = slices.Delete(a.positions, idx, idx+1)
+	case quantity.IsPositive():
+		cost := position.AveragePrice.Mul(position.Quantity).Add(value)
+		position.AveragePrice = cost.Div(held)
+		position.Quantity = held
+	default:
+		position.Quantity = held
+	}
+
+	filledAt := b.now
+	o.order.Status = pies.OrderStatusFilled
+	o.order.FilledQty = o.order.Quantity
+	o.order.FilledPrice = price
+	o.order.FilledAt = &filledAt
+	return nil
+}
+
+// valuePositionsLocked prices positions at the current quotes, falling back
+// to their average price for symbols without one
+func (b *Brokerage) valuePositionsLocked(positions []pies.Position) []pies.Position {
+	valued := make([]pies.Position, 0, len(positions))
+	for _, position := range positions {
+		price := position.AveragePrice
+		position.DayPL = decimal.Zero
+		if quote, ok := b.quotes[strings.ToUpper(position.Symbol)]; ok {
+			price = quote.Last
+			position.DayPL = quote.DayChange(position.Quantity)
+			if position.Description == "" {
+				position.Description = quote.Description
+			}
+		}
+		if position.AssetType == "" {
+			position.AssetType = pies.AssetTypeEquity
+		}
+
+		cost := position.AveragePrice.Mul(position.Quantity)
+		position.LongQuantity, position.ShortQuantity = pies.LongShort(position.Quantity)
+		position.CurrentPrice = price
+		position.MarketValue = price.Mul(position.Quantity)
+		position.UnrealizedPL = position.MarketValue.Sub(cost)
+		position.UnrealizedPLPct = 0
+		if cost.IsPositive() {
+			position.UnrealizedPLPct = position.UnrealizedPL.Div(cost).Mul(decimal.NewFromInt(100)).InexactFloat64()
+		}
+		valued = append(valued, position)
+	}
+	return valued
+}
+
+// lockedQuotes reads the fake's quotes while its lock is already held,
+// without recording a call
+type lockedQuotes struct {
+	b *Brokerage
+}
+
+func (q lockedQuotes) GetQuote(ctx context.Context, symbol string) (*pies.Quote, error) {
+	return q.b.quoteLocked(symbol)
+}
+
+func sameOrder(a, b pies.OrderRequest) bool {
+	return strings.EqualFold(a.Symbol, b.Symbol) &&
+		a.Action == b.Action &&
+		a.Type == b.Type &&
+		a.Quantity.Equal(b.Quantity) &&
+		sameDecimal(a.Amount, b.Amount) &&
+		sameDecimal(a.LimitPrice, b.LimitPrice) &&
+		sameDecimal(a.StopPrice, b.StopPrice)
+}
+
+func sameDecimal(a, b *decimal.Decimal) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func formatOrder(o pies.OrderRequest) string {
+	size := o.Quantity.String()
+	if o.Amount != nil {
+		size = "$" + o.Amount.String()
+	}
+	s := fmt.Sprintf("%s %s %s %s", o.Action, size, o.Symbol, o.Type)
+	if o.LimitPrice != nil {
+		s += " limit " + o.LimitPrice.String()
+	}
+	if o.StopPrice != nil {
+		s += " stop " + o.StopPrice.String()
+	}
+	return s
+}
+
+func formatOrders(orders []pies.OrderRequest) string {
+	formatted := make([]string, len(orders))
+	for i, o := range orders {
+		formatted[i] = formatOrder(o)
+	}
+	return "[" + strings.Join(formatted, ", ") + "]"
+}
@@ -0,0 +1,239 @@
+package piestest_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/alysoliman1/money-pies/pkg/pies/piestest"
+	"github.com/shopspring/decimal"
+)
+
+func buy(symbol string, quantity int64) pies.OrderRequest {
+	return pies.OrderRequest{
+		Symbol:   symbol,
+		Action:   pies.OrderActionBuy,
+		Type:     pies.OrderTypeMarket,
+		Quantity: decimal.NewFromInt(quantity),
+	}
+}
+
+func TestFillImmediatelyMovesCashIntoPositions(t *testing.T) {
+	b := piestest.EmptyAccount(decimal.NewFromInt(1000))
+	b.SetPrice("VTI", decimal.NewFromInt(100))
+
+	order, err := b.PlaceOrder(t.Context(), piestest.AccountID, buy("VTI", 3))
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	if order.ID != "1" || order.Status != pies.OrderStatusFilled {
+		t.Errorf("PlaceOrder() = order %q %s, want filled order \"1\"", order.ID, order.Status)
+	}
+	if !order.SubmittedAt.Equal(piestest.Epoch) {
+		t.Errorf("SubmittedAt = %s, want %s", order.SubmittedAt, piestest.Epoch)
+	}
+
+	accounts, err := b.GetAccounts(t.Context())
+	if err != nil {
+		t.Fatalf("GetAccounts() error = %v", err)
+	}
+	if got := accounts[0].CashBalance; !got.Equal(decimal.NewFromInt(700)) {
+		t.Errorf("CashBalance = %s, want 700", got)
+	}
+	if got := accounts[0].TotalValue; !got.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("TotalValue = %s, want 1000", got)
+	}
+
+	positions, err := b.GetPositions(t.Context(), piestest.AccountID)
+	if err != nil {
+		t.Fatalf("GetPositions() error = %v", err)
+	}
+	if len(positions) != 1 || positions[0].Symbol != "VTI" || !positions[0].Quantity.Equal(decimal.NewFromInt(3)) {
+		t.Errorf("GetPositions() = %+v, want 3 VTI", positions)
+	}
+}
+
+func TestFillAfterPolls(t *testing.T) {
+	b := piestest.EmptyAccount(decimal.NewFromInt(1000))
+	b.SetPrice("VTI", decimal.NewFromInt(100))
+	b.SetOrderBehavior(piestest.FillAfterPolls(2))
+
+	order, err := b.PlaceOrder(t.Context(), piestest.AccountID, buy("VTI", 1))
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	if order.Status != pies.OrderStatusWorking {
+		t.Fatalf("PlaceOrder() status = %s, want %s", order.Status, pies.OrderStatusWorking)
+	}
+
+	for poll, want := range []pies.OrderStatus{pies.OrderStatusWorking, pies.OrderStatusWorking, pies.OrderStatusFilled} {
+		got, err := b.GetOrderStatus(t.Context(), piestest.AccountID, order.ID)
+		if err != nil {
+			t.Fatalf("GetOrderStatus() error = %v", err)
+		}
+		if got.Status != want {
+			t.Errorf("poll %d: status = %s, want %s", poll+1, got.Status, want)
+		}
+	}
+}
+
+func TestNeverFillLeavesOrdersWorkingUntilCancelled(t *testing.T) {
+	b := piestest.EmptyAccount(decimal.NewFromInt(1000))
+	b.SetPrice("VTI", decimal.NewFromInt(100))
+	b.SetOrderBehavior(piestest.NeverFill())
+
+	order, err := b.PlaceOrder(t.Context(), piestest.AccountID, buy("VTI", 1))
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	for range 5 {
+		got, err := b.GetOrderStatus(t.Context(), piestest.AccountID, order.ID)
+		if err != nil {
+			t.Fatalf("GetOrderStatus() error = %v", err)
+		}
+		if got.Status != pies.OrderStatusWorking {
+			t.Fatalf("status = %s, want %s", got.Status, pies.OrderStatusWorking)
+		}
+	}
+
+	if err := b.CancelPendingOrder(t.Context(), piestest.AccountID, order.ID); err != nil {
+		t.Fatalf("CancelPendingOrder() error = %v", err)
+	}
+	err = b.CancelPendingOrder(t.Context(), piestest.AccountID, order.ID)
+	if !errors.Is(err, pies.ErrOrderNotOpen) {
+		t.Errorf("second CancelPendingOrder() error = %v, want pies.ErrOrderNotOpen", err)
+	}
+}
+
+func TestRejectReturnsTheRejectedOrder(t *testing.T) {
+	errRejected := errors.New("insufficient buying power")
+
+	b := piestest.EmptyAccount(decimal.NewFromInt(1000))
+	b.SetPrice("VTI", decimal.NewFromInt(100))
+	b.SetPrice("BND", decimal.NewFromInt(70))
+	b.SetSymbolOrderBehavior("bnd", piestest.Reject(errRejected))
+
+	order, err := b.PlaceOrder(t.Context(), piestest.AccountID, buy("BND", 1))
+	if !errors.Is(err, errRejected) {
+		t.Fatalf("PlaceOrder(BND) error = %v, want %v", err, errRejected)
+	}
+	if order == nil || order.Status != pies.OrderStatusRejected {
+		t.Errorf("PlaceOrder(BND) = %+v, want a rejected order", order)
+	}
+
+	order, err = b.PlaceOrder(t.Context(), piestest.AccountID, buy("VTI", 1))
+	if err != nil || order.Status != pies.OrderStatusFilled {
+		t.Errorf("PlaceOrder(VTI) = %v, %v, want a filled order", order, err)
+	}
+}
+
+func TestLimitOrdersWaitForThePrice(t *testing.T) {
+	b := piestest.EmptyAccount(decimal.NewFromInt(1000))
+	b.SetPrice("VTI", decimal.NewFromInt(100))
+
+	limit := decimal.NewFromInt(95)
+	request := buy("VTI", 1)
+	request.Type = pies.OrderTypeLimit
+	request.LimitPrice = &limit
+
+	order, err := b.PlaceOrder(t.Context(), piestest.AccountID, request)
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	if order.Status != pies.OrderStatusWorking {
+		t.Fatalf("PlaceOrder() status = %s, want %s", order.Status, pies.OrderStatusWorking)
+	}
+
+	b.SetPrice("VTI", decimal.NewFromInt(94))
+	got, err := b.GetOrderStatus(t.Context(), piestest.AccountID, order.ID)
+	if err != nil {
+		t.Fatalf("GetOrderStatus() error = %v", err)
+	}
+	if got.Status != pies.OrderStatusFilled || !got.FilledPrice.Equal(limit) {
+		t.Errorf("GetOrderStatus() = %s at %s, want filled at %s", got.Status, got.FilledPrice, limit)
+	}
+}
+
+func TestCallsAreRecorded(t *testing.T) {
+	b := piestest.EmptyAccount(decimal.NewFromInt(1000))
+	b.SetPrice("VTI", decimal.NewFromInt(100))
+
+	ctx := t.Context()
+	b.GetAccounts(ctx)
+	b.GetQuotes(ctx, []string{"VTI"})
+	b.PlaceOrder(ctx, piestest.AccountID, buy("VTI", 2))
+	b.PlaceOrder(ctx, piestest.AccountID, buy("VTI", 1))
+
+	b.AssertCalled(t, "GetAccounts", 1)
+	b.AssertCalled(t, "PlaceOrder", 2)
+	b.AssertCalled(t, "CancelPendingOrder", 0)
+	b.AssertPlaced(t, buy("vti", 2), buy("VTI", 1))
+
+	calls := b.Calls()
+	if len(calls) != 4 || calls[1].Method != "GetQuotes" || calls[1].Symbols[0] != "VTI" {
+		t.Errorf("Calls() = %+v", calls)
+	}
+	if got := b.CallsTo("PlaceOrder")[0].AccountID; got != piestest.AccountID {
+		t.Errorf("PlaceOrder AccountID = %q, want %q", got, piestest.AccountID)
+	}
+}
+
+func TestSetError(t *testing.T) {
+	errDown := errors.New("brokerage is down")
+
+	b := piestest.EmptyAccount(decimal.NewFromInt(1000))
+	b.SetError("GetPositions", errDown)
+	if _, err := b.GetPositions(t.Context(), piestest.AccountID); !errors.Is(err, errDown) {
+		t.Errorf("GetPositions() error = %v, want %v", err, errDown)
+	}
+	if _, err := b.GetAccounts(t.Context()); err != nil {
+		t.Errorf("GetAccounts() error = %v", err)
+	}
+
+	b.SetError("GetPositions", nil)
+	if _, err := b.GetPositions(t.Context(), piestest.AccountID); err != nil {
+		t.Errorf("GetPositions() after clearing error = %v", err)
+	}
+}
+
+func TestDriftedPortfolio(t *testing.T) {
+	pie := pies.Pie{
+		Name: "three fund",
+		Slices: []pies.Slice{
+			{Weight: 60, Asset: pies.Asset{Symbol: "VTI"}},
+			{Weight: 30, Asset: pies.Asset{Symbol: "VXUS"}},
+			{Weight: 10, Asset: pies.Asset{Symbol: "BND"}},
+		},
+	}
+	b := piestest.DriftedPortfolio(pie, decimal.NewFromInt(10000), 5)
+
+	positions, err := b.GetPositions(t.Context(), piestest.AccountID)
+	if err != nil {
+		t.Fatalf("GetPositions() error = %v", err)
+	}
+	want := map[string]int64{"VTI": 6500, "VXUS": 3000, "BND": 500}
+	if len(positions) != len(want) {
+		t.Fatalf("GetPositions() = %+v, want %d positions", positions, len(want))
+	}
+	for _, position := range positions {
+		if !position.MarketValue.Equal(decimal.NewFromInt(want[position.Symbol])) {
+			t.Errorf("%s MarketValue = %s, want %d", position.Symbol, position.MarketValue, want[position.Symbol])
+		}
+	}
+}
+
+func TestConformance(t *testing.T) {
+	piestest.RunBrokerageConformanceTests(t, func() pies.BrokerageClient {
+		b := piestest.EmptyAccount(decimal.NewFromInt(1000))
+		b.SetPrice(piestest.ConformanceSymbol, decimal.NewFromInt(100))
+		return b
+	})
+}
+
+func TestUnauthenticatedConformance(t *testing.T) {
+	piestest.RunUnauthenticatedConformanceTests(t, func() pies.BrokerageClient {
+		b := piestest.EmptyAccount(decimal.NewFromInt(1000))
+		b.SetAuthenticated(false)
+		return b
+	})
+}
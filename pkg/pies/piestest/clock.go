@@ -0,0 +1,122 @@
+package piestest
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// Clock is a fake pies.Clock whose time only moves when Advance is called.
+// It also satisfies the TimerClock of brokerage clients that wait on timers.
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []timer
+	changed chan struct{} // Closed and replaced whenever a timer is added
+}
+
+// timer is a channel returned by After waiting for the clock to reach at
+type timer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewClock returns a clock stopped at now
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now, changed: make(chan struct{})}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// After returns a channel receiving the clock's time once Advance has moved
+// it d past now
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.timers = append(c.timers, timer{at: c.now.Add(d), ch: ch})
+	close(c.changed)
+	c.changed = make(chan struct{})
+	return ch
+}
+
+// Advance moves the clock d forward and fires every timer that is due
+func (c *Clock) Advance(d time.Duration) {
+	c.AdvanceTo(c.Now().Add(d))
+}
+
+// AdvanceTo moves the clock forward to t and fires every timer that is due.
+// The clock never moves back.
+func (c *Clock) AdvanceTo(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if t.After(c.now) {
+		c.now = t
+	}
+	pending := c.timers[:0]
+	for _, tm := range c.timers {
+		if tm.at.After(c.now) {
+			pending = append(pending, tm)
+			continue
+		}
+		tm.ch <- c.now
+	}
+	c.timers = pending
+}
+
+// Waiting returns how many timers have not fired yet
+func (c *Clock) Waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// NextTimer returns when the earliest pending timer fires, and false if no
+// timer is pending
+func (c *Clock) NextTimer() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var next time.Time
+	for _, tm := range c.timers {
+		if next.IsZero() || tm.at.Before(next) {
+			next = tm.at
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// WaitForTimers blocks until at least n timers are pending, so a test can
+// advance the clock only once the code under test is waiting on it. The
+// test fails after a few seconds of real time.
+func (c *Clock) WaitForTimers(t testing.TB, n int) {
+	t.Helper()
+
+	deadline := time.After(5 * time.Second)
+	for {
+		c.mu.Lock()
+		pending, changed := len(c.timers), c.changed
+		c.mu.Unlock()
+		if pending >= n {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			t.Fatalf("waited for %d timers, %d are pending", n, pending)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package piestest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// ConformanceSymbol is the symbol the conformance tests quote and trade
+	ConformanceSymbol = "VTI"
+
+	// UnknownSymbol is a symbol no brokerage quotes
+	UnknownSymbol = "NOSUCHSYMBOL"
+
+	// fillPolls and fillPollInterval bound how long CancelFilledOrder waits
+	// for a market order to fill
+	fillPolls        = 10
+	fillPollInterval = 100 * time.Millisecond
+)
+
+// RunBrokerageConformanceTests checks a BrokerageClient implementation
+// against the contract documented on pies.BrokerageClient. newClient is
+// called for every subtest and returns an authenticated client whose first
+// account has the cash to buy a share of ConformanceSymbol, which the
+// brokerage must quote. The tests place, replace and cancel orders, so run
+// them against a paper account or a mock server.
+func RunBrokerageConformanceTests(t *testing.T, newClient func() pies.BrokerageClient) {
+	t.Run("Accounts", func(t *testing.T) {
+		client := newClient()
+		if !client.IsAuthenticated() {
+			t.Fatal("IsAuthenticated() = false, want true")
+		}
+		conformanceAccount(t, client)
+	})
+
+	t.Run("Positions", func(t *testing.T) {
+		client := newClient()
+		positions, err := client.GetPositions(t.Context(), conformanceAccount(t, client))
+		if err != nil {
+			t.Fatalf("GetPositions() error = %v", err)
+		}
+		if positions == nil {
+			t.Error("GetPositions() = nil, want a non-nil slice")
+		}
+		for _, position := range positions {
+			if position.Symbol == "" || position.Quantity.IsZero() {
+				t.Errorf("GetPositions() returned empty position %+v", position)
+			}
+			if position.AssetType == "" {
+				t.Errorf("GetPositions() returned position %s without an asset type", position.Symbol)
+			}
+		}
+	})
+
+	t.Run("Quotes", func(t *testing.T) {
+		client := newClient()
+		quote, err := client.GetQuote(t.Context(), ConformanceSymbol)
+		if err != nil {
+			t.Fatalf("GetQuote(%s) error = %v", ConformanceSymbol, err)
+		}
+		if !quote.Last.IsPositive() {
+			t.Errorf("GetQuote(%s).Last = %s, want a positive price", ConformanceSymbol, quote.Last)
+		}
+
+		quotes, err := client.GetQuotes(t.Context(), nil)
+		if err != nil || quotes == nil || len(quotes) != 0 {
+			t.Errorf("GetQuotes(nil) = %v, %v, want an empty map", quotes, err)
+		}
+	})
+
+	t.Run("MissingQuotes", func(t *testing.T) {
+		client := newClient()
+		quotes, err := client.GetQuotes(t.Context(), []string{ConformanceSymbol, UnknownSymbol})
+
+		var missing *pies.MissingQuotesError
+		if !errors.As(err, &missing) {
+			t.Fatalf("GetQuotes() error = %v, want a *pies.MissingQuotesError", err)
+		}
+		if !slices.Contains(missing.Symbols, UnknownSymbol) {
+			t.Errorf("MissingQuotesError.Symbols = %v, want %s", missing.Symbols, UnknownSymbol)
+		}
+		if _, ok := quotes[ConformanceSymbol]; !ok {
+			t.Errorf("GetQuotes() = %v, want the quote for %s alongside the error", quotes, ConformanceSymbol)
+		}
+	})
+
+	t.Run("InvalidOrders", func(t *testing.T) {
+		client := newClient()
+		accountID := conformanceAccount(t, client)
+		one := decimal.NewFromInt(1)
+		minusOne := decimal.NewFromInt(-1)
+
+		for name, order := range map[string]pies.OrderRequest{
+			"no symbol":                   {Action: pies.OrderActionBuy, Type: pies.OrderTypeMarket, Quantity: one},
+			"no action":                   {Symbol: ConformanceSymbol, Type: pies.OrderTypeMarket, Quantity: one},
+			"unknown type":                {Type: "TRAILING_STOP", Quantity: one},
+			"no size":                     {Type: pies.OrderTypeMarket},
+			"two sizes":                   {Type: pies.OrderTypeMarket, Quantity: one, Amount: &one},
+			"no limit price":              {Type: pies.OrderTypeLimit, Quantity: one},
+			"negative limit price":        {Type: pies.OrderTypeLimit, Quantity: one, LimitPrice: &minusOne},
+			"stop price on limit order":   {Type: pies.OrderTypeLimit, Quantity: one, LimitPrice: &one, StopPrice: &one},
+			"limit price on market order": {Type: pies.OrderTypeMarket, Quantity: one, LimitPrice: &one},
+			"no stop price":               {Type: pies.OrderTypeStop, Quantity: one},
+		} {
+			if order.Symbol == "" && order.Action == "" {
+				order.Symbol = ConformanceSymbol
+				order.Action = pies.OrderActionBuy
+			}
+			if _, err := client.PlaceOrder(t.Context(), accountID, order); err == nil {
+				t.Errorf("PlaceOrder(%s) succeeded, want an error", name)
+			}
+		}
+	})
+
+	t.Run("OrderLifecycle", func(t *testing.T) {
+		client := newClient()
+		ctx := t.Context()
+		accountID := conformanceAccount(t, client)
+		limit := belowMarket(t, client)
+
+		first := placeOpenOrder(t, client, accountID, limit)
+		second := placeOpenOrder(t, client, accountID, limit.Sub(decimal.NewFromFloat(0.01)))
+
+		status, err := client.GetOrderStatus(ctx, accountID, first.ID)
+		if err != nil {
+			t.Fatalf("GetOrderStatus() error = %v", err)
+		}
+		if status.ID != first.ID || !status.Status.IsOpen() {
+			t.Errorf("GetOrderStatus() = order %s %s, want open order %s", status.ID, status.Status, first.ID)
+		}
+
+		orders, err := client.GetRecentOrders(ctx, accountID, pies.OrdersQuery{})
+		if err != nil {
+			t.Fatalf("GetRecentOrders() error = %v", err)
+		}
+		checkNewestFirst(t, orders)
+		for _, id := range []string{first.ID, second.ID} {
+			if !slices.ContainsFunc(orders, func(o pies.Order) bool { return o.ID == id }) {
+				t.Errorf("GetRecentOrders() is missing order %s", id)
+			}
+		}
+
+		limited, err := client.GetRecentOrders(ctx, accountID, pies.OrdersQuery{MaxResults: 1})
+		if err != nil {
+			t.Fatalf("GetRecentOrders(MaxResults: 1) error = %v", err)
+		}
+		if len(limited) > 1 {
+			t.Errorf("GetRecentOrders(MaxResults: 1) returned %d orders", len(limited))
+		}
+
+		replacement, err := client.ReplaceOrder(ctx, accountID, first.ID, limitBuy(limit.Sub(decimal.NewFromFloat(0.02))))
+		if err != nil {
+			t.Fatalf("ReplaceOrder() error = %v", err)
+		}
+		t.Cleanup(func() { client.CancelPendingOrder(context.Background(), accountID, replacement.ID) })
+		if replacement.ID == "" || !replacement.Status.IsOpen() {
+			t.Errorf("ReplaceOrder() = order %q %s, want an open order", replacement.ID, replacement.Status)
+		}
+		if replacement.ID != first.ID {
+			if replaced, err := client.GetOrderStatus(ctx, accountID, first.ID); err == nil && replaced.Status.IsOpen() {
+				t.Errorf("replaced order %s is still %s", first.ID, replaced.Status)
+			}
+		}
+
+		if err := client.CancelPendingOrder(ctx, accountID, second.ID); err != nil {
+			t.Fatalf("CancelPendingOrder() error = %v", err)
+		}
+		cancelled, err := client.GetOrderStatus(ctx, accountID, second.ID)
+		if err != nil {
+			t.Fatalf("GetOrderStatus() of cancelled order error = %v", err)
+		}
+		if cancelled.Status != pies.OrderStatusCancelled {
+			t.Errorf("cancelled order status = %s, want %s", cancelled.Status, pies.OrderStatusCancelled)
+		}
+
+		err = client.CancelPendingOrder(ctx, accountID, second.ID)
+		if !errors.Is(err, pies.ErrOrderNotOpen) {
+			t.Errorf("CancelPendingOrder() of cancelled order error = %v, want pies.ErrOrderNotOpen", err)
+		}
+	})
+
+	t.Run("CancelFilledOrder", func(t *testing.T) {
+		client := newClient()
+		ctx := t.Context()
+		accountID := conformanceAccount(t, client)
+
+		order, err := client.PlaceOrder(ctx, accountID, pies.OrderRequest{
+			Symbol:   ConformanceSymbol,
+			Action:   pies.OrderActionBuy,
+			Type:     pies.OrderTypeMarket,
+			Quantity: decimal.NewFromInt(1),
+		})
+		if err != nil {
+			t.Fatalf("PlaceOrder() error = %v", err)
+		}
+		for range fillPolls {
+			if !order.Status.IsOpen() {
+				break
+			}
+			time.Sleep(fillPollInterval)
+			if order, err = client.GetOrderStatus(ctx, accountID, order.ID); err != nil {
+				t.Fatalf("GetOrderStatus() error = %v", err)
+			}
+		}
+		if order.Status != pies.OrderStatusFilled {
+			client.CancelPendingOrder(ctx, accountID, order.ID)
+			t.Skipf("market order is %s, the brokerage did not fill it", order.Status)
+		}
+
+		err = client.CancelPendingOrder(ctx, accountID, order.ID)
+		if !errors.Is(err, pies.ErrOrderNotOpen) {
+			t.Errorf("CancelPendingOrder() of filled order error = %v, want pies.ErrOrderNotOpen", err)
+		}
+	})
+
+	t.Run("UnknownOrder", func(t *testing.T) {
+		client := newClient()
+		if _, err := client.GetOrderStatus(t.Context(), conformanceAccount(t, client), "0"); err == nil {
+			t.Error("GetOrderStatus() of unknown order succeeded, want an error")
+		}
+	})
+
+	t.Run("NoRecentOrders", func(t *testing.T) {
+		client := newClient()
+		from := time.Now().Add(time.Hour)
+		orders, err := client.GetRecentOrders(t.Context(), conformanceAccount(t, client), pies.OrdersQuery{
+			From: from,
+			To:   from.Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("GetRecentOrders() error = %v", err)
+		}
+		if orders == nil || len(orders) != 0 {
+			t.Errorf("GetRecentOrders() of a future range = %v, want an empty, non-nil slice", orders)
+		}
+	})
+}
+
+// RunUnauthenticatedConformanceTests checks that a client without usable
+// credentials, as returned by newClient, reports itself unauthenticated and
+// fails with errors matching pies.ErrNotAuthenticated
+func RunUnauthenticatedConformanceTests(t *testing.T, newClient func() pies.BrokerageClient) {
+	client := newClient()
+	if client.IsAuthenticated() {
+		t.Error("IsAuthenticated() = true, want false")
+	}
+
+	ctx := t.Context()
+	calls := map[string]func() error{
+		"GetAccounts": func() error {
+			_, err := client.GetAccounts(ctx)
+			return err
+		},
+		"GetPositions": func() error {
+			_, err := client.GetPositions(ctx, AccountID)
+			return err
+		},
+		"GetRecentOrders": func() error {
+			_, err := client.GetRecentOrders(ctx, AccountID, pies.OrdersQuery{})
+			return err
+		},
+		"GetQuote": func() error {
+			_, err := client.GetQuote(ctx, ConformanceSymbol)
+			return err
+		},
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, pies.ErrNotAuthenticated) {
+			t.Errorf("%s() error = %v, want pies.ErrNotAuthenticated", name, err)
+		}
+	}
+}
+
+// conformanceAccount returns the ID of the client's first account
+func conformanceAccount(t *testing.T, client pies.BrokerageClient) string {
+	t.Helper()
+
+	accounts, err := client.GetAccounts(t.Context())
+	if err != nil {
+		t.Fatalf("GetAccounts() error = %v", err)
+	}
+	if len(accounts) == 0 {
+		t.Fatal("GetAccounts() returned no accounts")
+	}
+	for _, account := range accounts {
+		if account.ID() == "" {
+			t.Errorf("GetAccounts() returned account %+v without an ID", account)
+		}
+	}
+	return accounts[0].ID()
+}
+
+// belowMarket returns a limit price for ConformanceSymbol low enough that
+// a buy at it stays open
+func belowMarket(t *testing.T, client pies.BrokerageClient) decimal.Decimal {
+	t.Helper()
+
+	quote, err := client.GetQuote(t.Context(), ConformanceSymbol)
+	if err != nil {
+		t.Fatalf("GetQuote(%s) error = %v", ConformanceSymbol, err)
+	}
+	return pies.RoundCents(quote.Last.Div(decimal.NewFromInt(2)))
+}
+
+func limitBuy(limit decimal.Decimal) pies.OrderRequest {
+	return pies.OrderRequest{
+		Symbol:     ConformanceSymbol,
+		Action:     pies.OrderActionBuy,
+		Type:       pies.OrderTypeLimit,
+		Quantity:   decimal.NewFromInt(1),
+		LimitPrice: &limit,
+		Duration:   pies.OrderDurationDay,
+	}
+}
+
+// placeOpenOrder places a limit buy of one share and cancels it when the
+// test ends
+func placeOpenOrder(t *testing.T, client pies.BrokerageClient, accountID string, limit decimal.Decimal) *pies.Order {
+	t.Helper()
+
+	order, err := client.PlaceOrder(t.Context(), accountID, limitBuy(limit))
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	if order.ID == "" || !order.Status.IsOpen() {
+		t.Fatalf("PlaceOrder() = order %q %s, want an open order", order.ID, order.Status)
+	}
+	t.Cleanup(func() { client.CancelPendingOrder(context.Background(), accountID, order.ID) })
+	return order
+}
+
+func checkNewestFirst(t *testing.T, orders []pies.Order) {
+	t.Helper()
+
+	for i := 1; i < len(orders); i++ {
+		if orders[i].SubmittedAt.After(orders[i-1].SubmittedAt) {
+			t.Errorf("GetRecentOrders() returned order %s submitted %s after order %s submitted %s",
+				orders[i].ID, orders[i].SubmittedAt, orders[i-1].ID, orders[i-1].SubmittedAt)
+			return
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package piestest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+)
+
+// Notifier is a fake pies.Notifier recording the events it is sent
+type Notifier struct {
+	// Err, when set, is returned by every Notify call after the event is
+	// recorded, to check that failing to notify fails nothing else
+	Err error
+
+	mu     sync.Mutex
+	events []pies.Event
+}
+
+func (n *Notifier) Notify(ctx context.Context, event pies.Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.events = append(n.events, event)
+	return n.Err
+}
+
+// Events returns the events sent so far, oldest first
+func (n *Notifier) Events() []pies.Event {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return append([]pies.Event(nil), n.events...)
+}
+
+// Kinds returns the kind of each event sent so far, oldest first
+func (n *Notifier) Kinds() []pies.EventKind {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	kinds := make([]pies.EventKind, 0, len(n.events))
+	for _, event := range n.events {
+		kinds = append(kinds, event.Kind)
+	}
+	return kinds
+}
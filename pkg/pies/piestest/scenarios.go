@@ -0,0 +1,75 @@
+package piestest
+
+import (
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// AccountID identifies the account of the scenario fakes
+	AccountID = "test-account"
+
+	// DefaultPrice is the price DriftedPortfolio quotes every symbol at
+	DefaultPrice = 100
+)
+
+// Account returns a cash account with AccountID holding cash
+func Account(cash decimal.Decimal) pies.Account {
+	return pies.Account{
+		AccountID:     AccountID,
+		AccountNumber: AccountID,
+		Type:          "CASH",
+		Kind:          pies.AccountKindCash,
+		CashBalance:   cash,
+		SettledCash:   cash,
+		BuyingPower:   cash,
+	}
+}
+
+// EmptyAccount returns a fake whose only account holds cash and no
+// positions
+func EmptyAccount(cash decimal.Decimal) *Brokerage {
+	b := New()
+	b.AddAccount(Account(cash))
+	return b
+}
+
+// DriftedPortfolio returns a fake whose only account holds value invested
+// in pie's slices, except that its first slice is drift percentage points
+// overweight and its last slice as much underweight. Every symbol is quoted
+// at DefaultPrice and positions may hold fractional shares. The weight of
+// cash slices and nested pies is held as cash.
+func DriftedPortfolio(pie pies.Pie, value decimal.Decimal, drift float64) *Brokerage {
+	b := New()
+	price := decimal.NewFromInt(DefaultPrice)
+
+	weights := make([]float64, len(pie.Slices))
+	for i, slice := range pie.Slices {
+		weights[i] = slice.Weight
+	}
+	if len(weights) > 1 {
+		weights[0] += drift
+		weights[len(weights)-1] -= drift
+	}
+
+	cash := value
+	var positions []pies.Position
+	for i, slice := range pie.Slices {
+		symbol := slice.Asset.Symbol
+		if slice.Pie != nil || symbol == "" || weights[i] <= 0 {
+			continue
+		}
+
+		invested := value.Mul(decimal.NewFromFloat(weights[i])).Div(decimal.NewFromInt(100)).Round(2)
+		cash = cash.Sub(invested)
+		positions = append(positions, pies.Position{
+			Symbol:       symbol,
+			Quantity:     invested.Div(price),
+			AveragePrice: price,
+		})
+		b.SetPrice(symbol, price)
+	}
+
+	b.AddAccount(Account(cash), positions...)
+	return b
+}
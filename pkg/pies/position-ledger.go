@@ -0,0 +1,291 @@
+package pies
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// shareTolerance is the difference in shares below which a ledger and the
+// account are considered to agree, to absorb fractional share rounding
+var shareTolerance = decimal.New(1, -6)
+
+// PositionLedger records which pie the shares traded in an account belong
+// to, so that several pies can share an account and hold the same symbols.
+// ExecutePlan records every fill when the investor has a ledger; shares
+// bought some other way can be attributed to a pie by recording an entry
+// without an order ID.
+type PositionLedger interface {
+	// Entries returns everything recorded for the account, oldest first
+	Entries(accountID string) ([]LedgerEntry, error)
+
+	// Record appends entries to the ledger
+	Record(entries ...LedgerEntry) error
+}
+
+// LedgerEntry attributes a change in the shares held of a symbol to a pie
+type LedgerEntry struct {
+	Time      time.Time       `json:"time"`
+	AccountID string          `json:"account_id"`
+	Pie       string          `json:"pie"`
+	Symbol    string          `json:"symbol"`
+	Quantity  decimal.Decimal `json:"quantity"` // Negative for sells
+	Price     decimal.Decimal `json:"price"`
+	OrderID   string          `json:"order_id,omitempty"`
+}
+
+// Discrepancy is a symbol whose shares in the account differ from the
+// shares the ledger attributes to all pies, e.g. after a manual trade or a
+// reinvested dividend
+type Discrepancy struct {
+	Symbol     string          `json:"symbol"`
+	Held       decimal.Decimal `json:"held"`       // Shares in the account
+	Attributed decimal.Decimal `json:"attributed"` // Shares the ledger attributes to any pie
+}
+
+// Unattributed returns the shares held that no pie accounts for, negative
+// when the ledger attributes more shares than the account holds
+func (d Discrepancy) Unattributed() decimal.Decimal {
+	return d.Held.Sub(d.Attributed)
+}
+
+// FileLedger keeps a PositionLedger as a JSON file. Writes are atomic, so a
+// crash never leaves a truncated ledger behind.
+type FileLedger struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (l *FileLedger) Entries(accountID string) ([]LedgerEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	all, err := l.loadLocked()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]LedgerEntry, 0, len(all))
+	for _, entry := range all {
+		if entry.AccountID == accountID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (l *FileLedger) Record(entries ...LedgerEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	all, err := l.loadLocked()
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.MarshalIndent(append(all, entries...), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal position ledger: %w", err)
+	}
+	raw = append(raw, '\n')
+
+	if err := writeFileAtomic(l.Path, raw); err != nil {
+		return fmt.Errorf("failed to save position ledger: %w", err)
+	}
+	return nil
+}
+
+// loadLocked reads every entry of the ledger. A missing file is an empty
+// ledger.
+func (l *FileLedger) loadLocked() ([]LedgerEntry, error) {
+	raw, err := os.ReadFile(l.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read position ledger: %w", err)
+	}
+
+	var entries []LedgerEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse position ledger %s: %w", l.Path, err)
+	}
+	return entries, nil
+}
+
+// GetPieStatusAttributed compares pie with the shares the investor's
+// PositionLedger attributes to it, rather than with every share of its
+// symbols in the account. Shares of the pie's symbols held for other pies
+// or for none are reported in Other, and every symbol where the account and
+// the ledger disagree is listed in Discrepancies. A pie is never attributed
+// more shares than the account holds. Cash is not attributed, so Cash is
+// the whole account's.
+func (i *Investor) GetPieStatusAttributed(ctx context.Context, pie Pie) (*PieStatus, error) {
+	if i.Ledger == nil {
+		return nil, errors.New("investor has no position ledger")
+	}
+
+	flat, err := pie.Flatten()
+	if err != nil {
+		return nil, fmt.Errorf("invalid pie %s: %w", pie.Name, err)
+	}
+
+	holdings, err := i.getHoldings(ctx, flat)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := i.Ledger.Entries(holdings.account.ID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read position ledger: %w", err)
+	}
+
+	owned, others := attributeHoldings(flat, holdings, entries)
+	status := computePieStatus(flat, owned)
+	for _, position := range others {
+		status.addOutside(position)
+	}
+	status.sortOutside()
+	status.Discrepancies = findDiscrepancies(flat, holdings, entries)
+
+	if pie.IsNested() {
+		status.Groups = computeGroups(pie, status)
+	}
+	return status, nil
+}
+
+// attributeHoldings splits the positions in the pie's symbols into the part
+// the ledger attributes to the pie, returned as holdings, and the rest
+func attributeHoldings(pie Pie, h *holdings, entries []LedgerEntry) (*holdings, []Position) {
+	ledger := make(map[string]decimal.Decimal)
+	for _, entry := range entries {
+		if entry.Pie == pie.Name {
+			symbol := normalizeSymbol(entry.Symbol)
+			ledger[symbol] = ledger[symbol].Add(entry.Quantity)
+		}
+	}
+
+	owned := &holdings{
+		account:   h.account,
+		positions: make(map[string]Position, len(h.positions)),
+		quotes:    h.quotes,
+
+		extendedHours: h.extendedHours,
+	}
+	for symbol, position := range h.positions {
+		owned.positions[symbol] = position
+	}
+
+	var others []Position
+	for _, slice := range pie.Slices {
+		symbol := slice.key()
+		position, held := h.positions[symbol]
+		if slice.IsCash() || !held {
+			continue
+		}
+
+		quantity := decimal.Min(decimal.Max(ledger[symbol], decimal.Zero), position.Quantity)
+		mine, rest := splitPosition(position, quantity)
+		owned.positions[symbol] = mine
+		if rest.Quantity.GreaterThan(shareTolerance) {
+			others = append(others, rest)
+		}
+	}
+
+	return owned, others
+}
+
+// splitPosition divides a position into quantity shares and the remainder,
+// sharing its market value and profit in proportion
+func splitPosition(position Position, quantity decimal.Decimal) (Position, Position) {
+	share := decimal.Zero
+	if position.Quantity.IsPositive() {
+		share = quantity.Div(position.Quantity)
+	}
+
+	mine, rest := position, position
+	mine.Quantity = quantity
+	mine.MarketValue = position.MarketValue.Mul(share)
+	mine.UnrealizedPL = position.UnrealizedPL.Mul(share)
+	rest.Quantity = position.Quantity.Sub(quantity)
+	rest.MarketValue = position.MarketValue.Sub(mine.MarketValue)
+	rest.UnrealizedPL = position.UnrealizedPL.Sub(mine.UnrealizedPL)
+	return mine, rest
+}
+
+// findDiscrepancies lists the pie's symbols, and every symbol the ledger
+// attributes to any pie, whose shares in the account differ from the ledger
+func findDiscrepancies(pie Pie, h *holdings, entries []LedgerEntry) []Discrepancy {
+	attributed := make(map[string]decimal.Decimal)
+	for _, entry := range entries {
+		symbol := normalizeSymbol(entry.Symbol)
+		attributed[symbol] = attributed[symbol].Add(entry.Quantity)
+	}
+	for _, slice := range pie.Slices {
+		if _, ok := attributed[slice.key()]; !slice.IsCash() && !ok {
+			attributed[slice.key()] = decimal.Zero
+		}
+	}
+
+	var discrepancies []Discrepancy
+	for symbol, quantity := range attributed {
+		discrepancy := Discrepancy{
+			Symbol:     symbol,
+			Held:       h.positions[symbol].Quantity,
+			Attributed: quantity,
+		}
+		if discrepancy.Unattributed().Abs().GreaterThan(shareTolerance) {
+			discrepancies = append(discrepancies, discrepancy)
+		}
+	}
+	sort.Slice(discrepancies, func(a, b int) bool {
+		return discrepancies[a].Symbol < discrepancies[b].Symbol
+	})
+	return discrepancies
+}
+
+// recordFills adds the fills of orders to the investor's ledger, if it has
+// one
+func (i *Investor) recordFills(report *ExecutionReport, orders []ExecutedOrder) error {
+	if i.Ledger == nil {
+		return nil
+	}
+
+	now := time.Now()
+	entries := make([]LedgerEntry, 0, len(orders))
+	for _, executed := range orders {
+		if !executed.FilledQty.IsPositive() {
+			continue
+		}
+		quantity := executed.FilledQty
+		if executed.Request.Action == OrderActionSell {
+			quantity = quantity.Neg()
+		}
+		entries = append(entries, LedgerEntry{
+			Time:      now,
+			AccountID: report.AccountID,
+			Pie:       report.Pie,
+			Symbol:    normalizeSymbol(executed.Request.Symbol),
+			Quantity:  quantity,
+			Price:     executed.FilledPrice,
+			OrderID:   executed.OrderID,
+		})
+	}
+
+	if err := i.Ledger.Record(entries...); err != nil {
+		return fmt.Errorf("failed to record fills in position ledger: %w", err)
+	}
+	return nil
+}
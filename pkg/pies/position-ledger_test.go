@@ -0,0 +1,194 @@
+package pies_test
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/paper"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/alysoliman1/money-pies/pkg/pies/piestest"
+	"github.com/shopspring/decimal"
+)
+
+// attribute returns ledger entries giving pie the symbols and share
+// quantities in holdings, e.g. "VTI", "60"
+func attribute(t *testing.T, pie string, holdings ...string) []pies.LedgerEntry {
+	t.Helper()
+
+	var entries []pies.LedgerEntry
+	for i := 0; i < len(holdings); i += 2 {
+		entries = append(entries, pies.LedgerEntry{
+			AccountID: piestest.AccountID,
+			Pie:       pie,
+			Symbol:    holdings[i],
+			Quantity:  dec(t, holdings[i+1]),
+			Price:     decimal.NewFromInt(piestest.DefaultPrice),
+		})
+	}
+	return entries
+}
+
+// sliceQuantities renders the quantity of every slice of status as
+// "SYMBOL QUANTITY"
+func sliceQuantities(status *pies.PieStatus) []string {
+	var quantities []string
+	for _, slice := range status.Slices {
+		quantities = append(quantities, slice.Symbol+" "+slice.Quantity.String())
+	}
+	return quantities
+}
+
+// discrepancies renders the discrepancies of status as "SYMBOL HELD/ATTRIBUTED"
+func discrepancies(status *pies.PieStatus) []string {
+	var rendered []string
+	for _, d := range status.Discrepancies {
+		rendered = append(rendered, d.Symbol+" "+d.Held.String()+"/"+d.Attributed.String())
+	}
+	return rendered
+}
+
+func TestGetPieStatusAttributed(t *testing.T) {
+	// Both pies hold VTI in one account; the core pie 60 of its 100 shares
+	core := testPie("VTI", 75, "BND", 25)
+	shared := slices.Concat(attribute(t, "test", "VTI", "60", "BND", "20"), attribute(t, "satellite", "VTI", "40", "VXUS", "30"))
+
+	tests := []struct {
+		name          string
+		holdings      []string
+		entries       []pies.LedgerEntry
+		want          []string
+		other         []string // "SYMBOL QUANTITY"
+		discrepancies []string
+	}{
+		{
+			name:     "overlapping symbols split by the ledger",
+			holdings: []string{"VTI", "100", "BND", "20", "VXUS", "30"},
+			entries:  shared,
+			want:     []string{"VTI 60", "BND 20"},
+			other:    []string{"VTI 40", "VXUS 30"},
+		},
+		{
+			name:          "a reinvested dividend is a discrepancy, not the pie's",
+			holdings:      []string{"VTI", "100.5", "BND", "20", "VXUS", "30"},
+			entries:       shared,
+			want:          []string{"VTI 60", "BND 20"},
+			other:         []string{"VTI 40.5", "VXUS 30"},
+			discrepancies: []string{"VTI 100.5/100"},
+		},
+		{
+			name:          "a manual sale leaves the pie at most what is held",
+			holdings:      []string{"VTI", "50", "BND", "20", "VXUS", "30"},
+			entries:       shared,
+			want:          []string{"VTI 50", "BND 20"},
+			other:         []string{"VXUS 30"},
+			discrepancies: []string{"VTI 50/100"},
+		},
+		{
+			name:     "sells net against buys",
+			holdings: []string{"VTI", "100", "BND", "20", "VXUS", "30"},
+			entries: slices.Concat(shared, attribute(t, "test", "VTI", "-15"),
+				attribute(t, "satellite", "VTI", "15")),
+			want:  []string{"VTI 45", "BND 20"},
+			other: []string{"VTI 55", "VXUS 30"},
+		},
+		{
+			name:          "a pie symbol missing from the ledger",
+			holdings:      []string{"VTI", "100", "BND", "20"},
+			entries:       attribute(t, "test", "VTI", "100"),
+			want:          []string{"VTI 100", "BND 0"},
+			other:         []string{"BND 20"},
+			discrepancies: []string{"BND 20/0"},
+		},
+		{
+			name:     "entries of other accounts are ignored",
+			holdings: []string{"VTI", "100", "BND", "20"},
+			entries: func() []pies.LedgerEntry {
+				entries := attribute(t, "test", "VTI", "100", "BND", "20")
+				elsewhere := attribute(t, "test", "VTI", "500")
+				elsewhere[0].AccountID = "elsewhere"
+				return append(entries, elsewhere...)
+			}(),
+			want: []string{"VTI 100", "BND 20"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := &pies.FileLedger{Path: filepath.Join(t.TempDir(), "ledger.json")}
+			if err := ledger.Record(tt.entries...); err != nil {
+				t.Fatalf("Record() error = %v", err)
+			}
+			b := holdingFake(t, pies.AccountKindCash, "0", tt.holdings...)
+			investor := &pies.Investor{BrokerageClient: b, Ledger: ledger}
+
+			status, err := investor.GetPieStatusAttributed(t.Context(), core)
+			if err != nil {
+				t.Fatalf("GetPieStatusAttributed() error = %v", err)
+			}
+
+			if got := sliceQuantities(status); strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("slices = %v, want %v", got, tt.want)
+			}
+			var other []string
+			for _, position := range status.Other {
+				other = append(other, position.Symbol+" "+position.Quantity.String())
+			}
+			if strings.Join(other, ", ") != strings.Join(tt.other, ", ") {
+				t.Errorf("other = %v, want %v", other, tt.other)
+			}
+			if got := discrepancies(status); strings.Join(got, ", ") != strings.Join(tt.discrepancies, ", ") {
+				t.Errorf("discrepancies = %v, want %v", got, tt.discrepancies)
+			}
+		})
+	}
+}
+
+func TestExecutePlanRecordsPartialFills(t *testing.T) {
+	// The sell fills 8 of its 10 shares before the timeout and is left
+	// working, so its last 2 shares fill after ExecutePlan returns
+	client := paperAccount(t, paper.Config{MaxFillQuantity: decimal.NewFromInt(4)})
+	ledger := &pies.FileLedger{Path: filepath.Join(t.TempDir(), "ledger.json")}
+	entries := attribute(t, "test", "VTI", "70", "BND", "30")
+	for i := range entries {
+		entries[i].AccountID = "paper"
+	}
+	if err := ledger.Record(entries...); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	investor := &pies.Investor{BrokerageClient: client, Ledger: ledger}
+
+	report, err := investor.ExecutePlan(t.Context(), planFor(t, investor), pies.ExecuteOptions{
+		PollInterval: time.Millisecond,
+		FillTimeout:  time.Nanosecond,
+	})
+	if err != nil {
+		t.Fatalf("ExecutePlan() error = %v", err)
+	}
+
+	recorded, err := ledger.Entries("paper")
+	if err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	var fills []string
+	for _, entry := range recorded[len(entries):] {
+		fills = append(fills, entry.Symbol+" "+entry.Quantity.String()+" "+entry.OrderID)
+	}
+	want := []string{"VTI -8 " + report.Orders[0].OrderID, "BND 8 " + report.Orders[1].OrderID}
+	if strings.Join(fills, ", ") != strings.Join(want, ", ") {
+		t.Errorf("recorded fills = %v, want %v", fills, want)
+	}
+
+	status, err := investor.GetPieStatusAttributed(t.Context(), testPie("VTI", 60, "BND", 40))
+	if err != nil {
+		t.Fatalf("GetPieStatusAttributed() error = %v", err)
+	}
+	if got, want := sliceQuantities(status), []string{"VTI 60", "BND 38"}; strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("slices = %v, want %v", got, want)
+	}
+	if got, want := discrepancies(status), []string{"VTI 60/62"}; strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("discrepancies = %v, want the late fills %v", got, want)
+	}
+}
@@ -0,0 +1,125 @@
+package pies
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// QuoteCacheClient wraps a BrokerageClient so that a quote is reused for
+// TTL after it was fetched, keeping commands that price the same symbols
+// over and over within the brokerage's rate limits. Every other
+// BrokerageClient call goes through to the wrapped client.
+type QuoteCacheClient struct {
+	BrokerageClient
+	TTL time.Duration
+
+	mu     sync.Mutex
+	quotes map[string]cachedQuote // Keyed by upper-case symbol
+}
+
+// cachedQuote is a quote and when it stops being reused
+type cachedQuote struct {
+	quote   Quote
+	expires time.Time
+}
+
+// NewQuoteCacheClient wraps client, reusing its quotes for ttl
+func NewQuoteCacheClient(client BrokerageClient, ttl time.Duration) *QuoteCacheClient {
+	return &QuoteCacheClient{
+		BrokerageClient: client,
+		TTL:             ttl,
+		quotes:          make(map[string]cachedQuote),
+	}
+}
+
+// BatchesQuotes reports whether the wrapped client batches quotes, so that
+// QuoteFetcher keeps using GetQuotes for it
+func (c *QuoteCacheClient) BatchesQuotes() bool {
+	batcher, ok := c.BrokerageClient.(QuoteBatcher)
+	return ok && batcher.BatchesQuotes()
+}
+
+// SupportsFractionalShares reports whether the wrapped client trades
+// fractional shares
+func (c *QuoteCacheClient) SupportsFractionalShares() bool {
+	trader, ok := c.BrokerageClient.(FractionalTrader)
+	return ok && trader.SupportsFractionalShares()
+}
+
+func (c *QuoteCacheClient) GetQuote(ctx context.Context, symbol string) (*Quote, error) {
+	if quote, ok := c.cached(symbol, time.Now()); ok {
+		return &quote, nil
+	}
+
+	quote, err := c.BrokerageClient.GetQuote(ctx, symbol)
+	if err != nil || quote == nil {
+		return quote, err
+	}
+	c.store(map[string]Quote{symbol: *quote}, time.Now())
+	return quote, nil
+}
+
+// GetQuotes returns the cached quotes of symbols and fetches the others in
+// one call to the wrapped client. A *MissingQuotesError from it is returned
+// with the quotes that were found.
+func (c *QuoteCacheClient) GetQuotes(ctx context.Context, symbols []string) (map[string]Quote, error) {
+	now := time.Now()
+	quotes := make(map[string]Quote, len(symbols))
+	var uncached []string
+	for _, symbol := range symbols {
+		if quote, ok := c.cached(symbol, now); ok {
+			quotes[symbol] = quote
+		} else {
+			uncached = append(uncached, symbol)
+		}
+	}
+	if len(uncached) == 0 {
+		return quotes, nil
+	}
+
+	fetched, err := c.BrokerageClient.GetQuotes(ctx, uncached)
+	var missing *MissingQuotesError
+	if err != nil && !errors.As(err, &missing) {
+		return nil, err
+	}
+	c.store(fetched, time.Now())
+	for symbol, quote := range fetched {
+		quotes[symbol] = quote
+	}
+	return quotes, err
+}
+
+// cached returns the quote of symbol if it was fetched less than TTL before
+// now
+func (c *QuoteCacheClient) cached(symbol string, now time.Time) (Quote, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.quotes[normalizeSymbol(symbol)]
+	if !ok || !now.Before(entry.expires) {
+		return Quote{}, false
+	}
+	return entry.quote, true
+}
+
+// store caches quotes fetched at now
+func (c *QuoteCacheClient) store(quotes map[string]Quote, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.quotes == nil {
+		c.quotes = make(map[string]cachedQuote)
+	}
+	for symbol, quote := range quotes {
+		c.quotes[normalizeSymbol(symbol)] = cachedQuote{quote: quote, expires: now.Add(c.TTL)}
+	}
+	// Drop expired quotes so that a long-running command does not keep
+	// every symbol it ever priced
+	for symbol, entry := range c.quotes {
+		if !now.Before(entry.expires) {
+			delete(c.quotes, symbol)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package pies
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// defaultQuoteConcurrency is the number of quotes QuoteFetcher requests at
+// once when its Concurrency is zero
+const defaultQuoteConcurrency = 5
+
+// QuoteBatcher is implemented by brokerages whose GetQuotes prices many
+// symbols in a single request, like Schwab's
+type QuoteBatcher interface {
+	BatchesQuotes() bool
+}
+
+// QuoteFetcher gets quotes for many symbols, through one GetQuotes call for
+// brokerages that batch quotes and otherwise through GetQuote calls made
+// Concurrency at a time. Every call goes through the client, so a client
+// that limits its request rate keeps doing so.
+type QuoteFetcher struct {
+	Client      BrokerageClient
+	Concurrency int // Zero requests 5 quotes at once
+}
+
+// Fetch returns quotes for symbols keyed by symbol. Symbols that could not
+// be quoted do not fail the others: they are reported in a
+// *MissingQuotesError, with the error for each one that failed, alongside
+// the quotes that were found.
+func (f QuoteFetcher) Fetch(ctx context.Context, symbols []string) (map[string]Quote, error) {
+	if batcher, ok := f.Client.(QuoteBatcher); ok && batcher.BatchesQuotes() {
+		return f.Client.GetQuotes(ctx, symbols)
+	}
+
+	concurrency := f.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultQuoteConcurrency
+	}
+
+	var unique []string
+	for _, symbol := range symbols {
+		if !slices.Contains(unique, symbol) {
+			unique = append(unique, symbol)
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	quotes := make(map[string]Quote, len(unique))
+	failed := make(map[string]error)
+	work := make(chan string)
+	for range min(concurrency, len(unique)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for symbol := range work {
+				quote, err := f.Client.GetQuote(ctx, symbol)
+				if err == nil && quote == nil {
+					err = errors.New("no quote returned")
+				}
+
+				mu.Lock()
+				if err != nil {
+					failed[symbol] = err
+				} else {
+					quotes[symbol] = *quote
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+send:
+	for _, symbol := range unique {
+		select {
+		case work <- symbol:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(failed) == 0 {
+		return quotes, nil
+	}
+
+	missing := &MissingQuotesError{Errors: failed}
+	for _, symbol := range unique {
+		if _, ok := failed[symbol]; ok {
+			missing.Symbols = append(missing.Symbols, symbol)
+		}
+	}
+	return quotes, missing
+}
@@ -0,0 +1,230 @@
+package pies_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/alysoliman1/money-pies/pkg/pies/piestest"
+	"github.com/shopspring/decimal"
+)
+
+// countingBrokerage is a fake that counts the GetQuote calls in flight and
+// holds each one until release is closed
+type countingBrokerage struct {
+	*piestest.Brokerage
+	release chan struct{}
+	fail    map[string]error // Errors GetQuote fails with, by symbol
+	batches bool
+
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+	started  chan struct{} // Receives once per call that starts
+}
+
+// maxQuoteCalls bounds the calls a countingBrokerage can start
+const maxQuoteCalls = 64
+
+func newCountingBrokerage(symbols ...string) *countingBrokerage {
+	b := &countingBrokerage{
+		Brokerage: piestest.New(),
+		release:   make(chan struct{}),
+		started:   make(chan struct{}, maxQuoteCalls),
+	}
+	for _, symbol := range symbols {
+		b.SetPrice(symbol, decimal.NewFromInt(piestest.DefaultPrice))
+	}
+	return b
+}
+
+func (b *countingBrokerage) GetQuote(ctx context.Context, symbol string) (*pies.Quote, error) {
+	b.mu.Lock()
+	b.inFlight++
+	b.peak = max(b.peak, b.inFlight)
+	b.mu.Unlock()
+	b.started <- struct{}{}
+
+	defer func() {
+		b.mu.Lock()
+		b.inFlight--
+		b.mu.Unlock()
+	}()
+
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	quote, err := b.Brokerage.GetQuote(ctx, symbol)
+	if failure := b.fail[symbol]; failure != nil {
+		return nil, failure
+	}
+	return quote, err
+}
+
+func (b *countingBrokerage) BatchesQuotes() bool {
+	return b.batches
+}
+
+// waitForStarts waits until n GetQuote calls have started
+func (b *countingBrokerage) waitForStarts(t *testing.T, n int) {
+	t.Helper()
+
+	deadline := time.After(5 * time.Second)
+	for range n {
+		select {
+		case <-b.started:
+		case <-deadline:
+			t.Fatalf("waited for %d quotes to be requested", n)
+		}
+	}
+}
+
+// symbolsN returns n distinct symbols
+func symbolsN(n int) []string {
+	symbols := make([]string, n)
+	for i := range symbols {
+		symbols[i] = fmt.Sprintf("S%02d", i)
+	}
+	return symbols
+}
+
+func TestQuoteFetcherBoundsConcurrency(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency int
+		symbols     int
+		wantPeak    int
+	}{
+		{name: "configured", concurrency: 3, symbols: 12, wantPeak: 3},
+		{name: "default", symbols: 12, wantPeak: 5},
+		{name: "fewer symbols than workers", concurrency: 8, symbols: 2, wantPeak: 2},
+		{name: "one at a time", concurrency: 1, symbols: 4, wantPeak: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			symbols := symbolsN(tt.symbols)
+			b := newCountingBrokerage(symbols...)
+			fetcher := pies.QuoteFetcher{Client: b, Concurrency: tt.concurrency}
+
+			type result struct {
+				quotes map[string]pies.Quote
+				err    error
+			}
+			done := make(chan result, 1)
+			go func() {
+				quotes, err := fetcher.Fetch(t.Context(), symbols)
+				done <- result{quotes, err}
+			}()
+
+			// The pool fills up, and nothing more starts until a quote
+			// is returned
+			b.waitForStarts(t, tt.wantPeak)
+			select {
+			case <-b.started:
+				t.Fatalf("more than %d quotes requested at once", tt.wantPeak)
+			case <-time.After(20 * time.Millisecond):
+			}
+			close(b.release)
+
+			got := <-done
+			if got.err != nil {
+				t.Fatalf("Fetch() error = %v", got.err)
+			}
+			if len(got.quotes) != tt.symbols {
+				t.Errorf("Fetch() returned %d quotes, want %d", len(got.quotes), tt.symbols)
+			}
+			if b.peak != tt.wantPeak {
+				t.Errorf("%d quotes requested at once, want %d", b.peak, tt.wantPeak)
+			}
+			b.AssertCalled(t, "GetQuote", tt.symbols)
+		})
+	}
+}
+
+func TestQuoteFetcherPartialFailure(t *testing.T) {
+	b := newCountingBrokerage("VTI", "BND", "VXUS")
+	close(b.release)
+	errDown := errors.New("quote service down")
+	b.fail = map[string]error{"BND": errDown}
+
+	quotes, err := pies.QuoteFetcher{Client: b}.Fetch(t.Context(), []string{"VTI", "BND", "NOPE", "VXUS", "VTI"})
+
+	var missing *pies.MissingQuotesError
+	if !errors.As(err, &missing) {
+		t.Fatalf("Fetch() error = %v, want a *MissingQuotesError", err)
+	}
+	if !slices.Equal(missing.Symbols, []string{"BND", "NOPE"}) {
+		t.Errorf("missing symbols = %v, want [BND NOPE] in request order", missing.Symbols)
+	}
+	if !errors.Is(err, errDown) {
+		t.Errorf("Fetch() error = %v, want it to wrap the failure of BND", err)
+	}
+	if len(quotes) != 2 || !quotes["VTI"].Last.Equal(decimal.NewFromInt(piestest.DefaultPrice)) || quotes["VXUS"].Symbol != "VXUS" {
+		t.Errorf("Fetch() quotes = %+v, want VTI and VXUS", quotes)
+	}
+	// VTI is requested once although it is asked for twice
+	b.AssertCalled(t, "GetQuote", 4)
+}
+
+func TestQuoteFetcherBatches(t *testing.T) {
+	b := newCountingBrokerage("VTI", "BND")
+	b.batches = true
+
+	quotes, err := pies.QuoteFetcher{Client: b}.Fetch(t.Context(), []string{"VTI", "BND"})
+	if err != nil || len(quotes) != 2 {
+		t.Fatalf("Fetch() = %+v, %v, want both quotes", quotes, err)
+	}
+	b.AssertCalled(t, "GetQuotes", 1)
+	if b.peak != 0 {
+		t.Errorf("GetQuote called alongside the batch")
+	}
+}
+
+func TestQuoteFetcherCancelled(t *testing.T) {
+	symbols := symbolsN(10)
+	b := newCountingBrokerage(symbols...)
+	ctx, cancel := context.WithCancel(t.Context())
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := pies.QuoteFetcher{Client: b, Concurrency: 2}.Fetch(ctx, symbols)
+		done <- err
+	}()
+	b.waitForStarts(t, 2)
+	cancel()
+
+	// The quotes in flight give up and no quotes are returned
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Fetch() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestGetPieStatusWithAFailedQuote(t *testing.T) {
+	b := &countingBrokerage{
+		Brokerage: holdingFake(t, pies.AccountKindTaxable, "0", "VTI", "60", "BND", "40"),
+		release:   make(chan struct{}),
+		started:   make(chan struct{}, maxQuoteCalls),
+		fail:      map[string]error{"BND": errors.New("quote service down")},
+	}
+	close(b.release)
+
+	investor := &pies.Investor{BrokerageClient: b, QuoteConcurrency: 2}
+	status, err := investor.GetPieStatus(t.Context(), testPie("VTI", 60, "BND", 40))
+	if err != nil {
+		t.Fatalf("GetPieStatus() error = %v", err)
+	}
+	if !slices.Equal(status.Unpriced, []string{"BND"}) {
+		t.Errorf("Unpriced = %v, want [BND]", status.Unpriced)
+	}
+	if !status.Slices[0].Price.Equal(decimal.NewFromInt(piestest.DefaultPrice)) {
+		t.Errorf("VTI price = %s, want it quoted", status.Slices[0].Price)
+	}
+}
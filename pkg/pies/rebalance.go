@@ -0,0 +1,389 @@
+package pies
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// fractionalPrecision is the number of decimal places fractional share
+// quantities are rounded down to
+const fractionalPrecision = 6
+
+// RebalanceMode selects which slices ComputeRebalancePlan trades
+type RebalanceMode string
+
+const (
+	RebalanceAll       RebalanceMode = "ALL"       // Trade every slice back to target
+	RebalanceBreaching RebalanceMode = "BREACHING" // Only trade slices outside their drift band
+)
+
+// RebalanceOptions controls how ComputeRebalancePlan trades towards a pie
+type RebalanceOptions struct {
+	// Mode selects the slices to trade. Empty uses RebalanceAll. With
+	// RebalanceBreaching targets are weights of the pie's holdings alone and
+	// cash is only spent to fund the buys.
+	Mode RebalanceMode
+
+	// ToBandEdge trades breaching slices only back to the edge of their
+	// drift band instead of all the way to target. It requires
+	// RebalanceBreaching.
+	ToBandEdge bool
+
+	// AllowSells lets the plan sell overweight slices to fund underweight
+	// ones. Without it the plan only spends the account's cash.
+	AllowSells bool
+
+	// AllowTaxFreeSells lets the plan sell, even without AllowSells, in
+	// accounts whose kind is tax-advantaged, such as IRAs, where selling
+	// realizes no taxable gains
+	AllowTaxFreeSells bool
+
+	// MinTradeValue and MinTradeShares skip trades worth less than this
+	// many dollars or shares. The dollars of a skipped buy are spread over
+	// the remaining buys; a skipped sell leaves its shares in place.
+	MinTradeValue  decimal.Decimal
+	MinTradeShares decimal.Decimal
+
+	// OrderType is the type of the planned orders. Limit orders are priced
+	// at the quote the order was sized at. Empty uses market orders.
+	OrderType OrderType
+
+	// LimitOffsetBps moves the limit price of limit orders this many basis
+	// points past the quote, up for buys and down for sells, so that they
+	// still fill when the price moves a little. The plan's values stay at
+	// the quote. Zero prices them at the quote.
+	LimitOffsetBps float64
+
+	// Fractional sizes orders in fractional shares. Without it quantities
+	// are rounded down to whole shares.
+	Fractional bool
+
+	// AcknowledgeUnscreened lists symbols the plan may trade even though
+	// the investor's Screener rejects them. Their screening failures are
+	// recorded in the plan.
+	AcknowledgeUnscreened []string
+}
+
+// RebalancePlan is the list of orders bringing an account back towards a
+// pie. It is meant to be reviewed, e.g. as JSON, before it is executed.
+type RebalancePlan struct {
+	Pie       string          `json:"pie"`
+	PieID     string          `json:"pie_id,omitempty"`
+	AccountID string          `json:"account_id"`
+	Orders    []PlannedOrder  `json:"orders"`
+	Cash      decimal.Decimal `json:"cash"`              // Cash before trading
+	CashAfter decimal.Decimal `json:"cash_after"`        // Estimated cash once every order fills
+	Skipped   []SkippedTrade  `json:"skipped,omitempty"` // Trades too small to place
+
+	// Leftover is the part of the cash given to AllocateCash that buys no
+	// further whole share
+	Leftover decimal.Decimal `json:"leftover,omitzero"`
+
+	// AcknowledgedFailures are the screening failures of symbols traded
+	// under RebalanceOptions.AcknowledgeUnscreened
+	AcknowledgedFailures []ScreeningFailure `json:"acknowledged_screening_failures,omitempty"`
+}
+
+// PlannedOrder is an order of a RebalancePlan and the price it was sized at
+type PlannedOrder struct {
+	Order     OrderRequest    `json:"order"`
+	Price     decimal.Decimal `json:"price"`
+	Value     decimal.Decimal `json:"value"`                // Estimated value of the trade
+	AssetType string          `json:"asset_type,omitempty"` // Of the slice traded, see SliceStatus
+}
+
+// SkippedTrade is a trade left out of a RebalancePlan and why
+type SkippedTrade struct {
+	Symbol   string          `json:"symbol"`
+	Action   OrderAction     `json:"action"`
+	Quantity decimal.Decimal `json:"quantity"`
+	Value    decimal.Decimal `json:"value"`
+	Reason   string          `json:"reason"`
+}
+
+// OrderRequests returns the plan's orders, sells first
+func (p RebalancePlan) OrderRequests() []OrderRequest {
+	orders := make([]OrderRequest, 0, len(p.Orders))
+	for _, planned := range p.Orders {
+		orders = append(orders, planned.Order)
+	}
+	return orders
+}
+
+// ComputeRebalancePlan plans the orders that bring every slice of pie, or
+// with RebalanceBreaching only the slices outside their drift band, to its
+// target weight of the pie's holdings plus the account's cash. Holdings
+// outside the pie are left alone. Sells come first so that their proceeds
+// fund the buys, and when the cash is not enough to reach every target the
+// buys are scaled down proportionally, so the plan never spends more than
+// the account has. A cash slice is met by holding back its target from the
+// buys rather than by trading. Whole share buys are sized with
+// AllocateWholeShares.
+//
+// With a Screener a plan trading any rejected symbol fails with a
+// *ScreeningError, unless the symbol is acknowledged in opts.
+func (i *Investor) ComputeRebalancePlan(ctx context.Context, pie Pie, opts RebalanceOptions) (*RebalancePlan, error) {
+	status, holdings, err := i.getPieStatus(ctx, pie, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if len(status.Unpriced) > 0 {
+		return nil, fmt.Errorf("cannot rebalance without quotes for %s", strings.Join(status.Unpriced, ", "))
+	}
+
+	plan := computeRebalancePlan(status, holdings.quotes, holdings.extendedHours, opts)
+	if err := i.screenPlan(ctx, plan, opts.AcknowledgeUnscreened); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// computeRebalancePlan plans the trades from status to the pie's targets,
+// priced at extended-hours quotes with extendedHours. Slices without a
+// usable price are skipped rather than traded.
+func computeRebalancePlan(status *PieStatus, quotes map[string]Quote, extendedHours bool, opts RebalanceOptions) *RebalancePlan {
+	plan := &RebalancePlan{
+		Pie:       status.Pie,
+		PieID:     status.PieID,
+		AccountID: status.Account.ID(),
+		Orders:    []PlannedOrder{},
+		Cash:      status.Cash,
+	}
+
+	// Threshold rebalancing only corrects drift, so cash merely funds the
+	// buys instead of being invested in the pie as a whole. A cash slice
+	// already counts the cash towards the pie's value.
+	cash := decimal.Max(status.Cash, decimal.Zero)
+	total := status.PieValue.Add(cash)
+	cashSlice, hasCashSlice := status.cashSlice()
+	if opts.Mode == RebalanceBreaching || hasCashSlice {
+		total = status.PieValue
+	}
+	values := make([]decimal.Decimal, len(status.Slices))
+	goals := make([]decimal.Decimal, len(status.Slices))
+	for idx, slice := range status.Slices {
+		values[idx] = slice.Value
+		goals[idx] = weightOf(total, rebalanceGoal(slice, opts))
+		if opts.Mode == RebalanceBreaching && !slice.Breaches() {
+			goals[idx] = values[idx]
+		}
+	}
+
+	// The cash slice is never traded, its target is held back from the buys
+	var reserve decimal.Decimal
+	if hasCashSlice {
+		reserve = weightOf(total, rebalanceGoal(cashSlice, opts))
+	}
+
+	if opts.allowsSells(status.Account.Kind) {
+		for idx, slice := range status.Slices {
+			excess := values[idx].Sub(goals[idx])
+			if slice.IsCash || !excess.IsPositive() {
+				continue
+			}
+
+			price := quotePrice(quotes[slice.Symbol], OrderActionSell, extendedHours)
+			if !price.IsPositive() {
+				plan.unpriced(slice.Symbol, OrderActionSell)
+				continue
+			}
+			quantity := decimal.Min(RoundShares(excess.Div(price), opts.Fractional), slice.Quantity)
+			planned, ok := plan.add(slice, OrderActionSell, quantity, price, opts)
+			if !ok {
+				continue
+			}
+			values[idx] = values[idx].Sub(planned.Value)
+			cash = cash.Add(planned.Value)
+		}
+	}
+
+	deficits := make(map[string]decimal.Decimal, len(status.Slices))
+	prices := make(map[string]decimal.Decimal, len(status.Slices))
+	var totalDeficit decimal.Decimal
+	for idx, slice := range status.Slices {
+		deficit := goals[idx].Sub(values[idx])
+		if slice.IsCash || !deficit.IsPositive() {
+			continue
+		}
+		price := quotePrice(quotes[slice.Symbol], OrderActionBuy, extendedHours)
+		if !price.IsPositive() {
+			plan.unpriced(slice.Symbol, OrderActionBuy)
+			continue
+		}
+		deficits[slice.Symbol] = deficit
+		prices[slice.Symbol] = price
+		totalDeficit = totalDeficit.Add(deficit)
+	}
+
+	// Drop the smallest buy below the minimums and spread the budget over
+	// the others again, until every remaining buy is large enough. Dropping
+	// one buy at a time lets the freed dollars lift the next smallest one
+	// over the minimums.
+	budget := decimal.Min(decimal.Max(cash.Sub(reserve), decimal.Zero), totalDeficit)
+	var quantities map[string]decimal.Decimal
+	for len(deficits) > 0 {
+		quantities = sizeBuys(deficits, prices, budget, opts.Fractional)
+
+		smallest, smallestValue := "", decimal.Zero
+		for symbol, quantity := range quantities {
+			value := quantity.Mul(prices[symbol])
+			if belowMinimum(quantity, value, opts) == "" {
+				continue
+			}
+			if smallest == "" || value.LessThan(smallestValue) || (value.Equal(smallestValue) && symbol < smallest) {
+				smallest, smallestValue = symbol, value
+			}
+		}
+		if smallest == "" {
+			break
+		}
+
+		plan.skip(smallest, OrderActionBuy, quantities[smallest], prices[smallest], opts)
+		delete(deficits, smallest)
+		delete(quantities, smallest)
+	}
+
+	for _, slice := range status.Slices {
+		quantity, ok := quantities[slice.Symbol]
+		if slice.IsCash || !ok {
+			continue
+		}
+		if planned, ok := plan.add(slice, OrderActionBuy, quantity, prices[slice.Symbol], opts); ok {
+			cash = cash.Sub(planned.Value)
+		}
+	}
+
+	plan.CashAfter = cash
+	if status.Cash.IsNegative() {
+		plan.CashAfter = plan.CashAfter.Add(status.Cash)
+	}
+
+	return plan
+}
+
+// allowsSells reports whether a plan for an account of kind may sell
+func (o RebalanceOptions) allowsSells(kind AccountKind) bool {
+	return o.AllowSells || (o.AllowTaxFreeSells && kind.TaxAdvantaged())
+}
+
+// rebalanceGoal is the weight ComputeRebalancePlan trades slice towards
+func rebalanceGoal(slice SliceStatus, opts RebalanceOptions) float64 {
+	if opts.Mode == RebalanceBreaching && opts.ToBandEdge {
+		return slice.BandEdge()
+	}
+	return slice.TargetWeight
+}
+
+// sizeBuys splits budget over the buys in proportion to their deficits and
+// converts the dollars into share quantities
+func sizeBuys(deficits, prices map[string]decimal.Decimal, budget decimal.Decimal, fractional bool) map[string]decimal.Decimal {
+	var total decimal.Decimal
+	for _, deficit := range deficits {
+		total = total.Add(deficit)
+	}
+
+	targets := make(map[string]decimal.Decimal, len(deficits))
+	for symbol, deficit := range deficits {
+		targets[symbol] = deficit.Mul(budget).Div(total)
+	}
+
+	quantities := make(map[string]decimal.Decimal, len(targets))
+	if fractional {
+		for symbol, target := range targets {
+			quantities[symbol] = RoundShares(target.Div(prices[symbol]), true)
+		}
+		return quantities
+	}
+
+	wholeShares, _ := AllocateWholeShares(targets, prices, budget)
+	for symbol := range targets {
+		quantities[symbol] = decimal.NewFromInt(int64(wholeShares[symbol]))
+	}
+	return quantities
+}
+
+// belowMinimum returns why a trade is too small to place, or "" if it is not
+func belowMinimum(quantity, value decimal.Decimal, opts RebalanceOptions) string {
+	switch {
+	case !quantity.IsPositive():
+		return "less than one tradable share"
+	case value.LessThan(opts.MinTradeValue):
+		return fmt.Sprintf("value %s is below the minimum of %s", value.StringFixed(centPlaces), opts.MinTradeValue.StringFixed(centPlaces))
+	case quantity.LessThan(opts.MinTradeShares):
+		return fmt.Sprintf("%s shares is below the minimum of %s", quantity, opts.MinTradeShares)
+	default:
+		return ""
+	}
+}
+
+// skip records a trade as too small to place
+func (p *RebalancePlan) skip(symbol string, action OrderAction, quantity, price decimal.Decimal, opts RebalanceOptions) {
+	value := quantity.Mul(price)
+	p.Skipped = append(p.Skipped, SkippedTrade{
+		Symbol:   symbol,
+		Action:   action,
+		Quantity: quantity,
+		Value:    value,
+		Reason:   belowMinimum(quantity, value, opts),
+	})
+}
+
+// unpriced records a trade left out because its quote has no usable price
+func (p *RebalancePlan) unpriced(symbol string, action OrderAction) {
+	p.Skipped = append(p.Skipped, SkippedTrade{
+		Symbol: symbol,
+		Action: action,
+		Reason: "no usable price in quote",
+	})
+}
+
+// limitPrice returns the limit price offsetBps basis points past price in
+// the direction that favors a fill of an order with action
+func limitPrice(price decimal.Decimal, action OrderAction, offsetBps float64) decimal.Decimal {
+	offset := price.Mul(NewDecimal(offsetBps)).Div(basisPoints)
+	if action == OrderActionSell {
+		offset = offset.Neg()
+	}
+	return RoundCents(price.Add(offset))
+}
+
+// add appends an order for quantity shares of slice to the plan, or
+// records it as skipped when the trade is too small or the order it makes
+// is invalid, e.g. a limit price rounded down to nothing
+func (p *RebalancePlan) add(slice SliceStatus, action OrderAction, quantity, price decimal.Decimal, opts RebalanceOptions) (PlannedOrder, bool) {
+	value := quantity.Mul(price)
+	if belowMinimum(quantity, value, opts) != "" {
+		p.skip(slice.Symbol, action, quantity, price, opts)
+		return PlannedOrder{}, false
+	}
+
+	order := OrderRequest{
+		Symbol:   slice.Symbol,
+		Action:   action,
+		Type:     OrderTypeMarket,
+		Quantity: quantity,
+		Duration: OrderDurationDay,
+	}
+	if opts.OrderType == OrderTypeLimit {
+		order.Type = OrderTypeLimit
+		order.LimitPrice = DecimalPtr(limitPrice(price, action, opts.LimitOffsetBps))
+	}
+	if err := order.Validate(); err != nil {
+		p.Skipped = append(p.Skipped, SkippedTrade{
+			Symbol:   slice.Symbol,
+			Action:   action,
+			Quantity: quantity,
+			Value:    value,
+			Reason:   err.Error(),
+		})
+		return PlannedOrder{}, false
+	}
+
+	planned := PlannedOrder{Order: order, Price: price, Value: value, AssetType: slice.AssetType}
+	p.Orders = append(p.Orders, planned)
+	return planned, true
+}
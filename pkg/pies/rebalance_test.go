@@ -0,0 +1,308 @@
+package pies_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/alysoliman1/money-pies/pkg/pies/piestest"
+	"github.com/shopspring/decimal"
+)
+
+// testPie returns a pie of the symbols and weights in pairs, e.g.
+// testPie("VTI", 60, "BND", 40)
+func testPie(pairs ...any) pies.Pie {
+	pie := pies.Pie{Name: "test"}
+	for i := 0; i < len(pairs); i += 2 {
+		pie.Slices = append(pie.Slices, pies.Slice{
+			Weight: float64(pairs[i+1].(int)),
+			Asset:  pies.Asset{Symbol: pairs[i].(string)},
+		})
+	}
+	return pie
+}
+
+// dec parses s, failing the test when it is not a number
+func dec(t *testing.T, s string) decimal.Decimal {
+	t.Helper()
+
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		t.Fatalf("bad decimal %q: %v", s, err)
+	}
+	return d
+}
+
+// holdingFake returns a fake whose only account is of kind, holds cash and
+// the symbols and share quantities in holdings, e.g. "VTI", "70", each
+// quoted at piestest.DefaultPrice
+func holdingFake(t *testing.T, kind pies.AccountKind, cash string, holdings ...string) *piestest.Brokerage {
+	t.Helper()
+
+	price := decimal.NewFromInt(piestest.DefaultPrice)
+	account := piestest.Account(dec(t, cash))
+	account.Kind = kind
+
+	var positions []pies.Position
+	for i := 0; i < len(holdings); i += 2 {
+		positions = append(positions, pies.Position{
+			Symbol:       holdings[i],
+			Quantity:     dec(t, holdings[i+1]),
+			AveragePrice: price,
+		})
+	}
+
+	b := piestest.New()
+	b.AddAccount(account, positions...)
+	for _, position := range positions {
+		b.SetPrice(position.Symbol, price)
+	}
+	return b
+}
+
+// plannedTrades renders the orders of plan as "ACTION QUANTITY SYMBOL",
+// in plan order
+func plannedTrades(plan *pies.RebalancePlan) []string {
+	var trades []string
+	for _, planned := range plan.Orders {
+		trades = append(trades, string(planned.Order.Action)+" "+planned.Order.Quantity.String()+" "+planned.Order.Symbol)
+	}
+	return trades
+}
+
+func TestComputeRebalancePlan(t *testing.T) {
+	pie := testPie("VTI", 60, "BND", 40)
+
+	tests := []struct {
+		name string
+		// cash replaces the drifted portfolio's cash when set
+		cash string
+		kind pies.AccountKind
+		opts pies.RebalanceOptions
+		want []string
+	}{
+		{
+			name: "sells the overweight slice to buy the underweight one",
+			opts: pies.RebalanceOptions{AllowSells: true},
+			want: []string{"SELL 10 VTI", "BUY 10 BND"},
+		},
+		{
+			name: "without sells there is nothing to spend",
+			want: nil,
+		},
+		{
+			name: "without sells only cash funds the buys",
+			cash: "500",
+			want: []string{"BUY 5 BND"},
+		},
+		{
+			name: "tax-free sells are not allowed in a taxable account",
+			kind: pies.AccountKindCash,
+			opts: pies.RebalanceOptions{AllowTaxFreeSells: true},
+			want: nil,
+		},
+		{
+			name: "tax-free sells are allowed in an IRA",
+			kind: pies.AccountKindRothIRA,
+			opts: pies.RebalanceOptions{AllowTaxFreeSells: true},
+			want: []string{"SELL 10 VTI", "BUY 10 BND"},
+		},
+		{
+			name: "trades below the minimum value are skipped",
+			opts: pies.RebalanceOptions{AllowSells: true, MinTradeValue: decimal.NewFromInt(1500)},
+			want: nil,
+		},
+		{
+			name: "trades at the minimum value are placed",
+			opts: pies.RebalanceOptions{AllowSells: true, MinTradeValue: decimal.NewFromInt(1000)},
+			want: []string{"SELL 10 VTI", "BUY 10 BND"},
+		},
+		{
+			name: "trades below the minimum shares are skipped",
+			opts: pies.RebalanceOptions{AllowSells: true, MinTradeShares: decimal.NewFromInt(11)},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// VTI is held at 70% and BND at 30% of $10,000, all at $100
+			kind := tt.kind
+			if kind == "" {
+				kind = pies.AccountKindCash
+			}
+			cash := "0"
+			if tt.cash != "" {
+				cash = tt.cash
+			}
+			b := holdingFake(t, kind, cash, "VTI", "70", "BND", "30")
+			investor := &pies.Investor{BrokerageClient: b}
+
+			plan, err := investor.ComputeRebalancePlan(t.Context(), pie, tt.opts)
+			if err != nil {
+				t.Fatalf("ComputeRebalancePlan() error = %v", err)
+			}
+
+			got := plannedTrades(plan)
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("orders = %v, want %v", got, tt.want)
+			}
+			b.AssertCalled(t, "PlaceOrder", 0)
+		})
+	}
+}
+
+func TestComputeRebalancePlanSellsFirst(t *testing.T) {
+	// The underweight slice comes first in the pie, its buy still comes
+	// after the sell that funds it
+	pie := testPie("BND", 40, "VTI", 60)
+	b := holdingFake(t, pies.AccountKindCash, "0", "BND", "30", "VTI", "70")
+	investor := &pies.Investor{BrokerageClient: b}
+
+	plan, err := investor.ComputeRebalancePlan(t.Context(), pie, pies.RebalanceOptions{AllowSells: true})
+	if err != nil {
+		t.Fatalf("ComputeRebalancePlan() error = %v", err)
+	}
+
+	got := plannedTrades(plan)
+	want := []string{"SELL 10 VTI", "BUY 10 BND"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("orders = %v, want %v", got, want)
+	}
+	if !plan.CashAfter.IsZero() {
+		t.Errorf("CashAfter = %s, want 0", plan.CashAfter)
+	}
+}
+
+func TestComputeRebalancePlanRecordsSkippedTrades(t *testing.T) {
+	pie := testPie("VTI", 60, "BND", 40)
+	b := holdingFake(t, pies.AccountKindCash, "0", "VTI", "70", "BND", "30")
+	investor := &pies.Investor{BrokerageClient: b}
+
+	plan, err := investor.ComputeRebalancePlan(t.Context(), pie, pies.RebalanceOptions{
+		AllowSells:    true,
+		MinTradeValue: decimal.NewFromInt(1500),
+	})
+	if err != nil {
+		t.Fatalf("ComputeRebalancePlan() error = %v", err)
+	}
+
+	if len(plan.Orders) != 0 {
+		t.Errorf("orders = %v, want none", plannedTrades(plan))
+	}
+	// Without the sell's proceeds the BND buy is left without money too
+	want := []pies.SkippedTrade{
+		{Symbol: "VTI", Action: pies.OrderActionSell, Quantity: decimal.NewFromInt(10), Value: decimal.NewFromInt(1000),
+			Reason: "value 1000.00 is below the minimum of 1500.00"},
+		{Symbol: "BND", Action: pies.OrderActionBuy, Quantity: decimal.Zero, Value: decimal.Zero,
+			Reason: "less than one tradable share"},
+	}
+	if len(plan.Skipped) != len(want) {
+		t.Fatalf("skipped = %+v, want %+v", plan.Skipped, want)
+	}
+	for i, got := range plan.Skipped {
+		if got.Symbol != want[i].Symbol || got.Action != want[i].Action || !got.Quantity.Equal(want[i].Quantity) ||
+			!got.Value.Equal(want[i].Value) || got.Reason != want[i].Reason {
+			t.Errorf("skipped[%d] = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestMinTradeValueRedistributesBuys(t *testing.T) {
+	// On target with $1,000 of new cash, the buys are $500 of A, $300 of B
+	// and $200 of C
+	pie := testPie("A", 50, "B", 30, "C", 20)
+
+	tests := []struct {
+		name     string
+		minValue int64
+		want     []string
+		skipped  []string // "SYMBOL VALUE", in the order they were skipped
+	}{
+		{
+			name: "no minimum",
+			want: []string{"BUY 5 A", "BUY 3 B", "BUY 2 C"},
+		},
+		{
+			// Dropping C spreads its $200 over A and B, which lifts B to
+			// $400, over the minimum it started below
+			name:     "freed dollars lift the next smallest buy",
+			minValue: 350,
+			want:     []string{"BUY 6 A", "BUY 4 B"},
+			skipped:  []string{"C 200"},
+		},
+		{
+			// B is still short of the minimum with C's dollars, so its
+			// dollars go to A as well
+			name:     "drops cascade until every buy is large enough",
+			minValue: 450,
+			want:     []string{"BUY 10 A"},
+			skipped:  []string{"C 200", "B 400"},
+		},
+		{
+			name:     "every buy below the minimum leaves an empty plan",
+			minValue: 1001,
+			want:     nil,
+			skipped:  []string{"C 200", "B 400", "A 1000"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := holdingFake(t, pies.AccountKindCash, "1000", "A", "50", "B", "30", "C", "20")
+			investor := &pies.Investor{BrokerageClient: b}
+
+			plan, err := investor.ComputeRebalancePlan(t.Context(), pie, pies.RebalanceOptions{
+				MinTradeValue: decimal.NewFromInt(tt.minValue),
+			})
+			if err != nil {
+				t.Fatalf("ComputeRebalancePlan() error = %v", err)
+			}
+
+			if got := plannedTrades(plan); strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("orders = %v, want %v", got, tt.want)
+			}
+			var skipped []string
+			for _, trade := range plan.Skipped {
+				skipped = append(skipped, trade.Symbol+" "+trade.Value.String())
+			}
+			if strings.Join(skipped, ", ") != strings.Join(tt.skipped, ", ") {
+				t.Errorf("skipped = %v, want %v", skipped, tt.skipped)
+			}
+
+			// Whatever is not bought stays in cash
+			var spent decimal.Decimal
+			for _, planned := range plan.Orders {
+				spent = spent.Add(planned.Value)
+			}
+			if want := decimal.NewFromInt(1000).Sub(spent); !plan.CashAfter.Equal(want) {
+				t.Errorf("CashAfter = %s, want %s", plan.CashAfter, want)
+			}
+		})
+	}
+}
+
+func TestMinTradeSharesLeavesSellsInPlace(t *testing.T) {
+	// Selling 10 VTI funds the BND buy; below the minimum neither is placed
+	// and the VTI shares stay held
+	pie := testPie("VTI", 60, "BND", 40)
+	b := holdingFake(t, pies.AccountKindCash, "0", "VTI", "70", "BND", "30")
+	investor := &pies.Investor{BrokerageClient: b}
+
+	plan, err := investor.ComputeRebalancePlan(t.Context(), pie, pies.RebalanceOptions{
+		AllowSells:     true,
+		MinTradeShares: decimal.NewFromInt(11),
+	})
+	if err != nil {
+		t.Fatalf("ComputeRebalancePlan() error = %v", err)
+	}
+	if len(plan.Orders) != 0 {
+		t.Errorf("orders = %v, want none", plannedTrades(plan))
+	}
+	if len(plan.Skipped) == 0 || plan.Skipped[0].Reason != "10 shares is below the minimum of 11" {
+		t.Errorf("skipped = %+v, want the VTI sell below the minimum shares", plan.Skipped)
+	}
+	if !plan.CashAfter.IsZero() {
+		t.Errorf("CashAfter = %s, want the unchanged 0", plan.CashAfter)
+	}
+}
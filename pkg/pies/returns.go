@@ -0,0 +1,284 @@
+package pies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// priceLookback is how far before the start of a return period price
+// history is fetched, so that a period starting on a weekend or holiday
+// finds the last close before it
+const priceLookback = 10 * 24 * time.Hour
+
+// PriceHistorySource is implemented by brokerages that provide historical
+// prices
+type PriceHistorySource interface {
+	// GetDailyCandles returns the daily candles of symbol from start to
+	// end, oldest first
+	GetDailyCandles(ctx context.Context, symbol string, start, end time.Time) ([]Candle, error)
+}
+
+// ReturnOptions selects the period GetPieStatusWithReturns reports returns
+// over and the benchmarks it compares the pie with
+type ReturnOptions struct {
+	Since      time.Time // Start of the period, required
+	Benchmarks []string  // Compared with in addition to the pie's own Benchmarks
+}
+
+// PeriodReturn is the money-weighted return of a pie, or of a benchmark
+// bought and sold with the same contributions as the pie, over a period
+type PeriodReturn struct {
+	Symbol        string          `json:"symbol"` // The pie's name for the pie's own return
+	Benchmark     bool            `json:"benchmark"`
+	Start         time.Time       `json:"start"`
+	End           time.Time       `json:"end"`
+	StartValue    decimal.Decimal `json:"start_value"`
+	EndValue      decimal.Decimal `json:"end_value"`
+	Contributions decimal.Decimal `json:"contributions"` // Money put in during the period less money taken out
+	Annualized    float64         `json:"annualized"`    // Internal rate of return per year, 0.05 for 5%
+	Cumulative    float64         `json:"cumulative"`    // Annualized compounded over the period
+}
+
+// cashFlow is money moved into a holding, negative when taken out
+type cashFlow struct {
+	Time   time.Time
+	Amount decimal.Decimal
+}
+
+// GetPieStatusWithReturns is GetPieStatus with the money-weighted return
+// of the pie since opts.Since, followed by those of its benchmarks, in
+// Returns. Returns are internal rates of return (XIRR) over the pie's
+// holdings valued at daily closes, so that money put in or taken out does
+// not count as gains or losses. Each benchmark is bought with the pie's
+// value at the start of the period and bought or sold whenever the pie
+// was, so that it saw the same contributions.
+//
+// With a PositionLedger the pie is compared with the shares the ledger
+// attributes to it, as in GetPieStatusAttributed, and every fill the ledger
+// records during the period is a contribution. Without one the shares held
+// now are taken to have been held throughout the period. Cash slices and
+// dividends are left out. The brokerage client must implement
+// PriceHistorySource.
+func (i *Investor) GetPieStatusWithReturns(ctx context.Context, pie Pie, opts ReturnOptions) (*PieStatus, error) {
+	history, ok := i.BrokerageClient.(PriceHistorySource)
+	if !ok {
+		return nil, errors.New("brokerage does not provide price history")
+	}
+	end := time.Now()
+	if opts.Since.IsZero() || !opts.Since.Before(end) {
+		return nil, errors.New("returns need a start date in the past")
+	}
+
+	getStatus := i.GetPieStatus
+	if i.Ledger != nil {
+		getStatus = i.GetPieStatusAttributed
+	}
+	status, err := getStatus(ctx, pie)
+	if err != nil {
+		return nil, err
+	}
+
+	// Shares held at the start of the period, and contributions after it
+	startShares := make(map[string]decimal.Decimal)
+	var flows []cashFlow
+	if i.Ledger != nil {
+		entries, err := i.Ledger.Entries(status.Account.ID())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read position ledger: %w", err)
+		}
+		for _, entry := range entries {
+			switch {
+			case entry.Pie != pie.Name:
+			case entry.Time.After(opts.Since):
+				flows = append(flows, cashFlow{Time: entry.Time, Amount: entry.Quantity.Mul(entry.Price)})
+			default:
+				symbol := normalizeSymbol(entry.Symbol)
+				startShares[symbol] = startShares[symbol].Add(entry.Quantity)
+			}
+		}
+	} else {
+		for _, slice := range status.Slices {
+			if !slice.IsCash {
+				startShares[slice.Symbol] = slice.Quantity
+			}
+		}
+	}
+	sort.SliceStable(flows, func(a, b int) bool {
+		return flows[a].Time.Before(flows[b].Time)
+	})
+
+	closes := func(symbol string) ([]Candle, error) {
+		candles, err := history.GetDailyCandles(ctx, symbol, opts.Since.Add(-priceLookback), end)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get price history for %s: %w", symbol, err)
+		}
+		return candles, nil
+	}
+
+	pieReturn := PeriodReturn{Symbol: pie.Name, Start: opts.Since, End: end}
+	for symbol, shares := range startShares {
+		if shares.Abs().LessThanOrEqual(shareTolerance) {
+			continue
+		}
+		candles, err := closes(symbol)
+		if err != nil {
+			return nil, err
+		}
+		price, err := closeOn(symbol, candles, opts.Since)
+		if err != nil {
+			return nil, err
+		}
+		pieReturn.StartValue = pieReturn.StartValue.Add(shares.Mul(price))
+	}
+	for _, slice := range status.Slices {
+		if !slice.IsCash {
+			pieReturn.EndValue = pieReturn.EndValue.Add(slice.Value)
+		}
+	}
+	if err := pieReturn.compute(flows); err != nil {
+		return nil, fmt.Errorf("failed to compute the return of %s: %w", pie.Name, err)
+	}
+	status.Returns = append(status.Returns, pieReturn)
+
+	for _, symbol := range benchmarks(pie, opts) {
+		candles, err := closes(symbol)
+		if err != nil {
+			return nil, err
+		}
+		benchmark, err := benchmarkReturn(symbol, candles, pieReturn, flows)
+		if err != nil {
+			return nil, err
+		}
+		status.Returns = append(status.Returns, benchmark)
+	}
+	return status, nil
+}
+
+// benchmarks returns the pie's benchmarks and those of opts, without
+// duplicates
+func benchmarks(pie Pie, opts ReturnOptions) []string {
+	var symbols []string
+	for _, symbol := range append(slices.Clone(pie.Benchmarks), opts.Benchmarks...) {
+		if symbol = normalizeSymbol(symbol); symbol != "" && !slices.Contains(symbols, symbol) {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
+}
+
+// benchmarkReturn returns the return of buying symbol with the pie's value
+// at the start of the period and trading it with the pie's contributions
+func benchmarkReturn(symbol string, candles []Candle, pie PeriodReturn, flows []cashFlow) (PeriodReturn, error) {
+	benchmark := PeriodReturn{
+		Symbol:     symbol,
+		Benchmark:  true,
+		Start:      pie.Start,
+		End:        pie.End,
+		StartValue: pie.StartValue,
+	}
+
+	price, err := closeOn(symbol, candles, pie.Start)
+	if err != nil {
+		return PeriodReturn{}, err
+	}
+	shares := pie.StartValue.Div(price)
+	for _, flow := range flows {
+		if price, err = closeOn(symbol, candles, flow.Time); err != nil {
+			return PeriodReturn{}, err
+		}
+		shares = shares.Add(flow.Amount.Div(price))
+	}
+	if price, err = closeOn(symbol, candles, pie.End); err != nil {
+		return PeriodReturn{}, err
+	}
+	benchmark.EndValue = shares.Mul(price)
+
+	if err := benchmark.compute(flows); err != nil {
+		return PeriodReturn{}, fmt.Errorf("failed to compute the return of %s: %w", symbol, err)
+	}
+	return benchmark, nil
+}
+
+// closeOn returns the close of the last candle starting on or before t
+func closeOn(symbol string, candles []Candle, t time.Time) (decimal.Decimal, error) {
+	idx := sort.Search(len(candles), func(i int) bool {
+		return candles[i].Time.After(t)
+	})
+	if idx == 0 || candles[idx-1].Close <= 0 {
+		return decimal.Zero, fmt.Errorf("no price for %s on %s", symbol, t.Format(time.DateOnly))
+	}
+	return NewDecimal(candles[idx-1].Close), nil
+}
+
+// compute sets the return's contributions and rates from its start and end
+// values and the contributions made between them
+func (r *PeriodReturn) compute(flows []cashFlow) error {
+	if !r.StartValue.IsPositive() && len(flows) == 0 {
+		return errors.New("nothing was invested during the period")
+	}
+
+	// The investor pays the start value and each contribution, and gets the
+	// end value back
+	investor := make([]cashFlow, 0, len(flows)+2)
+	investor = append(investor, cashFlow{Time: r.Start, Amount: r.StartValue.Neg()})
+	for _, flow := range flows {
+		r.Contributions = r.Contributions.Add(flow.Amount)
+		investor = append(investor, cashFlow{Time: flow.Time, Amount: flow.Amount.Neg()})
+	}
+	investor = append(investor, cashFlow{Time: r.End, Amount: r.EndValue})
+
+	rate, err := xirr(investor)
+	if err != nil {
+		return err
+	}
+	r.Annualized = rate
+	r.Cumulative = math.Pow(1+rate, years(r.Start, r.End)) - 1
+	return nil
+}
+
+// xirr returns the annual rate at which the flows' net present value at
+// the first flow's time is zero, found by bisection. The search runs on
+// float64 since discounting is not exact anyway.
+func xirr(flows []cashFlow) (float64, error) {
+	npv := func(rate float64) float64 {
+		var value float64
+		for _, flow := range flows {
+			value += flow.Amount.InexactFloat64() / math.Pow(1+rate, years(flows[0].Time, flow.Time))
+		}
+		return value
+	}
+
+	low, high := -0.9999, 1.0
+	lowValue := npv(low)
+	for math.Signbit(npv(high)) == math.Signbit(lowValue) {
+		if high >= 1e6 {
+			return 0, errors.New("no rate of return fits the cash flows")
+		}
+		high *= 10
+	}
+
+	for range 200 {
+		mid := (low + high) / 2
+		if math.Signbit(npv(mid)) == math.Signbit(lowValue) {
+			low = mid
+		} else {
+			high = mid
+		}
+		if high-low < 1e-12 {
+			break
+		}
+	}
+	return (low + high) / 2, nil
+}
+
+// years returns the time from start to end in years of 365 days
+func years(start, end time.Time) float64 {
+	return end.Sub(start).Hours() / (24 * 365)
+}
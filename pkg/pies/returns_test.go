@@ -0,0 +1,192 @@
+package pies
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// year is a year of the 365 days returns are computed with
+const year = 365 * 24 * time.Hour
+
+// flow returns a cash flow of amount days after start
+func flow(start time.Time, days float64, amount int64) cashFlow {
+	return cashFlow{Time: start.Add(time.Duration(days * float64(24*time.Hour))), Amount: decimal.NewFromInt(amount)}
+}
+
+func TestPeriodReturnCompute(t *testing.T) {
+	start := time.Date(2024, 1, 2, 21, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		end            time.Duration
+		startValue     int64
+		endValue       int64
+		flows          []cashFlow
+		wantAnnualized float64
+		wantCumulative float64
+		wantFlows      int64
+	}{
+		{
+			// 1000 × 1.1 = 1100
+			name:           "one year without contributions",
+			end:            year,
+			startValue:     1000,
+			endValue:       1100,
+			wantAnnualized: 0.1,
+			wantCumulative: 0.1,
+		},
+		{
+			// 1000 × 1.1² = 1210
+			name:           "two years without contributions",
+			end:            2 * year,
+			startValue:     1000,
+			endValue:       1210,
+			wantAnnualized: 0.1,
+			wantCumulative: 0.21,
+		},
+		{
+			// 1.05² - 1
+			name:           "half a year annualized",
+			end:            year / 2,
+			startValue:     1000,
+			endValue:       1050,
+			wantAnnualized: 0.1025,
+			wantCumulative: 0.05,
+		},
+		{
+			name:           "a loss",
+			end:            year,
+			startValue:     1000,
+			endValue:       500,
+			wantAnnualized: -0.5,
+			wantCumulative: -0.5,
+		},
+		{
+			// 1000 × 1.1² + 1000 × 1.1 = 1210 + 1100
+			name:           "a contribution after a year",
+			end:            2 * year,
+			startValue:     1000,
+			endValue:       2310,
+			flows:          []cashFlow{flow(start, 365, 1000)},
+			wantAnnualized: 0.1,
+			wantCumulative: 0.21,
+			wantFlows:      1000,
+		},
+		{
+			// 1000 × 1.1² - 550 × 1.1 = 1210 - 605
+			name:           "a withdrawal after a year",
+			end:            2 * year,
+			startValue:     1000,
+			endValue:       605,
+			flows:          []cashFlow{flow(start, 365, -550)},
+			wantAnnualized: 0.1,
+			wantCumulative: 0.21,
+			wantFlows:      -550,
+		},
+		{
+			// 1000 doubles in the first year and halves in the second, so
+			// the time-weighted return is 0. The 9000 put in before the
+			// loss weighs it down: 1000 × (1+r)² + 9000 × (1+r) = 5500
+			// gives r = (-9000 + √(9000² + 4 × 1000 × 5500)) / 2000 - 1.
+			name:           "a contribution before a loss",
+			end:            2 * year,
+			startValue:     1000,
+			endValue:       5500,
+			flows:          []cashFlow{flow(start, 365, 9000)},
+			wantAnnualized: (-9000+math.Sqrt(9000*9000+4*1000*5500))/2000 - 1,
+			wantCumulative: math.Pow((-9000+math.Sqrt(9000*9000+4*1000*5500))/2000, 2) - 1,
+			wantFlows:      9000,
+		},
+		{
+			// Everything is put in halfway and grows 4.9% in half a year
+			name:           "nothing at the start",
+			end:            year,
+			endValue:       1049,
+			flows:          []cashFlow{flow(start, 182.5, 1000)},
+			wantAnnualized: math.Pow(1.049, 2) - 1,
+			wantCumulative: math.Pow(1.049, 2) - 1,
+			wantFlows:      1000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := PeriodReturn{
+				Start:      start,
+				End:        start.Add(tt.end),
+				StartValue: decimal.NewFromInt(tt.startValue),
+				EndValue:   decimal.NewFromInt(tt.endValue),
+			}
+			if err := r.compute(tt.flows); err != nil {
+				t.Fatalf("compute() error = %v", err)
+			}
+			if math.Abs(r.Annualized-tt.wantAnnualized) > 1e-9 {
+				t.Errorf("Annualized = %v, want %v", r.Annualized, tt.wantAnnualized)
+			}
+			if math.Abs(r.Cumulative-tt.wantCumulative) > 1e-9 {
+				t.Errorf("Cumulative = %v, want %v", r.Cumulative, tt.wantCumulative)
+			}
+			if !r.Contributions.Equal(decimal.NewFromInt(tt.wantFlows)) {
+				t.Errorf("Contributions = %s, want %d", r.Contributions, tt.wantFlows)
+			}
+		})
+	}
+}
+
+func TestPeriodReturnComputeErrors(t *testing.T) {
+	start := time.Date(2024, 1, 2, 21, 0, 0, 0, time.UTC)
+
+	r := PeriodReturn{Start: start, End: start.Add(year)}
+	if err := r.compute(nil); err == nil || err.Error() != "nothing was invested during the period" {
+		t.Errorf("compute() with nothing invested error = %v", err)
+	}
+
+	// Money taken out that was never put in fits no rate
+	r = PeriodReturn{Start: start, End: start.Add(year), EndValue: decimal.NewFromInt(100)}
+	if err := r.compute([]cashFlow{flow(start, 100, -100)}); err == nil {
+		t.Error("compute() of flows without a sign change error = nil")
+	}
+}
+
+func TestBenchmarkReturn(t *testing.T) {
+	start := time.Date(2024, 1, 2, 21, 0, 0, 0, time.UTC)
+	// Closes at the start of each day, with no candle on the days the
+	// flows and the end fall on
+	candles := []Candle{
+		{Time: start.Add(-24 * time.Hour), Close: 100},
+		{Time: start.Add(364 * 24 * time.Hour), Close: 110},
+		{Time: start.Add(729 * 24 * time.Hour), Close: 121},
+	}
+	pie := PeriodReturn{
+		Symbol:     "Core",
+		Start:      start,
+		End:        start.Add(2 * year),
+		StartValue: decimal.NewFromInt(1000),
+	}
+	flows := []cashFlow{flow(start, 365, 1100)}
+
+	// 10 shares at 100 and 10 more at 110 are worth 2420 at 121, which is
+	// 10% a year like the benchmark itself
+	got, err := benchmarkReturn("VT", candles, pie, flows)
+	if err != nil {
+		t.Fatalf("benchmarkReturn() error = %v", err)
+	}
+	if !got.Benchmark || got.Symbol != "VT" || !got.Start.Equal(pie.Start) || !got.End.Equal(pie.End) {
+		t.Errorf("benchmarkReturn() = %+v, want VT over the pie's period", got)
+	}
+	if !got.StartValue.Equal(decimal.NewFromInt(1000)) || !got.EndValue.Equal(decimal.NewFromInt(2420)) {
+		t.Errorf("values = %s to %s, want 1000 to 2420", got.StartValue, got.EndValue)
+	}
+	if math.Abs(got.Annualized-0.1) > 1e-9 || math.Abs(got.Cumulative-0.21) > 1e-9 {
+		t.Errorf("Annualized = %v, Cumulative = %v, want 0.1 and 0.21", got.Annualized, got.Cumulative)
+	}
+
+	// A period starting before the first close has no price
+	pie.Start = start.Add(-48 * time.Hour)
+	if _, err := benchmarkReturn("VT", candles, pie, flows); err == nil || err.Error() != "no price for VT on 2023-12-31" {
+		t.Errorf("benchmarkReturn() before the first close error = %v", err)
+	}
+}
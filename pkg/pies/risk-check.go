@@ -0,0 +1,344 @@
+package pies
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+)
+
+// The rules of RiskLimits, as named in risk files and RiskViolation.Rule
+const (
+	RiskMaxOrderValue     = "max_order_value"
+	RiskMaxOrderQuantity  = "max_order_quantity"
+	RiskMaxOrderPercent   = "max_order_percent"
+	RiskMaxConcentration  = "max_concentration"
+	RiskMaxPriceDeviation = "max_price_deviation"
+)
+
+// RiskRule is one limit of RiskLimits. An order breaches it when its value
+// is above Limit; an order exactly at the limit passes.
+type RiskRule struct {
+	Limit float64 `json:"limit" yaml:"limit"`                   // Zero disables the rule
+	Warn  bool    `json:"warn,omitempty" yaml:"warn,omitempty"` // Report breaches as warnings instead of refusing the order
+}
+
+// RiskLimits are the pre-trade checks of a RiskChecker, to catch
+// fat-fingered or outsized orders before they reach the brokerage. In a risk
+// file, or under the "risk" key of a pie file:
+//
+//	{
+//	  "max_order_value": {"limit": 10000},
+//	  "max_order_quantity": {"limit": 500},
+//	  "max_order_percent": {"limit": 25},
+//	  "max_concentration": {"limit": 40, "warn": true},
+//	  "max_price_deviation": {"limit": 5}
+//	}
+type RiskLimits struct {
+	// MaxOrderValue is the estimated dollar value of one order
+	MaxOrderValue RiskRule `json:"max_order_value,omitzero" yaml:"max_order_value,omitempty"`
+	// MaxOrderQuantity is the number of shares of one order
+	MaxOrderQuantity RiskRule `json:"max_order_quantity,omitzero" yaml:"max_order_quantity,omitempty"`
+	// MaxOrderPercent is the value of one order as a percentage of the
+	// account's total value
+	MaxOrderPercent RiskRule `json:"max_order_percent,omitzero" yaml:"max_order_percent,omitempty"`
+	// MaxConcentration is the percentage of the account's total value held
+	// in one symbol once a buy fills. Sells are not checked, as they only
+	// lower it.
+	MaxConcentration RiskRule `json:"max_concentration,omitzero" yaml:"max_concentration,omitempty"`
+	// MaxPriceDeviation is how far, in percent of the quote, the limit or
+	// stop price of an order may be from the quote
+	MaxPriceDeviation RiskRule `json:"max_price_deviation,omitzero" yaml:"max_price_deviation,omitempty"`
+}
+
+// rules returns the limits' rules with their names, in the order they are
+// checked
+func (l *RiskLimits) rules() []struct {
+	name string
+	rule *RiskRule
+} {
+	return []struct {
+		name string
+		rule *RiskRule
+	}{
+		{RiskMaxOrderValue, &l.MaxOrderValue},
+		{RiskMaxOrderQuantity, &l.MaxOrderQuantity},
+		{RiskMaxOrderPercent, &l.MaxOrderPercent},
+		{RiskMaxConcentration, &l.MaxConcentration},
+		{RiskMaxPriceDeviation, &l.MaxPriceDeviation},
+	}
+}
+
+// validate checks that no limit is negative
+func (l RiskLimits) validate() error {
+	for _, r := range l.rules() {
+		if !(r.rule.Limit >= 0) {
+			return fmt.Errorf("risk limit %s must not be negative, got %v", r.name, r.rule.Limit)
+		}
+	}
+	return nil
+}
+
+// Merge returns l with the rules that override sets, those with a Limit,
+// replacing l's. A rule is unset with a zero Limit, so override cannot
+// turn one of l's off.
+func (l RiskLimits) Merge(override RiskLimits) RiskLimits {
+	merged := l
+	overrides := override.rules()
+	for i, r := range merged.rules() {
+		if overrides[i].rule.Limit > 0 {
+			*r.rule = *overrides[i].rule
+		}
+	}
+	return merged
+}
+
+// LoadRiskLimits reads RiskLimits from path. The format is chosen like
+// LoadPie's.
+func LoadRiskLimits(path string) (RiskLimits, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return RiskLimits{}, fmt.Errorf("failed to read risk file: %w", err)
+	}
+
+	var limits RiskLimits
+	if isYAMLPieFile(path, raw) {
+		decoder := yaml.NewDecoder(bytes.NewReader(raw))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&limits)
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&limits)
+	}
+	if err != nil {
+		return RiskLimits{}, fmt.Errorf("failed to parse risk file %s: %w", path, err)
+	}
+	if err := limits.validate(); err != nil {
+		return RiskLimits{}, fmt.Errorf("invalid risk file %s: %w", path, err)
+	}
+	return limits, nil
+}
+
+// RiskViolation is an order breaching a rule of RiskLimits
+type RiskViolation struct {
+	Rule    string      `json:"rule"` // One of the Risk constants
+	Symbol  string      `json:"symbol"`
+	Action  OrderAction `json:"action"`
+	Message string      `json:"message"`
+	Warning bool        `json:"warning,omitempty"` // The rule only warns, the order may still be placed
+}
+
+// RiskError lists the breaches of rules that refuse orders
+type RiskError struct {
+	Violations []RiskViolation
+}
+
+func (e *RiskError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Message)
+	}
+	return "orders failed the risk checks: " + strings.Join(messages, "; ")
+}
+
+// RiskExposure is what an account is worth and holds, for the rules
+// relative to its size
+type RiskExposure struct {
+	AccountValue decimal.Decimal
+	Holdings     map[string]decimal.Decimal // Market value by upper-case symbol
+}
+
+// NewRiskExposure returns the exposure of account holding positions
+func NewRiskExposure(account Account, positions []Position) RiskExposure {
+	exposure := RiskExposure{
+		AccountValue: account.TotalValue,
+		Holdings:     make(map[string]decimal.Decimal, len(positions)),
+	}
+	for _, position := range positions {
+		symbol := normalizeSymbol(position.Symbol)
+		exposure.Holdings[symbol] = exposure.Holdings[symbol].Add(position.MarketValue)
+	}
+	return exposure
+}
+
+// RiskChecker checks orders against its Limits before they are placed. The
+// rules relative to the account's size are skipped for accounts without a
+// positive total value.
+type RiskChecker struct {
+	Limits RiskLimits
+}
+
+// NewRiskChecker returns a checker of limits, with the rules set by the
+// pie's Risk taking precedence, or nil when neither sets any rule
+func NewRiskChecker(limits RiskLimits, pie Pie) *RiskChecker {
+	if pie.Risk != nil {
+		limits = limits.Merge(*pie.Risk)
+	}
+	if limits == (RiskLimits{}) {
+		return nil
+	}
+	return &RiskChecker{Limits: limits}
+}
+
+// CheckOrder returns the rules order breaches, warnings among them, when
+// the symbol trades at quote. Market orders are valued at quote and limit
+// orders at their limit price.
+func (c *RiskChecker) CheckOrder(order OrderRequest, quote decimal.Decimal, exposure RiskExposure) []RiskViolation {
+	quantity, value := orderSize(order, quote)
+
+	var violations []RiskViolation
+	add := func(name string, rule RiskRule, format string, args ...any) {
+		violations = append(violations, RiskViolation{
+			Rule:    name,
+			Symbol:  order.Symbol,
+			Action:  order.Action,
+			Message: fmt.Sprintf("%s %s: ", order.Action, order.Symbol) + fmt.Sprintf(format, args...),
+			Warning: rule.Warn,
+		})
+	}
+	limits := c.Limits
+	accountValue := exposure.AccountValue
+
+	if rule := limits.MaxOrderValue; rule.Limit > 0 && value.GreaterThan(NewDecimal(rule.Limit)) {
+		add(RiskMaxOrderValue, rule, "estimated value %s is above the %s limit of %s",
+			value.StringFixed(2), RiskMaxOrderValue, NewDecimal(rule.Limit).StringFixed(2))
+	}
+	if rule := limits.MaxOrderQuantity; rule.Limit > 0 && quantity.GreaterThan(NewDecimal(rule.Limit)) {
+		add(RiskMaxOrderQuantity, rule, "%s shares are above the %s limit of %s",
+			quantity.Round(fractionalPrecision), RiskMaxOrderQuantity, NewDecimal(rule.Limit))
+	}
+	if rule := limits.MaxOrderPercent; rule.Limit > 0 && accountValue.IsPositive() &&
+		value.GreaterThan(weightOf(accountValue, rule.Limit)) {
+		add(RiskMaxOrderPercent, rule, "%.2f%% of the account's value is above the %s limit of %v%%",
+			percentOf(value, accountValue), RiskMaxOrderPercent, rule.Limit)
+	}
+	if rule := limits.MaxConcentration; rule.Limit > 0 && accountValue.IsPositive() && order.Action == OrderActionBuy {
+		held := exposure.Holdings[normalizeSymbol(order.Symbol)].Add(value)
+		if held.GreaterThan(weightOf(accountValue, rule.Limit)) {
+			add(RiskMaxConcentration, rule, "would hold %.2f%% of the account's value, above the %s limit of %v%%",
+				percentOf(held, accountValue), RiskMaxConcentration, rule.Limit)
+		}
+	}
+	if rule := limits.MaxPriceDeviation; rule.Limit > 0 && quote.IsPositive() {
+		for _, p := range []struct {
+			kind  string
+			price *decimal.Decimal
+		}{{"limit", order.LimitPrice}, {"stop", order.StopPrice}} {
+			if p.price == nil || !p.price.Sub(quote).Abs().GreaterThan(weightOf(quote, rule.Limit)) {
+				continue
+			}
+			add(RiskMaxPriceDeviation, rule, "%s price %s is %.2f%% from the quote of %s, above the %s limit of %v%%",
+				p.kind, p.price, percentOf(p.price.Sub(quote).Abs(), quote), quote, RiskMaxPriceDeviation, rule.Limit)
+		}
+	}
+	return violations
+}
+
+// orderSize returns the shares and value of order when the symbol trades at
+// quote. Market orders are valued at quote and limit orders at their limit
+// price.
+func orderSize(order OrderRequest, quote decimal.Decimal) (decimal.Decimal, decimal.Decimal) {
+	price := quote
+	if order.LimitPrice != nil {
+		price = *order.LimitPrice
+	}
+	quantity, value := order.Quantity, order.Quantity.Mul(price)
+	if order.Amount != nil {
+		value = *order.Amount
+		if price.IsPositive() {
+			quantity = order.Amount.Div(price)
+		}
+	}
+	return quantity, value
+}
+
+// CheckPlan checks the plan's orders, applying them to the exposure as it
+// goes. Each order is priced at its symbol's quote in quotes, the ask for
+// buys and the bid for sells, or at the price it was sized at when quotes
+// has none for it. The warnings are returned; breaches of rules that refuse
+// orders are returned, every one of them, as a *RiskError.
+func (c *RiskChecker) CheckPlan(plan *RebalancePlan, quotes map[string]Quote, exposure RiskExposure) ([]RiskViolation, error) {
+	exposure.Holdings = maps.Clone(exposure.Holdings)
+	if exposure.Holdings == nil {
+		exposure.Holdings = map[string]decimal.Decimal{}
+	}
+
+	var warnings, failures []RiskViolation
+	for _, planned := range plan.Orders {
+		symbol := normalizeSymbol(planned.Order.Symbol)
+		price := quotePrice(quotes[symbol], planned.Order.Action, false)
+		if !price.IsPositive() {
+			price = planned.Price
+		}
+
+		for _, violation := range c.CheckOrder(planned.Order, price, exposure) {
+			if violation.Warning {
+				warnings = append(warnings, violation)
+			} else {
+				failures = append(failures, violation)
+			}
+		}
+
+		_, value := orderSize(planned.Order, price)
+		if planned.Order.Action == OrderActionSell {
+			exposure.Holdings[symbol] = exposure.Holdings[symbol].Sub(value)
+		} else {
+			exposure.Holdings[symbol] = exposure.Holdings[symbol].Add(value)
+		}
+	}
+	if len(failures) > 0 {
+		return warnings, &RiskError{Violations: failures}
+	}
+	return warnings, nil
+}
+
+// CheckRisk checks the plan's orders with the investor's Risk checker
+// against the plan's account as it is now. It returns nothing when the
+// investor has no checker.
+func (i *Investor) CheckRisk(ctx context.Context, plan *RebalancePlan) ([]RiskViolation, error) {
+	if i.Risk == nil {
+		return nil, nil
+	}
+	account, err := i.findAccount(ctx, plan.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	return i.checkRisk(ctx, plan, account)
+}
+
+// checkRisk is CheckRisk for the plan's account, already fetched. The
+// orders are checked at fresh quotes, since prices may have moved since the
+// plan was computed.
+func (i *Investor) checkRisk(ctx context.Context, plan *RebalancePlan, account Account) ([]RiskViolation, error) {
+	positions, err := i.BrokerageClient.GetPositions(ctx, plan.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions for the risk checks: %w", err)
+	}
+
+	// Orders without a fresh quote are checked at their planned price
+	quotes := make(map[string]Quote)
+	if len(plan.Orders) > 0 {
+		symbols := make([]string, 0, len(plan.Orders))
+		for _, planned := range plan.Orders {
+			symbols = append(symbols, normalizeSymbol(planned.Order.Symbol))
+		}
+		fetched, err := QuoteFetcher{Client: i.BrokerageClient, Concurrency: i.QuoteConcurrency}.Fetch(ctx, symbols)
+		var missingErr *MissingQuotesError
+		if err != nil && !errors.As(err, &missingErr) {
+			return nil, fmt.Errorf("failed to get quotes for the risk checks: %w", err)
+		}
+		for symbol, quote := range fetched {
+			quotes[normalizeSymbol(symbol)] = quote
+		}
+	}
+
+	return i.Risk.CheckPlan(plan, quotes, NewRiskExposure(account, positions))
+}
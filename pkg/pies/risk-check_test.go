@@ -0,0 +1,407 @@
+package pies_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/brokerages/paper"
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/alysoliman1/money-pies/pkg/pies/piestest"
+	"github.com/shopspring/decimal"
+)
+
+// riskExposure returns the exposure of a $10,000 account holding $3,000 of
+// VTI
+func riskExposure(t *testing.T) pies.RiskExposure {
+	return pies.RiskExposure{
+		AccountValue: dec(t, "10000"),
+		Holdings:     map[string]decimal.Decimal{"VTI": dec(t, "3000")},
+	}
+}
+
+func TestRiskCheckerRuleBoundaries(t *testing.T) {
+	// order returns a market buy of quantity VTI changed by edit
+	order := func(quantity string, edit func(o *pies.OrderRequest)) pies.OrderRequest {
+		o := pies.OrderRequest{
+			Symbol:   "VTI",
+			Action:   pies.OrderActionBuy,
+			Type:     pies.OrderTypeMarket,
+			Quantity: dec(t, quantity),
+		}
+		edit(&o)
+		return o
+	}
+	noEdit := func(o *pies.OrderRequest) {}
+	amount := func(s string) func(o *pies.OrderRequest) {
+		return func(o *pies.OrderRequest) { o.Quantity = decimal.Zero; o.Amount = pies.DecimalPtr(dec(t, s)) }
+	}
+	limit := func(s string) func(o *pies.OrderRequest) {
+		return func(o *pies.OrderRequest) { o.Type = pies.OrderTypeLimit; o.LimitPrice = pies.DecimalPtr(dec(t, s)) }
+	}
+	stop := func(s string) func(o *pies.OrderRequest) {
+		return func(o *pies.OrderRequest) { o.Type = pies.OrderTypeStop; o.StopPrice = pies.DecimalPtr(dec(t, s)) }
+	}
+	sell := func(o *pies.OrderRequest) { o.Action = pies.OrderActionSell }
+	symbol := func(s string) func(o *pies.OrderRequest) {
+		return func(o *pies.OrderRequest) { o.Symbol = s }
+	}
+
+	value := pies.RiskLimits{MaxOrderValue: pies.RiskRule{Limit: 1000}}
+	quantity := pies.RiskLimits{MaxOrderQuantity: pies.RiskRule{Limit: 10}}
+	percent := pies.RiskLimits{MaxOrderPercent: pies.RiskRule{Limit: 25}}
+	concentration := pies.RiskLimits{MaxConcentration: pies.RiskRule{Limit: 40}}
+	deviation := pies.RiskLimits{MaxPriceDeviation: pies.RiskRule{Limit: 5}}
+
+	tests := []struct {
+		name         string
+		limits       pies.RiskLimits
+		order        pies.OrderRequest
+		quote        string
+		emptyAccount bool   // Check against an account without a value
+		want         string // The rule breached, empty for none
+	}{
+		{name: "value at the limit", limits: value, order: order("4", noEdit), quote: "250"},
+		{name: "value above the limit", limits: value, order: order("4", noEdit), quote: "250.01", want: pies.RiskMaxOrderValue},
+		{name: "value at the limit price", limits: value, order: order("4", limit("250")), quote: "240"},
+		{name: "value above the limit price", limits: value, order: order("4", limit("250.01")), quote: "240", want: pies.RiskMaxOrderValue},
+		{name: "amount at the value limit", limits: value, order: order("0", amount("1000")), quote: "250"},
+		{name: "amount above the value limit", limits: value, order: order("0", amount("1000.01")), quote: "250", want: pies.RiskMaxOrderValue},
+		{name: "sell above the value limit", limits: value, order: order("5", sell), quote: "250", want: pies.RiskMaxOrderValue},
+
+		{name: "quantity at the limit", limits: quantity, order: order("10", noEdit), quote: "250"},
+		{name: "quantity above the limit", limits: quantity, order: order("10.000001", noEdit), quote: "250", want: pies.RiskMaxOrderQuantity},
+		{name: "amount buying the limit", limits: quantity, order: order("0", amount("2500")), quote: "250"},
+		{name: "amount buying above the limit", limits: quantity, order: order("0", amount("2500.01")), quote: "250", want: pies.RiskMaxOrderQuantity},
+
+		{name: "percent at the limit", limits: percent, order: order("10", noEdit), quote: "250"},
+		{name: "percent above the limit", limits: percent, order: order("0", amount("2500.01")), quote: "250", want: pies.RiskMaxOrderPercent},
+		{name: "percent of an empty account", limits: percent, order: order("100", noEdit), quote: "250", emptyAccount: true},
+
+		{name: "concentration at the limit", limits: concentration, order: order("4", noEdit), quote: "250"},
+		{name: "concentration above the limit", limits: concentration, order: order("0", amount("1000.01")), quote: "250", want: pies.RiskMaxConcentration},
+		{name: "concentration by any case", limits: concentration, order: order("0", func(o *pies.OrderRequest) {
+			amount("1000.01")(o)
+			o.Symbol = " vti"
+		}), quote: "250", want: pies.RiskMaxConcentration},
+		{name: "concentration of a new symbol at the limit", limits: concentration, order: order("40", symbol("BND")), quote: "100"},
+		{name: "concentration of a new symbol above the limit", limits: concentration, order: order("40.0001", symbol("BND")), quote: "100", want: pies.RiskMaxConcentration},
+		{name: "concentration ignores sells", limits: concentration, order: order("40", sell), quote: "250"},
+		{name: "concentration of an empty account", limits: concentration, order: order("100", noEdit), quote: "250", emptyAccount: true},
+
+		{name: "limit at the deviation above", limits: deviation, order: order("1", limit("105")), quote: "100"},
+		{name: "limit past the deviation above", limits: deviation, order: order("1", limit("105.01")), quote: "100", want: pies.RiskMaxPriceDeviation},
+		{name: "limit at the deviation below", limits: deviation, order: order("1", limit("95")), quote: "100"},
+		{name: "limit past the deviation below", limits: deviation, order: order("1", limit("94.99")), quote: "100", want: pies.RiskMaxPriceDeviation},
+		{name: "stop at the deviation", limits: deviation, order: order("1", stop("95")), quote: "100"},
+		{name: "stop past the deviation", limits: deviation, order: order("1", stop("94.99")), quote: "100", want: pies.RiskMaxPriceDeviation},
+		{name: "market order", limits: deviation, order: order("1", noEdit), quote: "100"},
+		{name: "deviation without a quote", limits: deviation, order: order("1", limit("500")), quote: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exposure := riskExposure(t)
+			if tt.emptyAccount {
+				exposure = pies.RiskExposure{}
+			}
+
+			checker := &pies.RiskChecker{Limits: tt.limits}
+			violations := checker.CheckOrder(tt.order, dec(t, tt.quote), exposure)
+			var got []string
+			for _, violation := range violations {
+				got = append(got, violation.Rule)
+			}
+			switch {
+			case tt.want == "" && len(got) != 0:
+				t.Errorf("CheckOrder() breached %v, want nothing", got)
+			case tt.want != "" && !reflect.DeepEqual(got, []string{tt.want}):
+				t.Errorf("CheckOrder() breached %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRiskViolations(t *testing.T) {
+	order := pies.OrderRequest{
+		Symbol:     "VTI",
+		Action:     pies.OrderActionBuy,
+		Type:       pies.OrderTypeLimit,
+		Quantity:   dec(t, "12"),
+		LimitPrice: pies.DecimalPtr(dec(t, "250")),
+	}
+	checker := &pies.RiskChecker{Limits: pies.RiskLimits{
+		MaxOrderValue:     pies.RiskRule{Limit: 1000},
+		MaxOrderQuantity:  pies.RiskRule{Limit: 10, Warn: true},
+		MaxOrderPercent:   pies.RiskRule{Limit: 25},
+		MaxConcentration:  pies.RiskRule{Limit: 40, Warn: true},
+		MaxPriceDeviation: pies.RiskRule{Limit: 5},
+	}}
+
+	got := checker.CheckOrder(order, dec(t, "200"), riskExposure(t))
+	want := []pies.RiskViolation{
+		{
+			Rule: pies.RiskMaxOrderValue, Symbol: "VTI", Action: pies.OrderActionBuy,
+			Message: "BUY VTI: estimated value 3000.00 is above the max_order_value limit of 1000.00",
+		},
+		{
+			Rule: pies.RiskMaxOrderQuantity, Symbol: "VTI", Action: pies.OrderActionBuy, Warning: true,
+			Message: "BUY VTI: 12 shares are above the max_order_quantity limit of 10",
+		},
+		{
+			Rule: pies.RiskMaxOrderPercent, Symbol: "VTI", Action: pies.OrderActionBuy,
+			Message: "BUY VTI: 30.00% of the account's value is above the max_order_percent limit of 25%",
+		},
+		{
+			Rule: pies.RiskMaxConcentration, Symbol: "VTI", Action: pies.OrderActionBuy, Warning: true,
+			Message: "BUY VTI: would hold 60.00% of the account's value, above the max_concentration limit of 40%",
+		},
+		{
+			Rule: pies.RiskMaxPriceDeviation, Symbol: "VTI", Action: pies.OrderActionBuy,
+			Message: "BUY VTI: limit price 250 is 25.00% from the quote of 200, above the max_price_deviation limit of 5%",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CheckOrder() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestRiskCheckerCheckPlan(t *testing.T) {
+	planned := func(action pies.OrderAction, symbol, value string) pies.PlannedOrder {
+		return pies.PlannedOrder{
+			Order: pies.OrderRequest{
+				Symbol:   symbol,
+				Action:   action,
+				Type:     pies.OrderTypeMarket,
+				Quantity: dec(t, value).Div(decimal.NewFromInt(100)),
+			},
+			Price: decimal.NewFromInt(100),
+			Value: dec(t, value),
+		}
+	}
+	checker := &pies.RiskChecker{Limits: pies.RiskLimits{
+		MaxOrderValue:    pies.RiskRule{Limit: 1000},
+		MaxConcentration: pies.RiskRule{Limit: 40, Warn: true},
+	}}
+
+	// Each order is checked against the holdings the orders before it leave
+	plan := &pies.RebalancePlan{Orders: []pies.PlannedOrder{
+		planned(pies.OrderActionSell, "VTI", "1000"), // 2000 held
+		planned(pies.OrderActionBuy, "VTI", "1000"),  // 3000 held
+		planned(pies.OrderActionBuy, "VTI", "1000"),  // 4000 held, at the limit
+		planned(pies.OrderActionBuy, "VTI", "500"),   // 4500 held
+		planned(pies.OrderActionBuy, "BND", "1500"),
+	}}
+	exposure := riskExposure(t)
+
+	warnings, err := checker.CheckPlan(plan, nil, exposure)
+	if len(warnings) != 1 || warnings[0].Rule != pies.RiskMaxConcentration || warnings[0].Symbol != "VTI" ||
+		warnings[0].Message != "BUY VTI: would hold 45.00% of the account's value, above the max_concentration limit of 40%" {
+		t.Errorf("CheckPlan() warnings = %+v, want the last VTI buy's concentration", warnings)
+	}
+	var riskErr *pies.RiskError
+	if !errors.As(err, &riskErr) {
+		t.Fatalf("CheckPlan() error = %v, want a *RiskError", err)
+	}
+	if len(riskErr.Violations) != 1 || riskErr.Violations[0].Rule != pies.RiskMaxOrderValue || riskErr.Violations[0].Symbol != "BND" {
+		t.Errorf("RiskError.Violations = %+v, want the BND buy's value", riskErr.Violations)
+	}
+	if want := "orders failed the risk checks: BUY BND: estimated value 1500.00 is above the max_order_value limit of 1000.00"; err.Error() != want {
+		t.Errorf("CheckPlan() error = %q, want %q", err, want)
+	}
+	if held := exposure.Holdings["VTI"]; !held.Equal(dec(t, "3000")) {
+		t.Errorf("CheckPlan() changed the exposure's VTI holding to %s", held)
+	}
+
+	// Without holdings or breaches there is nothing to report
+	warnings, err = checker.CheckPlan(&pies.RebalancePlan{Orders: plan.Orders[:1]}, nil, pies.RiskExposure{AccountValue: dec(t, "10000")})
+	if len(warnings) != 0 || err != nil {
+		t.Errorf("CheckPlan() = %v, %v, want nothing", warnings, err)
+	}
+}
+
+func TestRiskCheckerCheckPlanAtQuotes(t *testing.T) {
+	// A buy of 10 BND sized when it asked 100
+	plan := &pies.RebalancePlan{Orders: []pies.PlannedOrder{{
+		Order: pies.OrderRequest{Symbol: "BND", Action: pies.OrderActionBuy, Type: pies.OrderTypeMarket, Quantity: dec(t, "10")},
+		Price: dec(t, "100"),
+		Value: dec(t, "1000"),
+	}}}
+	checker := &pies.RiskChecker{Limits: pies.RiskLimits{MaxOrderValue: pies.RiskRule{Limit: 1000}}}
+
+	tests := []struct {
+		name    string
+		quotes  map[string]pies.Quote
+		wantErr string // Empty when the plan passes
+	}{
+		{name: "no quotes", quotes: nil},
+		{name: "unchanged quote", quotes: map[string]pies.Quote{"BND": {Bid: dec(t, "99"), Ask: dec(t, "100"), Last: dec(t, "100")}}},
+		{
+			name:    "price moved up",
+			quotes:  map[string]pies.Quote{"BND": {Bid: dec(t, "109"), Ask: dec(t, "110"), Last: dec(t, "110")}},
+			wantErr: "BUY BND: estimated value 1100.00 is above the max_order_value limit of 1000.00",
+		},
+		{name: "quote for another symbol", quotes: map[string]pies.Quote{"VTI": {Ask: dec(t, "500")}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := checker.CheckPlan(plan, tt.quotes, riskExposure(t))
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("CheckPlan() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckRiskAtLiveQuotes(t *testing.T) {
+	// The plan sells 10 VTI and buys 10 BND, $1,000 each at the planned
+	// prices of 100
+	tests := []struct {
+		name    string
+		reprice func(b *piestest.Brokerage)
+		wantErr string // Empty when the plan passes
+	}{
+		{name: "prices unchanged", reprice: func(*piestest.Brokerage) {}},
+		{
+			name:    "buy became more expensive",
+			reprice: func(b *piestest.Brokerage) { b.SetPrice("BND", dec(t, "110")) },
+			wantErr: "BUY BND: estimated value 1100.00",
+		},
+		{
+			name:    "sell became more valuable",
+			reprice: func(b *piestest.Brokerage) { b.SetPrice("VTI", dec(t, "105")) },
+			wantErr: "SELL VTI: estimated value 1050.00",
+		},
+		{name: "prices fell", reprice: func(b *piestest.Brokerage) { b.SetPrice("BND", dec(t, "90")) }},
+		{name: "quote gone", reprice: func(b *piestest.Brokerage) { b.RemoveQuote("BND") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := holdingFake(t, pies.AccountKindTaxable, "0", "VTI", "70", "BND", "30")
+			investor := &pies.Investor{
+				BrokerageClient: b,
+				Risk:            &pies.RiskChecker{Limits: pies.RiskLimits{MaxOrderValue: pies.RiskRule{Limit: 1000}}},
+			}
+			plan := planFor(t, investor)
+			tt.reprice(b)
+
+			_, err := investor.CheckRisk(t.Context(), plan)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("CheckRisk() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewRiskChecker(t *testing.T) {
+	if checker := pies.NewRiskChecker(pies.RiskLimits{}, pies.Pie{}); checker != nil {
+		t.Errorf("NewRiskChecker() without limits = %+v, want nil", checker)
+	}
+
+	file := pies.RiskLimits{
+		MaxOrderValue:    pies.RiskRule{Limit: 1000},
+		MaxOrderQuantity: pies.RiskRule{Limit: 10},
+	}
+	pie := pies.Pie{Risk: &pies.RiskLimits{
+		MaxOrderValue:    pies.RiskRule{Limit: 500, Warn: true},
+		MaxConcentration: pies.RiskRule{Limit: 40},
+	}}
+	checker := pies.NewRiskChecker(file, pie)
+	want := pies.RiskLimits{
+		MaxOrderValue:    pies.RiskRule{Limit: 500, Warn: true},
+		MaxOrderQuantity: pies.RiskRule{Limit: 10},
+		MaxConcentration: pies.RiskRule{Limit: 40},
+	}
+	if checker == nil || checker.Limits != want {
+		t.Errorf("NewRiskChecker() = %+v, want limits %+v", checker, want)
+	}
+
+	// A pie's zero limit leaves the file's rule on
+	pie.Risk = &pies.RiskLimits{MaxOrderValue: pies.RiskRule{Warn: true}}
+	if checker := pies.NewRiskChecker(file, pie); checker == nil || checker.Limits != file {
+		t.Errorf("NewRiskChecker() = %+v, want limits %+v", checker, file)
+	}
+}
+
+func TestLoadRiskLimits(t *testing.T) {
+	want := pies.RiskLimits{
+		MaxOrderValue:     pies.RiskRule{Limit: 10000},
+		MaxConcentration:  pies.RiskRule{Limit: 40, Warn: true},
+		MaxPriceDeviation: pies.RiskRule{Limit: 2.5},
+	}
+	files := map[string]string{
+		"risk.json": `{
+			"max_order_value": {"limit": 10000},
+			"max_concentration": {"limit": 40, "warn": true},
+			"max_price_deviation": {"limit": 2.5}
+		}`,
+		"risk.yaml": "max_order_value:\n  limit: 10000\nmax_concentration:\n  limit: 40\n  warn: true\nmax_price_deviation:\n  limit: 2.5\n",
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			got, err := pies.LoadRiskLimits(writePieFile(t, name, content))
+			if err != nil {
+				t.Fatalf("LoadRiskLimits() error = %v", err)
+			}
+			if got != want {
+				t.Errorf("LoadRiskLimits() = %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	for name, content := range map[string]string{
+		"negative limit": `{"max_order_quantity": {"limit": -1}}`,
+		"unknown rule":   `{"max_daily_loss": {"limit": 100}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := pies.LoadRiskLimits(writePieFile(t, "risk.json", content)); err == nil {
+				t.Error("LoadRiskLimits() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestExecutePlanRiskChecks(t *testing.T) {
+	// The plan sells 10 VTI and buys 10 BND, $1,000 each
+	tests := []struct {
+		name   string
+		rule   pies.RiskRule
+		placed bool
+	}{
+		{name: "hard failure", rule: pies.RiskRule{Limit: 999}, placed: false},
+		{name: "warning", rule: pies.RiskRule{Limit: 999, Warn: true}, placed: true},
+		{name: "at the limit", rule: pies.RiskRule{Limit: 1000}, placed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := paperAccount(t, paper.Config{})
+			investor := &pies.Investor{
+				BrokerageClient: client,
+				Risk:            &pies.RiskChecker{Limits: pies.RiskLimits{MaxOrderValue: tt.rule}},
+			}
+
+			report, err := investor.ExecutePlan(t.Context(), planFor(t, investor), pies.ExecuteOptions{PollInterval: time.Millisecond})
+			var riskErr *pies.RiskError
+			if tt.placed != (err == nil) || !tt.placed && !errors.As(err, &riskErr) {
+				t.Fatalf("ExecutePlan() error = %v, want placed %t", err, tt.placed)
+			}
+
+			_, held := holdings(t, client)
+			if tt.placed != (held["VTI"] == "60") {
+				t.Errorf("account holds %v, want placed %t", held, tt.placed)
+			}
+			wantWarnings := 0
+			if tt.rule.Warn {
+				wantWarnings = 2
+			}
+			if len(report.RiskWarnings) != wantWarnings {
+				t.Errorf("RiskWarnings = %+v, want %d", report.RiskWarnings, wantWarnings)
+			}
+		})
+	}
+}
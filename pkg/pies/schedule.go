@@ -0,0 +1,199 @@
+package pies
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scheduleHorizon bounds how far ahead Schedule.Next looks for a match, so
+// that a schedule which can never fire, e.g. on February 30th, ends
+const scheduleHorizon = 5 * 366 * 24 * time.Hour
+
+// Schedule tells the Scheduler when to run
+type Schedule interface {
+	// Next returns the first run time strictly after after, or the zero
+	// time if there is none
+	Next(after time.Time) time.Time
+}
+
+// CronSchedule fires on the minutes matched by a five-field cron expression
+type CronSchedule struct {
+	minutes, hours, days, months, weekdays uint64
+	anyDay, anyWeekday                     bool
+	location                               *time.Location
+}
+
+// cronField is the range of values one field of a cron expression takes
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = [5]cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseSchedule parses a cron expression of the form "minute hour
+// day-of-month month day-of-week", evaluated in location, e.g.
+// "0 10 * * 1-5" for 10:00 on weekdays. Fields take "*", numbers, ranges
+// "a-b", steps "*/n" or "a-b/n" and comma-separated lists of these. Day of
+// week runs from 0 for Sunday to 6, with 7 also meaning Sunday. As in cron,
+// when both day fields are restricted a day matching either one fires.
+// A nil location uses UTC.
+func ParseSchedule(expr string, location *time.Location) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("schedule %q has %d fields, want %d", expr, len(fields), len(cronFields))
+	}
+	if location == nil {
+		location = time.UTC
+	}
+
+	var sets [5]uint64
+	for idx, field := range fields {
+		set, err := parseCronField(field, cronFields[idx])
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", expr, err)
+		}
+		sets[idx] = set
+	}
+
+	// Sunday is both 0 and 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	return &CronSchedule{
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekdays:   sets[4],
+		anyDay:     fields[2] == "*",
+		anyWeekday: fields[4] == "*",
+		location:   location,
+	}, nil
+}
+
+// parseCronField returns the set of values matched by one field, as a bit
+// per value
+func parseCronField(expr string, field cronField) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepExpr)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepExpr, field.name)
+			}
+		}
+
+		low, high := field.min, field.max
+		if rangeExpr != "*" {
+			lowExpr, highExpr, isRange := strings.Cut(rangeExpr, "-")
+			var err error
+			if low, err = strconv.Atoi(lowExpr); err != nil {
+				return 0, fmt.Errorf("invalid value %q in %s field", lowExpr, field.name)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(highExpr); err != nil {
+					return 0, fmt.Errorf("invalid value %q in %s field", highExpr, field.name)
+				}
+			} else if hasStep {
+				high = field.max
+			}
+		}
+		if low < field.min || high > field.max || low > high {
+			return 0, fmt.Errorf("%s field %q is outside %d-%d", field.name, part, field.min, field.max)
+		}
+
+		for value := low; value <= high; value += step {
+			set |= 1 << value
+		}
+	}
+	return set, nil
+}
+
+// Next returns the first matching minute after after
+func (s *CronSchedule) Next(after time.Time) time.Time {
+	t := after.In(s.location)
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, s.location).Add(time.Minute)
+
+	for limit := t.Add(scheduleHorizon); t.Before(limit); {
+		switch {
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.location)
+		case s.hours&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.location)
+		case s.minutes&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches reports whether the schedule fires on t's day
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	if s.months&(1<<int(t.Month())) == 0 {
+		return false
+	}
+
+	day := s.days&(1<<t.Day()) != 0
+	weekday := s.weekdays&(1<<int(t.Weekday())) != 0
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// MarketCalendar tells the Scheduler whether the market is open
+type MarketCalendar interface {
+	IsOpen(ctx context.Context, at time.Time) (bool, error)
+}
+
+// USMarketHours is the regular session of the US stock exchanges, 9:30 to
+// 16:00 New York time on weekdays. The exchanges' holidays change every
+// year, so they are listed in Holidays as "2006-01-02" dates.
+type USMarketHours struct {
+	Holidays []string
+}
+
+func (h USMarketHours) IsOpen(ctx context.Context, at time.Time) (bool, error) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return false, fmt.Errorf("failed to load New York time zone: %w", err)
+	}
+
+	at = at.In(newYork)
+	if at.Weekday() == time.Saturday || at.Weekday() == time.Sunday {
+		return false, nil
+	}
+	date := at.Format(time.DateOnly)
+	for _, holiday := range h.Holidays {
+		if holiday == date {
+			return false, nil
+		}
+	}
+
+	open := time.Date(at.Year(), at.Month(), at.Day(), 9, 30, 0, 0, newYork)
+	closing := time.Date(at.Year(), at.Month(), at.Day(), 16, 0, 0, 0, newYork)
+	return !at.Before(open) && at.Before(closing), nil
+}
@@ -0,0 +1,384 @@
+package pies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alysoliman1/money-pies/internal/pkg/logging"
+)
+
+// ErrRunInProgress is returned by Scheduler.RunOnce while another run holds
+// the scheduler's lock
+var ErrRunInProgress = errors.New("another rebalance run is in progress")
+
+// Clock tells the Scheduler the time and waits for it to pass, so that
+// tests can drive the scheduler with a fake clock
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock used when Scheduler.Clock is nil
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Approver asks the user whether a scheduled plan may be executed
+type Approver interface {
+	Approve(ctx context.Context, plan *RebalancePlan) (bool, error)
+}
+
+// defaultTokenWarning is how long before the brokerage sign-in expires the
+// Scheduler starts warning about it when TokenWarning is zero
+const defaultTokenWarning = 48 * time.Hour
+
+// RefreshTokenChecker is implemented by brokerage clients whose credentials
+// eventually expire for good and have to be renewed by the user
+type RefreshTokenChecker interface {
+	RefreshTokenValid() bool
+}
+
+// RunOutcome is what a scheduled run did
+type RunOutcome string
+
+const (
+	RunSkippedClosed RunOutcome = "SKIPPED_CLOSED" // The market was closed
+	RunSkippedAuth   RunOutcome = "SKIPPED_AUTH"   // The brokerage credentials have expired
+	RunInBand        RunOutcome = "IN_BAND"        // No slice was outside its drift band
+	RunNoOrders      RunOutcome = "NO_ORDERS"      // The plan had nothing to trade
+	RunRejected      RunOutcome = "REJECTED"       // The Approver declined the plan
+	RunPlanned       RunOutcome = "PLANNED"        // A dry run planned the orders without placing them
+	RunExecuted      RunOutcome = "EXECUTED"       // The plan was executed
+	RunFailed        RunOutcome = "FAILED"         // The run ended with an error
+)
+
+// RunResult describes one scheduled run
+type RunResult struct {
+	Time      time.Time
+	Outcome   RunOutcome
+	Breaching []string // Symbols outside their drift band
+	Plan      *RebalancePlan
+	Report    *ExecutionReport
+}
+
+// Scheduler checks a pie's drift on a schedule and rebalances it when any
+// slice is outside its drift band
+type Scheduler struct {
+	Investor *Investor
+	Pie      Pie
+	Schedule Schedule
+
+	// Market skips runs while the market is closed. Nil runs regardless.
+	Market MarketCalendar
+
+	// Threshold is the drift band for pies without a default band of their
+	// own. Zero rebalances on any drift.
+	Threshold DriftBand
+
+	Rebalance RebalanceOptions
+	Execute   ExecuteOptions
+
+	// DryRun plans and reports the orders without placing them
+	DryRun bool
+
+	// Notifier is told about every run that finds drift, fails or is
+	// skipped because the credentials expire soon or have expired. It
+	// replaces the investor's Notifier during runs. Nil only logs.
+	Notifier Notifier
+
+	// TokenWarning is how long before the brokerage's refresh token
+	// expires each run warns about it. Zero warns 48 hours ahead.
+	TokenWarning time.Duration
+
+	// Approver, when set, must approve each plan before it is executed
+	Approver Approver
+
+	// LockPath names a lock file held during each run so that two
+	// processes never rebalance at once. A run that crashes leaves the file
+	// behind, and it has to be removed before the next run can start.
+	LockPath string
+
+	Clock  Clock        // Nil uses the system clock
+	Logger *slog.Logger // Nil uses slog.Default
+
+	mu sync.Mutex
+}
+
+// Run runs the scheduler until ctx is cancelled. A failed run is reported
+// and does not stop the scheduler. The brokerage client is kept between
+// runs, so a client that refreshes its access token, like Schwab's, stays
+// signed in for as long as its refresh token lasts.
+func (s *Scheduler) Run(ctx context.Context) error {
+	clock := s.clock()
+	for {
+		now := clock.Now()
+		next := s.Schedule.Next(now)
+		if next.IsZero() {
+			return errors.New("schedule has no further runs")
+		}
+		s.logger().InfoContext(ctx, "next scheduled rebalance", slog.Time("at", next))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clock.After(next.Sub(now)):
+		}
+
+		result, err := s.RunOnce(ctx)
+		if err != nil {
+			s.logger().ErrorContext(ctx, "scheduled rebalance failed", slog.String("error", err.Error()))
+			continue
+		}
+		s.logger().InfoContext(ctx, "scheduled rebalance finished", slog.String("outcome", string(result.Outcome)))
+	}
+}
+
+// RunOnce checks the pie now and rebalances it if needed. It returns
+// ErrRunInProgress rather than wait when another run holds the lock.
+func (s *Scheduler) RunOnce(ctx context.Context) (*RunResult, error) {
+	if !s.mu.TryLock() {
+		return nil, ErrRunInProgress
+	}
+	defer s.mu.Unlock()
+
+	unlock, err := s.lockFile()
+	if err != nil {
+		s.notify(ctx, Event{
+			Kind:    EventExecutionFailed,
+			Pie:     s.Pie.Name,
+			Message: fmt.Sprintf("Rebalance of %s skipped: %v", s.Pie.Name, err),
+			Error:   err.Error(),
+		})
+		return nil, err
+	}
+	defer unlock()
+
+	result := &RunResult{Time: s.clock().Now()}
+	if err := s.run(ctx, result); err != nil {
+		result.Outcome = RunFailed
+		// A failed execution has been reported by ExecutePlan already
+		if result.Report == nil {
+			s.notify(ctx, Event{
+				Kind:    EventExecutionFailed,
+				Time:    result.Time,
+				Pie:     s.Pie.Name,
+				Message: fmt.Sprintf("Rebalance of %s failed: %v", s.Pie.Name, err),
+				Plan:    result.Plan,
+				Error:   err.Error(),
+			})
+		}
+		return result, err
+	}
+	return result, nil
+}
+
+// run does the work of RunOnce, recording what it did in result
+func (s *Scheduler) run(ctx context.Context, result *RunResult) error {
+	if checker, ok := s.Investor.BrokerageClient.(RefreshTokenChecker); ok && !checker.RefreshTokenValid() {
+		result.Outcome = RunSkippedAuth
+		s.notify(ctx, Event{
+			Kind:      EventTokenExpiring,
+			Time:      result.Time,
+			Pie:       s.Pie.Name,
+			Message:   fmt.Sprintf("Rebalance of %s skipped: the brokerage refresh token has expired, sign in again", s.Pie.Name),
+			ExpiresAt: s.refreshTokenExpiry(),
+		})
+		return nil
+	}
+	if expiresAt := s.refreshTokenExpiry(); expiresAt != nil && expiresAt.Sub(result.Time) < s.tokenWarning() {
+		s.notify(ctx, Event{
+			Kind: EventTokenExpiring,
+			Time: result.Time,
+			Pie:  s.Pie.Name,
+			Message: fmt.Sprintf("The brokerage sign-in expires at %s, sign in again before then to keep %s rebalancing",
+				expiresAt.Format(time.DateTime), s.Pie.Name),
+			ExpiresAt: expiresAt,
+		})
+	}
+
+	if s.Market != nil {
+		open, err := s.Market.IsOpen(ctx, result.Time)
+		if err != nil {
+			return fmt.Errorf("failed to check market hours: %w", err)
+		}
+		if !open {
+			result.Outcome = RunSkippedClosed
+			return nil
+		}
+	}
+
+	pie := s.Pie
+	if pie.Band == (DriftBand{}) {
+		pie.Band = s.Threshold
+	}
+
+	status, holdings, err := s.Investor.getPieStatus(ctx, pie, result.Time)
+	if err != nil {
+		return err
+	}
+	var drifts []string
+	for _, slice := range status.NeedsRebalance() {
+		result.Breaching = append(result.Breaching, slice.Symbol)
+		drifts = append(drifts, fmt.Sprintf("%s %+.2f", slice.Symbol, slice.Drift))
+	}
+	if len(result.Breaching) == 0 {
+		result.Outcome = RunInBand
+		return nil
+	}
+	s.notify(ctx, Event{
+		Kind:      EventDriftBreach,
+		Time:      result.Time,
+		Pie:       pie.Name,
+		Message:   fmt.Sprintf("%s is outside its drift band: %s", pie.Name, strings.Join(drifts, ", ")),
+		Breaching: result.Breaching,
+	})
+	if len(status.Unpriced) > 0 {
+		return fmt.Errorf("cannot rebalance without quotes for %s", strings.Join(status.Unpriced, ", "))
+	}
+
+	result.Plan = computeRebalancePlan(status, holdings.quotes, holdings.extendedHours, s.Rebalance)
+	if err := s.Investor.screenPlan(ctx, result.Plan, s.Rebalance.AcknowledgeUnscreened); err != nil {
+		return err
+	}
+	if len(result.Plan.Orders) == 0 {
+		result.Outcome = RunNoOrders
+		s.notify(ctx, s.planEvent(result, fmt.Sprintf("%s is outside its drift band (%s) but there is nothing to trade",
+			pie.Name, strings.Join(result.Breaching, ", "))))
+		return nil
+	}
+
+	if s.Approver != nil {
+		approved, err := s.Approver.Approve(ctx, result.Plan)
+		if err != nil {
+			return fmt.Errorf("failed to get the plan approved: %w", err)
+		}
+		if !approved {
+			result.Outcome = RunRejected
+			s.notify(ctx, s.planEvent(result, fmt.Sprintf("Rebalance of %s was not approved: %s", pie.Name, describeOrders(result.Plan))))
+			return nil
+		}
+	}
+
+	if s.DryRun {
+		result.Outcome = RunPlanned
+		s.notify(ctx, s.planEvent(result, fmt.Sprintf("Dry run, %s would be rebalanced: %s", pie.Name, describeOrders(result.Plan))))
+		return nil
+	}
+
+	// ExecutePlan reports the execution through the scheduler's notify
+	investor := *s.Investor
+	investor.Notifier = schedulerNotifier{s}
+	result.Report, err = investor.ExecutePlan(ctx, result.Plan, s.Execute)
+	if err != nil {
+		return err
+	}
+	result.Outcome = RunExecuted
+	return nil
+}
+
+// planEvent is the EventPlanComputed for the plan of result
+func (s *Scheduler) planEvent(result *RunResult, message string) Event {
+	return Event{
+		Kind:      EventPlanComputed,
+		Time:      result.Time,
+		Pie:       result.Plan.Pie,
+		Message:   message,
+		Breaching: result.Breaching,
+		Plan:      result.Plan,
+	}
+}
+
+// refreshTokenExpiry returns when the brokerage's refresh token expires, nil
+// when the brokerage does not say
+func (s *Scheduler) refreshTokenExpiry() *time.Time {
+	expirer, ok := s.Investor.BrokerageClient.(RefreshTokenExpirer)
+	if !ok {
+		return nil
+	}
+	expiresAt := expirer.RefreshTokenExpiresAt()
+	if expiresAt.IsZero() {
+		return nil
+	}
+	return &expiresAt
+}
+
+func (s *Scheduler) tokenWarning() time.Duration {
+	if s.TokenWarning <= 0 {
+		return defaultTokenWarning
+	}
+	return s.TokenWarning
+}
+
+// lockFile creates the scheduler's lock file, failing if another process
+// holds it, and returns the function removing it
+func (s *Scheduler) lockFile() (func(), error) {
+	if s.LockPath == "" {
+		return func() {}, nil
+	}
+
+	file, err := os.OpenFile(s.LockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("%w: lock file %s exists", ErrRunInProgress, s.LockPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lock file: %w", err)
+	}
+	fmt.Fprintf(file, "%d\n", os.Getpid())
+	file.Close()
+
+	return func() { os.Remove(s.LockPath) }, nil
+}
+
+// notify sends event to the Notifier, logging its message either way
+func (s *Scheduler) notify(ctx context.Context, event Event) {
+	s.logger().InfoContext(ctx, event.Message, slog.String("kind", string(event.Kind)))
+	if s.Notifier != nil {
+		deliver(ctx, s.Notifier, s.logger(), event)
+	}
+}
+
+// schedulerNotifier passes the events of the investor to the scheduler's
+// notify
+type schedulerNotifier struct {
+	s *Scheduler
+}
+
+func (n schedulerNotifier) Notify(ctx context.Context, event Event) error {
+	n.s.notify(ctx, event)
+	return nil
+}
+
+func (s *Scheduler) clock() Clock {
+	if s.Clock == nil {
+		return realClock{}
+	}
+	return s.Clock
+}
+
+func (s *Scheduler) logger() *slog.Logger {
+	if s.Logger == nil {
+		return logging.RedactLogger(slog.Default())
+	}
+	return logging.RedactLogger(s.Logger)
+}
+
+// describeOrders summarizes a plan's orders on one line
+func describeOrders(plan *RebalancePlan) string {
+	orders := make([]string, 0, len(plan.Orders))
+	for _, planned := range plan.Orders {
+		orders = append(orders, fmt.Sprintf("%s %v %s", strings.ToLower(string(planned.Order.Action)), planned.Order.Quantity, planned.Order.Symbol))
+	}
+	return strings.Join(orders, ", ")
+}
@@ -0,0 +1,204 @@
+package pies_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/alysoliman1/money-pies/pkg/pies/piestest"
+	"github.com/shopspring/decimal"
+)
+
+// expiringBrokerage is a fake whose refresh token can be made to expire
+type expiringBrokerage struct {
+	*piestest.Brokerage
+	expired atomic.Bool
+}
+
+func (b *expiringBrokerage) RefreshTokenValid() bool {
+	return !b.expired.Load()
+}
+
+// tick advances clock to the scheduler's next run once it is waiting for it,
+// and returns the time of the run
+func tick(t *testing.T, clock *piestest.Clock) time.Time {
+	t.Helper()
+
+	clock.WaitForTimers(t, 1)
+	next, _ := clock.NextTimer()
+	clock.AdvanceTo(next)
+	return next
+}
+
+func TestSchedulerRunsOnSchedule(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("failed to load time zone: %v", err)
+	}
+	schedule, err := pies.ParseSchedule("0 10 * * 1-5", newYork)
+	if err != nil {
+		t.Fatalf("ParseSchedule() error = %v", err)
+	}
+
+	pie := testPie("VTI", 60, "BND", 40)
+	pie.Band = pies.DriftBand{Absolute: 5}
+	b := &expiringBrokerage{Brokerage: piestest.DriftedPortfolio(pie, decimal.NewFromInt(10_000), 10)}
+	notifier := &piestest.Notifier{}
+	// Thursday, two hours before the first run
+	clock := piestest.NewClock(time.Date(2025, time.January, 2, 8, 0, 0, 0, newYork))
+
+	scheduler := &pies.Scheduler{
+		Investor:  &pies.Investor{BrokerageClient: b},
+		Pie:       pie,
+		Schedule:  schedule,
+		Market:    pies.USMarketHours{Holidays: []string{"2025-01-07"}},
+		Rebalance: pies.RebalanceOptions{AllowSells: true},
+		Execute:   pies.ExecuteOptions{PollInterval: time.Millisecond},
+		Notifier:  notifier,
+		Clock:     clock,
+		Logger:    slog.New(slog.DiscardHandler),
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() { done <- scheduler.Run(ctx) }()
+
+	runs := []struct {
+		at      time.Time
+		prepare func()
+		kinds   []pies.EventKind // Events sent by the run
+		placed  int              // Orders placed by the run
+	}{
+		{
+			// 70/30 is outside the band and rebalanced
+			at:     time.Date(2025, time.January, 2, 10, 0, 0, 0, newYork),
+			kinds:  []pies.EventKind{pies.EventDriftBreach, pies.EventExecutionCompleted},
+			placed: 2,
+		},
+		{
+			// Back on target the next day
+			at: time.Date(2025, time.January, 3, 10, 0, 0, 0, newYork),
+		},
+		{
+			// The weekend is not scheduled; on Monday the sign-in has
+			// expired, so the run only alerts
+			at:      time.Date(2025, time.January, 6, 10, 0, 0, 0, newYork),
+			prepare: func() { b.expired.Store(true) },
+			kinds:   []pies.EventKind{pies.EventTokenExpiring},
+		},
+		{
+			// Signed in again and drifted, but the market is closed
+			at: time.Date(2025, time.January, 7, 10, 0, 0, 0, newYork),
+			prepare: func() {
+				b.expired.Store(false)
+				b.SetPositions(piestest.AccountID,
+					pies.Position{Symbol: "VTI", Quantity: decimal.NewFromInt(70), AveragePrice: decimal.NewFromInt(100)},
+					pies.Position{Symbol: "BND", Quantity: decimal.NewFromInt(30), AveragePrice: decimal.NewFromInt(100)})
+			},
+		},
+		{
+			at:     time.Date(2025, time.January, 8, 10, 0, 0, 0, newYork),
+			kinds:  []pies.EventKind{pies.EventDriftBreach, pies.EventExecutionCompleted},
+			placed: 2,
+		},
+	}
+
+	events, placed := 0, 0
+	for i, run := range runs {
+		if run.prepare != nil {
+			run.prepare()
+		}
+		if at := tick(t, clock); !at.Equal(run.at) {
+			t.Fatalf("run %d at %s, want %s", i, at, run.at)
+		}
+		// The run is over once the scheduler waits for the next one
+		clock.WaitForTimers(t, 1)
+
+		kinds := notifier.Kinds()[events:]
+		if !slices.Equal(kinds, run.kinds) {
+			t.Errorf("run %d at %s sent %v, want %v", i, run.at, kinds, run.kinds)
+		}
+		for _, event := range notifier.Events()[events:] {
+			if event.Kind != pies.EventExecutionCompleted && !event.Time.Equal(run.at) {
+				t.Errorf("run %d event %s at %s, want the fake clock's %s", i, event.Kind, event.Time, run.at)
+			}
+		}
+		if got := len(b.PlacedOrders()) - placed; got != run.placed {
+			t.Errorf("run %d at %s placed %d orders, want %d", i, run.at, got, run.placed)
+		}
+		events, placed = len(notifier.Kinds()), len(b.PlacedOrders())
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestSchedulerGlidePathAtClockTime(t *testing.T) {
+	// The glide path is at 70/30 on its 2040 anchor, which the account holds
+	pie := glidePie()
+	pie.Band = pies.DriftBand{Absolute: 5}
+	b := holdingFake(t, pies.AccountKindTaxable, "0", "VTI", "70", "BND", "30")
+	b.SetPrice("BNDX", decimal.NewFromInt(piestest.DefaultPrice))
+
+	scheduler := &pies.Scheduler{
+		Investor: &pies.Investor{BrokerageClient: b},
+		Pie:      pie,
+		Clock:    piestest.NewClock(date(2040, 1, 1)),
+		Logger:   slog.New(slog.DiscardHandler),
+	}
+	result, err := scheduler.RunOnce(t.Context())
+	if err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	if result.Outcome != pies.RunInBand {
+		t.Errorf("RunOnce() outcome = %s, want %s at the clock's glide path weights", result.Outcome, pies.RunInBand)
+	}
+}
+
+func TestSchedulerNeverOverlapsRuns(t *testing.T) {
+	pie := testPie("VTI", 60, "BND", 40)
+	b := piestest.DriftedPortfolio(pie, decimal.NewFromInt(10_000), 10)
+	lockPath := filepath.Join(t.TempDir(), "rebalance.lock")
+	scheduler := &pies.Scheduler{
+		Investor:  &pies.Investor{BrokerageClient: b},
+		Pie:       pie,
+		Rebalance: pies.RebalanceOptions{AllowSells: true},
+		DryRun:    true,
+		LockPath:  lockPath,
+		Logger:    slog.New(slog.DiscardHandler),
+	}
+
+	// Another process holds the lock file
+	if err := os.WriteFile(lockPath, []byte("1\n"), 0o644); err != nil {
+		t.Fatalf("failed to create lock file: %v", err)
+	}
+	if _, err := scheduler.RunOnce(t.Context()); !errors.Is(err, pies.ErrRunInProgress) {
+		t.Fatalf("RunOnce() error = %v, want ErrRunInProgress", err)
+	}
+	b.AssertCalled(t, "GetAccounts", 0)
+
+	// Once it is gone the run goes ahead and removes its own lock file
+	if err := os.Remove(lockPath); err != nil {
+		t.Fatalf("failed to remove lock file: %v", err)
+	}
+	result, err := scheduler.RunOnce(t.Context())
+	if err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	if result.Outcome != pies.RunPlanned {
+		t.Errorf("RunOnce() outcome = %s, want %s", result.Outcome, pies.RunPlanned)
+	}
+	if _, err := os.Stat(lockPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("lock file left behind after the run, stat error = %v", err)
+	}
+	b.AssertCalled(t, "PlaceOrder", 0)
+}
@@ -0,0 +1,192 @@
+package pies
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Screener decides whether a symbol may be invested in, e.g. against a
+// halal or ESG screen. ComputeRebalancePlan and AllocateCash refuse to trade
+// symbols it rejects when the investor has one.
+type Screener interface {
+	Screen(ctx context.Context, symbol string) (ScreenResult, error)
+}
+
+// ScreenResult is a Screener's verdict on a symbol
+type ScreenResult struct {
+	Allowed bool
+	Reason  string // Why the symbol was rejected
+}
+
+// ScreeningFailure is a symbol a Screener rejected and why
+type ScreeningFailure struct {
+	Symbol string `json:"symbol"`
+	Reason string `json:"reason"`
+}
+
+// ScreeningError lists the symbols that failed screening
+type ScreeningError struct {
+	Failures []ScreeningFailure
+}
+
+func (e *ScreeningError) Error() string {
+	failures := make([]string, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		failures = append(failures, fmt.Sprintf("%s failed screening: %s", failure.Symbol, failure.Reason))
+	}
+	return strings.Join(failures, "; ")
+}
+
+// ScreenPie screens every symbol of the flattened pie, returning a
+// *ScreeningError listing those rejected
+func ScreenPie(ctx context.Context, screener Screener, pie Pie) error {
+	flat, err := pie.Flatten()
+	if err != nil {
+		return err
+	}
+
+	symbols := make([]string, 0, len(flat.Slices))
+	for _, slice := range flat.Slices {
+		if !slice.IsCash() {
+			symbols = append(symbols, normalizeSymbol(slice.Asset.Symbol))
+		}
+	}
+
+	failures, err := screenSymbols(ctx, screener, symbols)
+	if err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		return &ScreeningError{Failures: failures}
+	}
+	return nil
+}
+
+// screenPlan screens the symbols of the plan's orders with the investor's
+// Screener, if it has one. Failures for the acknowledged symbols are
+// recorded in the plan instead of being returned.
+func (i *Investor) screenPlan(ctx context.Context, plan *RebalancePlan, acknowledged []string) error {
+	if i.Screener == nil {
+		return nil
+	}
+
+	var symbols []string
+	for _, planned := range plan.Orders {
+		if symbol := normalizeSymbol(planned.Order.Symbol); !slices.Contains(symbols, symbol) {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	failures, err := screenSymbols(ctx, i.Screener, symbols)
+	if err != nil {
+		return err
+	}
+
+	var rejected []ScreeningFailure
+	for _, failure := range failures {
+		if slices.ContainsFunc(acknowledged, func(symbol string) bool {
+			return normalizeSymbol(symbol) == failure.Symbol
+		}) {
+			plan.AcknowledgedFailures = append(plan.AcknowledgedFailures, failure)
+		} else {
+			rejected = append(rejected, failure)
+		}
+	}
+	if len(rejected) > 0 {
+		return &ScreeningError{Failures: rejected}
+	}
+	return nil
+}
+
+// screenSymbols returns the symbols screener rejects
+func screenSymbols(ctx context.Context, screener Screener, symbols []string) ([]ScreeningFailure, error) {
+	var failures []ScreeningFailure
+	for _, symbol := range symbols {
+		result, err := screener.Screen(ctx, symbol)
+		if err != nil {
+			return nil, fmt.Errorf("failed to screen %s: %w", symbol, err)
+		}
+		if !result.Allowed {
+			failures = append(failures, ScreeningFailure{Symbol: symbol, Reason: result.Reason})
+		}
+	}
+	return failures, nil
+}
+
+// ListScreener screens symbols against fixed lists. Denied symbols are
+// rejected with their reason; when Allowed is not empty every symbol
+// missing from it is rejected too. Symbols are upper-case.
+type ListScreener struct {
+	Allowed map[string]bool
+	Denied  map[string]string // Reason keyed by symbol
+}
+
+// screenListFile is the on-disk form of a ListScreener, in JSON or YAML:
+//
+//	allow: [HLAL, SPUS, UMMA]
+//	deny:
+//	  - {symbol: JPM, reason: conventional bank}
+type screenListFile struct {
+	Allow []string `json:"allow" yaml:"allow"`
+	Deny  []struct {
+		Symbol string `json:"symbol" yaml:"symbol"`
+		Reason string `json:"reason" yaml:"reason"`
+	} `json:"deny" yaml:"deny"`
+}
+
+// LoadScreenList reads a ListScreener from path. The format is chosen like
+// LoadPie's.
+func LoadScreenList(path string) (*ListScreener, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read screen list: %w", err)
+	}
+
+	var file screenListFile
+	if isYAMLPieFile(path, raw) {
+		decoder := yaml.NewDecoder(bytes.NewReader(raw))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&file)
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse screen list %s: %w", path, err)
+	}
+
+	screener := &ListScreener{
+		Allowed: make(map[string]bool, len(file.Allow)),
+		Denied:  make(map[string]string, len(file.Deny)),
+	}
+	for _, symbol := range file.Allow {
+		screener.Allowed[normalizeSymbol(symbol)] = true
+	}
+	for _, denied := range file.Deny {
+		reason := denied.Reason
+		if reason == "" {
+			reason = "on the deny list"
+		}
+		screener.Denied[normalizeSymbol(denied.Symbol)] = reason
+	}
+	return screener, nil
+}
+
+func (s *ListScreener) Screen(ctx context.Context, symbol string) (ScreenResult, error) {
+	symbol = normalizeSymbol(symbol)
+	if reason, ok := s.Denied[symbol]; ok {
+		return ScreenResult{Reason: reason}, nil
+	}
+	if len(s.Allowed) > 0 && !s.Allowed[symbol] {
+		return ScreenResult{Reason: "not on the allow list"}, nil
+	}
+	return ScreenResult{Allowed: true}, nil
+}
@@ -0,0 +1,182 @@
+package pies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+const (
+	// TotalWeight is what the slice weights of a pie add up to: weights
+	// are percentages, so a 60/40 pie has weights 60 and 40
+	TotalWeight = 100.0
+
+	// MaxPieDepth is how many levels of child pies a pie may contain
+	MaxPieDepth = 4
+
+	// weightTolerance absorbs floating point error when summing weights
+	weightTolerance = 1e-6
+)
+
+// symbolSearchProjection asks an InstrumentSearcher for an exact symbol match
+const symbolSearchProjection = "symbol-search"
+
+// InstrumentSearcher looks up instruments known to a brokerage. projection
+// selects the kind of search, e.g. "symbol-search" or "fundamental".
+type InstrumentSearcher interface {
+	SearchInstruments(ctx context.Context, query string, projection string) ([]Instrument, error)
+}
+
+// Validate checks that the pie has slices with non-empty, unique symbols and
+// positive weights summing to TotalWeight, and that no drift band or risk
+// limit is negative. A pie with a glide path needs no slice weights, its
+// anchors are checked instead. Child pies are validated the same way and may
+// be nested at most MaxPieDepth levels deep without referring back to an
+// enclosing pie.
+func (p Pie) Validate() error {
+	return p.validate(nil)
+}
+
+// validate checks the pie nested inside the pies in ancestors
+func (p Pie) validate(ancestors []*Pie) error {
+	if len(p.Slices) == 0 {
+		return errors.New("pie has no slices")
+	}
+
+	if err := p.Band.validate(); err != nil {
+		return err
+	}
+	if p.Risk != nil {
+		if err := p.Risk.validate(); err != nil {
+			return err
+		}
+	}
+
+	seen := make(map[string]int, len(p.Slices))
+	var total float64
+	for i, slice := range p.Slices {
+		symbol := normalizeSymbol(slice.Asset.Symbol)
+		if slice.Pie != nil {
+			if symbol != "" {
+				return fmt.Errorf("slice %d: has both symbol %s and a child pie", i, symbol)
+			}
+			if err := validateChild(slice.Pie, ancestors); err != nil {
+				return fmt.Errorf("slice %d: pie %s: %w", i, slice.Pie.Name, err)
+			}
+			symbol = "pie " + slice.Pie.Name
+		} else if slice.IsCash() {
+			symbol = "cash"
+		} else if symbol == "" {
+			return fmt.Errorf("slice %d: symbol is empty", i)
+		}
+		if first, ok := seen[symbol]; ok {
+			return fmt.Errorf("slice %d: %s is already in slice %d", i, symbol, first)
+		}
+		seen[symbol] = i
+
+		if slice.Band != nil {
+			if err := slice.Band.validate(); err != nil {
+				return fmt.Errorf("slice %d: %w", i, err)
+			}
+		}
+		if p.GlidePath != nil {
+			continue
+		}
+		if !(slice.Weight > 0) || math.IsInf(slice.Weight, 0) {
+			return fmt.Errorf("slice %d: weight of %s must be > 0, got %v", i, symbol, slice.Weight)
+		}
+		total += slice.Weight
+	}
+
+	if p.GlidePath != nil {
+		return p.GlidePath.validate(p)
+	}
+	if math.Abs(total-TotalWeight) > weightTolerance {
+		return fmt.Errorf("slice weights sum to %v, want %v", total, TotalWeight)
+	}
+
+	return nil
+}
+
+// validateChild checks a child pie against the pies enclosing it
+func validateChild(child *Pie, ancestors []*Pie) error {
+	if slices.Contains(ancestors, child) {
+		return errors.New("pie contains itself")
+	}
+	if len(ancestors)+1 > MaxPieDepth {
+		return fmt.Errorf("pies are nested more than %d levels deep", MaxPieDepth)
+	}
+	return child.validate(append(ancestors, child))
+}
+
+// Normalize returns a copy of the pie with its weights rescaled to sum to
+// exactly TotalWeight. Pies whose weights do not sum to a positive number
+// are returned unchanged.
+func (p Pie) Normalize() Pie {
+	var total float64
+	for _, slice := range p.Slices {
+		total += slice.Weight
+	}
+
+	normalized := p
+	normalized.Slices = append([]Slice(nil), p.Slices...)
+	if !(total > 0) || math.IsInf(total, 0) {
+		return normalized
+	}
+
+	// The last slice takes whatever rounding error remains so the sum is exact
+	remaining := TotalWeight
+	for i := range normalized.Slices {
+		if i == len(normalized.Slices)-1 {
+			normalized.Slices[i].Weight = remaining
+			break
+		}
+		normalized.Slices[i].Weight = normalized.Slices[i].Weight / total * TotalWeight
+		remaining -= normalized.Slices[i].Weight
+	}
+
+	return normalized
+}
+
+// ValidatePie checks that every slice of the flattened pie refers to a symbol
+// the brokerage knows and that is still listed on an exchange
+func ValidatePie(ctx context.Context, searcher InstrumentSearcher, pie Pie) error {
+	flat, err := pie.Flatten()
+	if err != nil {
+		return err
+	}
+
+	for i, slice := range flat.Slices {
+		if slice.IsCash() {
+			continue
+		}
+		symbol := slice.Asset.Symbol
+		instruments, err := searcher.SearchInstruments(ctx, symbol, symbolSearchProjection)
+		if err != nil {
+			return fmt.Errorf("slice %d: failed to look up %s: %w", i, symbol, err)
+		}
+
+		instrument, ok := findInstrument(instruments, symbol)
+		if !ok {
+			return fmt.Errorf("slice %d: unknown symbol %s", i, symbol)
+		}
+		if instrument.Exchange == "" {
+			return fmt.Errorf("slice %d: %s is not listed on any exchange", i, symbol)
+		}
+	}
+
+	return nil
+}
+
+// findInstrument returns the instrument whose symbol matches exactly
+func findInstrument(instruments []Instrument, symbol string) (Instrument, bool) {
+	for _, instrument := range instruments {
+		if strings.EqualFold(instrument.Symbol, symbol) {
+			return instrument, true
+		}
+	}
+	return Instrument{}, false
+}
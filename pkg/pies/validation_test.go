@@ -0,0 +1,183 @@
+package pies_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/alysoliman1/money-pies/pkg/pies/piestest"
+	"github.com/shopspring/decimal"
+)
+
+// weightedPie returns a pie of the symbols in symbols with weights
+func weightedPie(symbols []string, weights ...float64) pies.Pie {
+	pie := pies.Pie{Name: "test"}
+	for i, weight := range weights {
+		pie.Slices = append(pie.Slices, pies.Slice{Weight: weight, Asset: pies.Asset{Symbol: symbols[i]}})
+	}
+	return pie
+}
+
+func TestPieValidate(t *testing.T) {
+	three := []string{"VTI", "VXUS", "BND"}
+
+	tests := []struct {
+		name    string
+		pie     pies.Pie
+		wantErr string // Empty when the pie is valid
+	}{
+		{name: "valid", pie: weightedPie(three, 50, 30, 20)},
+		{name: "thirds within tolerance", pie: weightedPie(three, 33.333333333, 33.333333333, 33.333333334)},
+		{name: "decimal rounding noise", pie: weightedPie(three, 10.1, 20.2, 69.7)},
+		{name: "just short of 100", pie: weightedPie(three, 50, 30, 19.9999), wantErr: "slice weights sum to 99.9999, want 100"},
+		{name: "just over 100", pie: weightedPie(three, 50, 30, 20.00001), wantErr: "slice weights sum to 100.00001, want 100"},
+		{name: "sums to 87", pie: weightedPie(three, 50, 30, 7), wantErr: "slice weights sum to 87, want 100"},
+		{name: "fractions instead of percentages", pie: weightedPie(three, 0.5, 0.3, 0.2), wantErr: "slice weights sum to 1, want 100"},
+		{name: "no slices", pie: pies.Pie{Name: "empty"}, wantErr: "pie has no slices"},
+		{name: "zero weight", pie: weightedPie(three, 50, 50, 0), wantErr: "slice 2: weight of BND must be > 0, got 0"},
+		{name: "negative weight", pie: weightedPie(three, 60, 50, -10), wantErr: "slice 2: weight of BND must be > 0, got -10"},
+		{name: "NaN weight", pie: weightedPie(three, 50, 50, math.NaN()), wantErr: "slice 2: weight of BND must be > 0, got NaN"},
+		{name: "infinite weight", pie: weightedPie(three, 50, 50, math.Inf(1)), wantErr: "slice 2: weight of BND must be > 0, got +Inf"},
+		{name: "empty symbol", pie: weightedPie([]string{"VTI", " "}, 50, 50), wantErr: "slice 1: symbol is empty"},
+		{name: "duplicate symbol", pie: weightedPie([]string{"VTI", "BND", "VTI"}, 40, 40, 20), wantErr: "slice 2: VTI is already in slice 0"},
+		{name: "duplicate differing in case", pie: weightedPie([]string{"VTI", " vti"}, 50, 50), wantErr: "slice 1: VTI is already in slice 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.pie.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate() error = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPieNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights []float64
+		want    []float64 // Nil checks only the sum
+	}{
+		{name: "rescales to 100", weights: []float64{1, 1, 2}, want: []float64{25, 25, 50}},
+		{name: "rescales an 87% pie", weights: []float64{43.5, 43.5}, want: []float64{50, 50}},
+		{name: "thirds sum exactly", weights: []float64{1, 1, 1}},
+		{name: "zero total is unchanged", weights: []float64{0, 0}, want: []float64{0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pie := weightedPie([]string{"A", "B", "C"}, tt.weights...)
+			normalized := pie.Normalize()
+
+			var total float64
+			for i, slice := range normalized.Slices {
+				if tt.want != nil && slice.Weight != tt.want[i] {
+					t.Errorf("slice %d weight = %v, want %v", i, slice.Weight, tt.want[i])
+				}
+				total += slice.Weight
+			}
+			if (tt.want == nil || tt.want[0] != 0) && total != pies.TotalWeight {
+				t.Errorf("normalized weights sum to %v, want exactly %v", total, pies.TotalWeight)
+			}
+			for i, slice := range pie.Slices {
+				if slice.Weight != tt.weights[i] {
+					t.Errorf("Normalize() changed the original slice %d to %v", i, slice.Weight)
+				}
+			}
+		})
+	}
+}
+
+func TestInvestorRejectsInvalidPie(t *testing.T) {
+	b := piestest.EmptyAccount(decimal.NewFromInt(1000))
+	investor := &pies.Investor{BrokerageClient: b}
+	pie := weightedPie([]string{"VTI", "BND"}, 60, 27)
+
+	if _, err := investor.GetPieStatus(t.Context(), pie); err == nil || !strings.Contains(err.Error(), "sum to 87") {
+		t.Errorf("GetPieStatus() error = %v, want the weight sum error", err)
+	}
+	if _, err := investor.ComputeRebalancePlan(t.Context(), pie, pies.RebalanceOptions{}); err == nil || !strings.Contains(err.Error(), "sum to 87") {
+		t.Errorf("ComputeRebalancePlan() error = %v, want the weight sum error", err)
+	}
+	if calls := b.Calls(); len(calls) != 0 {
+		t.Errorf("an invalid pie reached the brokerage: %+v", calls)
+	}
+}
+
+// instrumentSearcher answers symbol searches from a map of instruments by
+// symbol, failing for the symbols in errs
+type instrumentSearcher struct {
+	instruments map[string]pies.Instrument
+	errs        map[string]error
+	queries     []string
+}
+
+func (s *instrumentSearcher) SearchInstruments(ctx context.Context, query string, projection string) ([]pies.Instrument, error) {
+	s.queries = append(s.queries, query+" "+projection)
+	if err := s.errs[query]; err != nil {
+		return nil, err
+	}
+	instrument, ok := s.instruments[query]
+	if !ok {
+		return nil, nil
+	}
+	return []pies.Instrument{instrument}, nil
+}
+
+func TestValidatePie(t *testing.T) {
+	listed := map[string]pies.Instrument{
+		"VTI":  {Symbol: "VTI", Exchange: "NYSE Arca", AssetType: "ETF"},
+		"BND":  {Symbol: "BND", Exchange: "NASDAQ", AssetType: "ETF"},
+		"VXUS": {Symbol: "vxus", Exchange: "NASDAQ", AssetType: "ETF"},
+		"OLD":  {Symbol: "OLD", AssetType: "EQUITY"},
+		"VT":   {Symbol: "VTV", Exchange: "NYSE Arca", AssetType: "ETF"},
+	}
+	lookupFailed := errors.New("lookup failed")
+	core := testPie("VTI", 50, "BND", 50)
+
+	tests := []struct {
+		name    string
+		pie     pies.Pie
+		wantErr string // Empty when the pie is valid
+	}{
+		{name: "listed symbols", pie: testPie("VTI", 60, "BND", 40)},
+		{name: "symbol differing in case", pie: testPie("VTI", 60, "VXUS", 40)},
+		{name: "cash slices are not looked up", pie: pies.Pie{Name: "test", Slices: []pies.Slice{
+			{Weight: 90, Asset: pies.Asset{Symbol: "VTI"}},
+			{Weight: 10, Asset: pies.Asset{TypeName: pies.AssetTypeCash}},
+		}}},
+		{name: "nested pies are flattened", pie: pies.Pie{Name: "test", Slices: []pies.Slice{
+			{Weight: 50, Pie: &core},
+			{Weight: 50, Asset: pies.Asset{Symbol: "VXUS"}},
+		}}},
+		{name: "unknown symbol", pie: testPie("VTI", 60, "NOPE", 40), wantErr: "slice 1: unknown symbol NOPE"},
+		{name: "only a similar symbol", pie: testPie("VT", 100), wantErr: "slice 0: unknown symbol VT"},
+		{name: "delisted symbol", pie: testPie("VTI", 60, "OLD", 40), wantErr: "slice 1: OLD is not listed on any exchange"},
+		{name: "failed lookup", pie: testPie("FAIL", 100), wantErr: "slice 0: failed to look up FAIL: lookup failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			searcher := &instrumentSearcher{instruments: listed, errs: map[string]error{"FAIL": lookupFailed}}
+			err := pies.ValidatePie(t.Context(), searcher, tt.pie)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("ValidatePie() error = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("ValidatePie() error = %v, want %q", err, tt.wantErr)
+			}
+			for _, query := range searcher.queries {
+				if !strings.HasSuffix(query, " symbol-search") {
+					t.Errorf("searched %q, want symbol searches only", query)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package pies
+
+import (
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// AllocateWholeShares converts dollar targets into whole share counts using
+// the largest remainder method. Every target is first rounded down to whole
+// shares; the cash this strands then buys one share at a time of whichever
+// affordable symbol is furthest below its target, until no symbol still
+// below its target is affordable. prices must hold a positive price for
+// every symbol in targets. Targets adding up to more than cash are scaled
+// down to fit.
+//
+// It returns the shares to buy per symbol and the cash left over. No symbol
+// gets more than one share beyond its target, so cash can be left over
+// even when it would buy a share of a symbol already on target.
+func AllocateWholeShares(targets map[string]decimal.Decimal, prices map[string]decimal.Decimal, cash decimal.Decimal) (map[string]int, decimal.Decimal) {
+	symbols := make([]string, 0, len(targets))
+	var total decimal.Decimal
+	for symbol, target := range targets {
+		if !prices[symbol].IsPositive() {
+			continue
+		}
+		symbols = append(symbols, symbol)
+		total = total.Add(decimal.Max(target, decimal.Zero))
+	}
+	sort.Strings(symbols)
+
+	remaining := decimal.Max(cash, decimal.Zero)
+	shares := make(map[string]int, len(symbols))
+	shortfalls := make(map[string]decimal.Decimal, len(symbols))
+	for _, symbol := range symbols {
+		target := decimal.Max(targets[symbol], decimal.Zero)
+		if total.GreaterThan(cash) {
+			target = target.Mul(remaining).Div(total)
+		}
+		count := target.Div(prices[symbol]).Floor()
+		shares[symbol] = int(count.IntPart())
+		shortfalls[symbol] = target.Sub(count.Mul(prices[symbol]))
+	}
+	for _, symbol := range symbols {
+		remaining = remaining.Sub(decimal.NewFromInt(int64(shares[symbol])).Mul(prices[symbol]))
+	}
+
+	for {
+		best := ""
+		for _, symbol := range symbols {
+			if !shortfalls[symbol].IsPositive() || prices[symbol].GreaterThan(remaining) {
+				continue
+			}
+			if best == "" || shortfalls[symbol].GreaterThan(shortfalls[best]) {
+				best = symbol
+			}
+		}
+		if best == "" {
+			break
+		}
+		shares[best]++
+		shortfalls[best] = shortfalls[best].Sub(prices[best])
+		remaining = remaining.Sub(prices[best])
+	}
+
+	return shares, remaining
+}
@@ -0,0 +1,163 @@
+package pies_test
+
+import (
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/alysoliman1/money-pies/pkg/pies"
+	"github.com/shopspring/decimal"
+)
+
+// decimals converts a map of float64 amounts into decimals
+func decimals(amounts map[string]float64) map[string]decimal.Decimal {
+	converted := make(map[string]decimal.Decimal, len(amounts))
+	for symbol, amount := range amounts {
+		converted[symbol] = decimal.NewFromFloat(amount)
+	}
+	return converted
+}
+
+func TestAllocateWholeShares(t *testing.T) {
+	tests := []struct {
+		name         string
+		targets      map[string]float64
+		prices       map[string]float64
+		cash         float64
+		want         map[string]int
+		wantLeftover float64
+	}{
+		{
+			name:         "exact multiples",
+			targets:      map[string]float64{"A": 300, "B": 200},
+			prices:       map[string]float64{"A": 100, "B": 50},
+			cash:         500,
+			want:         map[string]int{"A": 3, "B": 4},
+			wantLeftover: 0,
+		},
+		{
+			// A is 80 short after one share and B 20 short after two
+			name:         "stranded cash buys the largest shortfall",
+			targets:      map[string]float64{"A": 180, "B": 120},
+			prices:       map[string]float64{"A": 100, "B": 50},
+			cash:         300,
+			want:         map[string]int{"A": 2, "B": 2},
+			wantLeftover: 0,
+		},
+		{
+			// A's $50 shortfall cannot buy a share, B's $10 one can
+			name:         "stranded cash buys a smaller affordable shortfall",
+			targets:      map[string]float64{"A": 250, "B": 250},
+			prices:       map[string]float64{"A": 100, "B": 60},
+			cash:         500,
+			want:         map[string]int{"A": 2, "B": 5},
+			wantLeftover: 0,
+		},
+		{
+			name:         "a symbol on target gets no extra shares",
+			targets:      map[string]float64{"A": 150, "B": 10},
+			prices:       map[string]float64{"A": 100, "B": 5},
+			cash:         160,
+			want:         map[string]int{"A": 1, "B": 2},
+			wantLeftover: 50,
+		},
+		{
+			name:         "an expensive symbol's shortfall is not spent on a cheap one",
+			targets:      map[string]float64{"A": 950, "B": 50},
+			prices:       map[string]float64{"A": 500, "B": 10},
+			cash:         1000,
+			want:         map[string]int{"A": 1, "B": 5},
+			wantLeftover: 450,
+		},
+		{
+			name:         "targets beyond cash are scaled down",
+			targets:      map[string]float64{"A": 1000, "B": 1000},
+			prices:       map[string]float64{"A": 100, "B": 100},
+			cash:         500,
+			want:         map[string]int{"A": 3, "B": 2},
+			wantLeftover: 0,
+		},
+		{
+			name:         "unpriced symbols get nothing",
+			targets:      map[string]float64{"A": 200, "B": 200},
+			prices:       map[string]float64{"A": 100},
+			cash:         400,
+			want:         map[string]int{"A": 2},
+			wantLeftover: 200,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares, leftover := pies.AllocateWholeShares(decimals(tt.targets), decimals(tt.prices), decimal.NewFromFloat(tt.cash))
+
+			for symbol := range tt.targets {
+				if shares[symbol] != tt.want[symbol] {
+					t.Errorf("shares = %v, want %v", shares, tt.want)
+					break
+				}
+			}
+			if want := decimal.NewFromFloat(tt.wantLeftover); !leftover.Equal(want) {
+				t.Errorf("leftover = %s, want %s", leftover, want)
+			}
+		})
+	}
+}
+
+func TestAllocateWholeSharesProperties(t *testing.T) {
+	rng := rand.New(rand.NewPCG(53, 1))
+
+	for n := range 2000 {
+		targets := make(map[string]decimal.Decimal)
+		prices := make(map[string]decimal.Decimal)
+		var total decimal.Decimal
+		for i := range 1 + rng.IntN(6) {
+			symbol := fmt.Sprintf("S%d", i)
+			targets[symbol] = decimal.New(rng.Int64N(500_000), -2)
+			prices[symbol] = decimal.New(1+rng.Int64N(50_000), -2)
+			total = total.Add(targets[symbol])
+		}
+		// Mostly the targets' sum, as the rebalancer passes, sometimes less
+		// or more cash than that
+		cash := total
+		switch rng.IntN(3) {
+		case 1:
+			cash = decimal.New(rng.Int64N(1_000_000), -2)
+		case 2:
+			cash = total.Add(decimal.New(rng.Int64N(100_000), -2))
+		}
+
+		shares, leftover := pies.AllocateWholeShares(targets, prices, cash)
+		name := fmt.Sprintf("case %d: targets %v, prices %v, cash %s", n, targets, prices, cash)
+
+		var spent decimal.Decimal
+		for symbol, count := range shares {
+			if count < 0 {
+				t.Fatalf("%s: %d shares of %s", name, count, symbol)
+			}
+			value := decimal.NewFromInt(int64(count)).Mul(prices[symbol])
+			spent = spent.Add(value)
+
+			target := targets[symbol]
+			if total.GreaterThan(cash) {
+				target = target.Mul(cash).Div(total)
+			}
+			if value.GreaterThan(target.Add(prices[symbol])) {
+				t.Fatalf("%s: %d shares of %s are worth %s, more than a share over the target %s", name, count, symbol, value, target)
+			}
+		}
+		if spent.GreaterThan(cash) {
+			t.Fatalf("%s: spent %s, more than the cash", name, spent)
+		}
+		if !spent.Add(leftover).Equal(cash) {
+			t.Fatalf("%s: spent %s and left %s, want them to add up to the cash", name, spent, leftover)
+		}
+
+		// Map iteration order differs between calls, the result must not
+		again, againLeftover := pies.AllocateWholeShares(maps.Clone(targets), maps.Clone(prices), cash)
+		if !maps.Equal(shares, again) || !leftover.Equal(againLeftover) {
+			t.Fatalf("%s: got %v and %v from the same input", name, shares, again)
+		}
+	}
+}